@@ -6,9 +6,16 @@ import (
 	"runtime/debug"
 
 	"github.com/vvka-141/pgmi/internal/cli"
+	"github.com/vvka-141/pgmi/internal/db"
+	"github.com/vvka-141/pgmi/internal/db/krb5"
 	"github.com/vvka-141/pgmi/pkg/pgmi"
 )
 
+func init() {
+	// GSSAPI auth (--gssapi) needs a Kerberos implementation; pgx has none.
+	db.RegisterGSSProvider(krb5.NewGSS)
+}
+
 func main() {
 	// Recover from panics to ensure graceful exits with stack traces
 	defer func() {
@@ -1,12 +1,15 @@
 package main
 
 import (
+	"encoding/binary"
+	"io"
 	"net"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"runtime"
 	"strconv"
+	"strings"
 	"testing"
 	"time"
 
@@ -190,3 +193,74 @@ func TestMain_OverwriteWithoutForce_DoesNotHangOnOpenStdin(t *testing.T) {
 			exitErr.ExitCode(), pgmi.ExitApprovalDenied, pgmi.ExitTimeout)
 	}
 }
+
+// TestMain_GSSAPI_ReachesKerberosProvider checks that the binary registers a
+// Kerberos provider. It used to register none, so every --gssapi deploy
+// stopped with "this pgmi build has none" before dialing. A fake server asks
+// for GSSAPI auth; pgmi must hand the exchange to the provider, which then
+// fails on the missing credential cache.
+func TestMain_GSSAPI_ReachesKerberosProvider(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("net.Listen: %v", err)
+	}
+	defer listener.Close()
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				// StartupMessage: length, then the rest; nothing in it matters.
+				var length uint32
+				if binary.Read(conn, binary.BigEndian, &length) != nil || length < 4 {
+					return
+				}
+				if _, err := io.CopyN(io.Discard, conn, int64(length-4)); err != nil {
+					return
+				}
+				// AuthenticationGSS: 'R', length 8, code 7.
+				_, _ = conn.Write([]byte{'R', 0, 0, 0, 8, 0, 0, 0, 7})
+				_, _ = io.Copy(io.Discard, conn)
+			}()
+		}
+	}()
+
+	projectDir := t.TempDir()
+	if err := os.WriteFile(
+		filepath.Join(projectDir, "deploy.sql"), []byte("SELECT 1;\n"), 0644,
+	); err != nil {
+		t.Fatalf("writing deploy.sql: %v", err)
+	}
+
+	krbDir := t.TempDir()
+	krbConf := filepath.Join(krbDir, "krb5.conf")
+	if err := os.WriteFile(krbConf, []byte("[libdefaults]\n  default_realm = EXAMPLE.COM\n"), 0644); err != nil {
+		t.Fatalf("writing krb5.conf: %v", err)
+	}
+	missing := filepath.Join(krbDir, "krb5cc_missing")
+	cmd := pgmiCommand(t, "deploy", projectDir,
+		"--gssapi",
+		"--host", "127.0.0.1",
+		"--port", strconv.Itoa(listener.Addr().(*net.TCPAddr).Port),
+		"--sslmode", "disable",
+		"--database", "irrelevant",
+		"--username", "irrelevant",
+		"--timeout", "30s",
+		"--force",
+	)
+	cmd.Env = append(cmd.Env, "KRB5_CONFIG="+krbConf, "KRB5CCNAME=FILE:"+missing, "PGSERVICE=", "PGSERVICEFILE=")
+	out, err := cmd.CombinedOutput()
+	if err == nil {
+		t.Fatalf("deploy succeeded against a fake server:\n%s", out)
+	}
+	if strings.Contains(string(out), "has none") {
+		t.Fatalf("no Kerberos provider is registered:\n%s", out)
+	}
+	if !strings.Contains(string(out), "kerberos:") || !strings.Contains(string(out), missing) {
+		t.Errorf("output does not show the provider reading the credential cache:\n%s", out)
+	}
+}
@@ -2,7 +2,9 @@ package ai
 
 import (
 	"encoding/json"
+	"slices"
 
+	"github.com/vvka-141/pgmi/internal/contract"
 	"github.com/vvka-141/pgmi/pkg/pgmi"
 )
 
@@ -12,12 +14,17 @@ type ContractView struct {
 	// Note carries behaviour a column list cannot express — a normalisation
 	// applied on load, say. Omitted where there is nothing to say.
 	Note string `json:"note,omitempty"`
+	// Since is the session API version that introduced the view; empty for v1.
+	Since string `json:"since,omitempty"`
 }
 
 type ContractFunction struct {
 	Name    string   `json:"name"`
 	Args    []string `json:"args"`
 	Returns []string `json:"returns"`
+	// Since is the session API version that introduced the function; empty
+	// for v1.
+	Since string `json:"since,omitempty"`
 }
 
 type ContractExitCode struct {
@@ -58,6 +65,10 @@ type ContractExecution struct {
 }
 
 type Contract struct {
+	// APIVersion is the session API version this contract describes, the
+	// value --compat selects. SupportedVersions lists every value it accepts.
+	APIVersion        string             `json:"api_version"`
+	SupportedVersions []string           `json:"supported_versions"`
 	Views             []ContractView     `json:"views"`
 	Functions         []ContractFunction `json:"functions"`
 	Types             []ContractType     `json:"types"`
@@ -68,7 +79,35 @@ type Contract struct {
 	ExecutionContract ContractExecution  `json:"execution_contract"`
 }
 
+// GetContract describes the latest session API.
 func GetContract() Contract {
+	c, _ := GetContractFor("")
+	return c
+}
+
+// GetContractFor describes the session API a --compat value selects: objects
+// introduced by a later version are left out. An empty version means latest.
+func GetContractFor(version string) (Contract, error) {
+	_, v, err := contract.Load(version)
+	if err != nil {
+		return Contract{}, err
+	}
+	c := fullContract()
+	c.APIVersion = string(v)
+	for _, sv := range contract.SupportedVersions() {
+		c.SupportedVersions = append(c.SupportedVersions, string(sv))
+	}
+	c.Views = slices.DeleteFunc(c.Views, func(cv ContractView) bool { return newerThan(cv.Since, v) })
+	c.Functions = slices.DeleteFunc(c.Functions, func(cf ContractFunction) bool { return newerThan(cf.Since, v) })
+	return c, nil
+}
+
+// newerThan reports whether an object introduced in since is missing from v.
+func newerThan(since string, v contract.Version) bool {
+	return since != "" && v.Before(contract.Version(since))
+}
+
+func fullContract() Contract {
 	return Contract{
 		Views: []ContractView{
 			{
@@ -96,6 +135,12 @@ func GetContract() Contract {
 				Name:    "pgmi_test_directory_view",
 				Columns: []string{"path", "parent_path", "depth"},
 			},
+			{
+				Name:    "pgmi_plan_detail_view",
+				Columns: []string{"path", "content", "checksum", "generic_id", "id", "idempotent", "description", "sort_key", "execution_order", "custom", "name", "directory", "is_sql_file", "size_bytes", "phase", "phase_count", "depends_on", "dependency_order", "custom"},
+				Note:    "One row per pgmi_plan_view row, plus file columns and phase (1-based occurrence of the file in execution order) / phase_count (its number of sort keys). depends_on holds the resolved paths of the scripts named in <dependsOn>; dependency_order is execution_order adjusted for them, identical unless a file declares dependencies. custom is JSONB of prefixed <pgmi-meta> attributes by prefix, '{}' when none: custom->'ops'->>'owner'. Unordered: ORDER BY execution_order, or dependency_order to honour <dependsOn>.",
+				Since:   "2",
+			},
 		},
 		Functions: []ContractFunction{
 			{
@@ -138,6 +183,36 @@ func GetContract() Contract {
 				Args:    []string{"target_schema text", "p_pattern text DEFAULT NULL"},
 				Returns: []string{"void"},
 			},
+			{
+				Name:    "pgmi_api_version",
+				Args:    []string{},
+				Returns: []string{"text"},
+				Since:   "2",
+			},
+			{
+				Name:    "pgmi_execute",
+				Args:    []string{"p_path text", "p_sort_key text DEFAULT NULL", "p_savepoint boolean DEFAULT false"},
+				Returns: []string{"boolean"},
+				Since:   "2",
+			},
+			{
+				Name:    "pgmi_execute_plan",
+				Args:    []string{"p_filter text DEFAULT NULL", "p_savepoint boolean DEFAULT false"},
+				Returns: []string{"integer"},
+				Since:   "2",
+			},
+			{
+				Name:    "pgmi_catalog_fingerprint",
+				Args:    []string{},
+				Returns: []string{"object_type", "object_name", "definition"},
+				Since:   "2",
+			},
+			{
+				Name:    "pgmi_capture_fingerprint",
+				Args:    []string{},
+				Returns: []string{"integer"},
+				Since:   "2",
+			},
 		},
 		Types: []ContractType{
 			{
@@ -166,12 +241,13 @@ func GetContract() Contract {
 			{Code: pgmi.ExitUsageError, Name: "ExitUsageError", Description: "CLI usage error (missing args, invalid flags)"},
 			{Code: pgmi.ExitPanic, Name: "ExitPanic", Description: "Internal panic (unexpected crash)"},
 			{Code: pgmi.ExitConfigError, Name: "ExitConfigError", Description: "Invalid pgmi configuration, rejected before connecting (a parameter your SQL requires is exit 13)"},
-			{Code: pgmi.ExitConnectionError, Name: "ExitConnectionError", Description: "Failed to connect to database"},
+			{Code: pgmi.ExitConnectionError, Name: "ExitConnectionError", Description: "Failed to connect to database, or the server is a standby or read-only"},
 			{Code: pgmi.ExitApprovalDenied, Name: "ExitApprovalDenied", Description: "User denied overwrite approval"},
 			{Code: pgmi.ExitExecutionFailed, Name: "ExitExecutionFailed", Description: "SQL execution failed"},
 			{Code: pgmi.ExitDeploySQLMissing, Name: "ExitDeploySQLMissing", Description: "deploy.sql not found"},
 			{Code: pgmi.ExitConcurrentDeploy, Name: "ExitConcurrentDeploy", Description: "Another pgmi deployment is in progress"},
 			{Code: pgmi.ExitTimeout, Name: "ExitTimeout", Description: "Operation exceeded --timeout (context deadline exceeded)"},
+			{Code: pgmi.ExitDriftDetected, Name: "ExitDriftDetected", Description: "pgmi drift found objects changed since the last captured fingerprint"},
 			{Code: pgmi.ExitInterrupted, Name: "ExitInterrupted", Description: "Process interrupted by SIGINT (Ctrl-C)"},
 		},
 		Macros: []ContractMacro{
@@ -205,7 +281,15 @@ func GetContract() Contract {
 }
 
 func GetContractJSON() (string, error) {
-	c := GetContract()
+	return GetContractJSONFor("")
+}
+
+// GetContractJSONFor renders GetContractFor(version) as indented JSON.
+func GetContractJSONFor(version string) (string, error) {
+	c, err := GetContractFor(version)
+	if err != nil {
+		return "", err
+	}
 	data, err := json.MarshalIndent(c, "", "  ")
 	if err != nil {
 		return "", err
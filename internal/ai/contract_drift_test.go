@@ -15,23 +15,20 @@ func TestContract_ViewsExistInSQL(t *testing.T) {
 	if err != nil {
 		t.Fatalf("read schema.sql: %v", err)
 	}
-	apiV1, err := os.ReadFile("../contract/api-v1.sql")
-	if err != nil {
-		t.Fatalf("read api-v1.sql: %v", err)
-	}
+	apiSQL := readContractSQL(t)
 
-	combined := string(schema) + "\n" + string(apiV1)
+	combined := string(schema) + "\n" + apiSQL
 	c := ai.GetContract()
 
 	for _, v := range c.Views {
 		if !strings.Contains(combined, v.Name) {
-			t.Errorf("view %q declared in contract but not found in schema.sql or api-v1.sql", v.Name)
+			t.Errorf("view %q declared in contract but not found in schema.sql or api-v*.sql", v.Name)
 		}
 	}
 
 	for _, f := range c.Functions {
 		if !strings.Contains(combined, f.Name) {
-			t.Errorf("function %q declared in contract but not found in schema.sql or api-v1.sql", f.Name)
+			t.Errorf("function %q declared in contract but not found in schema.sql or api-v*.sql", f.Name)
 		}
 	}
 
@@ -43,7 +40,7 @@ func TestContract_ViewsExistInSQL(t *testing.T) {
 
 	for _, ct := range c.Types {
 		if !strings.Contains(combined, ct.Name) {
-			t.Errorf("type %q declared in contract but not found in schema.sql or api-v1.sql", ct.Name)
+			t.Errorf("type %q declared in contract but not found in schema.sql or api-v*.sql", ct.Name)
 		}
 		for _, ev := range ct.Events {
 			if !strings.Contains(combined, "'"+ev+"'") {
@@ -58,10 +55,7 @@ func TestContract_ViewColumnsMatchSchema(t *testing.T) {
 	if err != nil {
 		t.Fatalf("read schema.sql: %v", err)
 	}
-	apiV1, err := os.ReadFile("../contract/api-v1.sql")
-	if err != nil {
-		t.Fatalf("read api-v1.sql: %v", err)
-	}
+	apiSQL := readContractSQL(t)
 
 	backingTable := map[string]string{
 		"pgmi_source_view":          "_pgmi_source",
@@ -71,9 +65,9 @@ func TestContract_ViewColumnsMatchSchema(t *testing.T) {
 		"pgmi_source_metadata_view": "_pgmi_source_metadata",
 	}
 
-	combined := string(schema) + "\n" + string(apiV1)
+	combined := string(schema) + "\n" + apiSQL
 	c := ai.GetContract()
-	colRe := regexp.MustCompile(`(?m)^\s+"?(\w+)"?\s+(?:TEXT|INTEGER|BIGINT|BOOLEAN|BOOL|UUID|INT|TIMESTAMPTZ|SERIAL)`)
+	colRe := regexp.MustCompile(`(?m)^\s+"?(\w+)"?\s+(?:TEXT|INTEGER|BIGINT|BOOLEAN|BOOL|UUID|INT|TIMESTAMPTZ|SERIAL|JSONB)`)
 
 	for _, v := range c.Views {
 		table, ok := backingTable[v.Name]
@@ -119,11 +113,8 @@ func TestContract_FunctionDefaultsMatchSQL(t *testing.T) {
 	if err != nil {
 		t.Fatalf("read schema.sql: %v", err)
 	}
-	apiV1, err := os.ReadFile("../contract/api-v1.sql")
-	if err != nil {
-		t.Fatalf("read api-v1.sql: %v", err)
-	}
-	combined := string(schema) + "\n" + string(apiV1)
+	apiSQL := readContractSQL(t)
+	combined := string(schema) + "\n" + apiSQL
 
 	c := ai.GetContract()
 	for _, f := range c.Functions {
@@ -138,7 +129,9 @@ func TestContract_FunctionDefaultsMatchSQL(t *testing.T) {
 			defaultVal := strings.TrimSpace(parts[1])
 			paramName := strings.Fields(parts[0])[0]
 
-			searchDefault := paramName + " TEXT DEFAULT " + defaultVal
+			// parts[0] is "name type" as the contract writes it; SQL spells
+			// the same declaration, modulo case.
+			searchDefault := strings.TrimSpace(parts[0]) + " DEFAULT " + defaultVal
 			if !strings.Contains(strings.ToLower(combined), strings.ToLower(searchDefault)) {
 				t.Errorf("function %q: contract default %q for param %q not found in SQL",
 					f.Name, defaultVal, paramName)
@@ -146,3 +139,18 @@ func TestContract_FunctionDefaultsMatchSQL(t *testing.T) {
 		}
 	}
 }
+
+// readContractSQL returns every api-vN.sql, concatenated: GetContract describes
+// the latest version, which is all of them applied in order.
+func readContractSQL(t *testing.T) string {
+	t.Helper()
+	var all []string
+	for _, name := range []string{"api-v1.sql", "api-v2.sql", "fingerprint.sql"} {
+		body, err := os.ReadFile("../contract/" + name)
+		if err != nil {
+			t.Fatalf("read %s: %v", name, err)
+		}
+		all = append(all, string(body))
+	}
+	return strings.Join(all, "\n")
+}
@@ -112,3 +112,35 @@ func TestGetContract_MacroForms(t *testing.T) {
 		}
 	}
 }
+
+func TestGetContractFor_V1OmitsLaterObjects(t *testing.T) {
+	latest := GetContract()
+	if latest.APIVersion != "2" {
+		t.Fatalf("latest contract describes v%s, want v2", latest.APIVersion)
+	}
+
+	v1, err := GetContractFor("1")
+	if err != nil {
+		t.Fatalf("GetContractFor(1): %v", err)
+	}
+	if v1.APIVersion != "1" {
+		t.Errorf("APIVersion = %q, want 1", v1.APIVersion)
+	}
+	for _, v := range v1.Views {
+		if v.Since != "" {
+			t.Errorf("v1 contract lists view %s, introduced in v%s", v.Name, v.Since)
+		}
+	}
+	for _, f := range v1.Functions {
+		if f.Since != "" {
+			t.Errorf("v1 contract lists function %s, introduced in v%s", f.Name, f.Since)
+		}
+	}
+	if len(v1.Views) >= len(latest.Views) {
+		t.Error("v1 contract should list fewer views than the latest")
+	}
+
+	if _, err := GetContractFor("99"); err == nil {
+		t.Error("expected an error for an unsupported version")
+	}
+}
@@ -51,12 +51,18 @@ var aiContractCmd = &cobra.Command{
 before writing SQL against pgmi views/functions to avoid hallucinating
 identifiers.
 
-Output includes: view names and columns, test function signatures,
-step types, exit codes, and preprocessor macro forms.`,
+Output includes: the session API version described, view names and columns,
+test function signatures, step types, exit codes, and preprocessor macro forms.
+Objects a later API version introduced carry a "since" field; --compat
+describes an older version, leaving them out.`,
 	Args: usageArgs(cobra.NoArgs),
 	RunE: runAIContract,
 }
 
+var aiContractFlags struct {
+	compat string
+}
+
 var aiClientCmd = &cobra.Command{
 	Use:   "client [lang]",
 	Short: "Print API client guidance for AI coding agents",
@@ -85,6 +91,9 @@ func init() {
 	aiCmd.AddCommand(aiSkillCmd)
 	aiCmd.AddCommand(aiContractCmd)
 	aiCmd.AddCommand(aiClientCmd)
+
+	aiContractCmd.Flags().StringVar(&aiContractFlags.compat, "compat", "",
+		"Describe this session API version (default: latest)")
 }
 
 func runAIOverview(cmd *cobra.Command, args []string) error {
@@ -148,7 +157,7 @@ func runAISkill(cmd *cobra.Command, args []string) error {
 }
 
 func runAIContract(cmd *cobra.Command, args []string) error {
-	out, err := ai.GetContractJSON()
+	out, err := ai.GetContractJSONFor(aiContractFlags.compat)
 	if err != nil {
 		return fmt.Errorf("failed to generate contract: %w", err)
 	}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"testing"
 
+	"github.com/vvka-141/pgmi/internal/config"
 	"github.com/vvka-141/pgmi/internal/ui"
 )
 
@@ -54,6 +55,30 @@ func TestSelectApprover(t *testing.T) {
 	}
 }
 
+// An overwrite_policy goes in front of whichever approver was selected. In a
+// CI job without --force it is the only gate; otherwise the usual one follows.
+func TestWithOverwritePolicy(t *testing.T) {
+	const connString = "postgresql://ci@ci-db.internal:5432/orders_test"
+	policy := &config.ProjectConfig{OverwritePolicy: &config.OverwritePolicy{Databases: []string{"*_test"}}}
+
+	got, err := withOverwritePolicy(ui.NewNonInteractiveApprover(), nil, connString, false, false)
+	if err != nil || fmt.Sprintf("%T", got) != "*ui.NonInteractiveApprover" {
+		t.Errorf("without a policy the approver must be unchanged, got %T, %v", got, err)
+	}
+
+	// CI without --force: the policy approves on its own.
+	got, err = withOverwritePolicy(ui.NewNonInteractiveApprover(), policy, connString, false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if approved, err := got.RequestApproval(t.Context(), "orders_test"); !approved || err != nil {
+		t.Errorf("the policy should approve orders_test in CI, got %v, %v", approved, err)
+	}
+	if approved, _ := got.RequestApproval(t.Context(), "orders"); approved {
+		t.Error("the policy approved a database outside its patterns")
+	}
+}
+
 // The refusing approver must never approve, whatever it is asked about.
 func TestNonInteractiveApproverNeverApproves(t *testing.T) {
 	approved, err := ui.NewNonInteractiveApprover().RequestApproval(t.Context(), "mydb")
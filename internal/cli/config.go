@@ -11,6 +11,7 @@ import (
 	"github.com/vvka-141/pgmi/internal/config"
 	"github.com/vvka-141/pgmi/internal/tui"
 	"github.com/vvka-141/pgmi/internal/tui/wizards"
+	"github.com/vvka-141/pgmi/pkg/pgmi"
 )
 
 var configCmd = &cobra.Command{
@@ -21,8 +22,9 @@ var configCmd = &cobra.Command{
   pgmi config              In the current directory
   pgmi config ./project    In ./project
 
-The wizard handles local, Azure Entra ID, AWS IAM, and Google Cloud SQL
-auth. Requires an interactive terminal — for CI, write pgmi.yaml by hand.`,
+The wizard handles password, Azure Entra ID, AWS IAM, Google Cloud SQL,
+token helper, Kerberos (GSSAPI), and client certificate auth. Requires an
+interactive terminal — for CI, write pgmi.yaml by hand.`,
 	Args: usageArgs(cobra.MaximumNArgs(1)),
 	RunE: runConfig,
 }
@@ -75,6 +77,23 @@ func runConfig(cmd *cobra.Command, args []string) error {
 	}
 
 	fmt.Fprintf(os.Stderr, "Wrote %s\n", filepath.Join(targetDir, "pgmi.yaml"))
+	if connResult.Config.Service != "" {
+		fmt.Fprintf(os.Stderr, "Service: %s, from %s\n", connResult.Config.Service, connResult.Config.ServiceFile)
+	}
+	reportAuth(&connResult.Config)
 	offerSavePgpass(&connResult.Config)
 	return nil
 }
+
+// reportAuth says how the saved connection authenticates, and for the
+// methods that depend on something outside pgmi.yaml, what that is.
+func reportAuth(c *pgmi.ConnectionConfig) {
+	fmt.Fprintf(os.Stderr, "Authentication: %s\n", describeAuth(c))
+	switch c.AuthMethod {
+	case pgmi.AuthMethodGSSAPI:
+		fmt.Fprintln(os.Stderr, "  Uses the Kerberos ticket in your credential cache; run kinit first.")
+	case pgmi.AuthMethodCert:
+		fmt.Fprintf(os.Stderr, "  Key: %s\n", c.SSLKey)
+		fmt.Fprintln(os.Stderr, "  No password is sent; the server must use pg_hba.conf's cert method.")
+	}
+}
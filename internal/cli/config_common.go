@@ -1,12 +1,14 @@
 package cli
 
 import (
+	"cmp"
 	"errors"
 	"fmt"
 	"maps"
 	"os"
 	"path/filepath"
 	"slices"
+	"strings"
 	"time"
 
 	"github.com/joho/godotenv"
@@ -29,6 +31,7 @@ type connectionFlags struct {
 	password       string // not a CLI flag; set programmatically (e.g., from wizard)
 	database       string
 	sslMode        string
+	service        string
 	azure          bool
 	azureTenantID  string
 	azureClientID  string
@@ -36,9 +39,143 @@ type connectionFlags struct {
 	awsRegion      string
 	google         bool
 	googleInstance string
+	tokenCommand   string
+	tokenFile      string
+	gssapi         bool
+	krbSrvName     string
+	krbSPN         string
+	certAuth       bool
 	sslCert        string
 	sslKey         string
 	sslRootCert    string
+	sshHost        string
+	sshPort        int
+	sshUser        string
+	sshKey         string
+	sshKnownHosts  string
+}
+
+// addConnectionFlags registers the server and authentication flags every
+// command that connects shares. --connection and -d are left to each command:
+// what the connection string's database means differs between them.
+func addConnectionFlags(cmd *cobra.Command, f *connectionFlags) {
+	// Granular connection flags (PostgreSQL standard)
+	// Precedence: flag > environment variable > default
+	cmd.Flags().StringVarP(&f.host, "host", "h", "",
+		"PostgreSQL server host\n"+
+			"Precedence: --host > --service > $PGHOST > localhost")
+	cmd.Flags().IntVarP(&f.port, "port", "p", 0,
+		"PostgreSQL server port\n"+
+			"Precedence: --port > --service > $PGPORT > 5432")
+	cmd.Flags().StringVarP(&f.username, "username", "U", "",
+		"PostgreSQL user (default: $PGUSER or current OS user)")
+	cmd.Flags().StringVar(&f.sslMode, "sslmode", "",
+		"SSL mode: disable|allow|prefer|require|verify-ca|verify-full\n"+
+			"(default: prefer, or $PGSSLMODE)")
+	cmd.Flags().StringVar(&f.service, "service", "",
+		"pg_service.conf service to take connection parameters from\n"+
+			"Precedence: --service > $PGSERVICE > service in pgmi.yaml")
+
+	// Azure Entra ID flags
+	cmd.Flags().BoolVar(&f.azure, "azure", false,
+		"Enable Azure Entra ID authentication\n"+
+			"Uses DefaultAzureCredential chain (Managed Identity, Azure CLI, etc.)")
+	cmd.Flags().StringVar(&f.azureTenantID, "azure-tenant-id", "",
+		"Azure AD tenant/directory ID (overrides $AZURE_TENANT_ID)")
+	cmd.Flags().StringVar(&f.azureClientID, "azure-client-id", "",
+		"Azure AD application/client ID (overrides $AZURE_CLIENT_ID)")
+
+	// AWS IAM flags
+	cmd.Flags().BoolVar(&f.aws, "aws", false,
+		"Enable AWS IAM database authentication\n"+
+			"Uses default AWS credential chain (env vars, config file, IAM role, etc.)")
+	cmd.Flags().StringVar(&f.awsRegion, "aws-region", "",
+		"AWS region for RDS endpoint (overrides $AWS_REGION)")
+
+	// Google Cloud SQL IAM flags
+	cmd.Flags().BoolVar(&f.google, "google", false,
+		"Enable Google Cloud SQL IAM database authentication\n"+
+			"Uses Application Default Credentials (gcloud auth, service account, etc.)")
+	cmd.Flags().StringVar(&f.googleInstance, "google-instance", "",
+		"Cloud SQL instance connection name (format: project:region:instance)\n"+
+			"Required when --google is specified")
+
+	// External token flags
+	cmd.Flags().StringVar(&f.tokenCommand, "token-command", "",
+		"Command printing a token as JSON {\"token\", \"expiresAt\"}, used as the password\n"+
+			"Split like a shell would: quote arguments containing spaces, e.g.\n"+
+			"'\"/opt/My Tools/get-token\" --audience \"a b\"' (overrides $PGMI_TOKEN_COMMAND)")
+	cmd.Flags().StringVar(&f.tokenFile, "token-file", "",
+		"File holding a token kept current by an agent (Vault Agent, SPIFFE helper...)\n"+
+			"Re-read whenever it changes (overrides $PGMI_TOKEN_FILE)")
+
+	// Kerberos flags
+	cmd.Flags().BoolVar(&f.gssapi, "gssapi", false,
+		"Authenticate with a Kerberos ticket (GSSAPI) instead of a password\n"+
+			"Get one with kinit first; a server asking for a password is refused")
+	cmd.Flags().StringVar(&f.krbSrvName, "krbsrvname", "",
+		"Kerberos service name (default: postgres; overrides $PGKRBSRVNAME)")
+	cmd.Flags().StringVar(&f.krbSPN, "krbspn", "",
+		"Kerberos service principal name, instead of the service name and host")
+
+	// TLS client certificate flags
+	cmd.Flags().BoolVar(&f.certAuth, "cert-auth", false,
+		"Authenticate with the client certificate alone (pg_hba.conf cert method)\n"+
+			"Requires --sslcert and --sslkey; a server asking for a password is refused")
+	cmd.Flags().StringVar(&f.sslCert, "sslcert", "",
+		"Path to client SSL certificate file\n"+
+			"Precedence: --sslcert > $PGSSLCERT > pgmi.yaml")
+	cmd.Flags().StringVar(&f.sslKey, "sslkey", "",
+		"Path to client SSL private key file\n"+
+			"Precedence: --sslkey > $PGSSLKEY > pgmi.yaml")
+	cmd.Flags().StringVar(&f.sslRootCert, "sslrootcert", "",
+		"Path to root CA certificate for server verification\n"+
+			"Precedence: --sslrootcert > $PGSSLROOTCERT > pgmi.yaml")
+
+	// SSH tunnel flags
+	cmd.Flags().StringVar(&f.sshHost, "ssh-host", "",
+		"Tunnel every connection through this SSH server (a bastion or jump host)\n"+
+			"Precedence: --ssh-host > ssh.host in pgmi.yaml")
+	cmd.Flags().IntVar(&f.sshPort, "ssh-port", 0,
+		"SSH server port (default: 22)")
+	cmd.Flags().StringVar(&f.sshUser, "ssh-user", "",
+		"SSH user (default: the current OS user)")
+	cmd.Flags().StringVar(&f.sshKey, "ssh-key", "",
+		"SSH private key (default: the ssh-agent's keys, then ~/.ssh/id_*)\n"+
+			"An encrypted key's passphrase is read from $PGMI_SSH_PASSPHRASE")
+	cmd.Flags().StringVar(&f.sshKnownHosts, "ssh-known-hosts", "",
+		"known_hosts file holding the SSH server's host key (default: ~/.ssh/known_hosts)")
+
+	_ = cmd.RegisterFlagCompletionFunc("sslmode", completeSSLModes)
+}
+
+// resolveSSHTunnel merges the --ssh-* flags with pgmi.yaml's connection.ssh;
+// nil means no tunnel.
+func resolveSSHTunnel(flags connectionFlags, projectCfg *config.ProjectConfig) (*db.SSHTunnelConfig, error) {
+	return db.ResolveSSHTunnel(&db.SSHFlags{
+		Host:       flags.sshHost,
+		Port:       flags.sshPort,
+		User:       flags.sshUser,
+		KeyFile:    flags.sshKey,
+		KnownHosts: flags.sshKnownHosts,
+	}, projectCfg)
+}
+
+// openSSHTunnel returns the SSH tunnel the flags and pgmi.yaml configure, or
+// nil when there is none. The caller closes it once done connecting.
+func openSSHTunnel(flags connectionFlags, projectCfg *config.ProjectConfig, verbose bool) (*db.SSHTunnel, error) {
+	tunnelConfig, err := resolveSSHTunnel(flags, projectCfg)
+	if err != nil || tunnelConfig == nil {
+		return nil, err
+	}
+	tunnel, err := db.NewSSHTunnel(*tunnelConfig)
+	if err != nil {
+		return nil, err
+	}
+	if verbose {
+		fmt.Fprintf(os.Stderr, "[VERBOSE] Connecting through SSH tunnel: %s\n", tunnelConfig)
+	}
+	return tunnel, nil
 }
 
 // resolveConnectionFromFlags resolves connection configuration from flags and project config.
@@ -52,6 +189,7 @@ func resolveConnectionFromFlags(
 		Username: flags.username,
 		Database: flags.database,
 		SSLMode:  flags.sslMode,
+		Service:  flags.service,
 	}
 
 	azureFlags := &db.AzureFlags{
@@ -76,10 +214,35 @@ func resolveConnectionFromFlags(
 		SSLRootCert: flags.sslRootCert,
 	}
 
+	tokenFlags := &db.TokenFlags{
+		Command: flags.tokenCommand,
+		File:    flags.tokenFile,
+	}
+	cloudFlags := !azureFlags.IsEmpty() || !awsFlags.IsEmpty() || !googleFlags.IsEmpty()
+	if !tokenFlags.IsEmpty() && cloudFlags {
+		return nil, "", fmt.Errorf("--token-command and --token-file cannot be combined with --azure, --aws or --google: %w", pgmi.ErrInvalidConfig)
+	}
+
+	kerberosFlags := &db.KerberosFlags{
+		Enabled:     flags.gssapi,
+		ServiceName: flags.krbSrvName,
+		SPN:         flags.krbSPN,
+	}
+	if (flags.gssapi || flags.certAuth) && (cloudFlags || !tokenFlags.IsEmpty()) {
+		return nil, "", fmt.Errorf("--gssapi and --cert-auth cannot be combined with --azure, --aws, --google or a token flag: %w", pgmi.ErrInvalidConfig)
+	}
+
 	connConfig, maintenanceDB, err := resolveConnection(flags.connection, granularFlags, azureFlags, awsFlags, googleFlags, certFlags, projectCfg)
 	if err != nil {
 		return nil, "", err
 	}
+	env := db.LoadFromEnvironment()
+	if err := db.ApplyTokenAuth(connConfig, tokenFlags, env, projectCfg); err != nil {
+		return nil, "", err
+	}
+	if err := db.ApplyGSSAPIAndCertAuth(connConfig, kerberosFlags, flags.certAuth, env, projectCfg); err != nil {
+		return nil, "", err
+	}
 
 	if flags.password != "" && connConfig.Password == "" {
 		connConfig.Password = flags.password
@@ -143,6 +306,84 @@ func resolveEffectiveTimeout(
 	return flagTimeout, nil
 }
 
+// resolveEffectiveCompat returns the session API version to apply: --compat
+// when given, else the compat pinned in pgmi.yaml, else "" for latest.
+func resolveEffectiveCompat(cmd *cobra.Command, projectCfg *config.ProjectConfig, flagCompat string) string {
+	if projectCfg != nil && projectCfg.Compat != "" && !cmd.Flags().Changed("compat") {
+		return projectCfg.Compat
+	}
+	return flagCompat
+}
+
+// resolveEntrypoint turns the --entry flag into a project-relative script path
+// and the full list of orchestrators to keep out of _pgmi_source. The flag is
+// first looked up as a name under `entrypoints:` in pgmi.yaml, then taken as a
+// path. Every declared path is validated, used or not: a broken declaration is
+// a broken pgmi.yaml, and would otherwise surface only on the day someone
+// finally runs that entrypoint.
+func resolveEntrypoint(projectCfg *config.ProjectConfig, flagEntry string) (string, []string, error) {
+	var declared []string
+	for _, p := range projectCfg.EntrypointPaths() {
+		if err := pgmi.ValidateEntrypointPath(p); err != nil {
+			return "", nil, fmt.Errorf("invalid entrypoints in pgmi.yaml: %w", err)
+		}
+		declared = append(declared, pgmi.NormalizeEntrypoint(p))
+	}
+
+	if flagEntry == "" {
+		return "", declared, nil
+	}
+	if projectCfg != nil {
+		if p, ok := projectCfg.Entrypoints[flagEntry]; ok {
+			return pgmi.NormalizeEntrypoint(p), declared, nil
+		}
+	}
+
+	if filepath.Ext(flagEntry) == "" {
+		if projectCfg == nil || len(projectCfg.Entrypoints) == 0 {
+			return "", nil, fmt.Errorf("unknown entrypoint %q: pgmi.yaml declares no entrypoints; pass a script path such as ops/reindex.sql: %w",
+				flagEntry, pgmi.ErrInvalidConfig)
+		}
+		names := slices.Sorted(maps.Keys(projectCfg.Entrypoints))
+		return "", nil, fmt.Errorf("unknown entrypoint %q: pgmi.yaml declares %s: %w",
+			flagEntry, strings.Join(names, ", "), pgmi.ErrInvalidConfig)
+	}
+
+	if err := pgmi.ValidateEntrypointPath(flagEntry); err != nil {
+		return "", nil, err
+	}
+	return pgmi.NormalizeEntrypoint(flagEntry), declared, nil
+}
+
+// declaredEntrypoints returns the entrypoints pgmi.yaml declares, for the
+// offline commands that scan a project. They only need to keep the
+// orchestrators out of their file lists, so a missing or unreadable pgmi.yaml
+// declares none rather than failing the command.
+func declaredEntrypoints(projectPath string) []string {
+	cfg, err := config.Load(projectPath)
+	if err != nil {
+		return nil
+	}
+	return cfg.EntrypointPaths()
+}
+
+// declaredMetadataSchema returns the custom attribute schema from the
+// project's pgmi.yaml, nil when there is none. A pgmi.yaml that does not parse
+// is ignored here as in declaredEntrypoints — the deploy reports it — but a
+// schema that parses and is itself invalid is an error: ignoring it would pass
+// files the deploy then rejects.
+func declaredMetadataSchema(projectPath string) (pgmi.MetadataSchema, error) {
+	cfg, err := config.Load(projectPath)
+	if err != nil {
+		return nil, nil
+	}
+	schema := cfg.MetadataSchema()
+	if err := schema.Validate(); err != nil {
+		return nil, err
+	}
+	return schema, nil
+}
+
 // loadProjectConfig loads the project's .env and pgmi.yaml from sourcePath.
 // .env is project-scoped (sourcePath/.env), never the process CWD, so the
 // resolved target and credentials match the project being deployed.
@@ -187,8 +428,17 @@ func loadProjectConfig(sourcePath string) (*config.ProjectConfig, error) {
 // logConnectionVerbose logs connection details when verbose mode is enabled.
 func logConnectionVerbose(connConfig *pgmi.ConnectionConfig, maintenanceDB string, includeMaintenanceDB bool) {
 	fmt.Fprintf(os.Stderr, "[VERBOSE] Connection resolved:\n")
+	if connConfig.Service != "" {
+		fmt.Fprintf(os.Stderr, "  Service: %s (%s)\n", connConfig.Service, connConfig.ServiceFile)
+	}
 	fmt.Fprintf(os.Stderr, "  Host: %s\n", connConfig.Host)
 	fmt.Fprintf(os.Stderr, "  Port: %d\n", connConfig.Port)
+	for _, h := range connConfig.FallbackHosts {
+		fmt.Fprintf(os.Stderr, "  Fallback Host: %s:%d\n", h.Host, cmp.Or(h.Port, connConfig.Port))
+	}
+	if connConfig.TargetSessionAttrs != "" {
+		fmt.Fprintf(os.Stderr, "  Target Session Attrs: %s\n", connConfig.TargetSessionAttrs)
+	}
 	fmt.Fprintf(os.Stderr, "  User: %s\n", connConfig.Username)
 	fmt.Fprintf(os.Stderr, "  Target Database: %s\n", connConfig.Database)
 	if includeMaintenanceDB {
@@ -204,10 +454,41 @@ func logConnectionVerbose(connConfig *pgmi.ConnectionConfig, maintenanceDB strin
 	if connConfig.SSLRootCert != "" {
 		fmt.Fprintf(os.Stderr, "  SSL Root Cert: %s\n", connConfig.SSLRootCert)
 	}
-	fmt.Fprintf(os.Stderr, "  Auth Method: %s\n", connConfig.AuthMethod)
+	fmt.Fprintf(os.Stderr, "  Auth Method: %s\n", describeAuth(connConfig))
+}
+
+// describeAuth names the auth method with what it authenticates with, for
+// the verbose connection summary and pgmi config.
+func describeAuth(c *pgmi.ConnectionConfig) string {
+	switch c.AuthMethod {
+	case pgmi.AuthMethodGSSAPI:
+		if c.KerberosSPN != "" {
+			return fmt.Sprintf("%s (Kerberos SPN %s)", c.AuthMethod, c.KerberosSPN)
+		}
+		service := c.KerberosServiceName
+		if service == "" {
+			service = "postgres"
+		}
+		return fmt.Sprintf("%s (Kerberos service %s/%s)", c.AuthMethod, service, c.Host)
+	case pgmi.AuthMethodCert:
+		return fmt.Sprintf("%s (%s)", c.AuthMethod, c.SSLCert)
+	case pgmi.AuthMethodExec:
+		if len(c.TokenCommand) > 0 {
+			return fmt.Sprintf("%s (%s)", c.AuthMethod, c.TokenCommand[0])
+		}
+	case pgmi.AuthMethodTokenFile:
+		return fmt.Sprintf("%s (%s)", c.AuthMethod, c.TokenFile)
+	}
+	return c.AuthMethod.String()
 }
 
 // saveConnectionToConfig saves connection config to pgmi.yaml, merging with any existing config.
+//
+// A connection from a pg_service.conf service is saved as the service, and
+// its host, port and sslmode are left to it: they would rank below it anyway,
+// and the service file stays where they are changed. Several hosts are saved
+// as a comma-separated list sharing the first host's port, which is all
+// pgmi.yaml's port key holds.
 func saveConnectionToConfig(sourcePath string, connConfig *pgmi.ConnectionConfig, managementDB string) error {
 	configPath := filepath.Join(sourcePath, "pgmi.yaml")
 
@@ -216,13 +497,18 @@ func saveConnectionToConfig(sourcePath string, connConfig *pgmi.ConnectionConfig
 		cfg = &config.ProjectConfig{}
 	}
 
+	var hosts []string
+	for _, h := range connConfig.Hosts() {
+		hosts = append(hosts, h.Host)
+	}
 	cfg.Connection = config.ConnectionConfig{
-		Host:                connConfig.Host,
+		Host:                strings.Join(hosts, ","),
 		Port:                connConfig.Port,
 		Username:            connConfig.Username,
 		Database:            connConfig.Database,
 		MaintenanceDatabase: managementDB,
 		SSLMode:             connConfig.SSLMode,
+		TargetSessionAttrs:  connConfig.TargetSessionAttrs,
 		SSLCert:             connConfig.SSLCert,
 		SSLKey:              connConfig.SSLKey,
 		SSLRootCert:         connConfig.SSLRootCert,
@@ -231,6 +517,16 @@ func saveConnectionToConfig(sourcePath string, connConfig *pgmi.ConnectionConfig
 		AzureClientID:       connConfig.AzureClientID,
 		AWSRegion:           connConfig.AWSRegion,
 		GoogleInstance:      connConfig.GoogleInstance,
+		TokenCommand:        connConfig.TokenCommand,
+		TokenFile:           connConfig.TokenFile,
+		KerberosServiceName: connConfig.KerberosServiceName,
+		KerberosSPN:         connConfig.KerberosSPN,
+	}
+	if connConfig.Service != "" {
+		cfg.Connection.Service = connConfig.Service
+		cfg.Connection.Host = ""
+		cfg.Connection.Port = 0
+		cfg.Connection.SSLMode = ""
 	}
 
 	data, err := yaml.Marshal(cfg)
@@ -255,6 +551,14 @@ func authMethodToString(m pgmi.AuthMethod) string {
 		return "aws"
 	case pgmi.AuthMethodGoogleIAM:
 		return "google"
+	case pgmi.AuthMethodExec:
+		return "exec"
+	case pgmi.AuthMethodTokenFile:
+		return "token_file"
+	case pgmi.AuthMethodGSSAPI:
+		return "gssapi"
+	case pgmi.AuthMethodCert:
+		return "cert"
 	default:
 		return ""
 	}
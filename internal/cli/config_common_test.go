@@ -7,6 +7,8 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/spf13/cobra"
+
 	"github.com/vvka-141/pgmi/internal/config"
 	"github.com/vvka-141/pgmi/pkg/pgmi"
 	"gopkg.in/yaml.v3"
@@ -109,6 +111,10 @@ func TestAuthMethodToString(t *testing.T) {
 		{pgmi.AuthMethodAzureEntraID, "azure"},
 		{pgmi.AuthMethodAWSIAM, "aws"},
 		{pgmi.AuthMethodGoogleIAM, "google"},
+		{pgmi.AuthMethodExec, "exec"},
+		{pgmi.AuthMethodTokenFile, "token_file"},
+		{pgmi.AuthMethodGSSAPI, "gssapi"},
+		{pgmi.AuthMethodCert, "cert"},
 	}
 
 	for _, tt := range tests {
@@ -209,6 +215,109 @@ func TestSaveConnectionToConfig_AWSAuth(t *testing.T) {
 	}
 }
 
+func TestSaveConnectionToConfig_ExecAuth(t *testing.T) {
+	dir := t.TempDir()
+
+	connConfig := &pgmi.ConnectionConfig{
+		Host:         "db.internal",
+		Port:         5432,
+		Username:     "deploy",
+		Database:     "mydb",
+		AuthMethod:   pgmi.AuthMethodExec,
+		TokenCommand: []string{"vault-db-token", "--role", "deploy"},
+	}
+	if err := saveConnectionToConfig(dir, connConfig, "postgres"); err != nil {
+		t.Fatalf("saveConnectionToConfig() error = %v", err)
+	}
+
+	cfg, err := config.Load(dir)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.Connection.AuthMethod != "exec" {
+		t.Errorf("AuthMethod = %q, want %q", cfg.Connection.AuthMethod, "exec")
+	}
+	if got := strings.Join(cfg.Connection.TokenCommand, " "); got != "vault-db-token --role deploy" {
+		t.Errorf("TokenCommand = %q", cfg.Connection.TokenCommand)
+	}
+}
+
+func TestSaveConnectionToConfig_GSSAPIAuth(t *testing.T) {
+	dir := t.TempDir()
+
+	connConfig := &pgmi.ConnectionConfig{
+		Host:                "db.corp.example.com",
+		Port:                5432,
+		Username:            "deploy",
+		Database:            "mydb",
+		AuthMethod:          pgmi.AuthMethodGSSAPI,
+		KerberosServiceName: "pg",
+	}
+	if err := saveConnectionToConfig(dir, connConfig, "postgres"); err != nil {
+		t.Fatalf("saveConnectionToConfig() error = %v", err)
+	}
+
+	cfg, err := config.Load(dir)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.Connection.AuthMethod != "gssapi" {
+		t.Errorf("AuthMethod = %q, want %q", cfg.Connection.AuthMethod, "gssapi")
+	}
+	if cfg.Connection.KerberosServiceName != "pg" {
+		t.Errorf("KerberosServiceName = %q, want %q", cfg.Connection.KerberosServiceName, "pg")
+	}
+}
+
+func TestSaveConnectionToConfig_Service(t *testing.T) {
+	dir := t.TempDir()
+
+	connConfig := &pgmi.ConnectionConfig{
+		Host:        "db1.corp.example.com",
+		Port:        5433,
+		Username:    "deployer",
+		Database:    "myapp",
+		SSLMode:     "verify-full",
+		Service:     "prod",
+		ServiceFile: "/etc/postgresql-common/pg_service.conf",
+	}
+	if err := saveConnectionToConfig(dir, connConfig, "postgres"); err != nil {
+		t.Fatalf("saveConnectionToConfig() error = %v", err)
+	}
+
+	cfg, err := config.Load(dir)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	// The service file keeps the host, port and sslmode; pgmi.yaml names it.
+	c := cfg.Connection
+	if c.Service != "prod" || c.Host != "" || c.Port != 0 || c.SSLMode != "" {
+		t.Errorf("service %q, host %q, port %d, sslmode %q; want only the service", c.Service, c.Host, c.Port, c.SSLMode)
+	}
+	if c.Username != "deployer" || c.Database != "myapp" {
+		t.Errorf("Username = %q, Database = %q; want deployer and myapp", c.Username, c.Database)
+	}
+}
+
+func TestDescribeAuth(t *testing.T) {
+	tests := []struct {
+		config pgmi.ConnectionConfig
+		want   string
+	}{
+		{pgmi.ConnectionConfig{}, "Standard"},
+		{pgmi.ConnectionConfig{AuthMethod: pgmi.AuthMethodGSSAPI, Host: "db"}, "GSSAPI (Kerberos service postgres/db)"},
+		{pgmi.ConnectionConfig{AuthMethod: pgmi.AuthMethodGSSAPI, Host: "db", KerberosServiceName: "pg"}, "GSSAPI (Kerberos service pg/db)"},
+		{pgmi.ConnectionConfig{AuthMethod: pgmi.AuthMethodGSSAPI, KerberosSPN: "pg/db@EXAMPLE.COM"}, "GSSAPI (Kerberos SPN pg/db@EXAMPLE.COM)"},
+		{pgmi.ConnectionConfig{AuthMethod: pgmi.AuthMethodCert, SSLCert: "/c.crt"}, "Client Certificate (/c.crt)"},
+		{pgmi.ConnectionConfig{AuthMethod: pgmi.AuthMethodExec, TokenCommand: []string{"get-token", "--secret-arg"}}, "Exec (get-token)"},
+	}
+	for _, tt := range tests {
+		if got := describeAuth(&tt.config); got != tt.want {
+			t.Errorf("describeAuth(%v) = %q, want %q", tt.config.AuthMethod, got, tt.want)
+		}
+	}
+}
+
 func TestSaveConnectionToConfig_GoogleAuth(t *testing.T) {
 	dir := t.TempDir()
 
@@ -360,3 +469,78 @@ func TestValidateSSLMode(t *testing.T) {
 		t.Errorf("error should wrap ErrInvalidConfig, got %v", err)
 	}
 }
+
+func TestResolveEntrypoint(t *testing.T) {
+	cfg := &config.ProjectConfig{Entrypoints: map[string]string{
+		"reindex":  "./ops/reindex.sql",
+		"backfill": "ops/backfill.sql",
+	}}
+	wantAll := "ops/reindex.sql,ops/backfill.sql"
+
+	tests := []struct {
+		name      string
+		cfg       *config.ProjectConfig
+		flag      string
+		wantEntry string
+		wantErr   string
+	}{
+		{name: "no flag runs deploy.sql", cfg: cfg, flag: "", wantEntry: ""},
+		{name: "declared name", cfg: cfg, flag: "reindex", wantEntry: "ops/reindex.sql"},
+		{name: "plain path", cfg: cfg, flag: "./ops/adhoc.sql", wantEntry: "ops/adhoc.sql"},
+		{name: "unknown name lists declared ones", cfg: cfg, flag: "reindx", wantErr: "backfill, reindex"},
+		{name: "unknown name without pgmi.yaml", cfg: nil, flag: "reindex", wantErr: "declares no entrypoints"},
+		{name: "path outside project", cfg: nil, flag: "../x.sql", wantErr: "outside the project"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry, all, err := resolveEntrypoint(tt.cfg, tt.flag)
+			if tt.wantErr != "" {
+				if !errors.Is(err, pgmi.ErrInvalidConfig) || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected ErrInvalidConfig containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("resolveEntrypoint: %v", err)
+			}
+			if entry != tt.wantEntry {
+				t.Errorf("entry = %q, want %q", entry, tt.wantEntry)
+			}
+			if tt.cfg != nil && strings.Join(all, ",") != wantAll {
+				t.Errorf("entrypoints = %v, want %s", all, wantAll)
+			}
+		})
+	}
+}
+
+func TestResolveEntrypoint_RejectsBrokenDeclaration(t *testing.T) {
+	cfg := &config.ProjectConfig{Entrypoints: map[string]string{"bad": "/abs/path.sql"}}
+	_, _, err := resolveEntrypoint(cfg, "")
+	if !errors.Is(err, pgmi.ErrInvalidConfig) || !strings.Contains(err.Error(), "pgmi.yaml") {
+		t.Fatalf("expected a pgmi.yaml ErrInvalidConfig, got %v", err)
+	}
+}
+
+func TestResolveEffectiveCompat(t *testing.T) {
+	newCmd := func(compat string) *cobra.Command {
+		cmd := &cobra.Command{}
+		cmd.Flags().String("compat", "", "")
+		if compat != "" {
+			if err := cmd.Flags().Set("compat", compat); err != nil {
+				t.Fatalf("set compat flag: %v", err)
+			}
+		}
+		return cmd
+	}
+	pinned := &config.ProjectConfig{Compat: "1"}
+
+	if got := resolveEffectiveCompat(newCmd(""), pinned, ""); got != "1" {
+		t.Errorf("pgmi.yaml pin ignored: got %q", got)
+	}
+	if got := resolveEffectiveCompat(newCmd("2"), pinned, "2"); got != "2" {
+		t.Errorf("--compat must outrank pgmi.yaml: got %q", got)
+	}
+	if got := resolveEffectiveCompat(newCmd(""), nil, ""); got != "" {
+		t.Errorf("no pin and no flag means latest (\"\"), got %q", got)
+	}
+}
@@ -277,6 +277,38 @@ func TestDeployJSON_AtomicFailureCarriesUnitContext(t *testing.T) {
 	}
 }
 
+func TestDeployJSON_ResumeReportsSkippedUnits(t *testing.T) {
+	resumed := &services.DeployResult{
+		FilesLoaded:    5,
+		Duration:       720 * time.Millisecond,
+		Database:       "prod",
+		ExecutionUnits: 4,
+		UnitsCommitted: 4,
+		UnitsSkipped:   2,
+	}
+
+	env := decodeEnvelope(t, captureStdout(t, func() {
+		printDeployJSON(resumed, nil)
+	}))
+	if env["unitsSkipped"] != float64(2) {
+		t.Errorf("unitsSkipped = %#v, want 2", env["unitsSkipped"])
+	}
+
+	env = decodeEnvelope(t, captureStdout(t, func() {
+		printDeployJSON(sampleResult, nil)
+	}))
+	if _, ok := env["unitsSkipped"]; ok {
+		t.Errorf("unitsSkipped must be omitted when nothing was resumed: %v", env)
+	}
+
+	out := captureStderr(t, func() {
+		printDeploySummary(resumed, nil)
+	})
+	if !strings.Contains(out, "resumed past 2 committed tail unit(s)") {
+		t.Errorf("a resumed run should say so in the summary:\n%s", out)
+	}
+}
+
 func TestDeploySummary_TailFailureMentionsUnitOrdinal(t *testing.T) {
 	tailResult := &services.DeployResult{
 		FilesLoaded:    5,
@@ -371,3 +403,41 @@ func captureStderr(t *testing.T, fn func()) string {
 	}
 	return out
 }
+
+func TestDeployJSON_TemplateClone(t *testing.T) {
+	cloned := &services.DeployResult{
+		Duration: 310 * time.Millisecond,
+		Database: "ci_job_42",
+		Template: "myapp_template",
+	}
+
+	env := decodeEnvelope(t, captureStdout(t, func() {
+		printDeployJSON(cloned, nil)
+	}))
+	if env["template"] != "myapp_template" || env["templateRebuilt"] != false {
+		t.Errorf("template fields = %#v, %#v", env["template"], env["templateRebuilt"])
+	}
+
+	env = decodeEnvelope(t, captureStdout(t, func() {
+		printDeployJSON(sampleResult, nil)
+	}))
+	if _, ok := env["template"]; ok {
+		t.Errorf("template must be omitted when no template was used: %v", env)
+	}
+
+	out := captureStderr(t, func() {
+		printDeploySummary(cloned, nil)
+	})
+	if !strings.Contains(out, `cloned from template "myapp_template"`) || strings.Contains(out, "files loaded") {
+		t.Errorf("a clone-only run should say it cloned, not count files it never loaded:\n%s", out)
+	}
+
+	cloned.TemplateRebuilt = true
+	cloned.FilesLoaded = 5
+	out = captureStderr(t, func() {
+		printDeploySummary(cloned, nil)
+	})
+	if !strings.Contains(out, `5 files loaded, cloned from rebuilt template "myapp_template"`) {
+		t.Errorf("a rebuild should report both the deploy and the clone:\n%s", out)
+	}
+}
@@ -0,0 +1,201 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/vvka-141/pgmi/internal/config"
+	"github.com/vvka-141/pgmi/internal/services"
+	"github.com/vvka-141/pgmi/pkg/pgmi"
+)
+
+var driftCmd = &cobra.Command{
+	Use:   "drift [project_path]",
+	Short: "Report changes made to a database since its last deploy",
+	Long: `Compare a database's catalog with the fingerprint its last deploy recorded,
+and report every object added, removed or changed since: tables, columns,
+constraints, indexes, function bodies, triggers, grants, RLS policies, types
+and extensions.
+
+The fingerprint is recorded by deploy.sql itself, by calling
+pg_temp.pgmi_capture_fingerprint() as its last step (session API v2). drift
+only reads, in a read-only transaction, so it can run against a standby.
+
+  pgmi drift -d myapp                      Using PG* environment variables
+  pgmi drift ./project                     Using the connection in ./project/pgmi.yaml
+  pgmi drift -d myapp --json               Machine-readable report on stdout
+
+Exit code 0 means no drift, 17 means drift was found.`,
+	Args: usageArgs(cobra.MaximumNArgs(1)),
+	RunE: runDrift,
+}
+
+type driftFlagValues struct {
+	connectionFlags
+	logFlags
+	jsonOutput bool
+	timeout    time.Duration
+}
+
+var driftFlags driftFlagValues
+
+func init() {
+	rootCmd.AddCommand(driftCmd)
+
+	driftCmd.Flags().StringVar(&driftFlags.connection, "connection", "",
+		"PostgreSQL connection string (URI or ADO.NET format).\n"+
+			"Its database is checked unless -d says otherwise.\n"+
+			"Alternative: Use PGMI_CONNECTION_STRING or DATABASE_URL environment variable.")
+	driftCmd.Flags().StringVarP(&driftFlags.database, "database", "d", "",
+		"Database to check (optional if specified in connection string, or $PGDATABASE)")
+	addConnectionFlags(driftCmd, &driftFlags.connectionFlags)
+	addLogFlags(driftCmd, &driftFlags.logFlags)
+
+	driftCmd.Flags().BoolVar(&driftFlags.jsonOutput, "json", false,
+		"Emit the report as JSON to stdout")
+	driftCmd.Flags().DurationVar(&driftFlags.timeout, "timeout", 3*time.Minute,
+		"Give up after this long (0 disables the limit)")
+}
+
+func runDrift(cmd *cobra.Command, args []string) error {
+	verbose := getVerboseFlag(cmd)
+	if err := validateSSLMode(driftFlags.sslMode); err != nil {
+		return err
+	}
+	logger, closeLogger, err := newCommandLogger(driftFlags.logFlags, verbose)
+	if err != nil {
+		return err
+	}
+	defer closeLogger()
+
+	var projectCfg *config.ProjectConfig
+	if len(args) > 0 {
+		cfg, err := loadProjectConfig(args[0])
+		if err != nil {
+			return err
+		}
+		projectCfg = cfg
+	}
+
+	connConfig, _, err := resolveConnectionFromFlags(driftFlags.connectionFlags, projectCfg)
+	if err != nil {
+		return err
+	}
+	targetDB, err := resolveTargetDatabase(driftFlags.database, connConfig.Database, verbose)
+	if err != nil {
+		return err
+	}
+	connConfig.Database = targetDB
+	if connConfig.AppName == "" {
+		connConfig.AppName = "pgmi"
+	}
+
+	ctx, cancel := deadlineContext(context.Background(), driftFlags.timeout)
+	defer cancel()
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	tunnel, err := openSSHTunnel(driftFlags.connectionFlags, projectCfg, verbose)
+	if err != nil {
+		return err
+	}
+	if tunnel != nil {
+		defer tunnel.Close()
+	}
+	connector, err := connectorFactory(logger, tunnel)(connConfig)
+	if err != nil {
+		return err
+	}
+	if closer, ok := connector.(io.Closer); ok {
+		defer closer.Close()
+	}
+	pool, err := connector.Connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer pool.Close()
+
+	result, err := services.DetectDrift(ctx, pool)
+	if err != nil {
+		return err
+	}
+
+	if driftFlags.jsonOutput {
+		if err := printDriftJSON(os.Stdout, targetDB, result); err != nil {
+			return err
+		}
+	} else {
+		printDriftReport(os.Stderr, targetDB, result, verbose)
+	}
+	if result.Drifted() {
+		return fmt.Errorf("%w: %d object(s) in %s changed since the last deploy", pgmi.ErrDriftDetected, len(result.Entries), targetDB)
+	}
+	return nil
+}
+
+// driftMarks are the diff-style markers of the text report.
+var driftMarks = map[services.DriftChange]string{
+	services.DriftAdded:   "+",
+	services.DriftRemoved: "-",
+	services.DriftChanged: "~",
+}
+
+func printDriftReport(w io.Writer, database string, result *services.DriftResult, verbose bool) {
+	captured := result.CapturedAt.UTC().Format(time.RFC3339)
+	if !result.Drifted() {
+		fmt.Fprintf(w, "No drift: %s matches the fingerprint captured %s (%d objects)\n", database, captured, result.Recorded)
+		return
+	}
+
+	fmt.Fprintf(w, "Drift in %s since the fingerprint captured %s (%d objects):\n\n", database, captured, result.Recorded)
+	width := 0
+	for _, e := range result.Entries {
+		width = max(width, len(e.ObjectType))
+	}
+	for _, e := range result.Entries {
+		fmt.Fprintf(w, "  %s %-*s  %s\n", driftMarks[e.Change], width, e.ObjectType, e.Name)
+		if verbose && e.Change == services.DriftChanged {
+			fmt.Fprintf(w, "      recorded: %s\n", indentContinuation(e.Recorded, "                "))
+			fmt.Fprintf(w, "      live:     %s\n", indentContinuation(e.Live, "                "))
+		}
+	}
+	fmt.Fprintf(w, "\n%d added, %d removed, %d changed\n",
+		result.Count(services.DriftAdded), result.Count(services.DriftRemoved), result.Count(services.DriftChanged))
+	if !verbose && result.Count(services.DriftChanged) > 0 {
+		fmt.Fprintln(w, "Run with --verbose to see the recorded and live definitions of changed objects.")
+	}
+}
+
+// indentContinuation indents every line of a multi-line definition after the
+// first, so it stays under its label.
+func indentContinuation(s, indent string) string {
+	return strings.ReplaceAll(strings.TrimRight(s, "\n"), "\n", "\n"+indent)
+}
+
+func printDriftJSON(w io.Writer, database string, result *services.DriftResult) error {
+	entries := result.Entries
+	if entries == nil {
+		entries = []services.DriftEntry{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(map[string]any{
+		"database":   database,
+		"capturedAt": result.CapturedAt,
+		"recorded":   result.Recorded,
+		"drifted":    result.Drifted(),
+		"added":      result.Count(services.DriftAdded),
+		"removed":    result.Count(services.DriftRemoved),
+		"changed":    result.Count(services.DriftChanged),
+		"entries":    entries,
+	})
+}
@@ -0,0 +1,126 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vvka-141/pgmi/internal/services"
+	"github.com/vvka-141/pgmi/pkg/pgmi"
+)
+
+func resetDriftFlags() {
+	driftFlags = driftFlagValues{timeout: 3 * time.Minute}
+}
+
+func TestDriftCmd_ArgsValidation_TooMany(t *testing.T) {
+	err := driftCmd.Args(driftCmd, []string{"a", "b"})
+	if pgmi.ExitCodeForError(err) != pgmi.ExitUsageError {
+		t.Errorf("expected a usage error, got %v", err)
+	}
+}
+
+func TestDriftCmd_NoDatabase_ExitCode10(t *testing.T) {
+	resetDriftFlags()
+	defer resetDriftFlags()
+	clearPGEnv(t)
+	driftFlags.host = "localhost"
+
+	err := runDrift(driftCmd, nil)
+	if code := pgmi.ExitCodeForError(err); code != pgmi.ExitConfigError {
+		t.Errorf("expected exit code %d, got %d for: %v", pgmi.ExitConfigError, code, err)
+	}
+}
+
+func TestDriftCmd_UnreachableHost_ExitCode11(t *testing.T) {
+	resetDriftFlags()
+	defer resetDriftFlags()
+	clearPGEnv(t)
+
+	driftFlags.host = "nonexistent.invalid"
+	driftFlags.database = "myapp"
+	driftFlags.username = "testuser"
+	driftFlags.timeout = 30 * time.Second
+
+	err := runDrift(driftCmd, nil)
+	if code := pgmi.ExitCodeForError(err); code != pgmi.ExitConnectionError {
+		t.Errorf("expected exit code %d, got %d for: %v", pgmi.ExitConnectionError, code, err)
+	}
+}
+
+var sampleDrift = &services.DriftResult{
+	CapturedAt: time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC),
+	Recorded:   42,
+	Entries: []services.DriftEntry{
+		{Change: services.DriftRemoved, ObjectType: "function", Name: "public.order_total(p_id integer)", Recorded: "body"},
+		{Change: services.DriftChanged, ObjectType: "relation", Name: "public.orders", Recorded: "acl=", Live: "acl=reporting=r/app"},
+		{Change: services.DriftAdded, ObjectType: "column", Name: "public.orders.note", Live: "type=text"},
+	},
+}
+
+func TestPrintDriftReport(t *testing.T) {
+	var buf bytes.Buffer
+	printDriftReport(&buf, "myapp", sampleDrift, false)
+	out := buf.String()
+	for _, want := range []string{
+		"Drift in myapp since the fingerprint captured 2026-10-01T12:00:00Z (42 objects)",
+		"  - function  public.order_total(p_id integer)",
+		"  ~ relation  public.orders",
+		"  + column    public.orders.note",
+		"1 added, 1 removed, 1 changed",
+		"--verbose",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("report lacks %q:\n%s", want, out)
+		}
+	}
+
+	buf.Reset()
+	printDriftReport(&buf, "myapp", sampleDrift, true)
+	if !strings.Contains(buf.String(), "recorded: acl=\n      live:     acl=reporting=r/app") {
+		t.Errorf("verbose report lacks the definitions:\n%s", buf.String())
+	}
+
+	buf.Reset()
+	printDriftReport(&buf, "myapp", &services.DriftResult{CapturedAt: sampleDrift.CapturedAt, Recorded: 42}, false)
+	if !strings.HasPrefix(buf.String(), "No drift: myapp matches") {
+		t.Errorf("clean report = %q", buf.String())
+	}
+}
+
+func TestPrintDriftJSON(t *testing.T) {
+	var buf bytes.Buffer
+	if err := printDriftJSON(&buf, "myapp", sampleDrift); err != nil {
+		t.Fatalf("printDriftJSON: %v", err)
+	}
+	var got struct {
+		Database string `json:"database"`
+		Drifted  bool   `json:"drifted"`
+		Added    int    `json:"added"`
+		Entries  []struct {
+			Change     string `json:"change"`
+			ObjectType string `json:"objectType"`
+			Name       string `json:"name"`
+			Live       string `json:"live"`
+		} `json:"entries"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("not JSON: %v\n%s", err, buf.String())
+	}
+	if got.Database != "myapp" || !got.Drifted || got.Added != 1 || len(got.Entries) != 3 {
+		t.Errorf("unexpected JSON: %s", buf.String())
+	}
+	if e := got.Entries[2]; e.Change != "added" || e.ObjectType != "column" || e.Live != "type=text" {
+		t.Errorf("entry = %+v", e)
+	}
+
+	buf.Reset()
+	if err := printDriftJSON(&buf, "myapp", &services.DriftResult{}); err != nil {
+		t.Fatalf("printDriftJSON: %v", err)
+	}
+	if !strings.Contains(buf.String(), `"entries": []`) {
+		t.Errorf("no drift must give an empty list, not null: %s", buf.String())
+	}
+}
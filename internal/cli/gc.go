@@ -0,0 +1,149 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/vvka-141/pgmi/internal/config"
+	"github.com/vvka-141/pgmi/internal/db"
+	"github.com/vvka-141/pgmi/internal/db/manager"
+	"github.com/vvka-141/pgmi/internal/services"
+	"github.com/vvka-141/pgmi/pkg/pgmi"
+)
+
+var gcCmd = &cobra.Command{
+	Use:   "gc [project_path]",
+	Short: "Drop databases left behind by ephemeral deploys",
+	Long: `Drop databases that pgmi deploy --ephemeral created but never got to drop:
+a killed CI job, a lost connection.
+
+Only databases whose pg_database comment carries pgmi's ephemeral label are
+considered, and only those older than --older-than, so a deploy still running
+elsewhere keeps its database. Nothing else on the server is touched.
+
+  pgmi gc                       Using PG* environment variables
+  pgmi gc ./project             Using the connection in ./project/pgmi.yaml
+  pgmi gc --dry-run             List what would be dropped
+  pgmi gc --older-than 10m      Collect more aggressively`,
+	Args: usageArgs(cobra.MaximumNArgs(1)),
+	RunE: runGC,
+}
+
+type gcFlagValues struct {
+	connectionFlags
+	logFlags
+	olderThan time.Duration
+	dryRun    bool
+	timeout   time.Duration
+}
+
+var gcFlags gcFlagValues
+
+func init() {
+	rootCmd.AddCommand(gcCmd)
+
+	gcCmd.Flags().StringVar(&gcFlags.connection, "connection", "",
+		"PostgreSQL connection string (URI or ADO.NET format).\n"+
+			"Its database is the maintenance database gc connects to.\n"+
+			"Alternative: Use PGMI_CONNECTION_STRING or DATABASE_URL environment variable.")
+	addConnectionFlags(gcCmd, &gcFlags.connectionFlags)
+	addLogFlags(gcCmd, &gcFlags.logFlags)
+
+	gcCmd.Flags().DurationVar(&gcFlags.olderThan, "older-than", time.Hour,
+		"Only drop ephemeral databases created at least this long ago")
+	gcCmd.Flags().BoolVar(&gcFlags.dryRun, "dry-run", false,
+		"List the databases that would be dropped without dropping them")
+	gcCmd.Flags().DurationVar(&gcFlags.timeout, "timeout", 3*time.Minute,
+		"Give up after this long (0 disables the limit)")
+}
+
+func runGC(cmd *cobra.Command, args []string) error {
+	verbose := getVerboseFlag(cmd)
+	if gcFlags.olderThan < 0 {
+		return fmt.Errorf("%w: --older-than cannot be negative", pgmi.ErrInvalidConfig)
+	}
+	if err := validateSSLMode(gcFlags.sslMode); err != nil {
+		return err
+	}
+	logger, closeLogger, err := newCommandLogger(gcFlags.logFlags, verbose)
+	if err != nil {
+		return err
+	}
+	defer closeLogger()
+
+	var projectCfg *config.ProjectConfig
+	if len(args) > 0 {
+		cfg, err := loadProjectConfig(args[0])
+		if err != nil {
+			return err
+		}
+		projectCfg = cfg
+	}
+
+	connConfig, resolvedMaintenanceDB, err := resolveConnectionFromFlags(gcFlags.connectionFlags, projectCfg)
+	if err != nil {
+		return err
+	}
+	mgmtConfig := connConfig.DeepCopy()
+	mgmtConfig.Database = determineMaintenanceDB("", connConfig.Database, resolvedMaintenanceDB)
+	if mgmtConfig.Database == "" {
+		mgmtConfig.Database = pgmi.DefaultMaintenanceDB
+	}
+	if mgmtConfig.AppName == "" {
+		mgmtConfig.AppName = "pgmi"
+	}
+
+	ctx, cancel := deadlineContext(context.Background(), gcFlags.timeout)
+	defer cancel()
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	tunnel, err := openSSHTunnel(gcFlags.connectionFlags, projectCfg, verbose)
+	if err != nil {
+		return err
+	}
+	if tunnel != nil {
+		defer tunnel.Close()
+	}
+	connector, err := connectorFactory(logger, tunnel)(&mgmtConfig)
+	if err != nil {
+		return err
+	}
+	if closer, ok := connector.(io.Closer); ok {
+		defer closer.Close()
+	}
+	pool, err := connector.Connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer pool.Close()
+
+	result, err := services.CollectEphemeral(ctx, db.NewPoolAdapter(pool), manager.New(),
+		logger, services.GCOptions{OlderThan: gcFlags.olderThan, DryRun: gcFlags.dryRun})
+	if result != nil {
+		printGCResult(result, gcFlags.dryRun, gcFlags.olderThan)
+	}
+	return err
+}
+
+func printGCResult(result *services.GCResult, dryRun bool, olderThan time.Duration) {
+	verb := "dropped"
+	if dryRun {
+		verb = "would drop"
+	}
+	for _, name := range result.Dropped {
+		fmt.Fprintf(os.Stderr, "%s %s\n", verb, name)
+	}
+	fmt.Fprintf(os.Stderr, "%d ephemeral database(s) %s", len(result.Dropped), verb)
+	if len(result.Kept) > 0 {
+		fmt.Fprintf(os.Stderr, ", %d kept (created within the last %s)", len(result.Kept), olderThan)
+	}
+	fmt.Fprintln(os.Stderr)
+}
@@ -0,0 +1,46 @@
+package cli
+
+import (
+	"testing"
+	"time"
+
+	"github.com/vvka-141/pgmi/pkg/pgmi"
+)
+
+func resetGCFlags() {
+	gcFlags = gcFlagValues{olderThan: time.Hour, timeout: 3 * time.Minute}
+}
+
+func TestGCCmd_ArgsValidation_TooMany(t *testing.T) {
+	err := gcCmd.Args(gcCmd, []string{"a", "b"})
+	if pgmi.ExitCodeForError(err) != pgmi.ExitUsageError {
+		t.Errorf("expected a usage error, got %v", err)
+	}
+}
+
+func TestGCCmd_NegativeOlderThan(t *testing.T) {
+	resetGCFlags()
+	defer resetGCFlags()
+	gcFlags.olderThan = -time.Minute
+
+	err := runGC(gcCmd, nil)
+	if pgmi.ExitCodeForError(err) != pgmi.ExitConfigError {
+		t.Errorf("expected a configuration error, got %v", err)
+	}
+}
+
+func TestGCCmd_UnreachableHost_ExitCode11(t *testing.T) {
+	resetGCFlags()
+	defer resetGCFlags()
+	clearPGEnv(t)
+
+	gcFlags.host = "nonexistent.invalid"
+	gcFlags.port = 5432
+	gcFlags.username = "testuser"
+	gcFlags.timeout = 30 * time.Second
+
+	err := runGC(gcCmd, nil)
+	if code := pgmi.ExitCodeForError(err); code != pgmi.ExitConnectionError {
+		t.Errorf("expected exit code %d, got %d for: %v", pgmi.ExitConnectionError, code, err)
+	}
+}
@@ -8,10 +8,12 @@ import (
 	"os"
 	"path/filepath"
 	"slices"
+	"strings"
 
 	"github.com/spf13/cobra"
 	"github.com/vvka-141/pgmi/internal/checksum"
 	"github.com/vvka-141/pgmi/internal/config"
+	"github.com/vvka-141/pgmi/internal/contract"
 	"github.com/vvka-141/pgmi/internal/files/scanner"
 	"github.com/vvka-141/pgmi/internal/ui"
 	"github.com/vvka-141/pgmi/pkg/pgmi"
@@ -47,6 +49,18 @@ type projectInfo struct {
 	TestFiles    int            `json:"testFiles"`
 	MetadataWith int            `json:"metadataWith"`
 	Directories  map[string]int `json:"directories"`
+	SessionAPI   sessionAPIInfo `json:"sessionApi"`
+}
+
+// sessionAPIInfo reports which session API version a project runs under and
+// which it needs. Required is found by scanning deploy.sql, the entrypoints
+// and the project's SQL for objects a later version introduced.
+type sessionAPIInfo struct {
+	Pinned     string   `json:"pinned,omitempty"`
+	Effective  string   `json:"effective"`
+	Required   string   `json:"required"`
+	RequiredBy []string `json:"requiredBy,omitempty"`
+	Latest     string   `json:"latest"`
 }
 
 func runInfo(cmd *cobra.Command, args []string) error {
@@ -72,7 +86,7 @@ func runInfo(cmd *cobra.Command, args []string) error {
 	}
 
 	// Check pgmi.yaml
-	_, cfgErr := config.Load(sourcePath)
+	projectCfg, cfgErr := config.Load(sourcePath)
 	switch {
 	case cfgErr == nil:
 		info.ConfigFile = "ok"
@@ -86,11 +100,13 @@ func runInfo(cmd *cobra.Command, args []string) error {
 	info.Template = detectTemplate(sourcePath)
 
 	// Scan files
-	scanResult, err := s.ScanDirectory(sourcePath)
+	scanResult, err := s.ScanDirectoryExcluding(sourcePath, declaredEntrypoints(sourcePath)...)
 	if err != nil {
 		return fmt.Errorf("failed to scan directory: %w", err)
 	}
 
+	info.SessionAPI = inspectSessionAPI(s, sourcePath, projectCfg, scanResult.Files)
+
 	info.TotalFiles = len(scanResult.Files)
 	for _, f := range scanResult.Files {
 		dir := f.Directory
@@ -123,6 +139,36 @@ func runInfo(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+// inspectSessionAPI fills sessionAPIInfo. Unreadable orchestrators are
+// skipped rather than reported: info describes what it can see, and a missing
+// deploy.sql is already on its own line.
+func inspectSessionAPI(s *scanner.Scanner, sourcePath string, projectCfg *config.ProjectConfig, files []pgmi.FileMetadata) sessionAPIInfo {
+	api := sessionAPIInfo{Latest: string(contract.LatestVersion())}
+	if projectCfg != nil {
+		api.Pinned = projectCfg.Compat
+	}
+	api.Effective = api.Pinned
+	if api.Effective == "" {
+		api.Effective = api.Latest
+	}
+
+	var sources []string
+	for _, entry := range append([]string{pgmi.DefaultEntrypoint}, projectCfg.EntrypointPaths()...) {
+		if content, err := s.ReadEntrypoint(sourcePath, entry); err == nil {
+			sources = append(sources, content)
+		}
+	}
+	for _, f := range files {
+		if pgmi.IsSQLExtension(f.Extension) {
+			sources = append(sources, f.Content)
+		}
+	}
+	required, uses := contract.RequiredVersion(sources...)
+	api.Required = string(required)
+	api.RequiredBy = uses
+	return api
+}
+
 func detectTemplate(sourcePath string) string {
 	if _, err := os.Stat(filepath.Join(sourcePath, "lib", "api")); err == nil {
 		return "advanced"
@@ -146,6 +192,7 @@ func printProjectInfo(info projectInfo) {
 	}
 	fmt.Fprintf(w, "%s %s\n", ui.Bold("deploy.sql:"), deploySQLStatus)
 	fmt.Fprintf(w, "%s %s\n", ui.Bold("pgmi.yaml:"), info.ConfigFile)
+	fmt.Fprintf(w, "%s %s\n", ui.Bold("Session API:"), formatSessionAPI(info.SessionAPI))
 	fmt.Fprintln(w)
 
 	fmt.Fprintf(w, "%s\n", ui.Bold("Files"))
@@ -168,5 +215,23 @@ func printProjectInfo(info projectInfo) {
 	}
 }
 
+// formatSessionAPI renders the version line, flagging a pin older than what
+// the project uses: that deploy fails on the first reference to a missing
+// object, and info is the one place to see it coming.
+func formatSessionAPI(api sessionAPIInfo) string {
+	source := "latest"
+	if api.Pinned != "" {
+		source = "pinned in pgmi.yaml"
+	}
+	line := fmt.Sprintf("v%s (%s), requires v%s", api.Effective, source, api.Required)
+	if len(api.RequiredBy) > 0 {
+		line += " for " + strings.Join(api.RequiredBy, ", ")
+	}
+	if api.Pinned != "" && contract.Version(api.Pinned).Before(contract.Version(api.Required)) {
+		line += " " + ui.FailIcon() + " pin is older than the project needs"
+	}
+	return line
+}
+
 // ensure FileScanner is compatible
 var _ pgmi.FileScanner = (*scanner.Scanner)(nil)
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -81,6 +82,48 @@ func TestRunInfo_BasicProject(t *testing.T) {
 	}
 }
 
+func TestRunInfo_ReportsSessionAPIVersion(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"deploy.sql":    "SELECT path FROM pg_temp.pgmi_plan_detail_view ORDER BY execution_order;",
+		"pgmi.yaml":     "compat: \"1\"\n",
+		"schema/01.sql": "CREATE TABLE t(id int);",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	infoFlags.jsonOutput = true
+	defer func() { infoFlags.jsonOutput = false }()
+
+	var runErr error
+	out := captureStdout(t, func() { runErr = runInfo(infoCmd, []string{dir}) })
+	if runErr != nil {
+		t.Fatalf("runInfo() error: %v", runErr)
+	}
+
+	var info projectInfo
+	if err := json.Unmarshal([]byte(out), &info); err != nil {
+		t.Fatalf("json.Unmarshal: %v\nraw: %s", err, out)
+	}
+	api := info.SessionAPI
+	if api.Pinned != "1" || api.Effective != "1" {
+		t.Errorf("pinned/effective = %q/%q, want 1/1", api.Pinned, api.Effective)
+	}
+	if api.Required != "2" || len(api.RequiredBy) != 1 || api.RequiredBy[0] != "pgmi_plan_detail_view" {
+		t.Errorf("required = %q by %v, want 2 by pgmi_plan_detail_view", api.Required, api.RequiredBy)
+	}
+	if line := formatSessionAPI(api); !strings.Contains(line, "older than the project needs") {
+		t.Errorf("a pin older than the project needs is not flagged: %q", line)
+	}
+}
+
 func TestShowBanner_SuppressedByEnv(t *testing.T) {
 	t.Setenv("PGMI_NO_BANNER", "1")
 	if showBanner() {
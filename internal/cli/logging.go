@@ -0,0 +1,84 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/vvka-141/pgmi/internal/db"
+	"github.com/vvka-141/pgmi/internal/logging"
+	"github.com/vvka-141/pgmi/pkg/pgmi"
+)
+
+// logFlags holds the flags selecting structured logging, shared by the
+// commands that talk to a database.
+type logFlags struct {
+	logFormat string
+	logFile   string
+}
+
+// addLogFlags registers the structured logging flags on cmd.
+func addLogFlags(cmd *cobra.Command, f *logFlags) {
+	cmd.Flags().StringVar(&f.logFormat, "log-format", "",
+		"Write timestamped log records instead of plain messages: text or json\n"+
+			"Every record carries a run_id; PostgreSQL notices are logged at their severity")
+	cmd.Flags().StringVar(&f.logFile, "log-file", "",
+		"Append log records to this file instead of stderr (implies --log-format text)")
+}
+
+// newCommandLogger returns the logger a command reports through. Without
+// --log-format or --log-file it is the console logger, printing as pgmi
+// always has. With either, it is a SlogLogger, and PostgreSQL's notices go
+// through it rather than straight to stderr. The returned func restores
+// db.NoticeHandler and closes the log file.
+func newCommandLogger(f logFlags, verbose bool) (pgmi.Logger, func(), error) {
+	if f.logFormat == "" && f.logFile == "" {
+		return logging.NewConsoleLogger(verbose), func() {}, nil
+	}
+
+	var w io.Writer = os.Stderr
+	var file *os.File
+	if f.logFile != "" {
+		var err error
+		// 0600: records carry hosts, database names and whatever deploy.sql
+		// raises; the same convention as pgmi.yaml.
+		file, err = os.OpenFile(f.logFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: --log-file: %w", pgmi.ErrInvalidConfig, err)
+		}
+		w = file
+	}
+
+	logger, err := logging.NewSlogLogger(w, f.logFormat, verbose, "")
+	if err != nil {
+		if file != nil {
+			_ = file.Close()
+		}
+		return nil, nil, fmt.Errorf("%w: --log-format: %w", pgmi.ErrUsage, err)
+	}
+
+	origHandler := db.NoticeHandler
+	db.NoticeHandler = logger.Notice
+	return logger, func() {
+		db.NoticeHandler = origHandler
+		if file != nil {
+			_ = file.Close()
+		}
+	}, nil
+}
+
+// logDeployOutcome records how a deployment ended in a structured log, where
+// an aggregator looks for it. On the console main's "pgmi: error:" line
+// already says so.
+func logDeployOutcome(logger pgmi.Logger, err error) {
+	if _, structured := logger.(*logging.SlogLogger); !structured {
+		return
+	}
+	if err != nil {
+		logger.Error("Deployment failed (exit code %d): %s", pgmi.ExitCodeForError(err), pgmi.FormatError(err))
+		return
+	}
+	logger.Info("Deployment succeeded")
+}
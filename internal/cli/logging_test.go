@@ -0,0 +1,59 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/vvka-141/pgmi/internal/db"
+	"github.com/vvka-141/pgmi/internal/logging"
+	"github.com/vvka-141/pgmi/pkg/pgmi"
+)
+
+func TestNewCommandLogger(t *testing.T) {
+	logger, closeLogger, err := newCommandLogger(logFlags{}, false)
+	if err != nil {
+		t.Fatalf("newCommandLogger: %v", err)
+	}
+	closeLogger()
+	if _, ok := logger.(*logging.ConsoleLogger); !ok {
+		t.Errorf("without log flags got %T, want the console logger", logger)
+	}
+
+	orig := db.NoticeHandler
+	defer func() { db.NoticeHandler = orig }()
+	var printed []string
+	db.NoticeHandler = func(_, message, _, _ string) { printed = append(printed, message) }
+
+	// --log-file routes the command's records and the server's notices to
+	// the file, until closed.
+	path := filepath.Join(t.TempDir(), "pgmi.log")
+	logger, closeLogger, err = newCommandLogger(logFlags{logFormat: "json", logFile: path}, false)
+	if err != nil {
+		t.Fatalf("newCommandLogger: %v", err)
+	}
+	logger.Info("Loaded %d files", 3)
+	db.NoticeHandler("WARNING", "from deploy.sql", "", "")
+	closeLogger()
+	db.NoticeHandler("NOTICE", "after close", "", "")
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := string(data)
+	for _, want := range []string{`"msg":"Loaded 3 files"`, `"msg":"from deploy.sql"`, `"level":"WARN"`, `"run_id":`} {
+		if !strings.Contains(out, want) {
+			t.Errorf("log file lacks %s:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "after close") || len(printed) != 1 || printed[0] != "after close" {
+		t.Errorf("closing the logger did not restore db.NoticeHandler: printed %v", printed)
+	}
+
+	_, _, err = newCommandLogger(logFlags{logFormat: "xml"}, false)
+	if got := pgmi.ExitCodeForError(err); got != pgmi.ExitUsageError {
+		t.Errorf("--log-format xml: exit code %d, want %d: %v", got, pgmi.ExitUsageError, err)
+	}
+}
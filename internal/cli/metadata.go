@@ -3,8 +3,11 @@ package cli
 import (
 	"encoding/json"
 	"fmt"
+	"maps"
 	"os"
 	"path/filepath"
+	"reflect"
+	"slices"
 	"strings"
 
 	"github.com/spf13/cobra"
@@ -22,8 +25,11 @@ var metadataCmd = &cobra.Command{
   pgmi metadata scaffold ./project --write
   pgmi metadata validate ./project
   pgmi metadata plan ./project --json
+  pgmi metadata convert ./project --to yaml --write
+  pgmi metadata renumber ./project --write
+  pgmi metadata diff main HEAD --project ./project
 
-All three subcommands operate purely on the filesystem — no database
+Every subcommand operates purely on the filesystem — no database
 connection is opened.`,
 }
 
@@ -36,6 +42,9 @@ gets a fallback UUID derived from the file path and the default settings.
   pgmi metadata scaffold ./project              Preview only
   pgmi metadata scaffold ./project --write      Apply to files
   pgmi metadata scaffold ./project --idempotent=false --write
+  pgmi metadata scaffold ./project --format yaml --write
+
+--format picks the syntax: xml (default), or yaml or json front-matter.
 
 Without --write, no files are modified.`,
 	Args:              RequireProjectPath,
@@ -47,7 +56,9 @@ var metadataValidateCmd = &cobra.Command{
 	Use:   "validate <project_path>",
 	Short: "Check <pgmi-meta> XML validity and uniqueness",
 	Long: `Check that every <pgmi-meta> block parses, conforms to the XSD schema,
-and that no two files share an id.
+that no two files share an id, that every <dependsOn> names an existing
+script without forming a cycle, and that custom attributes match the
+metadata schema in pgmi.yaml, when it declares one.
 
   pgmi metadata validate ./project
   pgmi metadata validate ./project --json
@@ -62,7 +73,10 @@ var metadataPlanCmd = &cobra.Command{
 	Use:   "plan <project_path>",
 	Short: "Show files in execution order from sortKeys",
 	Long: `Show every SQL file with its id, sortKeys, and idempotent flag, ordered
-the way pgmi_plan_view would order them at deploy time.
+the way pgmi_plan_view would order them at deploy time. Files that declare
+<dependsOn> also show what they wait for and their dependency order, the
+order pgmi_plan_detail_view.dependency_order gives. Custom attributes are shown as
+pgmi_plan_detail_view.custom holds them.
 
   pgmi metadata plan ./project
   pgmi metadata plan ./project --json
@@ -73,10 +87,35 @@ Use this to verify ordering before running ` + "`pgmi deploy`" + `.`,
 	RunE:              runMetadataPlan,
 }
 
+var metadataConvertCmd = &cobra.Command{
+	Use:   "convert <project_path>",
+	Short: "Rewrite metadata blocks as XML, YAML or JSON",
+	Long: `Rewrite every metadata block in the project in one syntax: <pgmi-meta>
+XML, or YAML or JSON front-matter. The fields are unchanged, so the plan
+pgmi_plan_view gives is the same before and after; only the comment holding
+them is replaced.
+
+  pgmi metadata convert ./project --to yaml             Preview only
+  pgmi metadata convert ./project --to yaml --write     Apply to files
+  pgmi metadata convert ./project --to xml --write      And back
+
+Whitespace around a description is dropped, and so is anything pgmi
+ignores inside a <pgmi-meta> block: XML comments and unprefixed elements
+it does not know. Without --write, no files are modified.`,
+	Args:              RequireProjectPath,
+	ValidArgsFunction: completeDirectories,
+	RunE:              runMetadataConvert,
+}
+
 var (
 	// Scaffold flags
 	scaffoldIdempotent bool
 	scaffoldWrite      bool
+	scaffoldFormat     string
+
+	// Convert flags
+	convertTo    string
+	convertWrite bool
 
 	// Validate flags
 	validateJSON bool
@@ -90,10 +129,17 @@ func init() {
 	metadataCmd.AddCommand(metadataScaffoldCmd)
 	metadataCmd.AddCommand(metadataValidateCmd)
 	metadataCmd.AddCommand(metadataPlanCmd)
+	metadataCmd.AddCommand(metadataConvertCmd)
 
 	// Scaffold flags
 	metadataScaffoldCmd.Flags().BoolVar(&scaffoldWrite, "write", false, "Write generated metadata to files (default: preview only)")
 	metadataScaffoldCmd.Flags().BoolVar(&scaffoldIdempotent, "idempotent", true, "Mark generated scripts as idempotent (default: true)")
+	metadataScaffoldCmd.Flags().StringVar(&scaffoldFormat, "format", string(metadata.FormatXML), "Metadata syntax: xml, yaml or json")
+
+	// Convert flags
+	metadataConvertCmd.Flags().StringVar(&convertTo, "to", "", "Syntax to convert to: xml, yaml or json (required)")
+	metadataConvertCmd.Flags().BoolVar(&convertWrite, "write", false, "Write converted metadata to files (default: preview only)")
+	_ = metadataConvertCmd.MarkFlagRequired("to")
 
 	// Validate flags
 	metadataValidateCmd.Flags().BoolVar(&validateJSON, "json", false, "Output validation results as JSON")
@@ -110,10 +156,16 @@ func runMetadataScaffold(cmd *cobra.Command, args []string) error {
 	// Preview mode unless --write is specified
 	previewOnly := !scaffoldWrite
 
+	format, err := parseMetadataFormat("--format", scaffoldFormat)
+	if err != nil {
+		return err
+	}
+
 	if verbose {
 		fmt.Fprintf(os.Stderr, "[VERBOSE] Project path: %s\n", projectPath)
 		fmt.Fprintf(os.Stderr, "[VERBOSE] Preview only: %v\n", previewOnly)
 		fmt.Fprintf(os.Stderr, "[VERBOSE] Default idempotent: %v\n", scaffoldIdempotent)
+		fmt.Fprintf(os.Stderr, "[VERBOSE] Format: %s\n", format)
 	}
 
 	// Create scanner
@@ -121,7 +173,7 @@ func runMetadataScaffold(cmd *cobra.Command, args []string) error {
 
 	// Scan directory
 	fmt.Fprintln(os.Stderr, "Scanning SQL files...")
-	scanResult, err := fileScanner.ScanDirectory(projectPath)
+	scanResult, err := fileScanner.ScanDirectoryExcluding(projectPath, declaredEntrypoints(projectPath)...)
 	if err != nil {
 		return fmt.Errorf("failed to scan directory: %w", err)
 	}
@@ -152,20 +204,16 @@ func runMetadataScaffold(cmd *cobra.Command, args []string) error {
 		fallbackID := metadata.GenerateFallbackID(relPath)
 
 		// Build metadata block
-		metaBlock := fmt.Sprintf(`/*
-<pgmi-meta
-    id="%s"
-    idempotent="%v">
-  <description>
-    Auto-generated metadata for %s
-  </description>
-  <sortKeys>
-    <key>generated/%s</key>
-  </sortKeys>
-</pgmi-meta>
-*/
-
-`, fallbackID, scaffoldIdempotent, filepath.Base(filePath), filepath.Base(filePath))
+		idempotent := scaffoldIdempotent
+		metaBlock, err := metadata.Render(&metadata.Metadata{
+			ID:          fallbackID,
+			Idempotent:  &idempotent,
+			Description: "Auto-generated metadata for " + filepath.Base(filePath),
+			SortKeys:    metadata.SortKeysElement{Keys: []string{"generated/" + filepath.Base(filePath)}},
+		}, format)
+		if err != nil {
+			return err
+		}
 
 		fmt.Fprintf(os.Stderr, "  %s\n", filePath)
 		fmt.Fprintf(os.Stderr, "    ID: %s (fallback)\n", fallbackID)
@@ -183,23 +231,9 @@ func runMetadataScaffold(cmd *cobra.Command, args []string) error {
 			}
 
 			// Prepend metadata block
-			newContent := metaBlock + string(content)
-
-			// Atomic write: write to a sibling .tmp then os.Rename. A crash
-			// between Write and Rename leaves the original intact; a crash
-			// during Rename is handled atomically by the OS. Preserves the
-			// source file's mode so we don't silently widen permissions.
-			origInfo, err := os.Stat(absPath)
-			if err != nil {
-				return fmt.Errorf("failed to stat file %s: %w", filePath, err)
-			}
-			tmpPath := absPath + ".pgmi-tmp"
-			if err := os.WriteFile(tmpPath, []byte(newContent), origInfo.Mode().Perm()); err != nil {
-				return fmt.Errorf("failed to write %s: %w", filePath, err)
-			}
-			if err := os.Rename(tmpPath, absPath); err != nil {
-				_ = os.Remove(tmpPath)
-				return fmt.Errorf("failed to finalise write of %s: %w", filePath, err)
+			newContent := metaBlock + "\n\n" + string(content)
+			if err := writeFileAtomic(absPath, filePath, []byte(newContent)); err != nil {
+				return err
 			}
 
 			fmt.Fprintf(os.Stderr, "    Written to file\n")
@@ -216,6 +250,143 @@ func runMetadataScaffold(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+// writeFileAtomic replaces absPath's content: write to a sibling .tmp then
+// os.Rename. A crash between Write and Rename leaves the original intact; a
+// crash during Rename is handled atomically by the OS. Preserves the source
+// file's mode so we don't silently widen permissions. filePath names the file
+// in errors.
+func writeFileAtomic(absPath, filePath string, content []byte) error {
+	origInfo, err := os.Stat(absPath)
+	if err != nil {
+		return fmt.Errorf("failed to stat file %s: %w", filePath, err)
+	}
+	tmpPath := absPath + ".pgmi-tmp"
+	if err := os.WriteFile(tmpPath, content, origInfo.Mode().Perm()); err != nil {
+		return fmt.Errorf("failed to write %s: %w", filePath, err)
+	}
+	if err := os.Rename(tmpPath, absPath); err != nil {
+		_ = os.Remove(tmpPath)
+		return fmt.Errorf("failed to finalise write of %s: %w", filePath, err)
+	}
+	return nil
+}
+
+// parseMetadataFormat checks a metadata syntax flag.
+func parseMetadataFormat(flag, value string) (metadata.Format, error) {
+	format := metadata.Format(strings.ToLower(value))
+	if !slices.Contains(metadata.Formats, format) {
+		return "", fmt.Errorf("invalid %s %q: must be xml, yaml or json: %w", flag, value, pgmi.ErrInvalidConfig)
+	}
+	return format, nil
+}
+
+// runMetadataConvert rewrites every metadata block in the --to syntax.
+func runMetadataConvert(cmd *cobra.Command, args []string) error {
+	projectPath := args[0]
+	verbose := getVerboseFlag(cmd)
+	previewOnly := !convertWrite
+
+	format, err := parseMetadataFormat("--to", convertTo)
+	if err != nil {
+		return err
+	}
+
+	if verbose {
+		fmt.Fprintf(os.Stderr, "[VERBOSE] Project path: %s\n", projectPath)
+		fmt.Fprintf(os.Stderr, "[VERBOSE] Preview only: %v\n", previewOnly)
+		fmt.Fprintf(os.Stderr, "[VERBOSE] Target format: %s\n", format)
+	}
+
+	fmt.Fprintln(os.Stderr, "Scanning SQL files...")
+	scanResult, err := scanner.NewScanner(checksum.New()).ScanDirectoryExcluding(projectPath, declaredEntrypoints(projectPath)...)
+	if err != nil {
+		return fmt.Errorf("failed to scan directory: %w", err)
+	}
+
+	// Convert everything in memory first: one file that cannot be converted
+	// should not leave the project half in each syntax.
+	type conversion struct {
+		path, absPath string
+		from          metadata.Format
+		content       string
+	}
+	var conversions []conversion
+	for _, file := range scanResult.Files {
+		if file.Metadata == nil {
+			continue
+		}
+		absPath := filepath.Join(projectPath, filepath.FromSlash(file.Path))
+		raw, err := os.ReadFile(absPath)
+		if err != nil {
+			return fmt.Errorf("failed to read file %s: %w", file.Path, err)
+		}
+		converted, from, err := convertMetadataBlock(string(raw), file.Path, format)
+		if err != nil {
+			return err
+		}
+		if from != format {
+			conversions = append(conversions, conversion{file.Path, absPath, from, converted})
+		}
+	}
+
+	if len(conversions) == 0 {
+		fmt.Fprintf(os.Stderr, "All metadata is already %s.\n", strings.ToUpper(string(format)))
+		return nil
+	}
+
+	fmt.Fprintf(os.Stderr, "Found %d file(s) to convert to %s:\n\n", len(conversions), strings.ToUpper(string(format)))
+	for _, c := range conversions {
+		fmt.Fprintf(os.Stderr, "  %s (%s)\n", c.path, strings.ToUpper(string(c.from)))
+		if !previewOnly {
+			if err := writeFileAtomic(c.absPath, c.path, []byte(c.content)); err != nil {
+				return err
+			}
+			fmt.Fprintf(os.Stderr, "    Written to file\n")
+		}
+	}
+	fmt.Fprintln(os.Stderr)
+
+	if previewOnly {
+		fmt.Fprintln(os.Stderr, "Preview mode: no files were modified. Use --write to apply changes.")
+	} else {
+		fmt.Fprintf(os.Stderr, "Converted metadata in %d file(s).\n", len(conversions))
+	}
+	return nil
+}
+
+// convertMetadataBlock returns content with its metadata block rewritten in
+// format, and the format it was in. The result is read back before it is
+// returned: a conversion that would change what pgmi sees is refused rather
+// than written.
+func convertMetadataBlock(content, filePath string, format metadata.Format) (string, metadata.Format, error) {
+	block, err := metadata.Locate(content, filePath)
+	if err != nil {
+		return "", "", err
+	}
+	meta, err := metadata.Extract(content, filePath)
+	if err != nil {
+		return "", "", err
+	}
+	if block.Format == format {
+		return content, block.Format, nil
+	}
+
+	// The indentation scaffold puts around an XML description is layout,
+	// and would come out of YAML as a quoted string full of \n.
+	meta.Description = strings.TrimSpace(meta.Description)
+	rendered, err := metadata.Render(meta, format)
+	if err != nil {
+		return "", "", fmt.Errorf("cannot convert %s: %w", filePath, err)
+	}
+	converted := content[:block.Start] + rendered + content[block.End:]
+
+	check, err := metadata.Extract(converted, filePath)
+	if err != nil || !reflect.DeepEqual(check, meta) {
+		return "", "", fmt.Errorf("cannot convert %s to %s without changing its metadata; convert it by hand: %w", filePath, format, pgmi.ErrInvalidConfig)
+	}
+	return converted, block.Format, nil
+}
+
 // runMetadataValidate validates metadata across all files
 func runMetadataValidate(cmd *cobra.Command, args []string) error {
 	projectPath := args[0]
@@ -257,6 +428,22 @@ func runMetadataValidate(cmd *cobra.Command, args []string) error {
 			fmt.Fprintln(os.Stderr)
 		}
 
+		if len(result.DependencyErrors) > 0 {
+			fmt.Fprintln(os.Stderr, "Error: Invalid <dependsOn>:")
+			for _, problem := range result.DependencyErrors {
+				fmt.Fprintln(os.Stderr, "  "+problem)
+			}
+			fmt.Fprintln(os.Stderr)
+		}
+
+		if len(result.CustomAttributeErrors) > 0 {
+			fmt.Fprintln(os.Stderr, "Error: Custom attributes do not match pgmi.yaml:")
+			for _, problem := range result.CustomAttributeErrors {
+				fmt.Fprintln(os.Stderr, "  "+problem)
+			}
+			fmt.Fprintln(os.Stderr)
+		}
+
 		if result.ValidationPassed {
 			fmt.Fprintln(os.Stderr, "Metadata validation passed.")
 		}
@@ -300,6 +487,7 @@ func runMetadataPlan(cmd *cobra.Command, args []string) error {
 		// Human-readable output
 		fmt.Fprintf(os.Stderr, "\nMetadata Summary (%d files):\n\n", len(plan))
 
+		hasDependencies := slices.ContainsFunc(plan, func(e MetadataPlanEntry) bool { return len(e.DependsOn) > 0 })
 		for i, entry := range plan {
 			fmt.Fprintf(os.Stderr, "%d. %s\n", i+1, entry.Path)
 			fmt.Fprintf(os.Stderr, "   ID: %s\n", entry.ID)
@@ -308,11 +496,34 @@ func runMetadataPlan(cmd *cobra.Command, args []string) error {
 			}
 			fmt.Fprintf(os.Stderr, "   Idempotent: %v\n", entry.Idempotent)
 			fmt.Fprintf(os.Stderr, "   Sort Keys: %v\n", entry.SortKeys)
+			if len(entry.DependsOn) > 0 {
+				fmt.Fprintf(os.Stderr, "   Depends On: %v\n", entry.DependsOn)
+			}
+			if hasDependencies {
+				fmt.Fprintf(os.Stderr, "   Dependency Order: %d\n", entry.DependencyOrder)
+			}
+			if len(entry.Custom) > 0 {
+				fmt.Fprintf(os.Stderr, "   Custom: %s\n", formatCustom(entry.Custom))
+			}
 			fmt.Fprintln(os.Stderr)
 		}
 
 		fmt.Fprintln(os.Stderr, "Note: Actual execution order is determined by sort keys during deployment.")
+		if hasDependencies {
+			fmt.Fprintln(os.Stderr, "Dependency order applies where deploy.sql orders by pgmi_plan_detail_view.dependency_order.")
+		}
 	}
 
 	return nil
 }
+
+// formatCustom renders custom attributes as sorted prefix:name=value pairs.
+func formatCustom(custom map[string]map[string]any) string {
+	var pairs []string
+	for _, prefix := range slices.Sorted(maps.Keys(custom)) {
+		for _, name := range slices.Sorted(maps.Keys(custom[prefix])) {
+			pairs = append(pairs, fmt.Sprintf("%s:%s=%v", prefix, name, custom[prefix][name]))
+		}
+	}
+	return strings.Join(pairs, ", ")
+}
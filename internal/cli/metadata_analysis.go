@@ -2,6 +2,7 @@ package cli
 
 import (
 	"cmp"
+	"errors"
 	"slices"
 
 	"github.com/google/uuid"
@@ -18,6 +19,14 @@ type MetadataPlanEntry struct {
 	Idempotent  bool     `json:"idempotent"`
 	SortKeys    []string `json:"sort_keys"`
 	Description string   `json:"description"`
+	// DependsOn lists the resolved paths of the scripts named in <dependsOn>.
+	DependsOn []string `json:"depends_on"`
+	// DependencyOrder is the file's 1-based position once <dependsOn> is
+	// honoured; it equals its position in Plan when nothing declares any.
+	DependencyOrder int `json:"dependency_order"`
+	// Custom holds the file's prefixed <pgmi-meta> attributes, typed by the
+	// metadata schema in pgmi.yaml; the same JSON as pgmi_plan_detail_view.custom.
+	Custom map[string]map[string]any `json:"custom"`
 }
 
 // MetadataPlanResult is the structured result of analyzing a project's plan.
@@ -33,14 +42,37 @@ type MetadataValidateResult struct {
 	FilesWithoutMetadata int      `json:"files_without_metadata"`
 	ValidationPassed     bool     `json:"validation_passed"`
 	DuplicateIDs         []string `json:"duplicate_ids"`
+	DependencyErrors     []string `json:"dependency_errors"`
+	// CustomAttributeErrors lists custom attributes that break the metadata
+	// schema in pgmi.yaml.
+	CustomAttributeErrors []string `json:"custom_attribute_errors"`
 }
 
 // planProject scans a project and returns its files ordered to approximate
-// deployment execution order (smallest sort key, then path).
+// deployment execution order (smallest sort key, then path), each with its
+// position in the dependency order pgmi_plan_detail_view.dependency_order gives.
 func planProject(projectPath string) (MetadataPlanResult, error) {
-	scanResult, err := scanner.NewScanner(checksum.New()).ScanDirectory(projectPath)
+	result, _, err := planProjectFiles(projectPath)
+	return result, err
+}
+
+// planProjectFiles is planProject that also returns the scanned files, for
+// callers that need more of each file than the plan shows.
+func planProjectFiles(projectPath string) (MetadataPlanResult, []pgmi.FileMetadata, error) {
+	scanResult, err := scanner.NewScanner(checksum.New()).ScanDirectoryExcluding(projectPath, declaredEntrypoints(projectPath)...)
+	if err != nil {
+		return MetadataPlanResult{}, nil, err
+	}
+	deps, err := metadata.ResolveDependencies(scanResult.Files)
+	if err != nil {
+		return MetadataPlanResult{}, nil, err
+	}
+	schema, err := declaredMetadataSchema(projectPath)
 	if err != nil {
-		return MetadataPlanResult{}, err
+		return MetadataPlanResult{}, nil, err
+	}
+	if err := metadata.ApplySchema(scanResult.Files, schema); err != nil {
+		return MetadataPlanResult{}, nil, err
 	}
 
 	plan := make([]MetadataPlanEntry, 0, len(scanResult.Files))
@@ -69,6 +101,8 @@ func planProject(projectPath string) (MetadataPlanResult, error) {
 			Idempotent:  file.Metadata.Idempotent,
 			SortKeys:    file.Metadata.SortKeys,
 			Description: file.Metadata.Description,
+			DependsOn:   deps[file.Path],
+			Custom:      file.Metadata.Custom,
 		})
 	}
 
@@ -80,7 +114,25 @@ func planProject(projectPath string) (MetadataPlanResult, error) {
 		return cmp.Compare(a.Path, b.Path)
 	})
 
-	return MetadataPlanResult{TotalFiles: len(plan), Plan: plan}, nil
+	order := make([]string, len(plan))
+	for i, e := range plan {
+		order[i] = e.Path
+	}
+	position := make(map[string]int, len(plan))
+	for i, p := range metadata.DependencyOrder(order, deps) {
+		position[p] = i + 1
+	}
+	for i := range plan {
+		plan[i].DependencyOrder = position[plan[i].Path]
+		if plan[i].DependsOn == nil {
+			plan[i].DependsOn = []string{}
+		}
+		if plan[i].Custom == nil {
+			plan[i].Custom = map[string]map[string]any{}
+		}
+	}
+
+	return MetadataPlanResult{TotalFiles: len(plan), Plan: plan}, scanResult.Files, nil
 }
 
 func minSortKey(e MetadataPlanEntry) string {
@@ -96,11 +148,13 @@ func minSortKey(e MetadataPlanEntry) string {
 	return m
 }
 
-// validateProject scans a project, checks for duplicate metadata IDs, and
-// returns the validation summary. The error is non-nil only on scan failure;
-// a failed validation is reported via ValidationPassed.
+// validateProject scans a project, checks for duplicate metadata IDs, for
+// <dependsOn> ids that match no script or form a cycle, and for custom
+// attributes the metadata schema in pgmi.yaml rejects, and returns the
+// validation summary. The error is non-nil only when the project cannot be
+// scanned or its pgmi.yaml is invalid; a failed validation is reported via ValidationPassed.
 func validateProject(projectPath string) (MetadataValidateResult, error) {
-	scanResult, err := scanner.NewScanner(checksum.New()).ScanDirectory(projectPath)
+	scanResult, err := scanner.NewScanner(checksum.New()).ScanDirectoryExcluding(projectPath, declaredEntrypoints(projectPath)...)
 	if err != nil {
 		return MetadataValidateResult{}, err
 	}
@@ -120,11 +174,35 @@ func validateProject(projectPath string) (MetadataValidateResult, error) {
 		}
 	}
 
+	dependencyErrors := []string{}
+	if _, err := metadata.ResolveDependencies(scanResult.Files); err != nil {
+		var depErr *metadata.DependencyError
+		if !errors.As(err, &depErr) {
+			return MetadataValidateResult{}, err
+		}
+		dependencyErrors = depErr.Problems
+	}
+
+	schema, err := declaredMetadataSchema(projectPath)
+	if err != nil {
+		return MetadataValidateResult{}, err
+	}
+	customErrors := []string{}
+	if err := metadata.ApplySchema(scanResult.Files, schema); err != nil {
+		var customErr *metadata.CustomAttributeError
+		if !errors.As(err, &customErr) {
+			return MetadataValidateResult{}, err
+		}
+		customErrors = customErr.Problems
+	}
+
 	return MetadataValidateResult{
-		TotalFiles:           len(scanResult.Files),
-		FilesWithMetadata:    withMetadata,
-		FilesWithoutMetadata: len(scanResult.Files) - withMetadata,
-		ValidationPassed:     len(duplicates) == 0,
-		DuplicateIDs:         duplicates,
+		TotalFiles:            len(scanResult.Files),
+		FilesWithMetadata:     withMetadata,
+		FilesWithoutMetadata:  len(scanResult.Files) - withMetadata,
+		ValidationPassed:      len(duplicates) == 0 && len(dependencyErrors) == 0 && len(customErrors) == 0,
+		DuplicateIDs:          duplicates,
+		DependencyErrors:      dependencyErrors,
+		CustomAttributeErrors: customErrors,
 	}, nil
 }
@@ -0,0 +1,497 @@
+package cli
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/vvka-141/pgmi/pkg/pgmi"
+)
+
+var metadataDiffCmd = &cobra.Command{
+	Use:   "diff <old> <new>",
+	Short: "Compare the plans of two project revisions",
+	Long: `Compare the execution plans of two versions of a project: which files
+were added or removed, which changed content (normalized checksum, so
+whitespace and comments do not count), which moved in the order, and which
+changed idempotency, sort keys, dependencies or id. Changes that behave
+differently on databases that already ran the old version are flagged:
+editing a run-once script that has shipped, most of all.
+
+Each of <old> and <new> is a directory, or else a git revision of the
+project in --project (default: the current directory).
+
+  pgmi metadata diff ./release-1.4 ./project
+  pgmi metadata diff main HEAD --project ./db
+  pgmi metadata diff origin/main . --json
+
+Files are matched by their <pgmi-meta> id, so a renamed file is a rename,
+not a removal and an addition. The old side is taken to be what has shipped.`,
+	Args:              cobra.ExactArgs(2),
+	ValidArgsFunction: completeDirectories,
+	RunE:              runMetadataDiff,
+}
+
+var (
+	diffJSON    bool
+	diffProject string
+)
+
+func init() {
+	metadataCmd.AddCommand(metadataDiffCmd)
+	metadataDiffCmd.Flags().BoolVar(&diffJSON, "json", false, "Output the diff as JSON")
+	metadataDiffCmd.Flags().StringVar(&diffProject, "project", ".", "Project directory inside the git repository, for revision arguments")
+}
+
+// Risk levels of a MetadataDiffEntry, lowest first.
+const (
+	RiskLow    = "low"
+	RiskMedium = "medium"
+	RiskHigh   = "high"
+)
+
+// MetadataDiffEntry is one file that differs between the two plans.
+type MetadataDiffEntry struct {
+	// Path is the file's path in the new revision, or in the old one when it
+	// was removed. OldPath is set when the two differ.
+	Path    string `json:"path"`
+	OldPath string `json:"old_path,omitempty"`
+	ID      string `json:"id"`
+	// Changes lists what differs: added, removed, renamed, id, content,
+	// moved, idempotent, sort_keys, depends_on.
+	Changes []string `json:"changes"`
+	// OldPosition and NewPosition are 1-based places in each plan, 0 where
+	// the file is absent.
+	OldPosition int  `json:"old_position"`
+	NewPosition int  `json:"new_position"`
+	Idempotent  bool `json:"idempotent"`
+	// Risk is low, medium or high; Reasons says why for anything above low.
+	Risk    string   `json:"risk"`
+	Reasons []string `json:"reasons"`
+}
+
+// MetadataDiffResult is the structured result of comparing two plans.
+type MetadataDiffResult struct {
+	Old      string              `json:"old"`
+	New      string              `json:"new"`
+	Added    int                 `json:"added"`
+	Removed  int                 `json:"removed"`
+	Changed  int                 `json:"changed"`
+	Moved    int                 `json:"moved"`
+	HighRisk int                 `json:"high_risk"`
+	Files    []MetadataDiffEntry `json:"files"`
+}
+
+// diffSide is one revision's plan, indexed for matching.
+type diffSide struct {
+	entries  []MetadataPlanEntry
+	checksum map[string]string // path → normalized checksum
+	byID     map[string]int    // id → index in entries
+}
+
+func loadDiffSide(projectPath string) (diffSide, error) {
+	result, files, err := planProjectFiles(projectPath)
+	if err != nil {
+		return diffSide{}, err
+	}
+	side := diffSide{entries: result.Plan, checksum: make(map[string]string), byID: make(map[string]int)}
+	for _, f := range files {
+		side.checksum[f.Path] = f.Checksum
+	}
+	for i, e := range result.Plan {
+		side.byID[e.ID] = i
+	}
+	return side, nil
+}
+
+// diffProjects compares the plans of two project directories.
+func diffProjects(oldPath, newPath string) (MetadataDiffResult, error) {
+	oldSide, err := loadDiffSide(oldPath)
+	if err != nil {
+		return MetadataDiffResult{}, fmt.Errorf("old revision: %w", err)
+	}
+	newSide, err := loadDiffSide(newPath)
+	if err != nil {
+		return MetadataDiffResult{}, fmt.Errorf("new revision: %w", err)
+	}
+
+	// Pair files by id; a path that lost one id and gained another is the
+	// same file with a new id, which is worth more attention than a rename.
+	pairs := make(map[int]int) // new index → old index
+	var added, removed []int
+	for i, e := range newSide.entries {
+		if j, ok := oldSide.byID[e.ID]; ok {
+			pairs[i] = j
+		} else {
+			added = append(added, i)
+		}
+	}
+	for j, e := range oldSide.entries {
+		if _, ok := newSide.byID[e.ID]; !ok {
+			removed = append(removed, j)
+		}
+	}
+	oldByPath := make(map[string]int)
+	for _, j := range removed {
+		oldByPath[oldSide.entries[j].Path] = j
+	}
+	var stillAdded []int
+	for _, i := range added {
+		if j, ok := oldByPath[newSide.entries[i].Path]; ok {
+			pairs[i] = j
+			delete(oldByPath, newSide.entries[i].Path)
+			continue
+		}
+		stillAdded = append(stillAdded, i)
+	}
+	removed = slices.DeleteFunc(removed, func(j int) bool {
+		_, ok := oldByPath[oldSide.entries[j].Path]
+		return !ok
+	})
+
+	unchangedKeys := make(map[int]bool, len(pairs))
+	for i, j := range pairs {
+		unchangedKeys[i] = slices.Equal(newSide.entries[i].SortKeys, oldSide.entries[j].SortKeys)
+	}
+	moved := movedFiles(pairs, unchangedKeys)
+
+	var result MetadataDiffResult
+	for i := range newSide.entries {
+		n := newSide.entries[i]
+		j, paired := pairs[i]
+		if !paired {
+			continue
+		}
+		o := oldSide.entries[j]
+		entry := MetadataDiffEntry{Path: n.Path, ID: n.ID, OldPosition: j + 1, NewPosition: i + 1, Idempotent: n.Idempotent}
+		if o.Path != n.Path {
+			entry.OldPath = o.Path
+			entry.Changes = append(entry.Changes, "renamed")
+		}
+		if o.ID != n.ID {
+			entry.Changes = append(entry.Changes, "id")
+			if !o.Idempotent {
+				entry.flag(RiskHigh, fmt.Sprintf("id changed from %s: databases that already ran this run-once script will run it again", o.ID))
+			}
+		}
+		if oldSide.checksum[o.Path] != newSide.checksum[n.Path] {
+			entry.Changes = append(entry.Changes, "content")
+			if !o.Idempotent {
+				entry.flag(RiskHigh, "run-once script edited after it shipped: databases that already ran it keep the old version, new databases get this one")
+			}
+		}
+		if moved[i] {
+			entry.Changes = append(entry.Changes, "moved")
+			result.Moved++
+			if !o.Idempotent || !n.Idempotent {
+				entry.flag(RiskMedium, "run-once script moved in the order: existing databases are unaffected, new databases run it at its new place")
+			}
+		}
+		if o.Idempotent != n.Idempotent {
+			entry.Changes = append(entry.Changes, "idempotent")
+			if n.Idempotent {
+				entry.flag(RiskHigh, "run-once script made idempotent: it will run on every deploy, including on databases that already ran it")
+			} else {
+				entry.flag(RiskMedium, "script made run-once: databases that ran it before run it once more under the new tracking, then never again")
+			}
+		}
+		if !slices.Equal(o.SortKeys, n.SortKeys) {
+			entry.Changes = append(entry.Changes, "sort_keys")
+		}
+		if !slices.Equal(o.DependsOn, n.DependsOn) {
+			entry.Changes = append(entry.Changes, "depends_on")
+		}
+		if len(entry.Changes) > 0 {
+			result.Changed++
+			result.Files = append(result.Files, entry.finish())
+		}
+	}
+	for _, i := range stillAdded {
+		n := newSide.entries[i]
+		result.Added++
+		result.Files = append(result.Files, MetadataDiffEntry{
+			Path: n.Path, ID: n.ID, Changes: []string{"added"}, NewPosition: i + 1, Idempotent: n.Idempotent,
+		}.finish())
+	}
+	for _, j := range removed {
+		o := oldSide.entries[j]
+		entry := MetadataDiffEntry{Path: o.Path, ID: o.ID, Changes: []string{"removed"}, OldPosition: j + 1, Idempotent: o.Idempotent}
+		if !o.Idempotent {
+			entry.flag(RiskMedium, "run-once script removed: databases that already ran it keep its effects, new databases will not get them")
+		}
+		result.Removed++
+		result.Files = append(result.Files, entry.finish())
+	}
+
+	slices.SortStableFunc(result.Files, func(a, b MetadataDiffEntry) int {
+		return diffSortPosition(a) - diffSortPosition(b)
+	})
+	for _, f := range result.Files {
+		if f.Risk == RiskHigh {
+			result.HighRisk++
+		}
+	}
+	if result.Files == nil {
+		result.Files = []MetadataDiffEntry{}
+	}
+	return result, nil
+}
+
+// diffSortPosition lists files in new plan order, each removed file just
+// after where it used to run relative to the rest.
+func diffSortPosition(e MetadataDiffEntry) int {
+	if e.NewPosition > 0 {
+		return 2 * e.NewPosition
+	}
+	return 2*e.OldPosition + 1
+}
+
+func (e *MetadataDiffEntry) flag(risk, reason string) {
+	if riskRank(risk) > riskRank(e.Risk) {
+		e.Risk = risk
+	}
+	e.Reasons = append(e.Reasons, reason)
+}
+
+func (e MetadataDiffEntry) finish() MetadataDiffEntry {
+	if e.Risk == "" {
+		e.Risk = RiskLow
+	}
+	if e.Reasons == nil {
+		e.Reasons = []string{}
+	}
+	return e
+}
+
+func riskRank(risk string) int {
+	return slices.Index([]string{"", RiskLow, RiskMedium, RiskHigh}, risk)
+}
+
+// movedFiles returns the files whose order relative to the other files in
+// both plans changed: everything outside a largest set of pairs that kept
+// their order. One file moved to the front is one move, not n-1. Where
+// several sets are equally large, the one keeping the most files with
+// unchanged sort keys wins: a file whose keys changed is the one that moved.
+func movedFiles(pairs map[int]int, unchangedKeys map[int]bool) map[int]bool {
+	newIdx := make([]int, 0, len(pairs))
+	for i := range pairs {
+		newIdx = append(newIdx, i)
+	}
+	slices.Sort(newIdx)
+
+	// Heaviest increasing subsequence of old positions, in new order. Each
+	// file weighs more than all the tie-breaking bonuses put together, so
+	// the count is maximised first.
+	weight := func(k int) int {
+		w := len(newIdx) + 1
+		if unchangedKeys[newIdx[k]] {
+			w++
+		}
+		return w
+	}
+	best := make([]int, len(newIdx))
+	prev := make([]int, len(newIdx))
+	end := -1
+	for k := range newIdx {
+		best[k], prev[k] = weight(k), -1
+		for m := range k {
+			if pairs[newIdx[m]] < pairs[newIdx[k]] && best[m]+weight(k) > best[k] {
+				best[k], prev[k] = best[m]+weight(k), m
+			}
+		}
+		if end < 0 || best[k] > best[end] {
+			end = k
+		}
+	}
+	kept := make(map[int]bool)
+	for k := end; k >= 0; k = prev[k] {
+		kept[newIdx[k]] = true
+	}
+	moved := make(map[int]bool)
+	for _, i := range newIdx {
+		if !kept[i] {
+			moved[i] = true
+		}
+	}
+	return moved
+}
+
+// resolveDiffSide returns a directory holding arg: arg itself when it is a
+// directory, otherwise the project at that git revision, extracted to a
+// temporary directory that cleanup removes.
+func resolveDiffSide(ctx context.Context, arg, projectPath string) (dir string, cleanup func(), err error) {
+	if info, err := os.Stat(arg); err == nil && info.IsDir() {
+		return arg, func() {}, nil
+	}
+
+	out, err := gitOutput(ctx, projectPath, "rev-parse", "--show-toplevel", "--show-prefix")
+	if err != nil {
+		return "", nil, fmt.Errorf("%q is not a directory, and %s is not in a git repository to read it as a revision from: %w", arg, projectPath, pgmi.ErrInvalidConfig)
+	}
+	toplevel, prefix, _ := strings.Cut(strings.TrimRight(out, "\n"), "\n")
+	if _, err := gitOutput(ctx, projectPath, "rev-parse", "--verify", "--quiet", arg+"^{commit}"); err != nil {
+		return "", nil, fmt.Errorf("%q is neither a directory nor a git revision: %w", arg, pgmi.ErrInvalidConfig)
+	}
+	// From the top level: run in a subdirectory, git archive would look for
+	// that subdirectory again inside the tree it is given.
+	archive, err := gitOutput(ctx, toplevel, "archive", "--format=tar", arg+":"+prefix)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to read %s at %s: %w", projectPath, arg, err)
+	}
+
+	dir, err = os.MkdirTemp("", "pgmi-diff-")
+	if err != nil {
+		return "", nil, err
+	}
+	cleanup = func() { _ = os.RemoveAll(dir) }
+	if err := extractTar(strings.NewReader(archive), dir); err != nil {
+		cleanup()
+		return "", nil, fmt.Errorf("failed to extract %s at %s: %w", projectPath, arg, err)
+	}
+	return dir, cleanup, nil
+}
+
+func gitOutput(ctx context.Context, dir string, args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "git", append([]string{"-C", dir}, args...)...)
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("git %s: %s", args[0], msg)
+		}
+		return "", fmt.Errorf("git %s: %w", args[0], err)
+	}
+	return stdout.String(), nil
+}
+
+// extractTar writes the regular files of a git archive under dir. Links are
+// skipped: the scanner does not follow them either.
+func extractTar(r io.Reader, dir string) error {
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if hdr.Typeflag != tar.TypeReg || !filepath.IsLocal(hdr.Name) {
+			continue
+		}
+		path := filepath.Join(dir, filepath.FromSlash(hdr.Name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return err
+		}
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(f, tr)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// runMetadataDiff compares two revisions and prints the report.
+func runMetadataDiff(cmd *cobra.Command, args []string) error {
+	verbose := getVerboseFlag(cmd)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	if verbose {
+		fmt.Fprintf(os.Stderr, "[VERBOSE] Old: %s\n", args[0])
+		fmt.Fprintf(os.Stderr, "[VERBOSE] New: %s\n", args[1])
+		fmt.Fprintf(os.Stderr, "[VERBOSE] Project: %s\n", diffProject)
+	}
+
+	var dirs [2]string
+	for i, arg := range args {
+		dir, cleanup, err := resolveDiffSide(ctx, arg, diffProject)
+		if err != nil {
+			return err
+		}
+		defer cleanup()
+		dirs[i] = dir
+	}
+
+	result, err := diffProjects(dirs[0], dirs[1])
+	if err != nil {
+		return err
+	}
+	result.Old, result.New = args[0], args[1]
+
+	if diffJSON {
+		jsonBytes, err := json.MarshalIndent(result, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal JSON: %w", err)
+		}
+		fmt.Println(string(jsonBytes))
+		return nil
+	}
+
+	fmt.Fprintf(os.Stderr, "\nPlan diff %s → %s: %d added, %d removed, %d changed, %d moved",
+		result.Old, result.New, result.Added, result.Removed, result.Changed, result.Moved)
+	if result.HighRisk > 0 {
+		fmt.Fprintf(os.Stderr, " (%d high risk)", result.HighRisk)
+	}
+	fmt.Fprintln(os.Stderr)
+	if len(result.Files) == 0 {
+		fmt.Fprintln(os.Stderr, "\nThe plans are the same.")
+		return nil
+	}
+	fmt.Fprintln(os.Stderr)
+	for _, f := range result.Files {
+		fmt.Fprintf(os.Stderr, "  %-6s %s %s  %s\n", strings.ToUpper(f.Risk), diffMarker(f), f.Path, describeDiffEntry(f))
+		for _, reason := range f.Reasons {
+			fmt.Fprintf(os.Stderr, "           %s\n", reason)
+		}
+	}
+	return nil
+}
+
+func diffMarker(f MetadataDiffEntry) string {
+	switch f.Changes[0] {
+	case "added":
+		return "+"
+	case "removed":
+		return "-"
+	}
+	return "~"
+}
+
+func describeDiffEntry(f MetadataDiffEntry) string {
+	switch f.Changes[0] {
+	case "added":
+		return fmt.Sprintf("added at #%d", f.NewPosition)
+	case "removed":
+		return fmt.Sprintf("removed (was #%d)", f.OldPosition)
+	}
+	var parts []string
+	for _, c := range f.Changes {
+		switch c {
+		case "renamed":
+			parts = append(parts, "renamed from "+f.OldPath)
+		case "moved":
+			parts = append(parts, fmt.Sprintf("moved #%d → #%d", f.OldPosition, f.NewPosition))
+		default:
+			parts = append(parts, strings.ReplaceAll(c, "_", " "))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
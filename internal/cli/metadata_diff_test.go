@@ -0,0 +1,201 @@
+package cli
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/vvka-141/pgmi/pkg/pgmi"
+)
+
+func diffScript(id, key string, idempotent bool, body string) string {
+	flag := "true"
+	if !idempotent {
+		flag = "false"
+	}
+	return `/*
+<pgmi-meta id="` + id + `" idempotent="` + flag + `">
+  <sortKeys><key>` + key + `</key></sortKeys>
+</pgmi-meta>
+*/
+` + body
+}
+
+const (
+	diffID1 = "11111111-1111-4111-8111-111111111111"
+	diffID2 = "22222222-2222-4222-8222-222222222222"
+	diffID3 = "33333333-3333-4333-8333-333333333333"
+	diffID4 = "44444444-4444-4444-8444-444444444444"
+	diffID5 = "55555555-5555-4555-8555-555555555555"
+)
+
+func diffByPath(result MetadataDiffResult) map[string]MetadataDiffEntry {
+	out := make(map[string]MetadataDiffEntry)
+	for _, f := range result.Files {
+		out[f.Path] = f
+	}
+	return out
+}
+
+func TestDiffProjects(t *testing.T) {
+	oldPath := createTestProject(t, map[string]string{
+		"deploy.sql":          "SELECT 1;",
+		"m/001_users.sql":     diffScript(diffID1, "001", false, "CREATE TABLE users (id int);"),
+		"m/002_orders.sql":    diffScript(diffID2, "002", false, "CREATE TABLE orders (id int);"),
+		"m/003_backfill.sql":  diffScript(diffID3, "003", false, "UPDATE orders SET id = id;"),
+		"api/view.sql":        diffScript(diffID4, "100", true, "CREATE VIEW v AS SELECT 1;"),
+		"m/004_obsolete.sql":  diffScript(diffID5, "004", false, "SELECT 'gone';"),
+		"notes/formatted.sql": "SELECT 1;",
+	})
+	newPath := createTestProject(t, map[string]string{
+		"deploy.sql": "SELECT 1;",
+		// Shipped run-once script edited: high risk.
+		"m/001_users.sql": diffScript(diffID1, "001", false, "CREATE TABLE users (id int, email text);"),
+		// Moved to the end of the run-once scripts.
+		"m/002_orders.sql": diffScript(diffID2, "009", false, "CREATE TABLE orders (id int);"),
+		// Renamed, same id and content.
+		"m/003_backfill_orders.sql": diffScript(diffID3, "003", false, "UPDATE orders SET id = id;"),
+		// Made run-once → idempotent: reruns everywhere.
+		"api/view.sql":  diffScript(diffID4, "100", true, "CREATE VIEW v AS SELECT 2;"),
+		"m/005_new.sql": diffScript("66666666-6666-4666-8666-666666666666", "005", false, "SELECT 'new';"),
+		// Only whitespace and comments differ: not a content change.
+		"notes/formatted.sql": "-- tidied\nSELECT   1;",
+	})
+
+	result, err := diffProjects(oldPath, newPath)
+	if err != nil {
+		t.Fatalf("diffProjects: %v", err)
+	}
+	if result.Added != 1 || result.Removed != 1 || result.Moved != 1 {
+		t.Errorf("added=%d removed=%d moved=%d, want 1 each", result.Added, result.Removed, result.Moved)
+	}
+
+	files := diffByPath(result)
+	check := func(path string, changes []string, risk string) {
+		t.Helper()
+		f, ok := files[path]
+		if !ok {
+			t.Errorf("%s missing from the diff", path)
+			return
+		}
+		if !reflect.DeepEqual(f.Changes, changes) || f.Risk != risk {
+			t.Errorf("%s: changes %v risk %s, want %v %s (%v)", path, f.Changes, f.Risk, changes, risk, f.Reasons)
+		}
+	}
+	check("./m/001_users.sql", []string{"content"}, RiskHigh)
+	check("./m/002_orders.sql", []string{"moved", "sort_keys"}, RiskMedium)
+	check("./m/003_backfill_orders.sql", []string{"renamed"}, RiskLow)
+	check("./api/view.sql", []string{"content"}, RiskLow)
+	check("./m/005_new.sql", []string{"added"}, RiskLow)
+	check("./m/004_obsolete.sql", []string{"removed"}, RiskMedium)
+	if _, ok := files["./notes/formatted.sql"]; ok {
+		t.Error("a whitespace and comment change is not a content change")
+	}
+	if files["./m/003_backfill_orders.sql"].OldPath != "./m/003_backfill.sql" {
+		t.Errorf("rename should record the old path, got %+v", files["./m/003_backfill_orders.sql"])
+	}
+	if result.HighRisk != 1 {
+		t.Errorf("high_risk = %d, want 1", result.HighRisk)
+	}
+}
+
+func TestDiffProjects_IdentityChanges(t *testing.T) {
+	oldPath := createTestProject(t, map[string]string{
+		"deploy.sql": "SELECT 1;",
+		"a.sql":      diffScript(diffID1, "001", false, "SELECT 1;"),
+		"b.sql":      diffScript(diffID2, "002", false, "SELECT 2;"),
+	})
+	newPath := createTestProject(t, map[string]string{
+		"deploy.sql": "SELECT 1;",
+		// Same path, new id: runs again where it already ran.
+		"a.sql": diffScript(diffID3, "001", false, "SELECT 1;"),
+		"b.sql": diffScript(diffID2, "002", true, "SELECT 2;"),
+	})
+
+	result, err := diffProjects(oldPath, newPath)
+	if err != nil {
+		t.Fatalf("diffProjects: %v", err)
+	}
+	files := diffByPath(result)
+	if f := files["./a.sql"]; !reflect.DeepEqual(f.Changes, []string{"id"}) || f.Risk != RiskHigh {
+		t.Errorf("a.sql: %+v", f)
+	}
+	if f := files["./b.sql"]; !reflect.DeepEqual(f.Changes, []string{"idempotent"}) || f.Risk != RiskHigh {
+		t.Errorf("b.sql: %+v", f)
+	}
+	if result.Added != 0 || result.Removed != 0 {
+		t.Errorf("an id change is not an addition and a removal: %+v", result)
+	}
+}
+
+func TestMovedFiles_OneMoveIsOneMove(t *testing.T) {
+	// The last of five files moved to the front: only it moved, though every
+	// other file's position changed.
+	pairs := map[int]int{0: 4, 1: 0, 2: 1, 3: 2, 4: 3}
+	if got := movedFiles(pairs, nil); !reflect.DeepEqual(got, map[int]bool{0: true}) {
+		t.Errorf("moved = %v, want only the file now first", got)
+	}
+}
+
+func TestMetadataDiff_GitRevisions(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	repo := t.TempDir()
+	project := filepath.Join(repo, "db")
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-C", repo, "-c", "user.name=t", "-c", "user.email=t@example.com"}, args...)...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	write := func(name, content string) {
+		t.Helper()
+		path := filepath.Join(project, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	git("init", "-q")
+	write("deploy.sql", "SELECT 1;")
+	write("m/001.sql", diffScript(diffID1, "001", false, "CREATE TABLE t (id int);"))
+	git("add", "-A")
+	git("commit", "-q", "-m", "first")
+	git("tag", "v1")
+	write("m/001.sql", diffScript(diffID1, "001", false, "CREATE TABLE t (id bigint);"))
+	git("commit", "-q", "-am", "second")
+
+	dir, cleanup, err := resolveDiffSide(t.Context(), "v1", project)
+	if err != nil {
+		t.Fatalf("resolveDiffSide: %v", err)
+	}
+	defer cleanup()
+	if _, err := os.Stat(filepath.Join(dir, "m", "001.sql")); err != nil {
+		t.Fatalf("v1 was not extracted relative to the project: %v", err)
+	}
+
+	result, err := diffProjects(dir, project)
+	if err != nil {
+		t.Fatalf("diffProjects: %v", err)
+	}
+	if result.HighRisk != 1 || result.Files[0].Path != "./m/001.sql" {
+		t.Errorf("expected the edited run-once script flagged, got %+v", result)
+	}
+}
+
+func TestMetadataDiff_UnknownRevision(t *testing.T) {
+	project := createTestProject(t, map[string]string{"deploy.sql": "SELECT 1;"})
+	_, _, err := resolveDiffSide(t.Context(), "no-such-rev-or-dir", project)
+	if !errors.Is(err, pgmi.ErrInvalidConfig) || !strings.Contains(err.Error(), "no-such-rev-or-dir") {
+		t.Errorf("expected an invalid-config error naming the argument, got %v", err)
+	}
+}
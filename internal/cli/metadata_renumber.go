@@ -0,0 +1,272 @@
+package cli
+
+import (
+	"cmp"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/vvka-141/pgmi/internal/checksum"
+	"github.com/vvka-141/pgmi/internal/files/scanner"
+	"github.com/vvka-141/pgmi/internal/metadata"
+	"github.com/vvka-141/pgmi/pkg/pgmi"
+)
+
+var metadataRenumberCmd = &cobra.Command{
+	Use:   "renumber <project_path>",
+	Short: "Respace numeric sort keys without changing the plan",
+	Long: `Rewrite <sortKeys> so the numbers in them are evenly spaced again,
+leaving room to insert scripts between any two. The execution order
+pgmi_plan_view gives is the same before and after.
+
+A key's last segment is renumbered when it is all digits; what comes before
+it (10-schema/ in 10-schema/0007) groups keys that are numbered together, in
+plan order, by --step. Keys with any other last segment are left alone, and
+so are files without metadata, which run in path order.
+
+  pgmi metadata renumber ./project               Preview a diff
+  pgmi metadata renumber ./project --write       Apply to files
+  pgmi metadata renumber ./project --step 100
+
+Nothing is written if the new keys would change the order of any two plan
+entries, including the phases of a file with several sort keys.`,
+	Args:              RequireProjectPath,
+	ValidArgsFunction: completeDirectories,
+	RunE:              runMetadataRenumber,
+}
+
+var (
+	renumberStep  int
+	renumberWrite bool
+)
+
+func init() {
+	metadataCmd.AddCommand(metadataRenumberCmd)
+	metadataRenumberCmd.Flags().IntVar(&renumberStep, "step", 10, "Gap between consecutive numbers in a group")
+	metadataRenumberCmd.Flags().BoolVar(&renumberWrite, "write", false, "Write renumbered sort keys to files (default: preview only)")
+}
+
+// renumberChange is one file whose sort keys renumber rewrites.
+type renumberChange struct {
+	Path    string
+	OldKeys []string
+	NewKeys []string
+	Before  string // file content
+	After   string
+}
+
+// planStep is one row of pgmi_plan_view: a file at one of its sort keys.
+type planStep struct {
+	path  string
+	key   string
+	phase int // index of key in the file's <sortKeys>
+}
+
+// planSteps orders every file's sort keys the way pgmi_plan_view does:
+// sort_key, then path, byte-wise. A file without sort keys runs at its path.
+func planSteps(keys map[string][]string) []planStep {
+	var steps []planStep
+	for path, fileKeys := range keys {
+		if len(fileKeys) == 0 {
+			fileKeys = []string{path}
+		}
+		for i, k := range fileKeys {
+			steps = append(steps, planStep{path, k, i})
+		}
+	}
+	slices.SortStableFunc(steps, func(a, b planStep) int {
+		if n := cmp.Compare(a.key, b.key); n != 0 {
+			return n
+		}
+		if n := cmp.Compare(a.path, b.path); n != 0 {
+			return n
+		}
+		return cmp.Compare(a.phase, b.phase)
+	})
+	return steps
+}
+
+// renumberProject works out the renumbered sort keys of every file and
+// checks that the plan they give is the plan the current keys give.
+func renumberProject(projectPath string, step int) ([]renumberChange, error) {
+	if step < 1 {
+		return nil, fmt.Errorf("invalid --step %d: must be at least 1: %w", step, pgmi.ErrInvalidConfig)
+	}
+	scanResult, err := scanner.NewScanner(checksum.New()).ScanDirectoryExcluding(projectPath, declaredEntrypoints(projectPath)...)
+	if err != nil {
+		return nil, err
+	}
+
+	// Every file pgmi_plan_view holds takes a place in the order, including
+	// the ones renumbering cannot touch.
+	current := make(map[string][]string)
+	for _, file := range scanResult.Files {
+		if pgmi.IsTestPath(file.Path) {
+			continue
+		}
+		current[file.Path] = nil
+		if file.Metadata != nil {
+			current[file.Path] = file.Metadata.SortKeys
+		}
+	}
+	before := planSteps(current)
+
+	// Number each group in plan order, padded to one width so that string
+	// order is number order.
+	type group struct {
+		steps []planStep
+		width int
+	}
+	groups := make(map[string]*group)
+	var prefixes []string
+	for _, s := range before {
+		if current[s.path] == nil {
+			continue
+		}
+		prefix, digits, ok := numberedKey(s.key)
+		if !ok {
+			continue
+		}
+		g := groups[prefix]
+		if g == nil {
+			g = &group{}
+			groups[prefix] = g
+			prefixes = append(prefixes, prefix)
+		}
+		g.steps = append(g.steps, s)
+		g.width = max(g.width, len(digits))
+	}
+	renumbered := make(map[string][]string, len(current))
+	for path, keys := range current {
+		renumbered[path] = slices.Clone(keys)
+	}
+	for _, prefix := range prefixes {
+		g := groups[prefix]
+		width := max(g.width, len(strconv.Itoa(len(g.steps)*step)))
+		for i, s := range g.steps {
+			renumbered[s.path][s.phase] = fmt.Sprintf("%s%0*d", prefix, width, (i+1)*step)
+		}
+	}
+
+	if err := checkSamePlan(before, planSteps(renumbered), current); err != nil {
+		return nil, err
+	}
+
+	var changes []renumberChange
+	for _, file := range scanResult.Files {
+		oldKeys, newKeys := current[file.Path], renumbered[file.Path]
+		if slices.Equal(oldKeys, newKeys) {
+			continue
+		}
+		absPath := filepath.Join(projectPath, filepath.FromSlash(file.Path))
+		raw, err := os.ReadFile(absPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read file %s: %w", file.Path, err)
+		}
+		after, err := metadata.ReplaceSortKeys(string(raw), file.Path, newKeys)
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, renumberChange{file.Path, oldKeys, newKeys, string(raw), after})
+	}
+	return changes, nil
+}
+
+// numberedKey splits a sort key whose last segment is all digits.
+func numberedKey(key string) (prefix, digits string, ok bool) {
+	i := strings.LastIndex(key, "/") + 1
+	prefix, digits = key[:i], key[i:]
+	if digits == "" || strings.Trim(digits, "0123456789") != "" {
+		return "", "", false
+	}
+	return prefix, digits, true
+}
+
+// checkSamePlan refuses renumbered keys that would run anything in a
+// different order, naming a file whose phases would swap when there is one:
+// that is the change a reviewer of the diff is least likely to notice.
+func checkSamePlan(before, after []planStep, current map[string][]string) error {
+	phases := func(steps []planStep) map[string][]int {
+		out := make(map[string][]int)
+		for _, s := range steps {
+			out[s.path] = append(out[s.path], s.phase)
+		}
+		return out
+	}
+	beforePhases, afterPhases := phases(before), phases(after)
+	for _, s := range before {
+		if len(current[s.path]) > 1 && !slices.Equal(beforePhases[s.path], afterPhases[s.path]) {
+			return fmt.Errorf("refusing to renumber: the sort keys of %s would run in a different relative order (%s); "+
+				"give its phases keys in separate groups, or renumber by hand: %w",
+				s.path, strings.Join(current[s.path], ", "), pgmi.ErrInvalidConfig)
+		}
+	}
+	for i := range before {
+		if before[i].path != after[i].path || before[i].phase != after[i].phase {
+			return fmt.Errorf("refusing to renumber: %s (%s) would no longer run at position %d of the plan; "+
+				"keys in other groups or unnumbered keys sort between the new numbers: %w",
+				before[i].path, before[i].key, i+1, pgmi.ErrInvalidConfig)
+		}
+	}
+	return nil
+}
+
+// runMetadataRenumber previews or writes renumbered sort keys.
+func runMetadataRenumber(cmd *cobra.Command, args []string) error {
+	projectPath := args[0]
+	verbose := getVerboseFlag(cmd)
+	previewOnly := !renumberWrite
+
+	if verbose {
+		fmt.Fprintf(os.Stderr, "[VERBOSE] Project path: %s\n", projectPath)
+		fmt.Fprintf(os.Stderr, "[VERBOSE] Preview only: %v\n", previewOnly)
+		fmt.Fprintf(os.Stderr, "[VERBOSE] Step: %d\n", renumberStep)
+	}
+
+	fmt.Fprintln(os.Stderr, "Scanning SQL files...")
+	changes, err := renumberProject(projectPath, renumberStep)
+	if err != nil {
+		return err
+	}
+	if len(changes) == 0 {
+		fmt.Fprintln(os.Stderr, "Sort keys are already evenly spaced.")
+		return nil
+	}
+
+	for _, c := range changes {
+		fmt.Print(lineDiff(c.Path, c.Before, c.After))
+	}
+	fmt.Fprintln(os.Stderr)
+
+	if previewOnly {
+		fmt.Fprintf(os.Stderr, "Preview mode: %d file(s) would change. Use --write to apply changes.\n", len(changes))
+		return nil
+	}
+	for _, c := range changes {
+		absPath := filepath.Join(projectPath, filepath.FromSlash(c.Path))
+		if err := writeFileAtomic(absPath, c.Path, []byte(c.After)); err != nil {
+			return err
+		}
+	}
+	fmt.Fprintf(os.Stderr, "Renumbered sort keys in %d file(s). The plan order is unchanged.\n", len(changes))
+	return nil
+}
+
+// lineDiff renders a unified diff of two versions of a file that differ only
+// within lines, one hunk per changed line, as renumbering produces.
+func lineDiff(path, before, after string) string {
+	name := strings.TrimPrefix(path, "./")
+	var b strings.Builder
+	fmt.Fprintf(&b, "--- a/%s\n+++ b/%s\n", name, name)
+	oldLines, newLines := strings.Split(before, "\n"), strings.Split(after, "\n")
+	for i := range min(len(oldLines), len(newLines)) {
+		if oldLines[i] != newLines[i] {
+			fmt.Fprintf(&b, "@@ -%d +%d @@\n-%s\n+%s\n", i+1, i+1, oldLines[i], newLines[i])
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,150 @@
+package cli
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/vvka-141/pgmi/pkg/pgmi"
+)
+
+func resetRenumberFlags() {
+	renumberStep = 10
+	renumberWrite = false
+}
+
+func keysScript(id string, keys ...string) string {
+	var xml string
+	for _, k := range keys {
+		xml += "\n    <key>" + k + "</key>"
+	}
+	return `/*
+<pgmi-meta id="` + id + `" idempotent="true">
+  <sortKeys>` + xml + `
+  </sortKeys>
+</pgmi-meta>
+*/
+SELECT 1;`
+}
+
+func renumberFixture(t *testing.T) string {
+	return createTestProject(t, map[string]string{
+		"deploy.sql": "SELECT 1;",
+		"a.sql":      keysScript("11111111-1111-4111-8111-111111111111", "001/000"),
+		"b.sql":      keysScript("22222222-2222-4222-8222-222222222222", "001/001"),
+		"c.sql":      keysScript("33333333-3333-4333-8333-333333333333", "001/001", "099/5"),
+		"d.sql": "/*\n---\npgmi-meta:\n  id: 44444444-4444-4444-8444-444444444444\n  idempotent: true\n" +
+			"  sortKeys: [001/002, tail]  # yaml keeps its comment\n---\n*/\nSELECT 4;",
+		"e.sql": "SELECT 5;",
+	})
+}
+
+func TestMetadataRenumber_PreservesPlan(t *testing.T) {
+	resetRenumberFlags()
+	projectPath := renumberFixture(t)
+	before, err := planProject(projectPath)
+	if err != nil {
+		t.Fatalf("planProject: %v", err)
+	}
+
+	changes, err := renumberProject(projectPath, 10)
+	if err != nil {
+		t.Fatalf("renumberProject: %v", err)
+	}
+	got := map[string][]string{}
+	for _, c := range changes {
+		got[c.Path] = c.NewKeys
+	}
+	// Ties (b and c at 001/001) become distinct numbers in path order, the
+	// order the plan already broke them in.
+	want := map[string][]string{
+		"./a.sql": {"001/010"},
+		"./b.sql": {"001/020"},
+		"./c.sql": {"001/030", "099/10"},
+		"./d.sql": {"001/040", "tail"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("new keys = %v, want %v", got, want)
+	}
+
+	renumberWrite = true
+	if err := runMetadataRenumber(metadataRenumberCmd, []string{projectPath}); err != nil {
+		t.Fatalf("renumber --write: %v", err)
+	}
+	d, _ := os.ReadFile(filepath.Join(projectPath, "d.sql"))
+	if !strings.Contains(string(d), "sortKeys: [001/040, tail]  # yaml keeps its comment") {
+		t.Errorf("d.sql was rewritten beyond its keys:\n%s", d)
+	}
+	after, err := planProject(projectPath)
+	if err != nil {
+		t.Fatalf("planProject after renumber: %v", err)
+	}
+	for i := range before.Plan {
+		if before.Plan[i].Path != after.Plan[i].Path {
+			t.Fatalf("plan order changed at %d: %s, was %s", i+1, after.Plan[i].Path, before.Plan[i].Path)
+		}
+	}
+	if again, err := renumberProject(projectPath, 10); err != nil || len(again) != 0 {
+		t.Errorf("renumbering twice should change nothing, got %v, %v", again, err)
+	}
+}
+
+func TestMetadataRenumber_PreviewLeavesFiles(t *testing.T) {
+	resetRenumberFlags()
+	projectPath := renumberFixture(t)
+	before, _ := os.ReadFile(filepath.Join(projectPath, "a.sql"))
+
+	if err := runMetadataRenumber(metadataRenumberCmd, []string{projectPath}); err != nil {
+		t.Fatalf("renumber: %v", err)
+	}
+	after, _ := os.ReadFile(filepath.Join(projectPath, "a.sql"))
+	if string(after) != string(before) {
+		t.Error("Expected preview to NOT modify files")
+	}
+}
+
+func TestLineDiff(t *testing.T) {
+	got := lineDiff("./a.sql", "x\n<key>1</key>\ny", "x\n<key>10</key>\ny")
+	want := "--- a/a.sql\n+++ b/a.sql\n@@ -2 +2 @@\n-<key>1</key>\n+<key>10</key>\n"
+	if got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestMetadataRenumber_RefusesToReorderPhases(t *testing.T) {
+	// x.sql runs its 30-a/1 phase before its 9 phase. Respaced, 9 becomes 30,
+	// which sorts before 30-a/10: the phases would swap.
+	projectPath := createTestProject(t, map[string]string{
+		"deploy.sql": "SELECT 1;",
+		"x.sql":      keysScript("11111111-1111-4111-8111-111111111111", "9", "30-a/1"),
+		"y.sql":      keysScript("22222222-2222-4222-8222-222222222222", "1"),
+		"z.sql":      keysScript("33333333-3333-4333-8333-333333333333", "2"),
+	})
+	_, err := renumberProject(projectPath, 10)
+	if !errors.Is(err, pgmi.ErrInvalidConfig) || !strings.Contains(err.Error(), "./x.sql") {
+		t.Errorf("expected a refusal naming ./x.sql, got %v", err)
+	}
+}
+
+func TestMetadataRenumber_RefusesToReorderFiles(t *testing.T) {
+	// 5 → 20 would move y.sql ahead of 25-a/1.
+	projectPath := createTestProject(t, map[string]string{
+		"deploy.sql": "SELECT 1;",
+		"x.sql":      keysScript("11111111-1111-4111-8111-111111111111", "25-a/1"),
+		"y.sql":      keysScript("22222222-2222-4222-8222-222222222222", "5"),
+		"w.sql":      keysScript("33333333-3333-4333-8333-333333333333", "10"),
+	})
+	if _, err := renumberProject(projectPath, 10); !errors.Is(err, pgmi.ErrInvalidConfig) {
+		t.Errorf("expected a refusal, got %v", err)
+	}
+}
+
+func TestMetadataRenumber_InvalidStep(t *testing.T) {
+	projectPath := createTestProject(t, map[string]string{"deploy.sql": "SELECT 1;"})
+	if _, err := renumberProject(projectPath, 0); !errors.Is(err, pgmi.ErrInvalidConfig) {
+		t.Errorf("expected --step 0 to exit 10, got %v", err)
+	}
+}
@@ -1,15 +1,23 @@
 package cli
 
 import (
+	"encoding/json"
+	"errors"
 	"os"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
+
+	"github.com/vvka-141/pgmi/pkg/pgmi"
 )
 
 func resetMetadataFlags() {
 	scaffoldWrite = false
 	scaffoldIdempotent = true
+	scaffoldFormat = "xml"
+	convertTo = ""
+	convertWrite = false
 	validateJSON = false
 	planJSON = false
 }
@@ -452,3 +460,245 @@ CREATE TABLE t2(id int);`,
 		t.Fatal("Expected error for duplicate IDs in JSON mode")
 	}
 }
+
+func dependsOnScript(id, key string, dependsOn ...string) string {
+	var deps string
+	for _, d := range dependsOn {
+		deps += "<id>" + d + "</id>"
+	}
+	if deps != "" {
+		deps = "<dependsOn>" + deps + "</dependsOn>"
+	}
+	return `/*
+<pgmi-meta id="` + id + `" idempotent="true">
+  <sortKeys><key>` + key + `</key></sortKeys>
+  ` + deps + `
+</pgmi-meta>
+*/
+SELECT 1;`
+}
+
+func TestMetadataPlan_DependencyOrder(t *testing.T) {
+	resetMetadataFlags()
+	projectPath := createTestProject(t, map[string]string{
+		"deploy.sql":          "SELECT 1;",
+		"views/report.sql":    dependsOnScript("550e8400-e29b-41d4-a716-446655440001", "10", "22222222-2222-4222-8222-222222222222"),
+		"schemas/users.sql":   dependsOnScript("11111111-1111-4111-8111-111111111111", "20"),
+		"schemas/orders.sql":  dependsOnScript("22222222-2222-4222-8222-222222222222", "30", "schemas/users.sql"),
+		"migrations/seed.sql": dependsOnScript("33333333-3333-4333-8333-333333333333", "40"),
+	})
+
+	result, err := planProject(projectPath)
+	if err != nil {
+		t.Fatalf("planProject: %v", err)
+	}
+
+	got := map[string]int{}
+	var order []string
+	for _, e := range result.Plan {
+		got[e.Path] = e.DependencyOrder
+		order = append(order, e.Path)
+	}
+	// Listed by sort key, as before; the report view waits for orders.
+	if want := "./views/report.sql,./schemas/users.sql,./schemas/orders.sql,./migrations/seed.sql"; strings.Join(order, ",") != want {
+		t.Errorf("plan order %v, want %s", order, want)
+	}
+	want := map[string]int{"./schemas/users.sql": 1, "./schemas/orders.sql": 2, "./views/report.sql": 3, "./migrations/seed.sql": 4}
+	for path, pos := range want {
+		if got[path] != pos {
+			t.Errorf("%s: dependency_order %d, want %d", path, got[path], pos)
+		}
+	}
+	if deps := result.Plan[0].DependsOn; len(deps) != 1 || deps[0] != "./schemas/orders.sql" {
+		t.Errorf("report depends_on %v, want the resolved path ./schemas/orders.sql", deps)
+	}
+}
+
+func TestMetadataValidate_DependencyErrors(t *testing.T) {
+	resetMetadataFlags()
+	projectPath := createTestProject(t, map[string]string{
+		"deploy.sql": "SELECT 1;",
+		"a.sql":      dependsOnScript("11111111-1111-4111-8111-111111111111", "10", "b.sql"),
+		"b.sql":      dependsOnScript("22222222-2222-4222-8222-222222222222", "20", "a.sql"),
+		"c.sql":      dependsOnScript("33333333-3333-4333-8333-333333333333", "30", "nope.sql"),
+	})
+
+	result, err := validateProject(projectPath)
+	if err != nil {
+		t.Fatalf("validateProject: %v", err)
+	}
+	if result.ValidationPassed {
+		t.Error("validation passed despite a cycle and an unknown id")
+	}
+	want := []string{
+		`./c.sql: dependsOn "nope.sql" matches no script id or path`,
+		"dependsOn cycle: ./a.sql -> ./b.sql -> ./a.sql",
+	}
+	if strings.Join(result.DependencyErrors, "\n") != strings.Join(want, "\n") {
+		t.Errorf("dependency errors %v, want %v", result.DependencyErrors, want)
+	}
+
+	if err := runMetadataValidate(metadataValidateCmd, []string{projectPath}); err == nil {
+		t.Error("expected metadata validate to fail")
+	}
+	if _, err := planProject(projectPath); err == nil {
+		t.Error("expected metadata plan to refuse an unresolvable graph")
+	}
+}
+
+func customScript(id, attrs string) string {
+	return `/*
+<pgmi-meta id="` + id + `" idempotent="true" ` + attrs + `>
+  <sortKeys><key>10</key></sortKeys>
+</pgmi-meta>
+*/
+SELECT 1;`
+}
+
+func TestMetadataPlan_CustomAttributes(t *testing.T) {
+	resetMetadataFlags()
+	projectPath := createTestProject(t, map[string]string{
+		"deploy.sql": "SELECT 1;",
+		"pgmi.yaml": `metadata:
+  attributes:
+    ops:window:
+      type: boolean
+`,
+		"a.sql": customScript("11111111-1111-4111-8111-111111111111", `ops:window="true" team:owner="payments"`),
+		"b.sql": "SELECT 1;",
+	})
+
+	result, err := planProject(projectPath)
+	if err != nil {
+		t.Fatalf("planProject: %v", err)
+	}
+	out, err := json.Marshal(result.Plan)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	// Typed by pgmi.yaml where declared, a string where not, and {} for a
+	// file without metadata: the JSON pgmi_plan_detail_view.custom holds.
+	for _, want := range []string{
+		`"custom":{"ops":{"window":true},"team":{"owner":"payments"}}`,
+		`"path":"./b.sql","id":"`,
+		`"custom":{}`,
+	} {
+		if !strings.Contains(string(out), want) {
+			t.Errorf("plan JSON lacks %s:\n%s", want, out)
+		}
+	}
+}
+
+func TestMetadataValidate_CustomAttributeErrors(t *testing.T) {
+	resetMetadataFlags()
+	projectPath := createTestProject(t, map[string]string{
+		"deploy.sql": "SELECT 1;",
+		"pgmi.yaml": `metadata:
+  attributes:
+    ops:owner:
+      required: true
+`,
+		"a.sql": customScript("11111111-1111-4111-8111-111111111111", `ops:ownr="payments"`),
+	})
+
+	result, err := validateProject(projectPath)
+	if err != nil {
+		t.Fatalf("validateProject: %v", err)
+	}
+	want := []string{"./a.sql: missing required ops:owner", "./a.sql: ops:ownr is not declared in pgmi.yaml"}
+	if result.ValidationPassed || strings.Join(result.CustomAttributeErrors, "\n") != strings.Join(want, "\n") {
+		t.Errorf("passed=%t, custom attribute errors %v, want %v", result.ValidationPassed, result.CustomAttributeErrors, want)
+	}
+	if _, err := planProject(projectPath); !errors.Is(err, pgmi.ErrInvalidConfig) {
+		t.Errorf("expected metadata plan to refuse the project with exit 10, got %v", err)
+	}
+}
+
+func TestMetadataScaffold_FormatYAML(t *testing.T) {
+	resetMetadataFlags()
+	scaffoldWrite = true
+	scaffoldFormat = "yaml"
+
+	projectPath := createTestProject(t, map[string]string{
+		"deploy.sql":         "SELECT 1;",
+		"migrations/001.sql": "CREATE TABLE t1(id int);",
+	})
+	if err := runMetadataScaffold(metadataScaffoldCmd, []string{projectPath}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	content, _ := os.ReadFile(filepath.Join(projectPath, "migrations", "001.sql"))
+	if !strings.HasPrefix(string(content), "/*\n---\npgmi-meta:\n") || strings.Contains(string(content), "<pgmi-meta") {
+		t.Errorf("expected YAML front-matter, got:\n%s", content)
+	}
+	result, err := validateProject(projectPath)
+	if err != nil || !result.ValidationPassed || result.FilesWithMetadata != 1 {
+		t.Errorf("scaffolded YAML should validate: %+v, %v", result, err)
+	}
+}
+
+func TestMetadataScaffold_InvalidFormat(t *testing.T) {
+	resetMetadataFlags()
+	scaffoldFormat = "toml"
+	projectPath := createTestProject(t, map[string]string{"deploy.sql": "SELECT 1;"})
+	if err := runMetadataScaffold(metadataScaffoldCmd, []string{projectPath}); !errors.Is(err, pgmi.ErrInvalidConfig) {
+		t.Errorf("expected an invalid --format to exit 10, got %v", err)
+	}
+}
+
+func TestMetadataConvert_PreviewLeavesFiles(t *testing.T) {
+	resetMetadataFlags()
+	convertTo = "yaml"
+	script := customScript("11111111-1111-4111-8111-111111111111", `ops:owner="payments"`)
+	projectPath := createTestProject(t, map[string]string{"deploy.sql": "SELECT 1;", "a.sql": script})
+
+	if err := runMetadataConvert(metadataConvertCmd, []string{projectPath}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	content, _ := os.ReadFile(filepath.Join(projectPath, "a.sql"))
+	if string(content) != script {
+		t.Error("Expected preview to NOT modify files")
+	}
+}
+
+func TestMetadataConvert_RoundTripKeepsPlan(t *testing.T) {
+	resetMetadataFlags()
+	convertWrite = true
+	projectPath := createTestProject(t, map[string]string{
+		"deploy.sql": "SELECT 1;",
+		"a.sql":      "-- keep me\n" + customScript("11111111-1111-4111-8111-111111111111", `ops:owner="a &amp; b"`),
+		"b.sql":      dependsOnScript("22222222-2222-4222-8222-222222222222", "05", "./a.sql"),
+		"c.sql":      "SELECT 3;",
+	})
+	before, err := planProject(projectPath)
+	if err != nil {
+		t.Fatalf("planProject: %v", err)
+	}
+
+	for _, format := range []string{"json", "yaml", "xml"} {
+		convertTo = format
+		if err := runMetadataConvert(metadataConvertCmd, []string{projectPath}); err != nil {
+			t.Fatalf("convert --to %s: %v", format, err)
+		}
+		content, _ := os.ReadFile(filepath.Join(projectPath, "a.sql"))
+		if !strings.HasPrefix(string(content), "-- keep me\n/*") || !strings.HasSuffix(string(content), "*/\nSELECT 1;") {
+			t.Errorf("convert --to %s touched more than the metadata block:\n%s", format, content)
+		}
+		after, err := planProject(projectPath)
+		if err != nil {
+			t.Fatalf("planProject after --to %s: %v", format, err)
+		}
+		if !reflect.DeepEqual(after, before) {
+			t.Errorf("convert --to %s changed the plan:\n%+v\nwas\n%+v", format, after, before)
+		}
+	}
+}
+
+func TestMetadataConvert_InvalidTarget(t *testing.T) {
+	resetMetadataFlags()
+	convertTo = "toml"
+	projectPath := createTestProject(t, map[string]string{"deploy.sql": "SELECT 1;"})
+	if err := runMetadataConvert(metadataConvertCmd, []string{projectPath}); !errors.Is(err, pgmi.ErrInvalidConfig) {
+		t.Errorf("expected an invalid --to to exit 10, got %v", err)
+	}
+}
@@ -0,0 +1,102 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/vvka-141/pgmi/internal/db"
+	"github.com/vvka-141/pgmi/internal/metrics"
+	"github.com/vvka-141/pgmi/internal/services"
+	"github.com/vvka-141/pgmi/pkg/pgmi"
+)
+
+// metricsPushTimeout bounds how long exiting waits on a Pushgateway that does
+// not answer.
+const metricsPushTimeout = 10 * time.Second
+
+// metricsFlags holds the flags selecting where a deployment's Prometheus
+// metrics go.
+type metricsFlags struct {
+	metricsFile string
+	metricsPush string
+}
+
+// addMetricsFlags registers the metrics flags on cmd.
+func addMetricsFlags(cmd *cobra.Command, f *metricsFlags) {
+	cmd.Flags().StringVar(&f.metricsFile, "metrics-file", "",
+		"Write Prometheus metrics of the run to this file, for node_exporter's\n"+
+			"textfile collector (name it *.prom); other databases' samples in it are kept")
+	cmd.Flags().StringVar(&f.metricsPush, "metrics-push", "",
+		"Push Prometheus metrics of the run to this Pushgateway URL,\n"+
+			"e.g. http://localhost:9091, grouped by job=\"pgmi\" and database")
+}
+
+func (f metricsFlags) enabled() bool {
+	return f.metricsFile != "" || f.metricsPush != ""
+}
+
+// validate rejects a malformed --metrics-push before anything is deployed.
+func (f metricsFlags) validate() error {
+	if f.metricsPush == "" {
+		return nil
+	}
+	if _, err := metrics.ParseGatewayURL(f.metricsPush); err != nil {
+		return fmt.Errorf("%w: --metrics-push: %w", pgmi.ErrUsage, err)
+	}
+	return nil
+}
+
+// countTests counts the tests the deployment runs, from the notices passing
+// through db.NoticeHandler, if enabled: metrics or --watch want them. The
+// returned func restores the handler.
+func countTests(enabled bool) (*metrics.TestTally, func()) {
+	tally := &metrics.TestTally{}
+	if !enabled {
+		return tally, func() {}
+	}
+	origHandler := db.NoticeHandler
+	db.NoticeHandler = func(severity, message, detail, hint string) {
+		tally.Observe(message)
+		origHandler(severity, message, detail, hint)
+	}
+	return tally, func() { db.NoticeHandler = origHandler }
+}
+
+// reportMetrics writes and pushes the metrics of a deployment that ran. A
+// failure to deliver them is reported, but leaves the exit code to the deployment.
+//
+// The samples are labelled with database, the name -d gave, not with the
+// database the run deployed into: an --ephemeral run creates a newly named
+// one every time, and labelling by it would add a series to the textfile and
+// a group to the Pushgateway on every run.
+func reportMetrics(f metricsFlags, database string, result *services.DeployResult, tally *metrics.TestTally, deployErr error, logger pgmi.Logger) {
+	if !f.enabled() || result == nil {
+		return
+	}
+	run := metrics.Run{
+		Database:       database,
+		Duration:       result.Duration,
+		FilesLoaded:    result.FilesLoaded,
+		UnitsCommitted: result.UnitsCommitted,
+		ExitCode:       pgmi.ExitCodeForError(deployErr),
+		Finished:       time.Now(),
+	}
+	run.TestsRun, run.TestsFailed = tally.Counts(deployErr != nil)
+
+	if f.metricsFile != "" {
+		if err := metrics.WriteTextfile(f.metricsFile, run); err != nil {
+			logger.Error("Metrics were not written: %v", err)
+		}
+	}
+	if f.metricsPush != "" {
+		ctx, cancel := context.WithTimeout(context.Background(), metricsPushTimeout)
+		defer cancel()
+		if err := metrics.Push(ctx, http.DefaultClient, f.metricsPush, run); err != nil {
+			logger.Error("Metrics were not pushed: %v", err)
+		}
+	}
+}
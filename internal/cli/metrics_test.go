@@ -0,0 +1,103 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vvka-141/pgmi/internal/db"
+	"github.com/vvka-141/pgmi/internal/logging"
+	"github.com/vvka-141/pgmi/internal/metrics"
+	"github.com/vvka-141/pgmi/internal/services"
+	"github.com/vvka-141/pgmi/pkg/pgmi"
+)
+
+func TestReportMetrics(t *testing.T) {
+	orig := db.NoticeHandler
+	defer func() { db.NoticeHandler = orig }()
+	var printed []string
+	db.NoticeHandler = func(_, message, _, _ string) { printed = append(printed, message) }
+
+	path := filepath.Join(t.TempDir(), "pgmi.prom")
+	f := metricsFlags{metricsFile: path}
+
+	// Test events are counted on their way to the handler that prints them.
+	tally, stop := countTests(f.enabled())
+	db.NoticeHandler("NOTICE", "[pgmi] Test: ./__test__/test_a.sql", "", "")
+	stop()
+	db.NoticeHandler("NOTICE", "[pgmi] Test: ./__test__/after_stop.sql", "", "")
+	if len(printed) != 2 {
+		t.Errorf("handler printed %v, want both notices", printed)
+	}
+
+	result := &services.DeployResult{Database: "myapp", FilesLoaded: 4, UnitsCommitted: 1, Duration: time.Second}
+	deployErr := fmt.Errorf("%w: test_a failed", pgmi.ErrExecutionFailed)
+	reportMetrics(f, "myapp", result, tally, deployErr, logging.NewConsoleLogger(false))
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("metrics file: %v", err)
+	}
+	for _, line := range []string{
+		`pgmi_deploy_files_loaded{database="myapp"} 4`,
+		`pgmi_deploy_tests_run{database="myapp"} 1`,
+		`pgmi_deploy_tests_failed{database="myapp"} 1`,
+		`pgmi_deploy_exit_code{database="myapp"} 13`,
+	} {
+		if !strings.Contains(string(data), line+"\n") {
+			t.Errorf("metrics lack %q:\n%s", line, data)
+		}
+	}
+}
+
+// Every --ephemeral run deploys into a newly named database; its metrics
+// must still land in the one series of the name -d gave, or the textfile
+// grows by a set of samples per run.
+func TestReportMetrics_EphemeralRun(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pgmi.prom")
+	f := metricsFlags{metricsFile: path}
+
+	for i, name := range []string{"myapp_20261018120000_a1b2c3d4", "myapp_20261018130000_e5f6a7b8"} {
+		result := &services.DeployResult{Database: name, Ephemeral: true, EphemeralDropped: true, FilesLoaded: i + 1}
+		reportMetrics(f, "myapp", result, &metrics.TestTally{}, nil, logging.NewConsoleLogger(false))
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("metrics file: %v", err)
+	}
+	if strings.Contains(string(data), "myapp_2026") {
+		t.Errorf("metrics are labelled with the ephemeral database:\n%s", data)
+	}
+	if want := `pgmi_deploy_files_loaded{database="myapp"} 2` + "\n"; !strings.Contains(string(data), want) ||
+		strings.Count(string(data), "pgmi_deploy_files_loaded{") != 1 {
+		t.Errorf("want one files_loaded sample, %q:\n%s", want, data)
+	}
+}
+
+func TestReportMetrics_NotRequested(t *testing.T) {
+	orig := db.NoticeHandler
+	defer func() { db.NoticeHandler = orig }()
+
+	_, stop := countTests(false)
+	defer stop()
+	if db.NoticeHandler == nil {
+		t.Fatal("countTests cleared the notice handler")
+	}
+	// Nothing to write to; reportMetrics must not fail or create anything.
+	reportMetrics(metricsFlags{}, "myapp", &services.DeployResult{Database: "myapp"}, nil, nil, logging.NewConsoleLogger(false))
+}
+
+func TestMetricsFlagsValidate(t *testing.T) {
+	err := metricsFlags{metricsPush: "localhost:9091"}.validate()
+	if !errors.Is(err, pgmi.ErrUsage) {
+		t.Errorf("validate(no scheme) = %v, want ErrUsage", err)
+	}
+	if err := (metricsFlags{metricsPush: "http://localhost:9091"}).validate(); err != nil {
+		t.Errorf("validate(http URL) = %v", err)
+	}
+}
@@ -264,7 +264,7 @@ type noticeBuffer struct {
 	total int
 }
 
-func (b *noticeBuffer) add(message, detail, hint string) {
+func (b *noticeBuffer) add(severity, message, detail, hint string) {
 	b.mu.Lock()
 	b.total++
 	b.lines = append(b.lines, message)
@@ -272,7 +272,7 @@ func (b *noticeBuffer) add(message, detail, hint string) {
 		b.lines = b.lines[1:]
 	}
 	b.mu.Unlock()
-	db.DefaultNoticeHandler(message, detail, hint)
+	db.DefaultNoticeHandler(severity, message, detail, hint)
 }
 
 func (b *noticeBuffer) fields() map[string]any {
@@ -388,8 +388,8 @@ func runMCPDeploy(ctx context.Context, cfg pgmi.DeploymentConfig) (*services.Dep
 	fileScanner := scanner.NewScanner(checksum.New())
 	fileLoader := loader.NewLoader()
 	dbManager := manager.New()
-	sessionManager := services.NewSessionManager(db.NewConnector, fileScanner, fileLoader, logger)
-	deployer := services.NewDeploymentService(db.NewConnector, autoApprover{}, logger, sessionManager, fileScanner, dbManager)
+	sessionManager := services.NewSessionManager(connectorFactory(logger, nil), fileScanner, fileLoader, logger)
+	deployer := services.NewDeploymentService(connectorFactory(logger, nil), autoApprover{}, logger, sessionManager, fileScanner, dbManager)
 
 	ctx, cancel := deadlineContext(ctx, cfg.Timeout)
 	defer cancel()
@@ -507,22 +507,30 @@ func metadataPlanOutputSchema() map[string]any {
 	return withErrorVariant(objectSchema(map[string]any{
 		"total_files": intProp("Files scanned"),
 		"plan": arrayOf(objectSchema(map[string]any{
-			"path":        stringProp("Project-relative file path"),
-			"id":          stringProp("<pgmi-meta> id; empty when the file has no metadata"),
-			"idempotent":  boolProp("Whether the script is safe to re-run"),
-			"sort_keys":   arrayOf(stringProp("Sort key"), "Sort keys from <pgmi-meta>; empty means path order"),
-			"description": stringProp("<pgmi-meta> description"),
+			"path":             stringProp("Project-relative file path"),
+			"id":               stringProp("<pgmi-meta> id; empty when the file has no metadata"),
+			"idempotent":       boolProp("Whether the script is safe to re-run"),
+			"sort_keys":        arrayOf(stringProp("Sort key"), "Sort keys from <pgmi-meta>; empty means path order"),
+			"description":      stringProp("<pgmi-meta> description"),
+			"depends_on":       arrayOf(stringProp("Script path"), "Resolved paths of the scripts named in <dependsOn>"),
+			"dependency_order": intProp("1-based position once <dependsOn> is honoured"),
+			"custom": map[string]any{
+				"type":        "object",
+				"description": "Prefixed <pgmi-meta> attributes by prefix, then name: {\"ops\": {\"owner\": \"payments\"}}",
+			},
 		}, "path", "idempotent"), "Files in approximate deployment execution order"),
 	}, "total_files", "plan"))
 }
 
 func metadataValidateOutputSchema() map[string]any {
 	return withErrorVariant(objectSchema(map[string]any{
-		"total_files":            intProp("Files scanned"),
-		"files_with_metadata":    intProp("Files carrying a <pgmi-meta> block"),
-		"files_without_metadata": intProp("Files with no metadata (ordered by path)"),
-		"validation_passed":      boolProp("True when every block parses and ids are unique"),
-		"duplicate_ids":          arrayOf(stringProp("Duplicated <pgmi-meta> id"), "Ids claimed by more than one file"),
+		"total_files":             intProp("Files scanned"),
+		"files_with_metadata":     intProp("Files carrying a <pgmi-meta> block"),
+		"files_without_metadata":  intProp("Files with no metadata (ordered by path)"),
+		"validation_passed":       boolProp("True when every block parses, ids are unique, <dependsOn> resolves and custom attributes match pgmi.yaml"),
+		"duplicate_ids":           arrayOf(stringProp("Duplicated <pgmi-meta> id"), "Ids claimed by more than one file"),
+		"dependency_errors":       arrayOf(stringProp("Problem"), "<dependsOn> ids matching no script, and dependency cycles"),
+		"custom_attribute_errors": arrayOf(stringProp("Problem"), "Custom attributes the metadata schema in pgmi.yaml rejects"),
 	}, "total_files", "validation_passed"))
 }
 
@@ -8,7 +8,7 @@ import (
 func TestNoticeBuffer_TailAndTruncation(t *testing.T) {
 	b := &noticeBuffer{max: 3}
 	for i := 1; i <= 5; i++ {
-		b.add(fmt.Sprintf("line %d", i), "", "")
+		b.add("NOTICE", fmt.Sprintf("line %d", i), "", "")
 	}
 
 	f := b.fields()
@@ -23,7 +23,7 @@ func TestNoticeBuffer_TailAndTruncation(t *testing.T) {
 
 func TestNoticeBuffer_NoTruncationMarkerWhenWithinCap(t *testing.T) {
 	b := &noticeBuffer{max: 10}
-	b.add("only line", "", "")
+	b.add("NOTICE", "only line", "", "")
 
 	f := b.fields()
 	if _, present := f["noticesTruncated"]; present {
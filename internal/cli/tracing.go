@@ -0,0 +1,38 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/vvka-141/pgmi/internal/tracing"
+	"github.com/vvka-141/pgmi/pkg/pgmi"
+)
+
+// traceFlushTimeout bounds how long exiting waits on a collector that does
+// not answer.
+const traceFlushTimeout = 5 * time.Second
+
+// setupTracing starts exporting the deployment's spans when --otlp-endpoint
+// or the OTEL_EXPORTER_OTLP_* variables name a collector. The returned func
+// flushes them; a collector that cannot be reached is reported, but does not
+// fail a deployment that succeeded.
+func setupTracing(endpoint string, logger pgmi.Logger) (func(), error) {
+	v, _, _ := resolveVersionInfo()
+	shutdown, err := tracing.Setup(context.Background(), endpoint, v, func(err error) {
+		logger.Verbose("Trace export: %v", err)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: --otlp-endpoint: %w", pgmi.ErrUsage, err)
+	}
+	if tracing.Enabled(endpoint) {
+		logger.Verbose("Exporting traces over OTLP/HTTP")
+	}
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), traceFlushTimeout)
+		defer cancel()
+		if err := shutdown(ctx); err != nil {
+			logger.Error("Traces were not exported: %v", err)
+		}
+	}, nil
+}
@@ -0,0 +1,182 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/signal"
+	"path"
+	"slices"
+	"strings"
+	"syscall"
+
+	"github.com/vvka-141/pgmi/internal/config"
+	"github.com/vvka-141/pgmi/internal/db"
+	"github.com/vvka-141/pgmi/internal/files/watch"
+	"github.com/vvka-141/pgmi/internal/services"
+	"github.com/vvka-141/pgmi/internal/ui"
+	"github.com/vvka-141/pgmi/pkg/pgmi"
+)
+
+// checkWatchHosts refuses --watch against a server that is not on this
+// machine. Redeploying on every save, typically with --overwrite --force, is
+// for a dev database; a connection string left pointing at staging must not
+// get the same treatment. pgmi.yaml's watch.allowed_hosts opts other hosts in,
+// such as a database container reached by its service name.
+//
+// Through an SSH tunnel the connection string's hosts are resolved on the SSH
+// server, where localhost is that server, so the SSH host has to pass the same
+// test: a tunnel to a bastion makes every database behind it non-local.
+func checkWatchHosts(connString string, tunnel *db.SSHTunnelConfig, projectCfg *config.ProjectConfig) error {
+	connConfig, err := db.ParseConnectionString(connString)
+	if err != nil {
+		return err
+	}
+	var allowed []string
+	if projectCfg != nil && projectCfg.Watch != nil {
+		allowed = projectCfg.Watch.AllowedHosts
+	}
+	if tunnel != nil && !isLocalHost(tunnel.Host) && !hostAllowed(tunnel.Host, allowed) {
+		return fmt.Errorf("%w: --watch refuses a database reached through SSH host %q; list it under watch.allowed_hosts in pgmi.yaml",
+			pgmi.ErrInvalidConfig, tunnel.Host)
+	}
+	for _, h := range connConfig.Hosts() {
+		if isLocalHost(h.Host) || hostAllowed(h.Host, allowed) {
+			continue
+		}
+		return fmt.Errorf("%w: --watch refuses non-local host %q; list it under watch.allowed_hosts in pgmi.yaml",
+			pgmi.ErrInvalidConfig, h.Host)
+	}
+	return nil
+}
+
+// isLocalHost reports whether host is a Unix socket directory, localhost, or
+// a loopback address. An empty host means the default socket.
+func isLocalHost(host string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	switch {
+	case host == "", strings.HasPrefix(host, "/"), strings.HasPrefix(host, "@"):
+		return true
+	case host == "localhost", strings.HasSuffix(host, ".localhost"):
+		return true
+	}
+	ip := net.ParseIP(strings.Trim(host, "[]"))
+	return ip != nil && ip.IsLoopback()
+}
+
+// hostAllowed matches host against watch.allowed_hosts, ignoring case.
+// config.WatchConfig.Validate has already rejected malformed patterns.
+func hostAllowed(host string, patterns []string) bool {
+	host = strings.ToLower(host)
+	for _, p := range patterns {
+		if ok, _ := path.Match(strings.ToLower(p), host); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// watchDeploy deploys, then redeploys each time a file in the project
+// changes, until Ctrl-C. Every run goes through the same Deploy call as a
+// plain pgmi deploy, which rescans the project, so a run in watch mode
+// behaves exactly like one without. A failed run is reported and waited out
+// rather than returned; only an interrupt or a failure to watch ends the loop.
+func watchDeploy(deployer *services.DeploymentService, cfg pgmi.DeploymentConfig, logger pgmi.Logger, verbose bool) error {
+	base, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Files this command writes after every run must not start the next one.
+	w, err := watch.New(cfg.SourcePath, watch.DefaultInterval, watch.DefaultQuiet,
+		deployFlags.logFile, deployFlags.metricsFile)
+	if err != nil {
+		return err
+	}
+
+	for {
+		ctx, cancel := deadlineContext(base, cfg.Timeout)
+		tally, stopCounting := countTests(true)
+		deployErr := deployer.Deploy(ctx, cfg)
+		stopCounting()
+		cancel()
+		if base.Err() != nil {
+			fmt.Fprintln(os.Stderr, "pgmi: interrupted, stopped watching")
+			return context.Canceled
+		}
+
+		result := deployer.LastResult()
+		testsRun, _ := tally.Counts(deployErr != nil)
+		printWatchResult(os.Stderr, result, deployErr, testsRun, tally.FailingTest(deployErr != nil))
+		if deployErr != nil && verbose {
+			fmt.Fprintln(os.Stderr, pgmi.FormatError(deployErr))
+		}
+		logDeployOutcome(logger, deployErr)
+		reportMetrics(deployFlags.metricsFlags, cfg.DatabaseName, result, tally, deployErr, logger)
+
+		fmt.Fprintf(os.Stderr, "Watching %s for changes (Ctrl-C to stop)\n", cfg.SourcePath)
+		changed, err := w.Wait(base)
+		if errors.Is(err, context.Canceled) {
+			return context.Canceled
+		}
+		if err != nil {
+			return err
+		}
+		printWatchChanges(os.Stderr, changed)
+	}
+}
+
+// printWatchResult prints one line per run: what was deployed on success,
+// and where it failed otherwise — the test that was running, else the file
+// or script line PostgreSQL's error points at.
+func printWatchResult(out io.Writer, result *services.DeployResult, deployErr error, testsRun int, failingTest string) {
+	target, elapsed := "?", ""
+	if result != nil {
+		if result.Database != "" {
+			target = result.Database
+		}
+		elapsed = fmt.Sprintf("%.2fs", result.Duration.Seconds())
+	}
+
+	d := pgmi.NewErrorDetail(deployErr)
+	if d == nil {
+		files := 0
+		if result != nil {
+			files = result.FilesLoaded
+		}
+		fmt.Fprintf(out, "%s %s: %d files, %d tests in %s\n", ui.SuccessIcon(), target, files, testsRun, elapsed)
+		return
+	}
+
+	msg, _, _ := strings.Cut(d.Message, "\n")
+	location := failingTest
+	switch {
+	case location != "":
+	case d.FailedFile != "":
+		location = d.FailedFile
+	case d.Script != "" && d.Line > 0:
+		location = fmt.Sprintf("%s:%d", d.Script, d.Line)
+	}
+	if location != "" {
+		msg = location + ": " + msg
+	}
+	if elapsed != "" {
+		msg = fmt.Sprintf("failed after %s: %s", elapsed, msg)
+	}
+	fmt.Fprintf(out, "%s %s: %s\n", ui.FailIcon(), target, msg)
+}
+
+// printWatchChanges says what triggered a redeploy. pgmi.yaml is read once,
+// so a change to it takes a restart to apply.
+func printWatchChanges(out io.Writer, changed []string) {
+	const shown = 3
+	list := strings.Join(changed[:min(len(changed), shown)], ", ")
+	if len(changed) > shown {
+		list += fmt.Sprintf(" and %d more", len(changed)-shown)
+	}
+	fmt.Fprintf(out, "Changed: %s; redeploying\n", list)
+	if slices.Contains(changed, "./pgmi.yaml") {
+		fmt.Fprintln(out, "pgmi.yaml changed; restart pgmi to apply it")
+	}
+}
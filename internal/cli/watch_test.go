@@ -0,0 +1,109 @@
+package cli
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vvka-141/pgmi/internal/config"
+	"github.com/vvka-141/pgmi/internal/db"
+	"github.com/vvka-141/pgmi/internal/services"
+	"github.com/vvka-141/pgmi/pkg/pgmi"
+)
+
+func TestIsLocalHost(t *testing.T) {
+	for host, want := range map[string]bool{
+		"":                    true,
+		"/var/run/postgresql": true,
+		"@pgsock":             true,
+		"localhost":           true,
+		"LOCALHOST.":          true,
+		"db.localhost":        true,
+		"127.0.0.1":           true,
+		"127.0.0.53":          true,
+		"::1":                 true,
+		"[::1]":               true,
+		"10.0.0.5":            false,
+		"db":                  false,
+		"localhost.example":   false,
+		"prod.example.com":    false,
+	} {
+		if got := isLocalHost(host); got != want {
+			t.Errorf("isLocalHost(%q) = %v, want %v", host, got, want)
+		}
+	}
+}
+
+func TestCheckWatchHosts(t *testing.T) {
+	allowing := &config.ProjectConfig{Watch: &config.WatchConfig{AllowedHosts: []string{"db", "*.dev.internal"}}}
+	bastion := &db.SSHTunnelConfig{Host: "bastion.example.com"}
+	devBox := &db.SSHTunnelConfig{Host: "vm.dev.internal"}
+
+	for _, tc := range []struct {
+		name    string
+		conn    string
+		tunnel  *db.SSHTunnelConfig
+		cfg     *config.ProjectConfig
+		refused string
+	}{
+		{name: "localhost", conn: "postgresql://postgres@localhost:5432/app"},
+		{name: "socket", conn: "host=/tmp dbname=app"},
+		{name: "remote", conn: "postgresql://postgres@prod.example.com/app", refused: "prod.example.com"},
+		{name: "allowed", conn: "postgresql://postgres@DB:5432/app", cfg: allowing},
+		{name: "allowed glob", conn: "postgresql://postgres@pg.dev.internal/app", cfg: allowing},
+		{name: "one fallback remote", conn: "postgresql://postgres@localhost,prod.example.com/app", cfg: allowing, refused: "prod.example.com"},
+		// localhost behind a tunnel is the bastion, not this machine.
+		{name: "localhost through ssh", conn: "postgresql://postgres@localhost:5432/app", tunnel: bastion, cfg: allowing, refused: "bastion.example.com"},
+		{name: "localhost through allowed ssh", conn: "postgresql://postgres@localhost:5432/app", tunnel: devBox, cfg: allowing},
+		{name: "remote through allowed ssh", conn: "postgresql://postgres@prod.example.com/app", tunnel: devBox, cfg: allowing, refused: "prod.example.com"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := checkWatchHosts(tc.conn, tc.tunnel, tc.cfg)
+			if tc.refused == "" {
+				if err != nil {
+					t.Errorf("checkWatchHosts = %v, want nil", err)
+				}
+				return
+			}
+			if !errors.Is(err, pgmi.ErrInvalidConfig) || !strings.Contains(err.Error(), tc.refused) {
+				t.Errorf("checkWatchHosts = %v, want ErrInvalidConfig naming %s", err, tc.refused)
+			}
+		})
+	}
+}
+
+func TestPrintWatchResult(t *testing.T) {
+	result := &services.DeployResult{Database: "app_dev", FilesLoaded: 12, Duration: 1500 * time.Millisecond}
+
+	var buf bytes.Buffer
+	printWatchResult(&buf, result, nil, 3, "")
+	if want := "app_dev: 12 files, 3 tests in 1.50s\n"; !strings.HasSuffix(buf.String(), want) {
+		t.Errorf("success line = %q, want suffix %q", buf.String(), want)
+	}
+
+	buf.Reset()
+	deployErr := fmt.Errorf("%w: expected 2 rows\nCONTEXT: PL/pgSQL", pgmi.ErrExecutionFailed)
+	printWatchResult(&buf, result, deployErr, 3, "./__test__/test_orders.sql")
+	want := "app_dev: failed after 1.50s: ./__test__/test_orders.sql: " + pgmi.ErrExecutionFailed.Error() + ": expected 2 rows\n"
+	if !strings.HasSuffix(buf.String(), want) {
+		t.Errorf("failure line = %q, want suffix %q", buf.String(), want)
+	}
+	if strings.Count(buf.String(), "\n") != 1 {
+		t.Errorf("failure printed %q, want one line", buf.String())
+	}
+}
+
+func TestPrintWatchChanges(t *testing.T) {
+	var buf bytes.Buffer
+	printWatchChanges(&buf, []string{"./a.sql", "./b.sql", "./c.sql", "./d.sql", "./pgmi.yaml"})
+	out := buf.String()
+	if !strings.Contains(out, "./a.sql, ./b.sql, ./c.sql and 2 more") {
+		t.Errorf("output = %q, want the first three and a count", out)
+	}
+	if !strings.Contains(out, "restart pgmi") {
+		t.Errorf("output = %q, want a note that pgmi.yaml needs a restart", out)
+	}
+}
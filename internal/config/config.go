@@ -5,9 +5,13 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"net/url"
 	"os"
+	"path"
 	"path/filepath"
+	"sort"
 
+	"github.com/vvka-141/pgmi/pkg/pgmi"
 	"gopkg.in/yaml.v3"
 )
 
@@ -16,12 +20,19 @@ import (
 var ErrConfigNotFound = errors.New("config file not found")
 
 type ConnectionConfig struct {
+	// Service names a pg_service.conf entry. Its settings outrank the keys
+	// below and PG* environment variables; flags outrank it.
+	Service string `yaml:"service,omitempty"`
+
+	// Host may list several servers, comma-separated, to try in order;
+	// they share Port. TargetSessionAttrs picks which one to settle on.
 	Host                string `yaml:"host"`
 	Port                int    `yaml:"port"`
 	Username            string `yaml:"username"`
 	Database            string `yaml:"database"`
 	MaintenanceDatabase string `yaml:"maintenance_database,omitempty"`
 	SSLMode             string `yaml:"sslmode"`
+	TargetSessionAttrs  string `yaml:"target_session_attrs,omitempty"`
 	SSLCert             string `yaml:"sslcert,omitempty"`
 	SSLKey              string `yaml:"sslkey,omitempty"`
 	SSLRootCert         string `yaml:"sslrootcert,omitempty"`
@@ -30,12 +41,149 @@ type ConnectionConfig struct {
 	AzureClientID       string `yaml:"azure_client_id,omitempty"`
 	AWSRegion           string `yaml:"aws_region,omitempty"`
 	GoogleInstance      string `yaml:"google_instance,omitempty"`
+
+	// TokenCommand and TokenFile configure auth_method exec and token_file.
+	TokenCommand []string `yaml:"token_command,omitempty"`
+	TokenFile    string   `yaml:"token_file,omitempty"`
+
+	// KerberosServiceName and KerberosSPN configure auth_method gssapi.
+	KerberosServiceName string `yaml:"krbsrvname,omitempty"`
+	KerberosSPN         string `yaml:"krbspn,omitempty"`
+
+	// SSH tunnels every connection through an SSH server.
+	SSH *SSHConfig `yaml:"ssh,omitempty"`
+}
+
+// SSHConfig is the connection.ssh section of pgmi.yaml.
+type SSHConfig struct {
+	Host       string `yaml:"host"`
+	Port       int    `yaml:"port,omitempty"`
+	User       string `yaml:"user,omitempty"`
+	KeyFile    string `yaml:"key_file,omitempty"`
+	KnownHosts string `yaml:"known_hosts,omitempty"`
 }
 
 type ProjectConfig struct {
 	Connection ConnectionConfig  `yaml:"connection"`
 	Params     map[string]string `yaml:"params"`
 	Timeout    string            `yaml:"timeout"`
+
+	// Compat pins the session API version, as --compat does; the flag wins.
+	Compat string `yaml:"compat,omitempty"`
+
+	// Entrypoints names further orchestrator scripts besides deploy.sql,
+	// name -> path relative to the project, for `pgmi deploy --entry <name>`.
+	Entrypoints map[string]string `yaml:"entrypoints,omitempty"`
+
+	// Metadata declares the project's custom <pgmi-meta> attributes.
+	Metadata MetadataConfig `yaml:"metadata,omitempty"`
+
+	// OverwritePolicy gates `pgmi deploy --overwrite` before any database
+	// is dropped.
+	OverwritePolicy *OverwritePolicy `yaml:"overwrite_policy,omitempty"`
+
+	// Watch configures `pgmi deploy --watch`.
+	Watch *WatchConfig `yaml:"watch,omitempty"`
+}
+
+// WatchConfig is the watch section of pgmi.yaml.
+type WatchConfig struct {
+	// AllowedHosts are glob patterns of the non-local server hosts --watch
+	// may redeploy to. A server on this machine is always allowed; any other
+	// is refused unless it matches. Matched case-insensitively.
+	AllowedHosts []string `yaml:"allowed_hosts,omitempty"`
+}
+
+// Validate reports a pattern that cannot be matched.
+func (w *WatchConfig) Validate() error {
+	for _, pattern := range w.AllowedHosts {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("watch: bad pattern %q: %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// OverwritePolicy is the overwrite_policy section of pgmi.yaml: the rules a
+// database must pass before --overwrite may drop it. Every rule that is set
+// must pass. With a policy, a CI job can overwrite without --force, which
+// approves any database; with --force or at a terminal, the policy is checked
+// first and the usual confirmation follows.
+type OverwritePolicy struct {
+	// Databases are glob patterns (path.Match syntax) of the database names
+	// that may be overwritten. Empty allows any name.
+	Databases []string `yaml:"databases,omitempty"`
+	// ForbiddenHosts are glob patterns of server hosts whose databases are
+	// never overwritten, such as "*prod*". Matched case-insensitively.
+	ForbiddenHosts []string `yaml:"forbidden_hosts,omitempty"`
+	// ConfirmEnv names an environment variable that must hold the name of
+	// the database being overwritten.
+	ConfirmEnv string `yaml:"confirm_env,omitempty"`
+	// Command is run with PGMI_OVERWRITE_DATABASE and PGMI_OVERWRITE_HOST
+	// set, and approves by exiting 0.
+	Command []string `yaml:"command,omitempty"`
+	// Webhook is POSTed {"database", "host"} as JSON, and approves by
+	// answering 2xx.
+	Webhook string `yaml:"webhook,omitempty"`
+}
+
+// Validate reports a policy whose patterns or hooks cannot be used.
+func (p *OverwritePolicy) Validate() error {
+	for _, pattern := range append(append([]string(nil), p.Databases...), p.ForbiddenHosts...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("overwrite_policy: bad pattern %q: %w", pattern, err)
+		}
+	}
+	if len(p.Command) > 0 && p.Command[0] == "" {
+		return errors.New("overwrite_policy: command names no program")
+	}
+	if p.Webhook != "" {
+		u, err := url.Parse(p.Webhook)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("overwrite_policy: webhook %q is not an http(s) URL", p.Webhook)
+		}
+	}
+	return nil
+}
+
+// MetadataConfig is the metadata section of pgmi.yaml.
+type MetadataConfig struct {
+	// Attributes maps a prefixed name such as ops:owner to its declaration.
+	Attributes map[string]AttributeConfig `yaml:"attributes,omitempty"`
+}
+
+// AttributeConfig declares one custom attribute; see pgmi.CustomAttributeSpec.
+type AttributeConfig struct {
+	Type     string   `yaml:"type,omitempty"`
+	Required bool     `yaml:"required,omitempty"`
+	Values   []string `yaml:"values,omitempty"`
+}
+
+// MetadataSchema returns the declared custom attributes, nil when there are
+// none. A nil config declares none.
+func (c *ProjectConfig) MetadataSchema() pgmi.MetadataSchema {
+	if c == nil || len(c.Metadata.Attributes) == 0 {
+		return nil
+	}
+	schema := make(pgmi.MetadataSchema, len(c.Metadata.Attributes))
+	for name, a := range c.Metadata.Attributes {
+		schema[name] = pgmi.CustomAttributeSpec{Type: a.Type, Required: a.Required, Values: a.Values}
+	}
+	return schema
+}
+
+// EntrypointPaths returns the declared entrypoint paths, sorted. A nil config
+// declares none.
+func (c *ProjectConfig) EntrypointPaths() []string {
+	if c == nil || len(c.Entrypoints) == 0 {
+		return nil
+	}
+	paths := make([]string, 0, len(c.Entrypoints))
+	for _, p := range c.Entrypoints {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	return paths
 }
 
 const ConfigFileName = "pgmi.yaml"
@@ -61,5 +209,15 @@ func Load(sourcePath string) (*ProjectConfig, error) {
 		}
 		return nil, fmt.Errorf("parse %s: %w", configPath, err)
 	}
+	if cfg.OverwritePolicy != nil {
+		if err := cfg.OverwritePolicy.Validate(); err != nil {
+			return nil, fmt.Errorf("%s: %w", configPath, err)
+		}
+	}
+	if cfg.Watch != nil {
+		if err := cfg.Watch.Validate(); err != nil {
+			return nil, fmt.Errorf("%s: %w", configPath, err)
+		}
+	}
 	return &cfg, nil
 }
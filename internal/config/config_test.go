@@ -8,6 +8,8 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+
+	"github.com/vvka-141/pgmi/pkg/pgmi"
 )
 
 func TestLoad_AllFields(t *testing.T) {
@@ -102,3 +104,101 @@ func TestLoad_EmptyFile(t *testing.T) {
 	require.NotNil(t, cfg)
 	assert.Equal(t, ProjectConfig{}, *cfg)
 }
+
+func TestLoad_Entrypoints(t *testing.T) {
+	dir := t.TempDir()
+	content := `entrypoints:
+  reindex: ops/reindex.sql
+  backfill: ops/backfill.sql
+`
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ConfigFileName), []byte(content), 0644))
+
+	cfg, err := Load(dir)
+	require.NoError(t, err)
+	assert.Equal(t, "ops/reindex.sql", cfg.Entrypoints["reindex"])
+	assert.Equal(t, []string{"ops/backfill.sql", "ops/reindex.sql"}, cfg.EntrypointPaths())
+
+	var none *ProjectConfig
+	assert.Nil(t, none.EntrypointPaths())
+}
+
+func TestLoad_MetadataAttributes(t *testing.T) {
+	dir := t.TempDir()
+	content := `metadata:
+  attributes:
+    ops:owner:
+      required: true
+    ops:window:
+      type: boolean
+    ops:env:
+      values: [all, prod]
+`
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ConfigFileName), []byte(content), 0644))
+
+	cfg, err := Load(dir)
+	require.NoError(t, err)
+	schema := cfg.MetadataSchema()
+	assert.Equal(t, pgmi.CustomAttributeSpec{Required: true}, schema["ops:owner"])
+	assert.Equal(t, "boolean", schema["ops:window"].Type)
+	assert.Equal(t, []string{"all", "prod"}, schema["ops:env"].Values)
+	require.NoError(t, schema.Validate())
+
+	var none *ProjectConfig
+	assert.Nil(t, none.MetadataSchema())
+}
+
+func TestLoad_OverwritePolicy(t *testing.T) {
+	dir := t.TempDir()
+	content := `overwrite_policy:
+  databases: ["*_test", "preview_*"]
+  forbidden_hosts: ["*prod*"]
+  confirm_env: PGMI_CONFIRM_OVERWRITE
+  command: ["./scripts/approve-overwrite.sh"]
+  webhook: https://approvals.example.com/pgmi
+`
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ConfigFileName), []byte(content), 0644))
+
+	cfg, err := Load(dir)
+	require.NoError(t, err)
+	require.NotNil(t, cfg.OverwritePolicy)
+	assert.Equal(t, []string{"*_test", "preview_*"}, cfg.OverwritePolicy.Databases)
+	assert.Equal(t, []string{"*prod*"}, cfg.OverwritePolicy.ForbiddenHosts)
+	assert.Equal(t, "PGMI_CONFIRM_OVERWRITE", cfg.OverwritePolicy.ConfirmEnv)
+	assert.Equal(t, []string{"./scripts/approve-overwrite.sh"}, cfg.OverwritePolicy.Command)
+	assert.Equal(t, "https://approvals.example.com/pgmi", cfg.OverwritePolicy.Webhook)
+}
+
+func TestLoad_Watch(t *testing.T) {
+	dir := t.TempDir()
+	content := "watch:\n  allowed_hosts: [\"dev-db.internal\", \"*.dev.example.com\"]\n"
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ConfigFileName), []byte(content), 0644))
+
+	cfg, err := Load(dir)
+	require.NoError(t, err)
+	require.NotNil(t, cfg.Watch)
+	assert.Equal(t, []string{"dev-db.internal", "*.dev.example.com"}, cfg.Watch.AllowedHosts)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ConfigFileName), []byte("watch:\n  allowed_hosts: [\"[unclosed\"]\n"), 0644))
+	_, err = Load(dir)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "watch")
+}
+
+func TestLoad_OverwritePolicyRejectsUnusableRules(t *testing.T) {
+	for name, policy := range map[string]string{
+		"bad pattern":   `databases: ["[unclosed"]`,
+		"empty command": `command: [""]`,
+		"non-http hook": `webhook: ftp://approvals.example.com`,
+		"relative hook": `webhook: /approve`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			content := "overwrite_policy:\n  " + policy + "\n"
+			require.NoError(t, os.WriteFile(filepath.Join(dir, ConfigFileName), []byte(content), 0644))
+
+			_, err := Load(dir)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), "overwrite_policy")
+		})
+	}
+}
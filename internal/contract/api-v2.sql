@@ -0,0 +1,252 @@
+-- ============================================================================
+-- PGMI Session API v2
+-- ============================================================================
+-- Applied after api-v1.sql, never instead of it: v2 is v1 plus the objects
+-- below. Nothing here redefines a v1 object, so a deploy.sql written against
+-- v1 sees the same views, columns and functions under --compat 2 as under
+-- --compat 1. contract_test.go enforces both halves of that promise.
+--
+-- PUBLIC VIEWS:
+--   pgmi_plan_detail_view     - pgmi_plan_view plus file, phase, dependency and custom columns
+--
+-- PUBLIC FUNCTIONS:
+--   pgmi_api_version()        - The session API version this session was given
+--   pgmi_execute()            - Execute one source file with timing and error context
+--   pgmi_execute_plan()       - Execute plan entries in order through pgmi_execute()
+--   pgmi_capture_fingerprint() - Record the catalog for `pgmi drift` to compare against
+--
+-- pgmi_catalog_fingerprint(), which pgmi_capture_fingerprint() records, is in
+-- fingerprint.sql: `pgmi drift` runs it outside any session.
+-- ============================================================================
+
+-- §pgmi_api_version ──────────────────────────────────────────────────────────
+-- v1 sessions have no such function; a deploy.sql that must run under both
+-- tells them apart with to_regprocedure('pg_temp.pgmi_api_version()').
+CREATE OR REPLACE FUNCTION pg_temp.pgmi_api_version()
+RETURNS TEXT
+LANGUAGE sql IMMUTABLE AS $$ SELECT '2'::text $$;
+
+COMMENT ON FUNCTION pg_temp.pgmi_api_version() IS
+    'Session API version applied to this session (--compat). Absent under v1.';
+
+
+-- §pgmi_plan_detail_view ─────────────────────────────────────────────────────
+-- Built on pgmi_plan_view rather than beside it, so order and identity cannot
+-- drift between the two: every row of one is exactly one row of the other.
+-- phase numbers a file's occurrences in execution order; a file with three
+-- sort keys appears with phase 1, 2 and 3 and phase_count 3.
+-- dependency_order: the same entries reordered so each file runs after the
+-- first entry of every file it <dependsOn>; see metadata.DependencyOrder,
+-- which `pgmi metadata plan` uses and this must agree with. It and custom
+-- live here and not in pgmi_plan_view, whose columns v1 froze.
+CREATE OR REPLACE TEMP VIEW pgmi_plan_detail_view AS
+WITH RECURSIVE
+-- Every file each file transitively waits for, at every chain length. UNION
+-- keeps the rows distinct, so this is bounded even for a dense graph; the
+-- length cap only matters for a cycle, which ScanProject rejects first.
+ancestor(path, dep_path, distance) AS (
+    SELECT x.path, d.dep_path, 1
+    FROM pg_temp._pgmi_source_metadata_ext x
+    CROSS JOIN LATERAL UNNEST(x.depends_on) AS d(dep_path)
+    UNION
+    SELECT a.path, d.dep_path, a.distance + 1
+    FROM ancestor a
+    JOIN pg_temp._pgmi_source_metadata_ext x ON x.path = a.dep_path
+    CROSS JOIN LATERAL UNNEST(x.depends_on) AS d(dep_path)
+    WHERE a.distance < (SELECT count(*) FROM pg_temp._pgmi_source_metadata_ext)
+),
+-- wait_for: the latest first entry among a file's ancestors; depth: its
+-- longest dependency chain, which orders files waiting for the same entry.
+placement AS (
+    SELECT a.path, MAX(f.first_order) AS wait_for, MAX(a.distance) AS depth
+    FROM ancestor a
+    JOIN (
+        SELECT path, MIN(execution_order) AS first_order
+        FROM pg_temp.pgmi_plan_view GROUP BY path
+    ) f ON f.path = a.dep_path
+    GROUP BY a.path
+)
+SELECT
+    p.path,
+    p.content,
+    p.checksum,
+    p.generic_id,
+    p.id,
+    p.idempotent,
+    p.description,
+    p.sort_key,
+    p.execution_order,
+    s.name,
+    s.directory,
+    s.is_sql_file,
+    s.size_bytes,
+    ROW_NUMBER() OVER (PARTITION BY p.path ORDER BY p.execution_order) AS phase,
+    COUNT(*) OVER (PARTITION BY p.path) AS phase_count,
+    -- Resolved paths of the files this one <dependsOn>; '{}' for none
+    COALESCE(x.depends_on, '{}') AS depends_on,
+    -- Equal to execution_order unless some file declares <dependsOn>
+    ROW_NUMBER() OVER (
+        ORDER BY GREATEST(p.execution_order, pl.wait_for), COALESCE(pl.depth, 0), p.execution_order
+    ) AS dependency_order,
+    -- Custom <pgmi-meta> attributes; '{}' for files without any
+    COALESCE(x.custom, '{}') AS custom
+FROM pg_temp.pgmi_plan_view p
+JOIN pg_temp._pgmi_source s ON s.path = p.path
+LEFT JOIN pg_temp._pgmi_source_metadata_ext x ON x.path = p.path
+LEFT JOIN placement pl ON pl.path = p.path;
+
+COMMENT ON VIEW pg_temp.pgmi_plan_detail_view IS
+    'pgmi_plan_view with file columns (name, directory, is_sql_file, size_bytes),
+     phase/phase_count for files with several sort keys, depends_on, and custom,
+     the prefixed <pgmi-meta> attributes as JSONB.
+     dependency_order is the same plan honouring <dependsOn>: a file moves to just
+     after the first entry of the latest file it waits for; otherwise it equals
+     execution_order. Not ordered by itself: ORDER BY execution_order or
+     dependency_order.';
+
+GRANT SELECT ON pg_temp.pgmi_plan_detail_view TO PUBLIC;
+
+
+-- §pgmi_execute ──────────────────────────────────────────────────────────────
+-- The body of every hand-written plan loop, instrumented: one NOTICE per file
+-- in key=value form, and errors wrapped as 'Failed in <path>: ...' keeping
+-- the original SQLSTATE and DETAIL, exactly as pgmi_run_test_source does for
+-- tests. Which files run, and when, stays with deploy.sql.
+--
+-- p_savepoint runs the file in its own subtransaction and turns a failure into
+-- a WARNING and a false result, with the file's changes rolled back and the
+-- caller's transaction still usable. Without it a failure raises.
+--
+-- The EXCEPTION block makes every call a subtransaction either way; that is
+-- the price of the error context, and the same one pgmi_run_test_source pays.
+CREATE OR REPLACE FUNCTION pg_temp.pgmi_execute(
+    p_path TEXT,
+    p_sort_key TEXT DEFAULT NULL,
+    p_savepoint BOOLEAN DEFAULT false
+) RETURNS BOOLEAN
+LANGUAGE plpgsql AS $$
+DECLARE
+    v_content TEXT;
+    v_started TIMESTAMPTZ;
+    v_detail TEXT;
+BEGIN
+    SELECT content INTO v_content FROM pg_temp._pgmi_source WHERE path = p_path;
+    IF NOT FOUND THEN
+        RAISE EXCEPTION 'pgmi_execute: no source file %', p_path
+            USING ERRCODE = 'undefined_file',
+                  HINT = 'Paths are as in pg_temp.pgmi_source_view, e.g. ./migrations/001.sql';
+    END IF;
+
+    v_started := clock_timestamp();
+    BEGIN
+        EXECUTE v_content;
+    EXCEPTION WHEN OTHERS THEN
+        GET STACKED DIAGNOSTICS v_detail = PG_EXCEPTION_DETAIL;
+        IF p_savepoint THEN
+            RAISE WARNING 'Failed in %: %', p_path, SQLERRM
+                USING DETAIL = COALESCE(v_detail, '');
+            RETURN false;
+        END IF;
+        RAISE EXCEPTION 'Failed in %: %', p_path, SQLERRM
+            USING ERRCODE = SQLSTATE, DETAIL = COALESCE(v_detail, '');
+    END;
+
+    RAISE NOTICE 'pgmi_execute path=% sort_key=% duration_ms=%',
+        p_path, COALESCE(p_sort_key, '-'),
+        round((extract(epoch FROM clock_timestamp() - v_started) * 1000)::numeric, 3);
+    RETURN true;
+END;
+$$;
+
+COMMENT ON FUNCTION pg_temp.pgmi_execute IS
+'Executes one source file and reports it.
+Parameters:
+  p_path      - Path as in pgmi_source_view (./dir/file.sql)
+  p_sort_key  - Plan sort key, for the NOTICE only (NULL prints -)
+  p_savepoint - Roll back just this file on failure and return false
+Returns: true on success. Emits NOTICE pgmi_execute path=... sort_key=... duration_ms=...
+Errors:  raised as ''Failed in <path>: <message>'' with the original SQLSTATE and DETAIL.';
+
+
+-- §pgmi_execute_plan ─────────────────────────────────────────────────────────
+-- The plan in dependency_order (execution_order unless a file declares
+-- <dependsOn>), SQL files only, optionally filtered by a POSIX regex on path.
+-- Runs every matching entry; in savepoint mode a failed
+-- entry is rolled back and skipped and the rest still run, so the result —
+-- entries that succeeded — is how deploy.sql tells a clean run from one that
+-- needs attention.
+CREATE OR REPLACE FUNCTION pg_temp.pgmi_execute_plan(
+    p_filter TEXT DEFAULT NULL,
+    p_savepoint BOOLEAN DEFAULT false
+) RETURNS INTEGER
+LANGUAGE plpgsql AS $$
+DECLARE
+    v_entry RECORD;
+    v_done INTEGER := 0;
+BEGIN
+    FOR v_entry IN
+        SELECT path, sort_key
+        FROM pg_temp.pgmi_plan_detail_view
+        WHERE pg_temp.pgmi_is_sql_file(path)
+          AND (p_filter IS NULL OR path ~ p_filter)
+        ORDER BY dependency_order
+    LOOP
+        IF pg_temp.pgmi_execute(v_entry.path, v_entry.sort_key, p_savepoint) THEN
+            v_done := v_done + 1;
+        END IF;
+    END LOOP;
+    RETURN v_done;
+END;
+$$;
+
+COMMENT ON FUNCTION pg_temp.pgmi_execute_plan IS
+'Executes pgmi_plan_detail_view entries in dependency_order through pgmi_execute().
+Parameters:
+  p_filter    - POSIX regex on path (NULL = every SQL file in the plan)
+  p_savepoint - Passed to pgmi_execute: a failed entry is rolled back and skipped
+Returns: number of entries that succeeded.';
+
+
+-- §pgmi_capture_fingerprint ─────────────────────────────────────────────────
+-- Called last in deploy.sql, it replaces the recorded fingerprint with the
+-- catalog as this deploy leaves it. It runs in the deploy's transaction, so a
+-- deploy that fails and rolls back keeps the fingerprint of the last one that
+-- succeeded. pgmi.catalog_fingerprint is a real table: `pgmi drift` reads it
+-- from another connection, long after this session has ended.
+CREATE OR REPLACE FUNCTION pg_temp.pgmi_capture_fingerprint()
+RETURNS INTEGER
+LANGUAGE plpgsql AS $$
+DECLARE
+    v_count INTEGER;
+BEGIN
+    -- Checked rather than IF NOT EXISTS, which would print a NOTICE into
+    -- every deploy's output after the first.
+    IF to_regclass('pgmi.catalog_fingerprint') IS NULL THEN
+        CREATE SCHEMA IF NOT EXISTS pgmi;
+        CREATE TABLE pgmi.catalog_fingerprint (
+            object_type TEXT NOT NULL,
+            object_name TEXT NOT NULL,
+            definition  TEXT NOT NULL,
+            captured_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+            PRIMARY KEY (object_type, object_name)
+        );
+        COMMENT ON TABLE pgmi.catalog_fingerprint IS
+            'Catalog as the last deploy calling pg_temp.pgmi_capture_fingerprint() left it. Compared with the live catalog by pgmi drift.';
+    END IF;
+
+    DELETE FROM pgmi.catalog_fingerprint;
+    INSERT INTO pgmi.catalog_fingerprint (object_type, object_name, definition)
+    SELECT f.object_type, f.object_name, f.definition
+    FROM pg_temp.pgmi_catalog_fingerprint() f;
+    GET DIAGNOSTICS v_count = ROW_COUNT;
+
+    RAISE NOTICE 'pgmi_capture_fingerprint objects=%', v_count;
+    RETURN v_count;
+END;
+$$;
+
+COMMENT ON FUNCTION pg_temp.pgmi_capture_fingerprint IS
+'Replaces pgmi.catalog_fingerprint with pgmi_catalog_fingerprint() of the
+database as it is now. Call it at the end of deploy.sql; pgmi drift reports
+every change made after it.
+Returns: number of objects recorded.';
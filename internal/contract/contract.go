@@ -4,25 +4,58 @@ import (
 	"context"
 	_ "embed"
 	"fmt"
+	"regexp"
+	"strings"
 
-	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jackc/pgx/v5"
 	"github.com/vvka-141/pgmi/pkg/pgmi"
 )
 
 //go:embed api-v1.sql
 var apiV1SQL string
 
+//go:embed api-v2.sql
+var apiV2SQL string
+
+//go:embed fingerprint.sql
+var fingerprintSQL string
+
 // Version represents an API version identifier.
 type Version string
 
+// Before reports whether v is an older version than o. Versions are small
+// integers, so comparing by length then text orders them numerically.
+func (v Version) Before(o Version) bool {
+	if len(v) != len(o) {
+		return len(v) < len(o)
+	}
+	return v < o
+}
+
 const (
 	V1     Version = "1"
-	Latest Version = V1
+	V2     Version = "2"
+	Latest Version = V2
 )
 
+// introduced lists the public objects each version added over its
+// predecessor, and the columns it added that no earlier view has: a script
+// selecting dependency_order needs v2 as surely as one naming
+// pgmi_plan_detail_view. It drives RequiredVersion, and contract_test.go
+// checks it against what each api-vN.sql actually creates.
+var introduced = map[Version][]string{
+	V2: {"pgmi_plan_detail_view", "pgmi_api_version", "pgmi_execute", "pgmi_execute_plan",
+		"pgmi_capture_fingerprint", "pgmi_catalog_fingerprint",
+		"dependency_order", "depends_on", "custom"},
+}
+
 // Load returns the SQL content for the specified API version.
 // If version is empty, the latest version is used.
 // Returns the SQL content, the resolved version, and any error.
+//
+// Each version is applied on top of the previous one rather than replacing
+// it: a newer version only adds objects, which is what lets a v1 deploy.sql
+// run unchanged under any later --compat.
 func Load(version string) (string, Version, error) {
 	v := Version(version)
 	if v == "" {
@@ -32,6 +65,8 @@ func Load(version string) (string, Version, error) {
 	switch v {
 	case V1:
 		return apiV1SQL, v, nil
+	case V2:
+		return apiV1SQL + "\n" + apiV2SQL + "\n" + fingerprintSQL, v, nil
 	default:
 		return "", "", fmt.Errorf("%w: unsupported API version %q; supported: %v", pgmi.ErrInvalidConfig, version, SupportedVersions())
 	}
@@ -40,7 +75,7 @@ func Load(version string) (string, Version, error) {
 // Apply executes the API contract SQL for the specified version.
 // This creates the public views and functions that deploy.sql depends on.
 // Must be called after schema.sql and file loading.
-func Apply(ctx context.Context, conn *pgxpool.Conn, version string) (Version, error) {
+func Apply(ctx context.Context, conn *pgx.Conn, version string) (Version, error) {
 	sql, v, err := Load(version)
 	if err != nil {
 		return "", err
@@ -54,12 +89,60 @@ func Apply(ctx context.Context, conn *pgxpool.Conn, version string) (Version, er
 	return v, nil
 }
 
+// FingerprintSettings are the settings pgmi_catalog_fingerprint() declares in
+// SET clauses. A caller running FingerprintQuery sets them first, or it reads
+// differently deparsed text than a deploy recorded.
+var FingerprintSettings = [][2]string{
+	{"search_path", "pg_catalog"},
+	{"DateStyle", "ISO, YMD"},
+	{"IntervalStyle", "postgres"},
+	{"extra_float_digits", "3"},
+}
+
+// FingerprintQuery returns the body of pgmi_catalog_fingerprint() as a query
+// of its own, yielding (object_type, object_name, definition). It is how
+// `pgmi drift` reads the live catalog exactly as a deploy recorded it, without
+// a session or anything created on the server.
+func FingerprintQuery() string {
+	_, rest, _ := strings.Cut(fingerprintSQL, "AS $fingerprint$")
+	body, _, _ := strings.Cut(rest, "$fingerprint$")
+	return body
+}
+
 // SupportedVersions returns a sorted list of all supported API versions.
 func SupportedVersions() []Version {
-	return []Version{V1}
+	return []Version{V1, V2}
 }
 
 // LatestVersion returns the current latest API version.
 func LatestVersion() Version {
 	return Latest
 }
+
+// Introduced returns the public objects and view columns version v added over
+// its predecessor. V1 introduced the baseline, which is not listed.
+func Introduced(v Version) []string {
+	return introduced[v]
+}
+
+// RequiredVersion returns the oldest version whose contract defines every
+// versioned object the given SQL sources reference, and the objects that
+// forced it. Sources referencing only v1 objects require V1. It is a textual
+// scan — a name in a comment counts — which errs toward asking for more.
+func RequiredVersion(sources ...string) (Version, []string) {
+	required := V1
+	var uses []string
+	for _, v := range SupportedVersions() {
+		for _, name := range introduced[v] {
+			re := regexp.MustCompile(`\b` + regexp.QuoteMeta(name) + `\b`)
+			for _, src := range sources {
+				if re.MatchString(src) {
+					required = v
+					uses = append(uses, name)
+					break
+				}
+			}
+		}
+	}
+	return required, uses
+}
@@ -1,6 +1,8 @@
 package contract
 
 import (
+	"regexp"
+	"slices"
 	"strings"
 	"testing"
 )
@@ -60,26 +62,123 @@ func TestSupportedVersions(t *testing.T) {
 }
 
 func TestLatestVersion(t *testing.T) {
-	if LatestVersion() != V1 {
-		t.Errorf("expected latest version %q, got %q", V1, LatestVersion())
+	if LatestVersion() != V2 {
+		t.Errorf("expected latest version %q, got %q", V2, LatestVersion())
 	}
 }
 
-func TestLoad_EmptyAndV1_ReturnIdenticalSQL(t *testing.T) {
+func TestLoad_EmptyAndLatest_ReturnIdenticalSQL(t *testing.T) {
 	sqlEmpty, vEmpty, errEmpty := Load("")
-	sqlV1, vV1, errV1 := Load("1")
+	sqlLatest, vLatest, errLatest := Load(string(Latest))
 
 	if errEmpty != nil {
 		t.Fatalf("Load('') error: %v", errEmpty)
 	}
-	if errV1 != nil {
-		t.Fatalf("Load('1') error: %v", errV1)
+	if errLatest != nil {
+		t.Fatalf("Load(%q) error: %v", Latest, errLatest)
 	}
-	if vEmpty != vV1 {
-		t.Errorf("versions differ: %q vs %q", vEmpty, vV1)
+	if vEmpty != vLatest {
+		t.Errorf("versions differ: %q vs %q", vEmpty, vLatest)
 	}
-	if sqlEmpty != sqlV1 {
-		t.Error("SQL content differs between Load('') and Load('1')")
+	if sqlEmpty != sqlLatest {
+		t.Errorf("SQL content differs between Load('') and Load(%q)", Latest)
+	}
+}
+
+// v2 is v1 plus additions: the v1 SQL must be applied first and unchanged.
+func TestLoad_V2ExtendsV1(t *testing.T) {
+	sqlV1, _, err := Load("1")
+	if err != nil {
+		t.Fatalf("Load('1') failed: %v", err)
+	}
+	sqlV2, v, err := Load("2")
+	if err != nil {
+		t.Fatalf("Load('2') failed: %v", err)
+	}
+	if v != V2 {
+		t.Errorf("expected version %q, got %q", V2, v)
+	}
+	if !strings.HasPrefix(sqlV2, sqlV1) {
+		t.Error("v2 SQL must start with the v1 SQL, verbatim")
+	}
+	if len(sqlV2) <= len(sqlV1) {
+		t.Error("v2 SQL adds nothing over v1")
+	}
+}
+
+var createdObjectRe = regexp.MustCompile(`(?im)^\s*CREATE\s+(?:OR\s+REPLACE\s+)?(?:TEMP(?:ORARY)?\s+)?(?:VIEW|FUNCTION|TYPE|TABLE)\s+(?:pg_temp\.)?(\w+)(\.)?`)
+
+// createdObjects lists the session objects sql creates. A name qualified by
+// another schema is a table some function creates when it is called, such as
+// pgmi.catalog_fingerprint, not part of the session.
+func createdObjects(sql string) []string {
+	var names []string
+	for _, m := range createdObjectRe.FindAllStringSubmatch(sql, -1) {
+		if m[2] == "" {
+			names = append(names, m[1])
+		}
+	}
+	return names
+}
+
+// The compatibility promise, statically: api-v2.sql, with the fingerprint.sql
+// v2 also applies, creates only the objects Introduced(V2) declares, and none
+// of them is a v1 object. Redefining a v1
+// view there would change what a --compat 1 project sees under --compat 2.
+// The other names Introduced(V2) declares are columns of v2 views, which the
+// v1 contract must not mention at all.
+func TestAPIV2_OnlyAddsDeclaredObjects(t *testing.T) {
+	v1 := map[string]bool{}
+	for _, name := range createdObjects(apiV1SQL) {
+		v1[name] = true
+	}
+
+	v2SQL := apiV2SQL + "\n" + fingerprintSQL
+	created := createdObjects(v2SQL)
+	slices.Sort(created)
+	var declared []string
+	for _, name := range Introduced(V2) {
+		if slices.Contains(created, name) {
+			declared = append(declared, name)
+			continue
+		}
+		word := regexp.MustCompile(`\b` + name + `\b`)
+		if word.MatchString(apiV1SQL) || !word.MatchString(v2SQL) {
+			t.Errorf("Introduced(V2) declares %s, which is neither a v2 object nor a column only v2 has", name)
+		}
+	}
+	slices.Sort(declared)
+	if !slices.Equal(created, declared) {
+		t.Errorf("api-v2.sql creates %v, Introduced(V2) declares %v", created, declared)
+	}
+	for _, name := range created {
+		if v1[name] {
+			t.Errorf("api-v2.sql redefines v1 object %s", name)
+		}
+	}
+}
+
+func TestRequiredVersion(t *testing.T) {
+	tests := []struct {
+		name string
+		src  []string
+		want Version
+		uses []string
+	}{
+		{"v1 objects only", []string{"SELECT path FROM pg_temp.pgmi_plan_view"}, V1, nil},
+		{"no sources", nil, V1, nil},
+		{"v2 view", []string{"deploy", "FROM pg_temp.pgmi_plan_detail_view"}, V2, []string{"pgmi_plan_detail_view"}},
+		{"v2 column", []string{"SELECT path FROM plan ORDER BY dependency_order"}, V2, []string{"dependency_order"}},
+		{"v2 custom column", []string{"WHERE custom->'ops'->>'owner' = 'payments'"}, V2, []string{"custom"}},
+		{"prefix is not a match", []string{"pgmi_api_version_cache"}, V1, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, uses := RequiredVersion(tt.src...)
+			if got != tt.want || !slices.Equal(uses, tt.uses) {
+				t.Errorf("RequiredVersion = %q %v, want %q %v", got, uses, tt.want, tt.uses)
+			}
+		})
 	}
 }
 
@@ -143,6 +242,11 @@ func TestAPISQL_ViewsAreIdempotent(t *testing.T) {
 		if strings.HasPrefix(trimmed, "--") {
 			continue
 		}
+		// pgmi's own permanent tables are created inside function bodies, on
+		// first call, behind a check of their own.
+		if strings.Contains(trimmed, " PGMI.") || strings.HasSuffix(trimmed, " PGMI;") {
+			continue
+		}
 		isIdempotent := strings.Contains(trimmed, "OR REPLACE") ||
 			strings.Contains(trimmed, "CREATE TEMP")
 		if !isIdempotent {
@@ -209,3 +313,42 @@ func TestAPIContainsExpectedObjects(t *testing.T) {
 		}
 	}
 }
+
+func TestVersionBefore(t *testing.T) {
+	if !V1.Before(V2) || V2.Before(V1) || V2.Before(V2) {
+		t.Error("V1 must be before V2, and neither before itself")
+	}
+	if !Version("9").Before("10") {
+		t.Error("versions compare numerically: 9 is before 10")
+	}
+}
+
+// pgmi drift runs the function body on its own; cutting it out of the file
+// must give one query and nothing of the CREATE around it.
+func TestFingerprintQuery(t *testing.T) {
+	q := FingerprintQuery()
+	trimmed := strings.TrimSpace(q)
+	if !strings.HasPrefix(trimmed, "WITH ") || !strings.HasSuffix(trimmed, "FROM pg_extension x") {
+		t.Errorf("FingerprintQuery is not the function body:\n%s", q)
+	}
+	if strings.Contains(q, "$") || strings.Contains(q, ";") {
+		t.Error("FingerprintQuery holds a dollar quote or a statement separator")
+	}
+}
+
+// Drift sets FingerprintSettings where the function declares them; one out of
+// step with the other is drift reported on every object.
+func TestFingerprintSettings_MatchFunction(t *testing.T) {
+	header, _, _ := strings.Cut(fingerprintSQL, "AS $fingerprint$")
+	var declared []string
+	for _, m := range regexp.MustCompile(`(?m)^SET (\w+) = (.+)$`).FindAllStringSubmatch(header, -1) {
+		declared = append(declared, m[1]+"="+strings.Trim(m[2], "'"))
+	}
+	var want []string
+	for _, s := range FingerprintSettings {
+		want = append(want, s[0]+"="+s[1])
+	}
+	if !slices.Equal(declared, want) {
+		t.Errorf("fingerprint.sql SETs %v, FingerprintSettings has %v", declared, want)
+	}
+}
@@ -0,0 +1,165 @@
+-- ============================================================================
+-- PGMI Catalog Fingerprint
+-- ============================================================================
+-- Applied with api-v2.sql, and also read by `pgmi drift`, which runs the body
+-- below as a plain query against a live database: no session, no temp
+-- objects, so it works on a hot standby and leaves nothing behind. Keep the
+-- body one SELECT between the $fingerprint$ markers; contract.go cuts it out.
+--
+-- PUBLIC FUNCTIONS:
+--   pgmi_catalog_fingerprint() - One row per catalog object a deploy manages
+--
+-- The project's objects are those in every schema but pg_*,
+-- information_schema and pgmi (pgmi's own bookkeeping), minus the members of
+-- extensions, which the extension's version stands for. Each row's definition
+-- is the text that changes when the object does: pg_get_*def output where
+-- PostgreSQL has one, and owner and ACL wherever grants apply. ACL entries are
+-- sorted, so the order grants were issued in is not a difference.
+--
+-- Deparsed text depends on session settings: a name is schema-qualified only
+-- when search_path would not find it, and a date default prints in DateStyle.
+-- The function pins them, and pgmi drift sets the same ones (FingerprintSettings
+-- in contract.go), so a deploy.sql that changes search_path records the same
+-- text a later check reads.
+-- ============================================================================
+
+-- §pgmi_catalog_fingerprint ──────────────────────────────────────────────────
+CREATE OR REPLACE FUNCTION pg_temp.pgmi_catalog_fingerprint()
+RETURNS TABLE(object_type TEXT, object_name TEXT, definition TEXT)
+LANGUAGE sql STABLE
+SET search_path = pg_catalog
+SET DateStyle = 'ISO, YMD'
+SET IntervalStyle = postgres
+SET extra_float_digits = 3
+AS $fingerprint$
+WITH extension_member AS (
+    SELECT classid, objid FROM pg_depend WHERE deptype = 'e'
+),
+app AS (
+    SELECT n.oid, n.nspname, n.nspowner, n.nspacl
+    FROM pg_namespace n
+    WHERE n.nspname NOT LIKE 'pg\_%'
+      AND n.nspname NOT IN ('information_schema', 'pgmi')
+      AND NOT EXISTS (SELECT 1 FROM extension_member e
+                      WHERE e.classid = 'pg_namespace'::regclass AND e.objid = n.oid)
+),
+rel AS (
+    SELECT c.*, a.nspname
+    FROM pg_class c
+    JOIN app a ON a.oid = c.relnamespace
+    WHERE NOT EXISTS (SELECT 1 FROM extension_member e
+                      WHERE e.classid = 'pg_class'::regclass AND e.objid = c.oid)
+)
+SELECT 'schema', quote_ident(a.nspname),
+       format('owner=%s acl=%s', pg_get_userbyid(a.nspowner),
+              array_to_string(ARRAY(SELECT x::text FROM unnest(a.nspacl) x ORDER BY 1), ','))
+FROM app a
+
+UNION ALL
+SELECT 'relation', format('%I.%I', r.nspname, r.relname),
+       format('kind=%s owner=%s acl=%s rls=%s force_rls=%s',
+              r.relkind, pg_get_userbyid(r.relowner),
+              array_to_string(ARRAY(SELECT x::text FROM unnest(r.relacl) x ORDER BY 1), ','),
+              r.relrowsecurity, r.relforcerowsecurity)
+       || CASE WHEN r.relkind IN ('v', 'm') THEN E'\n' || pg_get_viewdef(r.oid) ELSE '' END
+       || coalesce((SELECT format(E'\nsequence %s start=%s increment=%s min=%s max=%s cache=%s cycle=%s',
+                                  s.seqtypid::regtype, s.seqstart, s.seqincrement, s.seqmin,
+                                  s.seqmax, s.seqcache, s.seqcycle)
+                    FROM pg_sequence s WHERE s.seqrelid = r.oid), '')
+FROM rel r
+WHERE r.relkind IN ('r', 'p', 'v', 'm', 'S', 'f')
+
+UNION ALL
+SELECT 'column', format('%I.%I.%I', r.nspname, r.relname, att.attname),
+       format('type=%s not_null=%s default=%s identity=%s collation=%s acl=%s',
+              format_type(att.atttypid, att.atttypmod), att.attnotnull,
+              coalesce(pg_get_expr(d.adbin, d.adrelid), ''), att.attidentity,
+              coalesce((SELECT co.collname::text FROM pg_collation co WHERE co.oid = att.attcollation), ''),
+              array_to_string(ARRAY(SELECT x::text FROM unnest(att.attacl) x ORDER BY 1), ','))
+FROM rel r
+JOIN pg_attribute att ON att.attrelid = r.oid
+LEFT JOIN pg_attrdef d ON d.adrelid = att.attrelid AND d.adnum = att.attnum
+WHERE r.relkind IN ('r', 'p', 'v', 'm', 'f')
+  AND att.attnum > 0 AND NOT att.attisdropped
+
+UNION ALL
+SELECT 'constraint', format('%I.%I.%I', r.nspname, r.relname, con.conname),
+       pg_get_constraintdef(con.oid)
+FROM rel r
+JOIN pg_constraint con ON con.conrelid = r.oid
+
+-- Indexes behind a primary key, unique or exclusion constraint are that
+-- constraint: listing them too would report one change twice.
+UNION ALL
+SELECT 'index', format('%I.%I', r.nspname, r.relname), pg_get_indexdef(r.oid)
+FROM rel r
+WHERE r.relkind IN ('i', 'I')
+  AND NOT EXISTS (SELECT 1 FROM pg_constraint con WHERE con.conindid = r.oid AND con.contype IN ('p', 'u', 'x'))
+
+UNION ALL
+SELECT 'function', format('%I.%I(%s)', a.nspname, p.proname, pg_get_function_identity_arguments(p.oid)),
+       format('owner=%s acl=%s', pg_get_userbyid(p.proowner),
+              array_to_string(ARRAY(SELECT x::text FROM unnest(p.proacl) x ORDER BY 1), ','))
+       || E'\n' || CASE WHEN p.prokind = 'a'
+                        THEN 'aggregate returns ' || pg_get_function_result(p.oid)
+                        ELSE pg_get_functiondef(p.oid) END
+FROM pg_proc p
+JOIN app a ON a.oid = p.pronamespace
+WHERE NOT EXISTS (SELECT 1 FROM extension_member e
+                  WHERE e.classid = 'pg_proc'::regclass AND e.objid = p.oid)
+
+UNION ALL
+SELECT 'trigger', format('%I.%I.%I', r.nspname, r.relname, tg.tgname),
+       format('enabled=%s ', tg.tgenabled) || pg_get_triggerdef(tg.oid)
+FROM rel r
+JOIN pg_trigger tg ON tg.tgrelid = r.oid
+WHERE NOT tg.tgisinternal
+
+UNION ALL
+SELECT 'policy', format('%I.%I.%I', r.nspname, r.relname, pol.polname),
+       format('command=%s permissive=%s roles=%s using=%s with_check=%s',
+              pol.polcmd, pol.polpermissive,
+              array_to_string(ARRAY(SELECT CASE WHEN r_oid = 0 THEN 'public' ELSE pg_get_userbyid(r_oid)::text END
+                                    FROM unnest(pol.polroles) r_oid ORDER BY 1), ','),
+              coalesce(pg_get_expr(pol.polqual, pol.polrelid), ''),
+              coalesce(pg_get_expr(pol.polwithcheck, pol.polrelid), ''))
+FROM rel r
+JOIN pg_policy pol ON pol.polrelid = r.oid
+
+-- Enums, domains, ranges and standalone composite types. A table's row type
+-- and array types come and go with what they belong to.
+UNION ALL
+SELECT 'type', format('%I.%I', a.nspname, t.typname),
+       format('kind=%s owner=%s acl=%s', t.typtype, pg_get_userbyid(t.typowner),
+              array_to_string(ARRAY(SELECT x::text FROM unnest(t.typacl) x ORDER BY 1), ','))
+       || CASE t.typtype
+              WHEN 'e' THEN E'\nlabels=' || array_to_string(ARRAY(
+                  SELECT quote_literal(en.enumlabel) FROM pg_enum en
+                  WHERE en.enumtypid = t.oid ORDER BY en.enumsortorder), ',')
+              WHEN 'd' THEN format(E'\nbase=%s not_null=%s default=%s',
+                  format_type(t.typbasetype, t.typtypmod), t.typnotnull, coalesce(t.typdefault, ''))
+                  || coalesce(E'\n' || (SELECT string_agg(format('%I %s', con.conname, pg_get_constraintdef(con.oid)), E'\n' ORDER BY con.conname)
+                                        FROM pg_constraint con WHERE con.contypid = t.oid), '')
+              WHEN 'r' THEN E'\nsubtype=' || (SELECT rng.rngsubtype::regtype::text FROM pg_range rng WHERE rng.rngtypid = t.oid)
+              WHEN 'c' THEN E'\n' || (SELECT string_agg(format('%I %s', att.attname, format_type(att.atttypid, att.atttypmod)), ', ' ORDER BY att.attnum)
+                                      FROM pg_attribute att WHERE att.attrelid = t.typrelid AND att.attnum > 0 AND NOT att.attisdropped)
+              ELSE ''
+          END
+FROM pg_type t
+JOIN app a ON a.oid = t.typnamespace
+WHERE t.typtype IN ('e', 'd', 'r', 'c')
+  AND (t.typtype <> 'c' OR (SELECT c.relkind FROM pg_class c WHERE c.oid = t.typrelid) = 'c')
+  AND NOT EXISTS (SELECT 1 FROM extension_member e
+                  WHERE e.classid = 'pg_type'::regclass AND e.objid = t.oid)
+
+UNION ALL
+SELECT 'extension', quote_ident(x.extname),
+       format('version=%s schema=%s', x.extversion, x.extnamespace::regnamespace)
+FROM pg_extension x
+$fingerprint$;
+
+COMMENT ON FUNCTION pg_temp.pgmi_catalog_fingerprint IS
+'Describes every catalog object of the project: schemas, relations, columns,
+constraints, indexes, functions, triggers, RLS policies, types and extensions.
+Returns: (object_type, object_name, definition), unordered. A definition
+changes whenever its object does, grants included.';
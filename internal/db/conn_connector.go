@@ -0,0 +1,145 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"sync"
+	"weak"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/vvka-141/pgmi/pkg/pgmi"
+)
+
+// ConnConnector lends pgmi a connection the caller owns, such as one from a
+// test harness or acquired from an application's pool. The deploy session
+// runs on that connection; see pgmi.BorrowedConnector for what pgmi does to
+// it and what the caller must not.
+type ConnConnector struct {
+	poolHooks
+	conn *pgx.Conn
+
+	captureOnce sync.Once
+	release     func()
+}
+
+// NewConnConnector creates a connector lending conn to the deploy session.
+func NewConnConnector(conn *pgx.Conn) *ConnConnector {
+	return &ConnConnector{conn: conn}
+}
+
+// NewConnConnectorWithHooks is NewConnConnector with hooks applied to the
+// pools Connect opens. Of the hooks, only OnNotice reaches conn itself, and
+// only if it was opened from a configuration RouteNotices prepared; its
+// dialer and resolver were used when it connected.
+func NewConnConnectorWithHooks(conn *pgx.Conn, hooks PoolHooks) *ConnConnector {
+	c := NewConnConnector(conn)
+	c.setHooks(hooks)
+	return c
+}
+
+// BorrowedConn returns the caller's connection. From then until Close, its
+// notices are reported the way those of the pools Connect opens are, if it
+// was opened from a configuration RouteNotices prepared.
+func (c *ConnConnector) BorrowedConn() *pgx.Conn {
+	c.captureOnce.Do(func() {
+		c.release = captureNotices(c.conn.PgConn(), c.noticeHandler())
+	})
+	return c.conn
+}
+
+// Close hands the connection's notices back to its own OnNotice. The
+// connection itself stays open.
+func (c *ConnConnector) Close() error {
+	if c.release != nil {
+		c.release()
+		c.release = nil
+	}
+	return nil
+}
+
+// Connect opens a separate pool from the connection's configuration, for the
+// checks pgmi makes outside the deploy session.
+func (c *ConnConnector) Connect(ctx context.Context) (*pgxpool.Pool, error) {
+	base, err := PoolConfigFromConn(c.conn)
+	if err != nil {
+		return nil, err
+	}
+	return NewPoolConfigConnectorWithHooks(base, "", c.hooks).Connect(ctx)
+}
+
+// PoolConfigFromConn returns a pool configuration that connects the way conn
+// did: host, TLS, credentials and dialer.
+func PoolConfigFromConn(conn *pgx.Conn) (*pgxpool.Config, error) {
+	return poolConfigFromConnConfig(conn.Config())
+}
+
+func poolConfigFromConnConfig(connConfig *pgx.ConnConfig) (*pgxpool.Config, error) {
+	// pgxpool only accepts a configuration ParseConfig created; the
+	// connection's own configuration then replaces what it parsed.
+	poolConfig, err := pgxpool.ParseConfig("")
+	if err != nil {
+		return nil, fmt.Errorf("failed to build a pool configuration from the connection: %w", err)
+	}
+	poolConfig.ConnConfig = connConfig
+	return poolConfig, nil
+}
+
+// RouteNotices prepares connConfig so that a deploy session can take over
+// the notices of the connections opened from it. pgx fixes a connection's
+// notice handler when it connects and offers no way to change it after, so
+// the handler installed here forwards each notice to whichever capture holds
+// the connection at the time, and to connConfig's own OnNotice otherwise.
+func RouteNotices(connConfig *pgx.ConnConfig) {
+	own := connConfig.OnNotice
+	connConfig.OnNotice = func(conn *pgconn.PgConn, notice *pgconn.Notice) {
+		if capture, ok := noticeCaptures.Load(conn); ok {
+			capture.(pgconn.NoticeHandler)(conn, notice)
+			return
+		}
+		if own != nil {
+			own(conn, notice)
+		}
+	}
+	afterConnect := connConfig.AfterConnect
+	connConfig.AfterConnect = func(ctx context.Context, conn *pgconn.PgConn) error {
+		if afterConnect != nil {
+			if err := afterConnect(ctx, conn); err != nil {
+				return err
+			}
+		}
+		key := weak.Make(conn)
+		routedConns.Store(key, struct{}{})
+		runtime.AddCleanup(conn, func(key weak.Pointer[pgconn.PgConn]) { routedConns.Delete(key) }, key)
+		return nil
+	}
+}
+
+// NoticesRouted reports whether conn was opened from a configuration
+// RouteNotices prepared, so that its notices can be captured.
+func NoticesRouted(conn *pgconn.PgConn) bool {
+	_, ok := routedConns.Load(weak.Make(conn))
+	return ok
+}
+
+var (
+	// routedConns holds the connections RouteNotices' AfterConnect saw, by
+	// weak pointer, so that holding one here does not keep it alive.
+	routedConns sync.Map
+	// noticeCaptures holds the captureNotices handlers, by connection.
+	noticeCaptures sync.Map
+)
+
+// captureNotices sends conn's notices to handle until the returned func is
+// called. It does nothing to a connection RouteNotices did not prepare.
+func captureNotices(conn *pgconn.PgConn, handle pgconn.NoticeHandler) (release func()) {
+	if !NoticesRouted(conn) {
+		return func() {}
+	}
+	noticeCaptures.Store(conn, handle)
+	return func() { noticeCaptures.Delete(conn) }
+}
+
+var _ pgmi.BorrowedConnector = (*ConnConnector)(nil)
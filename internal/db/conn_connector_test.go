@@ -0,0 +1,66 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestRouteNotices(t *testing.T) {
+	connConfig, err := pgx.ParseConfig("postgres://app@localhost/appdb")
+	if err != nil {
+		t.Fatalf("ParseConfig: %v", err)
+	}
+	var own, captured, observed []string
+	connConfig.OnNotice = func(_ *pgconn.PgConn, n *pgconn.Notice) { own = append(own, n.Message) }
+	ownAfterConnect := errors.New("own AfterConnect")
+	connConfig.AfterConnect = func(context.Context, *pgconn.PgConn) error { return ownAfterConnect }
+	RouteNotices(connConfig)
+
+	// The caller's AfterConnect still decides whether the connection is kept.
+	conn := new(pgconn.PgConn)
+	if err := connConfig.AfterConnect(context.Background(), conn); !errors.Is(err, ownAfterConnect) {
+		t.Fatalf("AfterConnect = %v, want the caller's error", err)
+	}
+	if NoticesRouted(conn) {
+		t.Error("a connection its own AfterConnect rejected counts as routed")
+	}
+	connConfig.AfterConnect = nil
+	RouteNotices(connConfig)
+	if err := connConfig.AfterConnect(context.Background(), conn); err != nil {
+		t.Fatalf("AfterConnect: %v", err)
+	}
+	if !NoticesRouted(conn) || NoticesRouted(new(pgconn.PgConn)) {
+		t.Fatal("NoticesRouted does not tell routed connections from others")
+	}
+
+	notify := func(message string) { connConfig.OnNotice(conn, &pgconn.Notice{Message: message}) }
+	notify("before")
+	hooks := &poolHooks{hooks: PoolHooks{OnNotice: func(message, _, _ string) { captured = append(captured, message) }}}
+	release := captureNotices(conn, hooks.noticeHandler())
+	stop := ObserveNotices(conn, func(message string) { observed = append(observed, message) })
+	notify("during")
+	stop()
+	release()
+	notify("after")
+
+	if want := []string{"before", "after"}; !slices.Equal(own, want) {
+		t.Errorf("own handler got %q, want %q", own, want)
+	}
+	if want := []string{"during"}; !slices.Equal(captured, want) || !slices.Equal(observed, want) {
+		t.Errorf("capture got %q and observer %q, want %q", captured, observed, want)
+	}
+}
+
+func TestCaptureNotices_UnroutedConn(t *testing.T) {
+	conn := new(pgconn.PgConn)
+	release := captureNotices(conn, func(*pgconn.PgConn, *pgconn.Notice) {})
+	defer release()
+	if _, ok := noticeCaptures.Load(conn); ok {
+		t.Error("a connection RouteNotices did not prepare was captured")
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"os"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/jackc/pgx/v5/pgconn"
@@ -28,12 +29,13 @@ const (
 	DefaultMaxConnIdleTime = 30 * time.Minute
 )
 
-// NoticeHandler is called for each PostgreSQL NOTICE/WARNING during execution.
-// Replaceable to support timing prefixes in verbose mode.
-var NoticeHandler func(message, detail, hint string) = DefaultNoticeHandler
+// NoticeHandler is called for each PostgreSQL NOTICE/WARNING during execution,
+// with the severity PostgreSQL gave it (NOTICE, WARNING, INFO...).
+// Replaceable to support timing prefixes in verbose mode and structured logs.
+var NoticeHandler func(severity, message, detail, hint string) = DefaultNoticeHandler
 
 // DefaultNoticeHandler prints notices to stderr without decoration.
-func DefaultNoticeHandler(message, detail, hint string) {
+func DefaultNoticeHandler(_, message, detail, hint string) {
 	fmt.Fprintln(os.Stderr, message)
 	if detail != "" {
 		fmt.Fprintf(os.Stderr, "DETAIL: %s\n", detail)
@@ -43,18 +45,103 @@ func DefaultNoticeHandler(message, detail, hint string) {
 	}
 }
 
-func configurePool(poolConfig *pgxpool.Config) {
+// PoolHooks customise the pools a connector opens, for a program that
+// embeds pgmi rather than running it as the CLI.
+type PoolHooks struct {
+	// OnNotice receives the server's notices instead of NoticeHandler.
+	// Swapping NoticeHandler is enough for the CLI, which runs one deploy at
+	// a time; a program running several at once needs each to report to its
+	// own handler.
+	OnNotice func(message, detail, hint string)
+
+	// DialFunc opens the network connection instead of pgx's dialer: through
+	// a tunnel, to a test container, over a custom transport.
+	DialFunc pgconn.DialFunc
+
+	// LookupFunc resolves host names instead of pgx's resolver. A tunnel
+	// sets one returning the name unresolved, for the far end to resolve.
+	LookupFunc pgconn.LookupFunc
+
+	// Logger receives the connector's diagnostics, such as when a token was
+	// acquired and when it expires. Nil discards them.
+	Logger pgmi.Logger
+}
+
+// poolHooks is embedded in every connector so NewConnectorWithHooks can set
+// its PoolHooks.
+type poolHooks struct {
+	hooks PoolHooks
+}
+
+func (h *poolHooks) setHooks(hooks PoolHooks) {
+	h.hooks = hooks
+}
+
+func (h *poolHooks) verbose(format string, args ...any) {
+	if h.hooks.Logger != nil {
+		h.hooks.Logger.Verbose(format, args...)
+	}
+}
+
+// configurePool applies pgmi's pool sizing, notice handling and any hooks.
+// Without an OnNotice hook, NoticeHandler is looked up as each notice
+// arrives, so a swap made after Connect still takes effect.
+func (h *poolHooks) configurePool(poolConfig *pgxpool.Config) {
 	poolConfig.MaxConns = DefaultMaxConns
 	poolConfig.MinConns = DefaultMinConns
 	poolConfig.MaxConnIdleTime = DefaultMaxConnIdleTime
-	poolConfig.ConnConfig.OnNotice = func(_ *pgconn.PgConn, notice *pgconn.Notice) {
-		NoticeHandler(notice.Message, notice.Detail, notice.Hint)
+	poolConfig.ConnConfig.OnNotice = h.noticeHandler()
+	if h.hooks.DialFunc != nil {
+		poolConfig.ConnConfig.DialFunc = h.hooks.DialFunc
+	}
+	if h.hooks.LookupFunc != nil {
+		poolConfig.ConnConfig.LookupFunc = h.hooks.LookupFunc
+	}
+}
+
+// noticeHandler reports a notice to the OnNotice hook, else to
+// NoticeHandler, after passing it to any ObserveNotices callback.
+func (h *poolHooks) noticeHandler() pgconn.NoticeHandler {
+	onNotice := h.hooks.OnNotice
+	return func(conn *pgconn.PgConn, notice *pgconn.Notice) {
+		if observe, ok := noticeObservers.Load(conn); ok {
+			observe.(func(string))(notice.Message)
+		}
+		if onNotice != nil {
+			onNotice(notice.Message, notice.Detail, notice.Hint)
+			return
+		}
+		NoticeHandler(noticeSeverity(notice), notice.Message, notice.Detail, notice.Hint)
+	}
+}
+
+// noticeObservers holds the ObserveNotices callbacks, by connection.
+var noticeObservers sync.Map
+
+// ObserveNotices passes the message of every notice conn receives to fn, in
+// addition to the handler reporting it, until the returned func is called.
+// pgx delivers a notice while reading the reply to the statement that raised
+// it, so fn runs on the goroutine executing that statement. Only connections
+// opened by pgmi's connectors are observed, and a borrowed one while a
+// ConnConnector captures its notices.
+func ObserveNotices(conn *pgconn.PgConn, fn func(message string)) (stop func()) {
+	noticeObservers.Store(conn, fn)
+	return func() { noticeObservers.Delete(conn) }
+}
+
+// noticeSeverity returns a notice's severity in English, whatever the
+// server's lc_messages; servers older than 9.6 send only the localized one.
+func noticeSeverity(notice *pgconn.Notice) string {
+	if notice.SeverityUnlocalized != "" {
+		return notice.SeverityUnlocalized
 	}
+	return notice.Severity
 }
 
 // StandardConnector implements the Connector interface for standard
 // username/password authentication with automatic retry on transient failures.
 type StandardConnector struct {
+	poolHooks
 	config        *pgmi.ConnectionConfig
 	retryExecutor *retry.Executor
 }
@@ -93,7 +180,7 @@ func (c *StandardConnector) Connect(ctx context.Context) (*pgxpool.Pool, error)
 			return fmt.Errorf("failed to parse connection config: %w", err)
 		}
 
-		configurePool(poolConfig)
+		c.configurePool(poolConfig)
 
 		newPool, err := pgxpool.NewWithConfig(ctx, poolConfig)
 		if err != nil {
@@ -137,11 +224,44 @@ func NewConnector(config *pgmi.ConnectionConfig) (pgmi.Connector, error) {
 		return newGoogleConnector(config)
 	case pgmi.AuthMethodAzureEntraID:
 		return newAzureConnector(config)
+	case pgmi.AuthMethodExec:
+		tokenProvider, err := NewExecTokenProvider(config.TokenCommand, config)
+		if err != nil {
+			return nil, err
+		}
+		return NewTokenBasedConnector(config, tokenProvider, "exec token"), nil
+	case pgmi.AuthMethodTokenFile:
+		tokenProvider, err := NewFileTokenProvider(config.TokenFile)
+		if err != nil {
+			return nil, err
+		}
+		return NewTokenBasedConnector(config, tokenProvider, "token file"), nil
+	case pgmi.AuthMethodGSSAPI:
+		return newGSSAPIConnector(config)
+	case pgmi.AuthMethodCert:
+		return newCertConnector(config)
 	default:
 		return nil, fmt.Errorf("unsupported auth method %v: %w", config.AuthMethod, pgmi.ErrUnsupportedAuthMethod)
 	}
 }
 
+// NewConnectorWithHooks is NewConnector with hooks applied to every pool the
+// connector opens. Google Cloud SQL connections are dialed by the Cloud SQL
+// connector, so they cannot take a DialFunc.
+func NewConnectorWithHooks(config *pgmi.ConnectionConfig, hooks PoolHooks) (pgmi.Connector, error) {
+	if (hooks.DialFunc != nil || hooks.LookupFunc != nil) && config.AuthMethod == pgmi.AuthMethodGoogleIAM {
+		return nil, fmt.Errorf("a custom dialer cannot be combined with Google Cloud SQL IAM auth, which dials through the Cloud SQL connector: %w", pgmi.ErrInvalidConfig)
+	}
+	connector, err := NewConnector(config)
+	if err != nil {
+		return nil, err
+	}
+	if h, ok := connector.(interface{ setHooks(PoolHooks) }); ok {
+		h.setHooks(hooks)
+	}
+	return connector, nil
+}
+
 // connError keeps the user-visible message free of wrapped-error noise while
 // still surfacing the raw err and the ErrConnectionFailed sentinel through
 // errors.Is(). Without the custom Unwrap, we'd have to repeat err.Error() in
@@ -209,6 +329,13 @@ func wrapConnectionError(err error, host string, port int, database, username st
 	errStr := strings.ToLower(err.Error())
 
 	switch {
+	// pgx's target_session_attrs checks found no writable server among the
+	// hosts it reached.
+	case strings.Contains(errStr, "read only connection") || strings.Contains(errStr, "server is in standby mode"):
+		return newConnError(fmt.Errorf("%w: %w", pgmi.ErrReadOnlyServer, err),
+			"no server reached accepts writes: each is a standby or read-only, and target_session_attrs asks for a primary\n"+
+				"list the cluster's primary among the hosts, or retry once a failover completes")
+
 	case strings.Contains(errStr, "connection refused") || strings.Contains(errStr, "actively refused"):
 		return newConnError(err, "connection refused to %s\nis PostgreSQL running? check: pg_isready -h %s -p %d", addr, host, port)
 
@@ -247,6 +374,24 @@ func newAWSConnector(config *pgmi.ConnectionConfig) (pgmi.Connector, error) {
 	return NewTokenBasedConnector(config, tokenProvider, "AWS IAM"), nil
 }
 
+// newCertConnector creates a connector whose only credential is the TLS
+// client certificate, for servers using pg_hba.conf's cert method. It needs
+// TLS on every attempt: sslmode prefer, the usual default, is raised to
+// require, since its plaintext fallback has no certificate to present.
+func newCertConnector(config *pgmi.ConnectionConfig) (pgmi.Connector, error) {
+	if config.SSLCert == "" || config.SSLKey == "" {
+		return nil, fmt.Errorf("certificate auth requires a client certificate and key (--sslcert/--sslkey, $PGSSLCERT/$PGSSLKEY or sslcert/sslkey in pgmi.yaml): %w", pgmi.ErrInvalidConfig)
+	}
+	cfg := config.DeepCopy()
+	switch cfg.SSLMode {
+	case "disable", "allow":
+		return nil, fmt.Errorf("certificate auth needs TLS, but sslmode is %q: %w", cfg.SSLMode, pgmi.ErrInvalidConfig)
+	case "", "prefer":
+		cfg.SSLMode = "require"
+	}
+	return NewStandardConnector(&cfg), nil
+}
+
 // newGoogleConnector creates a GoogleCloudSQLConnector for Google Cloud SQL IAM authentication.
 func newGoogleConnector(config *pgmi.ConnectionConfig) (pgmi.Connector, error) {
 	if config.GoogleInstance == "" {
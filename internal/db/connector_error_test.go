@@ -194,6 +194,14 @@ func TestWrapConnectionError(t *testing.T) {
 			database:     "busydb",
 			wantContains: `too many connections to database "busydb"`,
 		},
+		{
+			name:         "no writable host for target_session_attrs",
+			errMsg:       "failed to connect to `user=app database=mydb`: 10.0.0.2:5432 (db2): ValidateConnect failed: read only connection",
+			host:         "db1",
+			port:         5432,
+			database:     "mydb",
+			wantContains: "no server reached accepts writes",
+		},
 		{
 			name:         "unknown error falls through to default",
 			errMsg:       "something completely unexpected happened",
@@ -230,6 +238,10 @@ func TestWrapConnectionError(t *testing.T) {
 			if !errors.Is(wrapped, pgmi.ErrConnectionFailed) {
 				t.Error("wrapped error does not chain pgmi.ErrConnectionFailed")
 			}
+
+			if strings.Contains(tt.errMsg, "read only connection") && !errors.Is(wrapped, pgmi.ErrReadOnlyServer) {
+				t.Error("wrapped error does not chain pgmi.ErrReadOnlyServer")
+			}
 		})
 	}
 }
@@ -0,0 +1,96 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vvka-141/pgmi/pkg/pgmi"
+)
+
+// execTokenTimeout bounds one run of the token command. A helper waiting on
+// a login prompt nobody will answer would otherwise hold the deploy until
+// --timeout.
+const execTokenTimeout = 30 * time.Second
+
+// tokenResponse is what an exec token command prints, and what a token file
+// may hold instead of a bare token: {"token": "...", "expiresAt": "<RFC 3339>"}.
+// expiresAt may be omitted when the token's lifetime is unknown.
+type tokenResponse struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+func parseTokenResponse(data []byte) (string, time.Time, error) {
+	var resp tokenResponse
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return "", time.Time{}, fmt.Errorf(`expected JSON {"token": "...", "expiresAt": "<RFC 3339>"}: %w`, err)
+	}
+	if resp.Token == "" {
+		return "", time.Time{}, errors.New(`the JSON has no "token"`)
+	}
+	return resp.Token, resp.ExpiresAt, nil
+}
+
+// ExecTokenProvider runs a command to obtain a token, the way kubectl's exec
+// credential plugins work: the command prints {"token", "expiresAt"} as JSON
+// on stdout and exits 0. It is told which connection the token is for through
+// PGMI_TOKEN_HOST, PGMI_TOKEN_PORT, PGMI_TOKEN_USER and PGMI_TOKEN_DATABASE.
+type ExecTokenProvider struct {
+	command []string
+	env     []string
+}
+
+// NewExecTokenProvider creates a provider running command, program first,
+// for tokens to connect with config.
+func NewExecTokenProvider(command []string, config *pgmi.ConnectionConfig) (*ExecTokenProvider, error) {
+	if len(command) == 0 || command[0] == "" {
+		return nil, fmt.Errorf("exec auth requires a token command (--token-command, $PGMI_TOKEN_COMMAND or token_command in pgmi.yaml): %w", pgmi.ErrInvalidConfig)
+	}
+	return &ExecTokenProvider{
+		command: command,
+		env: []string{
+			"PGMI_TOKEN_HOST=" + config.Host,
+			"PGMI_TOKEN_PORT=" + strconv.Itoa(config.Port),
+			"PGMI_TOKEN_USER=" + config.Username,
+			"PGMI_TOKEN_DATABASE=" + config.Database,
+		},
+	}, nil
+}
+
+// GetToken runs the command and parses what it prints.
+func (p *ExecTokenProvider) GetToken(ctx context.Context) (string, time.Time, error) {
+	ctx, cancel := context.WithTimeout(ctx, execTokenTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, p.command[0], p.command[1:]...)
+	cmd.Env = append(os.Environ(), p.env...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", time.Time{}, fmt.Errorf("token command %s: %w: %s", p.command[0], err, msg)
+		}
+		return "", time.Time{}, fmt.Errorf("token command %s: %w", p.command[0], err)
+	}
+
+	token, expiresOn, err := parseTokenResponse(stdout.Bytes())
+	if err != nil {
+		// The output is not quoted back: it is a token, or close to one.
+		return "", time.Time{}, fmt.Errorf("token command %s: %w", p.command[0], err)
+	}
+	return token, expiresOn, nil
+}
+
+// String returns a human-readable representation of the provider.
+func (p *ExecTokenProvider) String() string {
+	return fmt.Sprintf("ExecTokenProvider(command=%s)", p.command[0])
+}
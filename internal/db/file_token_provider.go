@@ -0,0 +1,81 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/vvka-141/pgmi/pkg/pgmi"
+)
+
+// FileTokenProvider reads a token from a file another process keeps current:
+// a token projected into a pod, rendered by Vault Agent, or written by a
+// SPIFFE helper. The file holds either the bare token or the JSON an exec
+// token command prints.
+//
+// Those agents rotate the file by replacing it, so every GetToken checks its
+// modification time and size and re-reads it only when either changed. A
+// connection dialed after a rotation picks up the new token.
+type FileTokenProvider struct {
+	path string
+
+	mu        sync.Mutex
+	modTime   time.Time
+	size      int64
+	token     string
+	expiresOn time.Time
+}
+
+// NewFileTokenProvider creates a provider reading its token from path.
+func NewFileTokenProvider(path string) (*FileTokenProvider, error) {
+	if path == "" {
+		return nil, fmt.Errorf("token_file auth requires a token file (--token-file, $PGMI_TOKEN_FILE or token_file in pgmi.yaml): %w", pgmi.ErrInvalidConfig)
+	}
+	return &FileTokenProvider{path: path}, nil
+}
+
+// GetToken returns the file's current token.
+func (p *FileTokenProvider) GetToken(context.Context) (string, time.Time, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	info, err := os.Stat(p.path)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("token file: %w", err)
+	}
+	if p.token != "" && info.ModTime().Equal(p.modTime) && info.Size() == p.size {
+		return p.token, p.expiresOn, nil
+	}
+
+	data, err := os.ReadFile(p.path)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("token file: %w", err)
+	}
+	token, expiresOn, err := parseTokenFile(data)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("token file %s: %w", p.path, err)
+	}
+
+	p.modTime, p.size = info.ModTime(), info.Size()
+	p.token, p.expiresOn = token, expiresOn
+	return token, expiresOn, nil
+}
+
+func parseTokenFile(data []byte) (string, time.Time, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return "", time.Time{}, fmt.Errorf("the file is empty")
+	}
+	if data[0] == '{' {
+		return parseTokenResponse(data)
+	}
+	return string(data), time.Time{}, nil
+}
+
+// String returns a human-readable representation of the provider.
+func (p *FileTokenProvider) String() string {
+	return fmt.Sprintf("FileTokenProvider(path=%s)", p.path)
+}
@@ -16,6 +16,7 @@ import (
 // Implements io.Closer — caller must call Close() after the pool is closed
 // to release the Cloud SQL dialer resources.
 type GoogleCloudSQLConnector struct {
+	poolHooks
 	config   *pgmi.ConnectionConfig
 	instance string
 	dialer   *cloudsqlconn.Dialer
@@ -65,7 +66,7 @@ func (c *GoogleCloudSQLConnector) Connect(ctx context.Context) (*pgxpool.Pool, e
 		return dialer.Dial(ctx, c.instance)
 	}
 
-	configurePool(poolConfig)
+	c.configurePool(poolConfig)
 
 	pool, err := pgxpool.NewWithConfig(ctx, poolConfig)
 	if err != nil {
@@ -0,0 +1,38 @@
+package db
+
+import (
+	"fmt"
+	"sync/atomic"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/vvka-141/pgmi/pkg/pgmi"
+)
+
+// gssProviderRegistered records whether RegisterGSSProvider was called. pgx
+// keeps its provider private, and without one it fails only after the server
+// has asked for GSSAPI, with an error naming a package the user never chose.
+var gssProviderRegistered atomic.Bool
+
+// RegisterGSSProvider installs the Kerberos implementation GSSAPI auth uses.
+// pgx speaks the GSSAPI protocol but leaves the mechanism to a provider, so
+// the program registers one once at startup. cmd/pgmi registers krb5.NewGSS:
+//
+//	db.RegisterGSSProvider(krb5.NewGSS)
+func RegisterGSSProvider(newGSS pgconn.NewGSSFunc) {
+	pgconn.RegisterGSSProvider(newGSS)
+	gssProviderRegistered.Store(newGSS != nil)
+}
+
+// newGSSAPIConnector creates a connector authenticating with a Kerberos
+// ticket. BuildConnectionString adds require_auth=gss, so a server asking for
+// a password instead is refused rather than answered.
+func newGSSAPIConnector(config *pgmi.ConnectionConfig) (pgmi.Connector, error) {
+	if !gssProviderRegistered.Load() {
+		return nil, fmt.Errorf("GSSAPI auth needs a Kerberos implementation and this program registered none; "+
+			"call db.RegisterGSSProvider at startup (see docs/CONNECTIONS.md#kerberos-gssapi): %w", pgmi.ErrUnsupportedAuthMethod)
+	}
+	if config.KerberosServiceName != "" && config.KerberosSPN != "" {
+		return nil, fmt.Errorf("set either a Kerberos service name or an SPN, not both: %w", pgmi.ErrInvalidConfig)
+	}
+	return NewStandardConnector(config), nil
+}
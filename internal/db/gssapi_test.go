@@ -0,0 +1,89 @@
+package db
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/vvka-141/pgmi/pkg/pgmi"
+)
+
+func TestNewConnector_GSSAPIWithoutProvider(t *testing.T) {
+	if gssProviderRegistered.Load() {
+		t.Skip("a GSS provider is registered in this binary")
+	}
+	_, err := NewConnector(&pgmi.ConnectionConfig{Host: "db.example.com", Port: 5432, AuthMethod: pgmi.AuthMethodGSSAPI})
+	if !errors.Is(err, pgmi.ErrUnsupportedAuthMethod) {
+		t.Errorf("expected ErrUnsupportedAuthMethod, got %v", err)
+	}
+}
+
+func TestNewConnector_GSSAPI(t *testing.T) {
+	RegisterGSSProvider(func() (pgconn.GSS, error) { return nil, errors.New("no ticket") })
+	t.Cleanup(func() { RegisterGSSProvider(nil) })
+
+	c, err := NewConnector(&pgmi.ConnectionConfig{Host: "db.example.com", Port: 5432, AuthMethod: pgmi.AuthMethodGSSAPI})
+	if err != nil {
+		t.Fatalf("NewConnector: %v", err)
+	}
+	if _, ok := c.(*StandardConnector); !ok {
+		t.Errorf("got %T, want *StandardConnector", c)
+	}
+
+	_, err = NewConnector(&pgmi.ConnectionConfig{
+		AuthMethod:          pgmi.AuthMethodGSSAPI,
+		KerberosServiceName: "postgres",
+		KerberosSPN:         "postgres/db.example.com@EXAMPLE.COM",
+	})
+	if !errors.Is(err, pgmi.ErrInvalidConfig) {
+		t.Errorf("service name and SPN: expected ErrInvalidConfig, got %v", err)
+	}
+}
+
+func TestNewConnector_Cert(t *testing.T) {
+	base := pgmi.ConnectionConfig{
+		Host:       "db.example.com",
+		Port:       5432,
+		AuthMethod: pgmi.AuthMethodCert,
+		SSLCert:    "/certs/client.crt",
+		SSLKey:     "/certs/client.key",
+	}
+
+	tests := []struct {
+		sslMode  string
+		wantMode string
+		wantErr  bool
+	}{
+		{sslMode: "", wantMode: "require"},
+		{sslMode: "prefer", wantMode: "require"},
+		{sslMode: "verify-full", wantMode: "verify-full"},
+		{sslMode: "disable", wantErr: true},
+		{sslMode: "allow", wantErr: true},
+	}
+	for _, tt := range tests {
+		cfg := base
+		cfg.SSLMode = tt.sslMode
+		c, err := NewConnector(&cfg)
+		if tt.wantErr {
+			if !errors.Is(err, pgmi.ErrInvalidConfig) {
+				t.Errorf("sslmode %q: expected ErrInvalidConfig, got %v", tt.sslMode, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("sslmode %q: %v", tt.sslMode, err)
+		}
+		if got := c.(*StandardConnector).config.SSLMode; got != tt.wantMode {
+			t.Errorf("sslmode %q: connector uses %q, want %q", tt.sslMode, got, tt.wantMode)
+		}
+		if cfg.SSLMode != tt.sslMode {
+			t.Errorf("sslmode %q: the caller's config was changed to %q", tt.sslMode, cfg.SSLMode)
+		}
+	}
+
+	noKey := base
+	noKey.SSLKey = ""
+	if _, err := NewConnector(&noKey); !errors.Is(err, pgmi.ErrInvalidConfig) {
+		t.Errorf("without a key: expected ErrInvalidConfig, got %v", err)
+	}
+}
@@ -0,0 +1,125 @@
+// Package krb5 is the Kerberos provider behind GSSAPI auth (--gssapi,
+// auth_method: gssapi, require_auth=gss). pgx speaks the GSSAPI protocol but
+// leaves the mechanism to a provider; this one is pure Go, so it needs no cgo
+// and no system Kerberos library. It uses the ticket kinit put in a file
+// credential cache and never asks the KDC for a new TGT.
+package krb5
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jcmturner/gokrb5/v8/client"
+	"github.com/jcmturner/gokrb5/v8/config"
+	"github.com/jcmturner/gokrb5/v8/credentials"
+	"github.com/jcmturner/gokrb5/v8/spnego"
+)
+
+// defaultConfigPath is where krb5.conf is read from unless $KRB5_CONFIG says
+// otherwise.
+const defaultConfigPath = "/etc/krb5.conf"
+
+// GSS authenticates one connection with the ticket in the credential cache.
+type GSS struct {
+	cl *client.Client
+}
+
+// NewGSS loads krb5.conf and the credential cache, failing when the cache
+// holds no unexpired TGT. It has the signature db.RegisterGSSProvider takes.
+func NewGSS() (pgconn.GSS, error) {
+	cfgPath := configPath()
+	cfg, err := config.Load(cfgPath)
+	if err != nil {
+		return nil, fmt.Errorf("kerberos: failed to read %s (set $KRB5_CONFIG to use another file): %w", cfgPath, err)
+	}
+	ccPath, err := ccachePath()
+	if err != nil {
+		return nil, err
+	}
+	ccache, err := credentials.LoadCCache(ccPath)
+	if err != nil {
+		return nil, fmt.Errorf("kerberos: failed to read the credential cache %s (run kinit): %w", ccPath, err)
+	}
+	cl, err := client.NewFromCCache(ccache, cfg, client.DisablePAFXFAST(true))
+	if err != nil {
+		return nil, fmt.Errorf("kerberos: credential cache %s: %w", ccPath, err)
+	}
+	if err := cl.Login(); err != nil {
+		return nil, fmt.Errorf("kerberos: no valid ticket in %s (run kinit): %w", ccPath, err)
+	}
+	return &GSS{cl: cl}, nil
+}
+
+// GetInitToken returns the first token for the principal service/host. The
+// host is used as given, not canonicalized: the docs ask for the name in the
+// server's principal.
+func (g *GSS) GetInitToken(host, service string) ([]byte, error) {
+	return g.GetInitTokenFromSPN(service + "/" + host)
+}
+
+// GetInitTokenFromSPN returns the first token for the principal spn.
+func (g *GSS) GetInitTokenFromSPN(spn string) ([]byte, error) {
+	token, err := spnego.SPNEGOClient(g.cl, spn).InitSecContext()
+	if err != nil {
+		return nil, fmt.Errorf("kerberos: no service ticket for %s: %w", spn, err)
+	}
+	b, err := token.Marshal()
+	if err != nil {
+		return nil, fmt.Errorf("kerberos: %w", err)
+	}
+	return b, nil
+}
+
+// Continue reads the server's answer. One round is enough: the server either
+// accepts the ticket or rejects it.
+func (g *GSS) Continue(inToken []byte) (done bool, outToken []byte, err error) {
+	var t spnego.SPNEGOToken
+	if err := t.Unmarshal(inToken); err != nil {
+		return true, nil, fmt.Errorf("kerberos: unreadable server token: %w", err)
+	}
+	if !t.Resp {
+		return true, nil, errors.New("kerberos: the server did not answer with a response token")
+	}
+	if state := t.NegTokenResp.State(); state != spnego.NegStateAcceptCompleted {
+		return true, nil, fmt.Errorf("kerberos: the server did not accept the ticket (negotiation state %d)", state)
+	}
+	return true, nil, nil
+}
+
+// configPath returns the first file in $KRB5_CONFIG, a list like $PATH, or
+// the default.
+func configPath() string {
+	if env := os.Getenv("KRB5_CONFIG"); env != "" {
+		return filepath.SplitList(env)[0]
+	}
+	return defaultConfigPath
+}
+
+// ccachePath resolves $KRB5CCNAME the way MIT Kerberos does for file caches,
+// defaulting to /tmp/krb5cc_<uid>. Other cache types live outside the file
+// system and cannot be read.
+func ccachePath() (string, error) {
+	name := os.Getenv("KRB5CCNAME")
+	if name == "" {
+		u, err := user.Current()
+		if err != nil {
+			return "", fmt.Errorf("kerberos: cannot locate the credential cache; set $KRB5CCNAME: %w", err)
+		}
+		return "/tmp/krb5cc_" + u.Uid, nil
+	}
+	kind, path, ok := strings.Cut(name, ":")
+	switch {
+	case !ok, len(kind) == 1: // a bare path, or one with a Windows drive letter
+		return name, nil
+	case kind == "FILE":
+		return path, nil
+	default:
+		return "", fmt.Errorf("kerberos: credential cache type %s is not supported; "+
+			"point $KRB5CCNAME at a file (kinit -c FILE:/tmp/krb5cc_pgmi)", kind)
+	}
+}
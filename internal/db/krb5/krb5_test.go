@@ -0,0 +1,201 @@
+package krb5
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jcmturner/gofork/encoding/asn1"
+	"github.com/jcmturner/gokrb5/v8/iana/etypeID"
+	"github.com/jcmturner/gokrb5/v8/iana/nametype"
+	"github.com/jcmturner/gokrb5/v8/keytab"
+	"github.com/jcmturner/gokrb5/v8/messages"
+	"github.com/jcmturner/gokrb5/v8/service"
+	"github.com/jcmturner/gokrb5/v8/spnego"
+	"github.com/jcmturner/gokrb5/v8/types"
+)
+
+const (
+	realm   = "EXAMPLE.COM"
+	krb5Cfg = `[libdefaults]
+  default_realm = EXAMPLE.COM
+[realms]
+  EXAMPLE.COM = {
+    kdc = kdc.example.com:88
+  }
+`
+)
+
+// credential is one ticket for the credential cache writeCCache produces.
+type credential struct {
+	server  types.PrincipalName
+	key     types.EncryptionKey
+	ticket  messages.Ticket
+	endTime time.Time
+}
+
+// issue mints a ticket for server as a KDC would, encrypted with the key kt
+// holds for it.
+func issue(t *testing.T, kt *keytab.Keytab, client, server types.PrincipalName, lifetime time.Duration) credential {
+	t.Helper()
+	now := time.Now().UTC().Truncate(time.Second)
+	end := now.Add(lifetime)
+	tkt, key, err := messages.NewTicket(client, realm, server, realm, types.NewKrbFlags(), kt,
+		etypeID.AES256_CTS_HMAC_SHA1_96, 1, now, now, end, end)
+	if err != nil {
+		t.Fatalf("NewTicket(%s): %v", server.PrincipalNameString(), err)
+	}
+	return credential{server: server, key: key, ticket: tkt, endTime: end}
+}
+
+// writeCCache writes creds for client as an MIT version 4 credential cache,
+// the file kinit leaves behind.
+func writeCCache(t *testing.T, path string, client types.PrincipalName, creds ...credential) {
+	t.Helper()
+	var b bytes.Buffer
+	put := func(v any) { _ = binary.Write(&b, binary.BigEndian, v) }
+	data := func(d []byte) { put(uint32(len(d))); b.Write(d) }
+	principal := func(p types.PrincipalName) {
+		put(uint32(p.NameType))
+		put(uint32(len(p.NameString)))
+		data([]byte(realm))
+		for _, s := range p.NameString {
+			data([]byte(s))
+		}
+	}
+
+	b.Write([]byte{5, 4})
+	put(uint16(0)) // no header fields
+	principal(client)
+	for _, c := range creds {
+		tkt, err := c.ticket.Marshal()
+		if err != nil {
+			t.Fatal(err)
+		}
+		principal(client)
+		principal(c.server)
+		put(uint16(c.key.KeyType))
+		data(c.key.KeyValue)
+		start := uint32(c.endTime.Add(-time.Hour).Unix())
+		put(start)                    // authtime
+		put(start)                    // starttime
+		put(uint32(c.endTime.Unix())) // endtime
+		put(uint32(c.endTime.Unix())) // renew till
+		put(uint8(0))                 // is_skey
+		put(uint32(0))                // flags
+		put(uint32(0))                // addresses
+		put(uint32(0))                // authdata
+		data(tkt)
+		data(nil) // second ticket
+	}
+	if err := os.WriteFile(path, b.Bytes(), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// setup writes krb5.conf and a credential cache for deploy@EXAMPLE.COM
+// holding a TGT and a ticket for postgres/db.example.com, and returns the
+// keytab the postgres service would accept that ticket with.
+func setup(t *testing.T, lifetime time.Duration) *keytab.Keytab {
+	t.Helper()
+	dir := t.TempDir()
+	cfgPath := filepath.Join(dir, "krb5.conf")
+	if err := os.WriteFile(cfgPath, []byte(krb5Cfg), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	kdc, svc := keytab.New(), keytab.New()
+	ts := time.Now()
+	if err := kdc.AddEntry("krbtgt/"+realm, realm, "kdc-secret", ts, 1, etypeID.AES256_CTS_HMAC_SHA1_96); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.AddEntry("postgres/db.example.com", realm, "svc-secret", ts, 1, etypeID.AES256_CTS_HMAC_SHA1_96); err != nil {
+		t.Fatal(err)
+	}
+
+	user := types.NewPrincipalName(nametype.KRB_NT_PRINCIPAL, "deploy")
+	ccPath := filepath.Join(dir, "krb5cc")
+	writeCCache(t, ccPath, user,
+		issue(t, kdc, user, types.NewPrincipalName(nametype.KRB_NT_SRV_INST, "krbtgt/"+realm), lifetime),
+		issue(t, svc, user, types.NewPrincipalName(nametype.KRB_NT_SRV_HST, "postgres/db.example.com"), lifetime))
+
+	t.Setenv("KRB5_CONFIG", cfgPath)
+	t.Setenv("KRB5CCNAME", "FILE:"+ccPath)
+	return svc
+}
+
+func TestGSS_TicketAcceptedByService(t *testing.T) {
+	svc := setup(t, time.Hour)
+
+	gss, err := NewGSS()
+	if err != nil {
+		t.Fatalf("NewGSS: %v", err)
+	}
+	token, err := gss.GetInitToken("db.example.com", "postgres")
+	if err != nil {
+		t.Fatalf("GetInitToken: %v", err)
+	}
+
+	var st spnego.SPNEGOToken
+	if err := st.Unmarshal(token); err != nil {
+		t.Fatalf("token is not SPNEGO: %v", err)
+	}
+	ok, _, status := spnego.SPNEGOService(svc, service.DecodePAC(false)).AcceptSecContext(&st)
+	if !ok {
+		t.Fatalf("service rejected the token: %v", status)
+	}
+
+	accepted, err := (&spnego.NegTokenResp{NegState: asn1.Enumerated(spnego.NegStateAcceptCompleted)}).Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if done, out, err := gss.Continue(accepted); !done || out != nil || err != nil {
+		t.Errorf("Continue(accepted) = %v, %v, %v; want done", done, out, err)
+	}
+
+	rejected, err := (&spnego.NegTokenResp{NegState: asn1.Enumerated(spnego.NegStateReject)}).Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := gss.Continue(rejected); err == nil {
+		t.Error("Continue(rejected) succeeded")
+	}
+}
+
+func TestNewGSS_NoTicket(t *testing.T) {
+	setup(t, time.Hour)
+	missing := filepath.Join(t.TempDir(), "krb5cc_missing")
+	t.Setenv("KRB5CCNAME", missing)
+	_, err := NewGSS()
+	if err == nil || !strings.Contains(err.Error(), "kinit") || !strings.Contains(err.Error(), missing) {
+		t.Errorf("NewGSS without a cache = %v, want an error naming the cache and kinit", err)
+	}
+}
+
+func TestNewGSS_ExpiredTicket(t *testing.T) {
+	setup(t, -time.Minute)
+	if _, err := NewGSS(); err == nil || !strings.Contains(err.Error(), "kinit") {
+		t.Errorf("NewGSS with an expired TGT = %v, want an error saying to run kinit", err)
+	}
+}
+
+func TestCCachePath(t *testing.T) {
+	for name, want := range map[string]string{
+		"FILE:/tmp/krb5cc_1000": "/tmp/krb5cc_1000",
+		"/tmp/krb5cc_1000":      "/tmp/krb5cc_1000",
+		`C:\krb5cc`:             `C:\krb5cc`,
+	} {
+		t.Setenv("KRB5CCNAME", name)
+		if got, err := ccachePath(); err != nil || got != want {
+			t.Errorf("KRB5CCNAME=%s: ccachePath() = %q, %v; want %q", name, got, err, want)
+		}
+	}
+	t.Setenv("KRB5CCNAME", "KEYRING:persistent:1000")
+	if _, err := ccachePath(); err == nil || !strings.Contains(err.Error(), "KEYRING") {
+		t.Errorf("KEYRING cache: ccachePath() error = %v, want one naming the type", err)
+	}
+}
@@ -54,7 +54,7 @@ func (m *Manager) Settings(ctx context.Context, conn pgmi.DBConnection, dbName s
 		       pg_get_userbyid(d.datdba), d.datconnlimit,
 		       coalesce((SELECT s.setconfig FROM pg_db_role_setting s
 		                 WHERE s.setdatabase = d.oid AND s.setrole = 0), '{}'),
-		       shobj_description(d.oid, 'pg_database'),
+		       shobj_description(d.oid, 'pg_database'), d.datistemplate,
 		       pg_encoding_to_char(t.encoding), t.datcollate, t.datctype
 		FROM pg_database d, pg_database t
 		WHERE d.datname = $1 AND t.datname = 'template1'`
@@ -62,7 +62,7 @@ func (m *Manager) Settings(ctx context.Context, conn pgmi.DBConnection, dbName s
 	var s, def pgmi.DatabaseSettings
 	err := conn.QueryRow(ctx, q, dbName).Scan(
 		&s.Encoding, &s.Collate, &s.CType,
-		&s.Owner, &s.ConnectionLimit, &s.Options, &s.Comment,
+		&s.Owner, &s.ConnectionLimit, &s.Options, &s.Comment, &s.IsTemplate,
 		&def.Encoding, &def.Collate, &def.CType)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
@@ -85,7 +85,11 @@ func (m *Manager) Create(ctx context.Context, conn pgmi.DBConnection, dbName str
 	ident := pgx.Identifier{dbName}.Sanitize()
 	query := "CREATE DATABASE " + ident
 	if settings != nil {
-		if settings.PreserveLocale {
+		if settings.Template != "" {
+			// A clone carries its template's encoding and locale; naming them
+			// as well would only let PostgreSQL reject a mismatch.
+			query += " TEMPLATE " + pgx.Identifier{settings.Template}.Sanitize()
+		} else if settings.PreserveLocale {
 			// template0 is mandatory, not a preference: PostgreSQL refuses a
 			// non-default encoding or locale copied from template1, because
 			// whatever a site installed there may not survive the conversion.
@@ -163,5 +167,29 @@ func (m *Manager) TerminateConnections(ctx context.Context, conn pgmi.DBConnecti
 	return nil
 }
 
+// SetTemplate sets or clears IS_TEMPLATE on the specified database. PostgreSQL
+// refuses to drop a template, so a rebuild clears the flag first.
+func (m *Manager) SetTemplate(ctx context.Context, conn pgmi.DBConnection, dbName string, isTemplate bool) error {
+	query := fmt.Sprintf("ALTER DATABASE %s WITH IS_TEMPLATE %t", pgx.Identifier{dbName}.Sanitize(), isTemplate)
+	if _, err := conn.Exec(ctx, query); err != nil {
+		return fmt.Errorf("failed to set IS_TEMPLATE %t on database %q: %w", isTemplate, dbName, err)
+	}
+	return nil
+}
+
+// ListByComment returns, sorted, the databases whose comment starts with prefix.
+// The names come back as one array so the lookup fits DBConnection.QueryRow.
+func (m *Manager) ListByComment(ctx context.Context, conn pgmi.DBConnection, prefix string) ([]string, error) {
+	const q = `
+		SELECT coalesce(array_agg(datname ORDER BY datname), '{}')
+		FROM pg_database
+		WHERE starts_with(coalesce(shobj_description(oid, 'pg_database'), ''), $1)`
+	var names []string
+	if err := conn.QueryRow(ctx, q, prefix).Scan(&names); err != nil {
+		return nil, fmt.Errorf("failed to list databases commented %q: %w", prefix, err)
+	}
+	return names, nil
+}
+
 // Verify Manager implements the DatabaseManager interface at compile time
 var _ pgmi.DatabaseManager = (*Manager)(nil)
@@ -373,3 +373,100 @@ func TestManager_Drop_ConnectionAcquireFailure(t *testing.T) {
 		t.Errorf("Expected wrapped error, got: %v", err)
 	}
 }
+
+func TestManager_Create_FromTemplate(t *testing.T) {
+	ctx := context.Background()
+	mgr := manager.New()
+
+	var executedSQL []string
+	mockConn := &mockDBConnection{
+		acquireFunc: func(ctx context.Context) (pgmi.PooledConnection, error) {
+			return &mockPooledConnection{
+				execFunc: func(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+					executedSQL = append(executedSQL, sql)
+					return pgconn.CommandTag{}, nil
+				},
+			}, nil
+		},
+	}
+
+	// PreserveLocale alongside Template must not add template0: the clone
+	// takes its locale from the template it copies.
+	settings := &pgmi.DatabaseSettings{
+		Template:        `app"tpl`,
+		ConnectionLimit: -1,
+		PreserveLocale:  true,
+		Encoding:        "LATIN1",
+	}
+	if err := mgr.Create(ctx, mockConn, "job_1", settings); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	want := `CREATE DATABASE "job_1" TEMPLATE "app""tpl"`
+	if len(executedSQL) != 1 || executedSQL[0] != want {
+		t.Errorf("executed %q, want [%q]", executedSQL, want)
+	}
+}
+
+func TestManager_SetTemplate(t *testing.T) {
+	for _, tc := range []struct {
+		isTemplate bool
+		want       string
+	}{
+		{true, `ALTER DATABASE "app_tpl" WITH IS_TEMPLATE true`},
+		{false, `ALTER DATABASE "app_tpl" WITH IS_TEMPLATE false`},
+	} {
+		var executedSQL string
+		mockConn := &mockDBConnection{
+			execFunc: func(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+				executedSQL = sql
+				return pgconn.CommandTag{}, nil
+			},
+		}
+		if err := (&manager.Manager{}).SetTemplate(context.Background(), mockConn, "app_tpl", tc.isTemplate); err != nil {
+			t.Fatalf("SetTemplate(%t) failed: %v", tc.isTemplate, err)
+		}
+		if executedSQL != tc.want {
+			t.Errorf("SetTemplate(%t) executed %q, want %q", tc.isTemplate, executedSQL, tc.want)
+		}
+	}
+}
+
+func TestManager_SetTemplate_NamesTheDatabase(t *testing.T) {
+	expectedErr := errors.New("must be owner")
+	mockConn := &mockDBConnection{
+		execFunc: func(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+			return pgconn.CommandTag{}, expectedErr
+		},
+	}
+	err := (&manager.Manager{}).SetTemplate(context.Background(), mockConn, "app_tpl", true)
+	if !errors.Is(err, expectedErr) {
+		t.Fatalf("expected wrapped error, got: %v", err)
+	}
+	if !strings.Contains(err.Error(), `"app_tpl"`) {
+		t.Errorf("error should name the database: %v", err)
+	}
+}
+
+func TestManager_ListByComment(t *testing.T) {
+	var gotPrefix any
+	mockConn := &mockDBConnection{
+		queryRowFunc: func(ctx context.Context, sql string, args ...any) pgmi.Row {
+			gotPrefix = args[0]
+			return &mockRow{scanFunc: func(dest ...any) error {
+				*dest[0].(*[]string) = []string{"pr_1", "pr_2"}
+				return nil
+			}}
+		},
+	}
+	names, err := (&manager.Manager{}).ListByComment(context.Background(), mockConn, "pgmi ephemeral ")
+	if err != nil {
+		t.Fatalf("ListByComment failed: %v", err)
+	}
+	if gotPrefix != "pgmi ephemeral " {
+		t.Errorf("prefix passed as %v; it must be a parameter, not spliced into the SQL", gotPrefix)
+	}
+	if strings.Join(names, ",") != "pr_1,pr_2" {
+		t.Errorf("names = %v", names)
+	}
+}
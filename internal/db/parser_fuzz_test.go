@@ -17,6 +17,8 @@ func FuzzParseConnectionString(f *testing.F) {
 	f.Add("postgresql://user@localhost:5432/db?application_name=pgmi")
 	f.Add("postgresql://user@localhost:5432/db?sslcert=/path/client.crt&sslkey=/path/client.key&sslrootcert=/path/ca.crt&sslpassword=keypass")
 	f.Add("Host=localhost;Database=db;sslcert=/path/client.crt;sslkey=/path/client.key;sslrootcert=/path/ca.crt")
+	f.Add("host=localhost dbname=db user=user password='p a\\'ss'")
+	f.Add("service=prod")
 
 	// Seed with edge cases
 	f.Add("")
@@ -1,8 +1,11 @@
 package db
 
 import (
+	"reflect"
 	"testing"
 
+	"github.com/jackc/pgx/v5/pgconn"
+
 	"github.com/vvka-141/pgmi/pkg/pgmi"
 )
 
@@ -379,3 +382,250 @@ func compareConfigs(t *testing.T, got, want *pgmi.ConnectionConfig) {
 		t.Errorf("SSLPassword = %v, want %v", got.SSLPassword, want.SSLPassword)
 	}
 }
+
+func TestParseConnectionString_KerberosAndCertAuth(t *testing.T) {
+	tests := []struct {
+		name        string
+		connStr     string
+		wantMethod  pgmi.AuthMethod
+		wantService string
+		wantSPN     string
+		wantParam   string // require_auth left for pgx to enforce
+	}{
+		{
+			name:        "URI with require_auth=gss",
+			connStr:     "postgresql://deploy@db.example.com/app?require_auth=gss&krbsrvname=pg",
+			wantMethod:  pgmi.AuthMethodGSSAPI,
+			wantService: "pg",
+		},
+		{
+			name:       "URI with an SPN",
+			connStr:    "postgresql://deploy@db.example.com/app?require_auth=gss&krbspn=postgres/db.example.com@EXAMPLE.COM",
+			wantMethod: pgmi.AuthMethodGSSAPI,
+			wantSPN:    "postgres/db.example.com@EXAMPLE.COM",
+		},
+		{
+			name:       "URI with require_auth=none and a certificate",
+			connStr:    "postgresql://deploy@db.example.com/app?sslmode=verify-full&sslcert=/c.crt&sslkey=/c.key&require_auth=none",
+			wantMethod: pgmi.AuthMethodCert,
+		},
+		{
+			name:       "require_auth=none without a certificate is not cert auth",
+			connStr:    "postgresql://deploy@localhost/app?require_auth=none",
+			wantMethod: pgmi.AuthMethodStandard,
+			wantParam:  "none",
+		},
+		{
+			name:       "other require_auth values are passed through",
+			connStr:    "postgresql://deploy@localhost/app?require_auth=scram-sha-256",
+			wantMethod: pgmi.AuthMethodStandard,
+			wantParam:  "scram-sha-256",
+		},
+		{
+			name:        "ADO.NET with Integrated Security",
+			connStr:     "Host=db.example.com;Database=app;Username=deploy;Integrated Security=true;Kerberos Service Name=pg",
+			wantMethod:  pgmi.AuthMethodGSSAPI,
+			wantService: "pg",
+		},
+		{
+			name:       "ADO.NET with Integrated Security=false",
+			connStr:    "Host=db.example.com;Database=app;Username=deploy;Integrated Security=false",
+			wantMethod: pgmi.AuthMethodStandard,
+		},
+		{
+			name:       "ADO.NET with Require Auth=none and a certificate",
+			connStr:    "Host=db.example.com;Database=app;Username=deploy;SSL Cert=/c.crt;SSL Key=/c.key;Require Auth=none",
+			wantMethod: pgmi.AuthMethodCert,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseConnectionString(tt.connStr)
+			if err != nil {
+				t.Fatalf("ParseConnectionString: %v", err)
+			}
+			if got.AuthMethod != tt.wantMethod {
+				t.Errorf("AuthMethod = %v, want %v", got.AuthMethod, tt.wantMethod)
+			}
+			if got.KerberosServiceName != tt.wantService {
+				t.Errorf("KerberosServiceName = %q, want %q", got.KerberosServiceName, tt.wantService)
+			}
+			if got.KerberosSPN != tt.wantSPN {
+				t.Errorf("KerberosSPN = %q, want %q", got.KerberosSPN, tt.wantSPN)
+			}
+			if param := got.AdditionalParams["require_auth"]; param != tt.wantParam {
+				t.Errorf("require_auth param = %q, want %q", param, tt.wantParam)
+			}
+		})
+	}
+}
+
+func TestBuildConnectionString_KerberosAndCertAuth(t *testing.T) {
+	for _, config := range []*pgmi.ConnectionConfig{
+		{
+			Host: "db.example.com", Port: 5432, Database: "app", Username: "deploy",
+			AuthMethod: pgmi.AuthMethodGSSAPI, KerberosServiceName: "pg",
+		},
+		{
+			Host: "db.example.com", Port: 5432, Database: "app", Username: "deploy",
+			AuthMethod: pgmi.AuthMethodGSSAPI, KerberosSPN: "postgres/db.example.com@EXAMPLE.COM",
+		},
+		{
+			Host: "db.example.com", Port: 5432, Database: "app", Username: "deploy", SSLMode: "require",
+			AuthMethod: pgmi.AuthMethodCert, SSLCert: "/c.crt", SSLKey: "/c.key",
+		},
+	} {
+		parsed, err := ParseConnectionString(BuildConnectionString(config))
+		if err != nil {
+			t.Fatalf("%v: BuildConnectionString() produced invalid string: %v", config.AuthMethod, err)
+		}
+		compareConfigs(t, parsed, config)
+		if parsed.AuthMethod != config.AuthMethod {
+			t.Errorf("AuthMethod = %v, want %v", parsed.AuthMethod, config.AuthMethod)
+		}
+		if parsed.KerberosServiceName != config.KerberosServiceName || parsed.KerberosSPN != config.KerberosSPN {
+			t.Errorf("Kerberos = %q/%q, want %q/%q", parsed.KerberosServiceName, parsed.KerberosSPN,
+				config.KerberosServiceName, config.KerberosSPN)
+		}
+	}
+}
+
+func TestParseConnectionString_KeywordValue(t *testing.T) {
+	got, err := ParseConnectionString(`host=db.example.com port = 5433 dbname=app user=deployer password='p a\'ss' sslmode=require application_name=ci`)
+	if err != nil {
+		t.Fatalf("ParseConnectionString: %v", err)
+	}
+	compareConfigs(t, got, &pgmi.ConnectionConfig{
+		Host:     "db.example.com",
+		Port:     5433,
+		Database: "app",
+		Username: "deployer",
+		Password: "p a'ss",
+		SSLMode:  "require",
+		AppName:  "ci",
+	})
+
+	got, err = ParseConnectionString("service=prod servicefile=/etc/pgmi/services.conf")
+	if err != nil {
+		t.Fatalf("ParseConnectionString: %v", err)
+	}
+	if got.Service != "prod" || got.ServiceFile != "/etc/pgmi/services.conf" {
+		t.Errorf("Service = %q, ServiceFile = %q; want prod and /etc/pgmi/services.conf", got.Service, got.ServiceFile)
+	}
+	if got.Host != "localhost" || got.Port != 5432 {
+		t.Errorf("a service is not looked up by ParseConnectionString: got %s:%d", got.Host, got.Port)
+	}
+
+	for _, connStr := range []string{
+		"host=db port=abc",
+		"host=db =5432",
+		"host=db port",
+		"password='unterminated",
+	} {
+		if _, err := ParseConnectionString(connStr); err == nil {
+			t.Errorf("ParseConnectionString(%q): expected an error", connStr)
+		}
+	}
+}
+
+func TestBuildConnectionString_Service(t *testing.T) {
+	config := &pgmi.ConnectionConfig{
+		Host:        "db1.corp.example.com",
+		Port:        5433,
+		Database:    "postgres",
+		Username:    "deployer",
+		SSLMode:     "verify-full",
+		Service:     "prod",
+		ServiceFile: "/home/ci/.pg_service.conf",
+	}
+
+	got, err := ParseConnectionString(BuildConnectionString(config))
+	if err != nil {
+		t.Fatalf("ParseConnectionString: %v", err)
+	}
+	compareConfigs(t, got, config)
+	if got.Service != config.Service || got.ServiceFile != config.ServiceFile {
+		t.Errorf("Service = %q from %q, want %q from %q", got.Service, got.ServiceFile, config.Service, config.ServiceFile)
+	}
+	if len(got.AdditionalParams) != 0 {
+		t.Errorf("AdditionalParams = %v, want none", got.AdditionalParams)
+	}
+}
+
+func TestParseConnectionString_MultiHost(t *testing.T) {
+	want := []pgmi.HostPort{{Host: "db1", Port: 5432}, {Host: "db2", Port: 5433}, {Host: "db3", Port: 5432}}
+	for _, connStr := range []string{
+		"postgresql://app@db1:5432,db2:5433,db3/app?target_session_attrs=read-write",
+		"postgresql://app@db1,db2:5433,db3:5432/app?target_session_attrs=read-write",
+		"host=db1,db2,db3 port=5432,5433,5432 user=app dbname=app target_session_attrs=read-write",
+		"port=5432,5433, host=db1,db2,db3 user=app dbname=app target_session_attrs=read-write",
+		"Host=db1,db2:5433,db3;Username=app;Database=app;Target Session Attributes=ReadWrite",
+	} {
+		got, err := ParseConnectionString(connStr)
+		if err != nil {
+			t.Fatalf("%s: %v", connStr, err)
+		}
+		if hosts := got.Hosts(); !reflect.DeepEqual(hosts, want) {
+			t.Errorf("%s: hosts = %v, want %v", connStr, hosts, want)
+		}
+		if got.TargetSessionAttrs != "read-write" || got.Database != "app" {
+			t.Errorf("%s: got %s with target_session_attrs %q", connStr, got, got.TargetSessionAttrs)
+		}
+	}
+
+	// One port applies to every host.
+	got, err := ParseConnectionString("host=db1,db2 port=6432")
+	if err != nil {
+		t.Fatalf("single port: %v", err)
+	}
+	if hosts := got.Hosts(); !reflect.DeepEqual(hosts, []pgmi.HostPort{{Host: "db1", Port: 6432}, {Host: "db2", Port: 6432}}) {
+		t.Errorf("single port: hosts = %v", hosts)
+	}
+
+	for _, connStr := range []string{
+		"host=db1,db2 port=5432,5433,5434",
+		"host=db1 target_session_attrs=primary-ish",
+		"postgresql://db1:5432,db2:nope/app",
+		"Host=db1,db2;Target Session Attributes=writable",
+	} {
+		if _, err := ParseConnectionString(connStr); err == nil {
+			t.Errorf("ParseConnectionString(%q): expected an error", connStr)
+		}
+	}
+}
+
+func TestBuildConnectionString_MultiHost(t *testing.T) {
+	config := &pgmi.ConnectionConfig{
+		Host:               "db1",
+		Port:               5432,
+		FallbackHosts:      []pgmi.HostPort{{Host: "db2", Port: 5433}, {Host: "::1"}},
+		TargetSessionAttrs: "read-write",
+		Database:           "app",
+		Username:           "deployer",
+		SSLMode:            "disable",
+	}
+
+	connStr := BuildConnectionString(config)
+	got, err := ParseConnectionString(connStr)
+	if err != nil {
+		t.Fatalf("ParseConnectionString(%q): %v", connStr, err)
+	}
+	compareConfigs(t, got, config)
+	if !reflect.DeepEqual(got.Hosts(), config.Hosts()) || got.TargetSessionAttrs != "read-write" {
+		t.Errorf("%s: hosts %v with %q, want %v with read-write", connStr, got.Hosts(), got.TargetSessionAttrs, config.Hosts())
+	}
+
+	// pgx must see the same servers, in the same order.
+	pgxConfig, err := pgconn.ParseConfig(connStr)
+	if err != nil {
+		t.Fatalf("pgconn.ParseConfig(%q): %v", connStr, err)
+	}
+	pgxHosts := []pgmi.HostPort{{Host: pgxConfig.Host, Port: int(pgxConfig.Port)}}
+	for _, fb := range pgxConfig.Fallbacks {
+		pgxHosts = append(pgxHosts, pgmi.HostPort{Host: fb.Host, Port: int(fb.Port)})
+	}
+	if !reflect.DeepEqual(pgxHosts, config.Hosts()) {
+		t.Errorf("pgx hosts = %v, want %v", pgxHosts, config.Hosts())
+	}
+}
@@ -0,0 +1,57 @@
+package db
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/vvka-141/pgmi/pkg/pgmi"
+)
+
+// PoolConfigConnector opens pgmi's pool from the configuration of a pool the
+// caller already has: the same host, TLS settings, credentials, BeforeConnect
+// hook and dialer, pointed at another database when asked.
+//
+// The caller's pool is never used or closed. A deploy session leaves temp
+// objects and session settings on its connection, which must not go back into
+// an application's pool, and notices can only be routed when a pool is built.
+type PoolConfigConnector struct {
+	poolHooks
+	base     *pgxpool.Config
+	database string
+}
+
+// NewPoolConfigConnector creates a connector that opens a copy of base on
+// database, or on base's own database when database is empty.
+func NewPoolConfigConnector(base *pgxpool.Config, database string) *PoolConfigConnector {
+	return &PoolConfigConnector{base: base, database: database}
+}
+
+// NewPoolConfigConnectorWithHooks is NewPoolConfigConnector with hooks
+// applied to the pool it opens.
+func NewPoolConfigConnectorWithHooks(base *pgxpool.Config, database string, hooks PoolHooks) *PoolConfigConnector {
+	c := NewPoolConfigConnector(base, database)
+	c.setHooks(hooks)
+	return c
+}
+
+// Connect opens and pings a new pool. base is copied, not modified.
+func (c *PoolConfigConnector) Connect(ctx context.Context) (*pgxpool.Pool, error) {
+	poolConfig := c.base.Copy()
+	if c.database != "" {
+		poolConfig.ConnConfig.Database = c.database
+	}
+	c.configurePool(poolConfig)
+
+	cc := poolConfig.ConnConfig
+	pool, err := pgxpool.NewWithConfig(ctx, poolConfig)
+	if err != nil {
+		return nil, wrapConnectionError(err, cc.Host, int(cc.Port), cc.Database, cc.User)
+	}
+	if err := pool.Ping(ctx); err != nil {
+		pool.Close()
+		return nil, wrapConnectionError(err, cc.Host, int(cc.Port), cc.Database, cc.User)
+	}
+	return pool, nil
+}
+
+var _ pgmi.Connector = (*PoolConfigConnector)(nil)
@@ -2,8 +2,10 @@ package db
 
 import (
 	"fmt"
+	"maps"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/vvka-141/pgmi/internal/config"
@@ -24,6 +26,7 @@ type GranularConnFlags struct {
 	Username string
 	Database string
 	SSLMode  string
+	Service  string // --service: a pg_service.conf entry, overriding $PGSERVICE
 }
 
 // AzureFlags represents Azure Entra ID CLI flags.
@@ -64,6 +67,45 @@ func (g *GoogleFlags) IsEmpty() bool {
 	return g == nil || (!g.Enabled && g.Instance == "")
 }
 
+// TokenFlags represents the external token CLI flags. Each selects its auth
+// method, as --aws or --azure do, and outranks the matching PGMI_TOKEN_*
+// environment variable and pgmi.yaml key.
+type TokenFlags struct {
+	Command string // --token-command: program and arguments, separated by spaces
+	File    string // --token-file
+}
+
+// IsEmpty returns true if no token flags were provided.
+func (t *TokenFlags) IsEmpty() bool {
+	return t == nil || (t.Command == "" && t.File == "")
+}
+
+// KerberosFlags represents the GSSAPI CLI flags.
+type KerberosFlags struct {
+	Enabled     bool   // --gssapi
+	ServiceName string // --krbsrvname: overrides $PGKRBSRVNAME
+	SPN         string // --krbspn
+}
+
+// IsEmpty returns true if no Kerberos flags were provided.
+func (k *KerberosFlags) IsEmpty() bool {
+	return k == nil || (!k.Enabled && k.ServiceName == "" && k.SPN == "")
+}
+
+// SSHFlags represents the SSH tunnel CLI flags.
+type SSHFlags struct {
+	Host       string // --ssh-host
+	Port       int    // --ssh-port
+	User       string // --ssh-user
+	KeyFile    string // --ssh-key
+	KnownHosts string // --ssh-known-hosts
+}
+
+// IsEmpty returns true if no SSH flags were provided.
+func (s *SSHFlags) IsEmpty() bool {
+	return s == nil || (s.Host == "" && s.Port == 0 && s.User == "" && s.KeyFile == "" && s.KnownHosts == "")
+}
+
 // CertFlags represents TLS client certificate CLI flags.
 // These are additive — they can be combined with --connection or granular flags.
 type CertFlags struct {
@@ -81,21 +123,27 @@ func (c *CertFlags) IsEmpty() bool {
 // Note: Database flag is excluded from this check because it can be used to override
 // the database specified in a connection string.
 func (g *GranularConnFlags) IsEmpty() bool {
-	return g.Host == "" && g.Port == 0 && g.Username == "" && g.SSLMode == ""
+	return g.Host == "" && g.Port == 0 && g.Username == "" && g.SSLMode == "" && g.Service == ""
 }
 
 // EnvVars represents PostgreSQL standard environment variables.
 // See: https://www.postgresql.org/docs/current/libpq-envars.html
 type EnvVars struct {
-	PGHOST            string // PostgreSQL server host
-	PGPORT            string // PostgreSQL server port
-	PGUSER            string // PostgreSQL username
-	PGPASSWORD        string // PostgreSQL password (discouraged, use .pgpass instead)
-	PGDATABASE        string // Default database name
-	PGSSLMODE         string // SSL mode
-	PGAPPNAME         string // application_name reported in pg_stat_activity
-	PGCONNECT_TIMEOUT string // Connection timeout in seconds (libpq convention)
-	DATABASE_URL      string // Full connection string (Heroku/Rails convention)
+	PGHOST               string // PostgreSQL server host
+	PGPORT               string // PostgreSQL server port
+	PGUSER               string // PostgreSQL username
+	PGPASSWORD           string // PostgreSQL password (discouraged, use .pgpass instead)
+	PGDATABASE           string // Default database name
+	PGSSLMODE            string // SSL mode
+	PGAPPNAME            string // application_name reported in pg_stat_activity
+	PGCONNECT_TIMEOUT    string // Connection timeout in seconds (libpq convention)
+	PGTARGETSESSIONATTRS string // Which of several hosts to settle on (read-write, ...)
+	DATABASE_URL         string // Full connection string (Heroku/Rails convention)
+
+	// Service file environment variables (PostgreSQL standard)
+	PGSERVICE     string // pg_service.conf entry to take parameters from
+	PGSERVICEFILE string // User's service file, instead of ~/.pg_service.conf
+	PGSYSCONFDIR  string // Directory holding the system-wide pg_service.conf
 
 	// Azure Entra ID environment variables (Azure SDK standard names)
 	AZURE_TENANT_ID     string // Azure AD tenant/directory ID
@@ -106,35 +154,51 @@ type EnvVars struct {
 	AWS_REGION         string // AWS region for RDS IAM auth
 	AWS_DEFAULT_REGION string // Fallback region (AWS SDK convention)
 
+	// External token environment variables
+	PGMI_TOKEN_COMMAND string // Token command, arguments separated by spaces
+	PGMI_TOKEN_FILE    string // Token file path
+
 	// TLS client certificate environment variables (PostgreSQL standard)
 	PGSSLCERT     string // Client certificate path
 	PGSSLKEY      string // Client key path
 	PGSSLROOTCERT string // Root CA certificate path
 	PGSSLPASSWORD string // Client key password
+
+	// Server-side auth environment variables (PostgreSQL standard)
+	PGKRBSRVNAME  string // Kerberos service name
+	PGREQUIREAUTH string // require_auth: gss selects GSSAPI, none certificate auth
 }
 
 // LoadFromEnvironment loads PostgreSQL and cloud provider environment variables.
 // This follows standard PostgreSQL client behavior and Azure/AWS SDK conventions.
 func LoadFromEnvironment() *EnvVars {
 	return &EnvVars{
-		PGHOST:              os.Getenv("PGHOST"),
-		PGPORT:              os.Getenv("PGPORT"),
-		PGUSER:              os.Getenv("PGUSER"),
-		PGPASSWORD:          os.Getenv("PGPASSWORD"),
-		PGDATABASE:          os.Getenv("PGDATABASE"),
-		PGSSLMODE:           os.Getenv("PGSSLMODE"),
-		PGAPPNAME:           os.Getenv("PGAPPNAME"),
-		PGCONNECT_TIMEOUT:   os.Getenv("PGCONNECT_TIMEOUT"),
-		DATABASE_URL:        os.Getenv("DATABASE_URL"),
-		AZURE_TENANT_ID:     os.Getenv("AZURE_TENANT_ID"),
-		AZURE_CLIENT_ID:     os.Getenv("AZURE_CLIENT_ID"),
-		AZURE_CLIENT_SECRET: os.Getenv("AZURE_CLIENT_SECRET"),
-		AWS_REGION:          os.Getenv("AWS_REGION"),
-		AWS_DEFAULT_REGION:  os.Getenv("AWS_DEFAULT_REGION"),
-		PGSSLCERT:           os.Getenv("PGSSLCERT"),
-		PGSSLKEY:            os.Getenv("PGSSLKEY"),
-		PGSSLROOTCERT:       os.Getenv("PGSSLROOTCERT"),
-		PGSSLPASSWORD:       os.Getenv("PGSSLPASSWORD"),
+		PGHOST:               os.Getenv("PGHOST"),
+		PGPORT:               os.Getenv("PGPORT"),
+		PGUSER:               os.Getenv("PGUSER"),
+		PGPASSWORD:           os.Getenv("PGPASSWORD"),
+		PGDATABASE:           os.Getenv("PGDATABASE"),
+		PGSSLMODE:            os.Getenv("PGSSLMODE"),
+		PGAPPNAME:            os.Getenv("PGAPPNAME"),
+		PGCONNECT_TIMEOUT:    os.Getenv("PGCONNECT_TIMEOUT"),
+		PGTARGETSESSIONATTRS: os.Getenv("PGTARGETSESSIONATTRS"),
+		DATABASE_URL:         os.Getenv("DATABASE_URL"),
+		PGSERVICE:            os.Getenv("PGSERVICE"),
+		PGSERVICEFILE:        os.Getenv("PGSERVICEFILE"),
+		PGSYSCONFDIR:         os.Getenv("PGSYSCONFDIR"),
+		AZURE_TENANT_ID:      os.Getenv("AZURE_TENANT_ID"),
+		AZURE_CLIENT_ID:      os.Getenv("AZURE_CLIENT_ID"),
+		AZURE_CLIENT_SECRET:  os.Getenv("AZURE_CLIENT_SECRET"),
+		AWS_REGION:           os.Getenv("AWS_REGION"),
+		AWS_DEFAULT_REGION:   os.Getenv("AWS_DEFAULT_REGION"),
+		PGMI_TOKEN_COMMAND:   os.Getenv("PGMI_TOKEN_COMMAND"),
+		PGMI_TOKEN_FILE:      os.Getenv("PGMI_TOKEN_FILE"),
+		PGSSLCERT:            os.Getenv("PGSSLCERT"),
+		PGSSLKEY:             os.Getenv("PGSSLKEY"),
+		PGSSLROOTCERT:        os.Getenv("PGSSLROOTCERT"),
+		PGSSLPASSWORD:        os.Getenv("PGSSLPASSWORD"),
+		PGKRBSRVNAME:         os.Getenv("PGKRBSRVNAME"),
+		PGREQUIREAUTH:        os.Getenv("PGREQUIREAUTH"),
 	}
 }
 
@@ -146,6 +210,10 @@ func LoadFromEnvironment() *EnvVars {
 // 4. DATABASE_URL environment variable - fallback if no granular params
 // 5. Defaults (localhost:5432, prefer SSL)
 //
+// A pg_service.conf service — named by the connection string's service
+// parameter, --service, $PGSERVICE or pgmi.yaml's service — ranks just below
+// what the connection string or the flags set, above environment variables.
+//
 // Cloud Authentication:
 // If azureFlags/awsFlags/googleFlags are provided OR corresponding environment variables are set,
 // the AuthMethod is set accordingly and credentials are attached to the config.
@@ -205,7 +273,7 @@ func ResolveConnectionParams(
 	// Check for conflicts: connection string XOR granular flags
 	if connStringFlag != "" && !granularFlags.IsEmpty() {
 		return nil, "", fmt.Errorf(
-			"cannot specify both --connection and granular flags (-h, -p, -U)\n"+
+			"cannot specify both --connection and granular flags (-h, -p, -U, --service)\n"+
 				"Choose one approach:\n"+
 				"  1. Connection string: --connection \"postgresql://user@localhost:5432/postgres\"\n"+
 				"  2. Granular flags: -h localhost -p 5432 -U myuser -d mydb\n"+
@@ -334,6 +402,193 @@ func applyGoogleAuth(cfg *pgmi.ConnectionConfig, flags *GoogleFlags, pc *config.
 	}
 }
 
+// ApplyTokenAuth sets exec or token_file authentication on a config
+// ResolveConnectionParams returned. A token flag selects its method over
+// whatever was resolved; pgmi.yaml's auth_method selects it only when no
+// cloud flag chose another. The command and file themselves follow
+// flag > PGMI_TOKEN_COMMAND/PGMI_TOKEN_FILE > pgmi.yaml.
+//
+// A command given as a flag or environment variable is split like a shell
+// would split it, quotes included, as splitCommandLine describes;
+// pgmi.yaml's token_command is already a list.
+func ApplyTokenAuth(cfg *pgmi.ConnectionConfig, flags *TokenFlags, env *EnvVars, pc *config.ProjectConfig) error {
+	if flags == nil {
+		flags = &TokenFlags{}
+	}
+	if env == nil {
+		env = &EnvVars{}
+	}
+	if flags.Command != "" && flags.File != "" {
+		return fmt.Errorf("cannot use both --token-command and --token-file: %w", pgmi.ErrInvalidConfig)
+	}
+
+	var yamlAuthMethod, yamlFile string
+	var yamlCommand []string
+	if pc != nil {
+		yamlAuthMethod = pc.Connection.AuthMethod
+		yamlCommand = pc.Connection.TokenCommand
+		yamlFile = pc.Connection.TokenFile
+	}
+
+	command, err := splitCommandLine(flags.Command)
+	if err != nil {
+		return fmt.Errorf("--token-command: %w", err)
+	}
+	if len(command) == 0 {
+		if command, err = splitCommandLine(env.PGMI_TOKEN_COMMAND); err != nil {
+			return fmt.Errorf("PGMI_TOKEN_COMMAND: %w", err)
+		}
+	}
+	if len(command) == 0 {
+		command = yamlCommand
+	}
+	file := flags.File
+	if file == "" {
+		file = env.PGMI_TOKEN_FILE
+	}
+	if file == "" {
+		file = yamlFile
+	}
+
+	fromYAML := flags.IsEmpty() && cfg.AuthMethod == pgmi.AuthMethodStandard
+	switch {
+	case flags.Command != "" || (fromYAML && yamlAuthMethod == "exec"):
+		cfg.AuthMethod = pgmi.AuthMethodExec
+		cfg.TokenCommand = command
+	case flags.File != "" || (fromYAML && yamlAuthMethod == "token_file"):
+		cfg.AuthMethod = pgmi.AuthMethodTokenFile
+		cfg.TokenFile = file
+	}
+	return nil
+}
+
+// splitCommandLine splits a command into its arguments the way a POSIX shell
+// would, without any expansion: whitespace separates arguments, single quotes
+// keep everything literal, and double quotes keep whitespace. A backslash
+// escapes only a quote, a backslash or whitespace — and inside double quotes
+// only a double quote or a backslash — so a Windows path such as
+// C:\tools\get-token.exe needs no doubling.
+func splitCommandLine(s string) ([]string, error) {
+	var args []string
+	var arg strings.Builder
+	inArg := false
+	var quote rune
+	runes := []rune(s)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case quote == '\'':
+			if r == '\'' {
+				quote = 0
+			} else {
+				arg.WriteRune(r)
+			}
+		case quote == '"':
+			switch {
+			case r == '"':
+				quote = 0
+			case r == '\\' && i+1 < len(runes) && (runes[i+1] == '"' || runes[i+1] == '\\'):
+				i++
+				arg.WriteRune(runes[i])
+			default:
+				arg.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inArg = true
+		case r == '\\' && i+1 < len(runes) && strings.ContainsRune("'\"\\ \t\n", runes[i+1]):
+			i++
+			arg.WriteRune(runes[i])
+			inArg = true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inArg {
+				args = append(args, arg.String())
+				arg.Reset()
+				inArg = false
+			}
+		default:
+			arg.WriteRune(r)
+			inArg = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated %c quote in %q: %w", quote, s, pgmi.ErrInvalidConfig)
+	}
+	if inArg {
+		args = append(args, arg.String())
+	}
+	return args, nil
+}
+
+// JoinCommandLine is the inverse of splitCommandLine: it renders args as one
+// string --token-command splits back into the same list.
+func JoinCommandLine(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		switch {
+		case arg != "" && !strings.ContainsAny(arg, "'\" \t\n\\"):
+			quoted[i] = arg
+		case !strings.Contains(arg, "'"):
+			quoted[i] = "'" + arg + "'"
+		default:
+			quoted[i] = `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(arg) + `"`
+		}
+	}
+	return strings.Join(quoted, " ")
+}
+
+// ApplyGSSAPIAndCertAuth sets GSSAPI or certificate-only authentication on a
+// config ResolveConnectionParams returned, after ApplyTokenAuth. --gssapi and
+// --cert-auth select their method over whatever was resolved. Otherwise a
+// method is chosen only for a config still on password auth, by
+// $PGREQUIREAUTH (gss, or none with a client certificate) and then
+// pgmi.yaml's auth_method; a connection string's require_auth has already
+// chosen one when parsed.
+//
+// The Kerberos service name follows flag > connection string > $PGKRBSRVNAME
+// > pgmi.yaml, libpq's order, as applyCertParams explains.
+func ApplyGSSAPIAndCertAuth(cfg *pgmi.ConnectionConfig, kerberos *KerberosFlags, certAuth bool, env *EnvVars, pc *config.ProjectConfig) error {
+	if kerberos == nil {
+		kerberos = &KerberosFlags{}
+	}
+	if env == nil {
+		env = &EnvVars{}
+	}
+	if !kerberos.IsEmpty() && certAuth {
+		return fmt.Errorf("cannot use both --gssapi and --cert-auth: %w", pgmi.ErrInvalidConfig)
+	}
+
+	var yamlAuthMethod, yamlServiceName, yamlSPN string
+	if pc != nil {
+		yamlAuthMethod = pc.Connection.AuthMethod
+		yamlServiceName = pc.Connection.KerberosServiceName
+		yamlSPN = pc.Connection.KerberosSPN
+	}
+
+	switch {
+	case kerberos.Enabled:
+		cfg.AuthMethod = pgmi.AuthMethodGSSAPI
+	case certAuth:
+		cfg.AuthMethod = pgmi.AuthMethodCert
+	case cfg.AuthMethod != pgmi.AuthMethodStandard:
+		// A cloud flag, a token helper or the connection string chose.
+	case env.PGREQUIREAUTH == "gss":
+		cfg.AuthMethod = pgmi.AuthMethodGSSAPI
+	case env.PGREQUIREAUTH == "none" && cfg.SSLCert != "":
+		cfg.AuthMethod = pgmi.AuthMethodCert
+	case yamlAuthMethod == "gssapi":
+		cfg.AuthMethod = pgmi.AuthMethodGSSAPI
+	case yamlAuthMethod == "cert":
+		cfg.AuthMethod = pgmi.AuthMethodCert
+	}
+
+	if cfg.AuthMethod == pgmi.AuthMethodGSSAPI {
+		cfg.KerberosServiceName = firstNonEmpty(kerberos.ServiceName, cfg.KerberosServiceName, env.PGKRBSRVNAME, yamlServiceName)
+		cfg.KerberosSPN = firstNonEmpty(kerberos.SPN, cfg.KerberosSPN, yamlSPN)
+	}
+	return nil
+}
+
 // applyCertParams sets TLS client certificate parameters on the config.
 // Precedence: CLI flag > connection string > env var > pgmi.yaml.
 //
@@ -346,7 +601,8 @@ func applyGoogleAuth(cfg *pgmi.ConnectionConfig, flags *GoogleFlags, pc *config.
 // identity — both silently.
 //
 // cfg carries the connection-string values on entry. The granular-flag path
-// leaves these four fields empty, so a non-empty value here came from the string.
+// leaves these four fields empty, so a non-empty value here came from the string
+// or, ranking alongside it, a service.
 func applyCertParams(cfg *pgmi.ConnectionConfig, flags *CertFlags, env *EnvVars, pc *config.ProjectConfig) {
 	var yamlCert, yamlKey, yamlRootCert string
 	if pc != nil {
@@ -392,12 +648,13 @@ func firstNonEmpty(values ...string) string {
 //
 // The actual target database comes from --database/-d flag.
 //
-// Environment variables are applied as fallbacks for parameters not specified
-// in the connection string (following PostgreSQL standard behavior).
+// A service the string or $PGSERVICE names fills in what the string leaves
+// out, then environment variables are applied as fallbacks (following
+// PostgreSQL standard behavior).
 func resolveFromConnectionString(connStr string, envVars *EnvVars) (*pgmi.ConnectionConfig, string, error) {
-	config, err := ParseConnectionString(connStr)
+	config, err := ResolveConnectionString(connStr, envVars)
 	if err != nil {
-		return nil, "", fmt.Errorf("invalid connection string: %w: %w", err, pgmi.ErrInvalidConfig)
+		return nil, "", err
 	}
 
 	// Apply PGSSLMODE from environment if not specified in connection string
@@ -425,8 +682,10 @@ func resolveFromConnectionString(connStr string, envVars *EnvVars) (*pgmi.Connec
 //
 // Precedence for each parameter (following PostgreSQL standards):
 // 1. CLI flag (highest priority)
-// 2. Environment variable
-// 3. Default value (lowest priority)
+// 2. pg_service.conf service (--service > $PGSERVICE > pgmi.yaml)
+// 3. Environment variable
+// 4. pgmi.yaml
+// 5. Default value (lowest priority)
 //
 // For granular parameters, the maintenance database is always "postgres".
 func resolveFromGranularParams(
@@ -444,41 +703,48 @@ func resolveFromGranularParams(
 		pc = projectConfig.Connection
 	}
 
-	// Host: flag > PGHOST > pgmi.yaml > default
-	cfg.Host = flags.Host
-	if cfg.Host == "" {
-		cfg.Host = envVars.PGHOST
-	}
-	if cfg.Host == "" {
-		cfg.Host = pc.Host
-	}
-	if cfg.Host == "" {
-		cfg.Host = "localhost"
+	// svc holds the service's settings, left empty without one.
+	svc := &pgmi.ConnectionConfig{AdditionalParams: make(map[string]string)}
+	if service := firstNonEmpty(flags.Service, envVars.PGSERVICE, pc.Service); service != "" {
+		path, err := applyService(svc, service, "", envVars)
+		if err != nil {
+			return nil, "", err
+		}
+		cfg.Service = service
+		cfg.ServiceFile = path
 	}
 
-	// Port: flag > PGPORT > pgmi.yaml > default
+	// Host: flag > service > PGHOST > pgmi.yaml > default. Each may be a
+	// comma-separated list of servers to try in order.
+	svcHosts, svcPorts := hostPortLists(svc)
+	setHosts(cfg, parseHostList(firstNonEmpty(flags.Host, svcHosts, envVars.PGHOST, pc.Host, "localhost")))
+
+	// Port: flag > service > PGPORT > pgmi.yaml > default. A list pairs
+	// with the host list by position; a single port applies to every host.
 	if flags.Port != 0 {
 		if err := validatePort(flags.Port); err != nil {
 			return nil, "", fmt.Errorf("invalid --port flag: %w: %w", err, pgmi.ErrInvalidConfig)
 		}
 		cfg.Port = flags.Port
-	} else if envVars.PGPORT != "" {
-		port, err := strconv.Atoi(envVars.PGPORT)
-		if err != nil {
-			return nil, "", fmt.Errorf("invalid $PGPORT value '%s': must be an integer: %w", envVars.PGPORT, pgmi.ErrInvalidConfig)
+	} else if svcPorts != "" {
+		if err := setPorts(cfg, svcPorts); err != nil {
+			return nil, "", fmt.Errorf("service %q: %w: %w", cfg.Service, err, pgmi.ErrInvalidConfig)
 		}
-		if err := validatePort(port); err != nil {
+	} else if envVars.PGPORT != "" {
+		if err := setPorts(cfg, envVars.PGPORT); err != nil {
 			return nil, "", fmt.Errorf("invalid $PGPORT value '%s': %w: %w", envVars.PGPORT, err, pgmi.ErrInvalidConfig)
 		}
-		cfg.Port = port
 	} else if pc.Port != 0 {
 		cfg.Port = pc.Port
 	} else {
 		cfg.Port = 5432
 	}
+	if err := checkHostPorts(cfg); err != nil {
+		return nil, "", fmt.Errorf("%w: %w", err, pgmi.ErrInvalidConfig)
+	}
 
-	// Username: flag > PGUSER > pgmi.yaml > current OS user
-	cfg.Username = flags.Username
+	// Username: flag > service > PGUSER > pgmi.yaml > current OS user
+	cfg.Username = firstNonEmpty(flags.Username, svc.Username)
 	if cfg.Username == "" {
 		cfg.Username = envVars.PGUSER
 	}
@@ -493,10 +759,10 @@ func resolveFromGranularParams(
 		}
 	}
 
-	cfg.Password = envVars.PGPASSWORD
+	cfg.Password = firstNonEmpty(svc.Password, envVars.PGPASSWORD)
 
-	// Database: flag > PGDATABASE > pgmi.yaml
-	cfg.Database = flags.Database
+	// Database: flag > service > PGDATABASE > pgmi.yaml
+	cfg.Database = firstNonEmpty(flags.Database, svc.Database)
 	if cfg.Database == "" {
 		cfg.Database = envVars.PGDATABASE
 	}
@@ -504,8 +770,8 @@ func resolveFromGranularParams(
 		cfg.Database = pc.Database
 	}
 
-	// SSLMode: flag > PGSSLMODE > pgmi.yaml > default
-	cfg.SSLMode = flags.SSLMode
+	// SSLMode: flag > service > PGSSLMODE > pgmi.yaml > default
+	cfg.SSLMode = firstNonEmpty(flags.SSLMode, svc.SSLMode)
 	if cfg.SSLMode == "" {
 		cfg.SSLMode = envVars.PGSSLMODE
 	}
@@ -516,15 +782,17 @@ func resolveFromGranularParams(
 		cfg.SSLMode = "prefer"
 	}
 
-	// AppName: PGAPPNAME > "pgmi" default. Surfaces in pg_stat_activity.application_name
-	// so DBAs can attribute live sessions to pgmi deployments.
-	cfg.AppName = envVars.PGAPPNAME
+	// AppName: service > PGAPPNAME > "pgmi" default. Surfaces in
+	// pg_stat_activity.application_name so DBAs can attribute live sessions
+	// to pgmi deployments.
+	cfg.AppName = firstNonEmpty(svc.AppName, envVars.PGAPPNAME)
 	if cfg.AppName == "" {
 		cfg.AppName = "pgmi"
 	}
 
-	// ConnectTimeout: PGCONNECT_TIMEOUT (seconds) — libpq convention.
-	if envVars.PGCONNECT_TIMEOUT != "" {
+	// ConnectTimeout: service > PGCONNECT_TIMEOUT (seconds) — libpq convention.
+	cfg.ConnectTimeout = svc.ConnectTimeout
+	if cfg.ConnectTimeout == 0 && envVars.PGCONNECT_TIMEOUT != "" {
 		seconds, err := strconv.Atoi(envVars.PGCONNECT_TIMEOUT)
 		if err != nil || seconds < 0 {
 			return nil, "", fmt.Errorf("invalid $PGCONNECT_TIMEOUT value '%s': must be a non-negative integer (seconds): %w", envVars.PGCONNECT_TIMEOUT, pgmi.ErrInvalidConfig)
@@ -532,6 +800,23 @@ func resolveFromGranularParams(
 		cfg.ConnectTimeout = time.Duration(seconds) * time.Second
 	}
 
+	// TargetSessionAttrs: service > PGTARGETSESSIONATTRS > pgmi.yaml
+	if attrs := firstNonEmpty(svc.TargetSessionAttrs, envVars.PGTARGETSESSIONATTRS, pc.TargetSessionAttrs); attrs != "" {
+		var err error
+		if cfg.TargetSessionAttrs, err = parseTargetSessionAttrs(attrs); err != nil {
+			return nil, "", fmt.Errorf("%w: %w", err, pgmi.ErrInvalidConfig)
+		}
+	}
+
+	// The service's certificate and Kerberos settings stand where a
+	// connection string's would, for applyCertParams and
+	// ApplyGSSAPIAndCertAuth to rank; the rest go to pgx as they are.
+	cfg.SSLCert, cfg.SSLKey = svc.SSLCert, svc.SSLKey
+	cfg.SSLRootCert, cfg.SSLPassword = svc.SSLRootCert, svc.SSLPassword
+	cfg.KerberosServiceName, cfg.KerberosSPN = svc.KerberosServiceName, svc.KerberosSPN
+	maps.Copy(cfg.AdditionalParams, svc.AdditionalParams)
+	applyRequireAuth(cfg)
+
 	// Maintenance database: pgmi.yaml > default ("postgres")
 	maintenanceDB := pc.MaintenanceDatabase
 	if maintenanceDB == "" {
@@ -541,9 +826,68 @@ func resolveFromGranularParams(
 	return cfg, maintenanceDB, nil
 }
 
+// hostPortLists returns cfg's servers as libpq host and port parameters,
+// each empty if cfg sets none. The port list is a single port unless the
+// servers' ports differ.
+func hostPortLists(cfg *pgmi.ConnectionConfig) (hosts, ports string) {
+	var hostList, portList []string
+	samePort := true
+	for _, h := range cfg.Hosts() {
+		hostList = append(hostList, h.Host)
+		portList = append(portList, strconv.Itoa(h.Port))
+		samePort = samePort && h.Port == cfg.Port
+	}
+	if cfg.Host != "" {
+		hosts = strings.Join(hostList, ",")
+	}
+	switch {
+	case cfg.Port == 0:
+	case samePort:
+		ports = strconv.Itoa(cfg.Port)
+	default:
+		ports = strings.Join(portList, ",")
+	}
+	return hosts, ports
+}
+
 func validatePort(port int) error {
 	if port < 1 || port > 65535 {
 		return fmt.Errorf("invalid port %d: must be between 1 and 65535", port)
 	}
 	return nil
 }
+
+// ResolveSSHTunnel returns the SSH tunnel configured by flags and pgmi.yaml's
+// connection.ssh, each flag overriding its key, or nil when no SSH host is
+// set in either. Settings without a host are an error rather than a
+// connection made directly.
+func ResolveSSHTunnel(flags *SSHFlags, pc *config.ProjectConfig) (*SSHTunnelConfig, error) {
+	if flags == nil {
+		flags = &SSHFlags{}
+	}
+	var yaml config.SSHConfig
+	if pc != nil && pc.Connection.SSH != nil {
+		yaml = *pc.Connection.SSH
+	}
+
+	tunnel := &SSHTunnelConfig{
+		Host:           firstNonEmpty(flags.Host, yaml.Host),
+		Port:           flags.Port,
+		User:           firstNonEmpty(flags.User, yaml.User),
+		KeyFile:        firstNonEmpty(flags.KeyFile, yaml.KeyFile),
+		KnownHostsFile: firstNonEmpty(flags.KnownHosts, yaml.KnownHosts),
+	}
+	if tunnel.Port == 0 {
+		tunnel.Port = yaml.Port
+	}
+	if tunnel.Host == "" {
+		if !flags.IsEmpty() || pc != nil && pc.Connection.SSH != nil {
+			return nil, fmt.Errorf("SSH tunnel settings need an SSH host (--ssh-host or ssh.host in pgmi.yaml): %w", pgmi.ErrInvalidConfig)
+		}
+		return nil, nil
+	}
+	if tunnel.Port < 0 || tunnel.Port > 65535 {
+		return nil, fmt.Errorf("invalid SSH port %d: %w", tunnel.Port, pgmi.ErrInvalidConfig)
+	}
+	return tunnel, nil
+}
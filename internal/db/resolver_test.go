@@ -1,7 +1,9 @@
 package db
 
 import (
+	"errors"
 	"os"
+	"slices"
 	"testing"
 
 	"github.com/vvka-141/pgmi/internal/config"
@@ -44,6 +46,11 @@ func TestGranularConnFlags_IsEmpty(t *testing.T) {
 			flags: GranularConnFlags{SSLMode: "require"},
 			want:  false,
 		},
+		{
+			name:  "only service set",
+			flags: GranularConnFlags{Service: "prod"},
+			want:  false,
+		},
 		{
 			name: "all fields set",
 			flags: GranularConnFlags{
@@ -514,6 +521,57 @@ func TestResolveConnectionParams_Precedence(t *testing.T) {
 	}
 }
 
+func TestResolveConnectionParams_MultiHost(t *testing.T) {
+	envVars := &EnvVars{
+		PGHOST:               "db1,db2,db3",
+		PGPORT:               "5432,5433,5432",
+		PGTARGETSESSIONATTRS: "read-write",
+	}
+	pc := &config.ProjectConfig{Connection: config.ConnectionConfig{
+		Host:               "yaml1,yaml2",
+		Port:               6432,
+		TargetSessionAttrs: "prefer-standby",
+	}}
+
+	cfg, _, err := ResolveConnectionParams("", nil, nil, nil, nil, nil, envVars, pc)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []pgmi.HostPort{{Host: "db1", Port: 5432}, {Host: "db2", Port: 5433}, {Host: "db3", Port: 5432}}
+	if !slices.Equal(cfg.Hosts(), want) || cfg.TargetSessionAttrs != "read-write" {
+		t.Errorf("got hosts %v with %q, want %v with read-write from PG*", cfg.Hosts(), cfg.TargetSessionAttrs, want)
+	}
+
+	// pgmi.yaml's hosts share its port.
+	cfg, _, err = ResolveConnectionParams("", nil, nil, nil, nil, nil, &EnvVars{}, pc)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want = []pgmi.HostPort{{Host: "yaml1", Port: 6432}, {Host: "yaml2", Port: 6432}}
+	if !slices.Equal(cfg.Hosts(), want) || cfg.TargetSessionAttrs != "prefer-standby" {
+		t.Errorf("got hosts %v with %q, want %v with prefer-standby from pgmi.yaml", cfg.Hosts(), cfg.TargetSessionAttrs, want)
+	}
+
+	// --host replaces the whole list, and --port applies to all of it.
+	cfg, _, err = ResolveConnectionParams("", &GranularConnFlags{Host: "a,b", Port: 7000}, nil, nil, nil, nil, envVars, pc)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want = []pgmi.HostPort{{Host: "a", Port: 7000}, {Host: "b", Port: 7000}}
+	if !slices.Equal(cfg.Hosts(), want) {
+		t.Errorf("got hosts %v, want %v", cfg.Hosts(), want)
+	}
+
+	for name, env := range map[string]*EnvVars{
+		"more ports than hosts":        {PGHOST: "db1", PGPORT: "5432,5433"},
+		"unknown target_session_attrs": {PGTARGETSESSIONATTRS: "writable"},
+	} {
+		if _, _, err := ResolveConnectionParams("", nil, nil, nil, nil, nil, env, nil); !errors.Is(err, pgmi.ErrInvalidConfig) {
+			t.Errorf("%s: expected ErrInvalidConfig, got %v", name, err)
+		}
+	}
+}
+
 func TestResolveConnectionParams_ProjectConfig(t *testing.T) {
 	pc := &config.ProjectConfig{
 		Connection: config.ConnectionConfig{
@@ -862,6 +920,313 @@ func TestApplyGoogleAuth(t *testing.T) {
 	}
 }
 
+func TestApplyTokenAuth(t *testing.T) {
+	yamlExec := &config.ProjectConfig{Connection: config.ConnectionConfig{
+		AuthMethod:   "exec",
+		TokenCommand: []string{"vault-db-token", "--role", "deploy ci"},
+	}}
+	yamlFile := &config.ProjectConfig{Connection: config.ConnectionConfig{
+		AuthMethod: "token_file",
+		TokenFile:  "/var/run/secrets/db/token",
+	}}
+
+	tests := []struct {
+		name           string
+		resolved       pgmi.AuthMethod
+		flags          *TokenFlags
+		env            *EnvVars
+		pc             *config.ProjectConfig
+		wantAuthMethod pgmi.AuthMethod
+		wantCommand    []string
+		wantFile       string
+	}{
+		{
+			name:           "nothing configured",
+			wantAuthMethod: pgmi.AuthMethodStandard,
+		},
+		{
+			name:           "the environment alone selects nothing",
+			env:            &EnvVars{PGMI_TOKEN_COMMAND: "get-token", PGMI_TOKEN_FILE: "/tmp/token"},
+			wantAuthMethod: pgmi.AuthMethodStandard,
+		},
+		{
+			name:           "pgmi.yaml selects exec, keeping arguments with spaces",
+			pc:             yamlExec,
+			wantAuthMethod: pgmi.AuthMethodExec,
+			wantCommand:    []string{"vault-db-token", "--role", "deploy ci"},
+		},
+		{
+			name:           "the environment overrides pgmi.yaml's command",
+			env:            &EnvVars{PGMI_TOKEN_COMMAND: "get-token --fresh"},
+			pc:             yamlExec,
+			wantAuthMethod: pgmi.AuthMethodExec,
+			wantCommand:    []string{"get-token", "--fresh"},
+		},
+		{
+			name:           "a quoted flag keeps its spaces",
+			flags:          &TokenFlags{Command: `"/opt/My Tools/get-token" --audience "a b"`},
+			wantAuthMethod: pgmi.AuthMethodExec,
+			wantCommand:    []string{"/opt/My Tools/get-token", "--audience", "a b"},
+		},
+		{
+			name:           "a quoted environment variable keeps its spaces",
+			env:            &EnvVars{PGMI_TOKEN_COMMAND: `'/opt/My Tools/get-token' --role deploy\ ci`},
+			pc:             yamlExec,
+			wantAuthMethod: pgmi.AuthMethodExec,
+			wantCommand:    []string{"/opt/My Tools/get-token", "--role", "deploy ci"},
+		},
+		{
+			name:           "pgmi.yaml selects token_file",
+			pc:             yamlFile,
+			wantAuthMethod: pgmi.AuthMethodTokenFile,
+			wantFile:       "/var/run/secrets/db/token",
+		},
+		{
+			name:           "a flag overrides pgmi.yaml's method",
+			flags:          &TokenFlags{File: "/tmp/flag-token"},
+			pc:             yamlExec,
+			wantAuthMethod: pgmi.AuthMethodTokenFile,
+			wantFile:       "/tmp/flag-token",
+		},
+		{
+			name:           "a cloud flag outranks pgmi.yaml",
+			resolved:       pgmi.AuthMethodAWSIAM,
+			pc:             yamlExec,
+			wantAuthMethod: pgmi.AuthMethodAWSIAM,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &pgmi.ConnectionConfig{AuthMethod: tt.resolved}
+			if err := ApplyTokenAuth(cfg, tt.flags, tt.env, tt.pc); err != nil {
+				t.Fatalf("ApplyTokenAuth: %v", err)
+			}
+			if cfg.AuthMethod != tt.wantAuthMethod {
+				t.Errorf("AuthMethod = %v, want %v", cfg.AuthMethod, tt.wantAuthMethod)
+			}
+			if !slices.Equal(cfg.TokenCommand, tt.wantCommand) {
+				t.Errorf("TokenCommand = %q, want %q", cfg.TokenCommand, tt.wantCommand)
+			}
+			if cfg.TokenFile != tt.wantFile {
+				t.Errorf("TokenFile = %q, want %q", cfg.TokenFile, tt.wantFile)
+			}
+		})
+	}
+
+	err := ApplyTokenAuth(&pgmi.ConnectionConfig{}, &TokenFlags{Command: "get-token", File: "/tmp/token"}, nil, nil)
+	if !errors.Is(err, pgmi.ErrInvalidConfig) {
+		t.Errorf("both token flags: expected ErrInvalidConfig, got %v", err)
+	}
+	err = ApplyTokenAuth(&pgmi.ConnectionConfig{}, &TokenFlags{Command: `get-token --audience "a b`}, nil, nil)
+	if !errors.Is(err, pgmi.ErrInvalidConfig) {
+		t.Errorf("unterminated quote: expected ErrInvalidConfig, got %v", err)
+	}
+}
+
+func TestSplitCommandLine(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+	}{
+		{"", nil},
+		{"  get-token   --fresh ", []string{"get-token", "--fresh"}},
+		{`"/opt/My Tools/get-token" --audience "a b"`, []string{"/opt/My Tools/get-token", "--audience", "a b"}},
+		{`get-token --role 'deploy "ci"'`, []string{"get-token", "--role", `deploy "ci"`}},
+		{`get-token --say "it's \"quoted\""`, []string{"get-token", "--say", `it's "quoted"`}},
+		{`get-token --empty ""`, []string{"get-token", "--empty", ""}},
+		{`get-token --role=deploy\ ci`, []string{"get-token", "--role=deploy ci"}},
+		{`C:\tools\get-token.exe --audience x`, []string{`C:\tools\get-token.exe`, "--audience", "x"}},
+		{`"C:\Program Files\get-token.exe"`, []string{`C:\Program Files\get-token.exe`}},
+	}
+	for _, tt := range tests {
+		got, err := splitCommandLine(tt.in)
+		if err != nil {
+			t.Errorf("splitCommandLine(%q): %v", tt.in, err)
+			continue
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("splitCommandLine(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+		if tt.want == nil {
+			continue
+		}
+		// What JoinCommandLine renders must split back into the same list.
+		if back, err := splitCommandLine(JoinCommandLine(tt.want)); err != nil || !slices.Equal(back, tt.want) {
+			t.Errorf("JoinCommandLine(%q) = %q, which splits into %q (%v)", tt.want, JoinCommandLine(tt.want), back, err)
+		}
+	}
+
+	for _, in := range []string{`get-token "a b`, `get-token 'a b`} {
+		if _, err := splitCommandLine(in); !errors.Is(err, pgmi.ErrInvalidConfig) {
+			t.Errorf("splitCommandLine(%q): expected ErrInvalidConfig, got %v", in, err)
+		}
+	}
+}
+
+func TestApplyGSSAPIAndCertAuth(t *testing.T) {
+	yamlGSS := &config.ProjectConfig{Connection: config.ConnectionConfig{
+		AuthMethod:          "gssapi",
+		KerberosServiceName: "yaml-service",
+	}}
+	yamlCert := &config.ProjectConfig{Connection: config.ConnectionConfig{AuthMethod: "cert"}}
+
+	tests := []struct {
+		name        string
+		resolved    pgmi.ConnectionConfig
+		kerberos    *KerberosFlags
+		certAuth    bool
+		env         *EnvVars
+		pc          *config.ProjectConfig
+		wantMethod  pgmi.AuthMethod
+		wantService string
+		wantSPN     string
+	}{
+		{
+			name:       "nothing configured",
+			wantMethod: pgmi.AuthMethodStandard,
+		},
+		{
+			name:       "--gssapi",
+			kerberos:   &KerberosFlags{Enabled: true},
+			wantMethod: pgmi.AuthMethodGSSAPI,
+		},
+		{
+			name:       "--cert-auth",
+			certAuth:   true,
+			wantMethod: pgmi.AuthMethodCert,
+		},
+		{
+			name:        "PGREQUIREAUTH=gss with PGKRBSRVNAME",
+			env:         &EnvVars{PGREQUIREAUTH: "gss", PGKRBSRVNAME: "env-service"},
+			wantMethod:  pgmi.AuthMethodGSSAPI,
+			wantService: "env-service",
+		},
+		{
+			name:       "PGREQUIREAUTH=none needs a certificate",
+			env:        &EnvVars{PGREQUIREAUTH: "none"},
+			wantMethod: pgmi.AuthMethodStandard,
+		},
+		{
+			name:       "PGREQUIREAUTH=none with a certificate",
+			resolved:   pgmi.ConnectionConfig{SSLCert: "/c.crt"},
+			env:        &EnvVars{PGREQUIREAUTH: "none"},
+			wantMethod: pgmi.AuthMethodCert,
+		},
+		{
+			name:        "pgmi.yaml selects gssapi",
+			pc:          yamlGSS,
+			wantMethod:  pgmi.AuthMethodGSSAPI,
+			wantService: "yaml-service",
+		},
+		{
+			name:       "pgmi.yaml selects cert",
+			pc:         yamlCert,
+			wantMethod: pgmi.AuthMethodCert,
+		},
+		{
+			name:        "the service name: flag > connection string > env > pgmi.yaml",
+			resolved:    pgmi.ConnectionConfig{KerberosServiceName: "conn-service"},
+			kerberos:    &KerberosFlags{Enabled: true},
+			env:         &EnvVars{PGKRBSRVNAME: "env-service"},
+			pc:          yamlGSS,
+			wantMethod:  pgmi.AuthMethodGSSAPI,
+			wantService: "conn-service",
+		},
+		{
+			name:        "a --krbsrvname flag wins",
+			kerberos:    &KerberosFlags{Enabled: true, ServiceName: "flag-service"},
+			env:         &EnvVars{PGKRBSRVNAME: "env-service"},
+			wantMethod:  pgmi.AuthMethodGSSAPI,
+			wantService: "flag-service",
+		},
+		{
+			name:       "a --krbspn flag",
+			kerberos:   &KerberosFlags{Enabled: true, SPN: "postgres/db@EXAMPLE.COM"},
+			wantMethod: pgmi.AuthMethodGSSAPI,
+			wantSPN:    "postgres/db@EXAMPLE.COM",
+		},
+		{
+			name:       "a token helper outranks the environment and pgmi.yaml",
+			resolved:   pgmi.ConnectionConfig{AuthMethod: pgmi.AuthMethodExec},
+			env:        &EnvVars{PGREQUIREAUTH: "gss"},
+			pc:         yamlGSS,
+			wantMethod: pgmi.AuthMethodExec,
+		},
+		{
+			name:       "Kerberos settings do not leak into other methods",
+			resolved:   pgmi.ConnectionConfig{AuthMethod: pgmi.AuthMethodAzureEntraID},
+			env:        &EnvVars{PGKRBSRVNAME: "env-service"},
+			wantMethod: pgmi.AuthMethodAzureEntraID,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := tt.resolved
+			if err := ApplyGSSAPIAndCertAuth(&cfg, tt.kerberos, tt.certAuth, tt.env, tt.pc); err != nil {
+				t.Fatalf("ApplyGSSAPIAndCertAuth: %v", err)
+			}
+			if cfg.AuthMethod != tt.wantMethod {
+				t.Errorf("AuthMethod = %v, want %v", cfg.AuthMethod, tt.wantMethod)
+			}
+			if cfg.KerberosServiceName != tt.wantService {
+				t.Errorf("KerberosServiceName = %q, want %q", cfg.KerberosServiceName, tt.wantService)
+			}
+			if cfg.KerberosSPN != tt.wantSPN {
+				t.Errorf("KerberosSPN = %q, want %q", cfg.KerberosSPN, tt.wantSPN)
+			}
+		})
+	}
+
+	err := ApplyGSSAPIAndCertAuth(&pgmi.ConnectionConfig{}, &KerberosFlags{Enabled: true}, true, nil, nil)
+	if !errors.Is(err, pgmi.ErrInvalidConfig) {
+		t.Errorf("--gssapi with --cert-auth: expected ErrInvalidConfig, got %v", err)
+	}
+}
+
+func TestResolveSSHTunnel(t *testing.T) {
+	yaml := &config.ProjectConfig{Connection: config.ConnectionConfig{SSH: &config.SSHConfig{
+		Host:       "bastion.example.com",
+		Port:       2222,
+		User:       "jump",
+		KeyFile:    "/home/ci/.ssh/deploy",
+		KnownHosts: "/etc/ssh/known_hosts",
+	}}}
+
+	tunnel, err := ResolveSSHTunnel(nil, nil)
+	if err != nil || tunnel != nil {
+		t.Fatalf("nothing configured: got %v, %v; want no tunnel", tunnel, err)
+	}
+
+	tunnel, err = ResolveSSHTunnel(nil, yaml)
+	if err != nil {
+		t.Fatalf("ResolveSSHTunnel: %v", err)
+	}
+	want := SSHTunnelConfig{Host: "bastion.example.com", Port: 2222, User: "jump", KeyFile: "/home/ci/.ssh/deploy", KnownHostsFile: "/etc/ssh/known_hosts"}
+	if *tunnel != want {
+		t.Errorf("from pgmi.yaml: got %+v, want %+v", *tunnel, want)
+	}
+
+	tunnel, err = ResolveSSHTunnel(&SSHFlags{Host: "other-bastion", Port: 22, User: "ci"}, yaml)
+	if err != nil {
+		t.Fatalf("ResolveSSHTunnel: %v", err)
+	}
+	want = SSHTunnelConfig{Host: "other-bastion", Port: 22, User: "ci", KeyFile: "/home/ci/.ssh/deploy", KnownHostsFile: "/etc/ssh/known_hosts"}
+	if *tunnel != want {
+		t.Errorf("flags over pgmi.yaml: got %+v, want %+v", *tunnel, want)
+	}
+
+	// Tunnel settings without a host must not quietly connect directly.
+	if _, err := ResolveSSHTunnel(&SSHFlags{User: "ci"}, nil); !errors.Is(err, pgmi.ErrInvalidConfig) {
+		t.Errorf("--ssh-user alone: expected ErrInvalidConfig, got %v", err)
+	}
+	noHost := &config.ProjectConfig{Connection: config.ConnectionConfig{SSH: &config.SSHConfig{User: "jump"}}}
+	if _, err := ResolveSSHTunnel(nil, noHost); !errors.Is(err, pgmi.ErrInvalidConfig) {
+		t.Errorf("ssh without a host in pgmi.yaml: expected ErrInvalidConfig, got %v", err)
+	}
+}
+
 func TestApplyAzureAuth_YamlFallback(t *testing.T) {
 	tests := []struct {
 		name           string
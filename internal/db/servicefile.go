@@ -0,0 +1,128 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/jackc/pgservicefile"
+
+	"github.com/vvka-141/pgmi/pkg/pgmi"
+)
+
+// defaultSysConfDirs are where PostgreSQL packages put the system-wide
+// pg_service.conf when $PGSYSCONFDIR does not say. libpq has the directory
+// compiled in; these are the common builds' choices.
+var defaultSysConfDirs = []string{
+	"/etc/postgresql-common", // Debian and Ubuntu
+	"/etc/sysconfig/pgsql",   // Red Hat and Fedora
+	"/usr/local/pgsql/etc",   // source builds
+}
+
+// serviceFiles returns the files a service is looked for in, in libpq's
+// order: the user's service file, then the system-wide one. The user's file
+// is serviceFile (a connection string's servicefile parameter), else
+// $PGSERVICEFILE, else ~/.pg_service.conf; the system-wide file is
+// pg_service.conf in $PGSYSCONFDIR.
+func serviceFiles(serviceFile string, env *EnvVars) []string {
+	var files []string
+	switch {
+	case serviceFile != "":
+		files = append(files, serviceFile)
+	case env.PGSERVICEFILE != "":
+		files = append(files, env.PGSERVICEFILE)
+	case runtime.GOOS == "windows":
+		if appData := os.Getenv("APPDATA"); appData != "" {
+			files = append(files, filepath.Join(appData, "postgresql", ".pg_service.conf"))
+		}
+	default:
+		if home, err := os.UserHomeDir(); err == nil {
+			files = append(files, filepath.Join(home, ".pg_service.conf"))
+		}
+	}
+
+	if env.PGSYSCONFDIR != "" {
+		return append(files, filepath.Join(env.PGSYSCONFDIR, "pg_service.conf"))
+	}
+	if runtime.GOOS != "windows" {
+		for _, dir := range defaultSysConfDirs {
+			files = append(files, filepath.Join(dir, "pg_service.conf"))
+		}
+	}
+	return files
+}
+
+// lookupService returns the settings of the named service and the file they
+// came from. The first file holding the service wins, even if a later one
+// holds it too; files that do not exist are skipped.
+func lookupService(name, serviceFile string, env *EnvVars) (map[string]string, string, error) {
+	files := serviceFiles(serviceFile, env)
+	for _, path := range files {
+		sf, err := pgservicefile.ReadServicefile(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, "", fmt.Errorf("service file %s: %v: %w", path, err, pgmi.ErrInvalidConfig)
+		}
+		service, err := sf.GetService(name)
+		if err != nil {
+			continue
+		}
+		if _, nested := service.Settings["service"]; nested {
+			return nil, "", fmt.Errorf("service %q in %s: a service cannot name another service: %w", name, path, pgmi.ErrInvalidConfig)
+		}
+		return service.Settings, path, nil
+	}
+	return nil, "", fmt.Errorf("definition of service %q not found (looked in %s): %w", name, strings.Join(files, ", "), pgmi.ErrInvalidConfig)
+}
+
+// applyService sets the named service's settings on cfg and returns the file
+// they came from.
+func applyService(cfg *pgmi.ConnectionConfig, name, serviceFile string, env *EnvVars) (string, error) {
+	settings, path, err := lookupService(name, serviceFile, env)
+	if err != nil {
+		return "", err
+	}
+	for key, value := range settings {
+		if err := setParam(cfg, key, value); err != nil {
+			return "", fmt.Errorf("service %q in %s: %v: %w", name, path, err, pgmi.ErrInvalidConfig)
+		}
+	}
+	return path, nil
+}
+
+// ResolveConnectionString parses a connection string and fills in what it
+// leaves out from a pg_service.conf service: the one its service parameter
+// names, else $PGSERVICE. What the string sets wins over the service, as in
+// libpq. Without a service it is ParseConnectionString.
+func ResolveConnectionString(connStr string, env *EnvVars) (*pgmi.ConnectionConfig, error) {
+	if env == nil {
+		env = &EnvVars{}
+	}
+	config, err := ParseConnectionString(connStr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid connection string: %w: %w", err, pgmi.ErrInvalidConfig)
+	}
+	service := firstNonEmpty(config.Service, env.PGSERVICE)
+	if service == "" {
+		return config, nil
+	}
+
+	base := defaultConnectionConfig()
+	path, err := applyService(base, service, config.ServiceFile, env)
+	if err != nil {
+		return nil, err
+	}
+	config, err = parseConnectionString(connStr, base)
+	if err != nil {
+		return nil, fmt.Errorf("invalid connection string: %w: %w", err, pgmi.ErrInvalidConfig)
+	}
+	config.Service = service
+	config.ServiceFile = path
+	return config, nil
+}
@@ -0,0 +1,263 @@
+package db
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vvka-141/pgmi/internal/config"
+	"github.com/vvka-141/pgmi/pkg/pgmi"
+)
+
+func writeServiceFile(t *testing.T, path, content string) string {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// serviceEnv returns EnvVars whose user file is userFile and whose system
+// file is in a fresh directory, so nothing outside the test is read.
+func serviceEnv(t *testing.T, userFile string) (*EnvVars, string) {
+	t.Helper()
+	sysConfDir := t.TempDir()
+	return &EnvVars{PGSERVICEFILE: userFile, PGSYSCONFDIR: sysConfDir}, filepath.Join(sysConfDir, "pg_service.conf")
+}
+
+func TestLookupService(t *testing.T) {
+	dir := t.TempDir()
+	userFile := writeServiceFile(t, filepath.Join(dir, "user.conf"), `
+# DBA-managed
+[prod]
+host=db1.corp.example.com
+port=5433
+
+[staging]
+host=db-staging
+`)
+	env, systemFile := serviceEnv(t, userFile)
+	writeServiceFile(t, systemFile, `
+[prod]
+host=system-prod
+
+[reporting]
+host=reporting.corp.example.com
+`)
+
+	settings, path, err := lookupService("prod", "", env)
+	if err != nil {
+		t.Fatalf("lookupService(prod): %v", err)
+	}
+	if path != userFile || settings["host"] != "db1.corp.example.com" || settings["port"] != "5433" {
+		t.Errorf("prod: got %v from %s; want the user file's entry", settings, path)
+	}
+
+	settings, path, err = lookupService("reporting", "", env)
+	if err != nil {
+		t.Fatalf("lookupService(reporting): %v", err)
+	}
+	if path != systemFile || settings["host"] != "reporting.corp.example.com" {
+		t.Errorf("reporting: got %v from %s; want the system file's entry", settings, path)
+	}
+
+	// A servicefile parameter outranks $PGSERVICEFILE.
+	other := writeServiceFile(t, filepath.Join(dir, "other.conf"), "[prod]\nhost=other\n")
+	settings, path, err = lookupService("prod", other, env)
+	if err != nil || path != other || settings["host"] != "other" {
+		t.Errorf("servicefile parameter: got %v from %s (%v); want other.conf's entry", settings, path, err)
+	}
+
+	_, _, err = lookupService("missing", "", env)
+	if !errors.Is(err, pgmi.ErrInvalidConfig) || !strings.Contains(err.Error(), `definition of service "missing" not found`) {
+		t.Errorf("unknown service: expected a not-found ErrInvalidConfig, got %v", err)
+	}
+}
+
+func TestLookupService_MissingFilesSkipped(t *testing.T) {
+	env, systemFile := serviceEnv(t, filepath.Join(t.TempDir(), "does-not-exist.conf"))
+	writeServiceFile(t, systemFile, "[prod]\nhost=system-prod\n")
+
+	settings, path, err := lookupService("prod", "", env)
+	if err != nil || path != systemFile || settings["host"] != "system-prod" {
+		t.Errorf("got %v from %s (%v); want the system file's entry", settings, path, err)
+	}
+}
+
+func TestLookupService_InvalidFiles(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"line outside a section", "host=db\n[prod]\nport=5432\n"},
+		{"nested service", "[prod]\nservice=other\n"},
+		{"bad port", "[prod]\nport=not-a-port\n"},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env, _ := serviceEnv(t, writeServiceFile(t, filepath.Join(dir, strings.Repeat("x", i+1)+".conf"), tt.content))
+			_, err := applyService(&pgmi.ConnectionConfig{AdditionalParams: map[string]string{}}, "prod", "", env)
+			if !errors.Is(err, pgmi.ErrInvalidConfig) {
+				t.Errorf("expected ErrInvalidConfig, got %v", err)
+			}
+		})
+	}
+}
+
+func TestResolveConnectionString_Service(t *testing.T) {
+	userFile := writeServiceFile(t, filepath.Join(t.TempDir(), "pg_service.conf"), `
+[prod]
+host=db1.corp.example.com
+port=5433
+dbname=maint
+user=svc_user
+sslmode=verify-full
+sslrootcert=/etc/ssl/corp-ca.pem
+connect_timeout=7
+target_session_attrs=read-write
+
+[kerberos]
+host=db-krb
+require_auth=gss
+krbsrvname=pgsql
+`)
+	env, _ := serviceEnv(t, userFile)
+
+	got, err := ResolveConnectionString("service=prod", env)
+	if err != nil {
+		t.Fatalf("ResolveConnectionString: %v", err)
+	}
+	want := &pgmi.ConnectionConfig{
+		Host:           "db1.corp.example.com",
+		Port:           5433,
+		Database:       "maint",
+		Username:       "svc_user",
+		SSLMode:        "verify-full",
+		SSLRootCert:    "/etc/ssl/corp-ca.pem",
+		ConnectTimeout: 7 * time.Second,
+	}
+	compareConfigs(t, got, want)
+	if got.ConnectTimeout != want.ConnectTimeout || got.TargetSessionAttrs != "read-write" {
+		t.Errorf("ConnectTimeout = %v, TargetSessionAttrs = %q; want 7s and read-write", got.ConnectTimeout, got.TargetSessionAttrs)
+	}
+	if got.Service != "prod" || got.ServiceFile != userFile {
+		t.Errorf("Service = %q from %q, want prod from %q", got.Service, got.ServiceFile, userFile)
+	}
+
+	// What the string sets wins over the service, in every format.
+	for _, connStr := range []string{
+		"service=prod user=deployer port=6432",
+		"postgresql://deployer@:6432/?service=prod",
+		"Service=prod;Username=deployer;Port=6432",
+	} {
+		got, err := ResolveConnectionString(connStr, env)
+		if err != nil {
+			t.Fatalf("%s: %v", connStr, err)
+		}
+		if got.Host != "db1.corp.example.com" || got.Port != 6432 || got.Username != "deployer" || got.SSLMode != "verify-full" {
+			t.Errorf("%s: got %s; want the service's host and sslmode with the string's user and port", connStr, got)
+		}
+	}
+
+	// $PGSERVICE applies when the string names no service.
+	env.PGSERVICE = "prod"
+	got, err = ResolveConnectionString("postgresql://deployer@/app", env)
+	if err != nil {
+		t.Fatalf("$PGSERVICE: %v", err)
+	}
+	if got.Host != "db1.corp.example.com" || got.Database != "app" || got.Service != "prod" {
+		t.Errorf("$PGSERVICE: got %s (service %q); want prod's host with database app", got, got.Service)
+	}
+
+	// The string's service wins over $PGSERVICE, and the service's
+	// require_auth selects an auth method as the string's would.
+	got, err = ResolveConnectionString("service=kerberos", env)
+	if err != nil {
+		t.Fatalf("kerberos: %v", err)
+	}
+	if got.Host != "db-krb" || got.AuthMethod != pgmi.AuthMethodGSSAPI || got.KerberosServiceName != "pgsql" {
+		t.Errorf("kerberos: got %s, krbsrvname %q; want GSSAPI to db-krb as pgsql", got, got.KerberosServiceName)
+	}
+
+	if _, err := ResolveConnectionString("service=missing", env); !errors.Is(err, pgmi.ErrInvalidConfig) {
+		t.Errorf("unknown service: expected ErrInvalidConfig, got %v", err)
+	}
+}
+
+func TestResolveConnectionParams_ServicePrecedence(t *testing.T) {
+	userFile := writeServiceFile(t, filepath.Join(t.TempDir(), "pg_service.conf"), `
+[prod]
+host=db1.corp.example.com
+port=5433
+user=svc_user
+sslmode=verify-full
+sslcert=/etc/ssl/svc.crt
+
+[yaml]
+host=from-yaml-service
+`)
+	env, _ := serviceEnv(t, userFile)
+	env.PGHOST = "env-host"
+	env.PGPORT = "5999"
+	env.PGSSLMODE = "disable"
+	env.PGSSLCERT = "/env/client.crt"
+	projectCfg := &config.ProjectConfig{Connection: config.ConnectionConfig{
+		Service: "yaml",
+		Host:    "yaml-host",
+		SSLMode: "require",
+	}}
+
+	// --service over pgmi.yaml's service; the service over PG* and
+	// pgmi.yaml; flags over the service.
+	cfg, _, err := ResolveConnectionParams("", &GranularConnFlags{Service: "prod", Username: "deployer"}, nil, nil, nil, nil, env, projectCfg)
+	if err != nil {
+		t.Fatalf("ResolveConnectionParams: %v", err)
+	}
+	if cfg.Host != "db1.corp.example.com" || cfg.Port != 5433 || cfg.SSLMode != "verify-full" || cfg.Username != "deployer" {
+		t.Errorf("got %s; want prod's host, port and sslmode with the flag's user", cfg)
+	}
+	if cfg.SSLCert != "/etc/ssl/svc.crt" {
+		t.Errorf("SSLCert = %q, want the service's over $PGSSLCERT", cfg.SSLCert)
+	}
+	if cfg.Service != "prod" || cfg.ServiceFile != userFile {
+		t.Errorf("Service = %q from %q, want prod from %q", cfg.Service, cfg.ServiceFile, userFile)
+	}
+
+	// $PGSERVICE over pgmi.yaml's service.
+	env.PGSERVICE = "prod"
+	cfg, _, err = ResolveConnectionParams("", nil, nil, nil, nil, nil, env, projectCfg)
+	if err != nil {
+		t.Fatalf("$PGSERVICE: %v", err)
+	}
+	if cfg.Service != "prod" || cfg.Host != "db1.corp.example.com" {
+		t.Errorf("$PGSERVICE: got %s from service %q; want prod's host", cfg, cfg.Service)
+	}
+
+	// pgmi.yaml's service over its own host; PG* fill what it leaves out.
+	env.PGSERVICE = ""
+	cfg, _, err = ResolveConnectionParams("", nil, nil, nil, nil, nil, env, projectCfg)
+	if err != nil {
+		t.Fatalf("pgmi.yaml service: %v", err)
+	}
+	if cfg.Host != "from-yaml-service" || cfg.Port != 5999 || cfg.SSLMode != "disable" {
+		t.Errorf("pgmi.yaml service: got %s; want its host with $PGPORT and $PGSSLMODE", cfg)
+	}
+
+	// --service is a granular flag.
+	_, _, err = ResolveConnectionParams("postgresql://localhost/postgres", &GranularConnFlags{Service: "prod"}, nil, nil, nil, nil, env, nil)
+	if !errors.Is(err, pgmi.ErrInvalidConfig) {
+		t.Errorf("--connection with --service: expected ErrInvalidConfig, got %v", err)
+	}
+
+	_, _, err = ResolveConnectionParams("", &GranularConnFlags{Service: "missing"}, nil, nil, nil, nil, env, nil)
+	if !errors.Is(err, pgmi.ErrInvalidConfig) {
+		t.Errorf("unknown --service: expected ErrInvalidConfig, got %v", err)
+	}
+}
@@ -0,0 +1,330 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+
+	"github.com/vvka-141/pgmi/pkg/pgmi"
+)
+
+// sshKeepaliveInterval is how often an idle tunnel pings the SSH server.
+// Bastions commonly drop connections idle for a few minutes, and a deploy's
+// maintenance connection sits idle while the target session works.
+const sshKeepaliveInterval = 30 * time.Second
+
+// SSHTunnelConfig describes the SSH server connections are tunneled through.
+type SSHTunnelConfig struct {
+	Host string
+	Port int    // 0 means 22
+	User string // empty means the current OS user
+
+	// KeyFile is the private key to authenticate with. Without one, keys
+	// held by the agent at $SSH_AUTH_SOCK and the default keys in ~/.ssh
+	// are tried. An encrypted key's passphrase is read from
+	// $PGMI_SSH_PASSPHRASE.
+	KeyFile string
+
+	// KnownHostsFile holds the SSH server's host key; empty means
+	// ~/.ssh/known_hosts. A server whose key is not in it is refused.
+	KnownHostsFile string
+}
+
+// address returns host:port of the SSH server.
+func (c SSHTunnelConfig) address() string {
+	port := c.Port
+	if port == 0 {
+		port = 22
+	}
+	return net.JoinHostPort(c.Host, strconv.Itoa(port))
+}
+
+// String returns user@host:port.
+func (c SSHTunnelConfig) String() string {
+	if c.User == "" {
+		return c.address()
+	}
+	return c.User + "@" + c.address()
+}
+
+// SSHTunnel dials database connections through an SSH server, the way
+// `ssh -L` would, without a local port or a process to manage. Its
+// DialContext and LookupFunc go in PoolHooks, so every pool a connector
+// opens — the maintenance database and the target alike — goes through it.
+//
+// The SSH connection is opened on the first dial and shared by every later
+// one; if the server drops it, the next dial opens a new one.
+type SSHTunnel struct {
+	config       SSHTunnelConfig
+	clientConfig *ssh.ClientConfig
+	agentConn    net.Conn
+
+	mu     sync.Mutex
+	client *ssh.Client
+	closed bool
+}
+
+// NewSSHTunnel prepares a tunnel through the server cfg names. It reads the
+// keys and known_hosts now, so a mistake there is reported before any
+// connection is attempted; the SSH server itself is contacted on first use.
+func NewSSHTunnel(cfg SSHTunnelConfig) (*SSHTunnel, error) {
+	if cfg.Host == "" {
+		return nil, fmt.Errorf("an SSH tunnel requires an SSH host: %w", pgmi.ErrInvalidConfig)
+	}
+	if cfg.User == "" {
+		u, err := user.Current()
+		if err != nil {
+			return nil, fmt.Errorf("cannot determine the SSH user, set one explicitly: %w", pgmi.ErrInvalidConfig)
+		}
+		cfg.User = u.Username
+	}
+
+	hostKeyCallback, err := loadKnownHosts(cfg.KnownHostsFile)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &SSHTunnel{config: cfg}
+	auth, err := t.authMethods()
+	if err != nil {
+		return nil, err
+	}
+	t.clientConfig = &ssh.ClientConfig{
+		User:              cfg.User,
+		Auth:              auth,
+		HostKeyCallback:   hostKeyCallback,
+		HostKeyAlgorithms: knownHostKeyAlgorithms(hostKeyCallback, cfg.address()),
+	}
+	return t, nil
+}
+
+// loadKnownHosts returns a callback accepting only host keys in path.
+func loadKnownHosts(path string) (ssh.HostKeyCallback, error) {
+	if path == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, fmt.Errorf("cannot locate ~/.ssh/known_hosts, name a known_hosts file: %w", pgmi.ErrInvalidConfig)
+		}
+		path = filepath.Join(home, ".ssh", "known_hosts")
+	}
+	callback, err := knownhosts.New(path)
+	if err != nil {
+		return nil, fmt.Errorf("SSH known_hosts: %v (the SSH server's host key must be in it, e.g. from ssh-keyscan): %w", err, pgmi.ErrInvalidConfig)
+	}
+	return callback, nil
+}
+
+// probeKey is a host key no known_hosts file can hold. Offered to a
+// knownhosts callback, it makes the callback list the keys it does know.
+type probeKey struct{}
+
+func (probeKey) Type() string                        { return "pgmi-probe" }
+func (probeKey) Marshal() []byte                     { return []byte("pgmi-probe") }
+func (probeKey) Verify([]byte, *ssh.Signature) error { return errors.New("probe key") }
+
+// knownHostKeyAlgorithms returns the algorithms of the host keys known for
+// address, so the server is asked for one of those. Otherwise it may offer a
+// key of another type that is not in known_hosts, and be refused although
+// its key is known. Nil, for an unknown host, leaves the defaults.
+func knownHostKeyAlgorithms(callback ssh.HostKeyCallback, address string) []string {
+	var keyErr *knownhosts.KeyError
+	if err := callback(address, &net.TCPAddr{IP: net.IPv4zero}, probeKey{}); !errors.As(err, &keyErr) {
+		return nil
+	}
+	var algorithms []string
+	for _, known := range keyErr.Want {
+		switch keyType := known.Key.Type(); keyType {
+		case ssh.KeyAlgoRSA:
+			// An ssh-rsa key signs with any of these.
+			algorithms = append(algorithms, ssh.KeyAlgoRSASHA512, ssh.KeyAlgoRSASHA256, ssh.KeyAlgoRSA)
+		default:
+			algorithms = append(algorithms, keyType)
+		}
+	}
+	return algorithms
+}
+
+// authMethods returns the key file's key, or else the agent's keys and the
+// default keys in ~/.ssh.
+func (t *SSHTunnel) authMethods() ([]ssh.AuthMethod, error) {
+	if t.config.KeyFile != "" {
+		signer, err := loadSSHKey(t.config.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		return []ssh.AuthMethod{ssh.PublicKeys(signer)}, nil
+	}
+
+	var methods []ssh.AuthMethod
+	if sock := os.Getenv("SSH_AUTH_SOCK"); sock != "" {
+		if conn, err := net.Dial("unix", sock); err == nil {
+			t.agentConn = conn
+			methods = append(methods, ssh.PublicKeysCallback(agent.NewClient(conn).Signers))
+		}
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		var signers []ssh.Signer
+		for _, name := range []string{"id_ed25519", "id_ecdsa", "id_rsa"} {
+			// An encrypted default key is skipped rather than an error: the
+			// agent usually holds it.
+			if signer, err := loadSSHKey(filepath.Join(home, ".ssh", name)); err == nil {
+				signers = append(signers, signer)
+			}
+		}
+		if len(signers) > 0 {
+			methods = append(methods, ssh.PublicKeys(signers...))
+		}
+	}
+	if len(methods) == 0 {
+		return nil, fmt.Errorf("no SSH credentials: name a key file or run an ssh-agent: %w", pgmi.ErrInvalidConfig)
+	}
+	return methods, nil
+}
+
+// loadSSHKey reads a private key, decrypting it with $PGMI_SSH_PASSPHRASE
+// if it is encrypted.
+func loadSSHKey(path string) (ssh.Signer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("SSH key: %w", err)
+	}
+	signer, err := ssh.ParsePrivateKey(data)
+	var missing *ssh.PassphraseMissingError
+	if errors.As(err, &missing) {
+		passphrase := os.Getenv("PGMI_SSH_PASSPHRASE")
+		if passphrase == "" {
+			return nil, fmt.Errorf("SSH key %s is encrypted: set $PGMI_SSH_PASSPHRASE or add it to an ssh-agent: %w", path, pgmi.ErrInvalidConfig)
+		}
+		signer, err = ssh.ParsePrivateKeyWithPassphrase(data, []byte(passphrase))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("SSH key %s: %w", path, err)
+	}
+	return signer, nil
+}
+
+// DialContext opens a connection to addr from the SSH server. It is a
+// pgconn.DialFunc.
+func (t *SSHTunnel) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	client, err := t.sshClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := client.DialContext(ctx, network, addr)
+	if err != nil {
+		return nil, fmt.Errorf("SSH tunnel via %s to %s: %w", t.config.address(), addr, err)
+	}
+	return conn, nil
+}
+
+// LookupFunc returns host unresolved, for the SSH server to resolve. A
+// database behind a bastion often has a name only the bastion can resolve.
+// It is a pgconn.LookupFunc.
+func (t *SSHTunnel) LookupFunc(_ context.Context, host string) ([]string, error) {
+	return []string{host}, nil
+}
+
+// sshClient returns the open SSH connection, opening one if there is none.
+func (t *SSHTunnel) sshClient(ctx context.Context) (*ssh.Client, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return nil, errors.New("SSH tunnel is closed")
+	}
+	if t.client != nil {
+		return t.client, nil
+	}
+
+	address := t.config.address()
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil, fmt.Errorf("SSH tunnel: %w", err)
+	}
+	// The handshake has no context of its own; the deadline stands in.
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	sshConn, chans, reqs, err := ssh.NewClientConn(conn, address, t.clientConfig)
+	if err != nil {
+		_ = conn.Close()
+		var keyErr *knownhosts.KeyError
+		if errors.As(err, &keyErr) {
+			if len(keyErr.Want) == 0 {
+				return nil, fmt.Errorf("SSH tunnel: %s is not in known_hosts; add its host key (ssh-keyscan) after checking it: %w", address, pgmi.ErrConnectionFailed)
+			}
+			return nil, fmt.Errorf("SSH tunnel: the host key of %s does not match known_hosts; it changed or someone is in the middle: %w", address, pgmi.ErrConnectionFailed)
+		}
+		return nil, fmt.Errorf("SSH tunnel to %s: %w", t.config, err)
+	}
+	_ = conn.SetDeadline(time.Time{})
+
+	client := ssh.NewClient(sshConn, chans, reqs)
+	t.client = client
+	go t.keepalive(client)
+	go func() {
+		_ = client.Wait()
+		t.mu.Lock()
+		if t.client == client {
+			t.client = nil
+		}
+		t.mu.Unlock()
+	}()
+	return client, nil
+}
+
+// keepalive pings the server until the connection ends, closing it when a
+// ping fails so the next dial opens a new one.
+func (t *SSHTunnel) keepalive(client *ssh.Client) {
+	ticker := time.NewTicker(sshKeepaliveInterval)
+	defer ticker.Stop()
+	done := make(chan struct{})
+	go func() {
+		_ = client.Wait()
+		close(done)
+	}()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			if _, _, err := client.SendRequest("keepalive@openssh.com", true, nil); err != nil {
+				_ = client.Close()
+				return
+			}
+		}
+	}
+}
+
+// Close closes the SSH connection, and with it every connection tunneled
+// through it.
+func (t *SSHTunnel) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.closed = true
+	var err error
+	if t.client != nil {
+		err = t.client.Close()
+		t.client = nil
+	}
+	if t.agentConn != nil {
+		_ = t.agentConn.Close()
+	}
+	return err
+}
+
+// String returns a human-readable representation of the tunnel.
+func (t *SSHTunnel) String() string {
+	return fmt.Sprintf("SSHTunnel(%s)", t.config)
+}
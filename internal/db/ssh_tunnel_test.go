@@ -0,0 +1,543 @@
+package db
+
+import (
+	"bufio"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+
+	"github.com/vvka-141/pgmi/pkg/pgmi"
+)
+
+// testSSHServer is an in-process SSH server accepting one client key and
+// forwarding direct-tcpip channels, as a bastion does for `ssh -L`.
+type testSSHServer struct {
+	addr    string
+	hostKey ssh.Signer
+
+	connections atomic.Int32 // SSH connections accepted
+	forwards    atomic.Int32 // direct-tcpip channels opened
+
+	mu     sync.Mutex
+	conns  []net.Conn
+	target string // last forwarded-to address
+}
+
+func newTestSSHServer(t *testing.T, clientKey ssh.PublicKey) *testSSHServer {
+	t.Helper()
+	hostKey := newTestSigner(t)
+	s := &testSSHServer{hostKey: hostKey}
+
+	config := &ssh.ServerConfig{
+		PublicKeyCallback: func(_ ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if string(key.Marshal()) == string(clientKey.Marshal()) {
+				return nil, nil
+			}
+			return nil, errors.New("unknown key")
+		},
+	}
+	config.AddHostKey(hostKey)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+	s.addr = listener.Addr().String()
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			s.mu.Lock()
+			s.conns = append(s.conns, conn)
+			s.mu.Unlock()
+			go s.serve(conn, config)
+		}
+	}()
+	return s
+}
+
+func (s *testSSHServer) serve(conn net.Conn, config *ssh.ServerConfig) {
+	_, chans, reqs, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		return
+	}
+	s.connections.Add(1)
+	go ssh.DiscardRequests(reqs)
+	for newChannel := range chans {
+		if newChannel.ChannelType() != "direct-tcpip" {
+			_ = newChannel.Reject(ssh.UnknownChannelType, "only direct-tcpip")
+			continue
+		}
+		var payload struct {
+			Host       string
+			Port       uint32
+			OriginHost string
+			OriginPort uint32
+		}
+		if err := ssh.Unmarshal(newChannel.ExtraData(), &payload); err != nil {
+			_ = newChannel.Reject(ssh.Prohibited, "bad payload")
+			continue
+		}
+		target := net.JoinHostPort(payload.Host, strconv.Itoa(int(payload.Port)))
+		s.mu.Lock()
+		s.target = target
+		s.mu.Unlock()
+
+		upstream, err := net.Dial("tcp", target)
+		if err != nil {
+			_ = newChannel.Reject(ssh.ConnectionFailed, err.Error())
+			continue
+		}
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			_ = upstream.Close()
+			continue
+		}
+		s.forwards.Add(1)
+		go ssh.DiscardRequests(requests)
+		go func() {
+			_, _ = io.Copy(channel, upstream)
+			_ = channel.CloseWrite()
+		}()
+		go func() {
+			_, _ = io.Copy(upstream, channel)
+			_ = upstream.Close()
+		}()
+	}
+}
+
+// dropConnections closes every SSH connection, as a bastion restart would.
+func (s *testSSHServer) dropConnections() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, conn := range s.conns {
+		_ = conn.Close()
+	}
+	s.conns = nil
+}
+
+func (s *testSSHServer) lastTarget() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.target
+}
+
+// knownHostsFile writes a known_hosts file holding key for the server.
+func (s *testSSHServer) knownHostsFile(t *testing.T, key ssh.PublicKey) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "known_hosts")
+	line := knownhosts.Line([]string{knownhosts.Normalize(s.addr)}, key)
+	if err := os.WriteFile(path, []byte(line+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func (s *testSSHServer) tunnelConfig(t *testing.T) SSHTunnelConfig {
+	t.Helper()
+	host, port, _ := net.SplitHostPort(s.addr)
+	p, _ := strconv.Atoi(port)
+	return SSHTunnelConfig{Host: host, Port: p, User: "deploy"}
+}
+
+func newTestSigner(t *testing.T) ssh.Signer {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signer
+}
+
+// writeTestKey writes key in OpenSSH format, encrypted if passphrase is set.
+func writeTestKey(t *testing.T, passphrase string) (string, ssh.PublicKey) {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var block *pem.Block
+	if passphrase == "" {
+		block, err = ssh.MarshalPrivateKey(key, "")
+	} else {
+		block, err = ssh.MarshalPrivateKeyWithPassphrase(key, "", []byte(passphrase))
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "id_ed25519")
+	if err := os.WriteFile(path, pem.EncodeToMemory(block), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path, signer.PublicKey()
+}
+
+// startEchoServer stands in for the database: it echoes each line back.
+func startEchoServer(t *testing.T) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				_, _ = io.Copy(conn, conn)
+			}()
+		}
+	}()
+	return listener.Addr().String()
+}
+
+func echoThrough(t *testing.T, tunnel *SSHTunnel, addr string) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	conn, err := tunnel.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		t.Fatalf("DialContext: %v", err)
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte("SELECT 1\n")); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil || line != "SELECT 1\n" {
+		t.Fatalf("echo = %q, %v", line, err)
+	}
+}
+
+func TestSSHTunnel_ForwardsThroughTheServer(t *testing.T) {
+	keyFile, publicKey := writeTestKey(t, "")
+	server := newTestSSHServer(t, publicKey)
+	target := startEchoServer(t)
+
+	cfg := server.tunnelConfig(t)
+	cfg.KeyFile = keyFile
+	cfg.KnownHostsFile = server.knownHostsFile(t, server.hostKey.PublicKey())
+	tunnel, err := NewSSHTunnel(cfg)
+	if err != nil {
+		t.Fatalf("NewSSHTunnel: %v", err)
+	}
+	defer tunnel.Close()
+
+	echoThrough(t, tunnel, target)
+	echoThrough(t, tunnel, target)
+
+	if got := server.connections.Load(); got != 1 {
+		t.Errorf("SSH connections = %d, want one shared by every dial", got)
+	}
+	if got := server.forwards.Load(); got != 2 {
+		t.Errorf("forwarded channels = %d, want 2", got)
+	}
+	if got := server.lastTarget(); got != target {
+		t.Errorf("server forwarded to %q, want %q", got, target)
+	}
+}
+
+func TestSSHTunnel_ReconnectsAfterTheServerDropsIt(t *testing.T) {
+	keyFile, publicKey := writeTestKey(t, "")
+	server := newTestSSHServer(t, publicKey)
+	target := startEchoServer(t)
+
+	cfg := server.tunnelConfig(t)
+	cfg.KeyFile = keyFile
+	cfg.KnownHostsFile = server.knownHostsFile(t, server.hostKey.PublicKey())
+	tunnel, err := NewSSHTunnel(cfg)
+	if err != nil {
+		t.Fatalf("NewSSHTunnel: %v", err)
+	}
+	defer tunnel.Close()
+
+	echoThrough(t, tunnel, target)
+	server.dropConnections()
+
+	// The tunnel notices the drop asynchronously.
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		tunnel.mu.Lock()
+		dropped := tunnel.client == nil
+		tunnel.mu.Unlock()
+		if dropped || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	echoThrough(t, tunnel, target)
+	if got := server.connections.Load(); got != 2 {
+		t.Errorf("SSH connections = %d, want a new one after the drop", got)
+	}
+}
+
+func TestSSHTunnel_HostKeyVerification(t *testing.T) {
+	keyFile, publicKey := writeTestKey(t, "")
+	server := newTestSSHServer(t, publicKey)
+	target := startEchoServer(t)
+
+	t.Run("unknown host", func(t *testing.T) {
+		cfg := server.tunnelConfig(t)
+		cfg.KeyFile = keyFile
+		cfg.KnownHostsFile = filepath.Join(t.TempDir(), "known_hosts")
+		if err := os.WriteFile(cfg.KnownHostsFile, nil, 0o600); err != nil {
+			t.Fatal(err)
+		}
+		tunnel, err := NewSSHTunnel(cfg)
+		if err != nil {
+			t.Fatalf("NewSSHTunnel: %v", err)
+		}
+		defer tunnel.Close()
+
+		_, err = tunnel.DialContext(context.Background(), "tcp", target)
+		if !errors.Is(err, pgmi.ErrConnectionFailed) {
+			t.Errorf("expected ErrConnectionFailed for an unknown host key, got %v", err)
+		}
+		if server.forwards.Load() != 0 {
+			t.Error("a connection was forwarded by an unverified server")
+		}
+	})
+
+	t.Run("changed key", func(t *testing.T) {
+		cfg := server.tunnelConfig(t)
+		cfg.KeyFile = keyFile
+		cfg.KnownHostsFile = server.knownHostsFile(t, newTestSigner(t).PublicKey())
+		tunnel, err := NewSSHTunnel(cfg)
+		if err != nil {
+			t.Fatalf("NewSSHTunnel: %v", err)
+		}
+		defer tunnel.Close()
+
+		_, err = tunnel.DialContext(context.Background(), "tcp", target)
+		if !errors.Is(err, pgmi.ErrConnectionFailed) {
+			t.Errorf("expected ErrConnectionFailed for a changed host key, got %v", err)
+		}
+	})
+
+	t.Run("missing known_hosts", func(t *testing.T) {
+		cfg := server.tunnelConfig(t)
+		cfg.KeyFile = keyFile
+		cfg.KnownHostsFile = filepath.Join(t.TempDir(), "absent")
+		if _, err := NewSSHTunnel(cfg); !errors.Is(err, pgmi.ErrInvalidConfig) {
+			t.Errorf("expected ErrInvalidConfig, got %v", err)
+		}
+	})
+}
+
+func TestSSHTunnel_EncryptedKey(t *testing.T) {
+	keyFile, publicKey := writeTestKey(t, "s3cret")
+	server := newTestSSHServer(t, publicKey)
+	target := startEchoServer(t)
+
+	cfg := server.tunnelConfig(t)
+	cfg.KeyFile = keyFile
+	cfg.KnownHostsFile = server.knownHostsFile(t, server.hostKey.PublicKey())
+
+	t.Setenv("PGMI_SSH_PASSPHRASE", "")
+	if _, err := NewSSHTunnel(cfg); !errors.Is(err, pgmi.ErrInvalidConfig) {
+		t.Fatalf("without a passphrase: expected ErrInvalidConfig, got %v", err)
+	}
+
+	t.Setenv("PGMI_SSH_PASSPHRASE", "s3cret")
+	tunnel, err := NewSSHTunnel(cfg)
+	if err != nil {
+		t.Fatalf("NewSSHTunnel: %v", err)
+	}
+	defer tunnel.Close()
+	echoThrough(t, tunnel, target)
+}
+
+func TestSSHTunnel_Agent(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("ssh-agent sockets are Unix sockets")
+	}
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyring := agent.NewKeyring()
+	if err := keyring.Add(agent.AddedKey{PrivateKey: key}); err != nil {
+		t.Fatal(err)
+	}
+	signers, err := keyring.Signers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := newTestSSHServer(t, signers[0].PublicKey())
+	target := startEchoServer(t)
+
+	// os.MkdirTemp keeps the socket path under the Unix limit.
+	dir, err := os.MkdirTemp("", "pgmi-agent")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+	listener, err := net.Listen("unix", filepath.Join(dir, "agent.sock"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() { _ = agent.ServeAgent(keyring, conn) }()
+		}
+	}()
+	t.Setenv("SSH_AUTH_SOCK", listener.Addr().String())
+	t.Setenv("HOME", t.TempDir()) // no default keys to fall back on
+
+	cfg := server.tunnelConfig(t)
+	cfg.KnownHostsFile = server.knownHostsFile(t, server.hostKey.PublicKey())
+	tunnel, err := NewSSHTunnel(cfg)
+	if err != nil {
+		t.Fatalf("NewSSHTunnel: %v", err)
+	}
+	defer tunnel.Close()
+	echoThrough(t, tunnel, target)
+}
+
+func TestSSHTunnel_NoCredentials(t *testing.T) {
+	t.Setenv("SSH_AUTH_SOCK", "")
+	t.Setenv("HOME", t.TempDir())
+	known := filepath.Join(t.TempDir(), "known_hosts")
+	if err := os.WriteFile(known, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	_, err := NewSSHTunnel(SSHTunnelConfig{Host: "bastion.example.com", User: "deploy", KnownHostsFile: known})
+	if !errors.Is(err, pgmi.ErrInvalidConfig) {
+		t.Errorf("expected ErrInvalidConfig, got %v", err)
+	}
+}
+
+func TestSSHTunnel_ClosedTunnelRefusesDials(t *testing.T) {
+	keyFile, publicKey := writeTestKey(t, "")
+	server := newTestSSHServer(t, publicKey)
+
+	cfg := server.tunnelConfig(t)
+	cfg.KeyFile = keyFile
+	cfg.KnownHostsFile = server.knownHostsFile(t, server.hostKey.PublicKey())
+	tunnel, err := NewSSHTunnel(cfg)
+	if err != nil {
+		t.Fatalf("NewSSHTunnel: %v", err)
+	}
+	_ = tunnel.Close()
+	if _, err := tunnel.DialContext(context.Background(), "tcp", "127.0.0.1:5432"); err == nil {
+		t.Error("expected a closed tunnel to refuse dials")
+	}
+}
+
+// TestSSHTunnel_PoolHooks checks a connector given the tunnel's hooks dials
+// through it, leaving the database host for the SSH server to resolve.
+func TestSSHTunnel_PoolHooks(t *testing.T) {
+	keyFile, publicKey := writeTestKey(t, "")
+	server := newTestSSHServer(t, publicKey)
+
+	cfg := server.tunnelConfig(t)
+	cfg.KeyFile = keyFile
+	cfg.KnownHostsFile = server.knownHostsFile(t, server.hostKey.PublicKey())
+	tunnel, err := NewSSHTunnel(cfg)
+	if err != nil {
+		t.Fatalf("NewSSHTunnel: %v", err)
+	}
+	defer tunnel.Close()
+
+	// Only the bastion could resolve this name; the test server fails to,
+	// after recording it.
+	connector, err := NewConnectorWithHooks(&pgmi.ConnectionConfig{
+		Host: "db.behind-bastion.invalid", Port: 5432, Database: "appdb", Username: "app", SSLMode: "disable",
+	}, PoolHooks{DialFunc: tunnel.DialContext, LookupFunc: tunnel.LookupFunc})
+	if err != nil {
+		t.Fatalf("NewConnectorWithHooks: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if pool, err := connector.Connect(ctx); err == nil {
+		pool.Close()
+		t.Fatal("expected the connection to fail")
+	}
+	if got := server.lastTarget(); got != "db.behind-bastion.invalid:5432" {
+		t.Errorf("SSH server was asked for %q, want the unresolved database host", got)
+	}
+}
+
+func TestSSHTunnel_Integration(t *testing.T) {
+	connConfig := parseTestConnConfig(t, requireTokenTestDB(t))
+
+	keyFile, publicKey := writeTestKey(t, "")
+	server := newTestSSHServer(t, publicKey)
+	cfg := server.tunnelConfig(t)
+	cfg.KeyFile = keyFile
+	cfg.KnownHostsFile = server.knownHostsFile(t, server.hostKey.PublicKey())
+	tunnel, err := NewSSHTunnel(cfg)
+	if err != nil {
+		t.Fatalf("NewSSHTunnel: %v", err)
+	}
+	defer tunnel.Close()
+	hooks := PoolHooks{DialFunc: tunnel.DialContext, LookupFunc: tunnel.LookupFunc}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	// A deploy opens the maintenance database and then the target, each
+	// through its own connector.
+	maintenance := connConfig.DeepCopy()
+	maintenance.Database = pgmi.DefaultMaintenanceDB
+	for _, c := range []*pgmi.ConnectionConfig{&maintenance, connConfig} {
+		connector, err := NewConnectorWithHooks(c, hooks)
+		if err != nil {
+			t.Fatalf("NewConnectorWithHooks: %v", err)
+		}
+		pool, err := connector.Connect(ctx)
+		if err != nil {
+			t.Fatalf("Connect to %s through the tunnel: %v", c.Database, err)
+		}
+		var one int
+		err = pool.QueryRow(ctx, "SELECT 1").Scan(&one)
+		pool.Close()
+		if err != nil || one != 1 {
+			t.Fatalf("SELECT 1 on %s: %d, %v", c.Database, one, err)
+		}
+	}
+
+	if got := server.forwards.Load(); got < 2 {
+		t.Errorf("forwarded channels = %d, want both databases tunneled", got)
+	}
+	if got := server.connections.Load(); got != 1 {
+		t.Errorf("SSH connections = %d, want one shared by both", got)
+	}
+}
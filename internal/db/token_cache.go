@@ -0,0 +1,69 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// tokenRefreshMargin is how long before its expiry a cached token is
+// replaced. A token only has to be valid when the server checks it at login,
+// so the margin covers the dial, the TLS handshake and clock skew between
+// pgmi and the issuer.
+const tokenRefreshMargin = 2 * time.Minute
+
+// CachingTokenProvider hands out its provider's token until it comes within
+// tokenRefreshMargin of expiring, then fetches a new one. A TokenBasedConnector
+// asks for a token on every dial, so without the cache each new backend would
+// cost a round trip to the identity provider.
+//
+// A token whose expiry is unknown is never cached: the provider is asked
+// every time, which is what an exec command or token file expects.
+type CachingTokenProvider struct {
+	provider TokenProvider
+	now      func() time.Time
+
+	// onAcquire is called with each token fetched from provider, never with
+	// a cached one.
+	onAcquire func(expiresOn time.Time)
+
+	mu        sync.Mutex
+	token     string
+	expiresOn time.Time
+}
+
+// NewCachingTokenProvider creates a cache in front of provider.
+func NewCachingTokenProvider(provider TokenProvider) *CachingTokenProvider {
+	return &CachingTokenProvider{provider: provider, now: time.Now}
+}
+
+// GetToken returns the cached token while it is fresh, and otherwise the
+// provider's next one. Concurrent dials share a single refresh.
+func (p *CachingTokenProvider) GetToken(ctx context.Context) (string, time.Time, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.token != "" && p.now().Add(tokenRefreshMargin).Before(p.expiresOn) {
+		return p.token, p.expiresOn, nil
+	}
+
+	token, expiresOn, err := p.provider.GetToken(ctx)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	if p.onAcquire != nil {
+		p.onAcquire(expiresOn)
+	}
+	if expiresOn.IsZero() {
+		p.token, p.expiresOn = "", time.Time{}
+	} else {
+		p.token, p.expiresOn = token, expiresOn
+	}
+	return token, expiresOn, nil
+}
+
+// String returns a human-readable representation of the provider.
+func (p *CachingTokenProvider) String() string {
+	return fmt.Sprintf("Caching(%s)", p.provider)
+}
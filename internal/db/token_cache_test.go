@@ -0,0 +1,181 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/vvka-141/pgmi/pkg/pgmi"
+)
+
+// shortLivedTokenProvider issues a new token on every call, valid for ttl
+// from the fake clock's current time. A zero ttl issues tokens of unknown
+// lifetime.
+type shortLivedTokenProvider struct {
+	clock *fakeClock
+	ttl   time.Duration
+	err   error
+
+	mu     sync.Mutex
+	issued int
+}
+
+func (p *shortLivedTokenProvider) GetToken(context.Context) (string, time.Time, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.err != nil {
+		return "", time.Time{}, p.err
+	}
+	p.issued++
+	token := fmt.Sprintf("token-%d", p.issued)
+	if p.ttl == 0 {
+		return token, time.Time{}, nil
+	}
+	return token, p.clock.now().Add(p.ttl), nil
+}
+
+func (p *shortLivedTokenProvider) String() string { return "ShortLivedTokenProvider" }
+
+type fakeClock struct{ t time.Time }
+
+func (c *fakeClock) now() time.Time          { return c.t }
+func (c *fakeClock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func newTestCache(provider *shortLivedTokenProvider) *CachingTokenProvider {
+	cache := NewCachingTokenProvider(provider)
+	cache.now = provider.clock.now
+	return cache
+}
+
+func mustToken(t *testing.T, p TokenProvider) string {
+	t.Helper()
+	token, _, err := p.GetToken(context.Background())
+	if err != nil {
+		t.Fatalf("GetToken: %v", err)
+	}
+	return token
+}
+
+func TestCachingTokenProvider_RefreshesNearExpiry(t *testing.T) {
+	clock := &fakeClock{t: time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)}
+	provider := &shortLivedTokenProvider{clock: clock, ttl: 15 * time.Minute}
+	cache := newTestCache(provider)
+
+	if got := mustToken(t, cache); got != "token-1" {
+		t.Fatalf("first token = %q", got)
+	}
+
+	// A dial ten minutes in reuses the token.
+	clock.advance(10 * time.Minute)
+	if got := mustToken(t, cache); got != "token-1" {
+		t.Errorf("within its lifetime: token = %q, want the cached token-1", got)
+	}
+
+	// Inside the refresh margin the next dial gets a new one.
+	clock.advance(15*time.Minute - 10*time.Minute - tokenRefreshMargin + time.Second)
+	if got := mustToken(t, cache); got != "token-2" {
+		t.Errorf("near expiry: token = %q, want token-2", got)
+	}
+
+	// A multi-hour tail keeps getting current tokens.
+	for i := 3; i <= 10; i++ {
+		clock.advance(15 * time.Minute)
+		if got, want := mustToken(t, cache), fmt.Sprintf("token-%d", i); got != want {
+			t.Fatalf("after %d refreshes: token = %q, want %q", i-1, got, want)
+		}
+	}
+}
+
+func TestCachingTokenProvider_UnknownExpiryIsNotCached(t *testing.T) {
+	provider := &shortLivedTokenProvider{clock: &fakeClock{t: time.Now()}}
+	cache := newTestCache(provider)
+
+	mustToken(t, cache)
+	if got := mustToken(t, cache); got != "token-2" {
+		t.Errorf("token = %q, want the provider asked again", got)
+	}
+}
+
+func TestCachingTokenProvider_TokenShorterThanMargin(t *testing.T) {
+	provider := &shortLivedTokenProvider{clock: &fakeClock{t: time.Now()}, ttl: time.Minute}
+	cache := newTestCache(provider)
+
+	mustToken(t, cache)
+	if got := mustToken(t, cache); got != "token-2" {
+		t.Errorf("token = %q, want a new token for each dial", got)
+	}
+}
+
+func TestCachingTokenProvider_ErrorKeepsNothing(t *testing.T) {
+	clock := &fakeClock{t: time.Now()}
+	provider := &shortLivedTokenProvider{clock: clock, ttl: 15 * time.Minute}
+	cache := newTestCache(provider)
+	mustToken(t, cache)
+
+	clock.advance(15 * time.Minute)
+	provider.err = errors.New("identity provider unavailable")
+	if _, _, err := cache.GetToken(context.Background()); err == nil {
+		t.Fatal("expected the provider's error once the cached token is stale")
+	}
+
+	provider.err = nil
+	if got := mustToken(t, cache); got != "token-2" {
+		t.Errorf("after recovery: token = %q, want token-2", got)
+	}
+}
+
+type recordingLogger struct {
+	mu      sync.Mutex
+	verbose []string
+}
+
+func (l *recordingLogger) Verbose(format string, args ...any) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.verbose = append(l.verbose, fmt.Sprintf(format, args...))
+}
+func (l *recordingLogger) Info(string, ...any)  {}
+func (l *recordingLogger) Error(string, ...any) {}
+
+func TestTokenBasedConnector_LogsTokenExpiry(t *testing.T) {
+	clock := &fakeClock{t: time.Now()}
+	provider := &shortLivedTokenProvider{clock: clock, ttl: 15 * time.Minute}
+	logger := &recordingLogger{}
+
+	connector := NewTokenBasedConnector(&pgmi.ConnectionConfig{}, provider, "AWS IAM")
+	connector.tokens.now = clock.now
+	connector.setHooks(PoolHooks{Logger: logger})
+
+	mustToken(t, connector.tokens)
+	mustToken(t, connector.tokens)
+	clock.advance(14 * time.Minute)
+	mustToken(t, connector.tokens)
+
+	if len(logger.verbose) != 2 {
+		t.Fatalf("expected one line per acquired token, got %q", logger.verbose)
+	}
+	want := clock.now().Add(15 * time.Minute).Format(time.RFC3339)
+	if line := logger.verbose[1]; !strings.Contains(line, "AWS IAM token") || !strings.Contains(line, want) {
+		t.Errorf("log line = %q, want the provider and the expiry %s", line, want)
+	}
+	if strings.Contains(strings.Join(logger.verbose, "\n"), "token-") {
+		t.Errorf("a token leaked into the log: %q", logger.verbose)
+	}
+}
+
+func TestTokenBasedConnector_LogsUnknownExpiry(t *testing.T) {
+	provider := &shortLivedTokenProvider{clock: &fakeClock{t: time.Now()}}
+	logger := &recordingLogger{}
+
+	connector := NewTokenBasedConnector(&pgmi.ConnectionConfig{}, provider, "exec token")
+	connector.setHooks(PoolHooks{Logger: logger})
+	mustToken(t, connector.tokens)
+
+	if len(logger.verbose) != 1 || !strings.Contains(logger.verbose[0], "expiry is unknown") {
+		t.Errorf("log = %q, want the unknown expiry reported", logger.verbose)
+	}
+}
@@ -12,12 +12,19 @@ import (
 	"github.com/vvka-141/pgmi/pkg/pgmi"
 )
 
-// TokenBasedConnector implements the Connector interface for cloud providers
-// that authenticate via short-lived tokens (AWS IAM, Azure Entra ID).
+// TokenBasedConnector implements the Connector interface for authentication
+// via short-lived tokens (AWS IAM, Azure Entra ID, exec commands, token files).
 // The token is acquired from a TokenProvider and used as the PostgreSQL password.
+//
+// Tokens are cached until shortly before they expire and fetched again when a
+// new backend is dialed after that, so a deploy outliving its first token —
+// a long backfill, or a later connection to the maintenance database — logs
+// in with a current one.
 type TokenBasedConnector struct {
+	poolHooks
 	config        *pgmi.ConnectionConfig
 	tokenProvider TokenProvider
+	tokens        *CachingTokenProvider
 	retryExecutor *retry.Executor
 	providerName  string
 }
@@ -32,12 +39,24 @@ func NewTokenBasedConnector(config *pgmi.ConnectionConfig, tokenProvider TokenPr
 	)
 	executor := retry.NewExecutor(classifier, strategy)
 
-	return &TokenBasedConnector{
+	c := &TokenBasedConnector{
 		config:        config,
 		tokenProvider: tokenProvider,
+		tokens:        NewCachingTokenProvider(tokenProvider),
 		retryExecutor: executor,
 		providerName:  providerName,
 	}
+	c.tokens.onAcquire = c.logTokenExpiry
+	return c
+}
+
+func (c *TokenBasedConnector) logTokenExpiry(expiresOn time.Time) {
+	if expiresOn.IsZero() {
+		c.verbose("Acquired %s token; its expiry is unknown, so each new connection fetches another", c.providerName)
+		return
+	}
+	c.verbose("Acquired %s token, valid until %s (%v)", c.providerName,
+		expiresOn.Format(time.RFC3339), time.Until(expiresOn).Round(time.Second))
 }
 
 func (c *TokenBasedConnector) Connect(ctx context.Context) (*pgxpool.Pool, error) {
@@ -45,12 +64,12 @@ func (c *TokenBasedConnector) Connect(ctx context.Context) (*pgxpool.Pool, error
 
 	err := c.retryExecutor.Execute(ctx, func(ctx context.Context) error {
 		// Initial token — also doubles as a reachability check before pool construction.
-		token, expiresOn, err := c.tokenProvider.GetToken(ctx)
+		token, expiresOn, err := c.tokens.GetToken(ctx)
 		if err != nil {
 			return fmt.Errorf("failed to acquire %s token: %w", c.providerName, err)
 		}
 
-		if time.Until(expiresOn) < 5*time.Minute {
+		if !expiresOn.IsZero() && time.Until(expiresOn) < 5*time.Minute {
 			fmt.Fprintf(os.Stderr, "Warning: %s token expires in %v\n", c.providerName, time.Until(expiresOn).Round(time.Second))
 		}
 
@@ -64,18 +83,19 @@ func (c *TokenBasedConnector) Connect(ctx context.Context) (*pgxpool.Pool, error
 			return fmt.Errorf("failed to parse connection config: %w", err)
 		}
 
-		configurePool(poolConfig)
+		c.configurePool(poolConfig)
 
 		// Cloud auth tokens are short-lived (AWS RDS IAM: 15 min; Azure/GCP
 		// ~1h). Every time pgx dials a NEW backend (initial fill, growth,
-		// replacement after idle timeout), BeforeConnect acquires a fresh
-		// token so deployments longer than the token TTL keep working.
+		// replacement after idle timeout), BeforeConnect asks the cache for
+		// a token, which is the one above until it nears expiry and a new
+		// one after, so deployments longer than the token TTL keep working.
 		// The token baked into connStr above is only used as a fallback
 		// when BeforeConnect errors — in practice it's overwritten below.
-		tokenProvider := c.tokenProvider
+		tokens := c.tokens
 		providerName := c.providerName
 		poolConfig.BeforeConnect = func(ctx context.Context, cc *pgx.ConnConfig) error {
-			freshToken, _, tokenErr := tokenProvider.GetToken(ctx)
+			freshToken, _, tokenErr := tokens.GetToken(ctx)
 			if tokenErr != nil {
 				return fmt.Errorf("failed to refresh %s token on dial: %w", providerName, tokenErr)
 			}
@@ -154,7 +154,7 @@ func TestTokenBasedConnector_Connect(t *testing.T) {
 		}
 	})
 
-	t.Run("token used on initial dial and BeforeConnect fires", func(t *testing.T) {
+	t.Run("a fresh token is cached across dials", func(t *testing.T) {
 		provider := &countingTokenProvider{
 			token:     realConfig.Password,
 			expiresOn: time.Now().Add(1 * time.Hour),
@@ -173,6 +173,30 @@ func TestTokenBasedConnector_Connect(t *testing.T) {
 		}
 		defer pool.Close()
 
+		if calls := provider.calls.Load(); calls != 1 {
+			t.Errorf("expected the initial token to serve the BeforeConnect dial, got %d GetToken calls", calls)
+		}
+	})
+
+	t.Run("token used on initial dial and BeforeConnect refreshes a short-lived one", func(t *testing.T) {
+		provider := &countingTokenProvider{
+			token:     realConfig.Password,
+			expiresOn: time.Now().Add(tokenRefreshMargin / 2),
+		}
+		config := &pgmi.ConnectionConfig{
+			Host:     realConfig.Host,
+			Port:     realConfig.Port,
+			Database: realConfig.Database,
+			Username: realConfig.Username,
+			SSLMode:  realConfig.SSLMode,
+		}
+		connector := NewTokenBasedConnector(config, provider, "Test")
+		pool, err := connector.Connect(context.Background())
+		if err != nil {
+			t.Fatalf("Connect() error = %v", err)
+		}
+		defer pool.Close()
+
 		calls := provider.calls.Load()
 		if calls < 2 {
 			t.Errorf("expected at least 2 GetToken calls (initial + BeforeConnect dial), got %d", calls)
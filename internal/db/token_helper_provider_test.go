@@ -0,0 +1,136 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vvka-141/pgmi/pkg/pgmi"
+)
+
+func requireShell(t *testing.T) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("the stub token commands are sh scripts")
+	}
+}
+
+func TestExecTokenProvider(t *testing.T) {
+	requireShell(t)
+	config := &pgmi.ConnectionConfig{Host: "db.internal", Port: 6543, Username: "deploy", Database: "orders"}
+
+	t.Run("parses the token and expiry, and names the connection", func(t *testing.T) {
+		script := `printf '{"token": "%s@%s:%s/%s", "expiresAt": "2030-01-02T03:04:05Z"}' "$PGMI_TOKEN_USER" "$PGMI_TOKEN_HOST" "$PGMI_TOKEN_PORT" "$PGMI_TOKEN_DATABASE"`
+		p, err := NewExecTokenProvider([]string{"sh", "-c", script}, config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		token, expiresOn, err := p.GetToken(context.Background())
+		if err != nil {
+			t.Fatalf("GetToken: %v", err)
+		}
+		if token != "deploy@db.internal:6543/orders" {
+			t.Errorf("token = %q", token)
+		}
+		if !expiresOn.Equal(time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)) {
+			t.Errorf("expiresOn = %v", expiresOn)
+		}
+	})
+
+	t.Run("an omitted expiry is zero", func(t *testing.T) {
+		p, _ := NewExecTokenProvider([]string{"sh", "-c", `echo '{"token": "t"}'`}, config)
+		if _, expiresOn, err := p.GetToken(context.Background()); err != nil || !expiresOn.IsZero() {
+			t.Errorf("expiresOn = %v, err = %v", expiresOn, err)
+		}
+	})
+
+	for name, tt := range map[string]struct{ script, want string }{
+		"failure reports stderr": {`echo "vault: permission denied" >&2; exit 2`, "vault: permission denied"},
+		"not JSON":               {`echo s3cr3t-token`, "expected JSON"},
+		"JSON without a token":   {`echo '{"expiresAt": "2030-01-02T03:04:05Z"}'`, `no "token"`},
+		"unparseable expiry":     {`echo '{"token": "t", "expiresAt": "tomorrow"}'`, "expected JSON"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			p, _ := NewExecTokenProvider([]string{"sh", "-c", tt.script}, config)
+			_, _, err := p.GetToken(context.Background())
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("expected an error containing %q, got %v", tt.want, err)
+			}
+			if strings.Contains(err.Error(), "s3cr3t-token") {
+				t.Errorf("the error quotes the command's output: %v", err)
+			}
+		})
+	}
+
+	if _, err := NewExecTokenProvider(nil, config); !errors.Is(err, pgmi.ErrInvalidConfig) {
+		t.Errorf("no command: expected ErrInvalidConfig, got %v", err)
+	}
+}
+
+func TestFileTokenProvider(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "token")
+	p, err := NewFileTokenProvider(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := p.GetToken(context.Background()); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+
+	if err := os.WriteFile(path, []byte("first-token\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	token, expiresOn, err := p.GetToken(context.Background())
+	if err != nil || token != "first-token" || !expiresOn.IsZero() {
+		t.Fatalf("bare token: %q, %v, %v", token, expiresOn, err)
+	}
+
+	// An agent rotates the file; the next dial gets the new token.
+	rotated := []byte(`{"token": "second-token", "expiresAt": "2030-01-02T03:04:05Z"}`)
+	if err := os.WriteFile(path, rotated, 0600); err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Second)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatal(err)
+	}
+	token, expiresOn, err = p.GetToken(context.Background())
+	if err != nil || token != "second-token" || expiresOn.IsZero() {
+		t.Fatalf("rotated JSON token: %q, %v, %v", token, expiresOn, err)
+	}
+
+	if err := os.WriteFile(path, []byte("  \n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := p.GetToken(context.Background()); err == nil || !strings.Contains(err.Error(), "empty") {
+		t.Errorf("empty file: expected an error, got %v", err)
+	}
+
+	if _, err := NewFileTokenProvider(""); !errors.Is(err, pgmi.ErrInvalidConfig) {
+		t.Errorf("no path: expected ErrInvalidConfig, got %v", err)
+	}
+}
+
+func TestNewConnector_TokenHelpers(t *testing.T) {
+	for _, config := range []*pgmi.ConnectionConfig{
+		{Host: "localhost", Port: 5432, AuthMethod: pgmi.AuthMethodExec, TokenCommand: []string{"get-token"}},
+		{Host: "localhost", Port: 5432, AuthMethod: pgmi.AuthMethodTokenFile, TokenFile: "/tmp/token"},
+	} {
+		c, err := NewConnector(config)
+		if err != nil {
+			t.Fatalf("%v: %v", config.AuthMethod, err)
+		}
+		if _, ok := c.(*TokenBasedConnector); !ok {
+			t.Errorf("%v: got %T, want *TokenBasedConnector", config.AuthMethod, c)
+		}
+	}
+	if _, err := NewConnector(&pgmi.ConnectionConfig{AuthMethod: pgmi.AuthMethodExec}); !errors.Is(err, pgmi.ErrInvalidConfig) {
+		t.Errorf("exec without a command: expected ErrInvalidConfig, got %v", err)
+	}
+}
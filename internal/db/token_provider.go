@@ -5,13 +5,14 @@ import (
 	"time"
 )
 
-// TokenProvider abstracts cloud token acquisition for database authentication.
-// This interface enables testability (mock providers) and future extensibility
-// (AWS IAM, GCP, etc. can implement the same interface).
+// TokenProvider abstracts token acquisition for database authentication:
+// cloud IAM (AWS, Azure), an external command, or a file an agent maintains.
+// This interface enables testability (mock providers) and extensibility.
 type TokenProvider interface {
 	// GetToken acquires an OAuth token for database authentication.
 	// The token is used as the password when connecting to cloud-hosted PostgreSQL.
-	// Returns the token string and its expiry time.
+	// Returns the token string and its expiry time, which is zero when the
+	// token's lifetime is unknown.
 	GetToken(ctx context.Context) (token string, expiresOn time.Time, err error)
 
 	// String returns a human-readable description for logging.
@@ -4,6 +4,7 @@
 //   - filesystem: Filesystem abstraction interfaces and implementations (OS and in-memory)
 //   - scanner: File discovery and metadata extraction
 //   - loader: Database loading operations for session-scoped tables
+//   - watch: Change detection over a project tree, for pgmi deploy --watch
 //
 // # Usage
 //
@@ -28,4 +29,5 @@
 //   - filesystem: Provides filesystem abstraction for testability
 //   - scanner: Handles file discovery, checksum calculation, and placeholder detection
 //   - loader: Manages database operations for loading files and parameters
+//   - watch: Polls a project for changes and reports them once they settle
 package files
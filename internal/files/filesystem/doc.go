@@ -13,4 +13,5 @@
 // Implementations:
 //   - OSFileSystem: Production implementation using OS filesystem
 //   - MemoryFileSystem: In-memory implementation for testing
+//   - EmbedFileSystem: embed.FS, or any fs.FS through NewFSFileSystem
 package filesystem
@@ -9,9 +9,9 @@ import (
 	"strings"
 )
 
-// embedFile implements File interface for embed.FS
+// embedFile implements File interface for embed.FS and other fs.FS trees
 type embedFile struct {
-	embedFS *embed.FS
+	embedFS fs.FS
 	absPath string // path within embed.FS (always uses forward slashes)
 	relPath string // relative path from root
 	info    fs.FileInfo
@@ -22,12 +22,12 @@ func (f *embedFile) RelativePath() string { return f.relPath }
 func (f *embedFile) Info() FileInfo       { return f.info }
 
 func (f *embedFile) ReadContent() ([]byte, error) {
-	return f.embedFS.ReadFile(f.absPath)
+	return fs.ReadFile(f.embedFS, f.absPath)
 }
 
-// embedDirectory implements Directory interface for embed.FS
+// embedDirectory implements Directory interface for embed.FS and other fs.FS trees
 type embedDirectory struct {
-	embedFS *embed.FS
+	embedFS fs.FS
 	absPath string // path within embed.FS (always uses forward slashes)
 	root    string // root path for calculating relative paths
 }
@@ -62,9 +62,10 @@ func (d *embedDirectory) Walk(fn func(File, error) error) error {
 	})
 }
 
-// EmbedFileSystem implements FileSystemProvider for embed.FS
+// EmbedFileSystem implements FileSystemProvider for embed.FS, and for any other
+// fs.FS through NewFSFileSystem.
 type EmbedFileSystem struct {
-	embedFS embed.FS
+	embedFS fs.FS
 	root    string // root path within the embed.FS (always uses forward slashes)
 }
 
@@ -72,10 +73,17 @@ type EmbedFileSystem struct {
 // The root parameter specifies the subdirectory within the embed.FS to treat as the root.
 // All paths are normalized to use forward slashes for consistency with embed.FS.
 func NewEmbedFileSystem(embedFS embed.FS, root string) *EmbedFileSystem {
+	return NewFSFileSystem(embedFS, root)
+}
+
+// NewFSFileSystem creates a filesystem provider over any fs.FS — an
+// fstest.MapFS, a zip.Reader, os.DirFS — with the same root handling as
+// NewEmbedFileSystem. Use "." as root for the whole tree.
+func NewFSFileSystem(fsys fs.FS, root string) *EmbedFileSystem {
 	// Normalize root path to use forward slashes and remove trailing slash
 	root = path.Clean(root)
 	return &EmbedFileSystem{
-		embedFS: embedFS,
+		embedFS: fsys,
 		root:    root,
 	}
 }
@@ -101,7 +109,7 @@ func (efs *EmbedFileSystem) Open(openPath string) (Directory, error) {
 	absPath = path.Clean(absPath)
 
 	// Verify path exists and is a directory
-	entries, err := efs.embedFS.ReadDir(absPath)
+	entries, err := fs.ReadDir(efs.embedFS, absPath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open directory %s: %w", openPath, err)
 	}
@@ -110,7 +118,7 @@ func (efs *EmbedFileSystem) Open(openPath string) (Directory, error) {
 	_ = entries // ReadDir succeeds only for directories
 
 	return &embedDirectory{
-		embedFS: &efs.embedFS,
+		embedFS: efs.embedFS,
 		absPath: absPath,
 		root:    efs.root,
 	}, nil
@@ -132,7 +140,7 @@ func (efs *EmbedFileSystem) ReadFile(filePath string) ([]byte, error) {
 	// Clean the path
 	absPath = path.Clean(absPath)
 
-	content, err := efs.embedFS.ReadFile(absPath)
+	content, err := fs.ReadFile(efs.embedFS, absPath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read file %s: %w", filePath, err)
 	}
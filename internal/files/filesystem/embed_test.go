@@ -2,8 +2,10 @@ package filesystem
 
 import (
 	"embed"
+	"slices"
 	"strings"
 	"testing"
+	"testing/fstest"
 
 	"github.com/stretchr/testify/require"
 )
@@ -280,3 +282,34 @@ func TestEmbedFileSystem_RelativePaths(t *testing.T) {
 		require.NotContains(t, p, "\\", "Relative path should use forward slashes: %s", p)
 	}
 }
+
+func TestNewFSFileSystem_MapFS(t *testing.T) {
+	fsys := NewFSFileSystem(fstest.MapFS{
+		"deploy.sql":            {Data: []byte("SELECT 1;")},
+		"migrations/001.sql":    {Data: []byte("CREATE TABLE t (id int);")},
+		"migrations/README.txt": {Data: []byte("notes")},
+	}, ".")
+
+	content, err := fsys.ReadFile("deploy.sql")
+	require.NoError(t, err)
+	require.Equal(t, "SELECT 1;", string(content))
+
+	info, err := fsys.Stat("migrations")
+	require.NoError(t, err)
+	require.True(t, info.IsDir())
+
+	dir, err := fsys.Open(".")
+	require.NoError(t, err)
+	var files []string
+	require.NoError(t, dir.Walk(func(f File, err error) error {
+		if err != nil {
+			return err
+		}
+		if !f.Info().IsDir() {
+			files = append(files, f.RelativePath())
+		}
+		return nil
+	}))
+	slices.Sort(files)
+	require.Equal(t, []string{"deploy.sql", "migrations/001.sql", "migrations/README.txt"}, files)
+}
@@ -12,6 +12,7 @@ import (
 
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/vvka-141/pgmi/internal/metadata"
 	"github.com/vvka-141/pgmi/pkg/pgmi"
 )
 
@@ -23,9 +24,18 @@ func NewLoader() *Loader {
 	return &Loader{}
 }
 
+// unpooled returns the connection a pooled one wraps. nil stays nil: with
+// nothing to load the loader never touches the connection.
+func unpooled(conn *pgxpool.Conn) *pgx.Conn {
+	if conn == nil {
+		return nil
+	}
+	return conn.Conn()
+}
+
 // execBatch sends a prepared batch and checks each result.
 // labels must be parallel to the batch entries (one per queued statement).
-func execBatch(ctx context.Context, conn *pgxpool.Conn, batch *pgx.Batch, labels []string, itemErr, closeErr string) error {
+func execBatch(ctx context.Context, conn *pgx.Conn, batch *pgx.Batch, labels []string, itemErr, closeErr string) error {
 	results := conn.SendBatch(ctx, batch)
 
 	for _, label := range labels {
@@ -46,6 +56,12 @@ func execBatch(ctx context.Context, conn *pgxpool.Conn, batch *pgx.Batch, labels
 // Non-test files go into pg_temp._pgmi_source, test files go into pg_temp._pgmi_test_source.
 // Also loads script metadata (from <pgmi-meta> XML blocks) into pg_temp._pgmi_source_metadata.
 func (l *Loader) LoadFilesIntoSession(ctx context.Context, conn *pgxpool.Conn, files []pgmi.FileMetadata) error {
+	return l.LoadFilesIntoConn(ctx, unpooled(conn), files)
+}
+
+// LoadFilesIntoConn is LoadFilesIntoSession for a connection that did not
+// come from a pool, such as one a pgmi.BorrowedConnector lends.
+func (l *Loader) LoadFilesIntoConn(ctx context.Context, conn *pgx.Conn, files []pgmi.FileMetadata) error {
 	// Separate test files from non-test files
 	var sourceFiles, testFiles []pgmi.FileMetadata
 	for _, f := range files {
@@ -88,8 +104,9 @@ func (l *Loader) LoadFilesIntoSession(ctx context.Context, conn *pgxpool.Conn, f
 //
 // ANALYZE is legal inside a transaction block; VACUUM is not, and is not needed
 // here — these tables have no dead rows.
-func (l *Loader) analyzeSessionTables(ctx context.Context, conn *pgxpool.Conn) error {
+func (l *Loader) analyzeSessionTables(ctx context.Context, conn *pgx.Conn) error {
 	const stmt = `ANALYZE pg_temp._pgmi_source, pg_temp._pgmi_source_metadata,
+	              pg_temp._pgmi_source_metadata_ext,
 	              pg_temp._pgmi_test_source, pg_temp._pgmi_test_directory`
 	if _, err := conn.Exec(ctx, stmt); err != nil {
 		return fmt.Errorf("failed to analyze session tables: %w", err)
@@ -99,7 +116,7 @@ func (l *Loader) analyzeSessionTables(ctx context.Context, conn *pgxpool.Conn) e
 
 // insertTestFiles inserts test file content into pg_temp._pgmi_test_source.
 // Only SQL files are inserted (non-SQL files like README.md are skipped).
-func (l *Loader) insertTestFiles(ctx context.Context, conn *pgxpool.Conn, files []pgmi.FileMetadata) error {
+func (l *Loader) insertTestFiles(ctx context.Context, conn *pgx.Conn, files []pgmi.FileMetadata) error {
 	if len(files) == 0 {
 		return nil
 	}
@@ -134,7 +151,7 @@ func (l *Loader) insertTestFiles(ctx context.Context, conn *pgxpool.Conn, files
 
 // insertTestDirectories extracts unique test directories from file paths and inserts them.
 // Computes parent relationships and depth for hierarchical traversal.
-func (l *Loader) insertTestDirectories(ctx context.Context, conn *pgxpool.Conn, files []pgmi.FileMetadata) error {
+func (l *Loader) insertTestDirectories(ctx context.Context, conn *pgx.Conn, files []pgmi.FileMetadata) error {
 	if len(files) == 0 {
 		return nil
 	}
@@ -259,7 +276,7 @@ func isFixtureFile(filename string) bool {
 }
 
 // insertFiles inserts file metadata into the pg_temp._pgmi_source table using the pgmi_register_file function.
-func (l *Loader) insertFiles(ctx context.Context, conn *pgxpool.Conn, files []pgmi.FileMetadata) error {
+func (l *Loader) insertFiles(ctx context.Context, conn *pgx.Conn, files []pgmi.FileMetadata) error {
 	if len(files) == 0 {
 		return nil
 	}
@@ -284,6 +301,12 @@ func (l *Loader) insertFiles(ctx context.Context, conn *pgxpool.Conn, files []pg
 // This eliminates the need for users to call pgmi_init_params() in deploy.sql.
 // Parameters are immediately accessible via current_setting('pgmi.key').
 func (l *Loader) LoadParametersIntoSession(ctx context.Context, conn *pgxpool.Conn, params map[string]string) error {
+	return l.LoadParametersIntoConn(ctx, unpooled(conn), params)
+}
+
+// LoadParametersIntoConn is LoadParametersIntoSession for a connection that
+// did not come from a pool.
+func (l *Loader) LoadParametersIntoConn(ctx context.Context, conn *pgx.Conn, params map[string]string) error {
 	for key, value := range params {
 		if err := validateParameterKey(key); err != nil {
 			return fmt.Errorf("%w: %w", pgmi.ErrInvalidConfig, err)
@@ -310,7 +333,7 @@ func (l *Loader) LoadParametersIntoSession(ctx context.Context, conn *pgxpool.Co
 // does: setSessionVariables builds pgmi.<lower(key)>, and PostgreSQL's GUC
 // namespace is case-insensitive anyway. Preserving the original case here would
 // make pgmi_parameter_view.key disagree with the variable actually set.
-func (l *Loader) insertParams(ctx context.Context, conn *pgxpool.Conn, params map[string]string) error {
+func (l *Loader) insertParams(ctx context.Context, conn *pgx.Conn, params map[string]string) error {
 	if len(params) == 0 {
 		return nil
 	}
@@ -334,7 +357,7 @@ func (l *Loader) insertParams(ctx context.Context, conn *pgxpool.Conn, params ma
 //
 // Precondition: All keys must be pre-validated via validateParameterKey.
 // LoadParametersIntoSession validates before calling this function.
-func (l *Loader) setSessionVariables(ctx context.Context, conn *pgxpool.Conn, params map[string]string) error {
+func (l *Loader) setSessionVariables(ctx context.Context, conn *pgx.Conn, params map[string]string) error {
 	if len(params) == 0 {
 		return nil
 	}
@@ -352,10 +375,20 @@ func (l *Loader) setSessionVariables(ctx context.Context, conn *pgxpool.Conn, pa
 		"failed to complete session variable batch set")
 }
 
-// insertMetadata inserts script metadata into the pg_temp._pgmi_source_metadata table.
+// insertMetadata inserts script metadata into the pg_temp._pgmi_source_metadata table,
+// and what only session API v2 exposes into pg_temp._pgmi_source_metadata_ext.
 // Only processes files that have metadata (FileMetadata.Metadata != nil).
-func (l *Loader) insertMetadata(ctx context.Context, conn *pgxpool.Conn, files []pgmi.FileMetadata) error {
+func (l *Loader) insertMetadata(ctx context.Context, conn *pgx.Conn, files []pgmi.FileMetadata) error {
 	insertSQL := `INSERT INTO pg_temp._pgmi_source_metadata (path, id, idempotent, sort_keys, description) VALUES ($1, $2, $3, $4, $5)`
+	insertExtSQL := `INSERT INTO pg_temp._pgmi_source_metadata_ext (path, depends_on, custom) VALUES ($1, $2, $3)`
+
+	// depends_on holds resolved paths, not ids as written: pgmi_plan_detail_view
+	// walks it by path, and a project that reached the loader has already
+	// passed the same resolution in ScanProject.
+	deps, err := metadata.ResolveDependencies(files)
+	if err != nil {
+		return err
+	}
 
 	batch := &pgx.Batch{}
 	var labels []string
@@ -374,6 +407,12 @@ func (l *Loader) insertMetadata(ctx context.Context, conn *pgxpool.Conn, files [
 			sortKeys = []string{}
 		}
 
+		// The same NOT NULL trap as sort_keys: a nil map would go as NULL.
+		custom := file.Metadata.Custom
+		if custom == nil {
+			custom = map[string]map[string]any{}
+		}
+
 		batch.Queue(insertSQL,
 			file.Path,
 			file.Metadata.ID,
@@ -381,7 +420,8 @@ func (l *Loader) insertMetadata(ctx context.Context, conn *pgxpool.Conn, files [
 			sortKeys,
 			file.Metadata.Description,
 		)
-		labels = append(labels, file.Path)
+		batch.Queue(insertExtSQL, file.Path, append([]string{}, deps[file.Path]...), custom)
+		labels = append(labels, file.Path, file.Path)
 	}
 
 	if len(labels) == 0 {
@@ -406,6 +446,9 @@ func validateParameterKey(key string) error {
 	if !keyPattern.MatchString(key) {
 		return fmt.Errorf("invalid parameter key '%s': must start with a letter or underscore and contain only letters, digits and underscores, 1-63 characters (PostgreSQL identifier limit)", key)
 	}
+	if pgmi.IsReservedParameterKey(key) {
+		return fmt.Errorf("invalid parameter key '%s': pgmi.%s is set by pgmi itself", key, strings.ToLower(key))
+	}
 	return nil
 }
 
@@ -23,11 +23,12 @@ func TestLoadFilesIntoSession_Basic(t *testing.T) {
 	pool := testhelpers.GetTestPool(t, connString, testDB)
 	defer pool.Close()
 
-	conn, err := pool.Acquire(ctx)
+	pooled, err := pool.Acquire(ctx)
 	if err != nil {
 		t.Fatalf("Failed to acquire connection: %v", err)
 	}
-	defer conn.Release()
+	defer pooled.Release()
+	conn := pooled.Conn()
 
 	if err := params.CreateSchema(ctx, conn); err != nil {
 		t.Fatalf("Failed to create schema: %v", err)
@@ -39,7 +40,7 @@ func TestLoadFilesIntoSession_Basic(t *testing.T) {
 		{Path: "./migrations/002.sql", Content: "CREATE TABLE t2(id int);", Checksum: "a000000000000000000000000000000000000000000000000000000000000002", ChecksumRaw: "b000000000000000000000000000000000000000000000000000000000000002"},
 	}
 
-	if err := l.LoadFilesIntoSession(ctx, conn, files); err != nil {
+	if err := l.LoadFilesIntoConn(ctx, conn, files); err != nil {
 		t.Fatalf("LoadFilesIntoSession failed: %v", err)
 	}
 
@@ -64,18 +65,19 @@ func TestLoadFilesIntoSession_Empty(t *testing.T) {
 	pool := testhelpers.GetTestPool(t, connString, testDB)
 	defer pool.Close()
 
-	conn, err := pool.Acquire(ctx)
+	pooled, err := pool.Acquire(ctx)
 	if err != nil {
 		t.Fatalf("Failed to acquire connection: %v", err)
 	}
-	defer conn.Release()
+	defer pooled.Release()
+	conn := pooled.Conn()
 
 	if err := params.CreateSchema(ctx, conn); err != nil {
 		t.Fatalf("Failed to create schema: %v", err)
 	}
 
 	l := loader.NewLoader()
-	if err := l.LoadFilesIntoSession(ctx, conn, nil); err != nil {
+	if err := l.LoadFilesIntoConn(ctx, conn, nil); err != nil {
 		t.Fatalf("LoadFilesIntoSession with empty files failed: %v", err)
 	}
 }
@@ -91,11 +93,12 @@ func TestLoadParametersIntoSession_Valid(t *testing.T) {
 	pool := testhelpers.GetTestPool(t, connString, testDB)
 	defer pool.Close()
 
-	conn, err := pool.Acquire(ctx)
+	pooled, err := pool.Acquire(ctx)
 	if err != nil {
 		t.Fatalf("Failed to acquire connection: %v", err)
 	}
-	defer conn.Release()
+	defer pooled.Release()
+	conn := pooled.Conn()
 
 	if err := params.CreateSchema(ctx, conn); err != nil {
 		t.Fatalf("Failed to create schema: %v", err)
@@ -104,7 +107,7 @@ func TestLoadParametersIntoSession_Valid(t *testing.T) {
 	l := loader.NewLoader()
 	p := map[string]string{"env": "test", "version": "1.0"}
 
-	if err := l.LoadParametersIntoSession(ctx, conn, p); err != nil {
+	if err := l.LoadParametersIntoConn(ctx, conn, p); err != nil {
 		t.Fatalf("LoadParametersIntoSession failed: %v", err)
 	}
 
@@ -129,18 +132,19 @@ func TestLoadParametersIntoSession_Empty(t *testing.T) {
 	pool := testhelpers.GetTestPool(t, connString, testDB)
 	defer pool.Close()
 
-	conn, err := pool.Acquire(ctx)
+	pooled, err := pool.Acquire(ctx)
 	if err != nil {
 		t.Fatalf("Failed to acquire connection: %v", err)
 	}
-	defer conn.Release()
+	defer pooled.Release()
+	conn := pooled.Conn()
 
 	if err := params.CreateSchema(ctx, conn); err != nil {
 		t.Fatalf("Failed to create schema: %v", err)
 	}
 
 	l := loader.NewLoader()
-	if err := l.LoadParametersIntoSession(ctx, conn, map[string]string{}); err != nil {
+	if err := l.LoadParametersIntoConn(ctx, conn, map[string]string{}); err != nil {
 		t.Fatalf("LoadParametersIntoSession with empty params failed: %v", err)
 	}
 }
@@ -156,11 +160,12 @@ func TestLoadParametersIntoSession_InvalidKey(t *testing.T) {
 	pool := testhelpers.GetTestPool(t, connString, testDB)
 	defer pool.Close()
 
-	conn, err := pool.Acquire(ctx)
+	pooled, err := pool.Acquire(ctx)
 	if err != nil {
 		t.Fatalf("Failed to acquire connection: %v", err)
 	}
-	defer conn.Release()
+	defer pooled.Release()
+	conn := pooled.Conn()
 
 	if err := params.CreateSchema(ctx, conn); err != nil {
 		t.Fatalf("Failed to create schema: %v", err)
@@ -169,7 +174,7 @@ func TestLoadParametersIntoSession_InvalidKey(t *testing.T) {
 	l := loader.NewLoader()
 	p := map[string]string{"invalid key": "value"}
 
-	err = l.LoadParametersIntoSession(ctx, conn, p)
+	err = l.LoadParametersIntoConn(ctx, conn, p)
 	if err == nil {
 		t.Fatal("Expected error for invalid key with spaces")
 	}
@@ -186,11 +191,12 @@ func TestLoadParametersIntoSession_KeyTooLong(t *testing.T) {
 	pool := testhelpers.GetTestPool(t, connString, testDB)
 	defer pool.Close()
 
-	conn, err := pool.Acquire(ctx)
+	pooled, err := pool.Acquire(ctx)
 	if err != nil {
 		t.Fatalf("Failed to acquire connection: %v", err)
 	}
-	defer conn.Release()
+	defer pooled.Release()
+	conn := pooled.Conn()
 
 	if err := params.CreateSchema(ctx, conn); err != nil {
 		t.Fatalf("Failed to create schema: %v", err)
@@ -200,7 +206,7 @@ func TestLoadParametersIntoSession_KeyTooLong(t *testing.T) {
 	longKey := strings.Repeat("a", 64)
 	p := map[string]string{longKey: "value"}
 
-	err = l.LoadParametersIntoSession(ctx, conn, p)
+	err = l.LoadParametersIntoConn(ctx, conn, p)
 	if err == nil {
 		t.Fatal("Expected error for key exceeding 63 chars")
 	}
@@ -217,11 +223,12 @@ func TestLoadFilesIntoSession_WithMetadata(t *testing.T) {
 	pool := testhelpers.GetTestPool(t, connString, testDB)
 	defer pool.Close()
 
-	conn, err := pool.Acquire(ctx)
+	pooled, err := pool.Acquire(ctx)
 	if err != nil {
 		t.Fatalf("Failed to acquire connection: %v", err)
 	}
-	defer conn.Release()
+	defer pooled.Release()
+	conn := pooled.Conn()
 
 	if err := params.CreateSchema(ctx, conn); err != nil {
 		t.Fatalf("Failed to create schema: %v", err)
@@ -245,7 +252,7 @@ func TestLoadFilesIntoSession_WithMetadata(t *testing.T) {
 		},
 	}
 
-	if err := l.LoadFilesIntoSession(ctx, conn, files); err != nil {
+	if err := l.LoadFilesIntoConn(ctx, conn, files); err != nil {
 		t.Fatalf("LoadFilesIntoSession failed: %v", err)
 	}
 
@@ -270,11 +277,12 @@ func TestLoadFilesIntoSession_VerifyContent(t *testing.T) {
 	pool := testhelpers.GetTestPool(t, connString, testDB)
 	defer pool.Close()
 
-	conn, err := pool.Acquire(ctx)
+	pooled, err := pool.Acquire(ctx)
 	if err != nil {
 		t.Fatalf("Failed to acquire connection: %v", err)
 	}
-	defer conn.Release()
+	defer pooled.Release()
+	conn := pooled.Conn()
 
 	if err := params.CreateSchema(ctx, conn); err != nil {
 		t.Fatalf("Failed to create schema: %v", err)
@@ -290,7 +298,7 @@ func TestLoadFilesIntoSession_VerifyContent(t *testing.T) {
 		},
 	}
 
-	if err := l.LoadFilesIntoSession(ctx, conn, files); err != nil {
+	if err := l.LoadFilesIntoConn(ctx, conn, files); err != nil {
 		t.Fatalf("LoadFilesIntoSession failed: %v", err)
 	}
 
@@ -335,11 +343,12 @@ func TestLoadFilesIntoSession_WithAndWithoutMetadata(t *testing.T) {
 	pool := testhelpers.GetTestPool(t, connString, testDB)
 	defer pool.Close()
 
-	conn, err := pool.Acquire(ctx)
+	pooled, err := pool.Acquire(ctx)
 	if err != nil {
 		t.Fatalf("Failed to acquire connection: %v", err)
 	}
-	defer conn.Release()
+	defer pooled.Release()
+	conn := pooled.Conn()
 
 	if err := params.CreateSchema(ctx, conn); err != nil {
 		t.Fatalf("Failed to create schema: %v", err)
@@ -373,7 +382,7 @@ func TestLoadFilesIntoSession_WithAndWithoutMetadata(t *testing.T) {
 		},
 	}
 
-	if err := l.LoadFilesIntoSession(ctx, conn, files); err != nil {
+	if err := l.LoadFilesIntoConn(ctx, conn, files); err != nil {
 		t.Fatalf("LoadFilesIntoSession failed: %v", err)
 	}
 
@@ -405,11 +414,12 @@ func TestLoadParametersIntoSession_VerifySessionVars(t *testing.T) {
 	pool := testhelpers.GetTestPool(t, connString, testDB)
 	defer pool.Close()
 
-	conn, err := pool.Acquire(ctx)
+	pooled, err := pool.Acquire(ctx)
 	if err != nil {
 		t.Fatalf("Failed to acquire connection: %v", err)
 	}
-	defer conn.Release()
+	defer pooled.Release()
+	conn := pooled.Conn()
 
 	if err := params.CreateSchema(ctx, conn); err != nil {
 		t.Fatalf("Failed to create schema: %v", err)
@@ -422,7 +432,7 @@ func TestLoadParametersIntoSession_VerifySessionVars(t *testing.T) {
 		"debug":       "true",
 	}
 
-	if err := l.LoadParametersIntoSession(ctx, conn, p); err != nil {
+	if err := l.LoadParametersIntoConn(ctx, conn, p); err != nil {
 		t.Fatalf("LoadParametersIntoSession failed: %v", err)
 	}
 
@@ -462,11 +472,12 @@ func TestLoadParametersIntoSession_CaseNormalization(t *testing.T) {
 	pool := testhelpers.GetTestPool(t, connString, testDB)
 	defer pool.Close()
 
-	conn, err := pool.Acquire(ctx)
+	pooled, err := pool.Acquire(ctx)
 	if err != nil {
 		t.Fatalf("Failed to acquire connection: %v", err)
 	}
-	defer conn.Release()
+	defer pooled.Release()
+	conn := pooled.Conn()
 
 	if err := params.CreateSchema(ctx, conn); err != nil {
 		t.Fatalf("Failed to create schema: %v", err)
@@ -478,7 +489,7 @@ func TestLoadParametersIntoSession_CaseNormalization(t *testing.T) {
 		"ALL_UPPER": "value2",
 	}
 
-	if err := l.LoadParametersIntoSession(ctx, conn, p); err != nil {
+	if err := l.LoadParametersIntoConn(ctx, conn, p); err != nil {
 		t.Fatalf("LoadParametersIntoSession failed: %v", err)
 	}
 
@@ -509,11 +520,12 @@ func TestLoadFilesIntoSession_RootLevelTestFiles(t *testing.T) {
 	pool := testhelpers.GetTestPool(t, connString, testDB)
 	defer pool.Close()
 
-	conn, err := pool.Acquire(ctx)
+	pooled, err := pool.Acquire(ctx)
 	if err != nil {
 		t.Fatalf("Failed to acquire connection: %v", err)
 	}
-	defer conn.Release()
+	defer pooled.Release()
+	conn := pooled.Conn()
 
 	if err := params.CreateSchema(ctx, conn); err != nil {
 		t.Fatalf("Failed to create schema: %v", err)
@@ -527,7 +539,7 @@ func TestLoadFilesIntoSession_RootLevelTestFiles(t *testing.T) {
 		{Path: "./schema/__test__/nested_test.sql", Extension: ".sql", Content: "SELECT 1;", Checksum: "a000000000000000000000000000000000000000000000000000000000000004", ChecksumRaw: "b000000000000000000000000000000000000000000000000000000000000004"},
 	}
 
-	if err := l.LoadFilesIntoSession(ctx, conn, files); err != nil {
+	if err := l.LoadFilesIntoConn(ctx, conn, files); err != nil {
 		t.Fatalf("LoadFilesIntoSession failed: %v", err)
 	}
 
@@ -579,11 +591,12 @@ func TestLoadFilesIntoSession_LargeContent(t *testing.T) {
 	pool := testhelpers.GetTestPool(t, connString, testDB)
 	defer pool.Close()
 
-	conn, err := pool.Acquire(ctx)
+	pooled, err := pool.Acquire(ctx)
 	if err != nil {
 		t.Fatalf("Failed to acquire connection: %v", err)
 	}
-	defer conn.Release()
+	defer pooled.Release()
+	conn := pooled.Conn()
 
 	if err := params.CreateSchema(ctx, conn); err != nil {
 		t.Fatalf("Failed to create schema: %v", err)
@@ -601,7 +614,7 @@ func TestLoadFilesIntoSession_LargeContent(t *testing.T) {
 		},
 	}
 
-	if err := l.LoadFilesIntoSession(ctx, conn, files); err != nil {
+	if err := l.LoadFilesIntoConn(ctx, conn, files); err != nil {
 		t.Fatalf("LoadFilesIntoSession failed for large file: %v", err)
 	}
 
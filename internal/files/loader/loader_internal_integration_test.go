@@ -96,18 +96,18 @@ func connectToTestDB(t *testing.T, connString, dbName string) *pgxpool.Pool {
 	return pool
 }
 
-func acquireWithSchema(t *testing.T, pool *pgxpool.Pool) *pgxpool.Conn {
+func acquireWithSchema(t *testing.T, pool *pgxpool.Pool) (*pgx.Conn, func()) {
 	t.Helper()
 	ctx := context.Background()
-	conn, err := pool.Acquire(ctx)
+	pooled, err := pool.Acquire(ctx)
 	if err != nil {
 		t.Fatalf("Failed to acquire connection: %v", err)
 	}
-	if err := params.CreateSchema(ctx, conn); err != nil {
-		conn.Release()
+	if err := params.CreateSchema(ctx, pooled.Conn()); err != nil {
+		pooled.Release()
 		t.Fatalf("Failed to create schema: %v", err)
 	}
-	return conn
+	return pooled.Conn(), pooled.Release
 }
 
 func TestInsertFiles_BatchExecution_Internal(t *testing.T) {
@@ -119,8 +119,8 @@ func TestInsertFiles_BatchExecution_Internal(t *testing.T) {
 	pool := connectToTestDB(t, connString, testDB)
 	defer pool.Close()
 
-	conn := acquireWithSchema(t, pool)
-	defer conn.Release()
+	conn, release := acquireWithSchema(t, pool)
+	defer release()
 
 	ctx := context.Background()
 	l := NewLoader()
@@ -153,8 +153,8 @@ func TestInsertFiles_ManyFiles_Internal(t *testing.T) {
 	pool := connectToTestDB(t, connString, testDB)
 	defer pool.Close()
 
-	conn := acquireWithSchema(t, pool)
-	defer conn.Release()
+	conn, release := acquireWithSchema(t, pool)
+	defer release()
 
 	ctx := context.Background()
 	l := NewLoader()
@@ -191,8 +191,8 @@ func TestInsertParams_BatchExecution_Internal(t *testing.T) {
 	pool := connectToTestDB(t, connString, testDB)
 	defer pool.Close()
 
-	conn := acquireWithSchema(t, pool)
-	defer conn.Release()
+	conn, release := acquireWithSchema(t, pool)
+	defer release()
 
 	ctx := context.Background()
 	l := NewLoader()
@@ -233,8 +233,8 @@ func TestInsertParams_SpecialValues_Internal(t *testing.T) {
 	pool := connectToTestDB(t, connString, testDB)
 	defer pool.Close()
 
-	conn := acquireWithSchema(t, pool)
-	defer conn.Release()
+	conn, release := acquireWithSchema(t, pool)
+	defer release()
 
 	ctx := context.Background()
 	l := NewLoader()
@@ -279,8 +279,8 @@ func TestSetSessionVariables_BatchExecution_Internal(t *testing.T) {
 	pool := connectToTestDB(t, connString, testDB)
 	defer pool.Close()
 
-	conn := acquireWithSchema(t, pool)
-	defer conn.Release()
+	conn, release := acquireWithSchema(t, pool)
+	defer release()
 
 	ctx := context.Background()
 	l := NewLoader()
@@ -321,8 +321,8 @@ func TestSetSessionVariables_CaseNormalization_Internal(t *testing.T) {
 	pool := connectToTestDB(t, connString, testDB)
 	defer pool.Close()
 
-	conn := acquireWithSchema(t, pool)
-	defer conn.Release()
+	conn, release := acquireWithSchema(t, pool)
+	defer release()
 
 	ctx := context.Background()
 	l := NewLoader()
@@ -351,8 +351,8 @@ func TestInsertMetadata_BatchExecution_Internal(t *testing.T) {
 	pool := connectToTestDB(t, connString, testDB)
 	defer pool.Close()
 
-	conn := acquireWithSchema(t, pool)
-	defer conn.Release()
+	conn, release := acquireWithSchema(t, pool)
+	defer release()
 
 	ctx := context.Background()
 	l := NewLoader()
@@ -411,8 +411,8 @@ func TestInsertMetadata_FieldVerification_Internal(t *testing.T) {
 	pool := connectToTestDB(t, connString, testDB)
 	defer pool.Close()
 
-	conn := acquireWithSchema(t, pool)
-	defer conn.Release()
+	conn, release := acquireWithSchema(t, pool)
+	defer release()
 
 	ctx := context.Background()
 	l := NewLoader()
@@ -477,8 +477,8 @@ func TestLoadFilesIntoSession_FullPath_Internal(t *testing.T) {
 	pool := connectToTestDB(t, connString, testDB)
 	defer pool.Close()
 
-	conn := acquireWithSchema(t, pool)
-	defer conn.Release()
+	conn, release := acquireWithSchema(t, pool)
+	defer release()
 
 	ctx := context.Background()
 	l := NewLoader()
@@ -501,7 +501,7 @@ func TestLoadFilesIntoSession_FullPath_Internal(t *testing.T) {
 		},
 	}
 
-	if err := l.LoadFilesIntoSession(ctx, conn, files); err != nil {
+	if err := l.LoadFilesIntoConn(ctx, conn, files); err != nil {
 		t.Fatalf("LoadFilesIntoSession failed: %v", err)
 	}
 
@@ -531,15 +531,15 @@ func TestLoadParametersIntoSession_FullPath_Internal(t *testing.T) {
 	pool := connectToTestDB(t, connString, testDB)
 	defer pool.Close()
 
-	conn := acquireWithSchema(t, pool)
-	defer conn.Release()
+	conn, release := acquireWithSchema(t, pool)
+	defer release()
 
 	ctx := context.Background()
 	l := NewLoader()
 
 	p := map[string]string{"env": "test", "timeout": "30"}
 
-	if err := l.LoadParametersIntoSession(ctx, conn, p); err != nil {
+	if err := l.LoadParametersIntoConn(ctx, conn, p); err != nil {
 		t.Fatalf("LoadParametersIntoSession failed: %v", err)
 	}
 
@@ -569,13 +569,13 @@ func TestLoadParametersIntoSession_InvalidKeyError_Internal(t *testing.T) {
 	pool := connectToTestDB(t, connString, testDB)
 	defer pool.Close()
 
-	conn := acquireWithSchema(t, pool)
-	defer conn.Release()
+	conn, release := acquireWithSchema(t, pool)
+	defer release()
 
 	ctx := context.Background()
 	l := NewLoader()
 
-	err := l.LoadParametersIntoSession(ctx, conn, map[string]string{"bad key": "val"})
+	err := l.LoadParametersIntoConn(ctx, conn, map[string]string{"bad key": "val"})
 	if err == nil {
 		t.Fatal("Expected error for invalid key")
 	}
@@ -593,8 +593,8 @@ func TestInsertFiles_ContentPreservation_Internal(t *testing.T) {
 	pool := connectToTestDB(t, connString, testDB)
 	defer pool.Close()
 
-	conn := acquireWithSchema(t, pool)
-	defer conn.Release()
+	conn, release := acquireWithSchema(t, pool)
+	defer release()
 
 	ctx := context.Background()
 	l := NewLoader()
@@ -643,8 +643,8 @@ func TestInsertMetadata_AbsentSortKeysStoresEmptyArray(t *testing.T) {
 	pool := connectToTestDB(t, connString, testDB)
 	defer pool.Close()
 
-	conn := acquireWithSchema(t, pool)
-	defer conn.Release()
+	conn, release := acquireWithSchema(t, pool)
+	defer release()
 
 	ctx := context.Background()
 	l := NewLoader()
@@ -705,8 +705,8 @@ func TestLoadFilesIntoSession_AnalyzesSessionTables_Internal(t *testing.T) {
 	pool := connectToTestDB(t, connString, testDB)
 	defer pool.Close()
 
-	conn := acquireWithSchema(t, pool)
-	defer conn.Release()
+	conn, release := acquireWithSchema(t, pool)
+	defer release()
 
 	ctx := context.Background()
 	l := NewLoader()
@@ -724,7 +724,7 @@ func TestLoadFilesIntoSession_AnalyzesSessionTables_Internal(t *testing.T) {
 		},
 	}
 
-	if err := l.LoadFilesIntoSession(ctx, conn, files); err != nil {
+	if err := l.LoadFilesIntoConn(ctx, conn, files); err != nil {
 		t.Fatalf("LoadFilesIntoSession failed: %v", err)
 	}
 
@@ -90,6 +90,10 @@ func TestValidateParameterKey_InvalidKeys(t *testing.T) {
 		// and exiting 10.
 		{"leading digit", "1abc"},
 		{"only digits", "123"},
+		// pgmi sets these session variables itself; a parameter of the same
+		// name would be silently overwritten.
+		{"reserved entrypoint", "entrypoint"},
+		{"reserved resumed_units", "Resumed_Units"},
 	}
 
 	for _, tt := range tests {
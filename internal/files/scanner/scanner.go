@@ -63,6 +63,12 @@ func NewScannerWithFS(calculator checksum.Calculator, fsProvider filesystem.File
 //   - pgmi.FileScanResult: Scan results including files
 //   - error: Any error encountered during scanning
 func (s *Scanner) ScanDirectory(sourcePath string) (pgmi.FileScanResult, error) {
+	return s.ScanDirectoryExcluding(sourcePath)
+}
+
+// ScanDirectoryExcluding is ScanDirectory that also excludes every further
+// orchestrator script in entrypoints, paths relative to sourcePath.
+func (s *Scanner) ScanDirectoryExcluding(sourcePath string, entrypoints ...string) (pgmi.FileScanResult, error) {
 	// Open the directory using the filesystem provider
 	dir, err := s.fsProvider.Open(sourcePath)
 	if err != nil {
@@ -88,12 +94,19 @@ func (s *Scanner) ScanDirectory(sourcePath string) (pgmi.FileScanResult, error)
 
 		relPath := file.RelativePath()
 
-		if isExcludedPath(relPath) {
+		if IsExcludedPath(relPath) {
 			return nil
 		}
 
 		// Exclude only the root deploy.sql (not nested ones like examples/deploy.sql)
-		if strings.ToLower(relPath) == "deploy.sql" {
+		if strings.ToLower(relPath) == pgmi.DefaultEntrypoint {
+			return nil
+		}
+
+		// An orchestrator loaded as a source file is one plan loop away from
+		// executing itself, so every entrypoint is kept out, not only the one
+		// this run executes.
+		if isEntrypoint(relPath, entrypoints) {
 			return nil
 		}
 
@@ -115,6 +128,18 @@ func (s *Scanner) ScanDirectory(sourcePath string) (pgmi.FileScanResult, error)
 	}, nil
 }
 
+// isEntrypoint reports whether relPath names one of the entrypoints. Matching
+// is case-insensitive, as it is for deploy.sql.
+func isEntrypoint(relPath string, entrypoints []string) bool {
+	p := filepath.ToSlash(relPath)
+	for _, e := range entrypoints {
+		if strings.EqualFold(p, pgmi.NormalizeEntrypoint(e)) {
+			return true
+		}
+	}
+	return false
+}
+
 // excludedDirs are directories whose contents are tooling artifacts rather than
 // project files. They are matched by exact name, unlike dot-directories which
 // are matched by prefix.
@@ -123,14 +148,16 @@ var excludedDirs = map[string]bool{
 	"__pycache__":  true,
 }
 
-// isExcludedPath reports whether a file lies under a directory pgmi does not
+// IsExcludedPath reports whether a file lies under a directory pgmi does not
 // load: any dot-directory (.git, .venv, .idea, .claude) or a known tooling
 // cache. Discovery decides what enters the session, never what SQL runs, so
 // this stays on the infrastructure side of the execution-fabric line.
+// `pgmi deploy --watch` ignores the same paths, so a change it reacts to is
+// always one the redeploy can see.
 //
 // pgmi's own dunder directories (__test__, __tests__) are deliberately not
 // excluded — only the exact names above are.
-func isExcludedPath(relPath string) bool {
+func IsExcludedPath(relPath string) bool {
 	for segment := range strings.SplitSeq(filepath.ToSlash(relPath), "/") {
 		if segment == "" || segment == "." {
 			continue
@@ -226,6 +253,8 @@ func (s *Scanner) processFile(file filesystem.File) (pgmi.FileMetadata, error) {
 				Idempotent:  *meta.Idempotent,
 				SortKeys:    meta.SortKeys.Keys,
 				Description: meta.Description,
+				DependsOn:   meta.DependsOn.IDs,
+				Custom:      meta.Custom,
 			}
 		}
 	}
@@ -247,6 +276,14 @@ func (s *Scanner) processFile(file filesystem.File) (pgmi.FileMetadata, error) {
 
 // ValidateDeploySQL checks if deploy.sql exists in the source directory.
 func (s *Scanner) ValidateDeploySQL(sourcePath string) error {
+	return s.ValidateEntrypoint(sourcePath, pgmi.DefaultEntrypoint)
+}
+
+// ValidateEntrypoint checks that the project directory exists and holds the
+// orchestrator script entry, a path relative to it. A missing deploy.sql is
+// ErrDeploySQLNotFound; a missing --entry is a mistyped flag or pgmi.yaml
+// entry, and reports as configuration.
+func (s *Scanner) ValidateEntrypoint(sourcePath, entry string) error {
 	pathInfo, pathErr := s.fsProvider.Stat(sourcePath)
 	if pathErr != nil {
 		// "%s" not %q: %q escapes the separators in a Windows path, and a path
@@ -257,14 +294,25 @@ func (s *Scanner) ValidateDeploySQL(sourcePath string) error {
 		return fmt.Errorf("\"%s\" is a file; pass the project directory: %w", sourcePath, pgmi.ErrInvalidConfig)
 	}
 
-	deploySQLPath := filepath.Join(sourcePath, "deploy.sql")
-	info, err := s.fsProvider.Stat(deploySQLPath)
+	entry = pgmi.NormalizeEntrypoint(entry)
+	isDefault := entry == pgmi.DefaultEntrypoint
+	if !isDefault {
+		if err := pgmi.ValidateEntrypointPath(entry); err != nil {
+			return err
+		}
+	}
+
+	entryPath := filepath.Join(sourcePath, filepath.FromSlash(entry))
+	info, err := s.fsProvider.Stat(entryPath)
 	if err != nil {
-		return fmt.Errorf("%w in %s\nexpected: %s — run `pgmi init` to scaffold one", pgmi.ErrDeploySQLNotFound, sourcePath, deploySQLPath)
+		if isDefault {
+			return fmt.Errorf("%w in %s\nexpected: %s — run `pgmi init` to scaffold one", pgmi.ErrDeploySQLNotFound, sourcePath, entryPath)
+		}
+		return fmt.Errorf("entrypoint %s does not exist in %s\nexpected: %s: %w", entry, sourcePath, entryPath, pgmi.ErrInvalidConfig)
 	}
 
 	if info.IsDir() {
-		return fmt.Errorf("%s is a directory, expected a regular file", deploySQLPath)
+		return fmt.Errorf("%s is a directory, expected a regular file", entryPath)
 	}
 
 	return nil
@@ -272,18 +320,25 @@ func (s *Scanner) ValidateDeploySQL(sourcePath string) error {
 
 // ReadDeploySQL reads the deploy.sql file content.
 func (s *Scanner) ReadDeploySQL(sourcePath string) (string, error) {
-	deploySQLPath := filepath.Join(sourcePath, "deploy.sql")
+	return s.ReadEntrypoint(sourcePath, pgmi.DefaultEntrypoint)
+}
+
+// ReadEntrypoint reads the orchestrator script entry, a path relative to
+// sourcePath.
+func (s *Scanner) ReadEntrypoint(sourcePath, entry string) (string, error) {
+	entry = pgmi.NormalizeEntrypoint(entry)
+	entryPath := filepath.Join(sourcePath, filepath.FromSlash(entry))
 
-	content, err := s.fsProvider.ReadFile(deploySQLPath)
+	content, err := s.fsProvider.ReadFile(entryPath)
 	if err != nil {
-		return "", fmt.Errorf("failed to read deploy.sql: %w", err)
+		return "", fmt.Errorf("failed to read %s: %w", entry, err)
 	}
 
 	content = bytes.TrimPrefix(content, utf8BOM)
 
 	if !utf8.Valid(content) {
 		offset := findInvalidUTF8(content)
-		return "", fmt.Errorf("deploy.sql is not valid UTF-8 (first invalid byte at offset %d); re-save the file as UTF-8 without BOM", offset)
+		return "", fmt.Errorf("%s is not valid UTF-8 (first invalid byte at offset %d); re-save the file as UTF-8 without BOM", entry, offset)
 	}
 
 	return string(content), nil
@@ -303,4 +358,4 @@ func findInvalidUTF8(b []byte) int {
 }
 
 // Verify Scanner implements the interface at compile time
-var _ pgmi.FileScanner = (*Scanner)(nil)
+var _ pgmi.EntrypointScanner = (*Scanner)(nil)
@@ -417,6 +417,66 @@ func TestValidateDeploySQL_IsDirectory(t *testing.T) {
 	}
 }
 
+func TestScanDirectory_ExcludesEntrypoints(t *testing.T) {
+	s, fs := newTestScanner()
+	fs.AddFile("deploy.sql", "SELECT 1;")
+	fs.AddFile("ops/reindex.sql", "REINDEX DATABASE CONCURRENTLY app;")
+	fs.AddFile("ops/Vacuum.sql", "VACUUM;")
+	fs.AddFile("ops/keep.sql", "SELECT 2;")
+
+	result, err := s.ScanDirectoryExcluding("/project", "./ops/reindex.sql", "ops/vacuum.sql")
+	if err != nil {
+		t.Fatalf("ScanDirectory failed: %v", err)
+	}
+	if len(result.Files) != 1 || result.Files[0].Path != "./ops/keep.sql" {
+		var paths []string
+		for _, f := range result.Files {
+			paths = append(paths, f.Path)
+		}
+		t.Fatalf("expected only ./ops/keep.sql, got %v", paths)
+	}
+}
+
+func TestValidateEntrypoint_Missing(t *testing.T) {
+	s, fs := newTestScanner()
+	fs.AddFile("deploy.sql", "SELECT 1;")
+
+	err := s.ValidateEntrypoint("/project", "ops/reindex.sql")
+	if !errors.Is(err, pgmi.ErrInvalidConfig) {
+		t.Fatalf("a missing --entry is a configuration error (exit 10), got: %v", err)
+	}
+	if !strings.Contains(err.Error(), "ops/reindex.sql") {
+		t.Errorf("error should name the entrypoint, got: %v", err)
+	}
+}
+
+func TestValidateEntrypoint_RejectsPathOutsideProject(t *testing.T) {
+	s, fs := newTestScanner()
+	fs.AddFile("deploy.sql", "SELECT 1;")
+
+	err := s.ValidateEntrypoint("/project", "../elsewhere.sql")
+	if !errors.Is(err, pgmi.ErrInvalidConfig) {
+		t.Fatalf("expected ErrInvalidConfig, got: %v", err)
+	}
+}
+
+func TestReadEntrypoint(t *testing.T) {
+	s, fs := newTestScanner()
+	fs.AddFile("deploy.sql", "SELECT 1;")
+	fs.AddFile("ops/reindex.sql", "\xef\xbb\xbfSELECT 2;")
+
+	if err := s.ValidateEntrypoint("/project", "ops/reindex.sql"); err != nil {
+		t.Fatalf("ValidateEntrypoint failed: %v", err)
+	}
+	content, err := s.ReadEntrypoint("/project", "ops/reindex.sql")
+	if err != nil {
+		t.Fatalf("ReadEntrypoint failed: %v", err)
+	}
+	if content != "SELECT 2;" {
+		t.Errorf("Unexpected content: %q", content)
+	}
+}
+
 func TestReadDeploySQL(t *testing.T) {
 	s, fs := newTestScanner()
 	fs.AddFile("deploy.sql", "SELECT 1;")
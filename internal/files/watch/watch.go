@@ -0,0 +1,202 @@
+// Package watch detects changes to a project tree, for pgmi deploy --watch.
+//
+// It polls rather than subscribing to OS notifications: that needs no
+// dependency, behaves the same on every platform, and keeps working on the
+// network and container bind mounts where notifications are unreliable. A
+// pgmi project is small enough that walking it a few times a second costs
+// nothing.
+package watch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/vvka-141/pgmi/internal/files/scanner"
+)
+
+// Default timings for pgmi deploy --watch.
+const (
+	// DefaultInterval is how often the tree is walked.
+	DefaultInterval = 250 * time.Millisecond
+
+	// DefaultQuiet is how long the tree must stay unchanged before a change
+	// is reported, so an editor saving several files or a git checkout
+	// causes one redeploy rather than many.
+	DefaultQuiet = 500 * time.Millisecond
+)
+
+// fileState is what a change is detected by. A rewrite that keeps both the
+// size and the modification time, within the filesystem's timestamp
+// resolution, goes unnoticed.
+type fileState struct {
+	size    int64
+	modTime int64 // UnixNano, so states compare with ==
+}
+
+// snapshot maps the project-relative, slash-separated path of every file
+// watched to its state.
+type snapshot map[string]fileState
+
+// Watcher reports changes to the files under a project directory, ignoring
+// the paths scanner.IsExcludedPath keeps out of a deployment. It is not safe
+// for concurrent use.
+type Watcher struct {
+	root     string
+	interval time.Duration
+	quiet    time.Duration
+	ignored  map[string]bool // snapshot keys never watched
+	last     snapshot
+}
+
+// New returns a Watcher over root, having recorded the tree's current state:
+// Wait reports changes made after New returns.
+//
+// ignore names files that are never watched, relative to the working
+// directory or absolute: files a deploy itself writes, such as --log-file
+// and --metrics-file, which would otherwise trigger a redeploy after every
+// run. Those outside root are dropped.
+func New(root string, interval, quiet time.Duration, ignore ...string) (*Watcher, error) {
+	ignored, err := ignoredPaths(root, ignore)
+	if err != nil {
+		return nil, err
+	}
+	w := &Watcher{root: root, interval: interval, quiet: quiet, ignored: ignored}
+	if w.last, err = w.take(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// ignoredPaths turns ignore into snapshot keys under root.
+func ignoredPaths(root string, ignore []string) (map[string]bool, error) {
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to watch %s: %w", root, err)
+	}
+	ignored := map[string]bool{}
+	for _, p := range ignore {
+		if p == "" {
+			continue
+		}
+		abs, err := filepath.Abs(p)
+		if err != nil {
+			return nil, fmt.Errorf("failed to watch %s: %w", root, err)
+		}
+		rel, err := filepath.Rel(absRoot, abs)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		ignored[filepath.ToSlash(rel)] = true
+	}
+	return ignored, nil
+}
+
+// Wait blocks until the tree differs from the state last reported and has
+// then stayed unchanged for the quiet period. It returns the paths added,
+// removed or modified, sorted, in the ./ form _pgmi_source uses. Changes made
+// while the caller was busy, such as during a redeploy, are reported by the
+// next call. Wait returns ctx's error once ctx is done.
+func (w *Watcher) Wait(ctx context.Context) ([]string, error) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	var pending snapshot
+	var changedAt time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+
+		cur, err := w.take()
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case pending == nil && maps.Equal(cur, w.last):
+			continue
+		case pending == nil || !maps.Equal(cur, pending):
+			pending, changedAt = cur, time.Now()
+			continue
+		case time.Since(changedAt) < w.quiet:
+			continue
+		}
+
+		changed := diff(w.last, pending)
+		w.last, pending = pending, nil
+		// A file changed and changed back is no change at all.
+		if len(changed) > 0 {
+			return changed, nil
+		}
+	}
+}
+
+// take walks the root and records the state of every file not excluded or
+// ignored.
+func (w *Watcher) take() (snapshot, error) {
+	root := w.root
+	s := snapshot{}
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// Deleted between listing its directory and reaching it.
+			if errors.Is(err, fs.ErrNotExist) && path != root {
+				return nil
+			}
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		if rel == "." {
+			return nil
+		}
+		if scanner.IsExcludedPath(rel) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() || w.ignored[filepath.ToSlash(rel)] {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		s[filepath.ToSlash(rel)] = fileState{size: info.Size(), modTime: info.ModTime().UnixNano()}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to watch %s: %w", root, err)
+	}
+	return s, nil
+}
+
+// diff returns the paths whose state differs between before and after.
+func diff(before, after snapshot) []string {
+	var changed []string
+	for p, st := range after {
+		if prev, ok := before[p]; !ok || prev != st {
+			changed = append(changed, "./"+p)
+		}
+	}
+	for p := range before {
+		if _, ok := after[p]; !ok {
+			changed = append(changed, "./"+p)
+		}
+	}
+	slices.Sort(changed)
+	return changed
+}
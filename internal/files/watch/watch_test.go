@@ -0,0 +1,183 @@
+package watch
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+const (
+	testInterval = 10 * time.Millisecond
+	testQuiet    = 50 * time.Millisecond
+)
+
+func writeFile(t *testing.T, root, rel, content string) {
+	t.Helper()
+	path := filepath.Join(root, filepath.FromSlash(rel))
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func waitFor(t *testing.T, w *Watcher, timeout time.Duration) ([]string, error) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return w.Wait(ctx)
+}
+
+func TestWatcher_ReportsChanges(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, root, "deploy.sql", "SELECT 1;")
+	writeFile(t, root, "migrations/001.sql", "CREATE TABLE a();")
+	writeFile(t, root, "migrations/002.sql", "CREATE TABLE b();")
+
+	w, err := New(root, testInterval, testQuiet)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	writeFile(t, root, "migrations/001.sql", "CREATE TABLE a(id int);")
+	writeFile(t, root, "__test__/test_a.sql", "SELECT 1;")
+	if err := os.Remove(filepath.Join(root, "migrations", "002.sql")); err != nil {
+		t.Fatal(err)
+	}
+
+	changed, err := waitFor(t, w, 5*time.Second)
+	if err != nil {
+		t.Fatalf("Wait: %v", err)
+	}
+	want := []string{"./__test__/test_a.sql", "./migrations/001.sql", "./migrations/002.sql"}
+	if !slices.Equal(changed, want) {
+		t.Errorf("changed = %v, want %v", changed, want)
+	}
+
+	// Reported changes are not reported again.
+	if changed, err := waitFor(t, w, 200*time.Millisecond); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("second Wait = %v, %v; want no change", changed, err)
+	}
+}
+
+func TestWatcher_IgnoresExcludedPaths(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, root, "deploy.sql", "SELECT 1;")
+
+	w, err := New(root, testInterval, testQuiet)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	writeFile(t, root, ".git/index", "binary")
+	writeFile(t, root, "node_modules/pkg/index.js", "x")
+	writeFile(t, root, ".deploy.sql.swp", "x")
+
+	if changed, err := waitFor(t, w, 200*time.Millisecond); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Wait = %v, %v; want excluded paths ignored", changed, err)
+	}
+}
+
+func TestWatcher_IgnoresGivenFiles(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, root, "deploy.sql", "SELECT 1;")
+
+	// The deploy's own output, once absolute and once relative to the
+	// working directory; a path outside root is dropped.
+	rel, err := filepath.Rel(mustGetwd(t), filepath.Join(root, "logs", "deploy.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := New(root, testInterval, testQuiet,
+		filepath.Join(root, "metrics.prom"), rel, filepath.Join(t.TempDir(), "elsewhere.log"))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	writeFile(t, root, "metrics.prom", "pgmi_deploy_success 1\n")
+	writeFile(t, root, "logs/deploy.log", "{}\n")
+	if changed, err := waitFor(t, w, 200*time.Millisecond); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Wait = %v, %v; want ignored files ignored", changed, err)
+	}
+
+	writeFile(t, root, "logs/other.log", "x")
+	changed, err := waitFor(t, w, 2*time.Second)
+	if err != nil {
+		t.Fatalf("Wait: %v", err)
+	}
+	if want := []string{"./logs/other.log"}; !slices.Equal(changed, want) {
+		t.Errorf("changed = %v, want %v", changed, want)
+	}
+}
+
+func mustGetwd(t *testing.T) string {
+	t.Helper()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return wd
+}
+
+func TestWatcher_DebouncesBursts(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, root, "deploy.sql", "SELECT 1;")
+
+	// A generous quiet period, so a slow machine does not split the burst.
+	const quiet = 300 * time.Millisecond
+	w, err := New(root, testInterval, quiet)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	// Files written over a span longer than the interval, but with gaps
+	// shorter than the quiet period, are one change.
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for _, name := range []string{"a.sql", "b.sql", "c.sql", "d.sql"} {
+			if err := os.WriteFile(filepath.Join(root, name), []byte("SELECT 1;"), 0644); err != nil {
+				t.Error(err)
+			}
+			time.Sleep(3 * testInterval)
+		}
+	}()
+
+	changed, err := waitFor(t, w, 5*time.Second)
+	<-done
+	if err != nil {
+		t.Fatalf("Wait: %v", err)
+	}
+	if want := []string{"./a.sql", "./b.sql", "./c.sql", "./d.sql"}; !slices.Equal(changed, want) {
+		t.Errorf("changed = %v, want %v", changed, want)
+	}
+}
+
+func TestWatcher_ChangedBackIsNoChange(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, root, "deploy.sql", "SELECT 1;")
+	w, err := New(root, testInterval, testQuiet)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	writeFile(t, root, "scratch.sql", "x")
+	if err := os.Remove(filepath.Join(root, "scratch.sql")); err != nil {
+		t.Fatal(err)
+	}
+
+	if changed, err := waitFor(t, w, 200*time.Millisecond); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Wait = %v, %v; want no change", changed, err)
+	}
+}
+
+func TestNew_MissingRoot(t *testing.T) {
+	if _, err := New(filepath.Join(t.TempDir(), "missing"), testInterval, testQuiet); err == nil {
+		t.Error("New succeeded on a missing directory")
+	}
+}
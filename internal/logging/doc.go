@@ -2,6 +2,7 @@
 //
 // Available implementations:
 //   - ConsoleLogger: Writes formatted messages to stdout with thread-safe output
+//   - SlogLogger: Writes timestamped text or JSON records through log/slog
 //   - NullLogger: Discards all messages (useful for testing)
 //
 // All logger implementations are safe for concurrent use by multiple goroutines.
@@ -0,0 +1,122 @@
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+// Log formats accepted by NewSlogLogger.
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// SlogLogger writes timestamped, leveled records through log/slog, as
+// logfmt-style text or one JSON object per line. Every record carries the
+// run's correlation id, so a log aggregator can gather one deployment's
+// records from among others.
+// Safe for concurrent use by multiple goroutines.
+type SlogLogger struct {
+	logger *slog.Logger
+	runID  string
+}
+
+// NewSlogLogger creates a SlogLogger writing to w in format (FormatText or
+// FormatJSON). If verbose is true, Verbose() records are written at debug
+// level; otherwise they are dropped. runID is the correlation id; empty
+// generates one.
+func NewSlogLogger(w io.Writer, format string, verbose bool, runID string) (*SlogLogger, error) {
+	level := slog.LevelInfo
+	if verbose {
+		level = slog.LevelDebug
+	}
+	opts := &slog.HandlerOptions{Level: level}
+
+	var handler slog.Handler
+	switch format {
+	case FormatText, "":
+		handler = slog.NewTextHandler(w, opts)
+	case FormatJSON:
+		handler = slog.NewJSONHandler(w, opts)
+	default:
+		return nil, fmt.Errorf("unknown log format %q: must be %s or %s", format, FormatText, FormatJSON)
+	}
+
+	if runID == "" {
+		runID = NewRunID()
+	}
+	return &SlogLogger{
+		logger: slog.New(handler).With("run_id", runID),
+		runID:  runID,
+	}, nil
+}
+
+// NewRunID returns a random correlation id for one pgmi run.
+func NewRunID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// RunID returns the correlation id every record carries.
+func (l *SlogLogger) RunID() string {
+	return l.runID
+}
+
+// Verbose logs detailed diagnostic information at debug level.
+func (l *SlogLogger) Verbose(format string, args ...any) {
+	l.logger.Debug(sprintf(format, args))
+}
+
+// Info logs informational messages about normal operations.
+func (l *SlogLogger) Info(format string, args ...any) {
+	l.logger.Info(sprintf(format, args))
+}
+
+// Error logs error messages.
+func (l *SlogLogger) Error(format string, args ...any) {
+	l.logger.Error(sprintf(format, args))
+}
+
+// Notice logs a message the server sent (RAISE NOTICE and the like) at the
+// level its severity maps to: DEBUG and LOG at debug, INFO and NOTICE at
+// info, WARNING at warn, anything graver at error. Its signature matches
+// db.NoticeHandler.
+func (l *SlogLogger) Notice(severity, message, detail, hint string) {
+	attrs := []any{"source", "postgres", "severity", severity}
+	if detail != "" {
+		attrs = append(attrs, "detail", detail)
+	}
+	if hint != "" {
+		attrs = append(attrs, "hint", hint)
+	}
+	l.logger.Log(context.Background(), noticeLevel(severity), message, attrs...)
+}
+
+// noticeLevel maps a PostgreSQL message severity to a log level.
+func noticeLevel(severity string) slog.Level {
+	switch s := strings.ToUpper(severity); {
+	case strings.HasPrefix(s, "DEBUG"), s == "LOG":
+		return slog.LevelDebug
+	case s == "INFO", s == "NOTICE", s == "":
+		return slog.LevelInfo
+	case s == "WARNING":
+		return slog.LevelWarn
+	default:
+		return slog.LevelError
+	}
+}
+
+// sprintf formats like ConsoleLogger: a message without args is taken as is,
+// so a stray % in it is not read as a verb.
+func sprintf(format string, args []any) string {
+	if len(args) == 0 {
+		return format
+	}
+	return fmt.Sprintf(format, args...)
+}
@@ -0,0 +1,107 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func decodeRecords(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	t.Helper()
+	var records []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var r map[string]any
+		if err := json.Unmarshal([]byte(line), &r); err != nil {
+			t.Fatalf("not a JSON record: %q: %v", line, err)
+		}
+		records = append(records, r)
+	}
+	return records
+}
+
+func TestSlogLogger_JSON(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := NewSlogLogger(&buf, FormatJSON, false, "run-1")
+	if err != nil {
+		t.Fatalf("NewSlogLogger: %v", err)
+	}
+	logger.Verbose("dropped: %d", 1)
+	logger.Info("Loaded %d files", 12)
+	logger.Error("100% broken")
+
+	records := decodeRecords(t, &buf)
+	if len(records) != 2 {
+		t.Fatalf("got %d records, want 2 (Verbose is dropped without verbose): %v", len(records), records)
+	}
+	for i, want := range []struct{ level, msg string }{{"INFO", "Loaded 12 files"}, {"ERROR", "100% broken"}} {
+		r := records[i]
+		if r["level"] != want.level || r["msg"] != want.msg || r["run_id"] != "run-1" || r["time"] == nil {
+			t.Errorf("record %d = %v, want %s %q with run_id and time", i, r, want.level, want.msg)
+		}
+	}
+}
+
+func TestSlogLogger_VerboseAndText(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := NewSlogLogger(&buf, FormatText, true, "")
+	if err != nil {
+		t.Fatalf("NewSlogLogger: %v", err)
+	}
+	if len(logger.RunID()) != 16 {
+		t.Errorf("RunID() = %q, want a generated 16-character id", logger.RunID())
+	}
+	logger.Verbose("Reading %s", "deploy.sql")
+
+	out := buf.String()
+	for _, want := range []string{"level=DEBUG", `msg="Reading deploy.sql"`, "run_id=" + logger.RunID(), "time="} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output %q lacks %q", out, want)
+		}
+	}
+}
+
+func TestSlogLogger_Notice(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := NewSlogLogger(&buf, FormatJSON, false, "run-1")
+	if err != nil {
+		t.Fatalf("NewSlogLogger: %v", err)
+	}
+	logger.Notice("NOTICE", "Applying migrations/001.sql", "", "")
+	logger.Notice("WARNING", "index is unused", "on orders", "drop it")
+	logger.Notice("DEBUG1", "dropped without verbose", "", "")
+
+	records := decodeRecords(t, &buf)
+	if len(records) != 2 {
+		t.Fatalf("got %d records, want 2: %v", len(records), records)
+	}
+	if r := records[0]; r["level"] != "INFO" || r["severity"] != "NOTICE" || r["source"] != "postgres" {
+		t.Errorf("NOTICE record = %v", r)
+	}
+	if r := records[1]; r["level"] != "WARN" || r["detail"] != "on orders" || r["hint"] != "drop it" {
+		t.Errorf("WARNING record = %v", r)
+	}
+}
+
+func TestNoticeLevel(t *testing.T) {
+	tests := map[string]string{
+		"DEBUG2": "DEBUG", "LOG": "DEBUG",
+		"INFO": "INFO", "NOTICE": "INFO", "": "INFO",
+		"WARNING": "WARN",
+		"ERROR":   "ERROR", "FATAL": "ERROR",
+	}
+	for severity, want := range tests {
+		if got := noticeLevel(severity).String(); got != want {
+			t.Errorf("noticeLevel(%q) = %s, want %s", severity, got, want)
+		}
+	}
+}
+
+func TestNewSlogLogger_UnknownFormat(t *testing.T) {
+	if _, err := NewSlogLogger(&bytes.Buffer{}, "xml", false, ""); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+}
@@ -0,0 +1,257 @@
+package metadata
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/vvka-141/pgmi/pkg/pgmi"
+)
+
+// CustomAttributeError lists every file that breaks the project's metadata
+// schema. Like MetadataError it reports as invalid configuration (exit 10).
+type CustomAttributeError struct {
+	Problems []string
+}
+
+// Error implements the error interface, one problem per line.
+func (e *CustomAttributeError) Error() string {
+	return "custom <pgmi-meta> attributes do not match the metadata schema in pgmi.yaml:\n  " + strings.Join(e.Problems, "\n  ")
+}
+
+// Unwrap reports schema violations as invalid configuration.
+func (e *CustomAttributeError) Unwrap() error { return pgmi.ErrInvalidConfig }
+
+// extractCustom collects the prefixed attributes of <pgmi-meta> and its
+// prefixed child elements. It reads raw tokens because the prefix, not the
+// namespace URI it may be bound to, is what a SQL author filters on, and an
+// xmlns declaration is optional in a comment nobody else parses.
+//
+// Unprefixed unknown names are left alone, as they always were.
+func extractCustom(metadataXML string) (map[string]map[string]any, error) {
+	dec := xml.NewDecoder(strings.NewReader(metadataXML))
+	custom := make(map[string]map[string]any)
+	fromAttr := make(map[string]bool)
+
+	add := func(name xml.Name, value string, attr bool) error {
+		qualified := name.Space + ":" + name.Local
+		if err := checkCustomName(name.Space, name.Local); err != nil {
+			return err
+		}
+		if custom[name.Space] == nil {
+			custom[name.Space] = make(map[string]any)
+		}
+		existing, seen := custom[name.Space][name.Local]
+		switch {
+		case !seen:
+			custom[name.Space][name.Local] = value
+			fromAttr[qualified] = attr
+		case attr || fromAttr[qualified]:
+			return fmt.Errorf("%s is given both as an attribute and as an element", qualified)
+		default:
+			// A repeated element is a list: <ops:tag>a</ops:tag><ops:tag>b</ops:tag>.
+			if s, ok := existing.(string); ok {
+				existing = []string{s}
+			}
+			custom[name.Space][name.Local] = append(existing.([]string), value)
+		}
+		return nil
+	}
+
+	depth := 0
+	var element *xml.Name
+	var text strings.Builder
+	for {
+		tok, err := dec.RawToken()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			depth++
+			switch {
+			case depth == 1:
+				for _, a := range t.Attr {
+					if isCustomName(a.Name) {
+						if err := add(a.Name, a.Value, true); err != nil {
+							return nil, err
+						}
+					}
+				}
+			case depth == 2 && isCustomName(t.Name):
+				for _, a := range t.Attr {
+					if a.Name.Space != "xmlns" && a.Name.Local != "xmlns" {
+						return nil, fmt.Errorf("custom element <%s:%s> cannot carry attributes", t.Name.Space, t.Name.Local)
+					}
+				}
+				name := t.Name
+				element = &name
+				text.Reset()
+			case depth == 3 && element != nil:
+				return nil, fmt.Errorf("custom element <%s:%s> holds text only, found <%s>", element.Space, element.Local, t.Name.Local)
+			}
+		case xml.CharData:
+			if element != nil {
+				text.Write(t)
+			}
+		case xml.EndElement:
+			if depth == 2 && element != nil {
+				if err := add(*element, strings.TrimSpace(text.String()), false); err != nil {
+					return nil, err
+				}
+				element = nil
+			}
+			depth--
+			if depth == 0 {
+				return nonEmpty(custom), nil
+			}
+		}
+	}
+	return nonEmpty(custom), nil
+}
+
+// checkCustomName rejects a custom name in a reserved prefix, or one whose
+// local part repeats a built-in: XML readers would confuse ops:description
+// with description, and front-matter follows XML so a file converts cleanly.
+func checkCustomName(prefix, local string) error {
+	if slices.Contains(pgmi.ReservedMetadataPrefixes, strings.ToLower(prefix)) {
+		return fmt.Errorf("%s:%s uses the reserved prefix %q", prefix, local, prefix)
+	}
+	if slices.Contains(pgmi.BuiltinMetadataNames, local) {
+		return fmt.Errorf("%s:%s would be read as the built-in %s; choose another name", prefix, local, local)
+	}
+	return nil
+}
+
+func isCustomName(n xml.Name) bool {
+	return n.Space != "" && n.Space != "xmlns"
+}
+
+func nonEmpty(custom map[string]map[string]any) map[string]map[string]any {
+	if len(custom) == 0 {
+		return nil
+	}
+	return custom
+}
+
+// ApplySchema checks every file's custom attributes against schema and
+// replaces each declared value with its typed form: a bool for boolean, an
+// int64 for integer, a float64 for number. A nil or empty schema accepts
+// everything and changes nothing.
+//
+// Returns a *CustomAttributeError naming every violation across the project.
+func ApplySchema(files []pgmi.FileMetadata, schema pgmi.MetadataSchema) error {
+	if len(schema) == 0 {
+		return nil
+	}
+
+	closed := make(map[string]bool)
+	var required []string
+	for _, name := range slices.Sorted(maps.Keys(schema)) {
+		prefix, _, _ := pgmi.SplitCustomName(name)
+		closed[prefix] = true
+		if schema[name].Required {
+			required = append(required, name)
+		}
+	}
+
+	var problems []string
+	for _, f := range files {
+		if f.Metadata == nil {
+			continue
+		}
+		for _, name := range required {
+			prefix, local, _ := pgmi.SplitCustomName(name)
+			if _, ok := f.Metadata.Custom[prefix][local]; !ok {
+				problems = append(problems, fmt.Sprintf("%s: missing required %s", f.Path, name))
+			}
+		}
+		for _, prefix := range slices.Sorted(maps.Keys(f.Metadata.Custom)) {
+			if !closed[prefix] {
+				continue
+			}
+			values := f.Metadata.Custom[prefix]
+			for _, local := range slices.Sorted(maps.Keys(values)) {
+				name := prefix + ":" + local
+				spec, ok := schema[name]
+				if !ok {
+					problems = append(problems, fmt.Sprintf("%s: %s is not declared in pgmi.yaml", f.Path, name))
+					continue
+				}
+				typed, err := typeCustomValue(values[local], spec)
+				if err != nil {
+					problems = append(problems, fmt.Sprintf("%s: %s %v", f.Path, name, err))
+					continue
+				}
+				values[local] = typed
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		return &CustomAttributeError{Problems: problems}
+	}
+	return nil
+}
+
+// typeCustomValue converts a raw string, or each string of a repeated
+// element, to spec's type.
+func typeCustomValue(raw any, spec pgmi.CustomAttributeSpec) (any, error) {
+	switch v := raw.(type) {
+	case string:
+		return typeCustomScalar(v, spec)
+	case []string:
+		out := make([]any, len(v))
+		for i, s := range v {
+			typed, err := typeCustomScalar(s, spec)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = typed
+		}
+		return out, nil
+	default:
+		// Already typed: ApplySchema ran on these files before.
+		return raw, nil
+	}
+}
+
+func typeCustomScalar(s string, spec pgmi.CustomAttributeSpec) (any, error) {
+	switch spec.Type {
+	case pgmi.CustomTypeBoolean:
+		// xs:boolean's lexical space, which is what an XML reader expects.
+		switch s {
+		case "true", "1":
+			return true, nil
+		case "false", "0":
+			return false, nil
+		}
+		return nil, fmt.Errorf("= %q is not a boolean (true, false, 1 or 0)", s)
+	case pgmi.CustomTypeInteger:
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("= %q is not an integer", s)
+		}
+		return n, nil
+	case pgmi.CustomTypeNumber:
+		n, err := strconv.ParseFloat(s, 64)
+		if err != nil || math.IsInf(n, 0) || math.IsNaN(n) {
+			return nil, fmt.Errorf("= %q is not a finite number", s)
+		}
+		return n, nil
+	default:
+		if len(spec.Values) > 0 && !slices.Contains(spec.Values, s) {
+			return nil, fmt.Errorf("= %q is not one of %s", s, strings.Join(spec.Values, ", "))
+		}
+		return s, nil
+	}
+}
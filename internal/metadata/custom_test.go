@@ -0,0 +1,151 @@
+package metadata
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/vvka-141/pgmi/pkg/pgmi"
+)
+
+func customMeta(attrs, body string) string {
+	return `/*
+<pgmi-meta id="550e8400-e29b-41d4-a716-446655440000" idempotent="true" ` + attrs + `>
+  <description>Backfill orders</description>
+` + body + `
+</pgmi-meta>
+*/
+SELECT 1;
+`
+}
+
+func TestExtract_CustomAttributes(t *testing.T) {
+	content := customMeta(`xmlns:ops="https://example.com/ops" ops:owner="payments" audit:level="high"`, `
+  <ops:window>sunday</ops:window>
+  <ops:tag>billing</ops:tag>
+  <ops:tag> ledger </ops:tag>
+  <unprefixed>ignored as before</unprefixed>`)
+
+	meta, err := Extract(content, "backfill.sql")
+	if err != nil {
+		t.Fatalf("Extract: %v", err)
+	}
+	want := map[string]map[string]any{
+		"ops":   {"owner": "payments", "window": "sunday", "tag": []string{"billing", "ledger"}},
+		"audit": {"level": "high"},
+	}
+	if !reflect.DeepEqual(meta.Custom, want) {
+		t.Errorf("Custom = %#v, want %#v", meta.Custom, want)
+	}
+	if meta.Description != "Backfill orders" {
+		t.Errorf("Description = %q; custom names must not disturb the built-ins", meta.Description)
+	}
+}
+
+func TestExtract_NoCustomAttributes(t *testing.T) {
+	meta, err := Extract(customMeta("", ""), "plain.sql")
+	if err != nil {
+		t.Fatalf("Extract: %v", err)
+	}
+	if meta.Custom != nil {
+		t.Errorf("Custom = %#v, want nil", meta.Custom)
+	}
+}
+
+func TestExtract_CustomAttributeErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		attrs string
+		body  string
+		want  string
+	}{
+		{"nested element", "", "<ops:window><day>sunday</day></ops:window>", "holds text only"},
+		{"element with attributes", "", `<ops:window day="sunday"/>`, "cannot carry attributes"},
+		{"attribute and element", `ops:owner="a"`, "<ops:owner>b</ops:owner>", "both as an attribute and as an element"},
+		{"reserved prefix", `pgmi:owner="a"`, "", "reserved prefix"},
+		{"shadows a built-in", "", "<ops:description>x</ops:description>", "built-in description"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Extract(customMeta(tt.attrs, tt.body), "bad.sql")
+			var metaErr *MetadataError
+			if !errors.As(err, &metaErr) {
+				t.Fatalf("expected a MetadataError, got %v", err)
+			}
+			if !strings.Contains(err.Error(), tt.want) || !errors.Is(err, pgmi.ErrInvalidConfig) {
+				t.Errorf("error %q should mention %q and exit 10", err, tt.want)
+			}
+		})
+	}
+}
+
+func customFiles() []pgmi.FileMetadata {
+	return []pgmi.FileMetadata{
+		{Path: "./a.sql", Metadata: &pgmi.ScriptMetadata{Custom: map[string]map[string]any{
+			"ops":  {"owner": "payments", "window": "true", "retries": "3", "env": "prod"},
+			"team": {"notes": "anything goes"},
+		}}},
+		{Path: "./b.sql", Metadata: &pgmi.ScriptMetadata{Custom: map[string]map[string]any{
+			"ops": {"owner": "search", "window": []string{"1", "0"}},
+		}}},
+		{Path: "./c.sql"}, // no metadata: never checked
+	}
+}
+
+var opsSchema = pgmi.MetadataSchema{
+	"ops:owner":   {Required: true},
+	"ops:window":  {Type: pgmi.CustomTypeBoolean},
+	"ops:retries": {Type: pgmi.CustomTypeInteger},
+	"ops:env":     {Values: []string{"all", "prod"}},
+}
+
+func TestApplySchema_TypesDeclaredValues(t *testing.T) {
+	files := customFiles()
+	if err := ApplySchema(files, opsSchema); err != nil {
+		t.Fatalf("ApplySchema: %v", err)
+	}
+	a := files[0].Metadata.Custom
+	if a["ops"]["window"] != true || a["ops"]["retries"] != int64(3) || a["ops"]["env"] != "prod" {
+		t.Errorf("a.sql ops = %#v", a["ops"])
+	}
+	if a["team"]["notes"] != "anything goes" {
+		t.Errorf("an undeclared prefix must stay free-form, got %#v", a["team"])
+	}
+	if got := files[1].Metadata.Custom["ops"]["window"]; !reflect.DeepEqual(got, []any{true, false}) {
+		t.Errorf("a repeated element is typed item by item, got %#v", got)
+	}
+}
+
+func TestApplySchema_ReportsEveryViolation(t *testing.T) {
+	files := customFiles()
+	files[0].Metadata.Custom["ops"]["env"] = "staging"
+	files[0].Metadata.Custom["ops"]["ownr"] = "typo"
+	files[1].Metadata.Custom["ops"]["retries"] = "three"
+	delete(files[1].Metadata.Custom["ops"], "owner")
+
+	err := ApplySchema(files, opsSchema)
+	var customErr *CustomAttributeError
+	if !errors.As(err, &customErr) || !errors.Is(err, pgmi.ErrInvalidConfig) {
+		t.Fatalf("expected a CustomAttributeError reporting as invalid config, got %v", err)
+	}
+	want := []string{
+		`./a.sql: ops:env = "staging" is not one of all, prod`,
+		"./a.sql: ops:ownr is not declared in pgmi.yaml",
+		"./b.sql: missing required ops:owner",
+		`./b.sql: ops:retries = "three" is not an integer`,
+	}
+	if !reflect.DeepEqual(customErr.Problems, want) {
+		t.Errorf("problems:\n  %s\nwant:\n  %s", strings.Join(customErr.Problems, "\n  "), strings.Join(want, "\n  "))
+	}
+}
+
+func TestApplySchema_EmptySchemaChangesNothing(t *testing.T) {
+	files := customFiles()
+	if err := ApplySchema(files, nil); err != nil {
+		t.Fatalf("ApplySchema: %v", err)
+	}
+	if files[0].Metadata.Custom["ops"]["window"] != "true" {
+		t.Error("without a schema every value stays a string")
+	}
+}
@@ -0,0 +1,178 @@
+package metadata
+
+import (
+	"cmp"
+	"fmt"
+	"maps"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/vvka-141/pgmi/pkg/pgmi"
+)
+
+// DependencyError lists every <dependsOn> problem found across a project:
+// references to scripts that do not exist and dependency cycles. Like
+// MetadataError it reports as invalid configuration (exit 10).
+type DependencyError struct {
+	Problems []string
+}
+
+// Error implements the error interface, one problem per line.
+func (e *DependencyError) Error() string {
+	return "invalid <dependsOn> in project metadata:\n  " + strings.Join(e.Problems, "\n  ")
+}
+
+// Unwrap reports dependency problems as invalid configuration.
+func (e *DependencyError) Unwrap() error { return pgmi.ErrInvalidConfig }
+
+// ResolveDependencies resolves every <dependsOn> id in files to the path of
+// the script it names and checks the resulting graph. An id is either another
+// script's <pgmi-meta> id or its project path ("./schemas/001.sql" or
+// "schemas/001.sql"); only SQL files outside __test__ can be named, since only
+// they are in the plan. The result maps each dependent path to the paths it
+// depends on, in declaration order; files without dependencies are absent.
+//
+// Returns a *DependencyError naming every unknown id and every cycle.
+func ResolveDependencies(files []pgmi.FileMetadata) (map[string][]string, error) {
+	byRef := make(map[string]string)
+	for _, f := range files {
+		if !pgmi.IsSQLExtension(f.Extension) || pgmi.IsTestPath(f.Path) {
+			continue
+		}
+		byRef[normalizeDependencyRef(f.Path)] = f.Path
+		if f.Metadata != nil && f.Metadata.ID != uuid.Nil {
+			byRef[f.Metadata.ID.String()] = f.Path
+		}
+	}
+
+	deps := make(map[string][]string)
+	var problems []string
+	for _, f := range files {
+		if f.Metadata == nil {
+			continue
+		}
+		for _, ref := range f.Metadata.DependsOn {
+			target, ok := byRef[normalizeDependencyRef(ref)]
+			if !ok {
+				problems = append(problems, fmt.Sprintf("%s: dependsOn %q matches no script id or path", f.Path, strings.TrimSpace(ref)))
+				continue
+			}
+			deps[f.Path] = append(deps[f.Path], target)
+		}
+	}
+
+	problems = append(problems, findCycles(deps)...)
+	if len(problems) > 0 {
+		return nil, &DependencyError{Problems: problems}
+	}
+	return deps, nil
+}
+
+// findCycles reports each dependency cycle once, as the chain of paths that
+// closes it. Traversal starts from paths in sorted order so the report is
+// deterministic.
+func findCycles(deps map[string][]string) []string {
+	const (
+		unvisited = iota
+		inProgress
+		done
+	)
+	state := make(map[string]int)
+	var stack []string
+	var cycles []string
+
+	var visit func(p string)
+	visit = func(p string) {
+		state[p] = inProgress
+		stack = append(stack, p)
+		for _, d := range deps[p] {
+			switch state[d] {
+			case inProgress:
+				start := slices.Index(stack, d)
+				chain := append(slices.Clone(stack[start:]), d)
+				cycles = append(cycles, "dependsOn cycle: "+strings.Join(chain, " -> "))
+			case unvisited:
+				visit(d)
+			}
+		}
+		stack = stack[:len(stack)-1]
+		state[p] = done
+	}
+
+	for _, p := range slices.Sorted(maps.Keys(deps)) {
+		if state[p] == unvisited {
+			visit(p)
+		}
+	}
+	return cycles
+}
+
+// DependencyOrder reorders scripts so each runs after everything it depends
+// on, disturbing the sort-key order as little as possible. order lists the
+// paths by their first execution (smallest sort key, then path); deps is the
+// acyclic result of ResolveDependencies.
+//
+// A script that depends on a later one moves to just after the latest script
+// it transitively waits for; scripts that wait for the same one follow it by
+// the length of their dependency chain, then in sort-key order. Everything
+// else keeps its position. This is the rule pgmi_plan_detail_view.dependency_order
+// implements in SQL, and the two must stay in step.
+func DependencyOrder(order []string, deps map[string][]string) []string {
+	rank := make(map[string]int, len(order))
+	for i, p := range order {
+		rank[p] = i
+	}
+
+	type placement struct{ wait, depth int }
+	memo := make(map[string]placement)
+	var place func(p string) placement
+	place = func(p string) placement {
+		if pl, ok := memo[p]; ok {
+			return pl
+		}
+		pl := placement{wait: -1}
+		for _, d := range deps[p] {
+			dp := place(d)
+			pl.wait = max(pl.wait, rank[d], dp.wait)
+			pl.depth = max(pl.depth, dp.depth+1)
+		}
+		memo[p] = pl
+		return pl
+	}
+
+	sorted := slices.Clone(order)
+	slices.SortStableFunc(sorted, func(a, b string) int {
+		pa, pb := place(a), place(b)
+		if n := cmp.Compare(max(rank[a], pa.wait), max(rank[b], pb.wait)); n != 0 {
+			return n
+		}
+		if n := cmp.Compare(pa.depth, pb.depth); n != 0 {
+			return n
+		}
+		return cmp.Compare(rank[a], rank[b])
+	})
+	return sorted
+}
+
+// normalizeDependencyRef puts a <dependsOn> id in the form it is matched in:
+// a UUID in canonical lower case, anything else as a "./"-prefixed,
+// forward-slash project path, the form the scanner gives every file.
+func normalizeDependencyRef(ref string) string {
+	ref = strings.TrimSpace(ref)
+	if id, err := uuid.Parse(ref); err == nil {
+		return id.String()
+	}
+	p := path.Clean(strings.ReplaceAll(ref, "\\", "/"))
+	return "./" + strings.TrimPrefix(p, "./")
+}
+
+// refersTo reports whether ref names the script with the given id or path.
+func refersTo(ref string, id uuid.UUID, filePath string) bool {
+	n := normalizeDependencyRef(ref)
+	if id != uuid.Nil && n == id.String() {
+		return true
+	}
+	return filePath != "" && n == normalizeDependencyRef(filePath)
+}
@@ -0,0 +1,138 @@
+package metadata
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/vvka-141/pgmi/pkg/pgmi"
+)
+
+func sqlFile(path string, id string, dependsOn ...string) pgmi.FileMetadata {
+	f := pgmi.FileMetadata{Path: path, Extension: ".sql"}
+	if id != "" {
+		f.Metadata = &pgmi.ScriptMetadata{ID: uuid.MustParse(id), Idempotent: true, DependsOn: dependsOn}
+	}
+	return f
+}
+
+const (
+	usersID  = "11111111-1111-4111-8111-111111111111"
+	ordersID = "22222222-2222-4222-8222-222222222222"
+	viewsID  = "33333333-3333-4333-8333-333333333333"
+)
+
+func TestResolveDependencies_ByIDAndPath(t *testing.T) {
+	files := []pgmi.FileMetadata{
+		sqlFile("./schemas/users.sql", usersID),
+		sqlFile("./schemas/orders.sql", ordersID, strings.ToUpper(usersID)),
+		sqlFile("./views/report.sql", viewsID, "schemas/orders.sql", `.\lib\helpers.sql`),
+		sqlFile("./lib/helpers.sql", ""),
+	}
+
+	deps, err := ResolveDependencies(files)
+	if err != nil {
+		t.Fatalf("ResolveDependencies: %v", err)
+	}
+	if got := deps["./schemas/orders.sql"]; !slices.Equal(got, []string{"./schemas/users.sql"}) {
+		t.Errorf("orders depends on %v, want the users path (id matched case-insensitively)", got)
+	}
+	if got := deps["./views/report.sql"]; !slices.Equal(got, []string{"./schemas/orders.sql", "./lib/helpers.sql"}) {
+		t.Errorf("report depends on %v, want orders and helpers in declaration order", got)
+	}
+	if _, ok := deps["./schemas/users.sql"]; ok {
+		t.Error("a file without dependencies should be absent from the result")
+	}
+}
+
+func TestResolveDependencies_ReportsUnknownIDsAndCycles(t *testing.T) {
+	files := []pgmi.FileMetadata{
+		sqlFile("./a.sql", usersID, "./b.sql"),
+		sqlFile("./b.sql", ordersID, viewsID),
+		sqlFile("./c.sql", viewsID, "./a.sql"),
+		sqlFile("./d.sql", "44444444-4444-4444-8444-444444444444", "./missing.sql"),
+		{Path: "./__test__/test_a.sql", Extension: ".sql"},
+		sqlFile("./e.sql", "55555555-5555-4555-8555-555555555555", "./__test__/test_a.sql"),
+		{Path: "./data.json", Extension: ".json"},
+		sqlFile("./f.sql", "66666666-6666-4666-8666-666666666666", "./data.json"),
+	}
+
+	_, err := ResolveDependencies(files)
+	var depErr *DependencyError
+	if !errors.As(err, &depErr) {
+		t.Fatalf("expected *DependencyError, got %v", err)
+	}
+	if !errors.Is(err, pgmi.ErrInvalidConfig) {
+		t.Error("dependency problems should report as ErrInvalidConfig")
+	}
+
+	want := []string{
+		`./d.sql: dependsOn "./missing.sql" matches no script id or path`,
+		`./e.sql: dependsOn "./__test__/test_a.sql" matches no script id or path`,
+		`./f.sql: dependsOn "./data.json" matches no script id or path`,
+		"dependsOn cycle: ./a.sql -> ./b.sql -> ./c.sql -> ./a.sql",
+	}
+	if !slices.Equal(depErr.Problems, want) {
+		t.Errorf("problems:\n%s\nwant:\n%s", strings.Join(depErr.Problems, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestDependencyOrder(t *testing.T) {
+	tests := []struct {
+		name  string
+		order []string
+		deps  map[string][]string
+		want  []string
+	}{
+		{
+			name:  "no dependencies keeps sort-key order",
+			order: []string{"a", "b", "c"},
+			want:  []string{"a", "b", "c"},
+		},
+		{
+			name:  "dependency already earlier changes nothing",
+			order: []string{"a", "b", "c"},
+			deps:  map[string][]string{"c": {"a"}},
+			want:  []string{"a", "b", "c"},
+		},
+		{
+			name:  "file moves to just after a later dependency",
+			order: []string{"a", "b", "c", "d"},
+			deps:  map[string][]string{"a": {"c"}},
+			want:  []string{"b", "c", "a", "d"},
+		},
+		{
+			name:  "chains follow in chain order, waiting files in sort-key order",
+			order: []string{"a", "b", "c", "d", "e"},
+			deps:  map[string][]string{"a": {"b"}, "b": {"d"}, "c": {"d"}},
+			want:  []string{"d", "b", "c", "a", "e"},
+		},
+		{
+			name:  "transitive wait uses the latest ancestor",
+			order: []string{"a", "b", "c", "d"},
+			deps:  map[string][]string{"a": {"b"}, "b": {"d"}},
+			want:  []string{"c", "d", "b", "a"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := DependencyOrder(tt.order, tt.deps)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("DependencyOrder = %v, want %v", got, tt.want)
+			}
+			pos := map[string]int{}
+			for i, p := range got {
+				pos[p] = i
+			}
+			for p, ds := range tt.deps {
+				for _, d := range ds {
+					if pos[d] > pos[p] {
+						t.Errorf("%s placed before its dependency %s", p, d)
+					}
+				}
+			}
+		})
+	}
+}
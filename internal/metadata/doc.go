@@ -1,4 +1,4 @@
-// Package metadata provides XML metadata parsing and validation for PGMI deployment scripts.
+// Package metadata provides metadata parsing and validation for PGMI deployment scripts.
 //
 // # Overview
 //
@@ -6,6 +6,7 @@
 //   - Unique identity (UUID, path-independent)
 //   - Idempotency flag (safe to rerun vs one-time only)
 //   - Execution order control (sortKeys)
+//   - Project-defined attributes (any prefixed name, e.g. ops:owner)
 //
 // # Metadata Format
 //
@@ -27,6 +28,20 @@
 //	</pgmi-meta>
 //	*/
 //
+// or as YAML or JSON front-matter carrying the same fields, which Extract
+// reads into the same Metadata (see frontmatter.go):
+//
+//	/*
+//	---
+//	pgmi-meta:
+//	  id: 550e8400-e29b-41d4-a716-446655440000
+//	  idempotent: true
+//	  sortKeys: [10-setup/0010]
+//	---
+//	*/
+//
+// Render writes a Metadata back out in any of the three.
+//
 // # Validation Rules
 //
 // The XSD schema (schema.xsd) defines strict validation:
@@ -34,6 +49,8 @@
 //   - idempotent: Required boolean (true/false)
 //   - description: Optional free-form text
 //   - sortKeys: Optional, contains one or more <key> elements
+//   - prefixed attributes and elements: Optional, text only; typed and
+//     checked against the metadata schema in pgmi.yaml by ApplySchema
 //
 // # Fallback Identity
 //
@@ -63,15 +80,18 @@
 //
 //   - types.go: Go structs mapping XSD schema
 //   - schema.xsd: Canonical XSD schema (embedded via go:embed)
-//   - extractor.go: XML parsing from SQL comments
+//   - extractor.go: Locating the metadata comment and XML parsing
+//   - frontmatter.go: YAML and JSON front-matter parsing
+//   - render.go: Writing metadata in any syntax
 //   - validator.go: XSD constraint validation
 //   - identity.go: Deterministic md5-based fallback identity generation
+//   - custom.go: Custom attribute extraction and schema checking
 //
 // # Design Principles
 //
 //  1. Fail Fast: Invalid metadata is caught during file scanning (before DB session)
 //  2. Optional but Validated: Metadata is optional, but if present, must be valid
 //  3. Deterministic: File paths always generate the same fallback UUID
-//  4. Pure Go: No external dependencies (uses stdlib encoding/xml)
+//  4. Pure Go: stdlib encoding/xml, and yaml.v3 for front-matter
 //  5. XSD-Compliant: Strict adherence to schema.xsd specification
 package metadata
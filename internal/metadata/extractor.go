@@ -30,14 +30,14 @@ var metaElementRegex = regexp.MustCompile(`<\s*pgmi-meta[\s>]`)
 // oldMetaElementRegex detects old format <pgmi:meta with namespace
 var oldMetaElementRegex = regexp.MustCompile(`<\s*pgmi:meta[\s>]`)
 
-// Extract parses PGMI metadata from the first block comment in SQL file content.
-// It searches for /* ... */ comments containing <pgmi-meta> XML elements.
+// Extract parses PGMI metadata from the block comment that holds it: a
+// <pgmi-meta> XML element, or YAML or JSON front-matter carrying the same
+// fields (see frontmatter.go). Every syntax produces the same Metadata.
 //
 // Algorithm:
-//  1. Find the first SQL block comment /* ... */
-//  2. Check if it contains <pgmi-meta> element
-//  3. Parse XML
-//  4. Return parsed metadata
+//  1. Locate the block comment holding the metadata
+//  2. Parse it as XML or front-matter, by the syntax Locate found
+//  3. Return parsed metadata
 //
 // Parameters:
 //   - content: SQL file content
@@ -48,64 +48,31 @@ var oldMetaElementRegex = regexp.MustCompile(`<\s*pgmi:meta[\s>]`)
 //   - error: ErrNoMetadata if no metadata found, or parsing/validation error
 //
 // Error cases:
-//   - No block comment or no <pgmi-meta> → ErrNoMetadata
-//   - Multiple <pgmi-meta> blocks → MetadataError
+//   - No block comment, or none holding metadata → ErrNoMetadata
+//   - Multiple metadata blocks, in any mix of syntaxes → MetadataError
 //   - Invalid XML syntax → wrapped xml.SyntaxError
+//   - Invalid front-matter → MetadataError with the line in the file
 func Extract(content string, filePath string) (*Metadata, error) {
-	spans := blockComments(content)
-	if len(spans) == 0 {
-		return nil, ErrNoMetadata
-	}
-
-	// Search for metadata in comments (check first few comments)
-	var metadataXML string
-	var metadataCount int
-
-	for _, span := range spans {
-		// Trim the /* */ delimiters and the whitespace the old regex ate.
-		commentContent := strings.TrimSpace(content[span.Start+2 : span.End-2])
-
-		// Check for old format first (backward compatibility detection)
-		if oldMetaElementRegex.MatchString(commentContent) {
-			return nil, &MetadataError{
-				FilePath: filePath,
-				Message:  "Found old metadata format with namespace",
-				Hint: "The metadata format changed to remove XML namespaces.\n\n" +
-					"Migration required:\n" +
-					"  OLD: <pgmi:meta xmlns:pgmi=\"https://pgmi.com/pgmi-metadata/v1\" ...>\n" +
-					"  NEW: <pgmi-meta id=\"...\" idempotent=\"...\" sortKey=\"...\">\n\n" +
-					"Remove the xmlns:pgmi attribute and change <pgmi:meta> to <pgmi-meta>.",
-			}
-		}
-
-		// Check if this comment contains <pgmi-meta>
-		if metaElementRegex.MatchString(commentContent) {
-			metadataCount++
-			if metadataCount > 1 {
-				return nil, &MetadataError{
-					FilePath: filePath,
-					Message:  "Multiple metadata blocks found",
-					Hint:     "Only one <pgmi-meta> block is allowed per file. Remove duplicates.",
-				}
-			}
-			metadataXML = commentContent
-		}
-	}
-
-	if metadataCount == 0 {
-		return nil, ErrNoMetadata
+	block, err := Locate(content, filePath)
+	if err != nil {
+		return nil, err
 	}
 
 	// Validate size limit
-	if len(metadataXML) > MaxMetadataSize {
+	if len(block.Body) > MaxMetadataSize {
 		return nil, &MetadataError{
 			FilePath: filePath,
-			Message:  fmt.Sprintf("Metadata block exceeds maximum size of %d bytes (got %d bytes)", MaxMetadataSize, len(metadataXML)),
+			Message:  fmt.Sprintf("Metadata block exceeds maximum size of %d bytes (got %d bytes)", MaxMetadataSize, len(block.Body)),
 			Hint: "Metadata should be concise. Move large descriptions to separate documentation files.\n" +
 				"Typical metadata blocks are 200-500 bytes.",
 		}
 	}
 
+	if block.Format != FormatXML {
+		return parseFrontMatter(content, block, filePath)
+	}
+	metadataXML := block.Body
+
 	// Validate content is not empty/whitespace only
 	if strings.TrimSpace(metadataXML) == "" {
 		return nil, &MetadataError{
@@ -123,9 +90,74 @@ func Extract(content string, filePath string) (*Metadata, error) {
 		return nil, wrapXMLError(err, filePath)
 	}
 
+	custom, err := extractCustom(metadataXML)
+	if err != nil {
+		return nil, &MetadataError{
+			FilePath: filePath,
+			Message:  err.Error(),
+			Hint: "Custom metadata is a prefixed attribute or a prefixed child element holding text:\n" +
+				"  <pgmi-meta id=\"...\" idempotent=\"true\" ops:owner=\"payments\">\n" +
+				"    <ops:window>sunday</ops:window>\n" +
+				"  </pgmi-meta>",
+		}
+	}
+	meta.Custom = custom
+
 	return &meta, nil
 }
 
+// Locate finds the block comment holding a file's metadata and the syntax it
+// is written in. A comment holds metadata when it contains a <pgmi-meta>
+// element, or when it opens with YAML or JSON front-matter whose first key is
+// pgmi-meta; only one comment per file may.
+//
+// Returns ErrNoMetadata when no comment holds metadata.
+func Locate(content string, filePath string) (Block, error) {
+	var found Block
+	var metadataCount int
+
+	for _, span := range blockComments(content) {
+		// Trim the /* */ delimiters and the whitespace the old regex ate.
+		commentContent := strings.TrimSpace(content[span.Start+2 : span.End-2])
+
+		// Front-matter first: its description may mention <pgmi-meta> in
+		// prose without being a second block.
+		format, isMeta := frontMatterFormat(commentContent)
+		if !isMeta {
+			// Check for old format first (backward compatibility detection)
+			if oldMetaElementRegex.MatchString(commentContent) {
+				return Block{}, &MetadataError{
+					FilePath: filePath,
+					Message:  "Found old metadata format with namespace",
+					Hint: "The metadata format changed to remove XML namespaces.\n\n" +
+						"Migration required:\n" +
+						"  OLD: <pgmi:meta xmlns:pgmi=\"https://pgmi.com/pgmi-metadata/v1\" ...>\n" +
+						"  NEW: <pgmi-meta id=\"...\" idempotent=\"...\" sortKey=\"...\">\n\n" +
+						"Remove the xmlns:pgmi attribute and change <pgmi:meta> to <pgmi-meta>.",
+				}
+			}
+			format, isMeta = FormatXML, metaElementRegex.MatchString(commentContent)
+		}
+
+		if isMeta {
+			metadataCount++
+			if metadataCount > 1 {
+				return Block{}, &MetadataError{
+					FilePath: filePath,
+					Message:  "Multiple metadata blocks found",
+					Hint:     "Only one metadata block (<pgmi-meta> XML, YAML or JSON front-matter) is allowed per file. Remove duplicates.",
+				}
+			}
+			found = Block{Start: span.Start, End: span.End, Format: format, Body: commentContent}
+		}
+	}
+
+	if metadataCount == 0 {
+		return Block{}, ErrNoMetadata
+	}
+	return found, nil
+}
+
 // ExtractAndValidate combines extraction and validation in one call.
 // This is a convenience function for the common case.
 //
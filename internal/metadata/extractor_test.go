@@ -50,6 +50,35 @@ CREATE TABLE test (id INT);
 	}
 }
 
+// TestExtract_DependsOn tests extraction of <dependsOn> ids, by UUID and by path
+func TestExtract_DependsOn(t *testing.T) {
+	content := `/*
+<pgmi-meta
+    id="550e8400-e29b-41d4-a716-446655440000"
+    idempotent="true">
+  <sortKeys>
+    <key>30-views/0010</key>
+  </sortKeys>
+  <dependsOn>
+    <id>6ba7b810-9dad-41d1-80b4-00c04fd430c8</id>
+    <id>./schemas/001_users.sql</id>
+  </dependsOn>
+</pgmi-meta>
+*/
+CREATE VIEW v AS SELECT 1;
+`
+
+	meta, err := ExtractAndValidate(content, "./views/v.sql")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	want := []string{"6ba7b810-9dad-41d1-80b4-00c04fd430c8", "./schemas/001_users.sql"}
+	if len(meta.DependsOn.IDs) != 2 || meta.DependsOn.IDs[0] != want[0] || meta.DependsOn.IDs[1] != want[1] {
+		t.Errorf("Expected dependsOn %v, got %v", want, meta.DependsOn.IDs)
+	}
+}
+
 // TestExtract_ValidMetadata_MinimalFields tests extraction with only required fields
 func TestExtract_ValidMetadata_MinimalFields(t *testing.T) {
 	content := `/*
@@ -0,0 +1,287 @@
+package metadata
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/vvka-141/pgmi/pkg/pgmi"
+	"gopkg.in/yaml.v3"
+)
+
+// Front-matter is the same metadata as <pgmi-meta>, written as YAML or JSON
+// in the block comment instead:
+//
+//	/*
+//	---
+//	pgmi-meta:
+//	  id: 550e8400-e29b-41d4-a716-446655440000
+//	  idempotent: true
+//	  description: Creates the users table
+//	  sortKeys: [10-schema/0010]
+//	  dependsOn: [./schemas/001_roles.sql]
+//	  ops:owner: payments
+//	---
+//	*/
+//
+// JSON is the same object: {"pgmi-meta": {"id": "...", "idempotent": true}}.
+// Both are read with the YAML parser, JSON being a subset of it.
+//
+// Unlike the XML, which ignores names it does not know, front-matter rejects
+// them: a misspelt sortKey there is an error naming the line, not a script
+// that silently runs in path order.
+
+var (
+	// yamlFrontMatterRegex matches a comment opening with a --- fence whose
+	// first key is pgmi-meta.
+	yamlFrontMatterRegex = regexp.MustCompile(`^---[ \t]*\r?\n\s*pgmi-meta[ \t]*:`)
+
+	// jsonFrontMatterRegex matches a comment that is a JSON object whose
+	// first key is pgmi-meta.
+	jsonFrontMatterRegex = regexp.MustCompile(`^\{\s*"pgmi-meta"\s*:`)
+
+	// yamlErrorRegex splits the line number off a yaml.v3 error message.
+	yamlErrorRegex = regexp.MustCompile(`^yaml: line (\d+): (.*)$`)
+)
+
+// frontMatterFormat reports whether a trimmed comment is front-matter, and in
+// which syntax.
+func frontMatterFormat(comment string) (Format, bool) {
+	switch {
+	case yamlFrontMatterRegex.MatchString(comment):
+		return FormatYAML, true
+	case jsonFrontMatterRegex.MatchString(comment):
+		return FormatJSON, true
+	}
+	return "", false
+}
+
+// frontMatterFields are the built-in front-matter keys, in the order Render
+// writes them.
+var frontMatterFields = []string{"id", "idempotent", "description", "sortKeys", "dependsOn"}
+
+// parseFrontMatter reads the front-matter in block into the Metadata the same
+// metadata written as <pgmi-meta> would give.
+func parseFrontMatter(content string, block Block, filePath string) (*Metadata, error) {
+	// Errors name lines of the file, not of the comment: that is what an
+	// editor shows.
+	bodyStart := block.Start + strings.Index(content[block.Start:block.End], block.Body)
+	firstLine := strings.Count(content[:bodyStart], "\n") + 1
+	p := &frontMatterParser{filePath: filePath, format: block.Format, firstLine: firstLine}
+
+	dec := yaml.NewDecoder(strings.NewReader(block.Body))
+	var doc yaml.Node
+	if err := dec.Decode(&doc); err != nil {
+		return nil, p.syntaxError(err)
+	}
+	// A closing --- fence starts a second, empty document; anything in it
+	// would be silently ignored.
+	var rest yaml.Node
+	if err := dec.Decode(&rest); err == nil && !isEmptyDocument(&rest) {
+		return nil, p.errorAt(&rest, "", "text after the closing --- fence",
+			"Front-matter is one document: put every field under pgmi-meta before the closing ---.")
+	} else if err != nil && !errors.Is(err, io.EOF) {
+		return nil, p.syntaxError(err)
+	}
+
+	root := &doc
+	if root.Kind == yaml.DocumentNode && len(root.Content) == 1 {
+		root = root.Content[0]
+	}
+	if root.Kind != yaml.MappingNode || len(root.Content) != 2 || root.Content[0].Value != "pgmi-meta" {
+		return nil, p.errorAt(root, "", "front-matter must hold only the pgmi-meta key",
+			"Put every field under pgmi-meta; other keys have no meaning to pgmi.")
+	}
+	fields := root.Content[1]
+	if fields.Kind != yaml.MappingNode {
+		return nil, p.errorAt(fields, "", "pgmi-meta must be a mapping of fields",
+			"Expected:\n  pgmi-meta:\n    id: <uuid>\n    idempotent: true")
+	}
+
+	// The node tree keeps both of two equal keys, where decoding into a
+	// map would have refused them.
+	seen := make(map[string]bool, len(fields.Content)/2)
+	for i := 0; i < len(fields.Content); i += 2 {
+		key := fields.Content[i]
+		if seen[key.Value] {
+			return nil, p.errorAt(key, key.Value, fmt.Sprintf("%s is already defined", key.Value), "Give each field once; list several values as [a, b].")
+		}
+		seen[key.Value] = true
+	}
+
+	meta := &Metadata{XMLName: xml.Name{Local: "pgmi-meta"}}
+	for i := 0; i < len(fields.Content); i += 2 {
+		if err := p.field(meta, fields.Content[i], fields.Content[i+1]); err != nil {
+			return nil, err
+		}
+	}
+	return meta, nil
+}
+
+type frontMatterParser struct {
+	filePath  string
+	format    Format
+	firstLine int
+}
+
+// field sets the Metadata field one front-matter key names.
+func (p *frontMatterParser) field(meta *Metadata, key, value *yaml.Node) error {
+	name := key.Value
+	switch name {
+	case "id":
+		s, err := p.scalar(name, value)
+		if err != nil {
+			return err
+		}
+		id, err := uuid.Parse(s)
+		if err != nil {
+			return p.errorAt(value, name, fmt.Sprintf("%q is not a UUID", s),
+				"Generate one with: uuidgen (Linux/Mac) or [guid]::NewGuid() (PowerShell).")
+		}
+		meta.ID = id
+	case "idempotent":
+		s, err := p.scalar(name, value)
+		if err != nil {
+			return err
+		}
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return p.errorAt(value, name, fmt.Sprintf("%q is not a boolean", s),
+				"true: the script is safe to rerun every deploy; false: it runs once per id.")
+		}
+		meta.Idempotent = &b
+	case "description":
+		if value.Tag == "!!null" {
+			return nil
+		}
+		s, err := p.scalar(name, value)
+		if err != nil {
+			return err
+		}
+		meta.Description = s
+	case "sortKeys":
+		keys, err := p.list(name, value)
+		if err != nil {
+			return err
+		}
+		meta.SortKeys.Keys = keys
+	case "dependsOn":
+		ids, err := p.list(name, value)
+		if err != nil {
+			return err
+		}
+		meta.DependsOn.IDs = ids
+	default:
+		prefix, local, ok := pgmi.SplitCustomName(name)
+		if !ok {
+			return p.errorAt(key, name, fmt.Sprintf("unknown field %q", name),
+				"Built-in fields are "+strings.Join(frontMatterFields, ", ")+
+					"; your own go under a prefix, as ops:owner.")
+		}
+		if err := checkCustomName(prefix, local); err != nil {
+			return p.errorAt(key, name, err.Error(), "")
+		}
+		values, err := p.list(name, value)
+		if err != nil {
+			return err
+		}
+		if meta.Custom == nil {
+			meta.Custom = make(map[string]map[string]any)
+		}
+		if meta.Custom[prefix] == nil {
+			meta.Custom[prefix] = make(map[string]any)
+		}
+		// One value is a plain value, as a single XML element is, so the
+		// same metadata gives the same pgmi_plan_detail_view.custom in every syntax.
+		if len(values) == 1 {
+			meta.Custom[prefix][local] = values[0]
+		} else {
+			meta.Custom[prefix][local] = values
+		}
+	}
+	return nil
+}
+
+// scalar returns the text of a scalar value. A YAML true or 3 is read as the
+// text "true" or "3", as the same attribute in XML would be.
+func (p *frontMatterParser) scalar(name string, n *yaml.Node) (string, error) {
+	if n.Kind == yaml.AliasNode {
+		n = n.Alias
+	}
+	if n.Kind != yaml.ScalarNode || n.Tag == "!!null" {
+		return "", p.errorAt(n, name, "must be a single value", "")
+	}
+	return n.Value, nil
+}
+
+// list returns a sequence of scalars, or a lone scalar as a list of one.
+func (p *frontMatterParser) list(name string, n *yaml.Node) ([]string, error) {
+	if n.Kind == yaml.AliasNode {
+		n = n.Alias
+	}
+	if n.Kind != yaml.SequenceNode {
+		s, err := p.scalar(name, n)
+		if err != nil {
+			return nil, p.errorAt(n, name, "must be a value or a list of values", "")
+		}
+		return []string{s}, nil
+	}
+	if len(n.Content) == 0 {
+		return nil, p.errorAt(n, name, "is an empty list", "Remove the field, or give it at least one value.")
+	}
+	out := make([]string, 0, len(n.Content))
+	for _, item := range n.Content {
+		s, err := p.scalar(name, item)
+		if err != nil {
+			return nil, p.errorAt(item, name, "must be a list of plain values, not nested lists or mappings", "")
+		}
+		out = append(out, s)
+	}
+	return out, nil
+}
+
+// errorAt reports a problem at a node, on its line of the file.
+func (p *frontMatterParser) errorAt(n *yaml.Node, field, message, hint string) error {
+	line := 0
+	if n.Line > 0 {
+		line = p.firstLine + n.Line - 1
+	}
+	return &MetadataError{
+		FilePath: p.filePath,
+		Line:     line,
+		Field:    field,
+		Message:  fmt.Sprintf("%s front-matter: %s", strings.ToUpper(string(p.format)), message),
+		Hint:     hint,
+	}
+}
+
+// syntaxError turns a YAML parse error into a MetadataError on the file's line.
+func (p *frontMatterParser) syntaxError(err error) error {
+	msg, line := err.Error(), 0
+	if m := yamlErrorRegex.FindStringSubmatch(msg); m != nil {
+		n, _ := strconv.Atoi(m[1])
+		msg, line = m[2], p.firstLine+n-1
+	}
+	hint := "Indent fields under pgmi-meta and quote values containing ': ' or starting with a special character."
+	if p.format == FormatJSON {
+		hint = "Check for a missing comma, a trailing comma, or an unquoted key."
+	}
+	return &MetadataError{
+		FilePath: p.filePath,
+		Line:     line,
+		Message:  fmt.Sprintf("%s front-matter does not parse: %s", strings.ToUpper(string(p.format)), msg),
+		Hint:     hint,
+	}
+}
+
+func isEmptyDocument(n *yaml.Node) bool {
+	if n.Kind == yaml.DocumentNode && len(n.Content) == 1 {
+		n = n.Content[0]
+	}
+	return n.Kind == 0 || (n.Kind == yaml.ScalarNode && n.Tag == "!!null")
+}
@@ -0,0 +1,219 @@
+package metadata
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/vvka-141/pgmi/pkg/pgmi"
+)
+
+const xmlUsers = `/*
+<pgmi-meta id="550e8400-e29b-41d4-a716-446655440000" idempotent="false" ops:owner="payments">
+  <description>Users &amp; roles</description>
+  <sortKeys>
+    <key>10-schema/0010</key>
+    <key>90-grants/0010</key>
+  </sortKeys>
+  <dependsOn>
+    <id>./schemas/001_roles.sql</id>
+  </dependsOn>
+  <ops:env>prod</ops:env>
+  <ops:env>staging</ops:env>
+</pgmi-meta>
+*/
+CREATE TABLE users ();
+`
+
+const yamlUsers = `-- users table
+/*
+---
+pgmi-meta:
+  id: 550e8400-e29b-41d4-a716-446655440000
+  idempotent: false
+  description: Users & roles
+  sortKeys:
+    - 10-schema/0010
+    - 90-grants/0010
+  dependsOn: ./schemas/001_roles.sql
+  ops:owner: payments
+  ops:env: [prod, staging]
+---
+*/
+CREATE TABLE users ();
+`
+
+const jsonUsers = `/*
+{
+  "pgmi-meta": {
+    "id": "550e8400-e29b-41d4-a716-446655440000",
+    "idempotent": false,
+    "description": "Users & roles",
+    "sortKeys": ["10-schema/0010", "90-grants/0010"],
+    "dependsOn": ["./schemas/001_roles.sql"],
+    "ops:owner": ["payments"],
+    "ops:env": ["prod", "staging"]
+  }
+}
+*/
+CREATE TABLE users ();
+`
+
+func TestExtract_FrontMatterMatchesXML(t *testing.T) {
+	want, err := Extract(xmlUsers, "users.sql")
+	if err != nil {
+		t.Fatalf("Extract XML: %v", err)
+	}
+	for name, content := range map[string]string{"yaml": yamlUsers, "json": jsonUsers} {
+		t.Run(name, func(t *testing.T) {
+			got, err := ExtractAndValidate(content, "users.sql")
+			if err != nil {
+				t.Fatalf("ExtractAndValidate: %v", err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("front-matter gave\n  %#v\nXML gave\n  %#v", got, want)
+			}
+		})
+	}
+}
+
+func TestLocate_Formats(t *testing.T) {
+	tests := map[string]Format{xmlUsers: FormatXML, yamlUsers: FormatYAML, jsonUsers: FormatJSON}
+	for content, want := range tests {
+		block, err := Locate(content, "users.sql")
+		if err != nil {
+			t.Fatalf("Locate: %v", err)
+		}
+		if block.Format != want {
+			t.Errorf("Format = %q, want %q", block.Format, want)
+		}
+		if got := content[block.Start:block.End]; !strings.HasPrefix(got, "/*") || !strings.HasSuffix(got, "*/") {
+			t.Errorf("span %q is not the comment", got)
+		}
+	}
+}
+
+func TestLocate_IgnoresOtherFrontMatter(t *testing.T) {
+	// A --- fenced comment about something else is just a comment.
+	_, err := Locate("/*\n---\ntitle: notes\n---\n*/\nSELECT 1;", "notes.sql")
+	if !errors.Is(err, ErrNoMetadata) {
+		t.Errorf("expected ErrNoMetadata, got %v", err)
+	}
+}
+
+func TestExtract_FrontMatterErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+		line    int
+	}{
+		{
+			name:    "unknown field names its line",
+			content: "SELECT 0;\n/*\n---\npgmi-meta:\n  id: 550e8400-e29b-41d4-a716-446655440000\n  sortKey: 10\n---\n*/",
+			want:    `unknown field "sortKey"`,
+			line:    6,
+		},
+		{
+			name:    "bad uuid",
+			content: "/*\n---\npgmi-meta:\n  id: not-a-uuid\n---\n*/",
+			want:    `"not-a-uuid" is not a UUID`,
+			line:    4,
+		},
+		{
+			name:    "bad boolean",
+			content: "/*\n{\"pgmi-meta\": {\"idempotent\": \"maybe\"}}\n*/",
+			want:    `"maybe" is not a boolean`,
+			line:    2,
+		},
+		{
+			name:    "syntax error",
+			content: "/*\n{\"pgmi-meta\": {\"id\": \"x\",, }}\n*/",
+			want:    "JSON front-matter does not parse",
+		},
+		{
+			name:    "duplicate key",
+			content: "/*\n---\npgmi-meta:\n  id: a\n  id: b\n---\n*/",
+			want:    "already defined",
+		},
+		{
+			name:    "other top-level keys",
+			content: "/*\n---\npgmi-meta:\n  id: a\ntitle: x\n---\n*/",
+			want:    "only the pgmi-meta key",
+		},
+		{
+			name:    "nested custom value",
+			content: "/*\n---\npgmi-meta:\n  ops:window: {day: sunday}\n---\n*/",
+			want:    "a value or a list of values",
+		},
+		{
+			name:    "reserved prefix",
+			content: "/*\n---\npgmi-meta:\n  pgmi:owner: a\n---\n*/",
+			want:    "reserved prefix",
+		},
+		{
+			name:    "text after the fence",
+			content: "/*\n---\npgmi-meta:\n  id: a\n---\nidempotent: true\n*/",
+			want:    "after the closing ---",
+		},
+		{
+			name:    "mixed with XML",
+			content: "/*\n---\npgmi-meta:\n  id: a\n---\n*/\n/* <pgmi-meta id=\"a\"/> */",
+			want:    "Multiple metadata blocks",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Extract(tt.content, "bad.sql")
+			var metaErr *MetadataError
+			if !errors.As(err, &metaErr) || !errors.Is(err, pgmi.ErrInvalidConfig) {
+				t.Fatalf("expected a MetadataError exiting 10, got %v", err)
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error %q should mention %q", err, tt.want)
+			}
+			if tt.line != 0 && metaErr.Line != tt.line {
+				t.Errorf("Line = %d, want %d (the line in the file)", metaErr.Line, tt.line)
+			}
+		})
+	}
+}
+
+func TestRender_RoundTrips(t *testing.T) {
+	idempotent := true
+	meta := &Metadata{
+		ID:          GenerateFallbackID("./tricky.sql"),
+		Idempotent:  &idempotent,
+		Description: `Checks a < b && "quoted": yes`,
+		SortKeys:    SortKeysElement{Keys: []string{"10-schema/0010", "true"}},
+		DependsOn:   DependsOnElement{IDs: []string{"./a.sql"}},
+		Custom: map[string]map[string]any{
+			"ops": {"window": "true", "note": "line one\nline two", "env": []string{"prod", "a, b"}},
+		},
+	}
+	meta.XMLName.Local = "pgmi-meta"
+
+	for _, format := range Formats {
+		t.Run(string(format), func(t *testing.T) {
+			block, err := Render(meta, format)
+			if err != nil {
+				t.Fatalf("Render: %v", err)
+			}
+			got, err := ExtractAndValidate(block+"\nSELECT 1;\n", "./tricky.sql")
+			if err != nil {
+				t.Fatalf("rendered block does not read back:\n%s\n%v", block, err)
+			}
+			if !reflect.DeepEqual(got, meta) {
+				t.Errorf("round trip changed the metadata:\n%s\ngot  %#v\nwant %#v", block, got, meta)
+			}
+		})
+	}
+}
+
+func TestRender_RefusesTypedCustomValues(t *testing.T) {
+	meta := &Metadata{Custom: map[string]map[string]any{"ops": {"window": true}}}
+	if _, err := Render(meta, FormatYAML); err == nil {
+		t.Error("expected an error for a value ApplySchema has already typed")
+	}
+}
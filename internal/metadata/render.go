@@ -0,0 +1,228 @@
+package metadata
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Render writes meta as a block comment in format, ready to put at the top of
+// a SQL file. Extract reads the result back as the same Metadata, which is
+// what lets `pgmi metadata convert` move a file between syntaxes.
+//
+// Custom values must still be the strings or lists of strings Extract
+// produces, not the typed values ApplySchema leaves behind.
+func Render(meta *Metadata, format Format) (string, error) {
+	switch format {
+	case FormatXML:
+		return renderXML(meta)
+	case FormatYAML:
+		return renderYAML(meta)
+	case FormatJSON:
+		return renderJSON(meta)
+	}
+	return "", fmt.Errorf("unknown metadata format %q", format)
+}
+
+// customNames returns a metadata block's custom names as sorted prefix:name.
+func customNames(custom map[string]map[string]any) []string {
+	var names []string
+	for prefix, values := range custom {
+		for local := range values {
+			names = append(names, prefix+":"+local)
+		}
+	}
+	slices.Sort(names)
+	return names
+}
+
+// customValue returns the value of a prefix:name as a list of strings.
+func customValue(custom map[string]map[string]any, name string) ([]string, error) {
+	prefix, local, _ := strings.Cut(name, ":")
+	switch v := custom[prefix][local].(type) {
+	case string:
+		return []string{v}, nil
+	case []string:
+		return v, nil
+	}
+	return nil, fmt.Errorf("custom attribute %s has a %T value; only text can be written back", name, custom[prefix][local])
+}
+
+var xmlEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;")
+
+// renderXML lays the block out the way `pgmi metadata scaffold` always has:
+// one attribute per line, one child element per line. Single custom values
+// become attributes; lists, and text an attribute would not keep intact,
+// become repeated elements.
+func renderXML(meta *Metadata) (string, error) {
+	attrs := []string{fmt.Sprintf(`id="%s"`, meta.ID)}
+	var elems []string
+	if meta.Idempotent != nil {
+		attrs = append(attrs, fmt.Sprintf(`idempotent="%t"`, *meta.Idempotent))
+	}
+	for _, name := range customNames(meta.Custom) {
+		values, err := customValue(meta.Custom, name)
+		if err != nil {
+			return "", err
+		}
+		// XML readers turn a newline or tab in an attribute into a space.
+		if len(values) == 1 && !strings.ContainsAny(values[0], "\n\r\t") {
+			attrs = append(attrs, fmt.Sprintf(`%s="%s"`, name, xmlEscaper.Replace(values[0])))
+			continue
+		}
+		for _, v := range values {
+			elems = append(elems, fmt.Sprintf("  <%s>%s</%s>", name, xmlEscaper.Replace(v), name))
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString("/*\n<pgmi-meta")
+	for _, a := range attrs {
+		b.WriteString("\n    " + a)
+	}
+	b.WriteString(">\n")
+	if meta.Description != "" {
+		fmt.Fprintf(&b, "  <description>%s</description>\n", xmlEscaper.Replace(meta.Description))
+	}
+	writeXMLList(&b, "sortKeys", "key", meta.SortKeys.Keys)
+	writeXMLList(&b, "dependsOn", "id", meta.DependsOn.IDs)
+	for _, e := range elems {
+		b.WriteString(e + "\n")
+	}
+	b.WriteString("</pgmi-meta>\n*/")
+	return b.String(), nil
+}
+
+func writeXMLList(b *strings.Builder, element, item string, values []string) {
+	if len(values) == 0 {
+		return
+	}
+	fmt.Fprintf(b, "  <%s>\n", element)
+	for _, v := range values {
+		fmt.Fprintf(b, "    <%s>%s</%s>\n", item, xmlEscaper.Replace(v), item)
+	}
+	fmt.Fprintf(b, "  </%s>\n", element)
+}
+
+// frontMatterField is one key of a front-matter block and its value: a
+// string, a bool, or a list of strings.
+type frontMatterField struct {
+	name  string
+	value any
+}
+
+// frontMatterFieldsOf lists meta's fields in the order Render writes them.
+func frontMatterFieldsOf(meta *Metadata) ([]frontMatterField, error) {
+	var fields []frontMatterField
+	fields = append(fields, frontMatterField{"id", meta.ID.String()})
+	if meta.Idempotent != nil {
+		fields = append(fields, frontMatterField{"idempotent", *meta.Idempotent})
+	}
+	if meta.Description != "" {
+		fields = append(fields, frontMatterField{"description", meta.Description})
+	}
+	if len(meta.SortKeys.Keys) > 0 {
+		fields = append(fields, frontMatterField{"sortKeys", meta.SortKeys.Keys})
+	}
+	if len(meta.DependsOn.IDs) > 0 {
+		fields = append(fields, frontMatterField{"dependsOn", meta.DependsOn.IDs})
+	}
+	for _, name := range customNames(meta.Custom) {
+		values, err := customValue(meta.Custom, name)
+		if err != nil {
+			return nil, err
+		}
+		if len(values) == 1 {
+			fields = append(fields, frontMatterField{name, values[0]})
+		} else {
+			fields = append(fields, frontMatterField{name, values})
+		}
+	}
+	return fields, nil
+}
+
+func renderYAML(meta *Metadata) (string, error) {
+	fields, err := frontMatterFieldsOf(meta)
+	if err != nil {
+		return "", err
+	}
+	body := &yaml.Node{Kind: yaml.MappingNode}
+	for _, f := range fields {
+		body.Content = append(body.Content, yamlString(f.name), yamlValue(f.value))
+	}
+	root := &yaml.Node{Kind: yaml.MappingNode, Content: []*yaml.Node{yamlString("pgmi-meta"), body}}
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(root); err != nil {
+		return "", err
+	}
+	if err := enc.Close(); err != nil {
+		return "", err
+	}
+	return "/*\n---\n" + buf.String() + "---\n*/", nil
+}
+
+func yamlString(s string) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: s}
+}
+
+func yamlValue(v any) *yaml.Node {
+	switch v := v.(type) {
+	case bool:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!bool", Value: strconv.FormatBool(v)}
+	case []string:
+		// Flow style keeps short lists on the field's line, as
+		// sortKeys: [10-schema/0010] reads in the docs.
+		seq := &yaml.Node{Kind: yaml.SequenceNode, Style: yaml.FlowStyle}
+		for _, s := range v {
+			if strings.ContainsAny(s, "\n\r") {
+				seq.Style = 0
+			}
+			seq.Content = append(seq.Content, yamlString(s))
+		}
+		return seq
+	default:
+		return yamlString(fmt.Sprint(v))
+	}
+}
+
+func renderJSON(meta *Metadata) (string, error) {
+	fields, err := frontMatterFieldsOf(meta)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	b.WriteString("/*\n{\n  \"pgmi-meta\": {")
+	for i, f := range fields {
+		if i > 0 {
+			b.WriteString(",")
+		}
+		fmt.Fprintf(&b, "\n    %s: %s", jsonText(f.name), jsonText(f.value))
+	}
+	b.WriteString("\n  }\n}\n*/")
+	return b.String(), nil
+}
+
+// jsonText marshals v on one line without HTML escaping: a description
+// saying "a < b" stays readable, which is half the point of not writing XML.
+func jsonText(v any) string {
+	if list, ok := v.([]string); ok {
+		items := make([]string, len(list))
+		for i, s := range list {
+			items[i] = jsonText(s)
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	_ = enc.Encode(v) // strings and bools always encode
+	return strings.TrimSuffix(buf.String(), "\n")
+}
@@ -0,0 +1,206 @@
+package metadata
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ReplaceSortKeys returns content with the sort keys of its metadata block
+// replaced by keys, one for one and in order, and every other byte left as it
+// was: the layout, the comments, the other fields. `pgmi metadata renumber`
+// uses it so that its diff shows the keys and nothing else.
+//
+// The block must already list len(keys) sort keys. The result is read back,
+// and anything but the keys coming out different is an error.
+func ReplaceSortKeys(content, filePath string, keys []string) (string, error) {
+	block, err := Locate(content, filePath)
+	if err != nil {
+		return "", err
+	}
+	meta, err := Extract(content, filePath)
+	if err != nil {
+		return "", err
+	}
+	if len(meta.SortKeys.Keys) != len(keys) {
+		return "", fmt.Errorf("%s lists %d sort keys, not %d", filePath, len(meta.SortKeys.Keys), len(keys))
+	}
+
+	bodyStart := block.Start + strings.Index(content[block.Start:block.End], block.Body)
+	var spans []keySpan
+	if block.Format == FormatXML {
+		spans, err = xmlSortKeySpans(block.Body)
+	} else {
+		spans, err = frontMatterSortKeySpans(block.Body)
+	}
+	if err != nil {
+		return "", fmt.Errorf("cannot locate the sort keys in %s: %w", filePath, err)
+	}
+	if len(spans) != len(keys) {
+		return "", fmt.Errorf("cannot locate the sort keys in %s: found %d of %d", filePath, len(spans), len(keys))
+	}
+
+	var b strings.Builder
+	last := 0
+	for i, span := range spans {
+		b.WriteString(block.Body[last:span.start])
+		b.WriteString(span.encode(keys[i]))
+		last = span.end
+	}
+	b.WriteString(block.Body[last:])
+	replaced := content[:bodyStart] + b.String() + content[bodyStart+len(block.Body):]
+
+	check, err := Extract(replaced, filePath)
+	want := *meta
+	want.SortKeys.Keys = keys
+	if err != nil || !reflect.DeepEqual(check, &want) {
+		return "", fmt.Errorf("cannot rewrite the sort keys of %s in place; edit them by hand", filePath)
+	}
+	return replaced, nil
+}
+
+// keySpan is where one sort key's text sits in a metadata block, and how to
+// write a replacement there.
+type keySpan struct {
+	start, end int
+	encode     func(string) string
+}
+
+// xmlSortKeySpans finds the text of each <key> inside <sortKeys>.
+func xmlSortKeySpans(body string) ([]keySpan, error) {
+	dec := xml.NewDecoder(strings.NewReader(body))
+	var spans []keySpan
+	var path []string
+	textStart := -1
+	for {
+		tok, err := dec.RawToken()
+		if errors.Is(err, io.EOF) {
+			return spans, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			path = append(path, t.Name.Local)
+			if slices.Equal(path, []string{"pgmi-meta", "sortKeys", "key"}) && t.Name.Space == "" {
+				textStart = int(dec.InputOffset())
+			}
+		case xml.EndElement:
+			if textStart >= 0 && slices.Equal(path, []string{"pgmi-meta", "sortKeys", "key"}) {
+				// InputOffset is now past </key>.
+				end := int(dec.InputOffset()) - len("</key>")
+				if end < textStart || body[end:end+len("</key>")] != "</key>" {
+					return nil, fmt.Errorf("unexpected </key> layout")
+				}
+				spans = append(spans, keySpan{textStart, end, xmlEscaper.Replace})
+				textStart = -1
+			}
+			path = path[:len(path)-1]
+		case xml.CharData, xml.Comment:
+		default:
+			if textStart >= 0 {
+				return nil, fmt.Errorf("<key> holds more than text")
+			}
+		}
+	}
+}
+
+// frontMatterSortKeySpans finds each sortKeys scalar from its node position.
+func frontMatterSortKeySpans(body string) ([]keySpan, error) {
+	var doc yaml.Node
+	if err := yaml.NewDecoder(strings.NewReader(body)).Decode(&doc); err != nil {
+		return nil, err
+	}
+	root := doc.Content[0]
+	fields := root.Content[1]
+	var keys *yaml.Node
+	for i := 0; i < len(fields.Content); i += 2 {
+		if fields.Content[i].Value == "sortKeys" {
+			keys = fields.Content[i+1]
+		}
+	}
+	if keys == nil {
+		return nil, nil
+	}
+	scalars := []*yaml.Node{keys}
+	if keys.Kind == yaml.SequenceNode {
+		scalars = keys.Content
+	}
+
+	lineStarts := []int{0}
+	for i, c := range body {
+		if c == '\n' {
+			lineStarts = append(lineStarts, i+1)
+		}
+	}
+	spans := make([]keySpan, 0, len(scalars))
+	for _, n := range scalars {
+		if n.Line < 1 || n.Line > len(lineStarts) {
+			return nil, fmt.Errorf("no position for %q", n.Value)
+		}
+		// Column counts characters, not bytes.
+		start := lineStarts[n.Line-1]
+		for col := 1; col < n.Column; col++ {
+			_, size := utf8.DecodeRuneInString(body[start:])
+			start += size
+		}
+		span, err := scalarSpan(body, start, n)
+		if err != nil {
+			return nil, err
+		}
+		spans = append(spans, span)
+	}
+	return spans, nil
+}
+
+// scalarSpan measures the scalar token starting at start, quotes included,
+// and writes replacements in the same style.
+func scalarSpan(body string, start int, n *yaml.Node) (keySpan, error) {
+	switch n.Style {
+	case yaml.DoubleQuotedStyle:
+		for i := start + 1; i < len(body); i++ {
+			switch body[i] {
+			case '\\':
+				i++
+			case '"':
+				return keySpan{start, i + 1, func(s string) string { return jsonText(s) }}, nil
+			}
+		}
+	case yaml.SingleQuotedStyle:
+		for i := start + 1; i < len(body); i++ {
+			if body[i] == '\'' {
+				if i+1 < len(body) && body[i+1] == '\'' {
+					i++
+					continue
+				}
+				return keySpan{start, i + 1, func(s string) string { return "'" + strings.ReplaceAll(s, "'", "''") + "'" }}, nil
+			}
+		}
+	case 0:
+		end := start + len(n.Value)
+		if end <= len(body) && body[start:end] == n.Value {
+			return keySpan{start, end, plainOrQuoted}, nil
+		}
+	}
+	return keySpan{}, fmt.Errorf("sort key %q is written in a style pgmi does not rewrite", n.Value)
+}
+
+// plainOrQuoted keeps a plain scalar plain unless the new text would not read
+// back as itself; ReplaceSortKeys checks either way.
+func plainOrQuoted(s string) string {
+	var n yaml.Node
+	if yaml.Unmarshal([]byte("k: "+s), &n) == nil && len(n.Content) == 1 &&
+		len(n.Content[0].Content) == 2 && n.Content[0].Content[1].Value == s &&
+		!strings.ContainsAny(s, ",[]{}#") {
+		return s
+	}
+	return jsonText(s)
+}
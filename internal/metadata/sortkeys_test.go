@@ -0,0 +1,77 @@
+package metadata
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestReplaceSortKeys_KeepsEverythingElse(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{
+			name: "xml",
+			content: `/*
+<pgmi-meta id="550e8400-e29b-41d4-a716-446655440000" idempotent="true">
+  <!-- phases -->
+  <sortKeys>
+    <key>001/000</key>
+    <key> 099/7 </key>
+  </sortKeys>
+</pgmi-meta>
+*/
+SELECT 1;`,
+			want: `/*
+<pgmi-meta id="550e8400-e29b-41d4-a716-446655440000" idempotent="true">
+  <!-- phases -->
+  <sortKeys>
+    <key>001/010</key>
+    <key>a&amp;b</key>
+  </sortKeys>
+</pgmi-meta>
+*/
+SELECT 1;`,
+		},
+		{
+			name:    "yaml block list",
+			content: "/*\n---\npgmi-meta:\n  id: 550e8400-e29b-41d4-a716-446655440000 # fixed\n  sortKeys:\n    - 001/000   # first\n    - '099/7'\n---\n*/\nSELECT 1;",
+			want:    "/*\n---\npgmi-meta:\n  id: 550e8400-e29b-41d4-a716-446655440000 # fixed\n  sortKeys:\n    - 001/010   # first\n    - 'a&b'\n---\n*/\nSELECT 1;",
+		},
+		{
+			name:    "json flow list",
+			content: "/*\n{\"pgmi-meta\": {\"sortKeys\": [\"001/000\", \"099/7\"], \"id\": \"550e8400-e29b-41d4-a716-446655440000\"}}\n*/",
+			want:    "/*\n{\"pgmi-meta\": {\"sortKeys\": [\"001/010\", \"a&b\"], \"id\": \"550e8400-e29b-41d4-a716-446655440000\"}}\n*/",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ReplaceSortKeys(tt.content, "a.sql", []string{"001/010", "a&b"})
+			if err != nil {
+				t.Fatalf("ReplaceSortKeys: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReplaceSortKeys_SingleValueStaysPlain(t *testing.T) {
+	content := "/*\n---\npgmi-meta:\n  sortKeys: 001/000\n---\n*/"
+	got, err := ReplaceSortKeys(content, "a.sql", []string{"001/010"})
+	if err != nil {
+		t.Fatalf("ReplaceSortKeys: %v", err)
+	}
+	if !strings.Contains(got, "sortKeys: 001/010\n") {
+		t.Errorf("got\n%s", got)
+	}
+}
+
+func TestReplaceSortKeys_CountMismatch(t *testing.T) {
+	content := "/*\n---\npgmi-meta:\n  sortKeys: [a, b]\n---\n*/"
+	if _, err := ReplaceSortKeys(content, "a.sql", []string{"c"}); err == nil {
+		t.Error("expected an error when the number of keys differs")
+	}
+}
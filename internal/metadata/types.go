@@ -19,18 +19,51 @@ import (
 //	    <key>10-utils/0010</key>
 //	    <key>90-cleanup/9999</key>
 //	  </sortKeys>
+//	  <dependsOn>
+//	    <id>./schemas/001_users.sql</id>
+//	  </dependsOn>
 //	</pgmi-meta>
 //
+// Custom Attributes:
+//
+//	Any prefixed attribute (ops:owner="payments") or prefixed child element
+//	(<ops:window>sunday</ops:window>) is the project's own; see Custom.
+//
 // Multi-Phase Execution:
 //
 //	Files can specify multiple sort keys to execute at different deployment stages.
 //	Each key in the array results in a separate execution entry in the plan.
 type Metadata struct {
-	XMLName     xml.Name        `xml:"pgmi-meta"`
-	ID          uuid.UUID       `xml:"id,attr"`
-	Idempotent  *bool           `xml:"idempotent,attr"`
-	Description string          `xml:"description"`
-	SortKeys    SortKeysElement `xml:"sortKeys"`
+	XMLName     xml.Name         `xml:"pgmi-meta"`
+	ID          uuid.UUID        `xml:"id,attr"`
+	Idempotent  *bool            `xml:"idempotent,attr"`
+	Description string           `xml:"description"`
+	SortKeys    SortKeysElement  `xml:"sortKeys"`
+	DependsOn   DependsOnElement `xml:"dependsOn"`
+
+	// Custom holds the prefixed attributes and elements, by prefix and then
+	// local name. Filled by Extract from the raw XML; see extractCustom.
+	Custom map[string]map[string]any `xml:"-"`
+}
+
+// Format is the syntax a metadata block is written in.
+type Format string
+
+const (
+	FormatXML  Format = "xml"  // <pgmi-meta id="..." idempotent="..."> ... </pgmi-meta>
+	FormatYAML Format = "yaml" // --- / pgmi-meta: / id: ... front-matter
+	FormatJSON Format = "json" // {"pgmi-meta": {"id": ...}} front-matter
+)
+
+// Formats lists every metadata syntax, XML first as the default.
+var Formats = []Format{FormatXML, FormatYAML, FormatJSON}
+
+// Block is the block comment holding a file's metadata, as found by Locate.
+type Block struct {
+	Start  int    // byte offset of the opening /*
+	End    int    // byte offset just past the closing */
+	Format Format // syntax of the metadata inside
+	Body   string // comment content without its delimiters, trimmed
 }
 
 // SortKeysElement represents the <sortKeys> element containing execution keys.
@@ -46,6 +79,20 @@ type SortKeysElement struct {
 	Keys []string `xml:"key"`
 }
 
+// DependsOnElement represents the <dependsOn> element: the scripts that must
+// have run before this one. Each <id> names another script by its <pgmi-meta>
+// id or by its project path. See ResolveDependencies.
+//
+// XML Structure:
+//
+//	<dependsOn>
+//	  <id>550e8400-e29b-41d4-a716-446655440000</id>
+//	  <id>./schemas/001_users.sql</id>
+//	</dependsOn>
+type DependsOnElement struct {
+	IDs []string `xml:"id"`
+}
+
 // ValidationResult contains the outcome of metadata validation.
 // If Valid is false, Errors contains human-readable error messages.
 type ValidationResult struct {
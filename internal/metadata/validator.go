@@ -11,6 +11,7 @@ import (
 //   - Required attributes (id, idempotent)
 //   - UUID validity and format
 //   - Sort keys array (optional, but must be non-empty strings if present)
+//   - dependsOn ids (optional; non-empty, unique, and not the script itself)
 //   - Whitespace-only content
 //
 // Parameters:
@@ -54,6 +55,25 @@ func Validate(m *Metadata, filePath string) ValidationResult {
 	}
 	// Note: Empty sortKeys array is allowed - files without sort keys use path as fallback
 
+	// Optional: dependsOn. Whether each id names a real script, and whether
+	// the graph is acyclic, needs the whole project: see ResolveDependencies.
+	seen := make(map[string]bool, len(m.DependsOn.IDs))
+	for i, ref := range m.DependsOn.IDs {
+		ref = strings.TrimSpace(ref)
+		switch {
+		case ref == "":
+			result.AddError(
+				"dependsOn[%d] cannot be empty or whitespace-only.\n"+
+					"  Name the script this one needs by its <pgmi-meta> id or its project path.", i)
+			continue
+		case refersTo(ref, m.ID, filePath):
+			result.AddError("dependsOn[%d] (%s) names this script itself.", i, ref)
+		case seen[normalizeDependencyRef(ref)]:
+			result.AddError("dependsOn[%d] (%s) is listed more than once.", i, ref)
+		}
+		seen[normalizeDependencyRef(ref)] = true
+	}
+
 	// Optional: description validation (warn about whitespace-only)
 	if m.Description != "" && strings.TrimSpace(m.Description) == "" {
 		result.AddError(
@@ -240,3 +240,39 @@ func TestValidationResult_AddError_MarksInvalid(t *testing.T) {
 		t.Error("Expected invalid after adding error")
 	}
 }
+
+// TestValidate_DependsOn tests the per-file <dependsOn> checks; unknown ids and
+// cycles need the whole project and are ResolveDependencies' job.
+func TestValidate_DependsOn(t *testing.T) {
+	id := uuid.MustParse("550e8400-e29b-41d4-a716-446655440000")
+	tests := []struct {
+		name      string
+		dependsOn []string
+		wantErr   string
+	}{
+		{"valid ids and paths", []string{"6ba7b810-9dad-41d1-80b4-00c04fd430c8", "schemas/users.sql"}, ""},
+		{"empty id", []string{"  "}, "dependsOn[0] cannot be empty"},
+		{"own id", []string{strings.ToUpper(id.String())}, "names this script itself"},
+		{"own path", []string{"views/report.sql"}, "names this script itself"},
+		{"listed twice", []string{"./schemas/users.sql", "schemas/users.sql"}, "dependsOn[1] (schemas/users.sql) is listed more than once"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			meta := &Metadata{
+				ID:         id,
+				Idempotent: boolPtr(true),
+				DependsOn:  DependsOnElement{IDs: tt.dependsOn},
+			}
+			result := Validate(meta, "./views/report.sql")
+			if tt.wantErr == "" {
+				if !result.Valid {
+					t.Errorf("Expected valid result, got errors: %v", result.Errors)
+				}
+				return
+			}
+			if result.Valid || !strings.Contains(result.ErrorString(), tt.wantErr) {
+				t.Errorf("Expected error containing %q, got %v", tt.wantErr, result.Errors)
+			}
+		})
+	}
+}
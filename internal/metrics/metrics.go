@@ -0,0 +1,119 @@
+// Package metrics reports a deployment as Prometheus metrics, for runs on a
+// schedule that nobody watches: written to a file for node_exporter's
+// textfile collector, or pushed to a Pushgateway.
+//
+// Every sample carries a database label, so one file or one Pushgateway
+// group per database holds the latest run against each. The Prometheus
+// text format is written directly; pgmi does not depend on a client library.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Metric names.
+const (
+	DurationSeconds      = "pgmi_deploy_duration_seconds"
+	FilesLoaded          = "pgmi_deploy_files_loaded"
+	TestsRun             = "pgmi_deploy_tests_run"
+	TestsFailed          = "pgmi_deploy_tests_failed"
+	UnitsCommitted       = "pgmi_deploy_units_committed"
+	ExitCode             = "pgmi_deploy_exit_code"
+	LastRunTimestamp     = "pgmi_deploy_last_run_timestamp_seconds"
+	LastSuccessTimestamp = "pgmi_deploy_last_success_timestamp_seconds"
+)
+
+// families lists the metrics in the order they are written, with their help.
+var families = []struct{ name, help string }{
+	{DurationSeconds, "Wall-clock duration of the last deployment."},
+	{FilesLoaded, "Files loaded into the session by the last deployment."},
+	{TestsRun, "Tests started by the last deployment."},
+	{TestsFailed, "Tests that failed in the last deployment."},
+	{UnitsCommitted, "Execution units of the orchestrator script the last deployment committed."},
+	{ExitCode, "Exit code of the last deployment; 0 is success."},
+	{LastRunTimestamp, "Unix time the last deployment finished."},
+	{LastSuccessTimestamp, "Unix time the last successful deployment finished."},
+}
+
+// Run is one deployment, as its metrics describe it.
+type Run struct {
+	Database       string
+	Duration       time.Duration
+	FilesLoaded    int
+	TestsRun       int
+	TestsFailed    int
+	UnitsCommitted int
+	ExitCode       int
+	Finished       time.Time
+}
+
+// Succeeded reports whether the run exited 0.
+func (r Run) Succeeded() bool { return r.ExitCode == 0 }
+
+// sample is one line of the text format: a metric for one database.
+type sample struct {
+	name     string
+	database string
+	value    string
+}
+
+// samples returns the run's samples. A failed run has no last-success
+// sample, so the one an earlier run left is kept wherever they are stored.
+func (r Run) samples() []sample {
+	s := []sample{
+		{DurationSeconds, r.Database, formatFloat(r.Duration.Seconds())},
+		{FilesLoaded, r.Database, strconv.Itoa(r.FilesLoaded)},
+		{TestsRun, r.Database, strconv.Itoa(r.TestsRun)},
+		{TestsFailed, r.Database, strconv.Itoa(r.TestsFailed)},
+		{UnitsCommitted, r.Database, strconv.Itoa(r.UnitsCommitted)},
+		{ExitCode, r.Database, strconv.Itoa(r.ExitCode)},
+		{LastRunTimestamp, r.Database, formatTimestamp(r.Finished)},
+	}
+	if r.Succeeded() {
+		s = append(s, sample{LastSuccessTimestamp, r.Database, formatTimestamp(r.Finished)})
+	}
+	return s
+}
+
+// Write writes the run's metrics to w in the Prometheus text format.
+func Write(w io.Writer, r Run) error {
+	return writeSamples(w, r.samples())
+}
+
+// writeSamples writes samples grouped under their family's HELP and TYPE
+// lines, in the order of families; within a family, in the given order.
+func writeSamples(w io.Writer, samples []sample) error {
+	var b strings.Builder
+	for _, f := range families {
+		header := false
+		for _, s := range samples {
+			if s.name != f.name {
+				continue
+			}
+			if !header {
+				fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s gauge\n", f.name, f.help, f.name)
+				header = true
+			}
+			fmt.Fprintf(&b, "%s{database=\"%s\"} %s\n", s.name, escapeLabel(s.database), s.value)
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// escapeLabel escapes a label value as the text format requires.
+func escapeLabel(v string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(v)
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+func formatTimestamp(t time.Time) string {
+	return formatFloat(float64(t.UnixMilli()) / 1000)
+}
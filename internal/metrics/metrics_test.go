@@ -0,0 +1,277 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+var finished = time.Date(2026, 10, 18, 9, 30, 0, 500_000_000, time.UTC)
+
+func TestWrite(t *testing.T) {
+	var buf bytes.Buffer
+	err := Write(&buf, Run{
+		Database:       "myapp",
+		Duration:       1500 * time.Millisecond,
+		FilesLoaded:    42,
+		TestsRun:       7,
+		UnitsCommitted: 3,
+		Finished:       finished,
+	})
+	if err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+
+	want := `# HELP pgmi_deploy_duration_seconds Wall-clock duration of the last deployment.
+# TYPE pgmi_deploy_duration_seconds gauge
+pgmi_deploy_duration_seconds{database="myapp"} 1.5
+# HELP pgmi_deploy_files_loaded Files loaded into the session by the last deployment.
+# TYPE pgmi_deploy_files_loaded gauge
+pgmi_deploy_files_loaded{database="myapp"} 42
+# HELP pgmi_deploy_tests_run Tests started by the last deployment.
+# TYPE pgmi_deploy_tests_run gauge
+pgmi_deploy_tests_run{database="myapp"} 7
+# HELP pgmi_deploy_tests_failed Tests that failed in the last deployment.
+# TYPE pgmi_deploy_tests_failed gauge
+pgmi_deploy_tests_failed{database="myapp"} 0
+# HELP pgmi_deploy_units_committed Execution units of the orchestrator script the last deployment committed.
+# TYPE pgmi_deploy_units_committed gauge
+pgmi_deploy_units_committed{database="myapp"} 3
+# HELP pgmi_deploy_exit_code Exit code of the last deployment; 0 is success.
+# TYPE pgmi_deploy_exit_code gauge
+pgmi_deploy_exit_code{database="myapp"} 0
+# HELP pgmi_deploy_last_run_timestamp_seconds Unix time the last deployment finished.
+# TYPE pgmi_deploy_last_run_timestamp_seconds gauge
+pgmi_deploy_last_run_timestamp_seconds{database="myapp"} 1792315800.5
+# HELP pgmi_deploy_last_success_timestamp_seconds Unix time the last successful deployment finished.
+# TYPE pgmi_deploy_last_success_timestamp_seconds gauge
+pgmi_deploy_last_success_timestamp_seconds{database="myapp"} 1792315800.5
+`
+	if got := buf.String(); got != want {
+		t.Errorf("Write() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestWrite_FailedRunHasNoLastSuccess(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, Run{Database: "myapp", ExitCode: 13, TestsRun: 2, TestsFailed: 1, Finished: finished}); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	out := buf.String()
+	if strings.Contains(out, LastSuccessTimestamp) {
+		t.Errorf("failed run wrote %s:\n%s", LastSuccessTimestamp, out)
+	}
+	for _, line := range []string{
+		`pgmi_deploy_exit_code{database="myapp"} 13`,
+		`pgmi_deploy_tests_failed{database="myapp"} 1`,
+	} {
+		if !strings.Contains(out, line+"\n") {
+			t.Errorf("output lacks %q:\n%s", line, out)
+		}
+	}
+}
+
+func TestWrite_EscapesLabel(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, Run{Database: `we"ird\db`, Finished: finished}); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if want := `pgmi_deploy_exit_code{database="we\"ird\\db"} 0`; !strings.Contains(buf.String(), want) {
+		t.Errorf("output lacks %q:\n%s", want, buf.String())
+	}
+}
+
+func TestWriteTextfile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pgmi.prom")
+
+	if err := WriteTextfile(path, Run{Database: "app", FilesLoaded: 10, Finished: finished}); err != nil {
+		t.Fatalf("first run: %v", err)
+	}
+	if err := WriteTextfile(path, Run{Database: `odd"name`, FilesLoaded: 5, Finished: finished}); err != nil {
+		t.Fatalf("second database: %v", err)
+	}
+	later := finished.Add(time.Hour)
+	if err := WriteTextfile(path, Run{Database: "app", FilesLoaded: 11, ExitCode: 13, Finished: later}); err != nil {
+		t.Fatalf("failed run: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := parseSamples(data)
+	values := map[string]string{}
+	for _, s := range got {
+		key := s.name + "/" + s.database
+		if _, dup := values[key]; dup {
+			t.Errorf("duplicate sample %s", key)
+		}
+		values[key] = s.value
+	}
+
+	for key, want := range map[string]string{
+		FilesLoaded + "/app":               "11",
+		ExitCode + "/app":                  "13",
+		LastRunTimestamp + "/app":          "1792319400.5",
+		LastSuccessTimestamp + "/app":      "1792315800.5",
+		FilesLoaded + `/odd"name`:          "5",
+		LastSuccessTimestamp + `/odd"name`: "1792315800.5",
+	} {
+		if values[key] != want {
+			t.Errorf("%s = %q, want %q", key, values[key], want)
+		}
+	}
+	if n := strings.Count(string(data), "# TYPE "+FilesLoaded+" gauge"); n != 1 {
+		t.Errorf("%d TYPE lines for %s, want 1:\n%s", n, FilesLoaded, data)
+	}
+
+	if runtime.GOOS != "windows" {
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if perm := info.Mode().Perm(); perm != 0644 {
+			t.Errorf("mode = %o, want 644", perm)
+		}
+	}
+	entries, _ := os.ReadDir(filepath.Dir(path))
+	if len(entries) != 1 {
+		t.Errorf("directory holds %d entries, want only the metrics file", len(entries))
+	}
+}
+
+func TestWriteTextfile_DropsForeignLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pgmi.prom")
+	if err := os.WriteFile(path, []byte("# a comment\nother_metric 1\npgmi_deploy_unknown{database=\"x\"} 1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteTextfile(path, Run{Database: "app", Finished: finished}); err != nil {
+		t.Fatalf("WriteTextfile: %v", err)
+	}
+	data, _ := os.ReadFile(path)
+	if strings.Contains(string(data), "other_metric") || strings.Contains(string(data), "pgmi_deploy_unknown") {
+		t.Errorf("foreign lines kept:\n%s", data)
+	}
+}
+
+func TestPush(t *testing.T) {
+	var gotMethod, gotPath, gotType, gotUser, gotPass, gotBody string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotMethod, gotPath, gotType = r.Method, r.URL.Path, r.Header.Get("Content-Type")
+		gotUser, gotPass, _ = r.BasicAuth()
+		body, _ := io.ReadAll(r.Body)
+		gotBody = string(body)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	gateway := strings.Replace(srv.URL, "http://", "http://ci:s3cret@", 1) + "/"
+	if err := Push(context.Background(), srv.Client(), gateway, Run{Database: "myapp", Finished: finished}); err != nil {
+		t.Fatalf("Push: %v", err)
+	}
+	if gotMethod != http.MethodPost {
+		t.Errorf("method = %s, want POST", gotMethod)
+	}
+	if gotPath != "/metrics/job/pgmi/database/myapp" {
+		t.Errorf("path = %s", gotPath)
+	}
+	if !strings.HasPrefix(gotType, "text/plain") {
+		t.Errorf("Content-Type = %s", gotType)
+	}
+	if gotUser != "ci" || gotPass != "s3cret" {
+		t.Errorf("basic auth = %s:%s, want ci:s3cret", gotUser, gotPass)
+	}
+	if !strings.Contains(gotBody, `pgmi_deploy_exit_code{database="myapp"} 0`) {
+		t.Errorf("body lacks the exit code:\n%s", gotBody)
+	}
+}
+
+func TestPush_SlashInDatabase(t *testing.T) {
+	var gotPath string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+	}))
+	defer srv.Close()
+
+	if err := Push(context.Background(), srv.Client(), srv.URL, Run{Database: "a/b", Finished: finished}); err != nil {
+		t.Fatalf("Push: %v", err)
+	}
+	if want := "/metrics/job/pgmi/database@base64/YS9i"; gotPath != want {
+		t.Errorf("path = %s, want %s", gotPath, want)
+	}
+}
+
+func TestPush_Rejected(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "pushed metrics are invalid", http.StatusBadRequest)
+	}))
+	defer srv.Close()
+
+	gateway := strings.Replace(srv.URL, "http://", "http://ci:s3cret@", 1)
+	err := Push(context.Background(), srv.Client(), gateway, Run{Database: "myapp", Finished: finished})
+	if err == nil {
+		t.Fatal("Push succeeded against a rejecting gateway")
+	}
+	if !strings.Contains(err.Error(), "400") || !strings.Contains(err.Error(), "pushed metrics are invalid") {
+		t.Errorf("error = %v, want the status and the gateway's message", err)
+	}
+	if strings.Contains(err.Error(), "s3cret") {
+		t.Errorf("error reveals the password: %v", err)
+	}
+}
+
+func TestParseGatewayURL(t *testing.T) {
+	for _, bad := range []string{"localhost:9091", "ftp://host", "http://", "http://u:s3cret@%zz"} {
+		_, err := ParseGatewayURL(bad)
+		if err == nil {
+			t.Errorf("ParseGatewayURL(%q) succeeded", bad)
+			continue
+		}
+		if strings.Contains(err.Error(), "s3cret") {
+			t.Errorf("error reveals the password: %v", err)
+		}
+	}
+	if _, err := ParseGatewayURL("https://push.example.com:9091"); err != nil {
+		t.Errorf("ParseGatewayURL(https URL): %v", err)
+	}
+}
+
+func TestTestTally(t *testing.T) {
+	notices := []string{
+		"[pgmi] Test suite started",
+		"[pgmi] Fixture: ./__test__/_setup.sql",
+		"[pgmi] Test: ./__test__/test_a.sql",
+		"a test's own notice",
+		"[pgmi] Test: ./__test__/test_b.sql",
+	}
+
+	tally := &TestTally{}
+	for _, n := range notices {
+		tally.Observe(n)
+	}
+	if run, failed := tally.Counts(true); run != 2 || failed != 1 {
+		t.Errorf("failed deploy in a test: Counts = %d, %d; want 2, 1", run, failed)
+	}
+	if run, failed := tally.Counts(false); run != 2 || failed != 0 {
+		t.Errorf("successful deploy: Counts = %d, %d; want 2, 0", run, failed)
+	}
+	if got := tally.FailingTest(true); got != "./__test__/test_b.sql" {
+		t.Errorf("FailingTest = %q, want ./__test__/test_b.sql", got)
+	}
+
+	tally.Observe("[pgmi] Test suite completed")
+	if run, failed := tally.Counts(true); run != 2 || failed != 0 {
+		t.Errorf("failed deploy after the suite: Counts = %d, %d; want 2, 0", run, failed)
+	}
+	if got := tally.FailingTest(true); got != "" {
+		t.Errorf("FailingTest after the suite = %q, want empty", got)
+	}
+}
@@ -0,0 +1,71 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Job is the job label of the group Push sends to.
+const Job = "pgmi"
+
+// Push sends the run's metrics to the Pushgateway at gateway, an http:// or
+// https:// URL, into the group job="pgmi",database="<database>". It uses POST,
+// which replaces only the metrics it sends, so a failed run leaves the
+// group's last success in place. Credentials in the URL are sent as basic
+// auth.
+func Push(ctx context.Context, client *http.Client, gateway string, r Run) error {
+	u, err := ParseGatewayURL(gateway)
+	if err != nil {
+		return err
+	}
+	label, value := groupLabel("database", r.Database)
+	u = u.JoinPath("metrics", "job", Job, label, value)
+
+	var body bytes.Buffer
+	if err := Write(&body, r); err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.String(), &body)
+	if err != nil {
+		return fmt.Errorf("failed to push metrics to %s: %w", u.Redacted(), err)
+	}
+	req.Header.Set("Content-Type", "text/plain; version=0.0.4")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to push metrics to %s: %w", u.Redacted(), err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("failed to push metrics to %s: %s: %s",
+			u.Redacted(), resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// ParseGatewayURL validates a Pushgateway URL. The error never repeats the
+// URL, which may hold a password.
+func ParseGatewayURL(gateway string) (*url.URL, error) {
+	u, err := url.Parse(gateway)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, errors.New("invalid Pushgateway URL: want an http:// or https:// URL such as http://localhost:9091")
+	}
+	return u, nil
+}
+
+// groupLabel returns a grouping label's two URL path segments, with the value
+// base64-encoded as the Pushgateway requires when it holds a slash.
+func groupLabel(name, value string) (string, string) {
+	if strings.Contains(value, "/") {
+		return name + "@base64", base64.RawURLEncoding.EncodeToString([]byte(value))
+	}
+	return name, value
+}
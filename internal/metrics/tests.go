@@ -0,0 +1,55 @@
+package metrics
+
+import (
+	"sync"
+
+	"github.com/vvka-141/pgmi/pkg/pgmi"
+)
+
+// TestTally counts the tests a deployment runs from the notices of the
+// default test callback. A failing test aborts the deployment, so at most one
+// fails: the one still running when it ended.
+// Safe for concurrent use by multiple goroutines.
+type TestTally struct {
+	mu      sync.Mutex
+	run     int
+	running string // the test started last, until another event
+}
+
+// Observe takes one notice message; other messages than test events are
+// ignored.
+func (t *TestTally) Observe(message string) {
+	event, path, ok := pgmi.ParseTestEventNotice(message)
+	if !ok {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.running = ""
+	if event == pgmi.TestEventTestStart {
+		t.run++
+		t.running = path
+	}
+}
+
+// Counts returns the tests run and failed, given whether the deployment
+// failed.
+func (t *TestTally) Counts(deployFailed bool) (run, failed int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if deployFailed && t.running != "" {
+		failed = 1
+	}
+	return t.run, failed
+}
+
+// FailingTest returns the path of the test a failed deployment failed in, or
+// "" when it failed elsewhere.
+func (t *TestTally) FailingTest(deployFailed bool) string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !deployFailed {
+		return ""
+	}
+	return t.running
+}
@@ -0,0 +1,98 @@
+package metrics
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// samplePattern matches a sample line this package wrote.
+var samplePattern = regexp.MustCompile(`^(pgmi_deploy_\w+)\{database="((?:[^"\\]|\\.)*)"\} (\S+)$`)
+
+// WriteTextfile writes the run's metrics to path for node_exporter's textfile
+// collector, which reads only files named *.prom. The samples other
+// databases' runs left in the file are kept, as is this database's last
+// success when the run failed, so one file can serve several scheduled
+// deployments run one after another.
+//
+// The file is replaced atomically, so the collector never scrapes a partial
+// one. Runs writing the same file at the same moment can lose one another's
+// samples.
+func WriteTextfile(path string, r Run) error {
+	var kept []sample
+	existing, err := os.ReadFile(path)
+	switch {
+	case err == nil:
+		kept = carriedOver(parseSamples(existing), r)
+	case !os.IsNotExist(err):
+		return fmt.Errorf("failed to read metrics file: %w", err)
+	}
+
+	var buf bytes.Buffer
+	if err := writeSamples(&buf, append(kept, r.samples()...)); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to write metrics file: %w", err)
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+	if _, err := tmp.Write(buf.Bytes()); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("failed to write metrics file: %w", err)
+	}
+	// The collector usually runs as another user than the deployment.
+	if err := tmp.Chmod(0644); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("failed to write metrics file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write metrics file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to write metrics file: %w", err)
+	}
+	return nil
+}
+
+// carriedOver returns the existing samples the run does not replace: those
+// of other databases, and this database's last success if the run failed.
+func carriedOver(existing []sample, r Run) []sample {
+	var kept []sample
+	for _, s := range existing {
+		if s.database != r.Database || (s.name == LastSuccessTimestamp && !r.Succeeded()) {
+			kept = append(kept, s)
+		}
+	}
+	return kept
+}
+
+// parseSamples reads back the samples of a file this package wrote. Lines
+// naming a metric it does not write are dropped.
+func parseSamples(data []byte) []sample {
+	known := make(map[string]bool, len(families))
+	for _, f := range families {
+		known[f.name] = true
+	}
+
+	var samples []sample
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for sc.Scan() {
+		m := samplePattern.FindStringSubmatch(sc.Text())
+		if m == nil || !known[m[1]] {
+			continue
+		}
+		samples = append(samples, sample{name: m[1], database: unescapeLabel(m[2]), value: m[3]})
+	}
+	return samples
+}
+
+// unescapeLabel reverses escapeLabel.
+func unescapeLabel(v string) string {
+	return strings.NewReplacer(`\\`, `\`, `\"`, `"`, `\n`, "\n").Replace(v)
+}
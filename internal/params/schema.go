@@ -5,7 +5,7 @@ import (
 	_ "embed"
 	"fmt"
 
-	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jackc/pgx/v5"
 )
 
 //go:embed schema.sql
@@ -14,7 +14,7 @@ var schemaSQL string
 // CreateSchema creates the temporary pgmi schema and utility functions.
 // This must be called before loading parameters into the session.
 // Must use the provided connection to ensure session-scoped tables are in the same session.
-func CreateSchema(ctx context.Context, conn *pgxpool.Conn) error {
+func CreateSchema(ctx context.Context, conn *pgx.Conn) error {
 	_, err := conn.Exec(ctx, schemaSQL)
 	if err != nil {
 		return fmt.Errorf("failed to create pgmi schema: %w", err)
@@ -16,6 +16,7 @@
 --   §_pgmi_parameter       - CLI parameters with type validation
 --   §_pgmi_source          - Project files (non-test)
 --   §_pgmi_source_metadata - Parsed <pgmi-meta> XML blocks
+--   §_pgmi_source_metadata_ext - Metadata only session API v2 exposes
 --   §_pgmi_test_directory  - Test directory hierarchy
 --   §_pgmi_test_source     - Test file content
 --   §pgmi_test_event       - Callback composite type
@@ -44,6 +45,7 @@ BEGIN
         -- drops only that FK constraint, not the referencing table, so re-running
         -- schema.sql in one session hit "relation _pgmi_source_metadata already
         -- exists". Drop it (and pgmi_test_event, likewise not covered) explicitly.
+        DROP TABLE IF EXISTS pg_temp._pgmi_source_metadata_ext CASCADE;
         DROP TABLE IF EXISTS pg_temp._pgmi_source_metadata CASCADE;
         DROP TABLE IF EXISTS pg_temp._pgmi_source CASCADE;
         DROP TABLE IF EXISTS pg_temp._pgmi_parameter CASCADE;
@@ -162,6 +164,26 @@ COMMENT ON TABLE pg_temp._pgmi_source_metadata IS
 
 GRANT SELECT ON TABLE pg_temp._pgmi_source_metadata TO PUBLIC;
 
+-- §_pgmi_source_metadata_ext ──────────────────────────────────────────────────
+-- Populated by: Go, one row per file with a <pgmi-meta> block
+-- Used by: pgmi_plan_detail_view (session API v2)
+-- Kept out of _pgmi_source_metadata because v1's pgmi_source_metadata_view is
+-- SELECT * over that table: a column added there would change the frozen v1
+-- surface under --compat 1.
+CREATE TEMP TABLE _pgmi_source_metadata_ext (
+    path TEXT PRIMARY KEY REFERENCES pg_temp._pgmi_source_metadata(path),
+    -- <dependsOn>, resolved by Go to the paths of the scripts named
+    depends_on TEXT[] NOT NULL DEFAULT '{}',
+    -- Prefixed attributes and elements, {"ops": {"owner": "payments"}}; typed
+    -- by the metadata schema in pgmi.yaml when it declares them
+    custom JSONB NOT NULL DEFAULT '{}'
+);
+
+COMMENT ON TABLE pg_temp._pgmi_source_metadata_ext IS
+    'Script metadata exposed only by session API v2. Session-scoped (ephemeral).';
+
+GRANT SELECT ON TABLE pg_temp._pgmi_source_metadata_ext TO PUBLIC;
+
 -- §_pgmi_test_directory ───────────────────────────────────────────────────────
 -- Populated by: Go when discovering __test__/ directories
 -- Used by: pgmi_test_plan() for depth-first traversal via parent_path
@@ -4,12 +4,13 @@ import (
 	"context"
 	"testing"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/vvka-141/pgmi/internal/params"
 	testhelpers "github.com/vvka-141/pgmi/internal/testing"
 )
 
-func acquireSchemaConn(t *testing.T) (*pgxpool.Conn, func()) {
+func acquireSchemaConn(t *testing.T) (*pgx.Conn, func()) {
 	t.Helper()
 	connStr := testhelpers.RequireDatabase(t)
 
@@ -18,12 +19,12 @@ func acquireSchemaConn(t *testing.T) (*pgxpool.Conn, func()) {
 	if err != nil {
 		t.Fatalf("pool: %v", err)
 	}
-	conn, err := pool.Acquire(ctx)
+	pooled, err := pool.Acquire(ctx)
 	if err != nil {
 		pool.Close()
 		t.Fatalf("acquire: %v", err)
 	}
-	return conn, func() { conn.Release(); pool.Close() }
+	return pooled.Conn(), func() { pooled.Release(); pool.Close() }
 }
 
 // TestRegisterFile_ExtensionMatchesConstraint pins the fix for the extension
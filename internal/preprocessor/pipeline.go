@@ -7,7 +7,7 @@ import (
 	"fmt"
 	"slices"
 
-	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jackc/pgx/v5"
 	"github.com/vvka-141/pgmi/internal/testgen"
 )
 
@@ -22,7 +22,7 @@ type PreprocessResult struct {
 }
 
 // testGenerateFunc is the signature for calling pgmi_test_generate.
-type testGenerateFunc func(ctx context.Context, conn *pgxpool.Conn, pattern string, callback string) (string, error)
+type testGenerateFunc func(ctx context.Context, conn *pgx.Conn, pattern string, callback string) (string, error)
 
 // Pipeline preprocesses SQL by expanding macros.
 type Pipeline struct {
@@ -43,7 +43,7 @@ func NewPipeline() *Pipeline {
 // Process preprocesses SQL by expanding CALL pgmi_test() macros.
 // Queries the pg_temp.pgmi_test_plan() function for test execution plan
 // and generates EXECUTE-based SQL that fetches content from pgmi_test_source.
-func (p *Pipeline) Process(ctx context.Context, conn *pgxpool.Conn, sql string) (*PreprocessResult, error) {
+func (p *Pipeline) Process(ctx context.Context, conn *pgx.Conn, sql string) (*PreprocessResult, error) {
 	result := &PreprocessResult{
 		ExpandedSQL: sql,
 		MacroCount:  0,
@@ -89,7 +89,7 @@ func (p *Pipeline) Process(ctx context.Context, conn *pgxpool.Conn, sql string)
 
 // callTestGenerate calls pg_temp.pgmi_test_generate() to get test execution SQL.
 // This delegates test SQL generation to PostgreSQL, making it part of the API contract.
-func callTestGenerate(ctx context.Context, conn *pgxpool.Conn, pattern string, callback string) (string, error) {
+func callTestGenerate(ctx context.Context, conn *pgx.Conn, pattern string, callback string) (string, error) {
 	query := `SELECT pg_temp.pgmi_test_generate($1, $2)`
 
 	var generatedSQL sql.NullString
@@ -6,14 +6,14 @@ import (
 	"fmt"
 	"testing"
 
-	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jackc/pgx/v5"
 )
 
 var errMockGenerate = errors.New("database connection lost")
 
 // mockTestGenerate returns a testGenerateFunc that maps patterns to fixed SQL.
 func mockTestGenerate(responses map[string]string) testGenerateFunc {
-	return func(_ context.Context, _ *pgxpool.Conn, pattern string, _ string) (string, error) {
+	return func(_ context.Context, _ *pgx.Conn, pattern string, _ string) (string, error) {
 		if sql, ok := responses[pattern]; ok {
 			return sql, nil
 		}
@@ -22,7 +22,7 @@ func mockTestGenerate(responses map[string]string) testGenerateFunc {
 }
 
 func mockTestGenerateError(err error) testGenerateFunc {
-	return func(_ context.Context, _ *pgxpool.Conn, _ string, _ string) (string, error) {
+	return func(_ context.Context, _ *pgx.Conn, _ string, _ string) (string, error) {
 		return "", err
 	}
 }
@@ -100,7 +100,7 @@ func TestPipeline_Process_MacroWithPattern(t *testing.T) {
 
 func TestPipeline_Process_MultipleMacros(t *testing.T) {
 	callCount := 0
-	p := newTestPipeline(func(_ context.Context, _ *pgxpool.Conn, pattern string, _ string) (string, error) {
+	p := newTestPipeline(func(_ context.Context, _ *pgx.Conn, pattern string, _ string) (string, error) {
 		callCount++
 		return fmt.Sprintf("/* expanded %d */", callCount), nil
 	})
@@ -125,7 +125,7 @@ func captureNotices(t *testing.T) func() []string {
 	)
 
 	orig := db.NoticeHandler
-	db.NoticeHandler = func(message, _, _ string) {
+	db.NoticeHandler = func(_, message, _, _ string) {
 		mu.Lock()
 		got = append(got, message)
 		mu.Unlock()
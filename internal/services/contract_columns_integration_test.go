@@ -27,11 +27,12 @@ func TestContractViewColumnsMatchLiveSession(t *testing.T) {
 	defer pool.Close()
 
 	ctx := context.Background()
-	conn, err := pool.Acquire(ctx)
+	pooled, err := pool.Acquire(ctx)
 	if err != nil {
 		t.Fatalf("acquire connection: %v", err)
 	}
-	defer conn.Release()
+	defer pooled.Release()
+	conn := pooled.Conn()
 
 	prepareSessionTables(t, ctx, conn)
 	if _, err := contract.Apply(ctx, conn, ""); err != nil {
@@ -0,0 +1,168 @@
+package services
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/vvka-141/pgmi/internal/checksum"
+	"github.com/vvka-141/pgmi/internal/contract"
+	"github.com/vvka-141/pgmi/internal/files/loader"
+	"github.com/vvka-141/pgmi/internal/files/scanner"
+	"github.com/vvka-141/pgmi/internal/params"
+	"github.com/vvka-141/pgmi/pkg/pgmi"
+)
+
+// compatProject is a v1 project exercising what v1 deploy scripts lean on: a
+// multi-phase plan loop, parameters, metadata and the test macro.
+var compatProject = map[string]string{
+	"schema/01_tables.sql": `/*
+<pgmi-meta id="6f1d7a52-0c3e-4c1b-9a3e-2b5f0d6a4e10" idempotent="true">
+  <sortKeys><key>001/000</key><key>900/000</key></sortKeys>
+</pgmi-meta>
+*/
+CREATE TABLE IF NOT EXISTS compat_t(id int);`,
+	"schema/02_data.sql":                   `INSERT INTO compat_t VALUES (1);`,
+	"schema/__test__/test_rows.sql":        `DO $$ BEGIN IF NOT EXISTS (SELECT 1 FROM compat_t) THEN RAISE EXCEPTION 'empty'; END IF; END $$;`,
+	"schema/__test__/_setup.sql":           `SELECT 1;`,
+	"schema/__test__/nested/test_more.sql": `SELECT 1;`,
+}
+
+const compatDeploySQL = `
+BEGIN;
+CREATE TABLE compat_log(ord int, path text, sort_key text, checksum text, generic_id uuid, idempotent bool);
+DO $$
+DECLARE r RECORD;
+BEGIN
+    FOR r IN SELECT * FROM pg_temp.pgmi_plan_view WHERE pg_temp.pgmi_is_sql_file(path) ORDER BY execution_order LOOP
+        INSERT INTO compat_log VALUES (r.execution_order, r.path, r.sort_key, r.checksum, r.generic_id, r.idempotent);
+        EXECUTE r.content;
+    END LOOP;
+END $$;
+CREATE TABLE compat_env AS SELECT current_setting('pgmi.env', true) AS env,
+    (SELECT string_agg(key || '=' || value, ',' ORDER BY key) FROM pg_temp.pgmi_parameter_view) AS params;
+CREATE TABLE compat_tests AS SELECT ordinal, step_type, script_path FROM pg_temp.pgmi_test_plan();
+CALL pgmi_test();
+COMMIT;
+`
+
+// A v1 project must behave identically under every later --compat: the same
+// plan, the same parameters, the same test plan, and the same v1 objects with
+// the same columns and signatures. This is the promise api-v2.sql makes by
+// only adding objects; TestAPIV2_OnlyAddsDeclaredObjects checks it from the
+// SQL text, this checks it against a server.
+func TestContractCompat_V1ProjectBehavesIdenticallyUnderEveryVersion(t *testing.T) {
+	connString := requireTestDB(t)
+
+	projectDir := t.TempDir()
+	for name, content := range compatProject {
+		path := filepath.Join(projectDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+	scanned, err := scanner.NewScanner(checksum.New()).ScanDirectory(projectDir)
+	if err != nil {
+		t.Fatalf("scan project: %v", err)
+	}
+
+	results := map[contract.Version][]string{}
+	for _, v := range contract.SupportedVersions() {
+		t.Run("v"+string(v), func(t *testing.T) {
+			dbName := "pgmi_itest_compat_v" + string(v)
+			cleanup := createTestDB(t, connString, dbName)
+			defer cleanup()
+
+			pool := connectToTestDB(t, connString, dbName)
+			defer pool.Close()
+
+			ctx := context.Background()
+			pooled, err := pool.Acquire(ctx)
+			if err != nil {
+				t.Fatalf("acquire connection: %v", err)
+			}
+			defer pooled.Release()
+			conn := pooled.Conn()
+
+			if err := params.CreateSchema(ctx, conn); err != nil {
+				t.Fatalf("create schema: %v", err)
+			}
+			l := loader.NewLoader()
+			if err := l.LoadFilesIntoConn(ctx, conn, scanned.Files); err != nil {
+				t.Fatalf("load files: %v", err)
+			}
+			if err := l.LoadParametersIntoConn(ctx, conn, map[string]string{"env": "compat"}); err != nil {
+				t.Fatalf("load params: %v", err)
+			}
+			if _, err := contract.Apply(ctx, conn, string(v)); err != nil {
+				t.Fatalf("apply contract v%s: %v", v, err)
+			}
+
+			svc := newServiceWithReadContent(compatDeploySQL)
+			if _, err := svc.executeDeploySQL(ctx, conn, pgmi.DeploymentConfig{SourcePath: projectDir}); err != nil {
+				t.Fatalf("deploy under v%s: %v", v, err)
+			}
+
+			results[v] = compatSnapshot(t, ctx, conn)
+		})
+	}
+
+	base := results[contract.V1]
+	for v, got := range results {
+		if !slices.Equal(base, got) {
+			t.Errorf("v1 project differs under v%s:\nv1: %v\nv%s: %v", v, base, v, got)
+		}
+	}
+}
+
+// compatSnapshot renders everything a v1 project can observe as comparable
+// lines: its own results, and the shape of every v1 session object.
+func compatSnapshot(t *testing.T, ctx context.Context, conn *pgx.Conn) []string {
+	t.Helper()
+	queries := []string{
+		`SELECT format('log %s %s %s %s %s %s', ord, path, sort_key, checksum, generic_id, idempotent) FROM compat_log ORDER BY ord`,
+		`SELECT format('env %s %s', env, params) FROM compat_env`,
+		`SELECT format('test %s %s %s', ordinal, step_type, script_path) FROM compat_tests ORDER BY ordinal`,
+		`SELECT format('view %s %s', c.relname, array_agg(a.attname || ':' || format_type(a.atttypid, a.atttypmod) ORDER BY a.attnum))
+		 FROM pg_class c
+		 JOIN pg_attribute a ON a.attrelid = c.oid AND a.attnum > 0 AND NOT a.attisdropped
+		 WHERE c.relnamespace = pg_my_temp_schema() AND c.relkind = 'v'
+		   AND c.relname <> ALL($1)
+		 GROUP BY c.relname ORDER BY c.relname`,
+		`SELECT format('function %s(%s) %s', p.proname, pg_get_function_arguments(p.oid), pg_get_function_result(p.oid))
+		 FROM pg_proc p
+		 WHERE p.pronamespace = pg_my_temp_schema() AND p.proname <> ALL($1)
+		 ORDER BY 1`,
+	}
+	var later []string
+	for _, v := range contract.SupportedVersions() {
+		later = append(later, contract.Introduced(v)...)
+	}
+
+	var lines []string
+	for i, q := range queries {
+		var args []any
+		if i >= 3 {
+			args = append(args, later)
+		}
+		rows, err := conn.Query(ctx, q, args...)
+		if err != nil {
+			t.Fatalf("snapshot query %d: %v", i, err)
+		}
+		got, err := pgx.CollectRows(rows, pgx.RowTo[string])
+		if err != nil {
+			t.Fatalf("snapshot query %d: %v", i, err)
+		}
+		lines = append(lines, got...)
+	}
+	if len(lines) == 0 {
+		t.Fatal("empty snapshot")
+	}
+	return lines
+}
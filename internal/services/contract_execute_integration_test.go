@@ -0,0 +1,119 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/vvka-141/pgmi/internal/contract"
+	"github.com/vvka-141/pgmi/internal/db"
+)
+
+// pgmi_execute and pgmi_execute_plan: one NOTICE per file, 'Failed in <path>'
+// errors with the original SQLSTATE, and a savepoint mode that rolls back only
+// the failing file.
+func TestPgmiExecute(t *testing.T) {
+	connString := requireTestDB(t)
+	testDB := "pgmi_itest_pgmi_execute"
+	cleanup := createTestDB(t, connString, testDB)
+	defer cleanup()
+
+	var mu sync.Mutex
+	var notices []string
+	config, err := db.ParseConnectionString(connString)
+	if err != nil {
+		t.Fatalf("Failed to parse connection string: %v", err)
+	}
+	config.Database = testDB
+	poolConfig, err := pgxpool.ParseConfig(db.BuildConnectionString(config))
+	if err != nil {
+		t.Fatalf("Failed to parse pool config: %v", err)
+	}
+	poolConfig.ConnConfig.OnNotice = func(_ *pgconn.PgConn, n *pgconn.Notice) {
+		mu.Lock()
+		defer mu.Unlock()
+		notices = append(notices, n.Message)
+	}
+	rawNotices := func() string {
+		mu.Lock()
+		defer mu.Unlock()
+		return strings.Join(notices, "\n")
+	}
+	ctx := context.Background()
+	pool, err := pgxpool.NewWithConfig(ctx, poolConfig)
+	if err != nil {
+		t.Fatalf("Failed to connect to %s: %v", testDB, err)
+	}
+	defer pool.Close()
+
+	pooled, err := pool.Acquire(ctx)
+	if err != nil {
+		t.Fatalf("acquire connection: %v", err)
+	}
+	defer pooled.Release()
+	conn := pooled.Conn()
+
+	prepareSessionTables(t, ctx, conn)
+	for path, content := range map[string]string{
+		"./a/001_table.sql": "CREATE TABLE exec_t(id int PRIMARY KEY);",
+		"./a/002_rows.sql":  "INSERT INTO exec_t VALUES (1), (2);",
+		"./b/003_dup.sql":   "INSERT INTO exec_t VALUES (3); INSERT INTO exec_t VALUES (1);",
+		"./b/004_more.sql":  "INSERT INTO exec_t VALUES (4);",
+	} {
+		if _, err := conn.Exec(ctx, `SELECT pg_temp.pgmi_register_file($1, $2, md5($2), md5($2))`, path, content); err != nil {
+			t.Fatalf("register %s: %v", path, err)
+		}
+	}
+	if _, err := contract.Apply(ctx, conn, string(contract.V2)); err != nil {
+		t.Fatalf("apply contract: %v", err)
+	}
+
+	var done int
+	if err := conn.QueryRow(ctx, `SELECT pg_temp.pgmi_execute_plan('^\./a/')`).Scan(&done); err != nil {
+		t.Fatalf("pgmi_execute_plan: %v", err)
+	}
+	if done != 2 {
+		t.Errorf("pgmi_execute_plan ran %d entries, want 2", done)
+	}
+	got := rawNotices()
+	if !strings.Contains(got, "pgmi_execute path=./a/001_table.sql sort_key=./a/001_table.sql duration_ms=") {
+		t.Errorf("missing structured NOTICE for 001_table.sql:\n%s", got)
+	}
+
+	// Without a savepoint the failure raises, names the file, and keeps the
+	// unique_violation SQLSTATE.
+	_, err = conn.Exec(ctx, `SELECT pg_temp.pgmi_execute('./b/003_dup.sql')`)
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) || pgErr.Code != "23505" || !strings.HasPrefix(pgErr.Message, "Failed in ./b/003_dup.sql: ") {
+		t.Fatalf("expected 23505 'Failed in ./b/003_dup.sql: ...', got %v", err)
+	}
+
+	// With one, the whole file is rolled back — including its first,
+	// successful insert — and the plan carries on.
+	if err := conn.QueryRow(ctx, `SELECT pg_temp.pgmi_execute_plan('^\./b/', true)`).Scan(&done); err != nil {
+		t.Fatalf("pgmi_execute_plan in savepoint mode: %v", err)
+	}
+	if done != 1 {
+		t.Errorf("savepoint mode: %d entries succeeded, want 1", done)
+	}
+	var ids string
+	if err := conn.QueryRow(ctx, `SELECT string_agg(id::text, ',' ORDER BY id) FROM exec_t`).Scan(&ids); err != nil {
+		t.Fatalf("read exec_t: %v", err)
+	}
+	if ids != "1,2,4" {
+		t.Errorf("exec_t = %s, want 1,2,4 (003_dup.sql rolled back whole)", ids)
+	}
+	got = rawNotices()
+	if !strings.Contains(got, "Failed in ./b/003_dup.sql: ") {
+		t.Errorf("savepoint mode did not warn about the failed file:\n%s", got)
+	}
+
+	_, err = conn.Exec(ctx, `SELECT pg_temp.pgmi_execute('./nope.sql')`)
+	if err == nil || !strings.Contains(err.Error(), "no source file ./nope.sql") {
+		t.Errorf("expected an unknown path to be named, got %v", err)
+	}
+}
@@ -31,11 +31,12 @@ func TestContractFunctionSignaturesMatchLiveSession(t *testing.T) {
 	defer pool.Close()
 
 	ctx := context.Background()
-	conn, err := pool.Acquire(ctx)
+	pooled, err := pool.Acquire(ctx)
 	if err != nil {
 		t.Fatalf("acquire connection: %v", err)
 	}
-	defer conn.Release()
+	defer pooled.Release()
+	conn := pooled.Conn()
 
 	prepareSessionTables(t, ctx, conn)
 	if _, err := contract.Apply(ctx, conn, ""); err != nil {
@@ -107,11 +108,12 @@ func TestEverySessionFunctionIsDeclaredOrExempt(t *testing.T) {
 	defer pool.Close()
 
 	ctx := context.Background()
-	conn, err := pool.Acquire(ctx)
+	pooled, err := pool.Acquire(ctx)
 	if err != nil {
 		t.Fatalf("acquire connection: %v", err)
 	}
-	defer conn.Release()
+	defer pooled.Release()
+	conn := pooled.Conn()
 
 	prepareSessionTables(t, ctx, conn)
 	if _, err := contract.Apply(ctx, conn, ""); err != nil {
@@ -166,11 +168,12 @@ func TestContractTestEventTypeMatchesLiveSession(t *testing.T) {
 	defer pool.Close()
 
 	ctx := context.Background()
-	conn, err := pool.Acquire(ctx)
+	pooled, err := pool.Acquire(ctx)
 	if err != nil {
 		t.Fatalf("acquire connection: %v", err)
 	}
-	defer conn.Release()
+	defer pooled.Release()
+	conn := pooled.Conn()
 
 	prepareSessionTables(t, ctx, conn)
 
@@ -7,12 +7,16 @@ import (
 	"strings"
 	"time"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
-	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/vvka-141/pgmi/internal/checksum"
 	"github.com/vvka-141/pgmi/internal/contract"
 	"github.com/vvka-141/pgmi/internal/db"
+	"github.com/vvka-141/pgmi/internal/metadata"
 	"github.com/vvka-141/pgmi/internal/preprocessor"
+	"github.com/vvka-141/pgmi/internal/tracing"
 	"github.com/vvka-141/pgmi/pkg/pgmi"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // DeployResult contains statistics from a completed deployment.
@@ -23,7 +27,19 @@ type DeployResult struct {
 	Database       string
 	ExecutionUnits int
 	UnitsCommitted int
-	ExecutionMode  string
+	// UnitsSkipped counts tail units a --resume run did not execute because an
+	// earlier run of the same deploy.sql had already committed them.
+	UnitsSkipped  int
+	ExecutionMode string
+	// Template is the template database Database was cloned from, and
+	// TemplateRebuilt whether this run had to deploy into it first. When it
+	// did not, nothing was loaded or executed and the counts above are zero.
+	Template        string
+	TemplateRebuilt bool
+	// Ephemeral is set when Database was created for this run alone, and
+	// EphemeralDropped once it has been dropped again.
+	Ephemeral        bool
+	EphemeralDropped bool
 }
 
 type maintenanceDBConnFunc func(ctx context.Context, connConfig *pgmi.ConnectionConfig, dbName string) (pgmi.DBConnection, func(), error)
@@ -104,6 +120,13 @@ func (s *DeploymentService) defaultMgmtConnector(ctx context.Context, connConfig
 		closeConnector(connector)
 		return nil, nil, fmt.Errorf("failed to connect to maintenance database: %w", err)
 	}
+	// CREATE and DROP DATABASE need a writable server as much as the deploy
+	// does; say so before either fails on a standby.
+	if err := checkWritable(ctx, pool); err != nil {
+		pool.Close()
+		closeConnector(connector)
+		return nil, nil, err
+	}
 
 	dbConn := db.NewPoolAdapter(pool)
 	cleanup := func() {
@@ -116,11 +139,24 @@ func (s *DeploymentService) defaultMgmtConnector(ctx context.Context, connConfig
 // Deploy executes a deployment using the provided configuration.
 // This method orchestrates the deployment workflow by calling smaller, focused methods.
 // After Deploy returns, call LastResult() for deployment statistics.
-func (s *DeploymentService) Deploy(ctx context.Context, config pgmi.DeploymentConfig) error {
+func (s *DeploymentService) Deploy(ctx context.Context, config pgmi.DeploymentConfig) (err error) {
 	start := time.Now()
 	s.lastResult = &DeployResult{Database: config.DatabaseName}
 	defer func() { s.lastResult.Duration = time.Since(start) }()
 
+	ctx, span := tracing.Start(ctx, "pgmi.deploy",
+		attribute.String("db.namespace", config.DatabaseName),
+		attribute.String("pgmi.entrypoint", pgmi.NormalizeEntrypoint(config.Entry)),
+	)
+	defer func() {
+		span.SetAttributes(
+			attribute.Int("pgmi.files_loaded", s.lastResult.FilesLoaded),
+			attribute.Int("pgmi.units_committed", s.lastResult.UnitsCommitted),
+			attribute.Int("pgmi.units_skipped", s.lastResult.UnitsSkipped),
+		)
+		tracing.End(span, err)
+	}()
+
 	// Validate and parse configuration
 	connConfig, err := s.validateAndParseConfig(config)
 	if err != nil {
@@ -130,11 +166,20 @@ func (s *DeploymentService) Deploy(ctx context.Context, config pgmi.DeploymentCo
 	// Scan the project before touching the server: a typo'd path, a missing
 	// deploy.sql or an unreadable file must not leave a freshly created
 	// database behind.
-	scanResult, err := s.sessionManager.ScanProject(config.SourcePath)
+	_, scanSpan := tracing.Start(ctx, "pgmi.scan_project")
+	scanResult, err := s.scanProject(config)
+	scanSpan.SetAttributes(attribute.Int("pgmi.files", len(scanResult.Files)))
+	tracing.End(scanSpan, err)
 	if err != nil {
 		return fmt.Errorf("file scanning failed: %w", err)
 	}
 
+	// Typing custom <pgmi-meta> attributes is offline too, and deploy.sql
+	// may branch on them, so a value the schema rejects stops here.
+	if err := metadata.ApplySchema(scanResult.Files, config.MetadataSchema); err != nil {
+		return err
+	}
+
 	// --compat is resolved entirely offline, so rejecting it here keeps
 	// --overwrite from dropping and recreating a database only to fail on a
 	// check that never needed the server.
@@ -142,6 +187,13 @@ func (s *DeploymentService) Deploy(ctx context.Context, config pgmi.DeploymentCo
 		return err
 	}
 
+	if config.TemplateDatabase != "" {
+		return s.deployViaTemplate(ctx, connConfig, config, scanResult)
+	}
+	if config.Ephemeral {
+		return s.deployEphemeral(ctx, connConfig, config, scanResult)
+	}
+
 	// Handle overwrite workflow if requested (drop and recreate database)
 	if config.Overwrite {
 		if err := s.handleOverwrite(ctx, connConfig, config); err != nil {
@@ -154,12 +206,27 @@ func (s *DeploymentService) Deploy(ctx context.Context, config pgmi.DeploymentCo
 		}
 	}
 
+	return s.runSession(ctx, connConfig, config.DatabaseName, scanResult, config)
+}
+
+// runSession prepares a deployment session on dbName and executes the
+// orchestrator script in it.
+func (s *DeploymentService) runSession(
+	ctx context.Context,
+	connConfig *pgmi.ConnectionConfig,
+	dbName string,
+	scanResult pgmi.FileScanResult,
+	config pgmi.DeploymentConfig,
+) error {
 	// Prepare deployment session (scan files, connect to database, load session tables)
 	// SessionManager handles: file scanning, database connection, utility functions, files, params
 	targetConfig := connConfig.DeepCopy()
-	targetConfig.Database = config.DatabaseName
+	targetConfig.Database = dbName
 	s.logger.Info("Preparing session: scanning files, loading parameters")
-	session, err := s.sessionManager.PrepareSession(ctx, &targetConfig, scanResult, config.Parameters, config.Compat, config.Verbose)
+	prepareCtx, prepareSpan := tracing.Start(ctx, "pgmi.prepare_session",
+		attribute.String("db.namespace", dbName))
+	session, err := s.sessionManager.PrepareSession(prepareCtx, &targetConfig, scanResult, config.Parameters, config.Compat, config.Verbose)
+	tracing.End(prepareSpan, err)
 	if err != nil {
 		return err // Error already wrapped by SessionManager
 	}
@@ -167,12 +234,24 @@ func (s *DeploymentService) Deploy(ctx context.Context, config pgmi.DeploymentCo
 
 	s.lastResult.FilesLoaded = session.FilesLoaded
 
-	s.logger.Info("Executing deploy.sql")
-	macroCount, err := s.executeDeploySQL(ctx, session.Conn(), config.SourcePath)
+	s.logger.Info("Executing %s", pgmi.NormalizeEntrypoint(config.Entry))
+	macroCount, err := s.executeDeploySQL(ctx, session.SessionConn(), config)
 	s.lastResult.TestMacros = macroCount
 	return err
 }
 
+// scanProject scans for config.Entry through pgmi.EntrypointPreparer. A
+// SessionPreparer without it serves a project that needs deploy.sql alone.
+func (s *DeploymentService) scanProject(config pgmi.DeploymentConfig) (pgmi.FileScanResult, error) {
+	if ep, ok := s.sessionManager.(pgmi.EntrypointPreparer); ok {
+		return ep.ScanProjectForEntry(config.SourcePath, config.Entry, config.Entrypoints...)
+	}
+	if pgmi.NormalizeEntrypoint(config.Entry) != pgmi.DefaultEntrypoint || len(config.Entrypoints) > 0 {
+		return pgmi.FileScanResult{}, errDeploySQLOnly("session preparer", s.sessionManager)
+	}
+	return s.sessionManager.ScanProject(config.SourcePath)
+}
+
 // validateAndParseConfig validates the configuration and parses the connection string.
 func (s *DeploymentService) validateAndParseConfig(config pgmi.DeploymentConfig) (*pgmi.ConnectionConfig, error) {
 	// Validate configuration
@@ -183,8 +262,9 @@ func (s *DeploymentService) validateAndParseConfig(config pgmi.DeploymentConfig)
 	s.logger.Verbose("Deploying to database %q", config.DatabaseName)
 	s.logger.Verbose("Source path: %s", config.SourcePath)
 
-	// Parse connection string
-	connConfig, err := db.ParseConnectionString(config.ConnectionString)
+	// Parse connection string, filling in what it leaves out from the
+	// pg_service.conf service it or $PGSERVICE names
+	connConfig, err := db.ResolveConnectionString(config.ConnectionString, db.LoadFromEnvironment())
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse connection string: %w", err)
 	}
@@ -194,39 +274,66 @@ func (s *DeploymentService) validateAndParseConfig(config pgmi.DeploymentConfig)
 		connConfig.AppName = "pgmi"
 	}
 
-	// Apply auth method and cloud credentials from deployment config
-	connConfig.AuthMethod = config.AuthMethod
+	// Apply auth method and cloud credentials from deployment config. A
+	// method the connection string selected itself (require_auth=gss, say)
+	// stands unless the config names another.
+	if config.AuthMethod != pgmi.AuthMethodStandard {
+		connConfig.AuthMethod = config.AuthMethod
+	}
 	connConfig.AzureTenantID = config.AzureTenantID
 	connConfig.AzureClientID = config.AzureClientID
 	connConfig.AzureClientSecret = config.AzureClientSecret
+	connConfig.TokenCommand = config.TokenCommand
+	connConfig.TokenFile = config.TokenFile
 
 	return connConfig, nil
 }
 
-// executeDeploySQL reads, preprocesses, and executes the deploy.sql file.
-// Preprocessing expands CALL pgmi_test() macros by querying pgmi_test_plan() from SQL.
+// executeDeploySQL reads, preprocesses, and executes the orchestrator script —
+// deploy.sql, or the one config.Entry names. Its project-relative path is
+// published as pgmi.entrypoint first. Preprocessing expands CALL pgmi_test()
+// macros by querying pgmi_test_plan() from SQL.
 // Returns the number of test macros expanded and any error.
+//
+// Every run of a script with a tail records its committed tail units in
+// TailProgressTable, so a run that fails part-way through the tail can be
+// resumed. With resume set, the units an earlier run of the byte-identical
+// script committed are skipped; without it, the earlier record is discarded
+// once the head has committed. The head always runs: it is one transaction
+// either way, and it is where session state the tail depends on (SET, temp
+// objects) is established.
 func (s *DeploymentService) executeDeploySQL(
 	ctx context.Context,
-	conn *pgxpool.Conn,
-	sourcePath string,
+	conn *pgx.Conn,
+	config pgmi.DeploymentConfig,
 ) (int, error) {
-	s.logger.Verbose("Reading deploy.sql")
+	entry := pgmi.NormalizeEntrypoint(config.Entry)
+	resume := config.Resume
+	s.logger.Verbose("Reading %s", entry)
 
-	deploySQL, err := s.fileScanner.ReadDeploySQL(sourcePath)
+	deploySQL, err := readEntrypoint(s.fileScanner, config.SourcePath, entry)
 	if err != nil {
-		return 0, fmt.Errorf("failed to read deploy.sql: %w", err)
+		return 0, fmt.Errorf("failed to read %s: %w", entry, err)
+	}
+
+	if err := setEntrypoint(ctx, conn, entry); err != nil {
+		return 0, err
+	}
+	if err := setTraceID(ctx, conn); err != nil {
+		return 0, err
 	}
 
 	// Preprocess: expand CALL pgmi_test() macros by querying pgmi_test_plan() from SQL
 	pipeline := preprocessor.NewPipeline()
-	result, err := pipeline.Process(ctx, conn, deploySQL)
+	processCtx, processSpan := tracing.Start(ctx, "pgmi.preprocess")
+	result, err := pipeline.Process(processCtx, conn, deploySQL)
+	tracing.End(processSpan, err)
 	if err != nil {
-		return 0, fmt.Errorf("failed to preprocess deploy.sql: %w", err)
+		return 0, fmt.Errorf("failed to preprocess %s: %w", entry, err)
 	}
 
 	if result.MacroCount > 0 {
-		s.logger.Verbose("Expanded %d test macro(s) in deploy.sql", result.MacroCount)
+		s.logger.Verbose("Expanded %d test macro(s) in %s", result.MacroCount, entry)
 	}
 
 	// Execute deploy.sql as a sequence of simple-query messages on ONE
@@ -245,25 +352,93 @@ func (s *DeploymentService) executeDeploySQL(
 	units := preprocessor.SplitExecutionUnits(result.ExpandedSQL)
 	s.lastResult.ExecutionUnits = len(units)
 	if len(units) > 1 {
-		s.logger.Verbose("deploy.sql splits into %d execution units at the first top-level transaction terminator", len(units))
+		s.logger.Verbose("%s splits into %d execution units at the first top-level transaction terminator", entry, len(units))
+	}
+
+	var recorder *tailRecorder
+	resumeFrom := 0
+	if len(units) > 1 {
+		recorder = &tailRecorder{
+			scriptChecksum: checksum.New().CalculateRaw([]byte(deploySQL)),
+			sums:           unitChecksums(units),
+		}
+	}
+	if resume && recorder != nil {
+		recorded, err := loadTailProgress(ctx, conn)
+		if err != nil {
+			return result.MacroCount, err
+		}
+		resumeFrom, err = planResume(recorder.scriptChecksum, recorder.sums, recorded)
+		if err != nil {
+			return result.MacroCount, err
+		}
+		if resumeFrom > 0 {
+			s.lastResult.UnitsSkipped = resumeFrom - 1
+			s.logger.Info("Resuming: skipping %d tail unit(s) committed by an earlier run", resumeFrom-1)
+		}
 	}
+	if recorder != nil {
+		if err := recorder.ensureTable(ctx, conn); err != nil {
+			return result.MacroCount, err
+		}
+	}
+	if resume {
+		if err := setResumedUnits(ctx, conn, s.lastResult.UnitsSkipped); err != nil {
+			return result.MacroCount, err
+		}
+	}
+
 	for i, unit := range units {
-		if _, err := conn.Exec(ctx, unit); err != nil {
+		if i > 0 && i < resumeFrom {
+			continue
+		}
+		mode := "atomic"
+		if i > 0 {
+			mode = "psql"
+		}
+		if err := execUnit(ctx, conn, i, mode, unit); err != nil {
 			s.lastResult.UnitsCommitted = i
-			if i == 0 {
-				s.lastResult.ExecutionMode = "atomic"
-			} else {
-				s.lastResult.ExecutionMode = "psql"
-			}
-			scriptErr := pgmi.NewScriptError(err, "deploy.sql", unit, result.MacroCount > 0)
+			s.lastResult.ExecutionMode = mode
+			scriptErr := pgmi.NewScriptError(err, entry, unit, result.MacroCount > 0)
 			return result.MacroCount, fmt.Errorf("%w: %w", pgmi.ErrExecutionFailed, scriptErr)
 		}
+		switch {
+		case recorder == nil:
+		case i == 0 && !resume:
+			// A run that starts over makes what an earlier one recorded
+			// stale. Cleared only now, so a run that fails in the head
+			// leaves it for a --resume.
+			if err := recorder.clear(ctx, conn); err != nil {
+				s.lastResult.UnitsCommitted = 1
+				return result.MacroCount, err
+			}
+		case i > 0:
+			if err := recorder.record(ctx, conn, i); err != nil {
+				s.lastResult.UnitsCommitted = i + 1
+				return result.MacroCount, err
+			}
+		}
 	}
 	s.lastResult.UnitsCommitted = len(units)
 
+	if recorder != nil {
+		if err := recorder.clear(ctx, conn); err != nil {
+			return result.MacroCount, err
+		}
+	}
+
 	return result.MacroCount, nil
 }
 
+// setEntrypoint publishes the orchestrator script being executed as
+// pgmi.entrypoint, in the './'-prefixed form _pgmi_source paths use.
+func setEntrypoint(ctx context.Context, conn *pgx.Conn, entry string) error {
+	if _, err := conn.Exec(ctx, `SELECT set_config($1, $2, false)`, pgmi.EntrypointSetting, "./"+entry); err != nil {
+		return fmt.Errorf("failed to set %s: %w", pgmi.EntrypointSetting, err)
+	}
+	return nil
+}
+
 func validateOverwriteTarget(targetDB, maintenanceDB string) error {
 	if strings.EqualFold(targetDB, maintenanceDB) {
 		return fmt.Errorf("cannot overwrite maintenance database %q\npgmi connects to it for CREATE/DROP DATABASE; pick a different target with -d: %w", targetDB, pgmi.ErrInvalidConfig)
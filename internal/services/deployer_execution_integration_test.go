@@ -22,11 +22,12 @@ func TestExecuteDeploySQL_DirectExecution(t *testing.T) {
 	pool := testhelpers.GetTestPool(t, connString, testDB)
 	defer pool.Close()
 
-	conn, err := pool.Acquire(ctx)
+	pooled, err := pool.Acquire(ctx)
 	if err != nil {
 		t.Fatalf("Failed to acquire connection: %v", err)
 	}
-	defer conn.Release()
+	defer pooled.Release()
+	conn := pooled.Conn()
 
 	if err := params.CreateSchema(ctx, conn); err != nil {
 		t.Fatalf("Failed to create schema: %v", err)
@@ -41,10 +42,10 @@ func TestExecuteDeploySQL_DirectExecution(t *testing.T) {
 			ChecksumRaw: "b000000000000000000000000000000000000000000000000000000000000001",
 		},
 	}
-	if err := l.LoadFilesIntoSession(ctx, conn, files); err != nil {
+	if err := l.LoadFilesIntoConn(ctx, conn, files); err != nil {
 		t.Fatalf("Failed to load files: %v", err)
 	}
-	if err := l.LoadParametersIntoSession(ctx, conn, map[string]string{}); err != nil {
+	if err := l.LoadParametersIntoConn(ctx, conn, map[string]string{}); err != nil {
 		t.Fatalf("Failed to load params: %v", err)
 	}
 	if _, err := contract.Apply(ctx, conn, ""); err != nil {
@@ -97,11 +98,12 @@ func TestExecuteDeploySQL_SyntaxError(t *testing.T) {
 	pool := testhelpers.GetTestPool(t, connString, testDB)
 	defer pool.Close()
 
-	conn, err := pool.Acquire(ctx)
+	pooled, err := pool.Acquire(ctx)
 	if err != nil {
 		t.Fatalf("Failed to acquire connection: %v", err)
 	}
-	defer conn.Release()
+	defer pooled.Release()
+	conn := pooled.Conn()
 
 	if err := params.CreateSchema(ctx, conn); err != nil {
 		t.Fatalf("Failed to create schema: %v", err)
@@ -124,21 +126,22 @@ func TestExecuteDeploySQL_MultipleStatements(t *testing.T) {
 	pool := testhelpers.GetTestPool(t, connString, testDB)
 	defer pool.Close()
 
-	conn, err := pool.Acquire(ctx)
+	pooled, err := pool.Acquire(ctx)
 	if err != nil {
 		t.Fatalf("Failed to acquire connection: %v", err)
 	}
-	defer conn.Release()
+	defer pooled.Release()
+	conn := pooled.Conn()
 
 	if err := params.CreateSchema(ctx, conn); err != nil {
 		t.Fatalf("Failed to create schema: %v", err)
 	}
 
 	l := loader.NewLoader()
-	if err := l.LoadFilesIntoSession(ctx, conn, nil); err != nil {
+	if err := l.LoadFilesIntoConn(ctx, conn, nil); err != nil {
 		t.Fatalf("Failed to load files: %v", err)
 	}
-	if err := l.LoadParametersIntoSession(ctx, conn, map[string]string{}); err != nil {
+	if err := l.LoadParametersIntoConn(ctx, conn, map[string]string{}); err != nil {
 		t.Fatalf("Failed to load params: %v", err)
 	}
 	if _, err := contract.Apply(ctx, conn, ""); err != nil {
@@ -183,21 +186,22 @@ func TestExecuteDeploySQL_ContextCancellation(t *testing.T) {
 
 	ctx, cancel := context.WithCancel(context.Background())
 
-	conn, err := pool.Acquire(ctx)
+	pooled, err := pool.Acquire(ctx)
 	if err != nil {
 		t.Fatalf("Failed to acquire connection: %v", err)
 	}
-	defer conn.Release()
+	defer pooled.Release()
+	conn := pooled.Conn()
 
 	if err := params.CreateSchema(ctx, conn); err != nil {
 		t.Fatalf("Failed to create schema: %v", err)
 	}
 
 	l := loader.NewLoader()
-	if err := l.LoadFilesIntoSession(ctx, conn, nil); err != nil {
+	if err := l.LoadFilesIntoConn(ctx, conn, nil); err != nil {
 		t.Fatalf("Failed to load files: %v", err)
 	}
-	if err := l.LoadParametersIntoSession(ctx, conn, map[string]string{}); err != nil {
+	if err := l.LoadParametersIntoConn(ctx, conn, map[string]string{}); err != nil {
 		t.Fatalf("Failed to load params: %v", err)
 	}
 	if _, err := contract.Apply(ctx, conn, ""); err != nil {
@@ -97,16 +97,16 @@ func connectToTestDB(t *testing.T, connString, dbName string) *pgxpool.Pool {
 	return pool
 }
 
-func prepareSessionTables(t *testing.T, ctx context.Context, conn *pgxpool.Conn) {
+func prepareSessionTables(t *testing.T, ctx context.Context, conn *pgx.Conn) {
 	t.Helper()
 	if err := params.CreateSchema(ctx, conn); err != nil {
 		t.Fatalf("Failed to create schema: %v", err)
 	}
 	l := loader.NewLoader()
-	if err := l.LoadFilesIntoSession(ctx, conn, nil); err != nil {
+	if err := l.LoadFilesIntoConn(ctx, conn, nil); err != nil {
 		t.Fatalf("Failed to load files: %v", err)
 	}
-	if err := l.LoadParametersIntoSession(ctx, conn, map[string]string{}); err != nil {
+	if err := l.LoadParametersIntoConn(ctx, conn, map[string]string{}); err != nil {
 		t.Fatalf("Failed to load params: %v", err)
 	}
 }
@@ -137,11 +137,12 @@ func TestExecuteDeploySQL_DirectExecution_Internal(t *testing.T) {
 	defer pool.Close()
 
 	ctx := context.Background()
-	conn, err := pool.Acquire(ctx)
+	pooled, err := pool.Acquire(ctx)
 	if err != nil {
 		t.Fatalf("Failed to acquire connection: %v", err)
 	}
-	defer conn.Release()
+	defer pooled.Release()
+	conn := pooled.Conn()
 
 	prepareSessionTables(t, ctx, conn)
 
@@ -151,7 +152,7 @@ func TestExecuteDeploySQL_DirectExecution_Internal(t *testing.T) {
 	`
 	svc := newServiceWithReadContent(deploySQL)
 
-	if _, err := svc.executeDeploySQL(ctx, conn, "/fake/path"); err != nil {
+	if _, err := svc.executeDeploySQL(ctx, conn, pgmi.DeploymentConfig{SourcePath: "/fake/path"}); err != nil {
 		t.Fatalf("executeDeploySQL failed: %v", err)
 	}
 
@@ -174,17 +175,18 @@ func TestExecuteDeploySQL_SyntaxError_Internal(t *testing.T) {
 	defer pool.Close()
 
 	ctx := context.Background()
-	conn, err := pool.Acquire(ctx)
+	pooled, err := pool.Acquire(ctx)
 	if err != nil {
 		t.Fatalf("Failed to acquire connection: %v", err)
 	}
-	defer conn.Release()
+	defer pooled.Release()
+	conn := pooled.Conn()
 
 	prepareSessionTables(t, ctx, conn)
 
 	svc := newServiceWithReadContent("SELCT INVALID SYNTAX;")
 
-	_, err = svc.executeDeploySQL(ctx, conn, "/fake/path")
+	_, err = svc.executeDeploySQL(ctx, conn, pgmi.DeploymentConfig{SourcePath: "/fake/path"})
 	if err == nil {
 		t.Fatal("Expected error for invalid SQL")
 	}
@@ -204,7 +206,7 @@ func TestExecuteDeploySQL_ReadError_Internal(t *testing.T) {
 		&mockDatabaseManager{},
 	)
 
-	_, err := svc.executeDeploySQL(context.Background(), nil, "/nonexistent")
+	_, err := svc.executeDeploySQL(context.Background(), nil, pgmi.DeploymentConfig{SourcePath: "/nonexistent"})
 	if err == nil {
 		t.Fatal("Expected error for missing deploy.sql")
 	}
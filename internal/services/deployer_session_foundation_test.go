@@ -67,11 +67,12 @@ func TestSessionPreparation_Foundation(t *testing.T) {
 	pool := testhelpers.GetTestPool(t, connString, testDB)
 
 	// Acquire connection for session-scoped operations
-	conn, err := pool.Acquire(ctx)
+	pooled, err := pool.Acquire(ctx)
 	if err != nil {
 		t.Fatalf("Failed to acquire connection: %v", err)
 	}
-	defer conn.Release()
+	defer pooled.Release()
+	conn := pooled.Conn()
 
 	// Prepare session (this is what we're testing)
 	// This involves:
@@ -86,7 +87,7 @@ func TestSessionPreparation_Foundation(t *testing.T) {
 
 	t.Log("Loading files into pg_temp._pgmi_source...")
 	fileLoader := loader.NewLoader()
-	if err := fileLoader.LoadFilesIntoSession(ctx, conn, scanResult.Files); err != nil {
+	if err := fileLoader.LoadFilesIntoConn(ctx, conn, scanResult.Files); err != nil {
 		t.Fatalf("Failed to load files: %v", err)
 	}
 
@@ -95,7 +96,7 @@ func TestSessionPreparation_Foundation(t *testing.T) {
 		"env":     "test",
 		"version": "1.0.0",
 	}
-	if err := fileLoader.LoadParametersIntoSession(ctx, conn, testParams); err != nil {
+	if err := fileLoader.LoadParametersIntoConn(ctx, conn, testParams); err != nil {
 		t.Fatalf("Failed to load parameters: %v", err)
 	}
 
@@ -141,11 +142,12 @@ func TestSessionPreparation_EmptyProject(t *testing.T) {
 
 	pool := testhelpers.GetTestPool(t, connString, testDB)
 
-	conn, err := pool.Acquire(ctx)
+	pooled, err := pool.Acquire(ctx)
 	if err != nil {
 		t.Fatalf("Failed to acquire connection: %v", err)
 	}
-	defer conn.Release()
+	defer pooled.Release()
+	conn := pooled.Conn()
 
 	// Prepare session with empty project
 	if err := params.CreateSchema(ctx, conn); err != nil {
@@ -153,11 +155,11 @@ func TestSessionPreparation_EmptyProject(t *testing.T) {
 	}
 
 	fileLoader := loader.NewLoader()
-	if err := fileLoader.LoadFilesIntoSession(ctx, conn, scanResult.Files); err != nil {
+	if err := fileLoader.LoadFilesIntoConn(ctx, conn, scanResult.Files); err != nil {
 		t.Fatalf("Failed to load files: %v", err)
 	}
 
-	if err := fileLoader.LoadParametersIntoSession(ctx, conn, map[string]string{}); err != nil {
+	if err := fileLoader.LoadParametersIntoConn(ctx, conn, map[string]string{}); err != nil {
 		t.Fatalf("Failed to load parameters: %v", err)
 	}
 
@@ -209,11 +211,12 @@ func TestSessionPreparation_OnlyMigrations(t *testing.T) {
 
 	pool := testhelpers.GetTestPool(t, connString, testDB)
 
-	conn, err := pool.Acquire(ctx)
+	pooled, err := pool.Acquire(ctx)
 	if err != nil {
 		t.Fatalf("Failed to acquire connection: %v", err)
 	}
-	defer conn.Release()
+	defer pooled.Release()
+	conn := pooled.Conn()
 
 	// Prepare session
 	if err := params.CreateSchema(ctx, conn); err != nil {
@@ -221,11 +224,11 @@ func TestSessionPreparation_OnlyMigrations(t *testing.T) {
 	}
 
 	fileLoader := loader.NewLoader()
-	if err := fileLoader.LoadFilesIntoSession(ctx, conn, scanResult.Files); err != nil {
+	if err := fileLoader.LoadFilesIntoConn(ctx, conn, scanResult.Files); err != nil {
 		t.Fatalf("Failed to load files: %v", err)
 	}
 
-	if err := fileLoader.LoadParametersIntoSession(ctx, conn, map[string]string{}); err != nil {
+	if err := fileLoader.LoadParametersIntoConn(ctx, conn, map[string]string{}); err != nil {
 		t.Fatalf("Failed to load parameters: %v", err)
 	}
 
@@ -183,6 +183,52 @@ func TestDeploy_MissingDeploySQL_FailsBeforeConnecting(t *testing.T) {
 	}
 }
 
+func TestDeploy_PassesEntrypointsToScan(t *testing.T) {
+	cf, ap, lg, _, fs, dm := validDeps()
+	sm := &mockSessionPreparer{scanErr: errMockStop}
+	svc := NewDeploymentService(cf, ap, lg, sm, fs, dm)
+
+	cfg := validConfig()
+	cfg.Entry = "ops/reindex.sql"
+	cfg.Entrypoints = []string{"ops/reindex.sql", "ops/backfill.sql"}
+
+	if err := svc.Deploy(context.Background(), cfg); !errors.Is(err, errMockStop) {
+		t.Fatalf("Expected errMockStop, got: %v", err)
+	}
+	if sm.scannedEntry != "ops/reindex.sql" {
+		t.Errorf("scanned entry %q, want ops/reindex.sql", sm.scannedEntry)
+	}
+	if len(sm.scannedEntrypoints) != 2 {
+		t.Errorf("scanned entrypoints %v, want both declared ones", sm.scannedEntrypoints)
+	}
+}
+
+func TestDeploy_CustomAttributeSchemaFailsBeforeConnecting(t *testing.T) {
+	cf, ap, lg, _, fs, dm := validDeps()
+	sm := &mockSessionPreparer{files: []pgmi.FileMetadata{{
+		Path:     "./a.sql",
+		Metadata: &pgmi.ScriptMetadata{Custom: map[string]map[string]any{"ops": {"window": "maybe"}}},
+	}}}
+	svc := NewDeploymentService(cf, ap, lg, sm, fs, dm)
+
+	connected := false
+	svc.mgmtConnector = func(_ context.Context, _ *pgmi.ConnectionConfig, _ string) (pgmi.DBConnection, func(), error) {
+		connected = true
+		return &mockDBConnection{}, noop, nil
+	}
+
+	cfg := validConfig()
+	cfg.MetadataSchema = pgmi.MetadataSchema{"ops:window": {Type: pgmi.CustomTypeBoolean}}
+
+	err := svc.Deploy(context.Background(), cfg)
+	if !errors.Is(err, pgmi.ErrInvalidConfig) {
+		t.Fatalf("Expected ErrInvalidConfig, got: %v", err)
+	}
+	if connected {
+		t.Error("Deploy connected to the server before checking custom attributes")
+	}
+}
+
 // --- Overwrite workflow tests ---
 
 func TestDeploy_OverwriteDBNotExists_Creates(t *testing.T) {
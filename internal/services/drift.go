@@ -0,0 +1,191 @@
+package services
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/vvka-141/pgmi/internal/contract"
+	"github.com/vvka-141/pgmi/pkg/pgmi"
+)
+
+// FingerprintTable is where pg_temp.pgmi_capture_fingerprint() records the
+// catalog at the end of a deploy. Like TailProgressTable it is a real table in
+// the pgmi schema, and only deploys whose deploy.sql asks for it create it.
+const FingerprintTable = "pgmi.catalog_fingerprint"
+
+// DriftChange says how an object differs from the recorded fingerprint.
+type DriftChange string
+
+const (
+	DriftAdded   DriftChange = "added"   // exists now, was not recorded
+	DriftRemoved DriftChange = "removed" // recorded, no longer exists
+	DriftChanged DriftChange = "changed" // both, with different definitions
+)
+
+// DriftEntry is one object that is not as the last deploy left it.
+type DriftEntry struct {
+	Change     DriftChange `json:"change"`
+	ObjectType string      `json:"objectType"`
+	Name       string      `json:"name"`
+	// Recorded and Live are the definitions pgmi_catalog_fingerprint() gave
+	// at the deploy and now; empty on the side where the object is absent.
+	Recorded string `json:"recorded,omitempty"`
+	Live     string `json:"live,omitempty"`
+}
+
+// DriftResult compares a database with the fingerprint its last deploy
+// captured.
+type DriftResult struct {
+	CapturedAt time.Time    `json:"capturedAt"`
+	Recorded   int          `json:"recorded"` // objects in the fingerprint
+	Entries    []DriftEntry `json:"entries"`
+}
+
+// Drifted reports whether anything changed since the fingerprint.
+func (r *DriftResult) Drifted() bool { return len(r.Entries) > 0 }
+
+// Count returns the number of entries of one kind.
+func (r *DriftResult) Count(change DriftChange) int {
+	n := 0
+	for _, e := range r.Entries {
+		if e.Change == change {
+			n++
+		}
+	}
+	return n
+}
+
+// TxBeginner opens a transaction. *pgxpool.Pool and *pgx.Conn satisfy it.
+type TxBeginner interface {
+	BeginTx(ctx context.Context, txOptions pgx.TxOptions) (pgx.Tx, error)
+}
+
+// querier runs a query returning rows, as pgx.Tx does.
+type querier interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+}
+
+// objectKey identifies a catalog object across two fingerprints.
+type objectKey struct {
+	objectType, name string
+}
+
+// DetectDrift compares the live catalog with FingerprintTable. It only reads,
+// in a read-only transaction: the live side is contract.FingerprintQuery run
+// as a plain query, so a hot standby can be checked as well as the primary.
+func DetectDrift(ctx context.Context, db TxBeginner) (*DriftResult, error) {
+	q, err := db.BeginTx(ctx, pgx.TxOptions{AccessMode: pgx.ReadOnly})
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = q.Rollback(context.WithoutCancel(ctx)) }()
+	for _, s := range contract.FingerprintSettings {
+		if _, err := q.Exec(ctx, "SELECT set_config($1, $2, true)", s[0], s[1]); err != nil {
+			return nil, fmt.Errorf("failed to set %s: %w", s[0], err)
+		}
+	}
+
+	exists, err := fingerprintTableExists(ctx, q)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up %s: %w", FingerprintTable, err)
+	}
+	var recorded map[objectKey]string
+	var capturedAt time.Time
+	if exists {
+		recorded, capturedAt, err = readFingerprint(ctx, q, `
+			SELECT object_type, object_name, definition, captured_at FROM `+FingerprintTable)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", FingerprintTable, err)
+		}
+	}
+	if len(recorded) == 0 {
+		return nil, fmt.Errorf("%w: no fingerprint recorded in this database\n"+
+			"Call SELECT pg_temp.pgmi_capture_fingerprint(); at the end of deploy.sql (session API v2), "+
+			"deploy, and run pgmi drift again", pgmi.ErrInvalidConfig)
+	}
+
+	live, _, err := readFingerprint(ctx, q, `
+		SELECT object_type, object_name, definition, NULL::timestamptz
+		FROM (`+contract.FingerprintQuery()+`) fingerprint`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the live catalog: %w", err)
+	}
+
+	return &DriftResult{
+		CapturedAt: capturedAt,
+		Recorded:   len(recorded),
+		Entries:    compareFingerprints(recorded, live),
+	}, nil
+}
+
+// readFingerprint loads (object_type, object_name, definition, captured_at)
+// rows, returning the latest captured_at.
+func readFingerprint(ctx context.Context, q querier, sql string) (map[objectKey]string, time.Time, error) {
+	rows, err := q.Query(ctx, sql)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	defer rows.Close()
+
+	out := make(map[objectKey]string)
+	var latest time.Time
+	for rows.Next() {
+		var k objectKey
+		var definition string
+		var capturedAt *time.Time
+		if err := rows.Scan(&k.objectType, &k.name, &definition, &capturedAt); err != nil {
+			return nil, time.Time{}, err
+		}
+		out[k] = definition
+		if capturedAt != nil && capturedAt.After(latest) {
+			latest = *capturedAt
+		}
+	}
+	return out, latest, rows.Err()
+}
+
+func fingerprintTableExists(ctx context.Context, q querier) (bool, error) {
+	rows, err := q.Query(ctx, `SELECT to_regclass($1) IS NOT NULL`, FingerprintTable)
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+	var exists bool
+	if rows.Next() {
+		if err := rows.Scan(&exists); err != nil {
+			return false, err
+		}
+	}
+	return exists, rows.Err()
+}
+
+// compareFingerprints lists every object added, removed or changed between
+// two fingerprints, by name so a table sits beside its columns.
+func compareFingerprints(recorded, live map[objectKey]string) []DriftEntry {
+	var entries []DriftEntry
+	for k, was := range recorded {
+		now, ok := live[k]
+		switch {
+		case !ok:
+			entries = append(entries, DriftEntry{DriftRemoved, k.objectType, k.name, was, ""})
+		case now != was:
+			entries = append(entries, DriftEntry{DriftChanged, k.objectType, k.name, was, now})
+		}
+	}
+	for k, now := range live {
+		if _, ok := recorded[k]; !ok {
+			entries = append(entries, DriftEntry{DriftAdded, k.objectType, k.name, "", now})
+		}
+	}
+	slices.SortFunc(entries, func(a, b DriftEntry) int {
+		if n := cmp.Compare(a.Name, b.Name); n != 0 {
+			return n
+		}
+		return cmp.Compare(a.ObjectType, b.ObjectType)
+	})
+	return entries
+}
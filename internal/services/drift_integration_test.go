@@ -0,0 +1,107 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/vvka-141/pgmi/internal/services"
+	testhelpers "github.com/vvka-141/pgmi/internal/testing"
+	"github.com/vvka-141/pgmi/pkg/pgmi"
+)
+
+// A deploy that captures a fingerprint reads as no drift until someone changes
+// the database by hand; then each change is reported once, as what it is.
+func TestDetectDrift_AfterDeploy(t *testing.T) {
+	connString := testhelpers.RequireDatabase(t)
+	ctx := context.Background()
+	const dbName = "pgmi_itest_drift"
+	testhelpers.CleanupTestDB(t, connString, dbName)
+	defer testhelpers.CleanupTestDB(t, connString, dbName)
+
+	// search_path is changed before the capture: what is recorded must not
+	// depend on it, or every later check would report drift.
+	projectPath := t.TempDir()
+	deploySQL := `
+CREATE TABLE orders (id int PRIMARY KEY, total numeric NOT NULL DEFAULT 0);
+CREATE TYPE order_state AS ENUM ('open', 'paid');
+CREATE FUNCTION order_total(p_id int) RETURNS numeric
+    LANGUAGE sql STABLE AS $$ SELECT total FROM orders WHERE id = p_id $$;
+ALTER TABLE orders ENABLE ROW LEVEL SECURITY;
+CREATE POLICY orders_all ON orders USING (true);
+SET search_path = pg_catalog;
+SELECT pg_temp.pgmi_capture_fingerprint();
+`
+	if err := os.WriteFile(filepath.Join(projectPath, "deploy.sql"), []byte(deploySQL), 0o644); err != nil {
+		t.Fatalf("write deploy.sql: %v", err)
+	}
+	err := testhelpers.NewTestDeployer(t).Deploy(ctx, pgmi.DeploymentConfig{
+		ConnectionString:    connString,
+		MaintenanceDatabase: "postgres",
+		DatabaseName:        dbName,
+		SourcePath:          projectPath,
+		Verbose:             testing.Verbose(),
+	})
+	if err != nil {
+		t.Fatalf("deploy: %v", err)
+	}
+
+	pool := testhelpers.GetTestPool(t, connString, dbName)
+	result, err := services.DetectDrift(ctx, pool)
+	if err != nil {
+		t.Fatalf("DetectDrift: %v", err)
+	}
+	if result.Drifted() || result.Recorded == 0 || result.CapturedAt.IsZero() {
+		t.Fatalf("fresh deploy: recorded %d at %v, drift %v", result.Recorded, result.CapturedAt, result.Entries)
+	}
+
+	if _, err := pool.Exec(ctx, `
+		ALTER TABLE orders ADD COLUMN note text;
+		CREATE INDEX orders_total_idx ON orders (total);
+		DROP FUNCTION order_total(int);
+		DROP POLICY orders_all ON orders;
+		GRANT SELECT ON orders TO PUBLIC;`); err != nil {
+		t.Fatalf("hand edits: %v", err)
+	}
+	// On its own: before PostgreSQL 12, ADD VALUE refuses a transaction block.
+	if _, err := pool.Exec(ctx, `ALTER TYPE order_state ADD VALUE 'refunded'`); err != nil {
+		t.Fatalf("hand edits: %v", err)
+	}
+
+	result, err = services.DetectDrift(ctx, pool)
+	if err != nil {
+		t.Fatalf("DetectDrift: %v", err)
+	}
+	var got []string
+	for _, e := range result.Entries {
+		got = append(got, string(e.Change)+" "+e.ObjectType+" "+e.Name)
+	}
+	want := []string{
+		"removed function public.order_total(p_id integer)",
+		"changed type public.order_state",
+		"changed relation public.orders",
+		"removed policy public.orders.orders_all",
+		"added column public.orders.note",
+		"added index public.orders_total_idx",
+	}
+	slices.Sort(got)
+	slices.Sort(want)
+	if !slices.Equal(got, want) {
+		t.Errorf("drift after hand edits:\n  got  %v\n  want %v", got, want)
+	}
+}
+
+func TestDetectDrift_NothingCaptured(t *testing.T) {
+	connString := testhelpers.RequireDatabase(t)
+	const dbName = "pgmi_itest_drift_none"
+	testhelpers.CleanupTestDB(t, connString, dbName)
+	defer testhelpers.CreateTestDB(t, connString, dbName)()
+
+	_, err := services.DetectDrift(context.Background(), testhelpers.GetTestPool(t, connString, dbName))
+	if !errors.Is(err, pgmi.ErrInvalidConfig) {
+		t.Errorf("expected ErrInvalidConfig naming pgmi_capture_fingerprint, got %v", err)
+	}
+}
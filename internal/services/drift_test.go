@@ -0,0 +1,55 @@
+package services
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestCompareFingerprints(t *testing.T) {
+	recorded := map[objectKey]string{
+		{"relation", "public.orders"}:               "kind=r owner=app acl=",
+		{"column", "public.orders.id"}:              "type=integer",
+		{"column", "public.orders.total"}:           "type=numeric",
+		{"function", "public.order_total(integer)"}: "body 1",
+	}
+	live := map[objectKey]string{
+		{"relation", "public.orders"}:     "kind=r owner=app acl=reporting=r/app",
+		{"column", "public.orders.id"}:    "type=integer",
+		{"column", "public.orders.note"}:  "type=text",
+		{"column", "public.orders.total"}: "type=numeric",
+	}
+
+	got := compareFingerprints(recorded, live)
+	var summary []string
+	for _, e := range got {
+		summary = append(summary, string(e.Change)+" "+e.ObjectType+" "+e.Name)
+	}
+	want := []string{
+		"removed function public.order_total(integer)",
+		"changed relation public.orders",
+		"added column public.orders.note",
+	}
+	if !slices.Equal(summary, want) {
+		t.Errorf("compareFingerprints =\n  %v\nwant\n  %v", summary, want)
+	}
+	if got[1].Recorded != "kind=r owner=app acl=" || got[1].Live != "kind=r owner=app acl=reporting=r/app" {
+		t.Errorf("changed entry carries %q → %q", got[1].Recorded, got[1].Live)
+	}
+	if got[0].Live != "" || got[2].Recorded != "" {
+		t.Error("the absent side of an added or removed object must be empty")
+	}
+
+	if entries := compareFingerprints(recorded, recorded); len(entries) != 0 {
+		t.Errorf("identical fingerprints differ: %v", entries)
+	}
+}
+
+func TestDriftResult_Count(t *testing.T) {
+	r := &DriftResult{Entries: []DriftEntry{{Change: DriftAdded}, {Change: DriftAdded}, {Change: DriftChanged}}}
+	if !r.Drifted() || r.Count(DriftAdded) != 2 || r.Count(DriftChanged) != 1 || r.Count(DriftRemoved) != 0 {
+		t.Errorf("Drifted/Count wrong for %v", r.Entries)
+	}
+	if (&DriftResult{}).Drifted() {
+		t.Error("an empty result is not drift")
+	}
+}
@@ -0,0 +1,52 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/vvka-141/pgmi/pkg/pgmi"
+)
+
+// An --entry script runs in place of deploy.sql and sees its own path in
+// pgmi.entrypoint; a failure in it is reported against that path.
+func TestExecuteDeploySQL_EntrypointSetting(t *testing.T) {
+	connString := requireTestDB(t)
+	testDB := "pgmi_itest_entrypoint"
+	cleanup := createTestDB(t, connString, testDB)
+	defer cleanup()
+
+	pool := connectToTestDB(t, connString, testDB)
+	defer pool.Close()
+
+	ctx := context.Background()
+	pooled, err := pool.Acquire(ctx)
+	if err != nil {
+		t.Fatalf("Failed to acquire connection: %v", err)
+	}
+	defer pooled.Release()
+	conn := pooled.Conn()
+
+	prepareSessionTables(t, ctx, conn)
+
+	svc := newServiceWithReadContent(`CREATE TABLE entry_seen AS SELECT current_setting('pgmi.entrypoint') AS entry;`)
+	cfg := pgmi.DeploymentConfig{SourcePath: "/fake/path", Entry: "ops/reindex.sql"}
+	if _, err := svc.executeDeploySQL(ctx, conn, cfg); err != nil {
+		t.Fatalf("executeDeploySQL: %v", err)
+	}
+
+	var entry string
+	if err := conn.QueryRow(ctx, `SELECT entry FROM entry_seen`).Scan(&entry); err != nil {
+		t.Fatalf("read entry_seen: %v", err)
+	}
+	if entry != "./ops/reindex.sql" {
+		t.Errorf("pgmi.entrypoint = %q, want ./ops/reindex.sql", entry)
+	}
+
+	svc = newServiceWithReadContent(`SELECT 1/0;`)
+	_, err = svc.executeDeploySQL(ctx, conn, cfg)
+	var scriptErr *pgmi.ScriptError
+	if !errors.As(err, &scriptErr) || scriptErr.Name != "ops/reindex.sql" {
+		t.Fatalf("expected a ScriptError naming ops/reindex.sql, got %v", err)
+	}
+}
@@ -0,0 +1,208 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/vvka-141/pgmi/internal/db/manager"
+	"github.com/vvka-141/pgmi/pkg/pgmi"
+)
+
+// EphemeralCommentPrefix starts the pg_database comment of every database an
+// ephemeral deploy creates. `pgmi gc` drops only databases carrying it, so a
+// database pgmi did not create for itself can never be collected.
+const EphemeralCommentPrefix = "pgmi ephemeral "
+
+// ephemeralCleanupTimeout bounds the drop at the end of an ephemeral deploy.
+// It runs on a fresh context: the deploy's own may already be cancelled by
+// Ctrl-C or expired by --timeout, which is exactly when cleanup matters most.
+const ephemeralCleanupTimeout = 30 * time.Second
+
+// maxIdentifierLength is PostgreSQL's NAMEDATALEN-1. A longer CREATE DATABASE
+// name is silently truncated by the server, which would make the name pgmi
+// drops differ from the one it created.
+const maxIdentifierLength = 63
+
+// ephemeralDatabaseName appends a timestamp and a random tag to prefix, cutting
+// the prefix short where the result would exceed PostgreSQL's identifier limit.
+func ephemeralDatabaseName(prefix string, now time.Time) (string, error) {
+	tag := make([]byte, 4)
+	if _, err := rand.Read(tag); err != nil {
+		return "", fmt.Errorf("failed to generate an ephemeral database name: %w", err)
+	}
+	suffix := "_" + now.UTC().Format("20060102150405") + "_" + hex.EncodeToString(tag)
+
+	limit := maxIdentifierLength - len(suffix)
+	for len(prefix) > limit {
+		_, size := utf8.DecodeLastRuneInString(prefix)
+		prefix = prefix[:len(prefix)-size]
+	}
+	return prefix + suffix, nil
+}
+
+// ephemeralComment labels an ephemeral database with when and where it was
+// created, so `pgmi gc` can tell a leftover from a deploy still running.
+func ephemeralComment(now time.Time) string {
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		host = "unknown"
+	}
+	return fmt.Sprintf("%screated=%s host=%s pid=%d",
+		EphemeralCommentPrefix, now.UTC().Format(time.RFC3339), strings.ReplaceAll(host, " ", "_"), os.Getpid())
+}
+
+// ephemeralCreatedAt reads the creation time back out of an ephemeral label.
+func ephemeralCreatedAt(comment string) (time.Time, bool) {
+	rest, ok := strings.CutPrefix(comment, EphemeralCommentPrefix)
+	if !ok {
+		return time.Time{}, false
+	}
+	for _, field := range strings.Fields(rest) {
+		if v, ok := strings.CutPrefix(field, "created="); ok {
+			t, err := time.Parse(time.RFC3339, v)
+			return t, err == nil
+		}
+	}
+	return time.Time{}, false
+}
+
+// deployEphemeral deploys into a freshly created, uniquely named database and
+// drops it afterwards whatever the outcome. A drop failure is reported when
+// the deploy itself succeeded; the label left on the database lets
+// `pgmi gc` remove it later.
+func (s *DeploymentService) deployEphemeral(ctx context.Context, connConfig *pgmi.ConnectionConfig, config pgmi.DeploymentConfig, scanResult pgmi.FileScanResult) (err error) {
+	now := time.Now()
+	name, err := ephemeralDatabaseName(config.DatabaseName, now)
+	if err != nil {
+		return err
+	}
+	s.lastResult.Database = name
+	s.lastResult.Ephemeral = true
+
+	dbConn, cleanup, err := s.connectMaintenance(ctx, connConfig, config.MaintenanceDatabase)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	comment := ephemeralComment(now)
+	if err := s.dbManager.Create(ctx, dbConn, name, &pgmi.DatabaseSettings{ConnectionLimit: -1, Comment: &comment}); err != nil {
+		return classifyCreateFailure(err)
+	}
+	s.logger.Info("Created ephemeral database %q", name)
+
+	defer func() {
+		dropErr := s.dropEphemeral(dbConn, name)
+		if dropErr != nil {
+			s.logger.Error("Ephemeral database %q was not dropped: %v; `pgmi gc` removes it later", name, dropErr)
+			if err == nil {
+				err = dropErr
+			}
+			return
+		}
+		s.lastResult.EphemeralDropped = true
+		s.logger.Info("Dropped ephemeral database %q", name)
+	}()
+
+	return s.runSession(ctx, connConfig, name, scanResult, config)
+}
+
+func (s *DeploymentService) dropEphemeral(dbConn pgmi.DBConnection, name string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), ephemeralCleanupTimeout)
+	defer cancel()
+	if err := s.dbManager.TerminateConnections(ctx, dbConn, name); err != nil {
+		return err
+	}
+	return s.dbManager.Drop(ctx, dbConn, name)
+}
+
+// GCOptions controls which leftover ephemeral databases CollectEphemeral drops.
+type GCOptions struct {
+	// OlderThan spares databases created more recently, so a collection
+	// running alongside CI does not drop a deploy still in progress.
+	OlderThan time.Duration
+
+	// DryRun reports what would be dropped without dropping anything.
+	DryRun bool
+
+	// Now is the reference time for OlderThan; zero means time.Now().
+	Now time.Time
+}
+
+// GCResult lists what a collection found.
+type GCResult struct {
+	Dropped []string // dropped, or with DryRun, would have been
+	Kept    []string // labelled but younger than OlderThan
+}
+
+// commentLister is what CollectEphemeral needs of the pgmi.DatabaseManager
+// beyond that interface. manager.Manager has it.
+type commentLister interface {
+	// ListByComment returns, sorted, the databases whose comment starts with
+	// prefix: the label pgmi puts on databases it creates for itself.
+	ListByComment(ctx context.Context, conn pgmi.DBConnection, prefix string) ([]string, error)
+}
+
+var _ commentLister = (*manager.Manager)(nil)
+
+// CollectEphemeral drops databases left behind by ephemeral deploys that never
+// got to clean up — a killed job, a lost connection. Only databases whose
+// comment carries EphemeralCommentPrefix are considered. A database that fails
+// to drop does not stop the rest; every failure is returned joined.
+func CollectEphemeral(ctx context.Context, conn pgmi.DBConnection, dbManager pgmi.DatabaseManager, logger pgmi.Logger, opts GCOptions) (*GCResult, error) {
+	now := opts.Now
+	if now.IsZero() {
+		now = time.Now()
+	}
+
+	lister, ok := dbManager.(commentLister)
+	if !ok {
+		return nil, fmt.Errorf("%w: database manager %T cannot list databases by comment", pgmi.ErrInvalidConfig, dbManager)
+	}
+	names, err := lister.ListByComment(ctx, conn, EphemeralCommentPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &GCResult{}
+	var errs []error
+	for _, name := range names {
+		settings, err := dbManager.Settings(ctx, conn, name)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if settings == nil || settings.Comment == nil {
+			continue // dropped by someone else since the listing
+		}
+		// A label without a readable time is still pgmi's; treating it as
+		// old is the only way it ever gets collected.
+		if created, ok := ephemeralCreatedAt(*settings.Comment); ok && now.Sub(created) < opts.OlderThan {
+			logger.Verbose("Keeping %q: created %s ago", name, now.Sub(created).Round(time.Second))
+			result.Kept = append(result.Kept, name)
+			continue
+		}
+		if opts.DryRun {
+			result.Dropped = append(result.Dropped, name)
+			continue
+		}
+		if err := dbManager.TerminateConnections(ctx, conn, name); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if err := dbManager.Drop(ctx, conn, name); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		logger.Verbose("Dropped %q (%s)", name, *settings.Comment)
+		result.Dropped = append(result.Dropped, name)
+	}
+	return result, errors.Join(errs...)
+}
@@ -0,0 +1,86 @@
+package services_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/vvka-141/pgmi/internal/db"
+	"github.com/vvka-141/pgmi/internal/db/manager"
+	"github.com/vvka-141/pgmi/internal/logging"
+	"github.com/vvka-141/pgmi/internal/services"
+	testhelpers "github.com/vvka-141/pgmi/internal/testing"
+	"github.com/vvka-141/pgmi/pkg/pgmi"
+)
+
+// An ephemeral deploy leaves nothing behind, whether deploy.sql succeeds or
+// fails, and `pgmi gc` removes a labelled leftover but not an unlabelled
+// database.
+func TestDeploymentService_Deploy_Ephemeral(t *testing.T) {
+	connString := testhelpers.RequireDatabase(t)
+	ctx := context.Background()
+	admin := testhelpers.GetTestPool(t, connString, "postgres")
+
+	exists := func(name string) bool {
+		t.Helper()
+		var found bool
+		if err := admin.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM pg_database WHERE datname = $1)`, name).Scan(&found); err != nil {
+			t.Fatalf("look up %s: %v", name, err)
+		}
+		return found
+	}
+
+	for _, script := range []string{"SELECT 1;", "SELECT 1/0;"} {
+		projectPath := t.TempDir()
+		if err := os.WriteFile(filepath.Join(projectPath, "deploy.sql"), []byte(script), 0o644); err != nil {
+			t.Fatalf("write deploy.sql: %v", err)
+		}
+
+		deployer := testhelpers.NewTestDeployer(t)
+		deployErr := deployer.Deploy(ctx, pgmi.DeploymentConfig{
+			ConnectionString:    connString,
+			MaintenanceDatabase: "postgres",
+			DatabaseName:        "pgmi_itest_ephemeral",
+			SourcePath:          projectPath,
+			Ephemeral:           true,
+			Verbose:             testing.Verbose(),
+		})
+		result := deployer.(*services.DeploymentService).LastResult()
+		if (deployErr != nil) != (script == "SELECT 1/0;") {
+			t.Errorf("%s: deploy error = %v", script, deployErr)
+		}
+		if !result.EphemeralDropped || exists(result.Database) {
+			t.Errorf("%s: ephemeral database %q survived the run", script, result.Database)
+			testhelpers.CleanupTestDB(t, connString, result.Database)
+		}
+	}
+
+	const leftover, unlabelled = "pgmi_itest_ephemeral_leftover", "pgmi_itest_ephemeral_unlabelled"
+	for _, name := range []string{leftover, unlabelled} {
+		testhelpers.CleanupTestDB(t, connString, name)
+		defer testhelpers.CleanupTestDB(t, connString, name)
+		if _, err := admin.Exec(ctx, fmt.Sprintf("CREATE DATABASE %s", pgx.Identifier{name}.Sanitize())); err != nil {
+			t.Fatalf("create %s: %v", name, err)
+		}
+	}
+	label := fmt.Sprintf("%screated=%s host=ci pid=1", services.EphemeralCommentPrefix, time.Now().Add(-2*time.Hour).UTC().Format(time.RFC3339))
+	if _, err := admin.Exec(ctx, fmt.Sprintf("COMMENT ON DATABASE %s IS '%s'", leftover, label)); err != nil {
+		t.Fatalf("label leftover: %v", err)
+	}
+
+	res, err := services.CollectEphemeral(ctx, db.NewPoolAdapter(admin), manager.New(), logging.NewNullLogger(),
+		services.GCOptions{OlderThan: time.Hour})
+	if err != nil {
+		t.Fatalf("CollectEphemeral: %v", err)
+	}
+	if exists(leftover) {
+		t.Errorf("labelled leftover survived gc; dropped %v", res.Dropped)
+	}
+	if !exists(unlabelled) {
+		t.Error("gc dropped a database pgmi did not label")
+	}
+}
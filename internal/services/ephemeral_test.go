@@ -0,0 +1,184 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vvka-141/pgmi/pkg/pgmi"
+)
+
+func TestEphemeralDatabaseName(t *testing.T) {
+	now := time.Date(2026, 10, 18, 9, 30, 0, 0, time.UTC)
+
+	a, err := ephemeralDatabaseName("pr_check", now)
+	if err != nil {
+		t.Fatalf("ephemeralDatabaseName: %v", err)
+	}
+	b, _ := ephemeralDatabaseName("pr_check", now)
+	if !strings.HasPrefix(a, "pr_check_20261018093000_") {
+		t.Errorf("name = %q, want the prefix and a UTC timestamp", a)
+	}
+	if a == b {
+		t.Errorf("two names generated in the same second collided: %q", a)
+	}
+
+	long, _ := ephemeralDatabaseName(strings.Repeat("é", 40), now)
+	if len(long) > maxIdentifierLength {
+		t.Errorf("name is %d bytes; PostgreSQL would truncate it past %d", len(long), maxIdentifierLength)
+	}
+	if !strings.HasPrefix(long, "é") || strings.ContainsRune(long, '�') {
+		t.Errorf("prefix was not cut at a character boundary: %q", long)
+	}
+}
+
+func TestEphemeralComment_RoundTrip(t *testing.T) {
+	now := time.Date(2026, 10, 18, 9, 30, 0, 0, time.UTC)
+	comment := ephemeralComment(now)
+	if !strings.HasPrefix(comment, EphemeralCommentPrefix) {
+		t.Fatalf("comment %q lacks the ephemeral label", comment)
+	}
+	got, ok := ephemeralCreatedAt(comment)
+	if !ok || !got.Equal(now) {
+		t.Errorf("ephemeralCreatedAt(%q) = %v, %t; want %v", comment, got, ok, now)
+	}
+	if _, ok := ephemeralCreatedAt("pgmi template abc"); ok {
+		t.Error("a template label must not read as ephemeral")
+	}
+}
+
+func ephemeralConfig() pgmi.DeploymentConfig {
+	cfg := validConfig()
+	cfg.DatabaseName = "pr_check"
+	cfg.Ephemeral = true
+	return cfg
+}
+
+func TestDeploy_EphemeralDropsTheDatabaseEvenWhenTheDeployFails(t *testing.T) {
+	dbMgr := &mockDatabaseManager{}
+	sess := &mockSessionPreparer{err: errMockStop}
+	svc := newTestService(dbMgr, nil, sess, successfulMgmtConn())
+
+	err := svc.Deploy(context.Background(), ephemeralConfig())
+	if !errors.Is(err, errMockStop) {
+		t.Fatalf("expected the deploy failure, got %v", err)
+	}
+	if len(dbMgr.created) != 1 || !strings.HasPrefix(dbMgr.created[0], "pr_check_") {
+		t.Fatalf("created %v, want one uniquely named database", dbMgr.created)
+	}
+	name := dbMgr.created[0]
+	if !slices.Equal(dbMgr.dropped, []string{name}) {
+		t.Errorf("dropped %v, want %q", dbMgr.dropped, name)
+	}
+	if c := dbMgr.createdWith.Comment; c == nil || !strings.HasPrefix(*c, EphemeralCommentPrefix) {
+		t.Errorf("created without the ephemeral label: %+v", dbMgr.createdWith)
+	}
+	if r := svc.LastResult(); r.Database != name || !r.Ephemeral || !r.EphemeralDropped {
+		t.Errorf("result = %+v", *r)
+	}
+}
+
+func TestDeploy_EphemeralDropsAfterCancellation(t *testing.T) {
+	dbMgr := &mockDatabaseManager{}
+	sess := &mockSessionPreparer{err: context.Canceled}
+	svc := newTestService(dbMgr, nil, sess, successfulMgmtConn())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := svc.Deploy(ctx, ephemeralConfig())
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if len(dbMgr.dropped) != 1 {
+		t.Errorf("dropped %v; an interrupted run must still clean up", dbMgr.dropped)
+	}
+}
+
+func TestDeploy_EphemeralDeployFailureOutranksDropFailure(t *testing.T) {
+	dropErr := errors.New("database is being accessed by other users")
+	dbMgr := &mockDatabaseManager{dropErr: dropErr}
+	svc := newTestService(dbMgr, nil, &mockSessionPreparer{err: errMockStop}, successfulMgmtConn())
+
+	// The drop failure is logged; the error returned explains the deploy.
+	if err := svc.Deploy(context.Background(), ephemeralConfig()); !errors.Is(err, errMockStop) {
+		t.Errorf("expected the deploy failure to be reported first, got %v", err)
+	}
+	if svc.LastResult().EphemeralDropped {
+		t.Error("EphemeralDropped set after a failed drop")
+	}
+}
+
+func TestDeploy_EphemeralCreateFailureDropsNothing(t *testing.T) {
+	dbMgr := &mockDatabaseManager{createErr: errors.New("permission denied to create database")}
+	svc := newTestService(dbMgr, nil, nil, successfulMgmtConn())
+
+	if err := svc.Deploy(context.Background(), ephemeralConfig()); err == nil {
+		t.Fatal("expected the create failure")
+	}
+	if len(dbMgr.dropped) > 0 {
+		t.Errorf("dropped %v although nothing was created", dbMgr.dropped)
+	}
+}
+
+func TestCollectEphemeral(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	old := ephemeralComment(now.Add(-3 * time.Hour))
+	young := ephemeralComment(now.Add(-5 * time.Minute))
+	unreadable := EphemeralCommentPrefix + "created=yesterday"
+
+	newManager := func() *mockDatabaseManager {
+		return &mockDatabaseManager{
+			listed: []string{"pr_old", "pr_young", "pr_unreadable"},
+			settingsFor: map[string]*pgmi.DatabaseSettings{
+				"pr_old":        {Comment: &old},
+				"pr_young":      {Comment: &young},
+				"pr_unreadable": {Comment: &unreadable},
+			},
+		}
+	}
+
+	dbMgr := newManager()
+	res, err := CollectEphemeral(context.Background(), &mockDBConnection{}, dbMgr, &mockLogger{},
+		GCOptions{OlderThan: time.Hour, Now: now})
+	if err != nil {
+		t.Fatalf("CollectEphemeral: %v", err)
+	}
+	if !slices.Equal(res.Dropped, []string{"pr_old", "pr_unreadable"}) || !slices.Equal(res.Kept, []string{"pr_young"}) {
+		t.Errorf("dropped %v, kept %v", res.Dropped, res.Kept)
+	}
+	if !slices.Equal(dbMgr.dropped, res.Dropped) {
+		t.Errorf("manager dropped %v, result says %v", dbMgr.dropped, res.Dropped)
+	}
+
+	dbMgr = newManager()
+	res, err = CollectEphemeral(context.Background(), &mockDBConnection{}, dbMgr, &mockLogger{},
+		GCOptions{OlderThan: time.Hour, Now: now, DryRun: true})
+	if err != nil {
+		t.Fatalf("CollectEphemeral dry run: %v", err)
+	}
+	if len(dbMgr.dropped) > 0 || len(res.Dropped) != 2 {
+		t.Errorf("dry run dropped %v and reported %v", dbMgr.dropped, res.Dropped)
+	}
+
+	dbMgr = newManager()
+	dbMgr.dropErr = errors.New("boom")
+	_, err = CollectEphemeral(context.Background(), &mockDBConnection{}, dbMgr, &mockLogger{},
+		GCOptions{OlderThan: time.Hour, Now: now})
+	if err == nil {
+		t.Fatal("expected drop failures to be reported")
+	}
+	if len(dbMgr.dropped) != 2 {
+		t.Errorf("attempted %v; one failure must not stop the rest", dbMgr.dropped)
+	}
+
+	// A manager with only the pgmi.DatabaseManager methods cannot find them.
+	dbMgr = newManager()
+	_, err = CollectEphemeral(context.Background(), &mockDBConnection{}, struct{ pgmi.DatabaseManager }{dbMgr}, &mockLogger{},
+		GCOptions{OlderThan: time.Hour, Now: now})
+	if !errors.Is(err, pgmi.ErrInvalidConfig) || len(dbMgr.dropped) > 0 {
+		t.Errorf("manager without ListByComment: err %v, dropped %v", err, dbMgr.dropped)
+	}
+}
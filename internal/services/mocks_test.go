@@ -2,7 +2,9 @@ package services
 
 import (
 	"context"
+	"fmt"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/vvka-141/pgmi/pkg/pgmi"
@@ -17,6 +19,17 @@ func (m *mockConnector) Connect(_ context.Context) (*pgxpool.Pool, error) {
 	return m.pool, m.err
 }
 
+// mockBorrowedConnector lends conn, which may be nil where the session is
+// refused before the connection is touched.
+type mockBorrowedConnector struct {
+	mockConnector
+	conn *pgx.Conn
+}
+
+func (m *mockBorrowedConnector) BorrowedConn() *pgx.Conn {
+	return m.conn
+}
+
 type mockApprover struct {
 	approved bool
 	err      error
@@ -30,10 +43,20 @@ type mockSessionPreparer struct {
 	session *pgmi.Session
 	err     error
 	scanErr error
+	files   []pgmi.FileMetadata
+
+	scannedEntry       string
+	scannedEntrypoints []string
+}
+
+func (m *mockSessionPreparer) ScanProject(sourcePath string) (pgmi.FileScanResult, error) {
+	return m.ScanProjectForEntry(sourcePath, "")
 }
 
-func (m *mockSessionPreparer) ScanProject(_ string) (pgmi.FileScanResult, error) {
-	return pgmi.FileScanResult{}, m.scanErr
+func (m *mockSessionPreparer) ScanProjectForEntry(_ string, entry string, entrypoints ...string) (pgmi.FileScanResult, error) {
+	m.scannedEntry = entry
+	m.scannedEntrypoints = entrypoints
+	return pgmi.FileScanResult{Files: m.files}, m.scanErr
 }
 
 func (m *mockSessionPreparer) PrepareSession(_ context.Context, _ *pgmi.ConnectionConfig, _ pgmi.FileScanResult, _ map[string]string, _ string, _ bool) (*pgmi.Session, error) {
@@ -55,26 +78,58 @@ func (m *mockDBConnection) QueryRow(_ context.Context, _ string, _ ...any) pgmi.
 }
 
 func (m *mockDBConnection) Acquire(_ context.Context) (pgmi.PooledConnection, error) {
-	return nil, m.acquireErr
+	if m.acquireErr != nil {
+		return nil, m.acquireErr
+	}
+	return &mockPooledConnection{execErr: m.execErr}, nil
 }
 
+type mockPooledConnection struct {
+	execErr error
+}
+
+func (m *mockPooledConnection) Exec(_ context.Context, _ string, _ ...any) (pgconn.CommandTag, error) {
+	return pgconn.CommandTag{}, m.execErr
+}
+
+func (m *mockPooledConnection) Release() {}
+
 type mockFileScanner struct {
 	scanResult  pgmi.FileScanResult
 	scanErr     error
 	validateErr error
 	readContent string
 	readErr     error
+
+	excluded      []string
+	validateEntry string
+	readEntry     string
+}
+
+func (m *mockFileScanner) ScanDirectory(sourcePath string) (pgmi.FileScanResult, error) {
+	return m.ScanDirectoryExcluding(sourcePath)
+}
+
+func (m *mockFileScanner) ValidateDeploySQL(sourcePath string) error {
+	return m.ValidateEntrypoint(sourcePath, pgmi.DefaultEntrypoint)
+}
+
+func (m *mockFileScanner) ReadDeploySQL(sourcePath string) (string, error) {
+	return m.ReadEntrypoint(sourcePath, pgmi.DefaultEntrypoint)
 }
 
-func (m *mockFileScanner) ScanDirectory(_ string) (pgmi.FileScanResult, error) {
+func (m *mockFileScanner) ScanDirectoryExcluding(_ string, entrypoints ...string) (pgmi.FileScanResult, error) {
+	m.excluded = entrypoints
 	return m.scanResult, m.scanErr
 }
 
-func (m *mockFileScanner) ValidateDeploySQL(_ string) error {
+func (m *mockFileScanner) ValidateEntrypoint(_ string, entry string) error {
+	m.validateEntry = entry
 	return m.validateErr
 }
 
-func (m *mockFileScanner) ReadDeploySQL(_ string) (string, error) {
+func (m *mockFileScanner) ReadEntrypoint(_ string, entry string) (string, error) {
+	m.readEntry = entry
 	return m.readContent, m.readErr
 }
 
@@ -107,21 +162,49 @@ type mockDatabaseManager struct {
 	settings    *pgmi.DatabaseSettings
 	settingsErr error
 	createdWith *pgmi.DatabaseSettings
+
+	// Per-database answers for workflows touching more than one database
+	// (a template and its clone); names absent here fall back to the fields
+	// above. Every Create and SetTemplate is recorded in call order.
+	existsFor   map[string]bool
+	settingsFor map[string]*pgmi.DatabaseSettings
+	created     []string
+	templated   []string
+
+	// What ListByComment reports, regardless of the prefix asked for.
+	listed  []string
+	listErr error
 }
 
-func (m *mockDatabaseManager) Exists(_ context.Context, _ pgmi.DBConnection, _ string) (bool, error) {
+func (m *mockDatabaseManager) Exists(_ context.Context, _ pgmi.DBConnection, name string) (bool, error) {
+	if exists, ok := m.existsFor[name]; ok {
+		return exists, m.existsErr
+	}
 	return m.existsResult, m.existsErr
 }
 
-func (m *mockDatabaseManager) Settings(_ context.Context, _ pgmi.DBConnection, _ string) (*pgmi.DatabaseSettings, error) {
+func (m *mockDatabaseManager) Settings(_ context.Context, _ pgmi.DBConnection, name string) (*pgmi.DatabaseSettings, error) {
+	if settings, ok := m.settingsFor[name]; ok {
+		return settings, m.settingsErr
+	}
 	return m.settings, m.settingsErr
 }
 
-func (m *mockDatabaseManager) Create(_ context.Context, _ pgmi.DBConnection, _ string, settings *pgmi.DatabaseSettings) error {
+func (m *mockDatabaseManager) Create(_ context.Context, _ pgmi.DBConnection, name string, settings *pgmi.DatabaseSettings) error {
+	m.created = append(m.created, name)
 	m.createdWith = settings
 	return m.createErr
 }
 
+func (m *mockDatabaseManager) ListByComment(_ context.Context, _ pgmi.DBConnection, _ string) ([]string, error) {
+	return m.listed, m.listErr
+}
+
+func (m *mockDatabaseManager) SetTemplate(_ context.Context, _ pgmi.DBConnection, name string, isTemplate bool) error {
+	m.templated = append(m.templated, fmt.Sprintf("%s=%t", name, isTemplate))
+	return nil
+}
+
 func (m *mockDatabaseManager) Drop(_ context.Context, _ pgmi.DBConnection, name string) error {
 	m.dropped = append(m.dropped, name)
 	return m.dropErr
@@ -38,20 +38,21 @@ func TestParameterValuesNeverReachDeployErrors(t *testing.T) {
 	defer pool.Close()
 
 	ctx := context.Background()
-	conn, err := pool.Acquire(ctx)
+	pooled, err := pool.Acquire(ctx)
 	if err != nil {
 		t.Fatalf("acquire connection: %v", err)
 	}
-	defer conn.Release()
+	defer pooled.Release()
+	conn := pooled.Conn()
 
 	if err := params.CreateSchema(ctx, conn); err != nil {
 		t.Fatalf("create schema: %v", err)
 	}
 	l := loader.NewLoader()
-	if err := l.LoadFilesIntoSession(ctx, conn, nil); err != nil {
+	if err := l.LoadFilesIntoConn(ctx, conn, nil); err != nil {
 		t.Fatalf("load files: %v", err)
 	}
-	if err := l.LoadParametersIntoSession(ctx, conn, map[string]string{
+	if err := l.LoadParametersIntoConn(ctx, conn, map[string]string{
 		"db_password": sentinel,
 	}); err != nil {
 		t.Fatalf("load parameters: %v", err)
@@ -79,7 +80,7 @@ END $$;
 `
 
 	svc := newServiceWithReadContent(deploySQL)
-	_, err = svc.executeDeploySQL(ctx, conn, "/fake/deploy.sql")
+	_, err = svc.executeDeploySQL(ctx, conn, pgmi.DeploymentConfig{SourcePath: "/fake/deploy.sql"})
 	if err == nil {
 		t.Fatal("deploy succeeded; it must fail for this to exercise the error path")
 	}
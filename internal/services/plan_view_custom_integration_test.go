@@ -0,0 +1,127 @@
+package services
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/vvka-141/pgmi/internal/checksum"
+	"github.com/vvka-141/pgmi/internal/contract"
+	"github.com/vvka-141/pgmi/internal/files/loader"
+	"github.com/vvka-141/pgmi/internal/files/scanner"
+	"github.com/vvka-141/pgmi/internal/metadata"
+	"github.com/vvka-141/pgmi/internal/params"
+	"github.com/vvka-141/pgmi/pkg/pgmi"
+)
+
+// Custom <pgmi-meta> attributes reach deploy.sql as JSONB, typed by the
+// project schema, so a policy is a plain WHERE clause.
+func TestPlanViewCustomAttributes(t *testing.T) {
+	connString := requireTestDB(t)
+
+	projectDir := t.TempDir()
+	for rel, content := range map[string]string{
+		"migrations/001.sql": `/* <pgmi-meta id="11111111-1111-4111-8111-111111111111" idempotent="false"
+    ops:owner="payments" ops:window="true"><sortKeys><key>10</key><key>90</key></sortKeys></pgmi-meta> */
+SELECT 1;`,
+		"migrations/002.sql": `/* <pgmi-meta id="22222222-2222-4222-8222-222222222222" idempotent="true"
+    ops:owner="search"><ops:env>prod</ops:env><ops:env>staging</ops:env></pgmi-meta> */
+SELECT 1;`,
+		"migrations/003.sql": "SELECT 1;",
+	} {
+		path := filepath.Join(projectDir, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("write %s: %v", rel, err)
+		}
+	}
+
+	scanned, err := scanner.NewScanner(checksum.New()).ScanDirectory(projectDir)
+	if err != nil {
+		t.Fatalf("scan project: %v", err)
+	}
+	schema := pgmi.MetadataSchema{"ops:owner": {}, "ops:window": {Type: pgmi.CustomTypeBoolean}, "ops:env": {}}
+	if err := metadata.ApplySchema(scanned.Files, schema); err != nil {
+		t.Fatalf("apply schema: %v", err)
+	}
+
+	dbName := "pgmi_itest_custom_metadata"
+	cleanup := createTestDB(t, connString, dbName)
+	defer cleanup()
+	pool := connectToTestDB(t, connString, dbName)
+	defer pool.Close()
+
+	ctx := context.Background()
+	pooled, err := pool.Acquire(ctx)
+	if err != nil {
+		t.Fatalf("acquire connection: %v", err)
+	}
+	defer pooled.Release()
+	conn := pooled.Conn()
+
+	if err := params.CreateSchema(ctx, conn); err != nil {
+		t.Fatalf("create schema: %v", err)
+	}
+	if err := loader.NewLoader().LoadFilesIntoConn(ctx, conn, scanned.Files); err != nil {
+		t.Fatalf("load files: %v", err)
+	}
+	if _, err := contract.Apply(ctx, conn, ""); err != nil {
+		t.Fatalf("apply contract: %v", err)
+	}
+
+	query := func(sql string) []string {
+		t.Helper()
+		rows, err := conn.Query(ctx, sql)
+		if err != nil {
+			t.Fatalf("query %q: %v", sql, err)
+		}
+		defer rows.Close()
+		var out []string
+		for rows.Next() {
+			var s string
+			if err := rows.Scan(&s); err != nil {
+				t.Fatalf("scan: %v", err)
+			}
+			out = append(out, s)
+		}
+		if err := rows.Err(); err != nil {
+			t.Fatalf("iterate: %v", err)
+		}
+		return out
+	}
+
+	// A boolean compares without a cast; each phase carries the attributes.
+	got := query(`SELECT path || '@' || sort_key FROM pg_temp.pgmi_plan_detail_view
+		WHERE (custom->'ops'->'window')::boolean ORDER BY execution_order`)
+	if want := []string{"./migrations/001.sql@10", "./migrations/001.sql@90"}; !slices.Equal(got, want) {
+		t.Errorf("maintenance-window entries %v, want %v", got, want)
+	}
+
+	got = query(`SELECT path FROM pg_temp.pgmi_plan_detail_view
+		WHERE custom @> '{"ops": {"env": ["prod"]}}' ORDER BY execution_order`)
+	if want := []string{"./migrations/002.sql"}; !slices.Equal(got, want) {
+		t.Errorf("prod entries %v, want %v", got, want)
+	}
+
+	got = query(`SELECT custom::text FROM pg_temp.pgmi_plan_detail_view WHERE path = './migrations/003.sql'`)
+	if want := []string{"{}"}; !slices.Equal(got, want) {
+		t.Errorf("a file without metadata has custom %v, want %v", got, want)
+	}
+
+	got = query(`SELECT DISTINCT path FROM pg_temp.pgmi_plan_detail_view WHERE custom->'ops'->>'owner' = 'search'`)
+	if want := []string{"./migrations/002.sql"}; !slices.Equal(got, want) {
+		t.Errorf("owner lookup %v, want %v", got, want)
+	}
+
+	// custom is a v2 column; the v1 views keep the columns v1 froze.
+	got = query(`SELECT attrelid::regclass || '.' || attname FROM pg_attribute
+		WHERE attrelid IN ('pg_temp.pgmi_plan_view'::regclass, 'pg_temp.pgmi_source_metadata_view'::regclass)
+		  AND attname = 'custom'`)
+	if len(got) != 0 {
+		t.Errorf("v1 views gained %v", got)
+	}
+}
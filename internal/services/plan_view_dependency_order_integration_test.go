@@ -0,0 +1,177 @@
+package services
+
+import (
+	"cmp"
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/vvka-141/pgmi/internal/checksum"
+	"github.com/vvka-141/pgmi/internal/contract"
+	"github.com/vvka-141/pgmi/internal/files/loader"
+	"github.com/vvka-141/pgmi/internal/files/scanner"
+	"github.com/vvka-141/pgmi/internal/metadata"
+	"github.com/vvka-141/pgmi/internal/params"
+)
+
+// pgmi_plan_detail_view.dependency_order and metadata.DependencyOrder are the same
+// rule written twice, once in SQL for deploy.sql and once in Go for
+// `pgmi metadata plan`. This pins them to each other on a project with chains,
+// a multi-phase file, a metadata-less dependency and a non-SQL file in the
+// plan, and checks the SQL side honours every declared edge entry by entry.
+func TestPlanViewDependencyOrderMatchesGo(t *testing.T) {
+	connString := requireTestDB(t)
+
+	meta := func(id string, keys []string, deps ...string) string {
+		var b strings.Builder
+		b.WriteString(`/* <pgmi-meta id="` + id + `" idempotent="true"><sortKeys>`)
+		for _, k := range keys {
+			b.WriteString("<key>" + k + "</key>")
+		}
+		b.WriteString("</sortKeys>")
+		if len(deps) > 0 {
+			b.WriteString("<dependsOn>")
+			for _, d := range deps {
+				b.WriteString("<id>" + d + "</id>")
+			}
+			b.WriteString("</dependsOn>")
+		}
+		b.WriteString("</pgmi-meta> */\nSELECT 1;\n")
+		return b.String()
+	}
+	projectDir := t.TempDir()
+	for rel, content := range map[string]string{
+		"views/report.sql":   meta("11111111-1111-4111-8111-111111111111", []string{"10"}, "22222222-2222-4222-8222-222222222222"),
+		"schemas/orders.sql": meta("22222222-2222-4222-8222-222222222222", []string{"30"}, "schemas/users.sql", "lib/helpers.sql"),
+		"schemas/users.sql":  meta("33333333-3333-4333-8333-333333333333", []string{"20", "90"}),
+		"grants/all.sql":     meta("44444444-4444-4444-8444-444444444444", []string{"40"}, "./schemas/users.sql"),
+		"lib/helpers.sql":    "SELECT 1;\n",
+		"data/config.json":   "{}\n",
+	} {
+		path := filepath.Join(projectDir, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("write %s: %v", rel, err)
+		}
+	}
+
+	scanned, err := scanner.NewScanner(checksum.New()).ScanDirectory(projectDir)
+	if err != nil {
+		t.Fatalf("scan project: %v", err)
+	}
+	deps, err := metadata.ResolveDependencies(scanned.Files)
+	if err != nil {
+		t.Fatalf("resolve dependencies: %v", err)
+	}
+
+	// Go side: SQL files by first execution (smallest sort key, then path).
+	type first struct{ path, key string }
+	var firsts []first
+	for _, f := range scanned.Files {
+		if filepath.Ext(f.Path) != ".sql" {
+			continue
+		}
+		key := f.Path
+		if f.Metadata != nil && len(f.Metadata.SortKeys) > 0 {
+			key = slices.Min(f.Metadata.SortKeys)
+		}
+		firsts = append(firsts, first{f.Path, key})
+	}
+	slices.SortFunc(firsts, func(a, b first) int {
+		if n := cmp.Compare(a.key, b.key); n != 0 {
+			return n
+		}
+		return cmp.Compare(a.path, b.path)
+	})
+	order := make([]string, len(firsts))
+	for i, f := range firsts {
+		order[i] = f.path
+	}
+	goOrder := metadata.DependencyOrder(order, deps)
+
+	want := []string{"./lib/helpers.sql", "./schemas/users.sql", "./schemas/orders.sql", "./views/report.sql", "./grants/all.sql"}
+	if !slices.Equal(goOrder, want) {
+		t.Fatalf("metadata.DependencyOrder = %v, want %v", goOrder, want)
+	}
+
+	dbName := "pgmi_itest_dependency_order"
+	cleanup := createTestDB(t, connString, dbName)
+	defer cleanup()
+	pool := connectToTestDB(t, connString, dbName)
+	defer pool.Close()
+
+	ctx := context.Background()
+	pooled, err := pool.Acquire(ctx)
+	if err != nil {
+		t.Fatalf("acquire connection: %v", err)
+	}
+	defer pooled.Release()
+	conn := pooled.Conn()
+
+	if err := params.CreateSchema(ctx, conn); err != nil {
+		t.Fatalf("create schema: %v", err)
+	}
+	if err := loader.NewLoader().LoadFilesIntoConn(ctx, conn, scanned.Files); err != nil {
+		t.Fatalf("load files: %v", err)
+	}
+	if _, err := contract.Apply(ctx, conn, ""); err != nil {
+		t.Fatalf("apply contract: %v", err)
+	}
+
+	var sqlOrder []string
+	rows, err := conn.Query(ctx, `
+		SELECT path FROM pg_temp.pgmi_plan_detail_view
+		WHERE is_sql_file
+		GROUP BY path ORDER BY MIN(dependency_order)`)
+	if err != nil {
+		t.Fatalf("query plan view: %v", err)
+	}
+	for rows.Next() {
+		var p string
+		if err := rows.Scan(&p); err != nil {
+			t.Fatalf("scan row: %v", err)
+		}
+		sqlOrder = append(sqlOrder, p)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatalf("iterate rows: %v", err)
+	}
+	if !slices.Equal(sqlOrder, goOrder) {
+		t.Errorf("pgmi_plan_detail_view.dependency_order gives %v, `pgmi metadata plan` gives %v", sqlOrder, goOrder)
+	}
+
+	// Every entry of a dependent file comes after the first entry of each
+	// file it depends on.
+	var violations int
+	if err := conn.QueryRow(ctx, `
+		SELECT count(*)
+		FROM pg_temp.pgmi_plan_detail_view e
+		CROSS JOIN LATERAL UNNEST(e.depends_on) AS d(dep_path)
+		WHERE e.dependency_order < (
+		    SELECT MIN(dependency_order) FROM pg_temp.pgmi_plan_detail_view WHERE path = d.dep_path)`,
+	).Scan(&violations); err != nil {
+		t.Fatalf("check edges: %v", err)
+	}
+	if violations != 0 {
+		t.Errorf("%d plan entries run before a file they depend on", violations)
+	}
+
+	// The v1 views keep the columns v1 froze.
+	var leaked []string
+	if err := conn.QueryRow(ctx, `
+		SELECT COALESCE(array_agg(attrelid::regclass || '.' || attname), '{}')
+		FROM pg_attribute
+		WHERE attrelid IN ('pg_temp.pgmi_plan_view'::regclass, 'pg_temp.pgmi_source_metadata_view'::regclass)
+		  AND attname IN ('dependency_order', 'depends_on')`,
+	).Scan(&leaked); err != nil {
+		t.Fatalf("check v1 columns: %v", err)
+	}
+	if len(leaked) != 0 {
+		t.Errorf("v1 views gained %v", leaked)
+	}
+}
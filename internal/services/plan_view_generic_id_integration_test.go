@@ -79,16 +79,17 @@ func TestPlanViewGenericIDMatchesGoFallbackAcrossEncodings(t *testing.T) {
 			defer pool.Close()
 
 			ctx := context.Background()
-			conn, err := pool.Acquire(ctx)
+			pooled, err := pool.Acquire(ctx)
 			if err != nil {
 				t.Fatalf("acquire connection: %v", err)
 			}
-			defer conn.Release()
+			defer pooled.Release()
+			conn := pooled.Conn()
 
 			if err := params.CreateSchema(ctx, conn); err != nil {
 				t.Fatalf("create schema: %v", err)
 			}
-			if err := loader.NewLoader().LoadFilesIntoSession(ctx, conn, scanned.Files); err != nil {
+			if err := loader.NewLoader().LoadFilesIntoConn(ctx, conn, scanned.Files); err != nil {
 				t.Fatalf("load files: %v", err)
 			}
 			if _, err := contract.Apply(ctx, conn, ""); err != nil {
@@ -0,0 +1,175 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/vvka-141/pgmi/internal/checksum"
+	"github.com/vvka-141/pgmi/pkg/pgmi"
+)
+
+// TailProgressTable is where every deploy records each committed tail unit,
+// for a later --resume to skip. It is a real table, not a pg_temp one: the
+// whole point is to outlive the session that failed. pgmi creates it only
+// when deploy.sql actually has a tail. The schema name is fixed; the
+// --resume help and DEPLOY-GUIDE.md tell users not to reuse it.
+const TailProgressTable = "pgmi.deploy_tail_progress"
+
+const createTailProgressSQL = `
+CREATE SCHEMA IF NOT EXISTS pgmi;
+CREATE TABLE IF NOT EXISTS pgmi.deploy_tail_progress (
+    script_checksum TEXT NOT NULL,
+    unit_index      INT NOT NULL,
+    unit_checksum   TEXT NOT NULL,
+    committed_at    TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (script_checksum, unit_index)
+);
+COMMENT ON TABLE pgmi.deploy_tail_progress IS
+    'Tail units of deploy.sql committed by a pgmi deploy run, for pgmi deploy --resume to skip. Cleared when a run completes.'`
+
+// tailProgress is one committed tail unit as recorded by an earlier run.
+type tailProgress struct {
+	ScriptChecksum string
+	UnitIndex      int
+	UnitChecksum   string
+}
+
+// unitChecksums hashes every execution unit. Units are hashed as sent — tail
+// units carry their whitespace padding — which is stable because a resume is
+// only ever attempted against a byte-identical deploy.sql.
+func unitChecksums(units []string) []string {
+	calc := checksum.New()
+	sums := make([]string, len(units))
+	for i, u := range units {
+		sums[i] = calc.CalculateRaw([]byte(u))
+	}
+	return sums
+}
+
+// planResume decides how many leading tail units a resumed run may skip, given
+// what an earlier run recorded. It returns the index of the first unit to
+// execute; the head (unit 0) always runs, so the result is never below 1 when
+// anything is skipped and is 0 when nothing is.
+//
+// Skipping is all-or-nothing on identity: any recorded row for another script,
+// any unit whose text no longer matches, or a gap in the recorded indices means
+// the earlier run executed something other than what is about to run, and
+// resuming would silently apply half of one script on top of half of another.
+func planResume(scriptChecksum string, sums []string, recorded []tailProgress) (int, error) {
+	if len(recorded) == 0 {
+		return 0, nil
+	}
+
+	byIndex := make(map[int]string, len(recorded))
+	for _, r := range recorded {
+		if r.ScriptChecksum != scriptChecksum {
+			return 0, fmt.Errorf("%w: cannot resume: the script changed since the interrupted run, "+
+				"by an edit or a different --entry (recorded checksum %.12s, now %.12s)\n"+
+				"resume only skips units of a byte-identical script; restore it, or discard the "+
+				"recorded progress with DELETE FROM %s and deploy from the beginning",
+				pgmi.ErrInvalidConfig, r.ScriptChecksum, scriptChecksum, TailProgressTable)
+		}
+		byIndex[r.UnitIndex] = r.UnitChecksum
+	}
+
+	next := 1
+	for ; next < len(sums); next++ {
+		recordedSum, ok := byIndex[next]
+		if !ok {
+			break
+		}
+		if recordedSum != sums[next] {
+			return 0, fmt.Errorf("%w: cannot resume: execution unit %d differs from the one the interrupted run committed\n"+
+				"the expanded script changed (a test file feeding a pgmi_test() macro, say); discard the "+
+				"recorded progress with DELETE FROM %s and deploy from the beginning",
+				pgmi.ErrInvalidConfig, next+1, TailProgressTable)
+		}
+		delete(byIndex, next)
+	}
+
+	if len(byIndex) > 0 {
+		return 0, fmt.Errorf("%w: cannot resume: recorded progress is not a contiguous run of "+
+			"tail units starting after the head\ndiscard it with DELETE FROM %s and deploy from the beginning",
+			pgmi.ErrInvalidConfig, TailProgressTable)
+	}
+
+	if next == 1 {
+		return 0, nil
+	}
+	return next, nil
+}
+
+// loadTailProgress reads what an earlier run recorded. A database that never
+// ran a script with a tail has no table, which is the same as no progress.
+func loadTailProgress(ctx context.Context, conn *pgx.Conn) ([]tailProgress, error) {
+	var exists bool
+	if err := conn.QueryRow(ctx, `SELECT to_regclass($1) IS NOT NULL`, TailProgressTable).Scan(&exists); err != nil {
+		return nil, fmt.Errorf("failed to look up %s: %w", TailProgressTable, err)
+	}
+	if !exists {
+		return nil, nil
+	}
+
+	rows, err := conn.Query(ctx, `SELECT script_checksum, unit_index, unit_checksum FROM pgmi.deploy_tail_progress ORDER BY unit_index`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", TailProgressTable, err)
+	}
+	recorded, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (tailProgress, error) {
+		var p tailProgress
+		err := row.Scan(&p.ScriptChecksum, &p.UnitIndex, &p.UnitChecksum)
+		return p, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", TailProgressTable, err)
+	}
+	return recorded, nil
+}
+
+// tailRecorder persists committed tail units for a later --resume.
+type tailRecorder struct {
+	scriptChecksum string
+	sums           []string
+}
+
+// ensureTable creates TailProgressTable. It runs before the head, while the
+// session is idle: created from inside an explicit tail BEGIN, the table would
+// vanish with that transaction's rollback.
+func (r *tailRecorder) ensureTable(ctx context.Context, conn *pgx.Conn) error {
+	if _, err := conn.Exec(ctx, createTailProgressSQL); err != nil {
+		return fmt.Errorf("failed to create %s: %w", TailProgressTable, err)
+	}
+	return nil
+}
+
+func (r *tailRecorder) record(ctx context.Context, conn *pgx.Conn, index int) error {
+	// Inside an explicit tail BEGIN ... COMMIT this insert joins the user's
+	// transaction, which is exactly right: if that transaction rolls back, so
+	// does the claim that its statements were committed.
+	if _, err := conn.Exec(ctx,
+		`INSERT INTO pgmi.deploy_tail_progress (script_checksum, unit_index, unit_checksum)
+		 VALUES ($1, $2, $3)
+		 ON CONFLICT (script_checksum, unit_index) DO UPDATE SET unit_checksum = EXCLUDED.unit_checksum, committed_at = now()`,
+		r.scriptChecksum, index, r.sums[index]); err != nil {
+		return fmt.Errorf("failed to record tail unit %d in %s: %w", index+1, TailProgressTable, err)
+	}
+	return nil
+}
+
+// clear forgets all recorded progress: once a run completes, and once the
+// head of a run that is not resuming has committed.
+func (r *tailRecorder) clear(ctx context.Context, conn *pgx.Conn) error {
+	if _, err := conn.Exec(ctx, `DELETE FROM pgmi.deploy_tail_progress`); err != nil {
+		return fmt.Errorf("failed to clear %s: %w", TailProgressTable, err)
+	}
+	return nil
+}
+
+// setResumedUnits publishes the number of skipped units to deploy.sql.
+func setResumedUnits(ctx context.Context, conn *pgx.Conn, skipped int) error {
+	if _, err := conn.Exec(ctx, `SELECT set_config($1, $2, false)`, pgmi.ResumedUnitsSetting, strconv.Itoa(skipped)); err != nil {
+		return fmt.Errorf("failed to set %s: %w", pgmi.ResumedUnitsSetting, err)
+	}
+	return nil
+}
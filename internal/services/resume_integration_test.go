@@ -0,0 +1,121 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/vvka-141/pgmi/pkg/pgmi"
+)
+
+// A run that fails mid-tail, retried with --resume and the same deploy.sql,
+// must skip exactly the tail units the first run committed and re-run the
+// head. The first run is a plain one: recording does not depend on --resume.
+func TestExecuteDeploySQL_ResumeSkipsCommittedTailUnits(t *testing.T) {
+	connString := requireTestDB(t)
+	testDB := "pgmi_itest_resume_tail"
+	cleanup := createTestDB(t, connString, testDB)
+	defer cleanup()
+
+	pool := connectToTestDB(t, connString, testDB)
+	defer pool.Close()
+
+	ctx := context.Background()
+	pooled, err := pool.Acquire(ctx)
+	if err != nil {
+		t.Fatalf("Failed to acquire connection: %v", err)
+	}
+	defer pooled.Release()
+	conn := pooled.Conn()
+
+	prepareSessionTables(t, ctx, conn)
+
+	if _, err := conn.Exec(ctx, `CREATE TABLE resume_log(step text); CREATE TABLE resume_gate(open bool)`); err != nil {
+		t.Fatalf("setup: %v", err)
+	}
+
+	deploySQL := `
+BEGIN;
+INSERT INTO resume_log VALUES ('head');
+COMMIT;
+INSERT INTO resume_log VALUES ('tail-1');
+DO $$ BEGIN IF NOT EXISTS (SELECT 1 FROM resume_gate WHERE open) THEN RAISE EXCEPTION 'gate closed'; END IF; END $$;
+INSERT INTO resume_log VALUES ('tail-3');
+`
+	svc := newServiceWithReadContent(deploySQL)
+	if _, err := svc.executeDeploySQL(ctx, conn, pgmi.DeploymentConfig{SourcePath: "/fake/path"}); err == nil {
+		t.Fatal("expected the closed gate to fail the first run")
+	}
+	if svc.lastResult.UnitsCommitted != 2 {
+		t.Fatalf("first run: UnitsCommitted = %d, want 2", svc.lastResult.UnitsCommitted)
+	}
+
+	if _, err := conn.Exec(ctx, `INSERT INTO resume_gate VALUES (true)`); err != nil {
+		t.Fatalf("open gate: %v", err)
+	}
+
+	svc = newServiceWithReadContent(deploySQL)
+	if _, err := svc.executeDeploySQL(ctx, conn, pgmi.DeploymentConfig{SourcePath: "/fake/path", Resume: true}); err != nil {
+		t.Fatalf("resumed run failed: %v", err)
+	}
+	if svc.lastResult.UnitsSkipped != 1 {
+		t.Errorf("UnitsSkipped = %d, want 1", svc.lastResult.UnitsSkipped)
+	}
+
+	var steps string
+	if err := conn.QueryRow(ctx, `SELECT string_agg(step, ',' ORDER BY ctid) FROM resume_log`).Scan(&steps); err != nil {
+		t.Fatalf("read log: %v", err)
+	}
+	if steps != "head,tail-1,head,tail-3" {
+		t.Errorf("executed steps = %q; the head re-runs and tail-1 must not", steps)
+	}
+
+	var resumed string
+	if err := conn.QueryRow(ctx, `SELECT current_setting('pgmi.resumed_units', true)`).Scan(&resumed); err != nil {
+		t.Fatalf("read setting: %v", err)
+	}
+	if resumed != "1" {
+		t.Errorf("pgmi.resumed_units = %q, want 1", resumed)
+	}
+
+	var left int
+	if err := conn.QueryRow(ctx, `SELECT count(*) FROM pgmi.deploy_tail_progress`).Scan(&left); err != nil {
+		t.Fatalf("count progress: %v", err)
+	}
+	if left != 0 {
+		t.Errorf("a completed resume must clear its progress, %d row(s) left", left)
+	}
+}
+
+func TestExecuteDeploySQL_ResumeRefusesChangedScript(t *testing.T) {
+	connString := requireTestDB(t)
+	testDB := "pgmi_itest_resume_changed"
+	cleanup := createTestDB(t, connString, testDB)
+	defer cleanup()
+
+	pool := connectToTestDB(t, connString, testDB)
+	defer pool.Close()
+
+	ctx := context.Background()
+	pooled, err := pool.Acquire(ctx)
+	if err != nil {
+		t.Fatalf("Failed to acquire connection: %v", err)
+	}
+	defer pooled.Release()
+	conn := pooled.Conn()
+
+	prepareSessionTables(t, ctx, conn)
+
+	failing := "BEGIN; COMMIT;\nSELECT 1;\nSELECT 1/0;\n"
+	svc := newServiceWithReadContent(failing)
+	if _, err := svc.executeDeploySQL(ctx, conn, pgmi.DeploymentConfig{SourcePath: "/fake/path", Resume: true}); err == nil {
+		t.Fatal("expected division by zero")
+	}
+
+	svc = newServiceWithReadContent("BEGIN; COMMIT;\nSELECT 2;\nSELECT 1;\n")
+	_, err = svc.executeDeploySQL(ctx, conn, pgmi.DeploymentConfig{SourcePath: "/fake/path", Resume: true})
+	if !errors.Is(err, pgmi.ErrInvalidConfig) || !strings.Contains(err.Error(), "script changed") {
+		t.Fatalf("expected a refusal to resume a changed script, got %v", err)
+	}
+}
@@ -0,0 +1,74 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/vvka-141/pgmi/pkg/pgmi"
+)
+
+func TestPlanResume(t *testing.T) {
+	sums := []string{"head", "u1", "u2", "u3"}
+	rows := func(script string, idx ...int) []tailProgress {
+		var out []tailProgress
+		for _, i := range idx {
+			out = append(out, tailProgress{ScriptChecksum: script, UnitIndex: i, UnitChecksum: sums[i]})
+		}
+		return out
+	}
+
+	tests := []struct {
+		name     string
+		recorded []tailProgress
+		want     int
+		wantErr  string
+	}{
+		{name: "nothing recorded runs everything", want: 0},
+		{name: "first tail unit committed", recorded: rows("s", 1), want: 2},
+		{name: "two tail units committed", recorded: rows("s", 1, 2), want: 3},
+		{name: "whole tail committed", recorded: rows("s", 1, 2, 3), want: 4},
+		{name: "another script refuses", recorded: rows("other", 1), wantErr: "script changed"},
+		{name: "gap refuses", recorded: rows("s", 1, 3), wantErr: "not a contiguous run"},
+		{name: "missing first tail unit refuses", recorded: rows("s", 2), wantErr: "not a contiguous run"},
+		{
+			name:     "changed unit refuses",
+			recorded: []tailProgress{{ScriptChecksum: "s", UnitIndex: 1, UnitChecksum: "stale"}},
+			wantErr:  "execution unit 2 differs",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := planResume("s", sums, tt.recorded)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("want error containing %q, got %v", tt.wantErr, err)
+				}
+				if !errors.Is(err, pgmi.ErrInvalidConfig) {
+					t.Errorf("a refused resume must exit 10, got %v", err)
+				}
+				if !strings.Contains(err.Error(), TailProgressTable) {
+					t.Errorf("the refusal must name the table to clear, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("planResume = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnitChecksums_DistinguishUnits(t *testing.T) {
+	sums := unitChecksums([]string{"SELECT 1;", "SELECT 1; ", "SELECT 1;"})
+	if sums[0] == sums[1] {
+		t.Error("units that differ in a byte must not share a checksum")
+	}
+	if sums[0] != sums[2] {
+		t.Error("identical units must share a checksum")
+	}
+}
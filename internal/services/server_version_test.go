@@ -56,3 +56,42 @@ func TestVerifyServerVersion(t *testing.T) {
 		})
 	}
 }
+
+// A standby or a read-only primary must be refused before the deploy's first
+// write, with ErrReadOnlyServer (exit 11) and a message saying what to do
+// rather than PostgreSQL's "cannot execute ... in a read-only transaction".
+func TestVerifyWritable(t *testing.T) {
+	tests := []struct {
+		name       string
+		inRecovery bool
+		readOnly   bool
+		want       string
+	}{
+		{"primary", false, false, ""},
+		{"standby", true, true, "target_session_attrs=read-write"},
+		{"read-only primary", false, true, "default_transaction_read_only"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := verifyWritable(tt.inRecovery, tt.readOnly, "10.0.0.2:5432")
+			if tt.want == "" {
+				if err != nil {
+					t.Fatalf("a writable primary was rejected: %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, pgmi.ErrReadOnlyServer) {
+				t.Fatalf("expected an ErrReadOnlyServer chain, got %v", err)
+			}
+			if got := pgmi.ExitCodeForError(err); got != pgmi.ExitConnectionError {
+				t.Errorf("exit code %d, want %d (connection error)", got, pgmi.ExitConnectionError)
+			}
+			for _, want := range []string{"10.0.0.2:5432", tt.want} {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("the diagnostic omits %q: %v", want, err)
+				}
+			}
+		})
+	}
+}
@@ -10,10 +10,14 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/vvka-141/pgmi/internal/contract"
+	"github.com/vvka-141/pgmi/internal/metadata"
 	"github.com/vvka-141/pgmi/internal/params"
+	"github.com/vvka-141/pgmi/internal/tracing"
 	"github.com/vvka-141/pgmi/pkg/pgmi"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // ReleaseDeployLock is indirected so a test can observe that the failure path
@@ -22,7 +26,16 @@ import (
 // backend, so it cannot be reproduced on demand over loopback. Exported only
 // because internal/testing imports this package, which keeps the test in the
 // external test package.
-var ReleaseDeployLock = pgmi.ReleaseDeployLock
+var ReleaseDeployLock = pgmi.ReleaseDeployLockConn
+
+// connLoader is a pgmi.FileLoader that can also load into a connection that
+// did not come from a pool, which a session on a borrowed connection needs.
+// internal/files/loader's Loader is one; a FileLoader that is not can still
+// serve every session pgmi connects itself.
+type connLoader interface {
+	LoadFilesIntoConn(ctx context.Context, conn *pgx.Conn, files []pgmi.FileMetadata) error
+	LoadParametersIntoConn(ctx context.Context, conn *pgx.Conn, params map[string]string) error
+}
 
 // SessionManager handles session initialization shared between deployment and testing.
 // Responsibility: Scan files, connect to database, prepare session (utility functions, files, parameters).
@@ -84,20 +97,15 @@ func (sm *SessionManager) PrepareSession(
 	compat string,
 	verbose bool,
 ) (*pgmi.Session, error) {
-	// Connect to target database
-	pool, connectorCleanup, err := sm.connectToDatabase(ctx, connConfig)
+	// Connect to target database, or take the connection the caller lent
+	connectCtx, connectSpan := tracing.Start(ctx, "pgmi.connect",
+		attribute.String("db.namespace", connConfig.Database))
+	sc, err := sm.acquireSessionConn(connectCtx, connConfig)
+	tracing.End(connectSpan, err)
 	if err != nil {
 		return nil, fmt.Errorf("database connection failed: %w", err)
 	}
-
-	// Acquire a single connection for the entire session
-	// This is critical because pg_temp tables are session-scoped
-	conn, err := pool.Acquire(ctx)
-	if err != nil {
-		pool.Close()
-		connectorCleanup()
-		return nil, fmt.Errorf("failed to acquire connection: %w", err)
-	}
+	conn := sc.conn
 
 	// lockAcquired is declared here so the cleanup below can see it: abandoning
 	// a half-prepared session must release the advisory lock explicitly, exactly
@@ -116,9 +124,7 @@ func (sm *SessionManager) PrepareSession(
 			ReleaseDeployLock(unlockCtx, conn)
 			cancel()
 		}
-		conn.Release()
-		pool.Close()
-		connectorCleanup()
+		sc.abandon()
 	}()
 
 	// Before anything else touches the server. The advisory lock below already
@@ -128,6 +134,9 @@ func (sm *SessionManager) PrepareSession(
 	if err := checkServerVersion(ctx, conn); err != nil {
 		return nil, err
 	}
+	if err := checkWritable(ctx, conn); err != nil {
+		return nil, err
+	}
 
 	// Serialise concurrent `pgmi deploy` against the same target database.
 	// pg_try_advisory_lock returns false immediately if another session
@@ -152,18 +161,18 @@ func (sm *SessionManager) PrepareSession(
 	}
 
 	if verbose {
-		if _, err := conn.Exec(ctx, "SET client_min_messages = 'debug'"); err != nil {
+		if err := sc.set(ctx, "client_min_messages", "debug"); err != nil {
 			return nil, fmt.Errorf("failed to set client_min_messages: %w", err)
 		}
 	}
 
 	// Prepare session (utility functions, files, params, API contract)
-	if err := sm.prepareSessionTables(ctx, conn, &scanResult, parameters, compat); err != nil {
+	if err := sm.prepareSessionTables(ctx, sc, &scanResult, parameters, compat); err != nil {
 		return nil, fmt.Errorf("session preparation failed: %w", err)
 	}
 
 	// Create Session object to encapsulate resources
-	session := pgmi.NewSession(pool, conn, connectorCleanup)
+	session := sc.session()
 	session.FilesLoaded = len(scanResult.Files)
 	success = true
 	return session, nil
@@ -172,7 +181,7 @@ func (sm *SessionManager) PrepareSession(
 // checkServerVersion rejects a server below pgmi's floor before any work is
 // done, so the operator gets a pgmi-authored diagnostic naming the requirement
 // rather than whatever the first unsupported construct happens to raise.
-func checkServerVersion(ctx context.Context, conn *pgxpool.Conn) error {
+func checkServerVersion(ctx context.Context, conn *pgx.Conn) error {
 	var versionNum int
 	var versionText string
 	if err := conn.QueryRow(ctx,
@@ -194,19 +203,69 @@ func verifyServerVersion(versionNum int, versionText string) error {
 		pgmi.ErrInvalidConfig, versionText, pgmi.MinimumServerVersionNum/10000)
 }
 
+// rowQuerier is what checkWritable needs of a *pgx.Conn or *pgxpool.Pool.
+type rowQuerier interface {
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// checkWritable rejects a server that cannot take a deployment's writes: a
+// standby, or a primary defaulting to read-only transactions. Without it a
+// multi-host connection string lacking target_session_attrs=read-write
+// settles on whichever host answers first, and the deploy fails at its first
+// write with "cannot execute CREATE TABLE in a read-only transaction".
+func checkWritable(ctx context.Context, q rowQuerier) error {
+	var inRecovery bool
+	var readOnly, server string
+	if err := q.QueryRow(ctx,
+		`SELECT pg_is_in_recovery(), current_setting('transaction_read_only'),
+		        coalesce(host(inet_server_addr()) || ':' || inet_server_port(), 'local socket')`,
+	).Scan(&inRecovery, &readOnly, &server); err != nil {
+		return fmt.Errorf("failed to check whether the server is read-only: %w", err)
+	}
+	return verifyWritable(inRecovery, readOnly == "on", server)
+}
+
+// verifyWritable is split out so the rejection is testable without a standby
+// to hand.
+func verifyWritable(inRecovery, readOnly bool, server string) error {
+	switch {
+	case inRecovery:
+		return fmt.Errorf("%w: %s is a standby in recovery, and pgmi deploys only to a primary\n"+
+			"connect to the primary, or list every cluster host with target_session_attrs=read-write",
+			pgmi.ErrReadOnlyServer, server)
+	case readOnly:
+		return fmt.Errorf("%w: %s only runs read-only transactions (default_transaction_read_only is on)\n"+
+			"connect to a writable server, or as a role without default_transaction_read_only",
+			pgmi.ErrReadOnlyServer, server)
+	}
+	return nil
+}
+
 // ScanProject scans the source directory and validates files. Callers run this
 // before creating or overwriting a database so an unscannable project fails
 // without leaving one behind.
 func (sm *SessionManager) ScanProject(sourcePath string) (pgmi.FileScanResult, error) {
+	return sm.ScanProjectForEntry(sourcePath, "")
+}
+
+// ScanProjectForEntry is ScanProject for the orchestrator script entry
+// (empty for deploy.sql); it and every other declared entrypoint are kept out
+// of the scan result.
+func (sm *SessionManager) ScanProjectForEntry(sourcePath, entry string, entrypoints ...string) (pgmi.FileScanResult, error) {
 	sm.logger.Verbose("Scanning %s", sourcePath)
 
-	// Validate deploy.sql exists
-	if err := sm.fileScanner.ValidateDeploySQL(sourcePath); err != nil {
-		return pgmi.FileScanResult{}, fmt.Errorf("failed to validate deploy.sql: %w", err)
+	entry = pgmi.NormalizeEntrypoint(entry)
+	if err := validateEntrypoint(sm.fileScanner, sourcePath, entry); err != nil {
+		return pgmi.FileScanResult{}, fmt.Errorf("failed to validate %s: %w", entry, err)
 	}
 
-	// Scan all files (excluding deploy.sql)
-	scanResult, err := sm.fileScanner.ScanDirectory(sourcePath)
+	// Scan all files (excluding the entrypoints)
+	var excluded []string
+	if entry != pgmi.DefaultEntrypoint {
+		excluded = append(excluded, entry)
+	}
+	excluded = append(excluded, entrypoints...)
+	scanResult, err := scanDirectory(sm.fileScanner, sourcePath, excluded)
 	if err != nil {
 		return pgmi.FileScanResult{}, fmt.Errorf("failed to scan directory \"%s\": %w", sourcePath, err)
 	}
@@ -215,6 +274,12 @@ func (sm *SessionManager) ScanProject(sourcePath string) (pgmi.FileScanResult, e
 		return pgmi.FileScanResult{}, err
 	}
 
+	// An unknown <dependsOn> id or a cycle would otherwise surface only when
+	// the loader resolves the graph, after the target database was created.
+	if _, err := metadata.ResolveDependencies(scanResult.Files); err != nil {
+		return pgmi.FileScanResult{}, err
+	}
+
 	sm.logger.Verbose("Found %d files to load", len(scanResult.Files))
 
 	return scanResult, nil
@@ -251,27 +316,155 @@ func validateNoDuplicateScriptIDs(files []pgmi.FileMetadata) error {
 	return nil
 }
 
-// connectToDatabase establishes a connection to the target database.
-// Returns a cleanup function that releases connector-level resources (e.g., Cloud SQL dialer).
-// The cleanup function must be called after the pool is closed.
-func (sm *SessionManager) connectToDatabase(
+// validateEntrypoint, scanDirectory and readEntrypoint use the
+// pgmi.EntrypointScanner methods when fileScanner has them. A FileScanner
+// without them serves a project that needs deploy.sql alone.
+func validateEntrypoint(fileScanner pgmi.FileScanner, sourcePath, entry string) error {
+	if es, ok := fileScanner.(pgmi.EntrypointScanner); ok {
+		return es.ValidateEntrypoint(sourcePath, entry)
+	}
+	if entry != pgmi.DefaultEntrypoint {
+		return errDeploySQLOnly("file scanner", fileScanner)
+	}
+	return fileScanner.ValidateDeploySQL(sourcePath)
+}
+
+func scanDirectory(fileScanner pgmi.FileScanner, sourcePath string, entrypoints []string) (pgmi.FileScanResult, error) {
+	if es, ok := fileScanner.(pgmi.EntrypointScanner); ok {
+		return es.ScanDirectoryExcluding(sourcePath, entrypoints...)
+	}
+	if len(entrypoints) > 0 {
+		return pgmi.FileScanResult{}, errDeploySQLOnly("file scanner", fileScanner)
+	}
+	return fileScanner.ScanDirectory(sourcePath)
+}
+
+func readEntrypoint(fileScanner pgmi.FileScanner, sourcePath, entry string) (string, error) {
+	if es, ok := fileScanner.(pgmi.EntrypointScanner); ok {
+		return es.ReadEntrypoint(sourcePath, entry)
+	}
+	if entry != pgmi.DefaultEntrypoint {
+		return "", errDeploySQLOnly("file scanner", fileScanner)
+	}
+	return fileScanner.ReadDeploySQL(sourcePath)
+}
+
+func errDeploySQLOnly(role string, impl any) error {
+	return fmt.Errorf("%w: %s %T does not support entrypoints other than %s",
+		pgmi.ErrInvalidConfig, role, impl, pgmi.DefaultEntrypoint)
+}
+
+// sessionConn is the connection a session is being prepared on.
+type sessionConn struct {
+	conn *pgx.Conn
+	// pooled is the pool connection conn belongs to; nil when borrowed.
+	pooled *pgxpool.Conn
+	// session wraps the connection once preparation has succeeded; abandon
+	// gives it up after a failure, leaving the advisory lock to the caller.
+	session func() *pgmi.Session
+	abandon func()
+	// changed holds the settings set changed on a borrowed connection, with
+	// the values to put back.
+	changed [][2]string
+}
+
+// set changes a setting for the rest of the session. A borrowed connection
+// gets its previous value back when the session ends; a pooled one is
+// discarded with its pool.
+func (sc *sessionConn) set(ctx context.Context, name, value string) error {
+	if sc.pooled == nil {
+		var previous string
+		if err := sc.conn.QueryRow(ctx, "SELECT current_setting($1)", name).Scan(&previous); err != nil {
+			return err
+		}
+		sc.changed = append(sc.changed, [2]string{name, previous})
+	}
+	_, err := sc.conn.Exec(ctx, "SELECT set_config($1, $2, false)", name, value)
+	return err
+}
+
+// restoreSettings puts back what set changed, latest first.
+func (sc *sessionConn) restoreSettings() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for i := len(sc.changed) - 1; i >= 0; i-- {
+		_, _ = sc.conn.Exec(ctx, "SELECT set_config($1, $2, false)", sc.changed[i][0], sc.changed[i][1])
+	}
+	sc.changed = nil
+}
+
+// acquireSessionConn connects to the target database and acquires the single
+// connection the session runs on. A pgmi.BorrowedConnector supplies that
+// connection itself: it is used as it is and never closed, only reset.
+func (sm *SessionManager) acquireSessionConn(
 	ctx context.Context,
 	connConfig *pgmi.ConnectionConfig,
-) (*pgxpool.Pool, func(), error) {
+) (*sessionConn, error) {
 	sm.logger.Verbose("Connecting to database %q", connConfig.Database)
 
 	connector, err := sm.connectorFactory(connConfig)
 	if err != nil {
-		return nil, func() {}, fmt.Errorf("failed to create connector: %w", err)
+		return nil, fmt.Errorf("failed to create connector: %w", err)
+	}
+	connectorCleanup := func() { closeConnector(connector) }
+
+	if borrowed, ok := connector.(pgmi.BorrowedConnector); ok {
+		if _, ok := sm.fileLoader.(connLoader); !ok {
+			connectorCleanup()
+			return nil, fmt.Errorf("%w: file loader %T cannot load into a borrowed connection", pgmi.ErrInvalidConfig, sm.fileLoader)
+		}
+		conn := borrowed.BorrowedConn()
+		if conn.IsClosed() {
+			connectorCleanup()
+			return nil, fmt.Errorf("%w: the connection lent to pgmi is closed", pgmi.ErrConnectionFailed)
+		}
+		// deploy.sql owns transaction control, and pgmi_test() rolls back to
+		// savepoints; neither works inside a transaction the caller left open.
+		if conn.PgConn().TxStatus() != 'I' {
+			connectorCleanup()
+			return nil, fmt.Errorf("%w: the connection lent to pgmi is inside a transaction; commit or roll it back first", pgmi.ErrInvalidConfig)
+		}
+		sc := &sessionConn{conn: conn}
+		sc.session = func() *pgmi.Session {
+			return pgmi.NewBorrowedSession(conn, func() {
+				sc.restoreSettings()
+				connectorCleanup()
+			})
+		}
+		sc.abandon = func() {
+			resetCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			pgmi.ResetBorrowedConn(resetCtx, conn)
+			cancel()
+			sc.restoreSettings()
+			connectorCleanup()
+		}
+		return sc, nil
 	}
 
 	pool, err := connector.Connect(ctx)
 	if err != nil {
-		closeConnector(connector)
-		return nil, func() {}, fmt.Errorf("failed to connect to database %q: %w", connConfig.Database, err)
+		connectorCleanup()
+		return nil, fmt.Errorf("failed to connect to database %q: %w", connConfig.Database, err)
 	}
 
-	return pool, func() { closeConnector(connector) }, nil
+	// Acquire a single connection for the entire session
+	// This is critical because pg_temp tables are session-scoped
+	pooled, err := pool.Acquire(ctx)
+	if err != nil {
+		pool.Close()
+		connectorCleanup()
+		return nil, fmt.Errorf("failed to acquire connection: %w", err)
+	}
+	return &sessionConn{
+		conn:    pooled.Conn(),
+		pooled:  pooled,
+		session: func() *pgmi.Session { return pgmi.NewSession(pool, pooled, connectorCleanup) },
+		abandon: func() {
+			pooled.Release()
+			pool.Close()
+			connectorCleanup()
+		},
+	}, nil
 }
 
 // closeConnector closes connectors that implement io.Closer (e.g., GoogleCloudSQLConnector).
@@ -285,24 +478,29 @@ func closeConnector(c pgmi.Connector) {
 // and loading files and parameters into session-scoped tables.
 func (sm *SessionManager) prepareSessionTables(
 	ctx context.Context,
-	conn *pgxpool.Conn,
+	sc *sessionConn,
 	scanResult *pgmi.FileScanResult,
 	parameters map[string]string,
 	compat string,
 ) error {
+	conn := sc.conn
 	sm.logger.Verbose("Creating pg_temp internal tables")
 	if err := params.CreateSchema(ctx, conn); err != nil {
 		return fmt.Errorf("failed to create internal tables: %w", err)
 	}
 
 	sm.logger.Verbose("Loading files into pg_temp._pgmi_source")
-	if err := sm.fileLoader.LoadFilesIntoSession(ctx, conn, scanResult.Files); err != nil {
+	loadCtx, loadSpan := tracing.Start(ctx, "pgmi.load_files",
+		attribute.Int("pgmi.files", len(scanResult.Files)))
+	err := sm.loadFiles(loadCtx, sc, scanResult.Files)
+	tracing.End(loadSpan, err)
+	if err != nil {
 		return fmt.Errorf("failed to load files: %w", err)
 	}
 	sm.logger.Info("Loaded %d files", len(scanResult.Files))
 
 	sm.logger.Verbose("Loading parameters into pg_temp._pgmi_parameter")
-	if err := sm.fileLoader.LoadParametersIntoSession(ctx, conn, parameters); err != nil {
+	if err := sm.loadParameters(ctx, sc, parameters); err != nil {
 		return fmt.Errorf("failed to load parameters: %w", err)
 	}
 	if len(parameters) > 0 {
@@ -310,7 +508,10 @@ func (sm *SessionManager) prepareSessionTables(
 	}
 
 	sm.logger.Verbose("Applying API contract")
-	appliedVersion, err := contract.Apply(ctx, conn, compat)
+	applyCtx, applySpan := tracing.Start(ctx, "pgmi.contract_apply")
+	appliedVersion, err := contract.Apply(applyCtx, conn, compat)
+	applySpan.SetAttributes(attribute.String("pgmi.contract_version", string(appliedVersion)))
+	tracing.End(applySpan, err)
 	if err != nil {
 		return fmt.Errorf("failed to apply API contract: %w", err)
 	}
@@ -318,3 +519,20 @@ func (sm *SessionManager) prepareSessionTables(
 
 	return nil
 }
+
+// loadFiles loads files through the FileLoader, on the pooled connection or,
+// for a borrowed one, the loader's connLoader side.
+func (sm *SessionManager) loadFiles(ctx context.Context, sc *sessionConn, files []pgmi.FileMetadata) error {
+	if sc.pooled != nil {
+		return sm.fileLoader.LoadFilesIntoSession(ctx, sc.pooled, files)
+	}
+	return sm.fileLoader.(connLoader).LoadFilesIntoConn(ctx, sc.conn, files)
+}
+
+// loadParameters is loadFiles for parameters.
+func (sm *SessionManager) loadParameters(ctx context.Context, sc *sessionConn, params map[string]string) error {
+	if sc.pooled != nil {
+		return sm.fileLoader.LoadParametersIntoSession(ctx, sc.pooled, params)
+	}
+	return sm.fileLoader.(connLoader).LoadParametersIntoConn(ctx, sc.conn, params)
+}
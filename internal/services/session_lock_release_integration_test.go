@@ -4,7 +4,7 @@ import (
 	"context"
 	"testing"
 
-	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jackc/pgx/v5"
 	"github.com/vvka-141/pgmi/internal/checksum"
 	"github.com/vvka-141/pgmi/internal/db"
 	"github.com/vvka-141/pgmi/internal/files/loader"
@@ -22,7 +22,7 @@ func countReleases(t *testing.T, n *int) {
 	t.Helper()
 
 	original := services.ReleaseDeployLock
-	services.ReleaseDeployLock = func(ctx context.Context, conn *pgxpool.Conn) {
+	services.ReleaseDeployLock = func(ctx context.Context, conn *pgx.Conn) {
 		*n++
 		original(ctx, conn)
 	}
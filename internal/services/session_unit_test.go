@@ -46,6 +46,20 @@ func TestValidateNoDuplicateScriptIDs(t *testing.T) {
 	})
 }
 
+// A FileLoader written against the pooled-connection interface alone still
+// serves every session pgmi connects itself; a borrowed connection is refused
+// up front rather than failing halfway through preparation.
+func TestAcquireSessionConn_BorrowedNeedsConnLoader(t *testing.T) {
+	sm := NewSessionManager(func(_ *pgmi.ConnectionConfig) (pgmi.Connector, error) {
+		return &mockBorrowedConnector{}, nil
+	}, &mockFileScanner{}, &mockFileLoader{}, &mockLogger{})
+
+	_, err := sm.acquireSessionConn(context.Background(), &pgmi.ConnectionConfig{Database: "app"})
+	if !errors.Is(err, pgmi.ErrInvalidConfig) || !strings.Contains(err.Error(), "borrowed") {
+		t.Errorf("acquireSessionConn = %v, want ErrInvalidConfig about the borrowed connection", err)
+	}
+}
+
 func TestNewSessionManager_NilDeps(t *testing.T) {
 	connFactory := func(_ *pgmi.ConnectionConfig) (pgmi.Connector, error) {
 		return &mockConnector{}, nil
@@ -97,6 +111,78 @@ func TestScanProject_ValidateDeploySQLFails(t *testing.T) {
 	}
 }
 
+func TestScanProject_ExcludesEveryEntrypoint(t *testing.T) {
+	connFactory := func(_ *pgmi.ConnectionConfig) (pgmi.Connector, error) {
+		return &mockConnector{}, nil
+	}
+	scanner := &mockFileScanner{}
+	sm := NewSessionManager(connFactory, scanner, &mockFileLoader{}, &mockLogger{})
+
+	if _, err := sm.ScanProjectForEntry("/src", "./ops/reindex.sql", "ops/reindex.sql", "ops/vacuum.sql"); err != nil {
+		t.Fatalf("ScanProjectForEntry failed: %v", err)
+	}
+	if scanner.validateEntry != "ops/reindex.sql" {
+		t.Errorf("validated %q, want the normalized entry ops/reindex.sql", scanner.validateEntry)
+	}
+	want := []string{"ops/reindex.sql", "ops/reindex.sql", "ops/vacuum.sql"}
+	if strings.Join(scanner.excluded, ",") != strings.Join(want, ",") {
+		t.Errorf("excluded %v, want %v", scanner.excluded, want)
+	}
+}
+
+// deploySQLScanner is a pgmi.FileScanner written before entrypoints: it
+// has none of the pgmi.EntrypointScanner methods.
+type deploySQLScanner struct{}
+
+func (deploySQLScanner) ScanDirectory(string) (pgmi.FileScanResult, error) {
+	return pgmi.FileScanResult{}, nil
+}
+func (deploySQLScanner) ValidateDeploySQL(string) error { return nil }
+func (deploySQLScanner) ReadDeploySQL(string) (string, error) {
+	return "SELECT 1;", nil
+}
+
+func TestScanProject_DeploySQLOnlyScanner(t *testing.T) {
+	connFactory := func(_ *pgmi.ConnectionConfig) (pgmi.Connector, error) {
+		return &mockConnector{}, nil
+	}
+	sm := NewSessionManager(connFactory, deploySQLScanner{}, &mockFileLoader{}, &mockLogger{})
+
+	if _, err := sm.ScanProject("/src"); err != nil {
+		t.Errorf("ScanProject: %v", err)
+	}
+	if content, err := readEntrypoint(deploySQLScanner{}, "/src", pgmi.DefaultEntrypoint); err != nil || content != "SELECT 1;" {
+		t.Errorf("readEntrypoint(deploy.sql) = %q, %v", content, err)
+	}
+	if _, err := sm.ScanProjectForEntry("/src", "ops/reindex.sql"); !errors.Is(err, pgmi.ErrInvalidConfig) {
+		t.Errorf("another entry: expected ErrInvalidConfig, got %v", err)
+	}
+	if _, err := sm.ScanProjectForEntry("/src", "", "ops/vacuum.sql"); !errors.Is(err, pgmi.ErrInvalidConfig) {
+		t.Errorf("declared entrypoints: expected ErrInvalidConfig, got %v", err)
+	}
+}
+
+func TestScanProject_RejectsDependencyCycle(t *testing.T) {
+	connFactory := func(_ *pgmi.ConnectionConfig) (pgmi.Connector, error) {
+		return &mockConnector{}, nil
+	}
+	script := func(path, id, dependsOn string) pgmi.FileMetadata {
+		return pgmi.FileMetadata{Path: path, Extension: ".sql", Metadata: &pgmi.ScriptMetadata{
+			ID: uuid.MustParse(id), Idempotent: true, DependsOn: []string{dependsOn},
+		}}
+	}
+	scanner := &mockFileScanner{scanResult: pgmi.FileScanResult{Files: []pgmi.FileMetadata{
+		script("./a.sql", "11111111-1111-4111-8111-111111111111", "./b.sql"),
+		script("./b.sql", "22222222-2222-4222-8222-222222222222", "11111111-1111-4111-8111-111111111111"),
+	}}}
+	sm := NewSessionManager(connFactory, scanner, &mockFileLoader{}, &mockLogger{})
+
+	_, err := sm.ScanProject("/src")
+	if !errors.Is(err, pgmi.ErrInvalidConfig) || !strings.Contains(err.Error(), "dependsOn cycle: ./a.sql -> ./b.sql -> ./a.sql") {
+		t.Errorf("expected the cycle as ErrInvalidConfig, got: %v", err)
+	}
+}
+
 func TestScanProject_ScanDirectoryFails(t *testing.T) {
 	connFactory := func(_ *pgmi.ConnectionConfig) (pgmi.Connector, error) {
 		return &mockConnector{}, nil
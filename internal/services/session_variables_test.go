@@ -6,6 +6,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/stretchr/testify/require"
 	"github.com/vvka-141/pgmi/internal/checksum"
@@ -30,7 +31,7 @@ func TestSessionVariableSystem(t *testing.T) {
 		cliParams     map[string]string
 		expectError   bool
 		errorContains string
-		verifyFunc    func(t *testing.T, conn *pgxpool.Conn)
+		verifyFunc    func(t *testing.T, conn *pgx.Conn)
 	}{
 		{
 			name: "CLI parameters become session variables",
@@ -43,7 +44,7 @@ func TestSessionVariableSystem(t *testing.T) {
 				"db_name": "myapp",
 			},
 			expectError: false,
-			verifyFunc: func(t *testing.T, conn *pgxpool.Conn) {
+			verifyFunc: func(t *testing.T, conn *pgx.Conn) {
 				var envValue, dbNameValue string
 				err := conn.QueryRow(context.Background(),
 					`SELECT
@@ -65,7 +66,7 @@ func TestSessionVariableSystem(t *testing.T) {
 				"api_port": "8080",
 			},
 			expectError: false,
-			verifyFunc: func(t *testing.T, conn *pgxpool.Conn) {
+			verifyFunc: func(t *testing.T, conn *pgx.Conn) {
 				var count int
 				err := conn.QueryRow(context.Background(),
 					`SELECT COUNT(*) FROM pg_temp.pgmi_parameter_view WHERE key IN ('env', 'api_port')`).Scan(&count)
@@ -87,7 +88,7 @@ func TestSessionVariableSystem(t *testing.T) {
 			deploySQL:   `SELECT 1;`,
 			cliParams:   map[string]string{},
 			expectError: false,
-			verifyFunc: func(t *testing.T, conn *pgxpool.Conn) {
+			verifyFunc: func(t *testing.T, conn *pgx.Conn) {
 				var missingValue *string
 				err := conn.QueryRow(context.Background(),
 					`SELECT current_setting('pgmi.nonexistent', true)`).Scan(&missingValue)
@@ -103,7 +104,7 @@ func TestSessionVariableSystem(t *testing.T) {
 			deploySQL:   `SELECT 1;`,
 			cliParams:   map[string]string{},
 			expectError: false,
-			verifyFunc: func(t *testing.T, conn *pgxpool.Conn) {
+			verifyFunc: func(t *testing.T, conn *pgx.Conn) {
 				var value string
 				err := conn.QueryRow(context.Background(),
 					`SELECT COALESCE(current_setting('pgmi.env', true), 'development')`).Scan(&value)
@@ -121,7 +122,7 @@ func TestSessionVariableSystem(t *testing.T) {
 				"env": "production",
 			},
 			expectError: false,
-			verifyFunc: func(t *testing.T, conn *pgxpool.Conn) {
+			verifyFunc: func(t *testing.T, conn *pgx.Conn) {
 				var value string
 				err := conn.QueryRow(context.Background(),
 					`SELECT COALESCE(current_setting('pgmi.env', true), 'development')`).Scan(&value)
@@ -154,9 +155,10 @@ func TestSessionVariableSystem(t *testing.T) {
 			require.NoError(t, err)
 			defer pool.Close()
 
-			conn, err := pool.Acquire(ctx)
+			pooled, err := pool.Acquire(ctx)
 			require.NoError(t, err)
-			defer conn.Release()
+			defer pooled.Release()
+			conn := pooled.Conn()
 
 			err = createSchemaAndLoadSessionVariables(ctx, conn, scanResult.Files, tt.cliParams)
 			require.NoError(t, err)
@@ -180,18 +182,18 @@ func TestSessionVariableSystem(t *testing.T) {
 	}
 }
 
-func createSchemaAndLoadSessionVariables(ctx context.Context, conn *pgxpool.Conn, filesList []pgmi.FileMetadata, cliParams map[string]string) error {
+func createSchemaAndLoadSessionVariables(ctx context.Context, conn *pgx.Conn, filesList []pgmi.FileMetadata, cliParams map[string]string) error {
 	if err := params.CreateSchema(ctx, conn); err != nil {
 		return err
 	}
 
 	fileLoader := loader.NewLoader()
 
-	if err := fileLoader.LoadFilesIntoSession(ctx, conn, filesList); err != nil {
+	if err := fileLoader.LoadFilesIntoConn(ctx, conn, filesList); err != nil {
 		return err
 	}
 
-	if err := fileLoader.LoadParametersIntoSession(ctx, conn, cliParams); err != nil {
+	if err := fileLoader.LoadParametersIntoConn(ctx, conn, cliParams); err != nil {
 		return err
 	}
 
@@ -0,0 +1,276 @@
+package services
+
+import (
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/vvka-141/pgmi/internal/checksum"
+	"github.com/vvka-141/pgmi/internal/contract"
+	"github.com/vvka-141/pgmi/internal/db/manager"
+	"github.com/vvka-141/pgmi/pkg/pgmi"
+)
+
+// TemplateCommentPrefix starts the pg_database comment of every template
+// database pgmi builds; the template key follows it. It is also how pgmi tells
+// its own template from an unrelated database that happens to have the name,
+// which it refuses to drop.
+const TemplateCommentPrefix = "pgmi template "
+
+// templateKey identifies what a template database was built from: every
+// file's pgmi_checksum and <pgmi-meta>, the orchestrator script, the
+// parameters and the resolved compat level. Any of them changing can change what deploy.sql
+// leaves behind, so any of them changing rebuilds the template.
+//
+// Files use the normalized checksum, as _pgmi_source.pgmi_checksum does, so
+// reformatting a file or editing a comment does not cost a rebuild.
+func templateKey(files []pgmi.FileMetadata, entry, entrySQL string, params map[string]string, compat string) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "compat %s\n", compat)
+	fmt.Fprintf(h, "entry %s %s\n", entry, checksum.New().CalculateNormalized([]byte(entrySQL)))
+
+	sorted := slices.Clone(files)
+	slices.SortFunc(sorted, func(a, b pgmi.FileMetadata) int { return cmp.Compare(a.Path, b.Path) })
+	for _, f := range sorted {
+		fmt.Fprintf(h, "file %q %s\n", f.Path, f.Checksum)
+		// The normalized checksum ignores comments, and <pgmi-meta> lives
+		// in one: a changed sort key or custom attribute changes what
+		// deploy.sql does without changing the checksum.
+		if f.Metadata != nil {
+			meta, _ := json.Marshal(f.Metadata)
+			fmt.Fprintf(h, "meta %q %s\n", f.Path, meta)
+		}
+	}
+
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	for _, k := range keys {
+		fmt.Fprintf(h, "param %q %q\n", k, params[k])
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// templateIsCurrent reports whether settings describe a finished template
+// built from key. A database pgmi created but never marked IS_TEMPLATE is a
+// build that failed part-way, and is rebuilt like a stale one.
+func templateIsCurrent(settings *pgmi.DatabaseSettings, key string) bool {
+	return settings != nil && settings.IsTemplate &&
+		settings.Comment != nil && *settings.Comment == TemplateCommentPrefix+key
+}
+
+// isPgmiTemplate reports whether settings describe a database pgmi created as
+// a template, current or not.
+func isPgmiTemplate(settings *pgmi.DatabaseSettings) bool {
+	return settings.Comment != nil && strings.HasPrefix(*settings.Comment, TemplateCommentPrefix)
+}
+
+// templateManager is what a TemplateDatabase deploy needs of the
+// pgmi.DatabaseManager beyond that interface. manager.Manager has it.
+type templateManager interface {
+	// SetTemplate sets or clears IS_TEMPLATE on the specified database.
+	SetTemplate(ctx context.Context, conn pgmi.DBConnection, dbName string, isTemplate bool) error
+}
+
+var _ templateManager = (*manager.Manager)(nil)
+
+// deployViaTemplate creates config.DatabaseName as a copy of
+// config.TemplateDatabase, deploying into the template first when it is
+// missing or was built from anything other than the current project.
+//
+// CREATE DATABASE ... TEMPLATE copies files rather than replaying SQL, so a
+// job that would otherwise run the full deploy gets its database in the time
+// it takes to copy it. Everything happens under an advisory lock on the
+// maintenance database: parallel jobs that find the template stale wait for
+// one of them to rebuild it instead of all rebuilding it at once.
+func (s *DeploymentService) deployViaTemplate(ctx context.Context, connConfig *pgmi.ConnectionConfig, config pgmi.DeploymentConfig, scanResult pgmi.FileScanResult) error {
+	tmpl, target := config.TemplateDatabase, config.DatabaseName
+	s.lastResult.Template = tmpl
+	tm, ok := s.dbManager.(templateManager)
+	if !ok {
+		return fmt.Errorf("%w: database manager %T cannot mark template databases; deploy without a template database",
+			pgmi.ErrInvalidConfig, s.dbManager)
+	}
+
+	mgmtDB := config.MaintenanceDatabase
+	if mgmtDB == "" {
+		mgmtDB = pgmi.DefaultMaintenanceDB
+	}
+	if strings.EqualFold(tmpl, mgmtDB) {
+		return fmt.Errorf("cannot use maintenance database %q as the template\npgmi connects to it for CREATE/DROP DATABASE; pick a different --template-db: %w", tmpl, pgmi.ErrInvalidConfig)
+	}
+	if config.Overwrite {
+		if err := validateOverwriteTarget(target, mgmtDB); err != nil {
+			return err
+		}
+	}
+
+	entry := pgmi.NormalizeEntrypoint(config.Entry)
+	entrySQL, err := readEntrypoint(s.fileScanner, config.SourcePath, entry)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", entry, err)
+	}
+	_, compat, _ := contract.Load(config.Compat) // already validated by Deploy
+	key := templateKey(scanResult.Files, entry, entrySQL, config.Parameters, string(compat))
+	s.logger.Verbose("Template key %.12s", key)
+
+	dbConn, cleanup, err := s.connectMaintenance(ctx, connConfig, mgmtDB)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	unlock, err := lockTemplate(ctx, dbConn, tmpl)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	// Ask before building anything: a denied overwrite should not first spend
+	// the full deploy on a template.
+	targetExists, err := s.dbManager.Exists(ctx, dbConn, target)
+	if err != nil {
+		return err // manager.Exists already names the operation and database
+	}
+	if targetExists {
+		if !config.Overwrite {
+			return fmt.Errorf("database %q already exists; a clone needs a fresh target, pass --overwrite to replace it: %w", target, pgmi.ErrInvalidConfig)
+		}
+		s.logger.Verbose("Database %q exists; requesting approval", target)
+		approved, err := s.approver.RequestApproval(ctx, target)
+		if err != nil {
+			return fmt.Errorf("approval request failed: %w", err)
+		}
+		if !approved {
+			return pgmi.ErrApprovalDenied
+		}
+	}
+
+	settings, err := s.dbManager.Settings(ctx, dbConn, tmpl)
+	if err != nil {
+		return err
+	}
+	if templateIsCurrent(settings, key) {
+		s.logger.Info("Template %q is current", tmpl)
+	} else {
+		if err := s.rebuildTemplate(ctx, tm, connConfig, dbConn, config, scanResult, settings, key); err != nil {
+			return err
+		}
+		s.lastResult.TemplateRebuilt = true
+	}
+
+	// PostgreSQL refuses to copy a database anyone is connected to. The
+	// template belongs to pgmi, so a session left open on it — a psql someone
+	// used to look around — is ended rather than allowed to fail every clone.
+	if err := s.dbManager.TerminateConnections(ctx, dbConn, tmpl); err != nil {
+		return err
+	}
+
+	clone := &pgmi.DatabaseSettings{ConnectionLimit: -1}
+	if targetExists {
+		// As with a plain --overwrite, the owner, connection limit, options
+		// and comment are read before the drop and given to the clone. The
+		// encoding and locale are not: a clone takes the template's.
+		previous, err := s.dbManager.Settings(ctx, dbConn, target)
+		if err != nil {
+			return err
+		}
+		if previous != nil {
+			clone = previous
+		}
+		s.logger.Verbose("Terminating connections to %q", target)
+		if err := s.dbManager.TerminateConnections(ctx, dbConn, target); err != nil {
+			return err
+		}
+		s.logger.Verbose("DROP DATABASE %q", target)
+		if err := s.dbManager.Drop(ctx, dbConn, target); err != nil {
+			return err
+		}
+	}
+
+	s.logger.Verbose("CREATE DATABASE %q TEMPLATE %q", target, tmpl)
+	clone.Template = tmpl
+	if err := s.dbManager.Create(ctx, dbConn, target, clone); err != nil {
+		return classifyCreateFailure(err)
+	}
+	s.logger.Info("Created database %q from template %q", target, tmpl)
+	return nil
+}
+
+// rebuildTemplate replaces whatever settings describe with a template freshly
+// deployed from the project. The key is written with the database but the
+// template only counts as built once IS_TEMPLATE is set, after the deploy
+// succeeds, so a failed build is never cloned.
+func (s *DeploymentService) rebuildTemplate(
+	ctx context.Context,
+	tm templateManager,
+	connConfig *pgmi.ConnectionConfig,
+	dbConn pgmi.DBConnection,
+	config pgmi.DeploymentConfig,
+	scanResult pgmi.FileScanResult,
+	settings *pgmi.DatabaseSettings,
+	key string,
+) error {
+	tmpl := config.TemplateDatabase
+	if settings != nil {
+		if !isPgmiTemplate(settings) {
+			return fmt.Errorf("database %q exists and is not a pgmi template; pgmi only rebuilds templates it created\npick a different --template-db or drop it yourself: %w", tmpl, pgmi.ErrInvalidConfig)
+		}
+		s.logger.Info("Template %q is out of date; rebuilding", tmpl)
+		if settings.IsTemplate {
+			if err := tm.SetTemplate(ctx, dbConn, tmpl, false); err != nil {
+				return err
+			}
+		}
+		if err := s.dbManager.TerminateConnections(ctx, dbConn, tmpl); err != nil {
+			return err
+		}
+		if err := s.dbManager.Drop(ctx, dbConn, tmpl); err != nil {
+			return err
+		}
+	} else {
+		s.logger.Info("Template %q does not exist; building it", tmpl)
+	}
+
+	comment := TemplateCommentPrefix + key
+	if err := s.dbManager.Create(ctx, dbConn, tmpl, &pgmi.DatabaseSettings{ConnectionLimit: -1, Comment: &comment}); err != nil {
+		return classifyCreateFailure(err)
+	}
+
+	if err := s.runSession(ctx, connConfig, tmpl, scanResult, config); err != nil {
+		return fmt.Errorf("deploying into template %q failed: %w", tmpl, err)
+	}
+
+	return tm.SetTemplate(ctx, dbConn, tmpl, true)
+}
+
+// lockTemplate serializes template builds and clones across pgmi runs with a
+// session-level advisory lock held on a dedicated maintenance connection. It
+// waits rather than failing: a job arriving while another rebuilds the
+// template only has to wait for the rebuild to be able to clone.
+func lockTemplate(ctx context.Context, dbConn pgmi.DBConnection, tmpl string) (func(), error) {
+	conn, err := dbConn.Acquire(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to acquire connection: %w", err)
+	}
+	if _, err := conn.Exec(ctx, `SELECT pg_advisory_lock(hashtextextended('pgmi.template.' || $1, 0))`, tmpl); err != nil {
+		conn.Release()
+		return nil, fmt.Errorf("failed to lock template %q: %w", tmpl, err)
+	}
+	return func() {
+		// context.Background(): a cancelled ctx would make the unlock a no-op
+		// and return a connection still holding the lock to the pool.
+		unlockCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_, _ = conn.Exec(unlockCtx, `SELECT pg_advisory_unlock(hashtextextended('pgmi.template.' || $1, 0))`, tmpl)
+		conn.Release()
+	}, nil
+}
@@ -0,0 +1,114 @@
+package services_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/vvka-141/pgmi/internal/services"
+	testhelpers "github.com/vvka-141/pgmi/internal/testing"
+	"github.com/vvka-141/pgmi/pkg/pgmi"
+)
+
+// A template is deployed once and cloned after that; editing a project file
+// rebuilds it, and clones carry what the deploy left behind.
+func TestDeploymentService_Deploy_ViaTemplate(t *testing.T) {
+	connString := testhelpers.RequireDatabase(t)
+	ctx := context.Background()
+
+	const tmpl = "pgmi_itest_template"
+	jobs := []string{"pgmi_itest_template_job1", "pgmi_itest_template_job2", "pgmi_itest_template_job3"}
+
+	admin := testhelpers.GetTestPool(t, connString, "postgres")
+	dropTemplate := func() {
+		// PostgreSQL refuses to drop a database still marked IS_TEMPLATE.
+		_, _ = admin.Exec(ctx, fmt.Sprintf("ALTER DATABASE %s WITH IS_TEMPLATE false", pgx.Identifier{tmpl}.Sanitize()))
+		testhelpers.CleanupTestDB(t, connString, tmpl)
+	}
+	dropTemplate()
+	defer dropTemplate()
+	for _, job := range jobs {
+		testhelpers.CleanupTestDB(t, connString, job)
+		defer testhelpers.CleanupTestDB(t, connString, job)
+	}
+
+	projectPath := t.TempDir()
+	write := func(rel, content string) {
+		path := filepath.Join(projectPath, rel)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("write %s: %v", rel, err)
+		}
+	}
+	write("deploy.sql", `DO $$ DECLARE f record; BEGIN
+    FOR f IN SELECT content FROM pg_temp.pgmi_source_view ORDER BY path LOOP
+        EXECUTE f.content;
+    END LOOP;
+END $$;`)
+	write("schema/marker.sql", "CREATE TABLE marker AS SELECT 'v1'::text AS version;\n")
+
+	deployer := testhelpers.NewTestDeployer(t)
+	deploy := func(job string) *services.DeployResult {
+		t.Helper()
+		err := deployer.Deploy(ctx, pgmi.DeploymentConfig{
+			ConnectionString:    connString,
+			MaintenanceDatabase: "postgres",
+			DatabaseName:        job,
+			TemplateDatabase:    tmpl,
+			SourcePath:          projectPath,
+			Verbose:             testing.Verbose(),
+		})
+		if err != nil {
+			t.Fatalf("deploy %s: %v", job, err)
+		}
+		return deployer.(*services.DeploymentService).LastResult()
+	}
+	marker := func(job string) string {
+		t.Helper()
+		pool := testhelpers.GetTestPool(t, connString, job)
+		defer pool.Close()
+		var v string
+		if err := pool.QueryRow(ctx, "SELECT version FROM marker").Scan(&v); err != nil {
+			t.Fatalf("read marker in %s: %v", job, err)
+		}
+		return v
+	}
+
+	if r := deploy(jobs[0]); !r.TemplateRebuilt {
+		t.Error("first deploy should have built the template")
+	}
+	if r := deploy(jobs[1]); r.TemplateRebuilt || r.FilesLoaded != 0 {
+		t.Errorf("second deploy rebuilt the template or loaded files: %+v", *r)
+	}
+	if got := marker(jobs[1]); got != "v1" {
+		t.Errorf("clone marker = %q, want v1", got)
+	}
+
+	write("schema/marker.sql", "CREATE TABLE marker AS SELECT 'v2'::text AS version;\n")
+	if r := deploy(jobs[2]); !r.TemplateRebuilt {
+		t.Error("an edited file should rebuild the template")
+	}
+	if got := marker(jobs[2]); got != "v2" {
+		t.Errorf("clone marker after the edit = %q, want v2", got)
+	}
+
+	var isTemplate bool
+	var comment string
+	if err := admin.QueryRow(ctx,
+		`SELECT datistemplate, shobj_description(oid, 'pg_database') FROM pg_database WHERE datname = $1`, tmpl,
+	).Scan(&isTemplate, &comment); err != nil {
+		t.Fatalf("read template: %v", err)
+	}
+	if !isTemplate {
+		t.Error("the template database is not marked IS_TEMPLATE")
+	}
+	if !strings.HasPrefix(comment, services.TemplateCommentPrefix) {
+		t.Errorf("template comment = %q, want it to carry the template key", comment)
+	}
+}
@@ -0,0 +1,236 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/vvka-141/pgmi/internal/contract"
+	"github.com/vvka-141/pgmi/pkg/pgmi"
+)
+
+func TestTemplateKey(t *testing.T) {
+	files := []pgmi.FileMetadata{
+		{Path: "./schemas/users.sql", Checksum: "aaa"},
+		{Path: "./schemas/orders.sql", Checksum: "bbb"},
+	}
+	params := map[string]string{"env": "test", "region": "eu"}
+	base := templateKey(files, "deploy.sql", "SELECT 1;", params, "2")
+
+	reordered := []pgmi.FileMetadata{files[1], files[0]}
+	if got := templateKey(reordered, "deploy.sql", "SELECT 1;", params, "2"); got != base {
+		t.Error("the key must not depend on scan order")
+	}
+	if got := templateKey(files, "deploy.sql", "-- reworded\nSELECT   1;", params, "2"); got != base {
+		t.Error("a comment or whitespace edit to the entry script should not rebuild the template")
+	}
+
+	changed := map[string]string{
+		"file checksum": templateKey([]pgmi.FileMetadata{{Path: "./schemas/users.sql", Checksum: "ccc"}, files[1]}, "deploy.sql", "SELECT 1;", params, "2"),
+		"file removed":  templateKey(files[:1], "deploy.sql", "SELECT 1;", params, "2"),
+		"entry script":  templateKey(files, "deploy.sql", "SELECT 2;", params, "2"),
+		"entry path":    templateKey(files, "ops/seed.sql", "SELECT 1;", params, "2"),
+		"parameter":     templateKey(files, "deploy.sql", "SELECT 1;", map[string]string{"env": "prod", "region": "eu"}, "2"),
+		"compat":        templateKey(files, "deploy.sql", "SELECT 1;", params, "1"),
+		"custom attribute": templateKey([]pgmi.FileMetadata{{Path: "./schemas/users.sql", Checksum: "aaa", Metadata: &pgmi.ScriptMetadata{
+			Custom: map[string]map[string]any{"ops": {"owner": "payments"}},
+		}}, files[1]}, "deploy.sql", "SELECT 1;", params, "2"),
+	}
+	for what, key := range changed {
+		if key == base {
+			t.Errorf("changing the %s left the template key unchanged", what)
+		}
+	}
+}
+
+func templateConfig() pgmi.DeploymentConfig {
+	cfg := validConfig()
+	cfg.DatabaseName = "job_1"
+	cfg.TemplateDatabase = "app_tpl"
+	return cfg
+}
+
+// currentTemplate describes app_tpl as finished and built from what
+// templateConfig deploys: no files, an empty deploy.sql, the latest compat.
+func currentTemplate() *pgmi.DatabaseSettings {
+	comment := TemplateCommentPrefix + templateKey(nil, pgmi.DefaultEntrypoint, "", nil, string(contract.Latest))
+	return &pgmi.DatabaseSettings{IsTemplate: true, Comment: &comment}
+}
+
+func TestDeploy_CurrentTemplateIsClonedWithoutDeploying(t *testing.T) {
+	dbMgr := &mockDatabaseManager{settingsFor: map[string]*pgmi.DatabaseSettings{"app_tpl": currentTemplate()}}
+	// A session would mean the template was rebuilt; make one fail loudly.
+	sess := &mockSessionPreparer{err: errMockStop}
+	svc := newTestService(dbMgr, nil, sess, successfulMgmtConn())
+
+	if err := svc.Deploy(context.Background(), templateConfig()); err != nil {
+		t.Fatalf("Deploy: %v", err)
+	}
+	if !slices.Equal(dbMgr.created, []string{"job_1"}) {
+		t.Errorf("created %v, want only the clone", dbMgr.created)
+	}
+	if dbMgr.createdWith == nil || dbMgr.createdWith.Template != "app_tpl" {
+		t.Errorf("clone created with %+v, want TEMPLATE app_tpl", dbMgr.createdWith)
+	}
+	if len(dbMgr.dropped) > 0 || len(dbMgr.templated) > 0 {
+		t.Errorf("a current template was touched: dropped %v, IS_TEMPLATE %v", dbMgr.dropped, dbMgr.templated)
+	}
+	if r := svc.LastResult(); r.Template != "app_tpl" || r.TemplateRebuilt {
+		t.Errorf("result = %+v, want Template app_tpl, not rebuilt", *r)
+	}
+}
+
+func TestDeploy_StaleTemplateIsRebuiltBeforeCloning(t *testing.T) {
+	stale := "pgmi template 0000"
+	dbMgr := &mockDatabaseManager{settingsFor: map[string]*pgmi.DatabaseSettings{
+		"app_tpl": {IsTemplate: true, Comment: &stale},
+	}}
+	sess := &mockSessionPreparer{err: errMockStop}
+	svc := newTestService(dbMgr, nil, sess, successfulMgmtConn())
+
+	err := svc.Deploy(context.Background(), templateConfig())
+	if !errors.Is(err, errMockStop) {
+		t.Fatalf("expected the template deploy to run and fail, got %v", err)
+	}
+	if !slices.Equal(dbMgr.templated, []string{"app_tpl=false"}) {
+		t.Errorf("IS_TEMPLATE changes = %v; a failed build must never be marked a template", dbMgr.templated)
+	}
+	if !slices.Equal(dbMgr.dropped, []string{"app_tpl"}) {
+		t.Errorf("dropped %v, want the stale template", dbMgr.dropped)
+	}
+	if !slices.Equal(dbMgr.created, []string{"app_tpl"}) {
+		t.Errorf("created %v, want the template and no clone of a failed build", dbMgr.created)
+	}
+	want := currentTemplate().Comment
+	if dbMgr.createdWith == nil || dbMgr.createdWith.Comment == nil || *dbMgr.createdWith.Comment != *want {
+		t.Errorf("template created with %+v, want comment %q", dbMgr.createdWith, *want)
+	}
+}
+
+func TestDeploy_TemplateRefusesToDropAForeignDatabase(t *testing.T) {
+	theirs := "the reporting replica"
+	dbMgr := &mockDatabaseManager{settingsFor: map[string]*pgmi.DatabaseSettings{
+		"app_tpl": {Comment: &theirs},
+	}}
+	svc := newTestService(dbMgr, nil, nil, successfulMgmtConn())
+
+	err := svc.Deploy(context.Background(), templateConfig())
+	if !errors.Is(err, pgmi.ErrInvalidConfig) {
+		t.Fatalf("expected ErrInvalidConfig, got %v", err)
+	}
+	if len(dbMgr.dropped) > 0 || len(dbMgr.created) > 0 {
+		t.Errorf("dropped %v, created %v; a database pgmi did not create must be left alone", dbMgr.dropped, dbMgr.created)
+	}
+}
+
+func TestDeploy_TemplateCloneNeedsAFreshTarget(t *testing.T) {
+	dbMgr := &mockDatabaseManager{
+		existsFor:   map[string]bool{"job_1": true},
+		settingsFor: map[string]*pgmi.DatabaseSettings{"app_tpl": currentTemplate()},
+	}
+	svc := newTestService(dbMgr, nil, nil, successfulMgmtConn())
+
+	err := svc.Deploy(context.Background(), templateConfig())
+	if !errors.Is(err, pgmi.ErrInvalidConfig) {
+		t.Fatalf("expected ErrInvalidConfig for an existing target, got %v", err)
+	}
+	if len(dbMgr.dropped) > 0 || len(dbMgr.created) > 0 {
+		t.Errorf("dropped %v, created %v without --overwrite", dbMgr.dropped, dbMgr.created)
+	}
+}
+
+func TestDeploy_TemplateOverwriteAsksBeforeBuilding(t *testing.T) {
+	dbMgr := &mockDatabaseManager{existsFor: map[string]bool{"job_1": true}}
+	sess := &mockSessionPreparer{err: errMockStop}
+	svc := newTestService(dbMgr, &mockApprover{approved: false}, sess, successfulMgmtConn())
+
+	cfg := templateConfig()
+	cfg.Overwrite = true
+	err := svc.Deploy(context.Background(), cfg)
+	if !errors.Is(err, pgmi.ErrApprovalDenied) {
+		t.Fatalf("expected ErrApprovalDenied, got %v", err)
+	}
+	if len(dbMgr.created) > 0 || len(dbMgr.dropped) > 0 {
+		t.Errorf("created %v, dropped %v after a denied overwrite", dbMgr.created, dbMgr.dropped)
+	}
+}
+
+func TestDeploy_TemplateOverwriteReplacesTheTarget(t *testing.T) {
+	dbMgr := &mockDatabaseManager{
+		existsFor:   map[string]bool{"job_1": true},
+		settingsFor: map[string]*pgmi.DatabaseSettings{"app_tpl": currentTemplate()},
+	}
+	svc := newTestService(dbMgr, &mockApprover{approved: true}, nil, successfulMgmtConn())
+
+	cfg := templateConfig()
+	cfg.Overwrite = true
+	if err := svc.Deploy(context.Background(), cfg); err != nil {
+		t.Fatalf("Deploy: %v", err)
+	}
+	if !slices.Equal(dbMgr.dropped, []string{"job_1"}) || !slices.Equal(dbMgr.created, []string{"job_1"}) {
+		t.Errorf("dropped %v, created %v; want job_1 replaced by a clone", dbMgr.dropped, dbMgr.created)
+	}
+}
+
+// Replacing the target with a clone must not quietly hand it to whoever ran
+// pgmi, lift its connection limit or lose its comment.
+func TestDeploy_TemplateOverwriteKeepsTheTargetSettings(t *testing.T) {
+	comment := "billing, owned by payments"
+	target := &pgmi.DatabaseSettings{
+		Owner:           "billing_owner",
+		ConnectionLimit: 20,
+		Options:         []string{"statement_timeout=30s"},
+		Comment:         &comment,
+	}
+	dbMgr := &mockDatabaseManager{
+		existsFor:   map[string]bool{"job_1": true},
+		settingsFor: map[string]*pgmi.DatabaseSettings{"app_tpl": currentTemplate(), "job_1": target},
+	}
+	svc := newTestService(dbMgr, &mockApprover{approved: true}, nil, successfulMgmtConn())
+
+	cfg := templateConfig()
+	cfg.Overwrite = true
+	if err := svc.Deploy(context.Background(), cfg); err != nil {
+		t.Fatalf("Deploy: %v", err)
+	}
+	got := dbMgr.createdWith
+	if got == nil || got.Template != "app_tpl" {
+		t.Fatalf("clone created with %+v, want TEMPLATE app_tpl", got)
+	}
+	if got.Owner != "billing_owner" || got.ConnectionLimit != 20 ||
+		!slices.Equal(got.Options, target.Options) || got.Comment == nil || *got.Comment != comment {
+		t.Errorf("clone created with %+v, want the replaced database's owner, limit, options and comment", *got)
+	}
+}
+
+func TestDeploy_TemplateCannotBeTheMaintenanceDatabase(t *testing.T) {
+	dbMgr := &mockDatabaseManager{}
+	svc := newTestService(dbMgr, nil, nil, successfulMgmtConn())
+
+	cfg := templateConfig()
+	cfg.TemplateDatabase = "postgres"
+	err := svc.Deploy(context.Background(), cfg)
+	if !errors.Is(err, pgmi.ErrInvalidConfig) {
+		t.Fatalf("expected ErrInvalidConfig, got %v", err)
+	}
+	if len(dbMgr.dropped) > 0 || len(dbMgr.created) > 0 {
+		t.Errorf("dropped %v, created %v", dbMgr.dropped, dbMgr.created)
+	}
+}
+
+func TestDeploy_TemplateNeedsATemplateManager(t *testing.T) {
+	dbMgr := &mockDatabaseManager{}
+	svc := newTestService(dbMgr, nil, nil, successfulMgmtConn())
+	// Only the pgmi.DatabaseManager methods, as a manager written outside
+	// pgmi would have.
+	svc.dbManager = struct{ pgmi.DatabaseManager }{dbMgr}
+
+	err := svc.Deploy(context.Background(), templateConfig())
+	if !errors.Is(err, pgmi.ErrInvalidConfig) {
+		t.Fatalf("expected ErrInvalidConfig, got %v", err)
+	}
+	if len(dbMgr.created) > 0 {
+		t.Errorf("created %v with a manager that cannot mark templates", dbMgr.created)
+	}
+}
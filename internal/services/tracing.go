@@ -0,0 +1,121 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/vvka-141/pgmi/internal/db"
+	"github.com/vvka-141/pgmi/internal/tracing"
+	"github.com/vvka-141/pgmi/pkg/pgmi"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// execUnit executes one execution unit of the orchestrator script under its
+// own span. While a span is being recorded, the test events the unit raises
+// become spans beneath it.
+func execUnit(ctx context.Context, conn *pgx.Conn, index int, mode, unit string) (err error) {
+	ctx, span := tracing.Start(ctx, "pgmi.execute_unit",
+		attribute.Int("pgmi.unit.index", index),
+		attribute.String("pgmi.unit.mode", mode),
+	)
+	defer func() { tracing.End(span, err) }()
+
+	if span.IsRecording() {
+		tests := &testSpans{ctx: ctx}
+		stop := db.ObserveNotices(conn.PgConn(), tests.observe)
+		defer func() {
+			stop()
+			tests.finish(err)
+		}()
+	}
+
+	_, err = conn.Exec(ctx, unit)
+	return err
+}
+
+// testStepSpans names the span each test event that starts a step opens.
+var testStepSpans = map[string]string{
+	pgmi.TestEventFixtureStart:  "pgmi.fixture",
+	pgmi.TestEventTestStart:     "pgmi.test",
+	pgmi.TestEventTeardownStart: "pgmi.teardown",
+}
+
+// testSpans turns the notices of the default test callback into spans: one
+// per suite, and beneath it one per fixture, test and teardown, each lasting
+// until the next event. A rollback is an event on the suite's span. The
+// callback reports rollbacks and teardowns at DEBUG, so without --verbose a
+// test's span also covers the rollback after it.
+//
+// The unit that failed is left with the step that was running, so the span
+// of the failing test carries the error.
+type testSpans struct {
+	ctx      context.Context // the execution unit's
+	suiteCtx context.Context
+	suite    trace.Span
+	step     trace.Span
+}
+
+func (t *testSpans) observe(message string) {
+	event, path, ok := pgmi.ParseTestEventNotice(message)
+	if !ok {
+		return
+	}
+	t.endStep(nil)
+
+	switch event {
+	case pgmi.TestEventSuiteStart:
+		t.endSuite(nil)
+		t.suiteCtx, t.suite = tracing.Start(t.ctx, "pgmi.test_suite")
+	case pgmi.TestEventSuiteEnd:
+		t.endSuite(nil)
+	case pgmi.TestEventRollback:
+		trace.SpanFromContext(t.parent()).AddEvent("pgmi.rollback",
+			trace.WithAttributes(attribute.String("pgmi.test.path", path)))
+	default:
+		_, t.step = tracing.Start(t.parent(), testStepSpans[event],
+			attribute.String("pgmi.test.path", path))
+	}
+}
+
+// finish ends the spans still open when the unit completed.
+func (t *testSpans) finish(err error) {
+	t.endStep(err)
+	t.endSuite(err)
+}
+
+func (t *testSpans) parent() context.Context {
+	if t.suite != nil {
+		return t.suiteCtx
+	}
+	return t.ctx
+}
+
+func (t *testSpans) endStep(err error) {
+	if t.step != nil {
+		tracing.End(t.step, err)
+		t.step = nil
+	}
+}
+
+func (t *testSpans) endSuite(err error) {
+	if t.suite != nil {
+		tracing.End(t.suite, err)
+		t.suite, t.suiteCtx = nil, nil
+	}
+}
+
+// setTraceID publishes the id of the trace the deployment is recorded in as
+// pgmi.trace_id, so the script can log it beside its own output. Nothing is
+// set when no trace is being recorded.
+func setTraceID(ctx context.Context, conn *pgx.Conn) error {
+	id := tracing.TraceID(ctx)
+	if id == "" {
+		return nil
+	}
+	if _, err := conn.Exec(ctx, `SELECT set_config($1, $2, false)`, pgmi.TraceIDSetting, id); err != nil {
+		return fmt.Errorf("failed to set %s: %w", pgmi.TraceIDSetting, err)
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/vvka-141/pgmi/internal/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	nooptrace "go.opentelemetry.io/otel/trace/noop"
+)
+
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+	rec := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec)))
+	t.Cleanup(func() { otel.SetTracerProvider(nooptrace.NewTracerProvider()) })
+	return rec
+}
+
+func TestTestSpans(t *testing.T) {
+	rec := recordSpans(t)
+
+	ctx, unit := tracing.Start(context.Background(), "pgmi.execute_unit")
+	tests := &testSpans{ctx: ctx}
+	for _, msg := range []string{
+		"[pgmi] Test suite started",
+		"[pgmi] Fixture: ./__test__/_setup.sql",
+		"[pgmi] Test: ./__test__/test_a.sql",
+		"[pgmi] Rollback: ./__test__/",
+		"a notice of the test's own",
+		"[pgmi] Test: ./__test__/test_b.sql",
+	} {
+		tests.observe(msg)
+	}
+	tests.finish(errors.New("test_b failed"))
+	unit.End()
+
+	type span struct {
+		name, path, parent string
+		failed             bool
+	}
+	byID := map[string]string{}
+	for _, s := range rec.Ended() {
+		byID[s.SpanContext().SpanID().String()] = s.Name()
+	}
+	var got []span
+	var suiteEvents []string
+	for _, s := range rec.Ended() {
+		sp := span{name: s.Name(), parent: byID[s.Parent().SpanID().String()], failed: s.Status().Code == codes.Error}
+		for _, a := range s.Attributes() {
+			if a.Key == "pgmi.test.path" {
+				sp.path = a.Value.AsString()
+			}
+		}
+		if s.Name() == "pgmi.test_suite" {
+			for _, e := range s.Events() {
+				suiteEvents = append(suiteEvents, e.Name)
+			}
+		}
+		got = append(got, sp)
+	}
+
+	want := []span{
+		{name: "pgmi.fixture", path: "./__test__/_setup.sql", parent: "pgmi.test_suite"},
+		{name: "pgmi.test", path: "./__test__/test_a.sql", parent: "pgmi.test_suite"},
+		{name: "pgmi.test", path: "./__test__/test_b.sql", parent: "pgmi.test_suite", failed: true},
+		{name: "pgmi.test_suite", parent: "pgmi.execute_unit", failed: true},
+		{name: "pgmi.execute_unit"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("spans = %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("span %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+	// Events are recorded on the suite span; the SDK adds one for the error too.
+	if len(suiteEvents) == 0 || suiteEvents[0] != "pgmi.rollback" {
+		t.Errorf("suite events = %v, want pgmi.rollback first", suiteEvents)
+	}
+}
+
+func TestTestSpans_SuiteCompleted(t *testing.T) {
+	rec := recordSpans(t)
+
+	ctx, unit := tracing.Start(context.Background(), "pgmi.execute_unit")
+	tests := &testSpans{ctx: ctx}
+	tests.observe("[pgmi] Test suite started")
+	tests.observe("[pgmi] Test: ./__test__/test_a.sql")
+	tests.observe("[pgmi] Teardown: ./__test__/")
+	tests.observe("[pgmi] Test suite completed")
+	tests.finish(nil)
+	unit.End()
+
+	var names []string
+	for _, s := range rec.Ended() {
+		if s.Status().Code == codes.Error {
+			t.Errorf("span %s failed, want ok", s.Name())
+		}
+		names = append(names, s.Name())
+	}
+	want := []string{"pgmi.test", "pgmi.teardown", "pgmi.test_suite", "pgmi.execute_unit"}
+	if len(names) != len(want) {
+		t.Fatalf("spans = %v, want %v", names, want)
+	}
+	for i := range want {
+		if names[i] != want[i] {
+			t.Errorf("spans = %v, want %v", names, want)
+			break
+		}
+	}
+}
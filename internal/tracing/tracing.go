@@ -0,0 +1,124 @@
+// Package tracing exports a deployment's phases as OpenTelemetry spans.
+//
+// The services record spans through the global tracer provider, which is a
+// no-op until Setup installs one exporting over OTLP/HTTP. Code embedding pgmi
+// that installs its own provider gets the same spans in its own traces.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TracerName is the instrumentation scope pgmi's spans are recorded under.
+const TracerName = "github.com/vvka-141/pgmi"
+
+// Start starts a span named name as a child of the span in ctx, if any.
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(TracerName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End records err on span, when there is one, and ends it.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// TraceID returns the id of the trace ctx's span belongs to, or "" when
+// nothing is being recorded.
+func TraceID(ctx context.Context) string {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.HasTraceID() {
+		return ""
+	}
+	return sc.TraceID().String()
+}
+
+// Enabled reports whether Setup would export: endpoint is set, or one of the
+// standard OTEL_EXPORTER_OTLP_ENDPOINT variables is, and OTEL_SDK_DISABLED
+// is not true.
+func Enabled(endpoint string) bool {
+	if strings.EqualFold(os.Getenv("OTEL_SDK_DISABLED"), "true") {
+		return false
+	}
+	return endpoint != "" ||
+		os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") != "" ||
+		os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != ""
+}
+
+// Setup installs a global tracer provider that batches spans and exports
+// them over OTLP/HTTP to endpoint, an http:// or https:// URL. An endpoint
+// without a path gets the standard /v1/traces. An empty endpoint leaves it to
+// the OTEL_EXPORTER_OTLP_* variables, which also supply headers, timeouts and
+// TLS settings; OTEL_SERVICE_NAME and OTEL_RESOURCE_ATTRIBUTES override the
+// resource pgmi describes itself with.
+//
+// When Enabled(endpoint) is false Setup installs nothing. The returned func
+// flushes the spans still buffered and must be called before exiting; onError
+// receives the failures the exporter reports along the way.
+func Setup(ctx context.Context, endpoint, version string, onError func(error)) (func(context.Context) error, error) {
+	if !Enabled(endpoint) {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	var opts []otlptracehttp.Option
+	if endpoint != "" {
+		u, err := endpointURL(endpoint)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, otlptracehttp.WithEndpointURL(u))
+	}
+	exporter, err := otlptracehttp.New(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the OTLP trace exporter: %w", err)
+	}
+
+	res, err := resource.New(ctx,
+		resource.WithTelemetrySDK(),
+		resource.WithAttributes(
+			attribute.String("service.name", "pgmi"),
+			attribute.String("service.version", version),
+		),
+		resource.WithFromEnv(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe the trace resource: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	if onError != nil {
+		otel.SetErrorHandler(otel.ErrorHandlerFunc(onError))
+	}
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// endpointURL validates endpoint and fills in the OTLP/HTTP traces path.
+func endpointURL(endpoint string) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", fmt.Errorf("invalid OTLP endpoint %q: want an http:// or https:// URL such as http://localhost:4318", endpoint)
+	}
+	if u.Path == "" || u.Path == "/" {
+		u.Path = "/v1/traces"
+	}
+	return u.String(), nil
+}
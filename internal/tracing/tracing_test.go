@@ -0,0 +1,193 @@
+package tracing
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace/noop"
+	collectortrace "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// collectorStub stands in for an OpenTelemetry collector's OTLP/HTTP
+// receiver, keeping the export requests it was sent.
+type collectorStub struct {
+	mu       sync.Mutex
+	paths    []string
+	requests []*collectortrace.ExportTraceServiceRequest
+}
+
+func (c *collectorStub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	req := &collectortrace.ExportTraceServiceRequest{}
+	if err := proto.Unmarshal(body, req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	c.mu.Lock()
+	c.paths = append(c.paths, r.URL.Path)
+	c.requests = append(c.requests, req)
+	c.mu.Unlock()
+
+	resp, _ := proto.Marshal(&collectortrace.ExportTraceServiceResponse{})
+	w.Header().Set("Content-Type", "application/x-protobuf")
+	_, _ = w.Write(resp)
+}
+
+func clearOTelEnv(t *testing.T) {
+	t.Helper()
+	for _, key := range []string{
+		"OTEL_SDK_DISABLED", "OTEL_EXPORTER_OTLP_ENDPOINT", "OTEL_EXPORTER_OTLP_TRACES_ENDPOINT",
+		"OTEL_SERVICE_NAME", "OTEL_RESOURCE_ATTRIBUTES",
+	} {
+		t.Setenv(key, "")
+	}
+	t.Cleanup(func() {
+		otel.SetTracerProvider(noop.NewTracerProvider())
+		otel.SetErrorHandler(otel.ErrorHandlerFunc(func(error) {}))
+	})
+}
+
+func TestSetup_ExportsToCollector(t *testing.T) {
+	clearOTelEnv(t)
+	stub := &collectorStub{}
+	srv := httptest.NewServer(stub)
+	defer srv.Close()
+
+	shutdown, err := Setup(context.Background(), srv.URL, "1.2.3", func(err error) { t.Errorf("export error: %v", err) })
+	if err != nil {
+		t.Fatalf("Setup: %v", err)
+	}
+
+	ctx, parent := Start(context.Background(), "pgmi.deploy")
+	traceID := TraceID(ctx)
+	_, child := Start(ctx, "pgmi.execute_unit")
+	End(child, errors.New("unit failed"))
+	End(parent, nil)
+
+	if err := shutdown(context.Background()); err != nil {
+		t.Fatalf("shutdown: %v", err)
+	}
+
+	stub.mu.Lock()
+	defer stub.mu.Unlock()
+	if len(stub.requests) == 0 {
+		t.Fatal("collector received no export request")
+	}
+	if stub.paths[0] != "/v1/traces" {
+		t.Errorf("path = %q, want /v1/traces", stub.paths[0])
+	}
+
+	spans := map[string]bool{}
+	var serviceName, serviceVersion string
+	for _, req := range stub.requests {
+		for _, rs := range req.ResourceSpans {
+			for _, kv := range rs.Resource.Attributes {
+				switch kv.Key {
+				case "service.name":
+					serviceName = kv.Value.GetStringValue()
+				case "service.version":
+					serviceVersion = kv.Value.GetStringValue()
+				}
+			}
+			for _, ss := range rs.ScopeSpans {
+				if ss.Scope.Name != TracerName {
+					t.Errorf("scope = %q, want %q", ss.Scope.Name, TracerName)
+				}
+				for _, s := range ss.Spans {
+					spans[s.Name] = true
+					if got := hex.EncodeToString(s.TraceId); got != traceID {
+						t.Errorf("span %s trace id = %s, want %s", s.Name, got, traceID)
+					}
+					if s.Name == "pgmi.execute_unit" && s.Status.GetMessage() != "unit failed" {
+						t.Errorf("failed span status = %v, want the error", s.Status)
+					}
+				}
+			}
+		}
+	}
+	if !spans["pgmi.deploy"] || !spans["pgmi.execute_unit"] {
+		t.Errorf("exported spans = %v, want pgmi.deploy and pgmi.execute_unit", spans)
+	}
+	if serviceName != "pgmi" || serviceVersion != "1.2.3" {
+		t.Errorf("resource = %s %s, want pgmi 1.2.3", serviceName, serviceVersion)
+	}
+}
+
+func TestSetup_Disabled(t *testing.T) {
+	clearOTelEnv(t)
+
+	shutdown, err := Setup(context.Background(), "", "dev", nil)
+	if err != nil {
+		t.Fatalf("Setup: %v", err)
+	}
+	if err := shutdown(context.Background()); err != nil {
+		t.Fatalf("shutdown: %v", err)
+	}
+
+	ctx, span := Start(context.Background(), "pgmi.deploy")
+	defer span.End()
+	if span.IsRecording() {
+		t.Error("span is recording without an endpoint")
+	}
+	if id := TraceID(ctx); id != "" {
+		t.Errorf("TraceID = %q, want empty", id)
+	}
+}
+
+func TestEnabled(t *testing.T) {
+	clearOTelEnv(t)
+	if Enabled("") {
+		t.Error("Enabled(\"\") = true with no OTEL variables")
+	}
+	if !Enabled("http://localhost:4318") {
+		t.Error("Enabled(endpoint) = false")
+	}
+
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "http://collector:4318")
+	if !Enabled("") {
+		t.Error("Enabled(\"\") = false with OTEL_EXPORTER_OTLP_ENDPOINT set")
+	}
+
+	t.Setenv("OTEL_SDK_DISABLED", "true")
+	if Enabled("http://localhost:4318") {
+		t.Error("Enabled = true with OTEL_SDK_DISABLED=true")
+	}
+}
+
+func TestEndpointURL(t *testing.T) {
+	tests := []struct {
+		in, want string
+		wantErr  bool
+	}{
+		{in: "http://localhost:4318", want: "http://localhost:4318/v1/traces"},
+		{in: "http://localhost:4318/", want: "http://localhost:4318/v1/traces"},
+		{in: "https://otel.example.com/custom/traces", want: "https://otel.example.com/custom/traces"},
+		{in: "localhost:4318", wantErr: true},
+		{in: "grpc://localhost:4317", wantErr: true},
+		{in: "http://", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := endpointURL(tt.in)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("endpointURL(%q) = %q, want error", tt.in, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("endpointURL(%q) = %q, %v; want %q", tt.in, got, err, tt.want)
+		}
+	}
+}
@@ -1062,6 +1062,204 @@ func TestConnectionWizard_ConnStringFlow_InvalidStringShowsError(t *testing.T) {
 	}
 }
 
+// --- Token helper flow ---
+
+func selectTokenProvider(t *testing.T, w ConnectionWizard, authIdx int) tea.Model {
+	t.Helper()
+	// Provider list: Local(0), Azure(1), AWS(2), Google(3), Custom(4), Token Helper(5)
+	var m tea.Model = w
+	for i := 0; i < 5; i++ {
+		m, _ = update(t, m, keyMsg("down"))
+	}
+	m, _ = update(t, m, keyMsg("enter")) // Select Token Helper → auth selection
+	if wiz := asWizard(t, m); wiz.step != stepSelectAuth {
+		t.Fatalf("expected stepSelectAuth, got %d", wiz.step)
+	}
+	// Auth options: command(0), file(1)
+	for i := 0; i < authIdx; i++ {
+		m, _ = update(t, m, keyMsg("down"))
+	}
+	m, _ = update(t, m, keyMsg("enter"))
+	return m
+}
+
+func fillTokenForm(t *testing.T, m tea.Model, source string) (tea.Model, tea.Cmd) {
+	t.Helper()
+	m = typeString(t, m, "db.internal")
+	m, _ = update(t, m, keyMsg("enter")) // host → port (5432 pre-filled)
+	m, _ = update(t, m, keyMsg("enter")) // port → database
+	m = typeString(t, m, "orders")
+	m, _ = update(t, m, keyMsg("enter")) // database → username
+	m = typeString(t, m, "deploy")
+	m, _ = update(t, m, keyMsg("enter")) // username → source
+	m = typeString(t, m, source)
+	return update(t, m, keyMsg("enter")) // source → submit
+}
+
+func TestConnectionWizard_TokenCommandFlow(t *testing.T) {
+	mock := &mockTester{info: "Connected"}
+	m := selectTokenProvider(t, NewConnectionWizard(WithTester(mock)), 0)
+	wiz := asWizard(t, m)
+	if wiz.step != stepInputToken {
+		t.Fatalf("expected stepInputToken, got %d", wiz.step)
+	}
+	if len(wiz.inputs) != 5 {
+		t.Fatalf("token form should have 5 inputs, got %d", len(wiz.inputs))
+	}
+
+	m, cmd := fillTokenForm(t, m, "vault-db-token --role deploy")
+	if wiz = asWizard(t, m); wiz.step != stepTestConnection {
+		t.Fatalf("expected stepTestConnection, got %d (%s)", wiz.step, wiz.validationErr)
+	}
+	if _, ok := findTestResult(drainCmds(cmd)); !ok {
+		t.Fatal("expected testResultMsg")
+	}
+
+	got := mock.gotCfg
+	if got.AuthMethod != pgmi.AuthMethodExec {
+		t.Errorf("auth = %v, want Exec", got.AuthMethod)
+	}
+	if strings.Join(got.TokenCommand, "|") != "vault-db-token|--role|deploy" {
+		t.Errorf("command = %q", got.TokenCommand)
+	}
+	if got.Host != "db.internal" || got.Port != 5432 || got.Username != "deploy" {
+		t.Errorf("connection = %s:%d as %s", got.Host, got.Port, got.Username)
+	}
+	if wiz.result.Config.Database != "orders" {
+		t.Errorf("database = %q, want orders", wiz.result.Config.Database)
+	}
+}
+
+func TestConnectionWizard_TokenFileFlow(t *testing.T) {
+	mock := &mockTester{info: "Connected"}
+	m := selectTokenProvider(t, NewConnectionWizard(WithTester(mock)), 1)
+
+	m, cmd := fillTokenForm(t, m, "/var/run/secrets/db/token")
+	if wiz := asWizard(t, m); wiz.step != stepTestConnection {
+		t.Fatalf("expected stepTestConnection, got %d (%s)", wiz.step, wiz.validationErr)
+	}
+	drainCmds(cmd)
+	if mock.gotCfg.AuthMethod != pgmi.AuthMethodTokenFile {
+		t.Errorf("auth = %v, want Token File", mock.gotCfg.AuthMethod)
+	}
+	if mock.gotCfg.TokenFile != "/var/run/secrets/db/token" {
+		t.Errorf("token file = %q", mock.gotCfg.TokenFile)
+	}
+}
+
+func TestConnectionWizard_TokenFlow_ValidationMissingSource(t *testing.T) {
+	m := selectTokenProvider(t, NewConnectionWizard(), 1)
+	m, _ = fillTokenForm(t, m, "")
+	wiz := asWizard(t, m)
+	if wiz.validationErr != "token file is required" {
+		t.Errorf("validationErr = %q, want the missing token file", wiz.validationErr)
+	}
+}
+
+// --- Kerberos and client certificate flow ---
+
+func selectServerProvider(t *testing.T, w ConnectionWizard, authIdx int) tea.Model {
+	t.Helper()
+	// Provider list: ... Token Helper(5), Kerberos or Client Certificate(6)
+	var m tea.Model = w
+	for i := 0; i < 6; i++ {
+		m, _ = update(t, m, keyMsg("down"))
+	}
+	m, _ = update(t, m, keyMsg("enter"))
+	if wiz := asWizard(t, m); wiz.step != stepSelectAuth {
+		t.Fatalf("expected stepSelectAuth, got %d", wiz.step)
+	}
+	// Auth options: gssapi(0), cert(1)
+	for i := 0; i < authIdx; i++ {
+		m, _ = update(t, m, keyMsg("down"))
+	}
+	m, _ = update(t, m, keyMsg("enter"))
+	return m
+}
+
+// fillServerForm fills host, port, database and username, then types each of
+// rest into the next input, submitting after the last.
+func fillServerForm(t *testing.T, m tea.Model, rest ...string) (tea.Model, tea.Cmd) {
+	t.Helper()
+	m = typeString(t, m, "db.corp.example.com")
+	m, _ = update(t, m, keyMsg("enter")) // host → port (5432 pre-filled)
+	m, _ = update(t, m, keyMsg("enter")) // port → database
+	m = typeString(t, m, "orders")
+	m, _ = update(t, m, keyMsg("enter")) // database → username
+	m = typeString(t, m, "deploy")
+	var cmd tea.Cmd
+	m, cmd = update(t, m, keyMsg("enter"))
+	for _, value := range rest {
+		m = typeString(t, m, value)
+		m, cmd = update(t, m, keyMsg("enter"))
+	}
+	return m, cmd
+}
+
+func TestConnectionWizard_KerberosFlow(t *testing.T) {
+	mock := &mockTester{info: "Connected"}
+	m := selectServerProvider(t, NewConnectionWizard(WithTester(mock)), 0)
+	wiz := asWizard(t, m)
+	if wiz.step != stepInputKerberos {
+		t.Fatalf("expected stepInputKerberos, got %d", wiz.step)
+	}
+	if len(wiz.inputs) != 5 {
+		t.Fatalf("Kerberos form should have 5 inputs, got %d", len(wiz.inputs))
+	}
+
+	// The service name is pre-filled with postgres; submitting keeps it.
+	m, cmd := fillServerForm(t, m, "")
+	if wiz = asWizard(t, m); wiz.step != stepTestConnection {
+		t.Fatalf("expected stepTestConnection, got %d (%s)", wiz.step, wiz.validationErr)
+	}
+	drainCmds(cmd)
+
+	got := mock.gotCfg
+	if got.AuthMethod != pgmi.AuthMethodGSSAPI {
+		t.Errorf("auth = %v, want GSSAPI", got.AuthMethod)
+	}
+	if got.KerberosServiceName != "" {
+		t.Errorf("service name = %q, want the default left unset", got.KerberosServiceName)
+	}
+	if got.Host != "db.corp.example.com" || got.Username != "deploy" || got.Password != "" {
+		t.Errorf("connection = %s as %s (password set: %v)", got.Host, got.Username, got.Password != "")
+	}
+}
+
+func TestConnectionWizard_CertFlow(t *testing.T) {
+	mock := &mockTester{info: "Connected"}
+	m := selectServerProvider(t, NewConnectionWizard(WithTester(mock)), 1)
+	if wiz := asWizard(t, m); wiz.step != stepInputCert {
+		t.Fatalf("expected stepInputCert, got %d", wiz.step)
+	}
+
+	m, cmd := fillServerForm(t, m, "/certs/deploy.crt", "/certs/deploy.key", "/certs/ca.crt")
+	if wiz := asWizard(t, m); wiz.step != stepTestConnection {
+		t.Fatalf("expected stepTestConnection, got %d (%s)", wiz.step, wiz.validationErr)
+	}
+	drainCmds(cmd)
+
+	got := mock.gotCfg
+	if got.AuthMethod != pgmi.AuthMethodCert {
+		t.Errorf("auth = %v, want Client Certificate", got.AuthMethod)
+	}
+	if got.SSLCert != "/certs/deploy.crt" || got.SSLKey != "/certs/deploy.key" || got.SSLRootCert != "/certs/ca.crt" {
+		t.Errorf("certs = %q %q %q", got.SSLCert, got.SSLKey, got.SSLRootCert)
+	}
+	if got.SSLMode != "verify-full" {
+		t.Errorf("sslmode = %q, want verify-full with a root CA", got.SSLMode)
+	}
+}
+
+func TestConnectionWizard_CertFlow_ValidationMissingKey(t *testing.T) {
+	m := selectServerProvider(t, NewConnectionWizard(), 1)
+	m, _ = fillServerForm(t, m, "/certs/deploy.crt", "", "")
+	wiz := asWizard(t, m)
+	if wiz.validationErr != "certificate and key are required" {
+		t.Errorf("validationErr = %q, want the missing key", wiz.validationErr)
+	}
+}
+
 func TestConnectionWizard_CtrlC_Cancels(t *testing.T) {
 	w := NewConnectionWizard()
 	_, cmd := update(t, w, tea.KeyMsg{Type: tea.KeyCtrlC})
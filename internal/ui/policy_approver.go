@@ -0,0 +1,169 @@
+package ui
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/vvka-141/pgmi/internal/config"
+	"github.com/vvka-141/pgmi/pkg/pgmi"
+)
+
+// policyHookTimeout bounds the policy's command and webhook. Either one
+// hanging would otherwise hold the deploy until --timeout with the target
+// database still intact, and report a timeout for what is a stuck approver.
+const policyHookTimeout = 30 * time.Second
+
+// PolicyApprover checks the overwrite_policy of pgmi.yaml before a database
+// is dropped. The checks run cheapest first and the first failure refuses:
+// database name patterns, forbidden hosts, the confirmation variable, then
+// the command and the webhook.
+//
+// When the policy passes, next decides — the forced countdown or the prompt
+// — so a policy only ever narrows what --force and a terminal allow. A nil
+// next approves: that is how a CI job overwrites without --force.
+type PolicyApprover struct {
+	policy     config.OverwritePolicy
+	host       string
+	next       pgmi.Approver
+	getenv     func(string) string
+	httpClient *http.Client
+}
+
+// NewPolicyApprover creates an approver enforcing policy for databases on
+// host, deferring to next when the policy passes. host may be a
+// comma-separated list of servers, as libpq takes: the deploy may settle on
+// any of them, so each must pass forbidden_hosts.
+func NewPolicyApprover(policy config.OverwritePolicy, host string, next pgmi.Approver) pgmi.Approver {
+	return &PolicyApprover{
+		policy:     policy,
+		host:       host,
+		next:       next,
+		getenv:     os.Getenv,
+		httpClient: &http.Client{Timeout: policyHookTimeout},
+	}
+}
+
+func (a *PolicyApprover) RequestApproval(ctx context.Context, dbName string) (bool, error) {
+	if err := a.check(ctx, dbName); err != nil {
+		return false, fmt.Errorf("%w: overwrite_policy refuses to drop %q: %w", pgmi.ErrApprovalDenied, dbName, err)
+	}
+	if a.next == nil {
+		return true, nil
+	}
+	return a.next.RequestApproval(ctx, dbName)
+}
+
+func (a *PolicyApprover) check(ctx context.Context, dbName string) error {
+	p := a.policy
+	if len(p.Databases) > 0 && !matchAny(p.Databases, dbName) {
+		return fmt.Errorf("the name matches none of databases %q", p.Databases)
+	}
+	for _, host := range strings.Split(a.host, ",") {
+		if matchAnyFold(p.ForbiddenHosts, host) {
+			return fmt.Errorf("host %q is in forbidden_hosts", host)
+		}
+	}
+	if p.ConfirmEnv != "" {
+		if got := a.getenv(p.ConfirmEnv); got != dbName {
+			if got == "" {
+				return fmt.Errorf("set %s=%s to confirm", p.ConfirmEnv, dbName)
+			}
+			return fmt.Errorf("%s names %q, not this database", p.ConfirmEnv, got)
+		}
+	}
+	if len(p.Command) > 0 {
+		if err := a.runCommand(ctx, dbName); err != nil {
+			return err
+		}
+	}
+	if p.Webhook != "" {
+		if err := a.callWebhook(ctx, dbName); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// matchAny reports whether name matches one of patterns. The patterns were
+// validated when pgmi.yaml was loaded, so a match error cannot occur.
+func matchAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// matchAnyFold is matchAny ignoring case, as host names are.
+func matchAnyFold(patterns []string, name string) bool {
+	lowered := make([]string, len(patterns))
+	for i, pattern := range patterns {
+		lowered[i] = strings.ToLower(pattern)
+	}
+	return matchAny(lowered, strings.ToLower(name))
+}
+
+func (a *PolicyApprover) runCommand(ctx context.Context, dbName string) error {
+	ctx, cancel := context.WithTimeout(ctx, policyHookTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, a.policy.Command[0], a.policy.Command[1:]...)
+	cmd.Env = append(os.Environ(),
+		"PGMI_OVERWRITE_DATABASE="+dbName,
+		"PGMI_OVERWRITE_HOST="+a.host)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if reason := firstLine(stderr.String()); reason != "" {
+			return fmt.Errorf("command %s: %w: %s", a.policy.Command[0], err, reason)
+		}
+		return fmt.Errorf("command %s: %w", a.policy.Command[0], err)
+	}
+	return nil
+}
+
+func (a *PolicyApprover) callWebhook(ctx context.Context, dbName string) error {
+	body, err := json.Marshal(map[string]string{"database": dbName, "host": a.host})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.policy.Webhook, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("webhook: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := a.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("webhook: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode/100 == 2 {
+		return nil
+	}
+	reply, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	if reason := firstLine(string(reply)); reason != "" {
+		return fmt.Errorf("webhook answered %s: %s", resp.Status, reason)
+	}
+	return fmt.Errorf("webhook answered %s", resp.Status)
+}
+
+func firstLine(s string) string {
+	s = strings.TrimSpace(s)
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		s = s[:i]
+	}
+	return strings.TrimSpace(s)
+}
+
+var _ pgmi.Approver = (*PolicyApprover)(nil)
@@ -0,0 +1,123 @@
+package ui
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/vvka-141/pgmi/internal/config"
+	"github.com/vvka-141/pgmi/pkg/pgmi"
+)
+
+type recordingApprover struct{ asked []string }
+
+func (a *recordingApprover) RequestApproval(_ context.Context, dbName string) (bool, error) {
+	a.asked = append(a.asked, dbName)
+	return true, nil
+}
+
+func newTestPolicyApprover(policy config.OverwritePolicy, host string, env map[string]string, next pgmi.Approver) *PolicyApprover {
+	a := NewPolicyApprover(policy, host, next).(*PolicyApprover)
+	a.getenv = func(key string) string { return env[key] }
+	return a
+}
+
+func TestPolicyApprover(t *testing.T) {
+	policy := config.OverwritePolicy{
+		Databases:      []string{"*_test", "preview_*"},
+		ForbiddenHosts: []string{"*prod*"},
+		ConfirmEnv:     "PGMI_CONFIRM_OVERWRITE",
+	}
+	tests := []struct {
+		name, db, host, confirm string
+		wantDenial              string
+	}{
+		{"every rule passes", "orders_test", "ci-db.internal", "orders_test", ""},
+		{"name outside the patterns", "orders", "ci-db.internal", "orders", "matches none of databases"},
+		{"forbidden host, any case", "orders_test", "db.PROD.example.com", "orders_test", `host "db.PROD.example.com" is in forbidden_hosts`},
+		{"forbidden fallback host", "orders_test", "ci-db.internal,prod-db.internal", "orders_test", `host "prod-db.internal" is in forbidden_hosts`},
+		{"no confirmation", "preview_42", "ci-db.internal", "", "set PGMI_CONFIRM_OVERWRITE=preview_42"},
+		{"confirmation for another database", "preview_42", "ci-db.internal", "preview_41", `names "preview_41"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newTestPolicyApprover(policy, tt.host, map[string]string{"PGMI_CONFIRM_OVERWRITE": tt.confirm}, nil)
+			approved, err := a.RequestApproval(context.Background(), tt.db)
+			if tt.wantDenial == "" {
+				if !approved || err != nil {
+					t.Fatalf("expected approval, got %v, %v", approved, err)
+				}
+				return
+			}
+			if approved || pgmi.ExitCodeForError(err) != pgmi.ExitApprovalDenied {
+				t.Fatalf("expected a denial, got %v, %v", approved, err)
+			}
+			if !strings.Contains(err.Error(), tt.wantDenial) || !strings.Contains(err.Error(), tt.db) {
+				t.Errorf("the denial does not explain itself: %v", err)
+			}
+		})
+	}
+}
+
+// With --force or at a terminal the policy only narrows: the usual approver
+// still has the last word, and is never asked about a refused database.
+func TestPolicyApprover_DefersToNext(t *testing.T) {
+	next := &recordingApprover{}
+	a := newTestPolicyApprover(config.OverwritePolicy{Databases: []string{"*_test"}}, "localhost", nil, next)
+
+	if _, err := a.RequestApproval(context.Background(), "orders"); err == nil {
+		t.Fatal("expected a denial")
+	}
+	if approved, err := a.RequestApproval(context.Background(), "orders_test"); !approved || err != nil {
+		t.Fatalf("expected approval, got %v, %v", approved, err)
+	}
+	if len(next.asked) != 1 || next.asked[0] != "orders_test" {
+		t.Errorf("next approver asked about %q", next.asked)
+	}
+}
+
+func TestPolicyApprover_Command(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	script := `[ "$PGMI_OVERWRITE_DATABASE" = orders_test ] && [ "$PGMI_OVERWRITE_HOST" = ci-db ] || { echo "not on the change calendar" >&2; exit 1; }`
+	policy := config.OverwritePolicy{Command: []string{"sh", "-c", script}}
+
+	a := newTestPolicyApprover(policy, "ci-db", nil, nil)
+	if approved, err := a.RequestApproval(context.Background(), "orders_test"); !approved || err != nil {
+		t.Errorf("expected approval, got %v, %v", approved, err)
+	}
+	_, err := a.RequestApproval(context.Background(), "orders")
+	if err == nil || !strings.Contains(err.Error(), "not on the change calendar") {
+		t.Errorf("expected the command's refusal, got %v", err)
+	}
+}
+
+func TestPolicyApprover_Webhook(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct{ Database, Host string }
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || r.Method != http.MethodPost {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		if req.Database != "orders_test" || req.Host != "ci-db" {
+			http.Error(w, "change freeze until Monday", http.StatusForbidden)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	a := newTestPolicyApprover(config.OverwritePolicy{Webhook: server.URL}, "ci-db", nil, nil)
+	if approved, err := a.RequestApproval(context.Background(), "orders_test"); !approved || err != nil {
+		t.Errorf("expected approval, got %v, %v", approved, err)
+	}
+	_, err := a.RequestApproval(context.Background(), "orders")
+	if err == nil || !strings.Contains(err.Error(), "403 Forbidden: change freeze until Monday") {
+		t.Errorf("expected the webhook's refusal, got %v", err)
+	}
+}
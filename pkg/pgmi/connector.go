@@ -3,6 +3,7 @@ package pgmi
 import (
 	"context"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
@@ -14,3 +15,17 @@ type Connector interface {
 	// The returned pool should be closed by the caller when done.
 	Connect(ctx context.Context) (*pgxpool.Pool, error)
 }
+
+// BorrowedConnector is a Connector for a connection the caller already holds,
+// such as one from a test harness or an application's own pool. The deploy
+// session runs on that connection, under the same advisory lock, instead of
+// one from a pool pgmi opens; pgmi never closes it, and resets it with
+// ResetBorrowedConn when the session ends. Connect still opens a pool from
+// the connection's configuration, for the checks that run outside the session.
+//
+// The caller must not use the connection while a deployment holds it: a
+// *pgx.Conn is not safe for concurrent use.
+type BorrowedConnector interface {
+	Connector
+	BorrowedConn() *pgx.Conn
+}
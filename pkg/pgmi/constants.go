@@ -19,12 +19,13 @@ const (
 	ExitUsageError       = 2   // CLI usage error (missing args, invalid flags)
 	ExitPanic            = 3   // Internal panic (unexpected crash)
 	ExitConfigError      = 10  // Invalid pgmi configuration, rejected before connecting
-	ExitConnectionError  = 11  // Failed to connect to database
+	ExitConnectionError  = 11  // Failed to connect to database, or it is read-only
 	ExitApprovalDenied   = 12  // User denied overwrite approval
 	ExitExecutionFailed  = 13  // SQL execution failed
 	ExitDeploySQLMissing = 14  // deploy.sql not found
 	ExitConcurrentDeploy = 15  // Another pgmi deployment is in progress against the same database
 	ExitTimeout          = 16  // Operation exceeded --timeout (context deadline exceeded)
+	ExitDriftDetected    = 17  // pgmi drift found the live catalog differs from the last deploy
 	ExitInterrupted      = 130 // Process interrupted by SIGINT (Ctrl-C) — Unix convention 128+SIGINT
 )
 
@@ -42,6 +42,15 @@ type DatabaseSettings struct {
 	// keeps inheriting whatever a site installed in template1, so naming them
 	// anyway would quietly discard it.
 	PreserveLocale bool
+
+	// IsTemplate is pg_database.datistemplate: the database may be cloned by
+	// any user with CREATEDB, and cannot be dropped until it is cleared.
+	IsTemplate bool
+
+	// Template names the database Create copies instead of template1. The
+	// copy takes its encoding and locale from it, so PreserveLocale is
+	// ignored when Template is set.
+	Template string
 }
 
 type DatabaseManager interface {
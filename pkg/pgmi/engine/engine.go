@@ -0,0 +1,380 @@
+// Package engine runs pgmi deployments from Go, in-process, with the same
+// scanner, loader, connectors and database manager the pgmi CLI uses.
+//
+//	eng := engine.New(
+//		engine.WithFS(migrations),  // an embed.FS, fstest.MapFS, os.DirFS...
+//		engine.WithPool(appPool),   // or WithConn, or ConnectionString in the config
+//	)
+//	result, err := eng.Deploy(ctx, pgmi.DeploymentConfig{DatabaseName: "myapp"})
+//
+// Deploy returns a Result whether or not the deployment succeeded: the
+// server's notices, the test events of every CALL pgmi_test(), the statistics
+// the CLI prints, and on failure the same structured diagnostics as
+// `pgmi deploy --json`. Errors carry the sentinels of package pgmi, so
+// pgmi.ExitCodeForError and errors.Is work on them as they do for the CLI.
+package engine
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/vvka-141/pgmi/internal/checksum"
+	"github.com/vvka-141/pgmi/internal/db"
+	"github.com/vvka-141/pgmi/internal/db/manager"
+	"github.com/vvka-141/pgmi/internal/files/filesystem"
+	"github.com/vvka-141/pgmi/internal/files/loader"
+	"github.com/vvka-141/pgmi/internal/files/scanner"
+	"github.com/vvka-141/pgmi/internal/logging"
+	"github.com/vvka-141/pgmi/internal/services"
+	"github.com/vvka-141/pgmi/pkg/pgmi"
+)
+
+// Engine deploys pgmi projects. It holds no connection between deployments
+// and is safe for concurrent Deploy calls; with WithConn they take turns on
+// the one connection.
+type Engine struct {
+	fsys     fs.FS
+	pool     *pgxpool.Pool
+	conn     *pgx.Conn
+	dial     pgconn.DialFunc
+	logger   pgmi.Logger
+	approver pgmi.Approver
+
+	connMu sync.Mutex
+}
+
+// Option configures an Engine.
+type Option func(*Engine)
+
+// WithFS reads projects from fsys instead of the operating system's
+// filesystem. DeploymentConfig.SourcePath is then a directory within fsys,
+// and may be left empty for its root.
+func WithFS(fsys fs.FS) Option {
+	return func(e *Engine) { e.fsys = fsys }
+}
+
+// WithPool connects the way pool does: its host, TLS settings, credentials,
+// BeforeConnect hook and dialer. DeploymentConfig.ConnectionString and
+// DatabaseName may then be left empty, and default to the pool's own.
+//
+// pgmi opens its own connections from a copy of the pool's configuration and
+// never uses or closes the pool itself. A deploy session leaves temp tables
+// and session settings behind, which must not return to an application's
+// pool, and it holds the deploy advisory lock for its whole duration.
+func WithPool(pool *pgxpool.Pool) Option {
+	return func(e *Engine) { e.pool = pool }
+}
+
+// WithConn runs the deploy session on conn, a connection the caller owns:
+// one a test harness opened, say, or one acquired from an application's pool.
+// DeploymentConfig.ConnectionString and DatabaseName may then be left empty,
+// and default to the connection's own; naming another database is an error,
+// as are Overwrite, Ephemeral and TemplateDatabase, which would have to drop
+// or create the database conn is connected to.
+//
+// conn must have been opened from a configuration passed to RouteNotices,
+// or Deploy refuses it: that is what lets the Result collect its notices and
+// test events as it does for WithPool. It must be idle, outside any
+// transaction, and unused by anything else until Deploy returns.
+//
+// conn is left open, not reset. Deploy releases the deploy advisory lock,
+// drops the pg_temp objects pgmi created and resets the pgmi.* settings, but
+// leaves the caller's own temp tables, prepared statements and settings
+// alone — and with them anything the deployment's SQL left behind outside
+// those names: its own temp tables, a SET without LOCAL, a session advisory
+// lock. Run DISCARD ALL on conn afterwards if it needs to come back clean.
+func WithConn(conn *pgx.Conn) Option {
+	return func(e *Engine) { e.conn = conn }
+}
+
+// RouteNotices prepares config for WithConn. pgx fixes a connection's notice
+// handler when it connects, so this must happen before any connection is
+// opened from config: while a deployment holds such a connection its notices
+// go to the deployment's Result, and otherwise to config's own OnNotice as
+// before. For a pool, prepare its ConnConfig:
+//
+//	poolConfig, err := pgxpool.ParseConfig(databaseURL)
+//	...
+//	engine.RouteNotices(poolConfig.ConnConfig)
+//	pool, err := pgxpool.NewWithConfig(ctx, poolConfig)
+func RouteNotices(config *pgx.ConnConfig) {
+	db.RouteNotices(config)
+}
+
+// WithDialer opens every connection through dial instead of pgx's TCP and
+// Unix socket dialer: through a tunnel, a proxy, or an in-memory listener in
+// tests. It applies to connections made from ConnectionString or WithPool;
+// a WithConn connection was already dialed, and the connections opened
+// from its configuration use its dialer. Google Cloud SQL IAM dials
+// through its own connector and cannot be combined with it.
+func WithDialer(dial pgconn.DialFunc) Option {
+	return func(e *Engine) { e.dial = dial }
+}
+
+// WithLogger reports progress to logger. The default discards it: notices
+// and errors are in the Result either way.
+func WithLogger(logger pgmi.Logger) Option {
+	return func(e *Engine) { e.logger = logger }
+}
+
+// WithApprover decides whether an Overwrite deployment may drop an existing
+// database. The default refuses unless DeploymentConfig.Force is set; Force
+// approves whatever the approver.
+func WithApprover(approver pgmi.Approver) Option {
+	return func(e *Engine) { e.approver = approver }
+}
+
+// New creates an Engine. Without options it reads projects from the
+// operating system's filesystem, connects with DeploymentConfig's connection
+// string and auth method, logs nothing, and refuses unforced overwrites.
+func New(opts ...Option) *Engine {
+	e := &Engine{
+		logger:   logging.NewNullLogger(),
+		approver: refusingApprover{},
+	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e
+}
+
+// Deploy runs one deployment, as `pgmi deploy` would with the same
+// configuration, and returns its Result. The Result is never nil; on failure
+// it carries the error's diagnostics in Error, and the error is returned too.
+func (e *Engine) Deploy(ctx context.Context, config pgmi.DeploymentConfig) (*Result, error) {
+	config = e.withDefaults(config)
+	if err := e.validate(config); err != nil {
+		result := newResult(nil, []Notice{})
+		result.Error = pgmi.NewErrorDetail(err)
+		return result, err
+	}
+	if e.conn != nil {
+		e.connMu.Lock()
+		defer e.connMu.Unlock()
+	}
+	if config.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, config.Timeout)
+		defer cancel()
+	}
+
+	notices := &noticeCollector{}
+	hooks := db.PoolHooks{OnNotice: notices.add, DialFunc: e.dial, Logger: e.logger}
+	connectorFactory := func(connConfig *pgmi.ConnectionConfig) (pgmi.Connector, error) {
+		switch {
+		case e.conn != nil && connConfig.Database == e.conn.Config().Database:
+			return db.NewConnConnectorWithHooks(e.conn, hooks), nil
+		case e.conn != nil:
+			base, err := db.PoolConfigFromConn(e.conn)
+			if err != nil {
+				return nil, err
+			}
+			return db.NewPoolConfigConnectorWithHooks(base, connConfig.Database, hooks), nil
+		case e.pool != nil:
+			return db.NewPoolConfigConnectorWithHooks(e.pool.Config(), connConfig.Database, hooks), nil
+		}
+		return db.NewConnectorWithHooks(connConfig, hooks)
+	}
+
+	fileScanner := scanner.NewScanner(checksum.New())
+	if e.fsys != nil {
+		fileScanner = scanner.NewScannerWithFS(checksum.New(), filesystem.NewFSFileSystem(e.fsys, "."))
+	}
+	approver := e.approver
+	if config.Force {
+		approver = forcedApprover{}
+	}
+
+	sessionManager := services.NewSessionManager(connectorFactory, fileScanner, loader.NewLoader(), e.logger)
+	deployer := services.NewDeploymentService(connectorFactory, approver, e.logger, sessionManager, fileScanner, manager.New())
+
+	err := deployer.Deploy(ctx, config)
+	result := newResult(deployer.LastResult(), notices.notices())
+	result.Error = pgmi.NewErrorDetail(err)
+	return result, err
+}
+
+// withDefaults fills what WithFS, WithPool and WithConn make optional.
+func (e *Engine) withDefaults(config pgmi.DeploymentConfig) pgmi.DeploymentConfig {
+	if e.fsys != nil && config.SourcePath == "" {
+		config.SourcePath = "."
+	}
+	var cc *pgx.ConnConfig
+	switch {
+	case e.conn != nil:
+		cc = e.conn.Config()
+	case e.pool != nil:
+		cc = e.pool.Config().ConnConfig
+	}
+	if cc != nil {
+		if config.DatabaseName == "" {
+			config.DatabaseName = cc.Database
+		}
+		if config.ConnectionString == "" {
+			// Only for validation and messages: every connection is opened
+			// from the pool's or conn's configuration, which also holds the
+			// secrets.
+			config.ConnectionString = db.BuildConnectionString(&pgmi.ConnectionConfig{
+				Host:     cc.Host,
+				Port:     int(cc.Port),
+				Username: cc.User,
+				Database: cc.Database,
+			})
+		}
+	}
+	return config
+}
+
+// validate rejects what a borrowed connection cannot do.
+func (e *Engine) validate(config pgmi.DeploymentConfig) error {
+	if e.conn == nil {
+		return nil
+	}
+	if e.pool != nil {
+		return fmt.Errorf("%w: engine.WithConn and engine.WithPool cannot be combined", pgmi.ErrInvalidConfig)
+	}
+	if !db.NoticesRouted(e.conn.PgConn()) {
+		return fmt.Errorf("%w: the connection passed to engine.WithConn was not opened from a configuration "+
+			"passed to engine.RouteNotices, so its notices and test events would be missing from the Result", pgmi.ErrInvalidConfig)
+	}
+	if database := e.conn.Config().Database; config.DatabaseName != database {
+		return fmt.Errorf("%w: the connection passed to engine.WithConn is to %q, not %q",
+			pgmi.ErrInvalidConfig, database, config.DatabaseName)
+	}
+	switch {
+	case config.Overwrite:
+		return fmt.Errorf("%w: Overwrite would drop the database of the connection passed to engine.WithConn", pgmi.ErrInvalidConfig)
+	case config.Ephemeral:
+		return fmt.Errorf("%w: an Ephemeral deployment creates its own database and cannot run on engine.WithConn's", pgmi.ErrInvalidConfig)
+	case config.TemplateDatabase != "":
+		return fmt.Errorf("%w: a TemplateDatabase deployment recreates its target and cannot run on engine.WithConn's", pgmi.ErrInvalidConfig)
+	}
+	return nil
+}
+
+// Result describes a deployment, successful or not.
+type Result struct {
+	Database       string        `json:"database"`
+	FilesLoaded    int           `json:"filesLoaded"`
+	TestMacros     int           `json:"testMacros"`
+	ExecutionMode  string        `json:"executionMode,omitempty"`
+	ExecutionUnits int           `json:"executionUnits,omitempty"`
+	UnitsCommitted int           `json:"unitsCommitted,omitempty"`
+	UnitsSkipped   int           `json:"unitsSkipped,omitempty"`
+	Duration       time.Duration `json:"duration"`
+
+	// Template is the template database Database was cloned from, and
+	// TemplateRebuilt whether this run had to deploy into it first.
+	Template        string `json:"template,omitempty"`
+	TemplateRebuilt bool   `json:"templateRebuilt,omitempty"`
+	// Ephemeral is set when Database was created for this run alone, and
+	// EphemeralDropped once it has been dropped again.
+	Ephemeral        bool `json:"ephemeral,omitempty"`
+	EphemeralDropped bool `json:"ephemeralDropped,omitempty"`
+
+	// Notices are every NOTICE, WARNING and, with Verbose, DEBUG message the
+	// server sent, in order.
+	Notices []Notice `json:"notices"`
+	// TestEvents are the test lifecycle events the default test callback
+	// reported. A project passing its own callback to pgmi_test() reports
+	// through that callback instead, and its events are only in Notices.
+	TestEvents []TestEvent `json:"testEvents"`
+
+	// Error is nil when the deployment succeeded.
+	Error *pgmi.ErrorDetail `json:"error,omitempty"`
+}
+
+// Succeeded reports whether the deployment completed without error.
+func (r *Result) Succeeded() bool { return r.Error == nil }
+
+func newResult(deployed *services.DeployResult, notices []Notice) *Result {
+	r := &Result{
+		Notices:    notices,
+		TestEvents: testEvents(notices),
+	}
+	if deployed == nil {
+		return r
+	}
+	r.Database = deployed.Database
+	r.FilesLoaded = deployed.FilesLoaded
+	r.TestMacros = deployed.TestMacros
+	r.ExecutionMode = deployed.ExecutionMode
+	r.ExecutionUnits = deployed.ExecutionUnits
+	r.UnitsCommitted = deployed.UnitsCommitted
+	r.UnitsSkipped = deployed.UnitsSkipped
+	r.Duration = deployed.Duration
+	r.Template = deployed.Template
+	r.TemplateRebuilt = deployed.TemplateRebuilt
+	r.Ephemeral = deployed.Ephemeral
+	r.EphemeralDropped = deployed.EphemeralDropped
+	return r
+}
+
+// Notice is a message the server sent during the deployment.
+type Notice struct {
+	Message string `json:"message"`
+	Detail  string `json:"detail,omitempty"`
+	Hint    string `json:"hint,omitempty"`
+}
+
+// noticeCollector gathers the notices of one deployment. pgx calls it from
+// whichever connection received the notice.
+type noticeCollector struct {
+	mu   sync.Mutex
+	list []Notice
+}
+
+func (c *noticeCollector) add(message, detail, hint string) {
+	c.mu.Lock()
+	c.list = append(c.list, Notice{Message: message, Detail: detail, Hint: hint})
+	c.mu.Unlock()
+}
+
+func (c *noticeCollector) notices() []Notice {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.list == nil {
+		return []Notice{}
+	}
+	return append([]Notice(nil), c.list...)
+}
+
+// TestEvent is one step of a CALL pgmi_test() run, as pg_temp.pgmi_test_event
+// names it: suite_start, fixture_start, test_start, rollback, teardown_start
+// or suite_end. Path is the script for fixtures and tests, the directory for
+// rollbacks and teardowns, and empty for the suite. The default callback
+// reports rollback and teardown_start at DEBUG, so they appear only with
+// Verbose.
+type TestEvent struct {
+	Event string `json:"event"`
+	Path  string `json:"path,omitempty"`
+}
+
+func testEvents(notices []Notice) []TestEvent {
+	events := []TestEvent{}
+	for _, n := range notices {
+		if event, path, ok := pgmi.ParseTestEventNotice(n.Message); ok {
+			events = append(events, TestEvent{Event: event, Path: path})
+		}
+	}
+	return events
+}
+
+// refusingApprover stands in for the CLI's prompt, which a library has no
+// terminal for.
+type refusingApprover struct{}
+
+func (refusingApprover) RequestApproval(_ context.Context, dbName string) (bool, error) {
+	return false, fmt.Errorf("%w: set Force in the DeploymentConfig, or pass an approver with engine.WithApprover, to drop and recreate %q",
+		pgmi.ErrApprovalDenied, dbName)
+}
+
+type forcedApprover struct{}
+
+func (forcedApprover) RequestApproval(context.Context, string) (bool, error) { return true, nil }
@@ -0,0 +1,237 @@
+package engine_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"testing/fstest"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	testhelpers "github.com/vvka-141/pgmi/internal/testing"
+	"github.com/vvka-141/pgmi/pkg/pgmi"
+	"github.com/vvka-141/pgmi/pkg/pgmi/engine"
+)
+
+var project = fstest.MapFS{
+	"migrations/001_users.sql": {Data: []byte(`CREATE TABLE users (id int PRIMARY KEY, name text);`)},
+	"__test__/test_users.sql": {Data: []byte(`
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_tables WHERE tablename = 'users') THEN
+        RAISE EXCEPTION 'users table not found';
+    END IF;
+END $$;`)},
+	"deploy.sql": {Data: []byte(`
+DO $$
+DECLARE
+    v_file RECORD;
+BEGIN
+    FOR v_file IN
+        SELECT path, content FROM pg_temp.pgmi_source_view
+        WHERE directory = './migrations/' AND is_sql_file ORDER BY path
+    LOOP
+        RAISE NOTICE 'applying %', v_file.path;
+        EXECUTE v_file.content;
+    END LOOP;
+END $$;
+
+BEGIN;
+SAVEPOINT _tests;
+CALL pgmi_test();
+ROLLBACK TO SAVEPOINT _tests;
+COMMIT;
+`)},
+}
+
+// An application deploys an embedded project through a pool it already has,
+// and gets the notices and test events back; its pool stays open and usable.
+func TestEngine_DeployFromFSThroughPool(t *testing.T) {
+	connString := testhelpers.RequireDatabase(t)
+	ctx := context.Background()
+	const dbName = "pgmi_itest_engine"
+	testhelpers.CleanupTestDB(t, connString, dbName)
+	defer testhelpers.CleanupTestDB(t, connString, dbName)
+
+	appPool := testhelpers.GetTestPool(t, connString, "postgres")
+	eng := engine.New(engine.WithFS(project), engine.WithPool(appPool))
+
+	result, err := eng.Deploy(ctx, pgmi.DeploymentConfig{DatabaseName: dbName})
+	if err != nil {
+		t.Fatalf("Deploy: %v", err)
+	}
+	if !result.Succeeded() || result.Database != dbName || result.FilesLoaded != 2 || result.TestMacros != 1 {
+		t.Errorf("result = %+v", result)
+	}
+
+	var messages []string
+	for _, n := range result.Notices {
+		messages = append(messages, n.Message)
+	}
+	if !slices.Contains(messages, "applying ./migrations/001_users.sql") {
+		t.Errorf("the deploy's own notice is missing: %q", messages)
+	}
+	var events []string
+	for _, e := range result.TestEvents {
+		events = append(events, e.Event+" "+e.Path)
+	}
+	if want := []string{"suite_start ", "test_start ./__test__/test_users.sql", "suite_end "}; !slices.Equal(events, want) {
+		t.Errorf("test events = %q, want %q", events, want)
+	}
+
+	if err := appPool.Ping(ctx); err != nil {
+		t.Errorf("the caller's pool was closed: %v", err)
+	}
+
+	// Deploying again into an existing database is refused unless forced.
+	_, err = eng.Deploy(ctx, pgmi.DeploymentConfig{DatabaseName: dbName, Overwrite: true})
+	if !errors.Is(err, pgmi.ErrApprovalDenied) {
+		t.Errorf("unforced overwrite: expected ErrApprovalDenied, got %v", err)
+	}
+	if _, err := eng.Deploy(ctx, pgmi.DeploymentConfig{DatabaseName: dbName, Overwrite: true, Force: true}); err != nil {
+		t.Errorf("forced overwrite: %v", err)
+	}
+}
+
+func TestEngine_FailedDeployCarriesErrorDetail(t *testing.T) {
+	connString := testhelpers.RequireDatabase(t)
+	const dbName = "pgmi_itest_engine_fail"
+	testhelpers.CleanupTestDB(t, connString, dbName)
+	defer testhelpers.CleanupTestDB(t, connString, dbName)
+
+	eng := engine.New(engine.WithFS(fstest.MapFS{
+		"deploy.sql": {Data: []byte("SELECT 1;\nSELEC 2;\n")},
+	}))
+	result, err := eng.Deploy(context.Background(), pgmi.DeploymentConfig{
+		ConnectionString: connString,
+		DatabaseName:     dbName,
+	})
+	if pgmi.ExitCodeForError(err) != pgmi.ExitExecutionFailed {
+		t.Fatalf("expected an execution failure, got %v", err)
+	}
+	if result.Error == nil || result.Error.SQLState != "42601" || result.Error.Line != 2 {
+		t.Errorf("error detail = %+v", result.Error)
+	}
+}
+
+// A deployment on a connection the caller owns reports its notices and test
+// events as one through a pool does, and leaves the connection open and idle
+// with nothing of the session behind and nothing of the caller's gone.
+func TestEngine_DeployOnBorrowedConn(t *testing.T) {
+	connString := testhelpers.RequireDatabase(t)
+	ctx := context.Background()
+	const dbName = "pgmi_itest_engine_conn"
+	testhelpers.CleanupTestDB(t, connString, dbName)
+	cleanup := testhelpers.CreateTestDB(t, connString, dbName)
+	defer cleanup()
+
+	poolConfig := testhelpers.GetTestPool(t, connString, dbName).Config()
+	var ownNotices []string
+	poolConfig.ConnConfig.OnNotice = func(_ *pgconn.PgConn, n *pgconn.Notice) { ownNotices = append(ownNotices, n.Message) }
+	engine.RouteNotices(poolConfig.ConnConfig)
+	pool, err := pgxpool.NewWithConfig(ctx, poolConfig)
+	if err != nil {
+		t.Fatalf("NewWithConfig: %v", err)
+	}
+	defer pool.Close()
+	pooled, err := pool.Acquire(ctx)
+	if err != nil {
+		t.Fatalf("Acquire: %v", err)
+	}
+	defer pooled.Release()
+	conn := pooled.Conn()
+
+	// The caller's own session state, which the deployment must not take.
+	for _, sql := range []string{
+		"CREATE TEMP TABLE caller_scratch (id int)",
+		"PREPARE caller_stmt AS SELECT 1",
+		"SET application_name = 'caller'",
+		"SELECT pg_advisory_lock(42)",
+	} {
+		if _, err := conn.Exec(ctx, sql); err != nil {
+			t.Fatalf("%s: %v", sql, err)
+		}
+	}
+
+	eng := engine.New(engine.WithFS(project), engine.WithConn(conn))
+	result, err := eng.Deploy(ctx, pgmi.DeploymentConfig{Verbose: true})
+	if err != nil {
+		t.Fatalf("Deploy: %v", err)
+	}
+	if result.Database != dbName || result.FilesLoaded != 2 || result.TestMacros != 1 {
+		t.Errorf("result = %+v", result)
+	}
+	var messages []string
+	for _, n := range result.Notices {
+		messages = append(messages, n.Message)
+	}
+	if !slices.Contains(messages, "applying ./migrations/001_users.sql") {
+		t.Errorf("the deploy's own notice is missing: %q", messages)
+	}
+	if len(result.TestEvents) == 0 {
+		t.Error("no test events in the result")
+	}
+	if len(ownNotices) != 0 {
+		t.Errorf("the connection's own handler got the deployment's notices: %q", ownNotices)
+	}
+	if _, err := conn.Exec(ctx, "DO $$ BEGIN RAISE NOTICE 'mine again'; END $$"); err != nil {
+		t.Fatalf("raise notice: %v", err)
+	}
+	if !slices.Equal(ownNotices, []string{"mine again"}) {
+		t.Errorf("after Deploy the connection's own handler got %q", ownNotices)
+	}
+
+	if conn.IsClosed() || conn.PgConn().TxStatus() != 'I' {
+		t.Fatalf("the connection was closed or left in a transaction")
+	}
+	var pgmiObjects, pgmiSettings, advisoryLocks, statements int
+	var appName, minMessages string
+	err = conn.QueryRow(ctx, `
+		SELECT (SELECT count(*) FROM pg_class WHERE relnamespace = pg_my_temp_schema() AND relname ~ '^_?pgmi_')
+		     + (SELECT count(*) FROM pg_proc WHERE pronamespace = pg_my_temp_schema()),
+		       (SELECT count(*) FROM pg_settings WHERE name LIKE 'pgmi.%' AND setting <> ''),
+		       (SELECT count(*) FROM pg_locks WHERE locktype = 'advisory' AND pid = pg_backend_pid()),
+		       (SELECT count(*) FROM pg_prepared_statements WHERE name = 'caller_stmt'),
+		       current_setting('application_name'),
+		       current_setting('client_min_messages')`,
+	).Scan(&pgmiObjects, &pgmiSettings, &advisoryLocks, &statements, &appName, &minMessages)
+	if err != nil {
+		t.Fatalf("inspect connection: %v", err)
+	}
+	if pgmiObjects != 0 || pgmiSettings != 0 {
+		t.Errorf("left behind %d pgmi temp objects and %d pgmi settings", pgmiObjects, pgmiSettings)
+	}
+	if advisoryLocks != 1 || statements != 1 || appName != "caller" || minMessages != "notice" {
+		t.Errorf("the caller's state changed: %d advisory locks, %d prepared statements, application_name %q, client_min_messages %q",
+			advisoryLocks, statements, appName, minMessages)
+	}
+	if _, err := conn.Exec(ctx, "INSERT INTO caller_scratch VALUES (1)"); err != nil {
+		t.Errorf("the caller's temp table is gone: %v", err)
+	}
+	var users int
+	if err := conn.QueryRow(ctx, "SELECT count(*) FROM users").Scan(&users); err != nil {
+		t.Errorf("the deployment's table is not visible on the connection: %v", err)
+	}
+
+	// A connection opened without RouteNotices is refused before it is used.
+	plain := testhelpers.GetTestPool(t, connString, dbName)
+	unrouted, err := plain.Acquire(ctx)
+	if err != nil {
+		t.Fatalf("Acquire: %v", err)
+	}
+	defer unrouted.Release()
+	if _, err := engine.New(engine.WithFS(project), engine.WithConn(unrouted.Conn())).Deploy(ctx, pgmi.DeploymentConfig{}); !errors.Is(err, pgmi.ErrInvalidConfig) {
+		t.Errorf("unrouted connection: expected ErrInvalidConfig, got %v", err)
+	}
+
+	for name, config := range map[string]pgmi.DeploymentConfig{
+		"another database": {DatabaseName: "postgres"},
+		"overwrite":        {Overwrite: true, Force: true},
+		"ephemeral":        {Ephemeral: true},
+	} {
+		if _, err := eng.Deploy(ctx, config); !errors.Is(err, pgmi.ErrInvalidConfig) {
+			t.Errorf("%s: expected ErrInvalidConfig, got %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package pgmi
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultEntrypoint is the orchestrator script a deploy runs unless --entry
+// names another.
+const DefaultEntrypoint = "deploy.sql"
+
+// Session variables pgmi sets itself. They live in the pgmi.* namespace the
+// --param values share, so a parameter with the same key is refused rather
+// than silently overwritten.
+const (
+	// EntrypointSetting holds the project-relative path of the orchestrator
+	// script being executed, e.g. './ops/reindex.sql', so a script shared by
+	// several entrypoints can tell which one included it.
+	EntrypointSetting = "pgmi.entrypoint"
+
+	// ResumedUnitsSetting holds the number of committed tail units a
+	// --resume run skips, so the script can tell a resume from a fresh run
+	// with current_setting('pgmi.resumed_units', true).
+	ResumedUnitsSetting = "pgmi.resumed_units"
+
+	// TraceIDSetting holds the OpenTelemetry trace id of the deployment when
+	// traces are exported, so the script can log it beside its own output:
+	// current_setting('pgmi.trace_id', true).
+	TraceIDSetting = "pgmi.trace_id"
+)
+
+// IsReservedParameterKey reports whether a --param key would name one of the
+// session variables pgmi sets itself.
+func IsReservedParameterKey(key string) bool {
+	name := "pgmi." + strings.ToLower(key)
+	return name == EntrypointSetting || name == ResumedUnitsSetting || name == TraceIDSetting
+}
+
+// NormalizeEntrypoint returns entry as a clean slash-separated path relative
+// to the project root, with an empty entry meaning DefaultEntrypoint. It does
+// not validate; see ValidateEntrypointPath.
+func NormalizeEntrypoint(entry string) string {
+	if entry == "" {
+		return DefaultEntrypoint
+	}
+	p := path.Clean(filepath.ToSlash(entry))
+	p = strings.TrimPrefix(p, "./")
+	if strings.EqualFold(p, DefaultEntrypoint) {
+		return DefaultEntrypoint
+	}
+	return p
+}
+
+// ValidateEntrypointPath checks that entry can name an orchestrator script:
+// a SQL file inside the project, outside any __test__ directory. It checks
+// the path only; whether the file exists is the scanner's business.
+func ValidateEntrypointPath(entry string) error {
+	p := NormalizeEntrypoint(entry)
+	if filepath.IsAbs(entry) || path.IsAbs(p) || filepath.VolumeName(entry) != "" {
+		return fmt.Errorf("entrypoint \"%s\" must be relative to the project directory: %w", entry, ErrInvalidConfig)
+	}
+	if p == ".." || strings.HasPrefix(p, "../") {
+		return fmt.Errorf("entrypoint \"%s\" is outside the project directory: %w", entry, ErrInvalidConfig)
+	}
+	if !IsSQLExtension(path.Ext(p)) {
+		return fmt.Errorf("entrypoint \"%s\" is not a SQL file: %w", entry, ErrInvalidConfig)
+	}
+	if IsTestPath("/" + p) {
+		return fmt.Errorf("entrypoint \"%s\" is inside a test directory; tests run through pgmi_test(), not as entrypoints: %w", entry, ErrInvalidConfig)
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package pgmi_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/vvka-141/pgmi/pkg/pgmi"
+)
+
+func TestNormalizeEntrypoint(t *testing.T) {
+	tests := []struct {
+		entry string
+		want  string
+	}{
+		{"", "deploy.sql"},
+		{"Deploy.SQL", "deploy.sql"},
+		{"./ops/reindex.sql", "ops/reindex.sql"},
+		{"ops//nightly/../reindex.sql", "ops/reindex.sql"},
+	}
+	for _, tt := range tests {
+		if got := pgmi.NormalizeEntrypoint(tt.entry); got != tt.want {
+			t.Errorf("NormalizeEntrypoint(%q) = %q, want %q", tt.entry, got, tt.want)
+		}
+	}
+}
+
+func TestValidateEntrypointPath(t *testing.T) {
+	tests := []struct {
+		entry   string
+		wantErr bool
+	}{
+		{"ops/reindex.sql", false},
+		{"./ops/maintenance.pgsql", false},
+		{"deploy.sql", false},
+		{"/etc/reindex.sql", true},
+		{"../other/deploy.sql", true},
+		{"ops/../../deploy.sql", true},
+		{"ops/reindex.txt", true},
+		{"ops/__test__/reindex.sql", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.entry, func(t *testing.T) {
+			err := pgmi.ValidateEntrypointPath(tt.entry)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ValidateEntrypointPath(%q) error = %v, wantErr %v", tt.entry, err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, pgmi.ErrInvalidConfig) {
+				t.Errorf("expected ErrInvalidConfig, got %v", err)
+			}
+		})
+	}
+}
+
+func TestIsReservedParameterKey(t *testing.T) {
+	for _, key := range []string{"entrypoint", "ENTRYPOINT", "resumed_units", "trace_id"} {
+		if !pgmi.IsReservedParameterKey(key) {
+			t.Errorf("IsReservedParameterKey(%q) = false, want true", key)
+		}
+	}
+	if pgmi.IsReservedParameterKey("env") {
+		t.Error("IsReservedParameterKey(\"env\") = true, want false")
+	}
+}
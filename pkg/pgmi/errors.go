@@ -39,6 +39,11 @@ var (
 	// ErrConnectionFailed indicates database connection failed.
 	ErrConnectionFailed = errors.New("connection failed")
 
+	// ErrReadOnlyServer indicates the server pgmi connected to accepts only
+	// read-only transactions: a hot standby, typically, reached through a
+	// replica endpoint or a multi-host list without target_session_attrs.
+	ErrReadOnlyServer = errors.New("server is read-only")
+
 	// ErrConcurrentDeploy indicates another pgmi deployment is already in
 	// progress against the target database (Go-side advisory lock contention).
 	ErrConcurrentDeploy = errors.New("concurrent deployment in progress")
@@ -48,6 +53,10 @@ var (
 	// at the boundary where the intent is known — ExitCodeForError checks
 	// errors.Is instead of sniffing error prose.
 	ErrUsage = errors.New("usage error")
+
+	// ErrDriftDetected indicates pgmi drift found objects changed since the
+	// fingerprint the last deploy captured. The check itself succeeded.
+	ErrDriftDetected = errors.New("drift detected")
 )
 
 // ExitCodeForError returns the appropriate exit code for an error.
@@ -77,10 +86,12 @@ func ExitCodeForError(err error) int {
 		return ExitDeploySQLMissing
 	case errors.Is(err, ErrApprovalDenied):
 		return ExitApprovalDenied
-	case errors.Is(err, ErrConnectionFailed):
+	case errors.Is(err, ErrConnectionFailed), errors.Is(err, ErrReadOnlyServer):
 		return ExitConnectionError
 	case errors.Is(err, ErrUnsupportedAuthMethod):
 		return ExitConfigError
+	case errors.Is(err, ErrDriftDetected):
+		return ExitDriftDetected
 	}
 
 	// --timeout expiry. A connect timeout is already handled above, because
@@ -26,8 +26,10 @@ func TestExitCodeForError(t *testing.T) {
 		{"ErrApprovalDenied", pgmi.ErrApprovalDenied, pgmi.ExitApprovalDenied},
 		{"ErrExecutionFailed", pgmi.ErrExecutionFailed, pgmi.ExitExecutionFailed},
 		{"ErrConnectionFailed", pgmi.ErrConnectionFailed, pgmi.ExitConnectionError},
+		{"ErrReadOnlyServer", pgmi.ErrReadOnlyServer, pgmi.ExitConnectionError},
 		{"ErrUnsupportedAuthMethod", pgmi.ErrUnsupportedAuthMethod, pgmi.ExitConfigError},
 		{"ErrConcurrentDeploy", pgmi.ErrConcurrentDeploy, pgmi.ExitConcurrentDeploy},
+		{"ErrDriftDetected", pgmi.ErrDriftDetected, pgmi.ExitDriftDetected},
 
 		// SIGINT / Ctrl-C
 		{"context.Canceled", context.Canceled, pgmi.ExitInterrupted},
@@ -14,6 +14,25 @@ type FileScanner interface {
 	ReadDeploySQL(sourcePath string) (string, error)
 }
 
+// EntrypointScanner is a FileScanner that can also serve orchestrator
+// scripts other than deploy.sql (deploy --entry, pgmi.yaml entrypoints).
+// pgmi's own scanner implements it; a FileScanner that does not can still
+// deploy deploy.sql.
+type EntrypointScanner interface {
+	FileScanner
+
+	// ScanDirectoryExcluding is ScanDirectory that also keeps every
+	// entrypoint named (paths relative to sourcePath) out of the results.
+	ScanDirectoryExcluding(sourcePath string, entrypoints ...string) (FileScanResult, error)
+
+	// ValidateEntrypoint checks that the orchestrator script entry (relative
+	// to sourcePath; DefaultEntrypoint for deploy.sql) exists.
+	ValidateEntrypoint(sourcePath, entry string) error
+
+	// ReadEntrypoint reads the orchestrator script entry.
+	ReadEntrypoint(sourcePath, entry string) (string, error)
+}
+
 // FileScanResult contains the results of scanning a directory.
 type FileScanResult struct {
 	Files []FileMetadata
@@ -0,0 +1,99 @@
+package pgmi
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Types a custom <pgmi-meta> attribute can declare. A value of any other type
+// than string is checked when the project is scanned and stored in the custom
+// JSONB column as a JSON boolean or number, so deploy.sql can compare it
+// without casting.
+const (
+	CustomTypeString  = "string"
+	CustomTypeBoolean = "boolean"
+	CustomTypeInteger = "integer"
+	CustomTypeNumber  = "number"
+)
+
+// ReservedMetadataPrefixes cannot name custom attributes: xml and xmlns belong
+// to XML itself, pgmi to future built-in metadata.
+var ReservedMetadataPrefixes = []string{"pgmi", "xml", "xmlns"}
+
+// BuiltinMetadataNames are the attributes and elements <pgmi-meta> defines.
+// Go's XML decoder matches them by local name whatever the prefix, so a
+// custom ops:description would silently replace the real one; such names are
+// refused instead.
+var BuiltinMetadataNames = []string{"id", "idempotent", "description", "sortKeys", "dependsOn"}
+
+// customNamePattern is a prefixed XML name restricted to what is also safe as
+// a JSON key a SQL author types by hand.
+var customNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.-]*:[A-Za-z_][A-Za-z0-9_.-]*$`)
+
+// MetadataSchema declares a project's custom <pgmi-meta> attributes, keyed
+// by qualified name as written in the XML: "ops:owner" for ops:owner="..."
+// or <ops:owner>...</ops:owner>. A prefix with any declared name is closed:
+// an undeclared name under it is an error, which is what catches typos.
+// Prefixes the schema never mentions stay free-form strings.
+type MetadataSchema map[string]CustomAttributeSpec
+
+// CustomAttributeSpec describes one custom attribute.
+type CustomAttributeSpec struct {
+	// Type is one of the CustomType constants; empty means string.
+	Type string
+
+	// Required makes every file with a <pgmi-meta> block carry the attribute.
+	// Files without metadata have nowhere to put it and are not checked.
+	Required bool
+
+	// Values, when set, is the complete list of accepted string values.
+	Values []string
+}
+
+// SplitCustomName splits a qualified name such as "ops:owner" into its
+// prefix and local name.
+func SplitCustomName(name string) (prefix, local string, ok bool) {
+	if !customNamePattern.MatchString(name) {
+		return "", "", false
+	}
+	prefix, local, _ = strings.Cut(name, ":")
+	return prefix, local, true
+}
+
+// Validate checks the schema itself, not any file against it: names,
+// types, and that Values is only given for strings.
+func (s MetadataSchema) Validate() error {
+	names := make([]string, 0, len(s))
+	for name := range s {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var errs []error
+	for _, name := range names {
+		spec := s[name]
+		prefix, local, ok := SplitCustomName(name)
+		switch {
+		case !ok:
+			errs = append(errs, fmt.Errorf("metadata attribute %q must be a prefixed name such as ops:owner: %w", name, ErrInvalidConfig))
+			continue
+		case slices.Contains(ReservedMetadataPrefixes, strings.ToLower(prefix)):
+			errs = append(errs, fmt.Errorf("metadata attribute %q uses the reserved prefix %q: %w", name, prefix, ErrInvalidConfig))
+		case slices.Contains(BuiltinMetadataNames, local):
+			errs = append(errs, fmt.Errorf("metadata attribute %q would shadow the built-in %q: %w", name, local, ErrInvalidConfig))
+		}
+		switch spec.Type {
+		case "", CustomTypeString, CustomTypeBoolean, CustomTypeInteger, CustomTypeNumber:
+		default:
+			errs = append(errs, fmt.Errorf("metadata attribute %q has unknown type %q (string, boolean, integer or number): %w", name, spec.Type, ErrInvalidConfig))
+		}
+		if len(spec.Values) > 0 && spec.Type != "" && spec.Type != CustomTypeString {
+			errs = append(errs, fmt.Errorf("metadata attribute %q lists values, which only a string attribute can: %w", name, ErrInvalidConfig))
+		}
+	}
+	return errors.Join(errs...)
+}
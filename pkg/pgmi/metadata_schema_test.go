@@ -0,0 +1,52 @@
+package pgmi_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/vvka-141/pgmi/pkg/pgmi"
+)
+
+func TestMetadataSchema_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		schema  pgmi.MetadataSchema
+		wantErr bool
+	}{
+		{"nil schema", nil, false},
+		{"typed attributes", pgmi.MetadataSchema{
+			"ops:owner":   {Required: true},
+			"ops:window":  {Type: pgmi.CustomTypeBoolean},
+			"ops:retries": {Type: pgmi.CustomTypeInteger},
+			"ops.v2:cost": {Type: pgmi.CustomTypeNumber},
+			"ops:env":     {Type: pgmi.CustomTypeString, Values: []string{"all", "prod"}},
+		}, false},
+		{"unprefixed name", pgmi.MetadataSchema{"owner": {}}, true},
+		{"empty local name", pgmi.MetadataSchema{"ops:": {}}, true},
+		{"reserved prefix", pgmi.MetadataSchema{"PGMI:owner": {}}, true},
+		{"shadows a built-in", pgmi.MetadataSchema{"ops:sortKeys": {}}, true},
+		{"unknown type", pgmi.MetadataSchema{"ops:timeout": {Type: "duration"}}, true},
+		{"values on a boolean", pgmi.MetadataSchema{"ops:window": {Type: pgmi.CustomTypeBoolean, Values: []string{"true"}}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.schema.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() = %v, wantErr %t", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, pgmi.ErrInvalidConfig) {
+				t.Errorf("Validate() = %v, want ErrInvalidConfig", err)
+			}
+		})
+	}
+}
+
+func TestSplitCustomName(t *testing.T) {
+	prefix, local, ok := pgmi.SplitCustomName("ops:owner")
+	if !ok || prefix != "ops" || local != "owner" {
+		t.Errorf("SplitCustomName(ops:owner) = %q, %q, %t", prefix, local, ok)
+	}
+	if _, _, ok := pgmi.SplitCustomName("ops:owner:extra"); ok {
+		t.Error("a name with two colons is not a prefixed XML name")
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"time"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
@@ -17,6 +18,15 @@ type SessionPreparer interface {
 	PrepareSession(ctx context.Context, connConfig *ConnectionConfig, scanResult FileScanResult, parameters map[string]string, compat string, verbose bool) (*Session, error)
 }
 
+// EntrypointPreparer is a SessionPreparer that can prepare a deployment of
+// an orchestrator script other than deploy.sql. ScanProjectForEntry
+// validates entry (empty for deploy.sql) and keeps it, and any further
+// entrypoints, out of the scan result.
+type EntrypointPreparer interface {
+	SessionPreparer
+	ScanProjectForEntry(sourcePath, entry string, entrypoints ...string) (FileScanResult, error)
+}
+
 // ReleaseDeployLock drops the deploy advisory lock before the connection goes
 // back to the pool. Every path that acquires the lock must call it — both
 // Session.Close and any failure that abandons a session mid-preparation.
@@ -29,17 +39,78 @@ type SessionPreparer interface {
 // rollback is conditional because a bare ROLLBACK on an idle connection raises
 // a warning, and deploy notices are user-facing output.
 func ReleaseDeployLock(ctx context.Context, conn *pgxpool.Conn) {
-	if conn.Conn().PgConn().TxStatus() != 'I' {
+	rollbackOpenTx(ctx, conn.Conn())
+	_, _ = conn.Exec(ctx, "SELECT pg_advisory_unlock_all()")
+}
+
+// ReleaseDeployLockConn is ReleaseDeployLock for a connection that did not
+// come from a pool, such as the one a BorrowedConnector lends. It releases
+// the deploy lock alone: other advisory locks on the connection may be its
+// owner's.
+func ReleaseDeployLockConn(ctx context.Context, conn *pgx.Conn) {
+	rollbackOpenTx(ctx, conn)
+	_, _ = conn.Exec(ctx, "SELECT pg_advisory_unlock(hashtextextended('pgmi.deploy.' || current_database(), 0))")
+}
+
+func rollbackOpenTx(ctx context.Context, conn *pgx.Conn) {
+	if conn.PgConn().TxStatus() != 'I' {
 		_, _ = conn.Exec(ctx, "ROLLBACK")
 	}
-	_, _ = conn.Exec(ctx, "SELECT pg_advisory_unlock_all()")
 }
 
+// ResetBorrowedConn removes what a session left on a caller's connection:
+// the pg_temp tables, views, types and functions pgmi creates, all named
+// pgmi_* or _pgmi_*, and the pgmi.* settings. An open transaction is rolled
+// back first.
+//
+// It is not DISCARD ALL, which would take the caller's own temp tables,
+// prepared statements and settings with it. The other side of that: whatever
+// the deployment's own SQL left on the connection stays — temp objects under
+// other names, SET without LOCAL, session advisory locks, LISTEN.
+func ResetBorrowedConn(ctx context.Context, conn *pgx.Conn) {
+	rollbackOpenTx(ctx, conn)
+	_, _ = conn.Exec(ctx, resetBorrowedConnSQL)
+}
+
+// resetBorrowedConnSQL collects the statements before running any, since a
+// CASCADE can drop objects still to come; IF EXISTS skips those, and the
+// notice saying so is silenced.
+const resetBorrowedConnSQL = `DO $reset$
+DECLARE
+	stmt TEXT;
+BEGIN
+	PERFORM set_config('client_min_messages', 'warning', true);
+	FOREACH stmt IN ARRAY ARRAY(
+		SELECT format('DROP %s IF EXISTS pg_temp.%I CASCADE',
+		              CASE c.relkind WHEN 'v' THEN 'VIEW' WHEN 'c' THEN 'TYPE' ELSE 'TABLE' END, c.relname)
+		FROM pg_catalog.pg_class c
+		WHERE c.relnamespace = pg_catalog.pg_my_temp_schema()
+		  AND c.relkind IN ('r', 'p', 'v', 'c') AND c.relname ~ '^_?pgmi_'
+		UNION ALL
+		SELECT format('DROP %s IF EXISTS pg_temp.%I(%s) CASCADE',
+		              CASE p.prokind WHEN 'p' THEN 'PROCEDURE' ELSE 'FUNCTION' END,
+		              p.proname, pg_catalog.pg_get_function_identity_arguments(p.oid))
+		FROM pg_catalog.pg_proc p
+		WHERE p.pronamespace = pg_catalog.pg_my_temp_schema() AND p.proname ~ '^_?pgmi_'
+		UNION ALL
+		SELECT format('RESET %I.%I', 'pgmi', substr(s.name, 6))
+		FROM pg_catalog.pg_settings s
+		WHERE s.name LIKE 'pgmi.%'
+	) LOOP
+		EXECUTE stmt;
+	END LOOP;
+END
+$reset$`
+
 // Session holds database resources for a deployment. Not safe for concurrent use.
+//
+// A session runs on a connection acquired from a pool pgmi opened, or on a
+// connection the caller owns (NewBorrowedSession), which Close leaves open.
 type Session struct {
-	pool    *pgxpool.Pool
-	conn    *pgxpool.Conn
-	onClose func()
+	pool     *pgxpool.Pool
+	pooled   *pgxpool.Conn
+	borrowed *pgx.Conn
+	onClose  func()
 
 	FilesLoaded int
 }
@@ -55,22 +126,51 @@ func NewSession(pool *pgxpool.Pool, conn *pgxpool.Conn, onClose func()) *Session
 
 	return &Session{
 		pool:    pool,
-		conn:    conn,
+		pooled:  conn,
 		onClose: onClose,
 	}
 }
 
+// NewBorrowedSession creates a Session on a connection the caller owns.
+// Close releases the deploy lock and resets the connection with
+// ResetBorrowedConn, but does not close it. Panics if conn is nil.
+func NewBorrowedSession(conn *pgx.Conn, onClose func()) *Session {
+	if conn == nil {
+		panic("conn cannot be nil")
+	}
+
+	return &Session{
+		borrowed: conn,
+		onClose:  onClose,
+	}
+}
+
+// Pool returns the pool the session's connection came from, or nil for a
+// borrowed connection.
 func (s *Session) Pool() *pgxpool.Pool {
 	return s.pool
 }
 
+// Conn returns the pooled connection the session runs on, or nil for a
+// borrowed connection. SessionConn covers both.
 func (s *Session) Conn() *pgxpool.Conn {
-	return s.conn
+	return s.pooled
+}
+
+// SessionConn returns the connection the session runs on, pooled or borrowed.
+func (s *Session) SessionConn() *pgx.Conn {
+	switch {
+	case s.pooled != nil:
+		return s.pooled.Conn()
+	case s.borrowed != nil:
+		return s.borrowed
+	}
+	return nil
 }
 
 // Close releases all session resources. Idempotent.
 func (s *Session) Close() error {
-	if s.conn != nil {
+	if conn := s.SessionConn(); conn != nil {
 		// Release the deploy advisory lock synchronously before returning the
 		// connection. pool.Close() below tears the connection down client-side,
 		// but the server drops a session-scoped advisory lock only once it has
@@ -78,11 +178,19 @@ func (s *Session) Close() error {
 		// database can outrace, spuriously hitting ErrConcurrentDeploy. The
 		// explicit unlock is a round-trip that completes before the next acquire.
 		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-		ReleaseDeployLock(ctx, s.conn)
+		if s.pooled != nil {
+			ReleaseDeployLock(ctx, s.pooled)
+		} else {
+			ReleaseDeployLockConn(ctx, conn)
+			ResetBorrowedConn(ctx, conn)
+		}
 		cancel()
 
-		s.conn.Release()
-		s.conn = nil
+		if s.pooled != nil {
+			s.pooled.Release()
+		}
+		s.pooled = nil
+		s.borrowed = nil
 	}
 
 	if s.pool != nil {
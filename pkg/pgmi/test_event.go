@@ -0,0 +1,43 @@
+package pgmi
+
+import "strings"
+
+// Test lifecycle events, as pg_temp.pgmi_test_event names them.
+const (
+	TestEventSuiteStart    = "suite_start"
+	TestEventSuiteEnd      = "suite_end"
+	TestEventFixtureStart  = "fixture_start"
+	TestEventTestStart     = "test_start"
+	TestEventRollback      = "rollback"
+	TestEventTeardownStart = "teardown_start"
+)
+
+// testEventNotices maps the messages of pg_temp.pgmi_test_callback to the
+// events that raised them. An entry ending in ": " is followed by a path.
+var testEventNotices = []struct{ prefix, event string }{
+	{"[pgmi] Test suite started", TestEventSuiteStart},
+	{"[pgmi] Test suite completed", TestEventSuiteEnd},
+	{"[pgmi] Fixture: ", TestEventFixtureStart},
+	{"[pgmi] Test: ", TestEventTestStart},
+	{"[pgmi] Rollback: ", TestEventRollback},
+	{"[pgmi] Teardown: ", TestEventTeardownStart},
+}
+
+// ParseTestEventNotice recognises a notice the default test callback raised
+// and returns its event and path. The path is the script for fixtures and
+// tests, the directory for rollbacks and teardowns, and empty for the suite.
+// ok is false for any other message, including those of a project's own
+// callback.
+func ParseTestEventNotice(message string) (event, path string, ok bool) {
+	for _, m := range testEventNotices {
+		rest, found := strings.CutPrefix(message, m.prefix)
+		if !found {
+			continue
+		}
+		if strings.HasSuffix(m.prefix, ": ") {
+			path = rest
+		}
+		return m.event, path, true
+	}
+	return "", "", false
+}
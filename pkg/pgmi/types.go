@@ -4,6 +4,8 @@ import (
 	"errors"
 	"fmt"
 	"maps"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -31,6 +33,35 @@ type DeploymentConfig struct {
 	// Force bypasses interactive approval prompts; a no-op when no prompt would occur
 	Force bool
 
+	// Entry is the orchestrator script to execute, relative to SourcePath.
+	// Empty means deploy.sql.
+	Entry string
+
+	// Entrypoints lists every orchestrator script the project declares besides
+	// deploy.sql. None of them, nor Entry, is loaded into _pgmi_source.
+	Entrypoints []string
+
+	// Resume records each committed psql-mode tail unit on the server and, when
+	// an earlier resume run of the byte-identical deploy.sql failed mid-tail,
+	// skips the tail units it already committed. The head always runs.
+	Resume bool
+
+	// TemplateDatabase, when set, deploys into this database instead, marks it
+	// IS_TEMPLATE, and creates DatabaseName as a copy of it. The template is
+	// keyed by a hash of the project's files, entry script, parameters and
+	// compat level, and rebuilt only when that key changes.
+	TemplateDatabase string
+
+	// Ephemeral deploys into a new database named DatabaseName plus a unique
+	// suffix, and drops it when the run ends however it ends. The database is
+	// labelled in pg_database so `pgmi gc` can remove any a crash left behind.
+	Ephemeral bool
+
+	// MetadataSchema types and checks the custom attributes in <pgmi-meta>
+	// blocks, from the metadata section of pgmi.yaml. Nil accepts any
+	// namespaced attribute as a string.
+	MetadataSchema MetadataSchema
+
 	// Parameters are key-value pairs passed to pgmi_params table
 	Parameters map[string]string
 
@@ -51,6 +82,11 @@ type DeploymentConfig struct {
 	AzureTenantID     string
 	AzureClientID     string
 	AzureClientSecret string
+
+	// External token parameters (used when AuthMethod is AuthMethodExec or
+	// AuthMethodTokenFile); see ConnectionConfig.
+	TokenCommand []string
+	TokenFile    string
 }
 
 // Validate checks if the DeploymentConfig has all required fields and valid values.
@@ -70,6 +106,47 @@ func (c *DeploymentConfig) Validate() error {
 		errs = append(errs, fmt.Errorf("ConnectionString is required: %w", ErrInvalidConfig))
 	}
 
+	if c.Entry != "" {
+		if err := ValidateEntrypointPath(c.Entry); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if c.TemplateDatabase != "" {
+		switch {
+		case strings.EqualFold(c.TemplateDatabase, c.DatabaseName):
+			errs = append(errs, fmt.Errorf("the template database must differ from the target %q: %w", c.DatabaseName, ErrInvalidConfig))
+		case IsTemplateDatabase(c.TemplateDatabase):
+			errs = append(errs, fmt.Errorf("cannot deploy into %q: template0/template1 belong to PostgreSQL: %w", c.TemplateDatabase, ErrInvalidConfig))
+		}
+		if c.Resume {
+			// A clone is created whole from a template that was deployed
+			// whole; there is no half-committed tail to resume into it.
+			errs = append(errs, fmt.Errorf("--resume cannot be combined with a template database: %w", ErrInvalidConfig))
+		}
+	}
+
+	if c.Ephemeral {
+		// Each of these either acts on an existing database or leaves one
+		// behind on purpose; an ephemeral run has neither.
+		for _, conflict := range []struct {
+			flag string
+			set  bool
+		}{
+			{"--overwrite", c.Overwrite},
+			{"--resume", c.Resume},
+			{"--template-db", c.TemplateDatabase != ""},
+		} {
+			if conflict.set {
+				errs = append(errs, fmt.Errorf("%s cannot be combined with an ephemeral deploy: %w", conflict.flag, ErrInvalidConfig))
+			}
+		}
+	}
+
+	if err := c.MetadataSchema.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+
 	// Validate timeout if set
 	if c.Timeout < 0 {
 		errs = append(errs, fmt.Errorf("timeout cannot be negative: %w", ErrInvalidConfig))
@@ -87,6 +164,16 @@ type ConnectionConfig struct {
 	Password string
 	SSLMode  string
 
+	// FallbackHosts are the further servers of a multi-host connection, as
+	// in libpq's host=a,b,c. They are tried in order after Host:Port until
+	// one accepts the connection and satisfies TargetSessionAttrs.
+	FallbackHosts []HostPort
+
+	// TargetSessionAttrs is libpq's target_session_attrs: any (the
+	// default, also when empty), read-write, read-only, primary, standby or
+	// prefer-standby.
+	TargetSessionAttrs string
+
 	// AuthMethod indicates the authentication mechanism to use
 	AuthMethod AuthMethod
 
@@ -110,12 +197,32 @@ type ConnectionConfig struct {
 	// GoogleInstance is the instance connection name in format: project:region:instance
 	GoogleInstance string
 
-	// TLS client certificate parameters (transport-layer, not auth method)
-	// pgx handles mTLS natively via these connection string parameters
+	// External token parameters. TokenCommand (used when AuthMethod is
+	// AuthMethodExec) is the program and arguments printing a token as JSON.
+	// TokenFile (used when AuthMethod is AuthMethodTokenFile) is a file an
+	// agent such as Vault Agent or a SPIFFE helper keeps a current token in.
+	TokenCommand []string
+	TokenFile    string
+
+	// Kerberos parameters (used when AuthMethod is AuthMethodGSSAPI), libpq's
+	// krbsrvname and krbspn. KerberosServiceName defaults to "postgres";
+	// KerberosSPN names the whole service principal instead.
+	KerberosServiceName string
+	KerberosSPN         string
+
+	// TLS client certificate parameters. pgx handles mTLS natively via these
+	// connection string parameters; with AuthMethodCert the certificate is
+	// the only credential.
 	SSLCert     string
 	SSLKey      string
 	SSLRootCert string
 	SSLPassword string
+
+	// Service is the pg_service.conf entry the parameters were taken from,
+	// and ServiceFile the file it was found in. The other fields already
+	// hold its settings; these record where they came from.
+	Service     string
+	ServiceFile string
 }
 
 // String renders the config with its three secrets — Password,
@@ -152,9 +259,30 @@ func (c *ConnectionConfig) DeepCopy() ConnectionConfig {
 	if c.AdditionalParams != nil {
 		cp.AdditionalParams = maps.Clone(c.AdditionalParams)
 	}
+	cp.TokenCommand = slices.Clone(c.TokenCommand)
+	cp.FallbackHosts = slices.Clone(c.FallbackHosts)
 	return cp
 }
 
+// HostPort is one server of a multi-host connection.
+type HostPort struct {
+	Host string
+	Port int // 0 means the ConnectionConfig's Port
+}
+
+// Hosts returns every server the config names, Host:Port first, with each
+// fallback's port filled in.
+func (c *ConnectionConfig) Hosts() []HostPort {
+	hosts := []HostPort{{Host: c.Host, Port: c.Port}}
+	for _, h := range c.FallbackHosts {
+		if h.Port == 0 {
+			h.Port = c.Port
+		}
+		hosts = append(hosts, h)
+	}
+	return hosts
+}
+
 // AuthMethod represents the type of authentication to use.
 type AuthMethod int
 
@@ -163,6 +291,10 @@ const (
 	AuthMethodAWSIAM                         // AWS IAM Database Authentication
 	AuthMethodGoogleIAM                      // Google Cloud SQL IAM
 	AuthMethodAzureEntraID                   // Azure Active Directory (Entra ID)
+	AuthMethodExec                           // Token printed by an external command
+	AuthMethodTokenFile                      // Token read from a file kept current by an agent
+	AuthMethodGSSAPI                         // Kerberos via GSSAPI
+	AuthMethodCert                           // TLS client certificate alone, no password
 )
 
 // String returns a human-readable string representation of the AuthMethod.
@@ -176,6 +308,14 @@ func (a AuthMethod) String() string {
 		return "Google IAM"
 	case AuthMethodAzureEntraID:
 		return "Azure Entra ID"
+	case AuthMethodExec:
+		return "Exec"
+	case AuthMethodTokenFile:
+		return "Token File"
+	case AuthMethodGSSAPI:
+		return "GSSAPI"
+	case AuthMethodCert:
+		return "Client Certificate"
 	default:
 		return fmt.Sprintf("Unknown(%d)", a)
 	}
@@ -218,6 +358,8 @@ type FileMetadata struct {
 //   - Idempotent: Whether the script can be safely rerun
 //   - SortKeys: Array of execution keys enabling multi-phase execution
 //   - Description: Human-readable purpose of the script
+//   - DependsOn: Scripts that must run before this one
+//   - Custom: Namespaced attributes the project defines for itself
 //
 // Multi-Phase Execution:
 //
@@ -249,4 +391,15 @@ type ScriptMetadata struct {
 	// Description is a human-readable explanation of what the script does.
 	// Optional, but highly recommended for maintainability.
 	Description string
+
+	// DependsOn names the scripts that must run before this one, each by its
+	// <pgmi-meta> id or project path, exactly as written in <dependsOn>.
+	// Resolved and checked project-wide by metadata.ResolveDependencies.
+	DependsOn []string
+
+	// Custom holds the namespaced attributes and elements of the block, by
+	// prefix and then local name: ops:owner="payments" is
+	// Custom["ops"]["owner"]. Values are strings, []string for a repeated
+	// element, until metadata.ApplySchema types them. Nil when there are none.
+	Custom map[string]map[string]any
 }
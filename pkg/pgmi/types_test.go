@@ -96,6 +96,95 @@ func TestDeploymentConfig_Validate(t *testing.T) {
 			wantError: true,
 			errorType: pgmi.ErrInvalidConfig,
 		},
+		{
+			name: "template database distinct from the target",
+			config: pgmi.DeploymentConfig{
+				SourcePath:       "./migrations",
+				DatabaseName:     "job_1",
+				ConnectionString: "postgresql://localhost:5432/postgres",
+				TemplateDatabase: "app_tpl",
+			},
+			wantError: false,
+		},
+		{
+			name: "template database same as the target",
+			config: pgmi.DeploymentConfig{
+				SourcePath:       "./migrations",
+				DatabaseName:     "app_tpl",
+				ConnectionString: "postgresql://localhost:5432/postgres",
+				TemplateDatabase: "APP_TPL",
+			},
+			wantError: true,
+			errorType: pgmi.ErrInvalidConfig,
+		},
+		{
+			name: "template1 as the template database",
+			config: pgmi.DeploymentConfig{
+				SourcePath:       "./migrations",
+				DatabaseName:     "job_1",
+				ConnectionString: "postgresql://localhost:5432/postgres",
+				TemplateDatabase: "template1",
+			},
+			wantError: true,
+			errorType: pgmi.ErrInvalidConfig,
+		},
+		{
+			name: "template database with resume",
+			config: pgmi.DeploymentConfig{
+				SourcePath:       "./migrations",
+				DatabaseName:     "job_1",
+				ConnectionString: "postgresql://localhost:5432/postgres",
+				TemplateDatabase: "app_tpl",
+				Resume:           true,
+			},
+			wantError: true,
+			errorType: pgmi.ErrInvalidConfig,
+		},
+		{
+			name: "ephemeral deploy",
+			config: pgmi.DeploymentConfig{
+				SourcePath:       "./migrations",
+				DatabaseName:     "pr_check",
+				ConnectionString: "postgresql://localhost:5432/postgres",
+				Ephemeral:        true,
+			},
+			wantError: false,
+		},
+		{
+			name: "ephemeral deploy with overwrite",
+			config: pgmi.DeploymentConfig{
+				SourcePath:       "./migrations",
+				DatabaseName:     "pr_check",
+				ConnectionString: "postgresql://localhost:5432/postgres",
+				Ephemeral:        true,
+				Overwrite:        true,
+			},
+			wantError: true,
+			errorType: pgmi.ErrInvalidConfig,
+		},
+		{
+			name: "ephemeral deploy through a template",
+			config: pgmi.DeploymentConfig{
+				SourcePath:       "./migrations",
+				DatabaseName:     "pr_check",
+				ConnectionString: "postgresql://localhost:5432/postgres",
+				Ephemeral:        true,
+				TemplateDatabase: "app_tpl",
+			},
+			wantError: true,
+			errorType: pgmi.ErrInvalidConfig,
+		},
+		{
+			name: "invalid metadata schema",
+			config: pgmi.DeploymentConfig{
+				SourcePath:       "./migrations",
+				DatabaseName:     "mydb",
+				ConnectionString: "postgresql://localhost:5432/postgres",
+				MetadataSchema:   pgmi.MetadataSchema{"owner": {}},
+			},
+			wantError: true,
+			errorType: pgmi.ErrInvalidConfig,
+		},
 		{
 			name: "multiple validation errors",
 			config: pgmi.DeploymentConfig{
@@ -174,6 +263,16 @@ func TestConnectionConfig_DeepCopy(t *testing.T) {
 			t.Errorf("expected empty map in copy, got %d entries", len(cp.AdditionalParams))
 		}
 	})
+
+	t.Run("copies TokenCommand independently", func(t *testing.T) {
+		orig := pgmi.ConnectionConfig{TokenCommand: []string{"vault-token", "--role", "deploy"}}
+		cp := orig.DeepCopy()
+
+		cp.TokenCommand[2] = "admin"
+		if orig.TokenCommand[2] != "deploy" {
+			t.Error("DeepCopy did not isolate the TokenCommand slice")
+		}
+	})
 }
 
 func TestAuthMethod_String(t *testing.T) {
@@ -185,6 +284,10 @@ func TestAuthMethod_String(t *testing.T) {
 		{pgmi.AuthMethodAWSIAM, "AWS IAM"},
 		{pgmi.AuthMethodGoogleIAM, "Google IAM"},
 		{pgmi.AuthMethodAzureEntraID, "Azure Entra ID"},
+		{pgmi.AuthMethodExec, "Exec"},
+		{pgmi.AuthMethodTokenFile, "Token File"},
+		{pgmi.AuthMethodGSSAPI, "GSSAPI"},
+		{pgmi.AuthMethodCert, "Client Certificate"},
 		{pgmi.AuthMethod(99), "Unknown(99)"},
 	}
 